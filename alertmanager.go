@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+
+	objectivesv1alpha1 "github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1"
+	"github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1/objectivesv1alpha1connect"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+type AlertmanagerWebhookConfig struct {
+	AlertmanagerWebhookForwardURL *url.URL `name:"alertmanager-webhook-forward-url" help:"If set Alertmanager webhooks received on /alertmanager/webhook are enriched with the error budget, window and link of the alert's objective, and forwarded to this URL."`
+	AlertmanagerWebhookPyrraURL   *url.URL `name:"alertmanager-webhook-pyrra-url" help:"The external URL of Pyrra to link objectives in enriched alerts. No links are added if empty."`
+}
+
+// alertmanagerWebhook is the payload of Alertmanager's webhook receiver.
+// https://prometheus.io/docs/alerting/latest/configuration/#webhook_config
+type alertmanagerWebhook struct {
+	Version           string              `json:"version"`
+	GroupKey          string              `json:"groupKey"`
+	TruncatedAlerts   int                 `json:"truncatedAlerts"`
+	Status            string              `json:"status"`
+	Receiver          string              `json:"receiver"`
+	GroupLabels       map[string]string   `json:"groupLabels"`
+	CommonLabels      map[string]string   `json:"commonLabels"`
+	CommonAnnotations map[string]string   `json:"commonAnnotations"`
+	ExternalURL       string              `json:"externalURL"`
+	Alerts            []alertmanagerAlert `json:"alerts"`
+}
+
+type alertmanagerAlert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// alertEnricher receives Alertmanager webhooks, adds annotations about the objective of Pyrra's alerts and forwards them.
+type alertEnricher struct {
+	logger     log.Logger
+	client     objectivesv1alpha1connect.ObjectiveBackendServiceClient
+	promAPI    budgetQuerier
+	forwardURL string
+	pyrraURL   string
+	httpClient *http.Client
+}
+
+func (e *alertEnricher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var payload alertmanagerWebhook
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode webhook: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Enriching is best effort, alerts are forwarded as they are if it fails.
+	if err := e.enrich(r.Context(), &payload); err != nil {
+		level.Warn(e.logger).Log("msg", "failed to enrich alerts", "err", err)
+	}
+
+	if err := postJSON(r.Context(), e.httpClient, e.forwardURL, payload); err != nil {
+		level.Warn(e.logger).Log("msg", "failed to forward alerts", "err", err)
+		// Alertmanager retries the webhook on errors.
+		http.Error(w, "failed to forward alerts", http.StatusBadGateway)
+		return
+	}
+}
+
+func (e *alertEnricher) enrich(ctx context.Context, payload *alertmanagerWebhook) error {
+	resp, err := e.client.List(ctx, connect.NewRequest(&objectivesv1alpha1.ListRequest{}))
+	if err != nil {
+		return fmt.Errorf("failed to list objectives: %w", err)
+	}
+	objectives := make([]slo.Objective, 0, len(resp.Msg.Objectives))
+	for _, o := range resp.Msg.Objectives {
+		objectives = append(objectives, objectivesv1alpha1.ToInternal(o))
+	}
+
+	for i, alert := range payload.Alerts {
+		if alert.Labels["slo"] == "" {
+			continue
+		}
+
+		sample := &model.Sample{Metric: model.Metric{}}
+		for name, value := range alert.Labels {
+			sample.Metric[model.LabelName(name)] = model.LabelValue(value)
+		}
+
+		for _, objective := range objectives {
+			if len(alertsMatchingObjectives(model.Vector{sample}, []slo.Objective{objective}, nil, false)) == 0 {
+				continue
+			}
+			if alert.Annotations == nil {
+				alert.Annotations = map[string]string{}
+			}
+			e.annotate(ctx, alert.Annotations, objective, sample.Metric)
+			payload.Alerts[i] = alert
+			break
+		}
+	}
+
+	return nil
+}
+
+func (e *alertEnricher) annotate(ctx context.Context, annotations map[string]string, objective slo.Objective, alert model.Metric) {
+	annotations["pyrra_objective"] = budgetEvent{Objective: objective}.objectiveName()
+	annotations["pyrra_target"] = fmt.Sprintf("%g%%", 100*objective.Target)
+	annotations["pyrra_window"] = objective.Window.String()
+	if e.pyrraURL != "" {
+		annotations["pyrra_url"] = objectivePageURL(e.pyrraURL, objective)
+	}
+
+	value, _, err := e.promAPI.Query(ctx, objective.QueryErrorBudget(), time.Now())
+	if err != nil {
+		level.Warn(e.logger).Log("msg", "failed to query error budget", "objective", objective.Name(), "err", err)
+		return
+	}
+	vector, ok := value.(model.Vector)
+	if !ok {
+		return
+	}
+	// Grouped objectives have a budget per group, pick the one the alert belongs to.
+	for _, s := range vector {
+		if labelsSubset(s.Metric, alert) {
+			annotations["pyrra_error_budget_remaining"] = fmt.Sprintf("%.2f%%", 100*float64(s.Value))
+			return
+		}
+	}
+}
+
+// labelsSubset returns true if all labels of a are in b.
+func labelsSubset(a, b model.Metric) bool {
+	for name, value := range a {
+		if b[name] != value {
+			return false
+		}
+	}
+	return true
+}
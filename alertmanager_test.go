@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+func TestAlertEnricher(t *testing.T) {
+	objective := reportObjective("api", "")
+	objective.Labels = labels.FromStrings(labels.MetricName, "api", "namespace", "monitoring")
+	window := objective.Windows()[0]
+
+	var forwarded alertmanagerWebhook
+	forwardStatus := http.StatusOK
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&forwarded))
+		w.WriteHeader(forwardStatus)
+	}))
+	defer downstream.Close()
+
+	enricher := &alertEnricher{
+		logger: log.NewNopLogger(),
+		client: staticBackend{objectives: []slo.Objective{objective}},
+		promAPI: queryFunc(func(query string) model.Value {
+			require.Equal(t, objective.QueryErrorBudget(), query)
+			return model.Vector{
+				{Metric: model.Metric{"handler": "/a"}, Value: 0.9},
+				{Metric: model.Metric{"handler": "/b"}, Value: 0.4213},
+			}
+		}),
+		forwardURL: downstream.URL,
+		pyrraURL:   "https://pyrra.example.com/",
+		httpClient: downstream.Client(),
+	}
+
+	webhook := func(payload string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		enricher.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/alertmanager/webhook", bytes.NewBufferString(payload)))
+		return rec
+	}
+
+	payload, err := json.Marshal(alertmanagerWebhook{
+		Version:  "4",
+		Status:   "firing",
+		Receiver: "pyrra",
+		Alerts: []alertmanagerAlert{{
+			Status: "firing",
+			Labels: map[string]string{
+				"alertname": "ErrorBudgetBurn",
+				"slo":       "api",
+				"namespace": "monitoring",
+				"handler":   "/b",
+				"severity":  string(window.Severity),
+				"short":     model.Duration(window.Short).String(),
+				"long":      model.Duration(window.Long).String(),
+			},
+			Annotations: map[string]string{"runbook_url": "https://runbooks.example.com"},
+		}, {
+			Status: "firing",
+			Labels: map[string]string{"alertname": "HighMemory"},
+		}, {
+			Status: "firing",
+			// The windows don't match the ones of the objective.
+			Labels: map[string]string{"alertname": "ErrorBudgetBurn", "slo": "api", "namespace": "monitoring", "short": "1m", "long": "2m"},
+		}},
+	})
+	require.NoError(t, err)
+
+	rec := webhook(string(payload))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	require.Equal(t, "4", forwarded.Version)
+	require.Len(t, forwarded.Alerts, 3)
+	require.Equal(t, map[string]string{
+		"runbook_url":                  "https://runbooks.example.com",
+		"pyrra_objective":              "monitoring/api",
+		"pyrra_target":                 "99%",
+		"pyrra_window":                 "4w",
+		"pyrra_error_budget_remaining": "42.13%",
+		"pyrra_url":                    "https://pyrra.example.com/objectives?expr=%7B__name__%3D%22api%22%2C+namespace%3D%22monitoring%22%7D",
+	}, forwarded.Alerts[0].Annotations)
+	require.Empty(t, forwarded.Alerts[1].Annotations)
+	require.Empty(t, forwarded.Alerts[2].Annotations)
+
+	forwardStatus = http.StatusInternalServerError
+	require.Equal(t, http.StatusBadGateway, webhook(string(payload)).Code)
+
+	require.Equal(t, http.StatusBadRequest, webhook("{").Code)
+}
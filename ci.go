@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pmezard/go-difflib/difflib"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	prometheusapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"sigs.k8s.io/yaml"
+
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+// ciResult is the outcome of checking a single changed objective file.
+type ciResult struct {
+	File      string
+	Objective string
+	// Removed is true if the file was deleted.
+	Removed  bool
+	Err      error
+	Warnings []string
+	// Diff of the generated rules against the base, empty if unchanged.
+	Diff     string
+	Backtest *objectiveReport
+}
+
+// readBase returns the content of the file at the git ref or nil if it doesn't exist there.
+type readBase func(ref, file string) ([]byte, error)
+
+func gitShow(ref, file string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "show", ref+":./"+file)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(stderr.String(), "does not exist") || strings.Contains(stderr.String(), "exists on disk, but not in") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("git show %s:%s: %s", ref, file, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+func cmdCIComment(
+	logger log.Logger,
+	out io.Writer,
+	files []string,
+	base string,
+	promAPI budgetQuerier,
+	status *githubStatus,
+) int {
+	ctx := context.Background()
+
+	results := make([]ciResult, 0, len(files))
+	for _, file := range files {
+		results = append(results, checkObjectiveFile(ctx, gitShow, file, base, promAPI))
+	}
+
+	if _, err := io.WriteString(out, ciMarkdown(results)); err != nil {
+		level.Error(logger).Log("msg", "failed to write comment", "err", err)
+		return 1
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+
+	if status != nil {
+		state, description := "success", fmt.Sprintf("%d objectives valid", len(results)-failed)
+		if failed > 0 {
+			state, description = "failure", fmt.Sprintf("%d of %d objectives invalid", failed, len(results))
+		}
+		if err := status.set(ctx, state, description); err != nil {
+			level.Error(logger).Log("msg", "failed to set commit status", "err", err)
+			return 1
+		}
+	}
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func checkObjectiveFile(ctx context.Context, read readBase, file, base string, promAPI budgetQuerier) ciResult {
+	result := ciResult{File: file}
+
+	var baseRules []byte
+	if base != "" {
+		content, err := read(base, file)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		if content != nil {
+			// The base might be invalid too, the diff then shows all rules as added.
+			if _, objective, err := objectiveFromBytes(file, content); err == nil {
+				baseRules, _ = objectiveRules(objective)
+			}
+		}
+	}
+
+	content, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		result.Removed = true
+		result.Diff = rulesDiff(baseRules, nil)
+		return result
+	}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	kubeObjective, objective, err := objectiveFromBytes(file, content)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Objective = objective.Name()
+	if ns := kubeObjective.GetNamespace(); ns != "" {
+		result.Objective = ns + "/" + result.Objective
+	}
+
+	warnings, err := kubeObjective.ValidateCreate()
+	result.Warnings = warnings
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	rules, err := objectiveRules(objective)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Diff = rulesDiff(baseRules, rules)
+
+	if promAPI != nil {
+		r := &reporter{promAPI: promAPI}
+		report, err := r.objectiveReport(ctx, objective, time.Now(), time.Duration(objective.Window))
+		if err == nil {
+			result.Backtest = &report
+		}
+	}
+
+	return result
+}
+
+// objectiveRules returns the rules generated for the objective as YAML.
+func objectiveRules(objective slo.Objective) ([]byte, error) {
+	increases, err := objective.IncreaseRules()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get increase rules: %w", err)
+	}
+	burnrates, err := objective.Burnrates()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get burn rate rules: %w", err)
+	}
+	groups := []monitoringv1.RuleGroup{increases, burnrates}
+	if objective.SharedBurnrates {
+		// The shared burn rates are written to a PrometheusRule of the namespace, only the objective's own are shown.
+		shared, err := slo.SharedBurnrates("pyrra-shared-burnrates", []slo.Objective{objective})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get shared burn rate rules: %w", err)
+		}
+		groups = append(groups, shared)
+	}
+	if objective.BudgetFreeze != nil {
+		freeze, err := objective.BudgetFreezeRules()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get budget freeze rules: %w", err)
+		}
+		groups = append(groups, freeze)
+	}
+	if objective.Calendar != "" {
+		calendar, err := objective.CalendarRules()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get calendar rules: %w", err)
+		}
+		groups = append(groups, calendar)
+	}
+	if objective.SLATarget != nil {
+		sla, err := objective.SLARules()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get SLA rules: %w", err)
+		}
+		groups = append(groups, sla)
+	}
+	return yaml.Marshal(monitoringv1.PrometheusRuleSpec{Groups: groups})
+}
+
+func rulesDiff(base, head []byte) string {
+	if bytes.Equal(base, head) {
+		return ""
+	}
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(base)),
+		B:        difflib.SplitLines(string(head)),
+		FromFile: "base",
+		ToFile:   "head",
+		Context:  3,
+	})
+	return diff
+}
+
+func ciMarkdown(results []ciResult) string {
+	var b strings.Builder
+	b.WriteString("## Pyrra\n\n")
+	if len(results) == 0 {
+		b.WriteString("No objectives changed.\n")
+		return b.String()
+	}
+
+	b.WriteString("| File | Objective | Result |\n")
+	b.WriteString("|------|-----------|--------|\n")
+	for _, r := range results {
+		result := ":white_check_mark: valid"
+		switch {
+		case r.Err != nil:
+			result = ":x: " + strings.ReplaceAll(r.Err.Error(), "|", "\\|")
+		case r.Removed:
+			result = ":wastebasket: removed"
+		case len(r.Warnings) > 0:
+			result = ":warning: " + strings.ReplaceAll(strings.Join(r.Warnings, ", "), "|", "\\|")
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s |\n", r.File, r.Objective, result)
+	}
+
+	for _, r := range results {
+		if r.Backtest == nil && r.Diff == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### `%s`\n", r.File)
+
+		if bt := r.Backtest; bt != nil {
+			fmt.Fprintf(&b, "\nBacktest: %.3f%% availability for a target of %.3f%%, %.1f%% of the error budget remaining.\n",
+				100*bt.Availability, 100*bt.Target, 100*(1-bt.BudgetConsumed))
+		}
+		if r.Diff != "" {
+			fmt.Fprintf(&b, "\n<details><summary>Rule changes</summary>\n\n```diff\n%s```\n\n</details>\n", r.Diff)
+		}
+	}
+
+	return b.String()
+}
+
+// promQuerier queries the Prometheus API directly without caching.
+type promQuerier struct {
+	api prometheusapiv1.API
+}
+
+func (q promQuerier) Query(ctx context.Context, query string, ts time.Time) (model.Value, prometheusapiv1.Warnings, error) {
+	return q.api.Query(ctx, query, ts)
+}
+
+// githubStatus sets the status of a commit on GitHub.
+type githubStatus struct {
+	url        string
+	token      string
+	repository string
+	sha        string
+	client     *http.Client
+}
+
+func (s *githubStatus) set(ctx context.Context, state, description string) error {
+	req, err := newJSONRequest(ctx, http.MethodPost,
+		fmt.Sprintf("%s/repos/%s/statuses/%s", strings.TrimSuffix(s.url, "/"), s.repository, url.PathEscape(s.sha)),
+		map[string]string{
+			"state":       state,
+			"description": description,
+			"context":     "pyrra",
+		},
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	return doJSON(s.client, req, nil)
+}
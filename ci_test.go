@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+const ciObjective = `apiVersion: pyrra.dev/v1alpha1
+kind: ServiceLevelObjective
+metadata:
+  name: api-errors
+  namespace: monitoring
+spec:
+  target: "%s"
+  window: 4w
+  indicator:
+    ratio:
+      errors:
+        metric: http_requests_total{job="api",code=~"5.."}
+      total:
+        metric: http_requests_total{job="api"}
+`
+
+func TestCheckObjectiveFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "api.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(strings.Replace(ciObjective, "%s", "99", 1)), 0o644))
+
+	base := func(target string) readBase {
+		return func(ref, f string) ([]byte, error) {
+			require.Equal(t, "origin/main", ref)
+			require.Equal(t, file, f)
+			if target == "" {
+				return nil, nil
+			}
+			return []byte(strings.Replace(ciObjective, "%s", target, 1)), nil
+		}
+	}
+
+	t.Run("unchanged", func(t *testing.T) {
+		result := checkObjectiveFile(context.Background(), base("99"), file, "origin/main", nil)
+		require.NoError(t, result.Err)
+		require.Equal(t, "monitoring/api-errors", result.Objective)
+		require.Empty(t, result.Diff)
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		result := checkObjectiveFile(context.Background(), base("99.9"), file, "origin/main", nil)
+		require.NoError(t, result.Err)
+		require.Contains(t, result.Diff, `-      and http_requests:burnrate1h{job="api",slo="api-errors"} > (14 * (1-0.9990000000000001))`)
+		require.Contains(t, result.Diff, `+      http_requests:burnrate1h{job="api",slo="api-errors"} > (14 * (1-0.99))`)
+	})
+
+	t.Run("added", func(t *testing.T) {
+		result := checkObjectiveFile(context.Background(), base(""), file, "origin/main", nil)
+		require.NoError(t, result.Err)
+		require.Contains(t, result.Diff, "+  name: api-errors-increase")
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		removed := filepath.Join(dir, "removed.yaml")
+		result := checkObjectiveFile(context.Background(), func(string, string) ([]byte, error) {
+			return []byte(strings.Replace(ciObjective, "%s", "99", 1)), nil
+		}, removed, "origin/main", nil)
+		require.NoError(t, result.Err)
+		require.True(t, result.Removed)
+		require.Contains(t, result.Diff, "-  name: api-errors-increase")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		invalid := filepath.Join(dir, "invalid.yaml")
+		require.NoError(t, os.WriteFile(invalid, []byte(strings.Replace(ciObjective, "%s", "101", 1)), 0o644))
+		result := checkObjectiveFile(context.Background(), nil, invalid, "", nil)
+		require.Error(t, result.Err)
+	})
+
+	t.Run("backtest", func(t *testing.T) {
+		result := checkObjectiveFile(context.Background(), nil, file, "", queryFunc(func(query string) model.Value {
+			return model.Vector{{Value: 0.002}}
+		}))
+		require.NoError(t, result.Err)
+		require.NotNil(t, result.Backtest)
+		require.InDelta(t, 0.998, result.Backtest.Availability, 1e-9)
+		require.InDelta(t, 0.2, result.Backtest.BudgetConsumed, 1e-9)
+	})
+}
+
+func TestCIMarkdown(t *testing.T) {
+	require.Equal(t, "## Pyrra\n\nNo objectives changed.\n", ciMarkdown(nil))
+
+	require.Equal(t, "## Pyrra\n\n"+
+		"| File | Objective | Result |\n"+
+		"|------|-----------|--------|\n"+
+		"| `api.yaml` | monitoring/api | :white_check_mark: valid |\n"+
+		"| `broken.yaml` |  | :x: target a \\| b is invalid |\n"+
+		"| `old.yaml` |  | :wastebasket: removed |\n"+
+		"\n### `api.yaml`\n"+
+		"\nBacktest: 99.950% availability for a target of 99.900%, 50.0% of the error budget remaining.\n"+
+		"\n<details><summary>Rule changes</summary>\n\n```diff\n-a\n+b\n```\n\n</details>\n",
+		ciMarkdown([]ciResult{{
+			File:      "api.yaml",
+			Objective: "monitoring/api",
+			Diff:      "-a\n+b\n",
+			Backtest:  &objectiveReport{Target: 0.999, Availability: 0.9995, BudgetConsumed: 0.5},
+		}, {
+			File: "broken.yaml",
+			Err:  errors.New("target a | b is invalid"),
+		}, {
+			File:    "old.yaml",
+			Removed: true,
+		}}),
+	)
+}
+
+func TestGitHubStatus(t *testing.T) {
+	var payload map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/repos/pyrra-dev/pyrra/statuses/abc123", r.URL.Path)
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	status := &githubStatus{
+		url:        server.URL,
+		token:      "token",
+		repository: "pyrra-dev/pyrra",
+		sha:        "abc123",
+		client:     server.Client(),
+	}
+	require.NoError(t, status.set(context.Background(), "failure", "1 of 2 objectives invalid"))
+	require.Equal(t, map[string]string{
+		"state":       "failure",
+		"description": "1 of 2 objectives invalid",
+		"context":     "pyrra",
+	}, payload)
+}
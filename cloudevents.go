@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"github.com/prometheus/prometheus/model/labels"
+	"google.golang.org/protobuf/proto"
+
+	objectivesv1alpha1 "github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1"
+	"github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1/objectivesv1alpha1connect"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+// CloudEvents types emitted by Pyrra.
+const (
+	cloudEventObjectiveCreated = "dev.pyrra.objective.created"
+	cloudEventObjectiveUpdated = "dev.pyrra.objective.updated"
+	cloudEventObjectiveDeleted = "dev.pyrra.objective.deleted"
+	cloudEventBudgetCrossed    = "dev.pyrra.budget.crossed"
+	cloudEventBudgetRecovered  = "dev.pyrra.budget.recovered"
+)
+
+type CloudEventsConfig struct {
+	CloudEventsSinkURL  *url.URL      `name:"cloudevents-sink-url" help:"URL to POST CloudEvents to when objectives are created, updated or deleted and when their error budget crosses one of the --notification-thresholds. Kafka is supported through an HTTP bridge like Knative's KafkaSink."`
+	CloudEventsSource   string        `name:"cloudevents-source" default:"pyrra" help:"The source attribute of the CloudEvents."`
+	CloudEventsInterval time.Duration `name:"cloudevents-interval" default:"1m" help:"How often objectives are checked for changes."`
+}
+
+// Validate checks the CloudEvents sink comes with a source and an interval.
+func (cc *CloudEventsConfig) Validate() error {
+	if cc.CloudEventsSinkURL == nil {
+		return nil
+	}
+	if cc.CloudEventsSource == "" {
+		return fmt.Errorf("--cloudevents-source must not be empty")
+	}
+	if cc.CloudEventsInterval <= 0 {
+		return fmt.Errorf("--cloudevents-interval must be greater than 0")
+	}
+	return nil
+}
+
+// sender returns a CloudEvents sender if a sink is configured.
+func (cc CloudEventsConfig) sender(client *http.Client) *cloudEventsSender {
+	if cc.CloudEventsSinkURL == nil {
+		return nil
+	}
+	return &cloudEventsSender{
+		url:    cc.CloudEventsSinkURL.String(),
+		source: cc.CloudEventsSource,
+		client: client,
+		now:    time.Now,
+	}
+}
+
+// cloudEvent is a CloudEvent in the structured JSON format.
+// https://github.com/cloudevents/spec/blob/v1.0.2/cloudevents/formats/json-format.md
+type cloudEvent struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject,omitempty"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            any       `json:"data"`
+}
+
+// cloudEventObjective is the data of objective lifecycle events.
+type cloudEventObjective struct {
+	Objective   string            `json:"objective"`
+	Labels      map[string]string `json:"labels"`
+	Description string            `json:"description,omitempty"`
+	Target      float64           `json:"target"`
+	Window      string            `json:"window"`
+}
+
+func newCloudEventObjective(objective slo.Objective) cloudEventObjective {
+	return cloudEventObjective{
+		Objective:   objective.Name(),
+		Labels:      objective.Labels.Map(),
+		Description: objective.Description,
+		Target:      objective.Target,
+		Window:      objective.Window.String(),
+	}
+}
+
+type cloudEventsSender struct {
+	url    string
+	source string
+	client *http.Client
+	now    func() time.Time
+}
+
+func (s *cloudEventsSender) send(ctx context.Context, eventType, subject string, data any) error {
+	req, err := newJSONRequest(ctx, http.MethodPost, s.url, cloudEvent{
+		SpecVersion:     "1.0",
+		ID:              uuid.NewString(),
+		Source:          s.source,
+		Type:            eventType,
+		Subject:         subject,
+		Time:            s.now().UTC(),
+		DataContentType: "application/json",
+		Data:            data,
+	})
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/cloudevents+json")
+	return doJSON(s.client, req, nil)
+}
+
+// Notify sends error budget threshold crossings, which makes the sender usable with the budgetWatcher.
+func (s *cloudEventsSender) Notify(ctx context.Context, event budgetEvent) error {
+	eventType := cloudEventBudgetCrossed
+	if event.Recovered {
+		eventType = cloudEventBudgetRecovered
+	}
+	return s.send(ctx, eventType, event.objectiveName(), webhookPayload{
+		Objective:   event.Objective.Name(),
+		Labels:      event.Objective.Labels.Map(),
+		Description: event.Objective.Description,
+		Target:      event.Objective.Target,
+		Window:      event.Objective.Window.String(),
+		Remaining:   event.Remaining,
+		Threshold:   event.Threshold,
+		Recovered:   event.Recovered,
+		Message:     event.String(),
+	})
+}
+
+// objectiveLifecycleWatcher periodically lists the objectives and sends events for the ones created, updated or deleted since.
+// The objectives found on the first check are taken as they are, so restarts don't send events for every objective.
+type objectiveLifecycleWatcher struct {
+	logger log.Logger
+	client objectivesv1alpha1connect.ObjectiveBackendServiceClient
+	sender *cloudEventsSender
+
+	// known holds the objectives by their labels, together with a fingerprint of their spec.
+	known map[string]knownObjective
+}
+
+type knownObjective struct {
+	objective   slo.Objective
+	fingerprint string
+}
+
+func (w *objectiveLifecycleWatcher) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.check(ctx); err != nil {
+			level.Warn(w.logger).Log("msg", "failed to check objectives for changes", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *objectiveLifecycleWatcher) check(ctx context.Context) error {
+	resp, err := w.client.List(ctx, connect.NewRequest(&objectivesv1alpha1.ListRequest{}))
+	if err != nil {
+		return fmt.Errorf("failed to list objectives: %w", err)
+	}
+
+	current := make(map[string]knownObjective, len(resp.Msg.Objectives))
+	for _, o := range resp.Msg.Objectives {
+		fingerprint, err := objectiveFingerprint(o)
+		if err != nil {
+			return err
+		}
+		objective := objectivesv1alpha1.ToInternal(o)
+		current[labels.New(objective.Labels...).String()] = knownObjective{objective: objective, fingerprint: fingerprint}
+	}
+
+	if w.known == nil {
+		w.known = current
+		return nil
+	}
+
+	for key, c := range current {
+		k, ok := w.known[key]
+		switch {
+		case !ok:
+			if !w.send(ctx, cloudEventObjectiveCreated, c.objective) {
+				delete(current, key)
+			}
+		case k.fingerprint != c.fingerprint:
+			if !w.send(ctx, cloudEventObjectiveUpdated, c.objective) {
+				current[key] = k
+			}
+		}
+	}
+	for key, k := range w.known {
+		if _, ok := current[key]; ok {
+			continue
+		}
+		if !w.send(ctx, cloudEventObjectiveDeleted, k.objective) {
+			current[key] = k
+		}
+	}
+
+	// Events that failed to send are kept out of the known state to be sent again on the next check.
+	w.known = current
+	return nil
+}
+
+func (w *objectiveLifecycleWatcher) send(ctx context.Context, eventType string, objective slo.Objective) bool {
+	subject := budgetEvent{Objective: objective}.objectiveName()
+	if err := w.sender.send(ctx, eventType, subject, newCloudEventObjective(objective)); err != nil {
+		level.Warn(w.logger).Log("msg", "failed to send CloudEvent", "type", eventType, "objective", subject, "err", err)
+		return false
+	}
+	return true
+}
+
+// objectiveFingerprint returns what identifies the spec of an objective.
+// The raw config and queries are left out, as they change with metadata and Pyrra versions.
+func objectiveFingerprint(o *objectivesv1alpha1.Objective) (string, error) {
+	o = proto.Clone(o).(*objectivesv1alpha1.Objective)
+	o.Config = ""
+	o.Queries = nil
+
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(o)
+	if err != nil {
+		return "", fmt.Errorf("failed to fingerprint objective: %w", err)
+	}
+	return string(b), nil
+}
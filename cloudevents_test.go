@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+// cloudEventsSink records the received events and fails while failing is true.
+type cloudEventsSink struct {
+	events  []map[string]any
+	failing bool
+}
+
+func (s *cloudEventsSink) server(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/cloudevents+json", r.Header.Get("Content-Type"))
+		if s.failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		s.events = append(s.events, event)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *cloudEventsSink) types() []string {
+	types := make([]string, 0, len(s.events))
+	for _, e := range s.events {
+		types = append(types, e["type"].(string)+" "+e["subject"].(string))
+	}
+	s.events = nil
+	return types
+}
+
+func testCloudEventsSender(server *httptest.Server) *cloudEventsSender {
+	sinkURL, _ := url.Parse(server.URL)
+	sender := CloudEventsConfig{CloudEventsSinkURL: sinkURL, CloudEventsSource: "pyrra"}.sender(server.Client())
+	sender.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+	return sender
+}
+
+func TestCloudEventsSender_Notify(t *testing.T) {
+	sink := &cloudEventsSink{}
+	sender := testCloudEventsSender(sink.server(t))
+
+	objective := reportObjective("api", "backend")
+	objective.Labels = labels.FromStrings(labels.MetricName, "api", "namespace", "monitoring")
+
+	require.NoError(t, sender.Notify(context.Background(), budgetEvent{Objective: objective, Remaining: 0.2, Threshold: 0.25}))
+	require.NoError(t, sender.Notify(context.Background(), budgetEvent{Objective: objective, Remaining: 0.3, Threshold: 0.25, Recovered: true}))
+
+	require.Len(t, sink.events, 2)
+	event := sink.events[0]
+	require.NotEmpty(t, event["id"])
+	require.NotEqual(t, event["id"], sink.events[1]["id"])
+	delete(event, "id")
+	require.Equal(t, map[string]any{
+		"specversion":     "1.0",
+		"source":          "pyrra",
+		"type":            "dev.pyrra.budget.crossed",
+		"subject":         "monitoring/api",
+		"time":            "2024-05-01T12:00:00Z",
+		"datacontenttype": "application/json",
+		"data": map[string]any{
+			"objective": "api",
+			"labels":    map[string]any{"__name__": "api", "namespace": "monitoring"},
+			"target":    0.99,
+			"window":    "4w",
+			"remaining": 0.2,
+			"threshold": 0.25,
+			"recovered": false,
+			"message":   "Objective monitoring/api has less than 25% error budget remaining (20.00% remaining)",
+		},
+	}, event)
+	require.Equal(t, "dev.pyrra.budget.recovered", sink.events[1]["type"])
+}
+
+func TestObjectiveLifecycleWatcher(t *testing.T) {
+	sink := &cloudEventsSink{}
+	watcher := &objectiveLifecycleWatcher{
+		logger: log.NewNopLogger(),
+		sender: testCloudEventsSender(sink.server(t)),
+	}
+	check := func(objectives ...slo.Objective) {
+		watcher.client = staticBackend{objectives: objectives}
+		require.NoError(t, watcher.check(context.Background()))
+	}
+
+	api := reportObjective("api", "backend")
+	web := reportObjective("web", "frontend")
+
+	// Objectives existing on startup are not sent.
+	check(api, web)
+	require.Empty(t, sink.types())
+
+	check(api, web)
+	require.Empty(t, sink.types())
+
+	updated := reportObjective("api", "backend")
+	updated.Target = 0.999
+	check(updated)
+	require.ElementsMatch(t, []string{
+		"dev.pyrra.objective.updated api",
+		"dev.pyrra.objective.deleted web",
+	}, sink.types())
+
+	// Failed events are sent again on the next check.
+	sink.failing = true
+	check(updated, web)
+	require.Empty(t, sink.types())
+
+	sink.failing = false
+	check(updated, web)
+	require.Equal(t, []string{"dev.pyrra.objective.created web"}, sink.types())
+
+	check(updated, web)
+	require.Empty(t, sink.types())
+}
+
+func TestCloudEventsConfig_Validate(t *testing.T) {
+	require.NoError(t, (&CloudEventsConfig{}).Validate())
+
+	sinkURL, _ := url.Parse("http://broker-ingress/default/default")
+	require.EqualError(t, (&CloudEventsConfig{CloudEventsSinkURL: sinkURL, CloudEventsInterval: time.Minute}).Validate(), "--cloudevents-source must not be empty")
+	require.EqualError(t, (&CloudEventsConfig{CloudEventsSinkURL: sinkURL, CloudEventsSource: "pyrra"}).Validate(), "--cloudevents-interval must be greater than 0")
+	require.NoError(t, (&CloudEventsConfig{CloudEventsSinkURL: sinkURL, CloudEventsSource: "pyrra", CloudEventsInterval: time.Minute}).Validate())
+}
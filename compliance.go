@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	objectivesv1alpha1 "github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1"
+	"github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1/objectivesv1alpha1connect"
+)
+
+// Formats of compliance reports.
+const (
+	complianceFormatJSON = "json"
+	complianceFormatCSV  = "csv"
+)
+
+// complianceReport is the compliance of objectives over a window, like the past 90 days,
+// with their availability, error budget consumed and alerts, as management asks for periodically.
+type complianceReport struct {
+	From       time.Time             `json:"from"`
+	To         time.Time             `json:"to"`
+	Window     string                `json:"window"`
+	Objectives []complianceObjective `json:"objectives"`
+}
+
+type complianceObjective struct {
+	objectiveReport
+	// Compliant is true if the availability during the window kept the objective's target.
+	Compliant bool `json:"compliant"`
+	// Error is why the objective couldn't be reported, its other fields are empty then.
+	Error string `json:"error,omitempty"`
+}
+
+// complianceReporter reports the compliance of the objectives of the backend from the Prometheus, Thanos or Mimir evaluating them.
+type complianceReporter struct {
+	logger  log.Logger
+	client  objectivesv1alpha1connect.ObjectiveBackendServiceClient
+	promAPI budgetQuerier
+}
+
+// report returns the compliance of the objectives matching the expr, all if it's empty, over the window until to.
+// Objectives that fail to be reported are part of the report with their error, so they don't go missing.
+func (c *complianceReporter) report(ctx context.Context, expr string, window time.Duration, to time.Time) (complianceReport, error) {
+	resp, err := c.client.List(ctx, connect.NewRequest(&objectivesv1alpha1.ListRequest{Expr: expr}))
+	if err != nil {
+		return complianceReport{}, fmt.Errorf("failed to list objectives: %w", err)
+	}
+
+	report := complianceReport{
+		From:       to.Add(-window),
+		To:         to,
+		Window:     model.Duration(window).String(),
+		Objectives: make([]complianceObjective, 0, len(resp.Msg.Objectives)),
+	}
+	r := &reporter{promAPI: c.promAPI}
+	for _, o := range resp.Msg.Objectives {
+		objective := objectivesv1alpha1.ToInternal(o)
+		or, err := r.objectiveReport(ctx, objective, to, window)
+		if err != nil {
+			level.Warn(c.logger).Log("msg", "failed to report objective", "objective", objective.Name(), "err", err)
+			report.Objectives = append(report.Objectives, complianceObjective{
+				objectiveReport: objectiveReport{Name: or.Name, Labels: or.Labels, Target: or.Target},
+				Error:           err.Error(),
+			})
+			continue
+		}
+		report.Objectives = append(report.Objectives, complianceObjective{
+			objectiveReport: or,
+			Compliant:       or.Availability >= or.Target,
+		})
+	}
+	sort.Slice(report.Objectives, func(i, j int) bool {
+		a, b := report.Objectives[i], report.Objectives[j]
+		if a.Labels["namespace"] != b.Labels["namespace"] {
+			return a.Labels["namespace"] < b.Labels["namespace"]
+		}
+		return a.Name < b.Name
+	})
+	return report, nil
+}
+
+// write writes the report in the format, either json or csv.
+func (r complianceReport) write(w io.Writer, format string) error {
+	switch format {
+	case complianceFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case complianceFormatCSV:
+		return r.writeCSV(w)
+	default:
+		return fmt.Errorf("format must be json or csv, not %q", format)
+	}
+}
+
+// writeCSV writes a row per objective, with ratios between 0 and 1 like the JSON.
+// The SLA columns are empty for objectives without an SLA.
+func (r complianceReport) writeCSV(w io.Writer) error {
+	formatFloat := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{
+		"namespace", "objective", "from", "to", "target", "availability", "budget_consumed", "alerts", "compliant", "sla_target", "sla_met", "error",
+	})
+	for _, o := range r.Objectives {
+		row := []string{
+			o.Labels["namespace"],
+			o.Name,
+			r.From.UTC().Format(time.RFC3339),
+			r.To.UTC().Format(time.RFC3339),
+			formatFloat(o.Target),
+			"", "", "", "", "", "",
+			o.Error,
+		}
+		if o.Error == "" {
+			row[5] = formatFloat(o.Availability)
+			row[6] = formatFloat(o.BudgetConsumed)
+			row[7] = strconv.Itoa(o.Alerts)
+			row[8] = strconv.FormatBool(o.Compliant)
+		}
+		if o.SLA != nil {
+			row[9] = formatFloat(o.SLA.Target)
+			row[10] = strconv.FormatBool(o.SLA.Met)
+		}
+		_ = cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// complianceHandler serves the compliance report of the objectives,
+// like ?window=90d&format=csv&expr={team="checkout"}. It defaults to the past 30 days as JSON of all objectives.
+type complianceHandler struct {
+	reporter *complianceReporter
+}
+
+func (h *complianceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	expr := r.URL.Query().Get("expr")
+	if expr != "" {
+		if _, err := parser.ParseMetricSelector(expr); err != nil {
+			http.Error(w, fmt.Sprintf("failed to parse expr: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+	window := 30 * 24 * time.Hour
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := model.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, fmt.Sprintf("window must be a duration like 90d, not %q", v), http.StatusBadRequest)
+			return
+		}
+		window = time.Duration(d)
+	}
+	format := complianceFormatJSON
+	if v := r.URL.Query().Get("format"); v != "" {
+		format = v
+	}
+	contentType := map[string]string{complianceFormatJSON: "application/json", complianceFormatCSV: "text/csv"}[format]
+	if contentType == "" {
+		http.Error(w, fmt.Sprintf("format must be json or csv, not %q", format), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.reporter.report(r.Context(), expr, window, time.Now())
+	if err != nil {
+		var connectErr *connect.Error
+		if errors.As(err, &connectErr) && connectErr.Code() == connect.CodeInvalidArgument {
+			http.Error(w, connectErr.Message(), http.StatusBadRequest)
+			return
+		}
+		level.Warn(h.reporter.logger).Log("msg", "failed to report compliance", "err", err)
+		http.Error(w, "failed to list objectives", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if format == complianceFormatCSV {
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="slo-compliance-%s.csv"`, report.To.UTC().Format(time.DateOnly)))
+	}
+	_ = report.write(w, format)
+}
+
+// cmdReport prints the compliance report of the objectives over the window until now.
+func cmdReport(
+	logger log.Logger,
+	out io.Writer,
+	client objectivesv1alpha1connect.ObjectiveBackendServiceClient,
+	promAPI budgetQuerier,
+	expr string,
+	window time.Duration,
+	format string,
+) int {
+	if window <= 0 {
+		level.Error(logger).Log("msg", "--window must be greater than 0")
+		return 1
+	}
+	if expr != "" {
+		if _, err := parser.ParseMetricSelector(expr); err != nil {
+			level.Error(logger).Log("msg", "failed to parse --expr", "err", err)
+			return 1
+		}
+	}
+
+	reporter := &complianceReporter{logger: logger, client: client, promAPI: promAPI}
+	report, err := reporter.report(context.Background(), expr, window, time.Now())
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to report compliance", "err", err)
+		return 1
+	}
+	if err := report.write(out, format); err != nil {
+		level.Error(logger).Log("msg", "failed to write report", "err", err)
+		return 1
+	}
+	for _, o := range report.Objectives {
+		if o.Error != "" {
+			// The report is still printed, but scripts shouldn't mistake it for complete.
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	prometheusapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+// complianceQuerier fails the queries of the objective named down, like its recording rules being broken.
+type complianceQuerier struct{}
+
+func (complianceQuerier) Query(_ context.Context, query string, _ time.Time) (model.Value, prometheusapiv1.Warnings, error) {
+	switch {
+	case strings.Contains(query, `"down"`):
+		return nil, nil, fmt.Errorf("query timed out")
+	case strings.HasPrefix(query, "count("):
+		return model.Vector{{Value: 2}}, nil, nil
+	case query == `pyrra_sla_target{slo="api"}`:
+		return model.Vector{{Value: 0.98}}, nil, nil
+	case strings.HasPrefix(query, "pyrra_sla_target"):
+		return model.Vector{}, nil, nil
+	case strings.Contains(query, `job="web"`):
+		return model.Vector{{Value: 0.02}}, nil, nil
+	default:
+		return model.Vector{{Value: 0.005}}, nil, nil
+	}
+}
+
+func complianceObjectives() []slo.Objective {
+	var objectives []slo.Objective
+	for _, o := range []struct{ namespace, name string }{{"shop", "web"}, {"shop", "api"}, {"payments", "down"}} {
+		objective := reportObjective(o.name, "")
+		objective.Labels = labels.NewBuilder(objective.Labels).Set("namespace", o.namespace).Del("pyrra.dev/team").Labels()
+		objectives = append(objectives, objective)
+	}
+	return objectives
+}
+
+func TestComplianceReporter_Report(t *testing.T) {
+	r := &complianceReporter{
+		logger:  log.NewNopLogger(),
+		client:  staticBackend{objectives: complianceObjectives()},
+		promAPI: complianceQuerier{},
+	}
+	to := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	report, err := r.report(context.Background(), "", 90*24*time.Hour, to)
+	require.NoError(t, err)
+	require.Equal(t, to.Add(-90*24*time.Hour), report.From)
+	require.Equal(t, to, report.To)
+	require.Equal(t, "90d", report.Window)
+
+	require.Len(t, report.Objectives, 3)
+	down := report.Objectives[0]
+	require.Equal(t, "down", down.Name)
+	require.Equal(t, "query timed out", down.Error)
+	require.False(t, down.Compliant)
+
+	api := report.Objectives[1]
+	require.Equal(t, "api", api.Name)
+	require.InDelta(t, 0.995, api.Availability, 1e-9)
+	require.InDelta(t, 0.5, api.BudgetConsumed, 1e-9)
+	require.Equal(t, 2, api.Alerts)
+	require.True(t, api.Compliant)
+	require.NotNil(t, api.SLA)
+	require.True(t, api.SLA.Met)
+
+	web := report.Objectives[2]
+	require.Equal(t, "web", web.Name)
+	require.InDelta(t, 0.98, web.Availability, 1e-9)
+	require.InDelta(t, 2, web.BudgetConsumed, 1e-9)
+	require.False(t, web.Compliant)
+	require.Empty(t, web.Error)
+}
+
+func TestComplianceReport_Write(t *testing.T) {
+	report := complianceReport{
+		From:   time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		To:     time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+		Window: "90d",
+		Objectives: []complianceObjective{{
+			objectiveReport: objectiveReport{
+				Name:           "api",
+				Labels:         map[string]string{"namespace": "shop"},
+				Target:         0.99,
+				Availability:   0.995,
+				BudgetConsumed: 0.5,
+				Alerts:         2,
+				SLA:            &slaReport{Target: 0.98, BudgetConsumed: 0.25, Met: true},
+			},
+			Compliant: true,
+		}, {
+			objectiveReport: objectiveReport{Name: "down", Labels: map[string]string{"namespace": "payments"}, Target: 0.999},
+			Error:           "query timed out",
+		}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, report.write(&buf, complianceFormatCSV))
+	require.Equal(t, `namespace,objective,from,to,target,availability,budget_consumed,alerts,compliant,sla_target,sla_met,error
+shop,api,2024-01-02T00:00:00Z,2024-04-01T00:00:00Z,0.99,0.995,0.5,2,true,0.98,true,
+payments,down,2024-01-02T00:00:00Z,2024-04-01T00:00:00Z,0.999,,,,,,,query timed out
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, report.write(&buf, complianceFormatJSON))
+	require.Contains(t, buf.String(), `"window": "90d"`)
+	require.Contains(t, buf.String(), `"compliant": true`)
+	require.Contains(t, buf.String(), `"error": "query timed out"`)
+
+	require.EqualError(t, report.write(&buf, "xml"), `format must be json or csv, not "xml"`)
+}
+
+func TestComplianceHandler(t *testing.T) {
+	h := &complianceHandler{reporter: &complianceReporter{
+		logger:  log.NewNopLogger(),
+		client:  staticBackend{objectives: complianceObjectives()},
+		promAPI: complianceQuerier{},
+	}}
+
+	for _, tc := range []struct {
+		query       string
+		status      int
+		contentType string
+		body        string
+	}{{
+		query:       "",
+		status:      http.StatusOK,
+		contentType: "application/json",
+		body:        `"window": "30d"`,
+	}, {
+		query:       "?window=90d&format=csv",
+		status:      http.StatusOK,
+		contentType: "text/csv",
+		body:        "shop,api,",
+	}, {
+		query:  "?window=3 months",
+		status: http.StatusBadRequest,
+		body:   `window must be a duration like 90d, not "3 months"`,
+	}, {
+		query:  "?format=xml",
+		status: http.StatusBadRequest,
+		body:   `format must be json or csv, not "xml"`,
+	}, {
+		query:  "?expr=foo{",
+		status: http.StatusBadRequest,
+		body:   "failed to parse expr",
+	}} {
+		t.Run(tc.query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/report"+strings.ReplaceAll(tc.query, " ", "%20"), nil))
+			require.Equal(t, tc.status, rec.Code)
+			if tc.contentType != "" {
+				require.Equal(t, tc.contentType, rec.Header().Get("Content-Type"))
+			}
+			require.Contains(t, rec.Body.String(), tc.body)
+		})
+	}
+}
+
+func TestCmdReport(t *testing.T) {
+	var out bytes.Buffer
+	backend := staticBackend{objectives: complianceObjectives()[:2]}
+	require.Equal(t, 0, cmdReport(log.NewNopLogger(), &out, backend, complianceQuerier{}, "", 90*24*time.Hour, complianceFormatCSV))
+	require.Len(t, strings.Split(strings.TrimSpace(out.String()), "\n"), 3)
+
+	// Objectives that couldn't be reported fail the command, after printing the report.
+	out.Reset()
+	backend = staticBackend{objectives: complianceObjectives()}
+	require.Equal(t, 1, cmdReport(log.NewNopLogger(), &out, backend, complianceQuerier{}, "", 90*24*time.Hour, complianceFormatJSON))
+	require.Contains(t, out.String(), `"error": "query timed out"`)
+
+	require.Equal(t, 1, cmdReport(log.NewNopLogger(), &out, backend, complianceQuerier{}, "foo{", 90*24*time.Hour, complianceFormatJSON))
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/prometheus/model/labels"
+
+	objectivesv1alpha1 "github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1"
+	"github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1/objectivesv1alpha1connect"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+// datadogTag is added to all SLOs created by Pyrra to find them again.
+const datadogTag = "managed-by:pyrra"
+
+type DatadogConfig struct {
+	DatadogAPIKey       string        `name:"datadog-api-key" env:"PYRRA_DATADOG_API_KEY" help:"Datadog API key. If set objectives are mirrored as metric based SLOs into Datadog."`
+	DatadogAppKey       string        `name:"datadog-app-key" env:"PYRRA_DATADOG_APP_KEY" help:"Datadog application key."`
+	DatadogURL          *url.URL      `default:"https://api.datadoghq.com" help:"The URL of the Datadog API for your site."`
+	DatadogMetricPrefix string        `default:"" help:"Prefix of the metric names in Datadog, like the namespace of the OpenMetrics integration scraping them."`
+	DatadogInterval     time.Duration `default:"5m" help:"How often the objectives are synced to Datadog."`
+}
+
+// Validate checks the Datadog API key comes with an application key and an interval.
+func (dc *DatadogConfig) Validate() error {
+	if dc.DatadogAPIKey == "" {
+		return nil
+	}
+	if dc.DatadogAppKey == "" {
+		return fmt.Errorf("--datadog-api-key requires --datadog-app-key")
+	}
+	if dc.DatadogInterval <= 0 {
+		return fmt.Errorf("--datadog-interval must be greater than 0")
+	}
+	return nil
+}
+
+// client returns a Datadog client if Datadog is configured.
+func (dc DatadogConfig) client(client *http.Client) *datadogClient {
+	if dc.DatadogAPIKey == "" {
+		return nil
+	}
+	return &datadogClient{
+		url:    strings.TrimSuffix(dc.DatadogURL.String(), "/"),
+		apiKey: dc.DatadogAPIKey,
+		appKey: dc.DatadogAppKey,
+		client: client,
+	}
+}
+
+// datadogSLO is a service level objective of Datadog's API.
+// https://docs.datadoghq.com/api/latest/service-level-objectives/
+type datadogSLO struct {
+	ID          string             `json:"id,omitempty"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Type        string             `json:"type"`
+	Query       datadogQuery       `json:"query"`
+	Thresholds  []datadogThreshold `json:"thresholds"`
+	Tags        []string           `json:"tags"`
+}
+
+type datadogQuery struct {
+	Numerator   string `json:"numerator"`
+	Denominator string `json:"denominator"`
+}
+
+type datadogThreshold struct {
+	Timeframe string  `json:"timeframe"`
+	Target    float64 `json:"target"`
+}
+
+// equal returns true if the SLOs are the same, ignoring their ID and the order of tags.
+func (s datadogSLO) equal(o datadogSLO) bool {
+	if s.Name != o.Name || s.Description != o.Description || s.Type != o.Type || s.Query != o.Query {
+		return false
+	}
+	if len(s.Thresholds) != len(o.Thresholds) || len(s.Tags) != len(o.Tags) {
+		return false
+	}
+	for i := range s.Thresholds {
+		if s.Thresholds[i] != o.Thresholds[i] {
+			return false
+		}
+	}
+	tags := append([]string{}, o.Tags...)
+	sort.Strings(tags)
+	for i, t := range s.Tags {
+		if tags[i] != t {
+			return false
+		}
+	}
+	return true
+}
+
+type datadogClient struct {
+	url    string
+	apiKey string
+	appKey string
+	client *http.Client
+}
+
+// listSLOs returns all SLOs that were created by Pyrra.
+func (c *datadogClient) listSLOs(ctx context.Context) ([]datadogSLO, error) {
+	var resp struct {
+		Data []datadogSLO `json:"data"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/v1/slo?tags_query="+url.QueryEscape(datadogTag), nil, &resp)
+	return resp.Data, err
+}
+
+func (c *datadogClient) createSLO(ctx context.Context, s datadogSLO) error {
+	return c.do(ctx, http.MethodPost, "/api/v1/slo", s, nil)
+}
+
+func (c *datadogClient) updateSLO(ctx context.Context, id string, s datadogSLO) error {
+	return c.do(ctx, http.MethodPut, "/api/v1/slo/"+url.PathEscape(id), s, nil)
+}
+
+func (c *datadogClient) deleteSLO(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/slo/"+url.PathEscape(id), nil, nil)
+}
+
+func (c *datadogClient) do(ctx context.Context, method, path string, in, out any) error {
+	req, err := newJSONRequest(ctx, method, c.url+path, in)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("DD-API-KEY", c.apiKey)
+	req.Header.Set("DD-APPLICATION-KEY", c.appKey)
+	return doJSON(c.client, req, out)
+}
+
+// datadogExporter mirrors the objectives as metric based SLOs into Datadog.
+// SLOs of objectives that no longer exist are deleted.
+type datadogExporter struct {
+	logger       log.Logger
+	client       objectivesv1alpha1connect.ObjectiveBackendServiceClient
+	datadog      *datadogClient
+	metricPrefix string
+}
+
+func (e *datadogExporter) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.sync(ctx); err != nil {
+			level.Warn(e.logger).Log("msg", "failed to sync Datadog SLOs", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *datadogExporter) sync(ctx context.Context) error {
+	resp, err := e.client.List(ctx, connect.NewRequest(&objectivesv1alpha1.ListRequest{}))
+	if err != nil {
+		return fmt.Errorf("failed to list objectives: %w", err)
+	}
+
+	existing, err := e.datadog.listSLOs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list Datadog SLOs: %w", err)
+	}
+	byTag := make(map[string]datadogSLO, len(existing))
+	for _, s := range existing {
+		if tag := datadogObjectiveTag(s.Tags); tag != "" {
+			byTag[tag] = s
+		}
+	}
+
+	for _, o := range resp.Msg.Objectives {
+		objective := objectivesv1alpha1.ToInternal(o)
+
+		desired, err := e.desiredSLO(objective)
+		if err != nil {
+			level.Debug(e.logger).Log("msg", "skipping objective", "objective", objective.Name(), "reason", err)
+			continue
+		}
+		tag := datadogObjectiveTag(desired.Tags)
+
+		current, ok := byTag[tag]
+		delete(byTag, tag)
+		switch {
+		case !ok:
+			if err := e.datadog.createSLO(ctx, desired); err != nil {
+				level.Warn(e.logger).Log("msg", "failed to create Datadog SLO", "objective", objective.Name(), "err", err)
+			}
+		case !desired.equal(current):
+			if err := e.datadog.updateSLO(ctx, current.ID, desired); err != nil {
+				level.Warn(e.logger).Log("msg", "failed to update Datadog SLO", "objective", objective.Name(), "err", err)
+			}
+		}
+	}
+
+	// Whatever is left over doesn't exist as objective anymore.
+	for _, s := range byTag {
+		if err := e.datadog.deleteSLO(ctx, s.ID); err != nil {
+			level.Warn(e.logger).Log("msg", "failed to delete Datadog SLO", "slo", s.Name, "err", err)
+		}
+	}
+
+	return nil
+}
+
+// desiredSLO returns the Datadog SLO of the objective.
+// Only ratio indicators are supported as their counters can be queried the same way in Datadog.
+func (e *datadogExporter) desiredSLO(objective slo.Objective) (datadogSLO, error) {
+	if objective.IndicatorType() != slo.Ratio {
+		return datadogSLO{}, fmt.Errorf("only ratio indicators are supported")
+	}
+
+	errorsQuery, err := datadogMetricQuery(e.metricPrefix, objective.Indicator.Ratio.Errors)
+	if err != nil {
+		return datadogSLO{}, fmt.Errorf("errors: %w", err)
+	}
+	totalQuery, err := datadogMetricQuery(e.metricPrefix, objective.Indicator.Ratio.Total)
+	if err != nil {
+		return datadogSLO{}, fmt.Errorf("total: %w", err)
+	}
+
+	name := objective.Name()
+	if ns := objective.Labels.Get("namespace"); ns != "" {
+		name = ns + "/" + name
+	}
+
+	tags := []string{datadogTag, "pyrra-objective:" + objectiveHash(objective)}
+	sort.Strings(tags)
+
+	return datadogSLO{
+		Name:        name,
+		Description: objective.Description,
+		Type:        "metric",
+		Query: datadogQuery{
+			Numerator:   totalQuery + " - " + errorsQuery,
+			Denominator: totalQuery,
+		},
+		Thresholds: []datadogThreshold{{
+			Timeframe: datadogTimeframe(time.Duration(objective.Window)),
+			Target:    math.Round(objective.Target*100*1e6) / 1e6,
+		}},
+		Tags: tags,
+	}, nil
+}
+
+func datadogObjectiveTag(tags []string) string {
+	for _, t := range tags {
+		if strings.HasPrefix(t, "pyrra-objective:") {
+			return t
+		}
+	}
+	return ""
+}
+
+// datadogTimeframe returns the timeframe Datadog supports that is closest to the window.
+func datadogTimeframe(window time.Duration) string {
+	timeframes := []struct {
+		name     string
+		duration time.Duration
+	}{
+		{"7d", 7 * 24 * time.Hour},
+		{"30d", 30 * 24 * time.Hour},
+		{"90d", 90 * 24 * time.Hour},
+	}
+
+	closest := timeframes[0]
+	for _, tf := range timeframes[1:] {
+		if (tf.duration - window).Abs() < (closest.duration - window).Abs() {
+			closest = tf
+		}
+	}
+	return closest.name
+}
+
+var (
+	// datadogAlternatives matches regular expressions like 500|502|503.
+	datadogAlternatives = regexp.MustCompile(`^[a-zA-Z0-9_\-/]+(\|[a-zA-Z0-9_\-/]+)*$`)
+	// datadogWildcard matches regular expressions like 5.. or 5.* that are a prefix with a wildcard.
+	datadogWildcard = regexp.MustCompile(`^([a-zA-Z0-9_\-/]*)(\.\*|\.+)$`)
+)
+
+// datadogMetricQuery translates the metric into a Datadog query counting its increases.
+func datadogMetricQuery(prefix string, m slo.Metric) (string, error) {
+	var filters []string
+	for _, lm := range m.LabelMatchers {
+		if lm.Name == labels.MetricName {
+			continue
+		}
+
+		switch lm.Type {
+		case labels.MatchEqual:
+			filters = append(filters, lm.Name+":"+lm.Value)
+		case labels.MatchNotEqual:
+			filters = append(filters, "!"+lm.Name+":"+lm.Value)
+		case labels.MatchRegexp, labels.MatchNotRegexp:
+			not := ""
+			if lm.Type == labels.MatchNotRegexp {
+				not = "NOT "
+			}
+			if datadogAlternatives.MatchString(lm.Value) {
+				filters = append(filters, fmt.Sprintf("%s %sIN (%s)", lm.Name, not, strings.ReplaceAll(lm.Value, "|", ",")))
+				continue
+			}
+			if match := datadogWildcard.FindStringSubmatch(lm.Value); match != nil {
+				if not != "" {
+					not = "!"
+				}
+				filters = append(filters, not+lm.Name+":"+match[1]+"*")
+				continue
+			}
+			return "", fmt.Errorf("regular expression %q of label %s cannot be expressed in Datadog", lm.Value, lm.Name)
+		}
+	}
+
+	scope := "*"
+	if len(filters) > 0 {
+		sort.Strings(filters)
+		scope = strings.Join(filters, " AND ")
+	}
+
+	return fmt.Sprintf("sum:%s%s{%s}.as_count()", prefix, m.Name, scope), nil
+}
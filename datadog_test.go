@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+func TestDatadogMetricQuery(t *testing.T) {
+	testcases := []struct {
+		name     string
+		matchers []*labels.Matcher
+		query    string
+		err      string
+	}{{
+		name:  "no matchers",
+		query: "sum:app.http_requests_total{*}.as_count()",
+	}, {
+		name: "equal",
+		matchers: []*labels.Matcher{
+			labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "http_requests_total"),
+			labels.MustNewMatcher(labels.MatchEqual, "job", "api"),
+			labels.MustNewMatcher(labels.MatchNotEqual, "method", "OPTIONS"),
+		},
+		query: "sum:app.http_requests_total{!method:OPTIONS AND job:api}.as_count()",
+	}, {
+		name: "wildcard",
+		matchers: []*labels.Matcher{
+			labels.MustNewMatcher(labels.MatchRegexp, "code", "5.."),
+			labels.MustNewMatcher(labels.MatchNotRegexp, "handler", "/debug/.*"),
+		},
+		query: "sum:app.http_requests_total{!handler:/debug/* AND code:5*}.as_count()",
+	}, {
+		name: "alternatives",
+		matchers: []*labels.Matcher{
+			labels.MustNewMatcher(labels.MatchRegexp, "code", "500|502|503"),
+			labels.MustNewMatcher(labels.MatchNotRegexp, "job", "canary|test"),
+		},
+		query: "sum:app.http_requests_total{code IN (500,502,503) AND job NOT IN (canary,test)}.as_count()",
+	}, {
+		name: "unsupported",
+		matchers: []*labels.Matcher{
+			labels.MustNewMatcher(labels.MatchRegexp, "code", "5[0-9]{2}"),
+		},
+		err: `regular expression "5[0-9]{2}" of label code cannot be expressed in Datadog`,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := datadogMetricQuery("app.", slo.Metric{Name: "http_requests_total", LabelMatchers: tc.matchers})
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.query, query)
+		})
+	}
+}
+
+func TestDatadogTimeframe(t *testing.T) {
+	require.Equal(t, "7d", datadogTimeframe(24*time.Hour))
+	require.Equal(t, "7d", datadogTimeframe(14*24*time.Hour))
+	require.Equal(t, "30d", datadogTimeframe(28*24*time.Hour))
+	require.Equal(t, "90d", datadogTimeframe(90*24*time.Hour))
+}
+
+func TestDatadogExporter_Sync(t *testing.T) {
+	objective := func(name string) slo.Objective {
+		o := reportObjective(name, "")
+		o.Labels = labels.FromStrings(labels.MetricName, name)
+		return o
+	}
+	var (
+		created   = objective("created")
+		updated   = objective("updated")
+		unchanged = objective("unchanged")
+	)
+	exporter := &datadogExporter{
+		logger: log.NewNopLogger(),
+		client: staticBackend{objectives: []slo.Objective{created, updated, unchanged}},
+	}
+
+	existingUpdated, err := exporter.desiredSLO(updated)
+	require.NoError(t, err)
+	existingUpdated.ID = "2"
+	existingUpdated.Thresholds[0].Target = 95
+
+	existingUnchanged, err := exporter.desiredSLO(unchanged)
+	require.NoError(t, err)
+	existingUnchanged.ID = "3"
+
+	var (
+		mu       sync.Mutex
+		requests []string
+		bodies   = map[string]datadogSLO{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		require.Equal(t, "api-key", r.Header.Get("DD-API-KEY"))
+		require.Equal(t, "app-key", r.Header.Get("DD-APPLICATION-KEY"))
+
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case http.MethodGet:
+			require.Equal(t, "managed-by:pyrra", r.URL.Query().Get("tags_query"))
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": []datadogSLO{
+				existingUpdated,
+				existingUnchanged,
+				{ID: "4", Name: "deleted", Tags: []string{"managed-by:pyrra", "pyrra-objective:0123"}},
+			}})
+		case http.MethodPost, http.MethodPut:
+			var s datadogSLO
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&s))
+			bodies[r.Method+" "+r.URL.Path] = s
+		}
+	}))
+	defer server.Close()
+
+	exporter.datadog = &datadogClient{url: server.URL, apiKey: "api-key", appKey: "app-key", client: server.Client()}
+	require.NoError(t, exporter.sync(context.Background()))
+
+	require.ElementsMatch(t, []string{
+		"GET /api/v1/slo",
+		"POST /api/v1/slo",
+		"PUT /api/v1/slo/2",
+		"DELETE /api/v1/slo/4",
+	}, requests)
+
+	require.Equal(t, datadogSLO{
+		Name: "created",
+		Type: "metric",
+		Query: datadogQuery{
+			Numerator:   `sum:http_requests_total{job:created}.as_count() - sum:http_requests_total{code:5* AND job:created}.as_count()`,
+			Denominator: `sum:http_requests_total{job:created}.as_count()`,
+		},
+		Thresholds: []datadogThreshold{{Timeframe: "30d", Target: 99}},
+		Tags:       []string{"managed-by:pyrra", "pyrra-objective:" + objectiveHash(created)},
+	}, bodies["POST /api/v1/slo"])
+	require.Equal(t, 99.0, bodies["PUT /api/v1/slo/2"].Thresholds[0].Target)
+}
+
+func TestDatadogConfig_Validate(t *testing.T) {
+	require.NoError(t, (&DatadogConfig{}).Validate())
+
+	dc := &DatadogConfig{DatadogAPIKey: "api-key", DatadogInterval: time.Minute}
+	require.EqualError(t, dc.Validate(), "--datadog-api-key requires --datadog-app-key")
+
+	dc.DatadogAppKey = "app-key"
+	require.NoError(t, dc.Validate())
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type DebugConfig struct {
+	DebugAddr string `name:"debug-addr" default:"" help:"The address, like localhost:6060, to serve pprof profiles and all Go runtime metrics on for debugging. Disabled if empty, as profiles shouldn't be exposed publicly."`
+}
+
+// debugHandler serves pprof profiles on /debug/pprof/, expvars on /debug/vars and all runtime metrics on /metrics.
+// The runtime metrics are kept off the main /metrics endpoint as there are a lot of them.
+func debugHandler() http.Handler {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectors.NewGoCollector(
+		collectors.WithGoCollectorRuntimeMetrics(collectors.MetricsAll),
+	))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	return mux
+}
+
+// serveDebug serves the debug handler in the background if an address is configured.
+func serveDebug(logger log.Logger, config DebugConfig) {
+	if config.DebugAddr == "" {
+		return
+	}
+
+	server := &http.Server{
+		Addr:              config.DebugAddr,
+		Handler:           debugHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		level.Info(logger).Log("msg", "serving debug endpoints", "address", config.DebugAddr)
+		if err := server.ListenAndServe(); err != nil {
+			level.Error(logger).Log("msg", "failed to serve debug endpoints", "err", err)
+		}
+	}()
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugHandler(t *testing.T) {
+	server := httptest.NewServer(debugHandler())
+	defer server.Close()
+
+	get := func(path string) string {
+		resp, err := server.Client().Get(server.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode, path)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	require.Contains(t, get("/debug/pprof/"), "goroutine")
+	require.Contains(t, get("/debug/pprof/heap?debug=1"), "heap profile")
+	require.Contains(t, get("/debug/vars"), "memstats")
+	require.Contains(t, get("/metrics"), "go_sched_goroutines_goroutines")
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pmezard/go-difflib/difflib"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+	"github.com/pyrra-dev/pyrra/kubernetes/controllers"
+)
+
+// cmdDiff generates the PrometheusRules of objectives like the Kubernetes operator
+// and prints the unified diff of their rules against the PrometheusRules in the cluster.
+// The objectives are read from the files, or from the cluster if there are none.
+// Like kubectl diff, it exits with 1 if there are differences and with 2 on errors.
+func cmdDiff(logger log.Logger, out io.Writer, c client.Reader, files []string, namespace string, genericRules bool) int {
+	ctx := context.Background()
+
+	objectives, err := diffObjectives(ctx, c, files, namespace)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to read objectives", "err", err)
+		return 2
+	}
+
+	changed := false
+	for _, kubeObjective := range objectives {
+		if controllers.IsLokiObjective(kubeObjective.GetAnnotations()) {
+			level.Warn(logger).Log("msg", "skipping objective evaluated by Loki", "namespace", kubeObjective.GetNamespace(), "name", kubeObjective.GetName())
+			continue
+		}
+
+		diff, err := objectiveDiff(ctx, c, kubeObjective, genericRules)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to diff objective", "namespace", kubeObjective.GetNamespace(), "name", kubeObjective.GetName(), "err", err)
+			return 2
+		}
+		if diff == "" {
+			continue
+		}
+		changed = true
+		if _, err := io.WriteString(out, diff); err != nil {
+			level.Error(logger).Log("msg", "failed to write diff", "err", err)
+			return 2
+		}
+	}
+
+	if changed {
+		return 1
+	}
+	return 0
+}
+
+// diffObjectives returns the objectives of the files, in the namespace if they don't have one,
+// or all objectives in the namespace of the cluster if there are no files.
+func diffObjectives(ctx context.Context, c client.Reader, files []string, namespace string) ([]pyrrav1alpha1.ServiceLevelObjective, error) {
+	if len(files) == 0 {
+		var list pyrrav1alpha1.ServiceLevelObjectiveList
+		if err := c.List(ctx, &list, client.InNamespace(namespace)); err != nil {
+			return nil, fmt.Errorf("failed to list objectives: %w", err)
+		}
+		sort.Slice(list.Items, func(i, j int) bool {
+			return client.ObjectKeyFromObject(&list.Items[i]).String() < client.ObjectKeyFromObject(&list.Items[j]).String()
+		})
+		return list.Items, nil
+	}
+
+	objectives := make([]pyrrav1alpha1.ServiceLevelObjective, 0, len(files))
+	for _, file := range files {
+		kubeObjective, _, err := objectiveFromFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := kubeObjective.ValidateCreate(); err != nil {
+			return nil, fmt.Errorf("invalid objective %s: %w", file, err)
+		}
+		if kubeObjective.GetNamespace() == "" {
+			kubeObjective.SetNamespace(namespace)
+		}
+		if kubeObjective.GetNamespace() == "" {
+			kubeObjective.SetNamespace("default")
+		}
+		objectives = append(objectives, kubeObjective)
+	}
+	return objectives, nil
+}
+
+// objectiveDiff returns the unified diff of the rules of the objective's PrometheusRule in the cluster to the generated ones.
+func objectiveDiff(ctx context.Context, c client.Reader, kubeObjective pyrrav1alpha1.ServiceLevelObjective, genericRules bool) (string, error) {
+	generated, err := controllers.MakePrometheusRule(kubeObjective, genericRules)
+	if err != nil {
+		return "", err
+	}
+	head, err := yaml.Marshal(generated.Spec)
+	if err != nil {
+		return "", err
+	}
+
+	var base []byte
+	var existing monitoringv1.PrometheusRule
+	if err := c.Get(ctx, client.ObjectKeyFromObject(generated), &existing); err != nil {
+		if !errors.IsNotFound(err) {
+			return "", fmt.Errorf("failed to get prometheus rule: %w", err)
+		}
+	} else {
+		if base, err = yaml.Marshal(existing.Spec); err != nil {
+			return "", err
+		}
+	}
+
+	if string(base) == string(head) {
+		return "", nil
+	}
+	name := client.ObjectKeyFromObject(generated).String()
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(base)),
+		B:        difflib.SplitLines(string(head)),
+		FromFile: name + " (cluster)",
+		ToFile:   name + " (generated)",
+		Context:  3,
+	})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+	"github.com/pyrra-dev/pyrra/kubernetes/controllers"
+)
+
+func TestCmdDiff(t *testing.T) {
+	objective := func(target string) pyrrav1alpha1.ServiceLevelObjective {
+		var kubeObjective pyrrav1alpha1.ServiceLevelObjective
+		require.NoError(t, yaml.Unmarshal([]byte(strings.Replace(ciObjective, "%s", target, 1)), &kubeObjective))
+		return kubeObjective
+	}
+	rule := func(target string) *monitoringv1.PrometheusRule {
+		rule, err := controllers.MakePrometheusRule(objective(target), false)
+		require.NoError(t, err)
+		return rule
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "api.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(strings.Replace(ciObjective, "%s", "99", 1)), 0o644))
+
+	t.Run("up to date", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(rule("99")).Build()
+
+		var out bytes.Buffer
+		require.Equal(t, 0, cmdDiff(log.NewNopLogger(), &out, c, []string{file}, "", false))
+		require.Empty(t, out.String())
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(rule("95")).Build()
+
+		var out bytes.Buffer
+		require.Equal(t, 1, cmdDiff(log.NewNopLogger(), &out, c, []string{file}, "", false))
+		require.Contains(t, out.String(), "--- monitoring/api-errors (cluster)\n+++ monitoring/api-errors (generated)\n")
+		require.Contains(t, out.String(), "-      http_requests:burnrate1h{job=\"api\",slo=\"api-errors\"} > (14 * (1-0.95))\n")
+		require.Contains(t, out.String(), "+      http_requests:burnrate1h{job=\"api\",slo=\"api-errors\"} > (14 * (1-0.99))\n")
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+		var out bytes.Buffer
+		require.Equal(t, 1, cmdDiff(log.NewNopLogger(), &out, c, []string{file}, "", false))
+		require.Contains(t, out.String(), "+groups:\n")
+	})
+
+	t.Run("cluster objectives", func(t *testing.T) {
+		live := objective("99")
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&live, rule("95")).Build()
+
+		var out bytes.Buffer
+		require.Equal(t, 1, cmdDiff(log.NewNopLogger(), &out, c, nil, "monitoring", false))
+		require.Contains(t, out.String(), "+      http_requests:burnrate1h{job=\"api\",slo=\"api-errors\"} > (14 * (1-0.99))\n")
+
+		out.Reset()
+		require.Equal(t, 0, cmdDiff(log.NewNopLogger(), &out, c, nil, "other", false))
+		require.Empty(t, out.String())
+	})
+}
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -22,12 +27,14 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/promql/parser"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/yaml"
 
 	"github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+	"github.com/pyrra-dev/pyrra/kubernetes/controllers"
 	objectivesv1alpha1 "github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1"
 	"github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1/objectivesv1alpha1connect"
 	"github.com/pyrra-dev/pyrra/slo"
@@ -44,6 +51,12 @@ func (os *Objectives) Set(o slo.Objective) {
 	os.mu.Unlock()
 }
 
+func (os *Objectives) Delete(o slo.Objective) {
+	os.mu.Lock()
+	delete(os.objectives, o.Labels.String())
+	os.mu.Unlock()
+}
+
 func (os *Objectives) Match(ms []*labels.Matcher) []slo.Objective {
 	if len(ms) == 0 {
 		os.mu.RLock()
@@ -89,7 +102,7 @@ Objectives:
 	return objectives
 }
 
-func cmdFilesystem(logger log.Logger, reg *prometheus.Registry, promClient api.Client, configFiles, prometheusFolder string, genericRules bool) int {
+func cmdFilesystem(logger log.Logger, reg *prometheus.Registry, promClient api.Client, configFiles, prometheusFolder string, genericRules bool, workers int) int {
 	reconcilesTotal := prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "pyrra_filesystem_reconciles_total",
 		Help: "The total amount of reconciles.",
@@ -151,7 +164,8 @@ func cmdFilesystem(logger log.Logger, reg *prometheus.Registry, promClient api.C
 					if !ok {
 						continue
 					}
-					if event.Op&fsnotify.Write == fsnotify.Write {
+					// Renaming and removing files removes their rules, files renamed to are created.
+					if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
 						files <- event.Name
 					}
 				case err := <-watcher.Errors:
@@ -164,40 +178,75 @@ func cmdFilesystem(logger log.Logger, reg *prometheus.Registry, promClient api.C
 		})
 	}
 	{
+		reconciler := &filesystemReconciler{
+			logger:           logger,
+			prometheusFolder: prometheusFolder,
+			genericRules:     genericRules,
+			objectives:       objectives,
+		}
+
+		process := func(f string) {
+			// We only care about watching for files with a valid yaml extension
+			if filepath.Ext(f) != ".yaml" && filepath.Ext(f) != ".yml" {
+				level.Warn(logger).Log("msg", "ignoring non YAML file", "file", f)
+				return
+			}
+
+			level.Debug(logger).Log("msg", "processing", "file", f)
+			reconcilesTotal.Inc()
+
+			changed, err := reconciler.reconcile(f)
+			if err != nil {
+				reconcilesErrors.Inc()
+				level.Error(logger).Log("msg", "error reconciling file", "file", f, "err", err)
+				return
+			}
+			if !changed {
+				level.Debug(logger).Log("msg", "file unchanged", "file", f)
+				return
+			}
+
+			reload <- struct{}{} // Trigger a Prometheus reload
+		}
+
+		// Files are processed in parallel, each by the same worker every time.
+		shards := make([]chan string, workerCount(workers))
+		for i := range shards {
+			shards[i] = make(chan string, 16)
+		}
+
 		gr.Add(func() error {
 			for {
 				select {
 				case <-ctx.Done():
 					return nil
 				case f := <-files:
-					// We only care about watching for files with a valid yaml extension
-					if filepath.Ext(f) != ".yaml" && filepath.Ext(f) != ".yml" {
-						level.Warn(logger).Log("msg", "ignoring non YAML file", "file", f)
-						continue
-					}
-
-					level.Debug(logger).Log("msg", "processing", "file", f)
-					reconcilesTotal.Inc()
-
-					err := writeRuleFile(logger, f, prometheusFolder, genericRules, false)
-					if err != nil {
-						reconcilesErrors.Inc()
-						level.Error(logger).Log("msg", "error creating rule file", "file", f, "err", err)
-					}
-
-					_, objective, err := objectiveFromFile(f)
-					if err != nil {
-						reconcilesErrors.Inc()
-						level.Error(logger).Log("msg", "failed to get objective from file", "file", f, "err", err)
+					select {
+					case <-ctx.Done():
+						return nil
+					case shards[fileShard(f, len(shards))] <- f:
 					}
-					objectives.Set(objective)
-
-					reload <- struct{}{} // Trigger a Prometheus reload
 				}
 			}
 		}, func(_ error) {
 			cancel()
 		})
+
+		for _, shard := range shards {
+			shard := shard
+			gr.Add(func() error {
+				for {
+					select {
+					case <-ctx.Done():
+						return nil
+					case f := <-shard:
+						process(f)
+					}
+				}
+			}, func(_ error) {
+				cancel()
+			})
+		}
 	}
 	{
 		// This gorountine waits for reload updates and eventually calls Prometheus' reload endpoint.
@@ -254,7 +303,7 @@ func cmdFilesystem(logger log.Logger, reg *prometheus.Registry, promClient api.C
 
 		server := http.Server{
 			Addr:    ":9444",
-			Handler: h2c.NewHandler(router, &http2.Server{}),
+			Handler: h2c.NewHandler(otelhttp.NewHandler(router, "backend"), &http2.Server{}),
 		}
 
 		gr.Add(func() error {
@@ -272,6 +321,80 @@ func cmdFilesystem(logger log.Logger, reg *prometheus.Registry, promClient api.C
 	return 0
 }
 
+// filesystemReconciler generates the rules of config files.
+// It tracks the content of every file last reconciled,
+// so that only the rules of files that actually changed are written again.
+type filesystemReconciler struct {
+	logger           log.Logger
+	prometheusFolder string
+	genericRules     bool
+	objectives       *Objectives
+
+	mu    sync.Mutex
+	files map[string]reconciledFile
+}
+
+type reconciledFile struct {
+	hash      [sha256.Size]byte
+	objective slo.Objective
+}
+
+// reconcile writes the rules of the file if its content changed since last reconciled,
+// or removes them if the file was deleted. It returns whether the rules changed.
+func (r *filesystemReconciler) reconcile(file string) (bool, error) {
+	r.mu.Lock()
+	previous, exists := r.files[file]
+	r.mu.Unlock()
+
+	content, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		if !exists {
+			return false, nil
+		}
+
+		_, f := filepath.Split(file)
+		path := filepath.Join(r.prometheusFolder, f)
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return false, fmt.Errorf("failed to remove file %q: %w", path, err)
+		}
+		r.objectives.Delete(previous.objective)
+
+		r.mu.Lock()
+		delete(r.files, file)
+		r.mu.Unlock()
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read file %q: %w", file, err)
+	}
+
+	hash := sha256.Sum256(content)
+	if exists && hash == previous.hash {
+		return false, nil
+	}
+
+	if err := writeRuleFile(r.logger, file, r.prometheusFolder, r.genericRules, ruleFormatPrometheus); err != nil {
+		return false, fmt.Errorf("failed to create rule file: %w", err)
+	}
+
+	_, objective, err := objectiveFromBytes(file, content)
+	if err != nil {
+		return false, fmt.Errorf("failed to get objective from file: %w", err)
+	}
+	if exists && previous.objective.Labels.String() != objective.Labels.String() {
+		r.objectives.Delete(previous.objective)
+	}
+	r.objectives.Set(objective)
+
+	r.mu.Lock()
+	if r.files == nil {
+		r.files = map[string]reconciledFile{}
+	}
+	r.files[file] = reconciledFile{hash: hash, objective: objective}
+	r.mu.Unlock()
+	return true, nil
+}
+
 type FilesystemObjectiveServer struct {
 	objectives *Objectives
 }
@@ -286,6 +409,11 @@ func (s *FilesystemObjectiveServer) List(_ context.Context, req *connect.Request
 		}
 	}
 
+	if req.Msg.LabelSelector != "" || req.Msg.PageSize != 0 || req.Msg.PageToken != "" {
+		// Objectives from files have no Kubernetes labels and are few enough to list at once.
+		return nil, connect.NewError(connect.CodeUnimplemented, fmt.Errorf("label selectors and pagination are only supported by the Kubernetes backend"))
+	}
+
 	matchingObjectives := s.objectives.Match(matchers)
 	objectives := make([]*objectivesv1alpha1.Objective, 0, len(matchingObjectives))
 	for _, o := range matchingObjectives {
@@ -301,7 +429,7 @@ func (s *FilesystemObjectiveServer) List(_ context.Context, req *connect.Request
 	}), nil
 }
 
-func writeRuleFile(logger log.Logger, file, prometheusFolder string, genericRules, operatorRule bool) error {
+func writeRuleFile(logger log.Logger, file, prometheusFolder string, genericRules bool, format ruleFileFormat) error {
 	kubeObjective, objective, err := objectiveFromFile(file)
 	if err != nil {
 		return fmt.Errorf("failed to get objective: %w", err)
@@ -320,6 +448,9 @@ func writeRuleFile(logger log.Logger, file, prometheusFolder string, genericRule
 	if err != nil {
 		return fmt.Errorf("invalid objective: %s - %w", file, err)
 	}
+	if objective.SharedBurnrates {
+		return fmt.Errorf("invalid objective: %s - shared burn rates are only written to PrometheusRules", file)
+	}
 
 	increases, err := objective.IncreaseRules()
 	if err != nil {
@@ -335,6 +466,30 @@ func writeRuleFile(logger log.Logger, file, prometheusFolder string, genericRule
 		Groups: []monitoringv1.RuleGroup{increases, burnrates},
 	}
 
+	if objective.BudgetFreeze != nil {
+		freeze, err := objective.BudgetFreezeRules()
+		if err != nil {
+			return fmt.Errorf("failed to get budget freeze rules: %w", err)
+		}
+		rule.Groups = append(rule.Groups, freeze)
+	}
+
+	if objective.Calendar != "" {
+		calendar, err := objective.CalendarRules()
+		if err != nil {
+			return fmt.Errorf("failed to get calendar rules: %w", err)
+		}
+		rule.Groups = append(rule.Groups, calendar)
+	}
+
+	if objective.SLATarget != nil {
+		sla, err := objective.SLARules()
+		if err != nil {
+			return fmt.Errorf("failed to get SLA rules: %w", err)
+		}
+		rule.Groups = append(rule.Groups, sla)
+	}
+
 	if genericRules {
 		rules, err := objective.GenericRules()
 		if err == nil {
@@ -349,13 +504,14 @@ func writeRuleFile(logger log.Logger, file, prometheusFolder string, genericRule
 			)
 		}
 	}
+	objective.RuleGroupOptions(rule.Groups)
 
-	bytes, err := yaml.Marshal(rule)
-	if err != nil {
-		return fmt.Errorf("failed to marshal rules: %w", err)
-	}
+	_, f := filepath.Split(file)
+	path := filepath.Join(prometheusFolder, f)
 
-	if operatorRule {
+	var write func(w io.Writer) (int64, error)
+	switch format {
+	case ruleFormatOperator:
 		monv1rule := &monitoringv1.PrometheusRule{
 			TypeMeta: metav1.TypeMeta{
 				Kind:       monitoringv1.PrometheusRuleKind,
@@ -368,28 +524,70 @@ func writeRuleFile(logger log.Logger, file, prometheusFolder string, genericRule
 			},
 			Spec: rule,
 		}
-
-		bytes, err = yaml.Marshal(monv1rule)
-		if err != nil {
-			return fmt.Errorf("failed to marshal rules: %w", err)
+		write = func(w io.Writer) (int64, error) {
+			return controllers.WritePrometheusRule(w, monv1rule)
+		}
+	case ruleFormatMimirtool:
+		namespace := kubeObjective.GetNamespace()
+		if namespace == "" {
+			namespace = metav1.NamespaceDefault
+		}
+		write = func(w io.Writer) (int64, error) {
+			return writeMimirtoolNamespace(w, namespace, rule)
+		}
+	default:
+		write = func(w io.Writer) (int64, error) {
+			return controllers.WriteRuleSpec(w, rule, 0)
 		}
 	}
 
-	_, f := filepath.Split(file)
-	path := filepath.Join(prometheusFolder, f)
-
-	if err := os.WriteFile(path, bytes, 0o644); err != nil {
-		return fmt.Errorf("failed to write file %q: %w", path, err)
+	size, err := writeFileStreaming(path, write)
+	if err != nil {
+		return err
 	}
+	level.Debug(logger).Log("msg", "wrote rule file", "file", path, "bytes", size)
 	return nil
 }
 
+// writeFileStreaming writes a file through a buffer, without holding its whole content in memory.
+// The content is written to a temporary file first, which replaces the file once complete.
+func writeFileStreaming(path string, write func(io.Writer) (int64, error)) (int64, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file %q: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	buf := bufio.NewWriter(tmp)
+	size, err := write(buf)
+	if err == nil {
+		err = buf.Flush()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return size, fmt.Errorf("failed to write file %q: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return size, fmt.Errorf("failed to write file %q: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return size, fmt.Errorf("failed to write file %q: %w", path, err)
+	}
+	return size, nil
+}
+
 func objectiveFromFile(file string) (v1alpha1.ServiceLevelObjective, slo.Objective, error) {
 	bytes, err := os.ReadFile(file)
 	if err != nil {
 		return v1alpha1.ServiceLevelObjective{}, slo.Objective{}, fmt.Errorf("failed to read file %q: %w", file, err)
 	}
 
+	return objectiveFromBytes(file, bytes)
+}
+
+func objectiveFromBytes(file string, bytes []byte) (v1alpha1.ServiceLevelObjective, slo.Objective, error) {
 	var config v1alpha1.ServiceLevelObjective
 	if err := yaml.UnmarshalStrict(bytes, &config); err != nil {
 		return v1alpha1.ServiceLevelObjective{}, slo.Objective{}, fmt.Errorf("failed to unmarshal objective %q: %w", file, err)
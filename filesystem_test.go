@@ -1,8 +1,13 @@
 package main
 
 import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/go-kit/log"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
 
@@ -56,3 +61,114 @@ func TestMatchObjectives(t *testing.T) {
 	require.Contains(t, matches, obj3)
 	require.Contains(t, matches, obj4)
 }
+
+func TestWriteFileStreaming(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+
+	size, err := writeFileStreaming(path, func(w io.Writer) (int64, error) {
+		n, err := io.WriteString(w, "groups: []\n")
+		return int64(n), err
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(11), size)
+
+	_, err = writeFileStreaming(path, func(w io.Writer) (int64, error) {
+		_, _ = io.WriteString(w, "groups:\n")
+		return 0, errors.New("marshal failed")
+	})
+	require.ErrorContains(t, err, "marshal failed")
+
+	// A failed write keeps the previous file and leaves no temporary files behind.
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "groups: []\n", string(content))
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestFilesystemReconciler(t *testing.T) {
+	configFolder, prometheusFolder := t.TempDir(), t.TempDir()
+	file := filepath.Join(configFolder, "http.yaml")
+	output := filepath.Join(prometheusFolder, "http.yaml")
+
+	objective := func(name, target string) string {
+		return `apiVersion: pyrra.dev/v1alpha1
+kind: ServiceLevelObjective
+metadata:
+  name: ` + name + `
+  namespace: monitoring
+spec:
+  target: '` + target + `'
+  window: 4w
+  indicator:
+    ratio:
+      errors:
+        metric: http_requests_total{job="api",code=~"5.."}
+      total:
+        metric: http_requests_total{job="api"}
+`
+	}
+
+	objectives := &Objectives{objectives: map[string]slo.Objective{}}
+	r := &filesystemReconciler{
+		logger:           log.NewNopLogger(),
+		prometheusFolder: prometheusFolder,
+		objectives:       objectives,
+	}
+
+	// Unknown files that don't exist are nothing to remove.
+	changed, err := r.reconcile(file)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	require.NoError(t, os.WriteFile(file, []byte(objective("http", "99")), 0o644))
+	changed, err = r.reconcile(file)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.FileExists(t, output)
+	require.Len(t, objectives.Match(nil), 1)
+
+	// Writing the same content again doesn't regenerate the rules.
+	require.NoError(t, os.Remove(output))
+	require.NoError(t, os.WriteFile(file, []byte(objective("http", "99")), 0o644))
+	changed, err = r.reconcile(file)
+	require.NoError(t, err)
+	require.False(t, changed)
+	require.NoFileExists(t, output)
+
+	// Renaming the objective replaces the previous one.
+	require.NoError(t, os.WriteFile(file, []byte(objective("http-errors", "99.5")), 0o644))
+	changed, err = r.reconcile(file)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.FileExists(t, output)
+	matched := objectives.Match(nil)
+	require.Len(t, matched, 1)
+	require.Equal(t, "http-errors", matched[0].Name())
+	require.Equal(t, 0.995, matched[0].Target)
+
+	// Invalid content isn't recorded and is reconciled again.
+	require.NoError(t, os.WriteFile(file, []byte("invalid: ["), 0o644))
+	_, err = r.reconcile(file)
+	require.Error(t, err)
+	_, err = r.reconcile(file)
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(file, []byte(objective("http-errors", "99.5")), 0o644))
+	changed, err = r.reconcile(file)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	// Deleting the file removes its rules and objective.
+	require.NoError(t, os.Remove(file))
+	changed, err = r.reconcile(file)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.NoFileExists(t, output)
+	require.Empty(t, objectives.Match(nil))
+
+	changed, err = r.reconcile(file)
+	require.NoError(t, err)
+	require.False(t, changed)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	prometheusapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	objectivesv1alpha1 "github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1"
+	"github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1/objectivesv1alpha1connect"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+// forecastConfidence is the z-score of the confidence bands of forecasts, about 95% for normally distributed errors.
+const forecastConfidence = 1.96
+
+type rangeQuerier interface {
+	QueryRange(ctx context.Context, query string, r prometheusapiv1.Range) (model.Value, prometheusapiv1.Warnings, error)
+}
+
+// forecastHandler forecasts when the error budget of an objective is exhausted.
+// It fits a line to the remaining error budget over the lookback, like ?expr={__name__="api"}&lookback=1d,
+// and extrapolates when it reaches 0 at the recent burn rate.
+// The error budget of the objective's rolling window recovers as old errors leave it,
+// so forecasts much further out than the lookback are only a rough estimate.
+type forecastHandler struct {
+	logger  log.Logger
+	promAPI rangeQuerier
+	client  objectivesv1alpha1connect.ObjectiveBackendServiceClient
+}
+
+type forecastResponse struct {
+	Objective string `json:"objective"`
+	Lookback  string `json:"lookback"`
+	// Forecasts has a forecast per series of the error budget, one per group of objectives with grouping.
+	Forecasts []budgetForecast `json:"forecasts"`
+}
+
+type budgetForecast struct {
+	Labels map[string]string `json:"labels"`
+	// Remaining is the latest remaining error budget, 1 if none of it is used and negative if it is exceeded.
+	Remaining float64 `json:"remaining"`
+	// Burn is the error budget used per hour at the recent burn rate, negative while it recovers.
+	Burn float64 `json:"burn"`
+	// Exhausted is when the error budget is forecast to be exhausted,
+	// null if it isn't burning and so is the confidence band.
+	Exhausted *time.Time `json:"exhausted"`
+	// ExhaustedEarliest and ExhaustedLatest are the confidence band of the forecast.
+	// ExhaustedLatest is null if the error budget might not be burning.
+	ExhaustedEarliest *time.Time `json:"exhaustedEarliest"`
+	ExhaustedLatest   *time.Time `json:"exhaustedLatest"`
+}
+
+func (h *forecastHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	expr := r.URL.Query().Get("expr")
+	if expr == "" {
+		http.Error(w, "expr selecting the objective is required, like ?expr={__name__=\"name\"}", http.StatusBadRequest)
+		return
+	}
+	if _, err := parser.ParseMetricSelector(expr); err != nil {
+		http.Error(w, fmt.Sprintf("failed to parse expr: %s", err), http.StatusBadRequest)
+		return
+	}
+	lookback := 24 * time.Hour
+	if l := r.URL.Query().Get("lookback"); l != "" {
+		d, err := model.ParseDuration(l)
+		if err != nil || d <= 0 {
+			http.Error(w, fmt.Sprintf("lookback must be a duration like 1d, not %q", l), http.StatusBadRequest)
+			return
+		}
+		lookback = time.Duration(d)
+	}
+
+	objective, err := h.objective(r.Context(), expr)
+	if err != nil {
+		var connectErr *connect.Error
+		if errors.As(err, &connectErr) {
+			http.Error(w, connectErr.Message(), http.StatusNotFound)
+			return
+		}
+		level.Warn(h.logger).Log("msg", "failed to get objective", "expr", expr, "err", err)
+		http.Error(w, "failed to get objective", http.StatusBadGateway)
+		return
+	}
+
+	resp, err := h.forecast(r.Context(), objective, lookback, time.Now())
+	if err != nil {
+		level.Warn(h.logger).Log("msg", "failed to query error budget", "objective", objective.Name(), "err", err)
+		http.Error(w, "failed to query error budget", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// objective returns the only objective matching the expr, or a connect error with code NotFound if there isn't exactly one.
+func (h *forecastHandler) objective(ctx context.Context, expr string) (slo.Objective, error) {
+	resp, err := h.client.List(ctx, connect.NewRequest(&objectivesv1alpha1.ListRequest{Expr: expr}))
+	if err != nil {
+		return slo.Objective{}, err
+	}
+	if len(resp.Msg.Objectives) != 1 {
+		return slo.Objective{}, connect.NewError(connect.CodeNotFound, fmt.Errorf("expr must match exactly one objective, it matches %d", len(resp.Msg.Objectives)))
+	}
+	return objectivesv1alpha1.ToInternal(resp.Msg.Objectives[0]), nil
+}
+
+func (h *forecastHandler) forecast(ctx context.Context, objective slo.Objective, lookback time.Duration, now time.Time) (forecastResponse, error) {
+	value, _, err := h.promAPI.QueryRange(contextSetPromCache(ctx, 15*time.Second), objective.QueryErrorBudget(), prometheusapiv1.Range{
+		Start: now.Add(-lookback),
+		End:   now,
+		Step:  max(lookback/100, time.Minute),
+	})
+	if err != nil {
+		return forecastResponse{}, err
+	}
+	matrix, ok := value.(model.Matrix)
+	if !ok {
+		return forecastResponse{}, fmt.Errorf("expected matrix, got %s", value.Type())
+	}
+
+	sort.Sort(matrix)
+
+	resp := forecastResponse{
+		Objective: objective.Name(),
+		Lookback:  model.Duration(lookback).String(),
+		Forecasts: []budgetForecast{},
+	}
+	for _, stream := range matrix {
+		forecast, ok := forecastBudget(stream.Values)
+		if !ok {
+			continue
+		}
+		forecast.Labels = map[string]string{}
+		for name, value := range stream.Metric {
+			forecast.Labels[string(name)] = string(value)
+		}
+		resp.Forecasts = append(resp.Forecasts, forecast)
+	}
+
+	return resp, nil
+}
+
+// forecastBudget fits a line to the remaining error budget with least squares and extrapolates when it reaches 0.
+// The confidence band extrapolates the slopes within the standard error of the fitted slope through the mean of the samples.
+// It returns false if there aren't enough samples to fit a line.
+func forecastBudget(samples []model.SamplePair) (budgetForecast, bool) {
+	// Samples without requests have no error budget.
+	points := make([]model.SamplePair, 0, len(samples))
+	for _, s := range samples {
+		if !math.IsNaN(float64(s.Value)) && !math.IsInf(float64(s.Value), 0) {
+			points = append(points, s)
+		}
+	}
+	if len(points) < 3 {
+		return budgetForecast{}, false
+	}
+
+	last := points[len(points)-1]
+	end := last.Timestamp.Time()
+	// The x values are hours before the last sample, to keep the sums small.
+	hours := func(s model.SamplePair) float64 {
+		return s.Timestamp.Time().Sub(end).Hours()
+	}
+
+	n := float64(len(points))
+	var meanX, meanY float64
+	for _, p := range points {
+		meanX += hours(p)
+		meanY += float64(p.Value)
+	}
+	meanX /= n
+	meanY /= n
+
+	var sxx, sxy float64
+	for _, p := range points {
+		dx := hours(p) - meanX
+		sxx += dx * dx
+		sxy += dx * (float64(p.Value) - meanY)
+	}
+	if sxx == 0 {
+		return budgetForecast{}, false
+	}
+	slope := sxy / sxx
+
+	var residuals float64
+	for _, p := range points {
+		r := float64(p.Value) - (meanY + slope*(hours(p)-meanX))
+		residuals += r * r
+	}
+	stderr := math.Sqrt(residuals / (n - 2) / sxx)
+
+	forecast := budgetForecast{
+		Remaining: float64(last.Value),
+		Burn:      -slope,
+	}
+	if forecast.Remaining <= 0 {
+		forecast.Exhausted, forecast.ExhaustedEarliest, forecast.ExhaustedLatest = &end, &end, &end
+		return forecast, true
+	}
+
+	// exhausted returns when the line with the slope through the mean reaches 0, not before the last sample.
+	exhausted := func(slope float64) *time.Time {
+		if slope >= 0 {
+			return nil
+		}
+		at := end.Add(time.Duration(math.Max(0, meanX-meanY/slope) * float64(time.Hour)))
+		return &at
+	}
+	forecast.Exhausted = exhausted(slope)
+	if forecast.Exhausted == nil {
+		return forecast, true
+	}
+	forecast.ExhaustedEarliest = exhausted(slope - forecastConfidence*stderr)
+	forecast.ExhaustedLatest = exhausted(slope + forecastConfidence*stderr)
+
+	return forecast, true
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	prometheusapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+type rangeQueryFunc func(query string, r prometheusapiv1.Range) model.Value
+
+func (f rangeQueryFunc) QueryRange(_ context.Context, query string, r prometheusapiv1.Range) (model.Value, prometheusapiv1.Warnings, error) {
+	return f(query, r), nil, nil
+}
+
+// budgetSamples returns hourly samples of the remaining error budget ending at end.
+func budgetSamples(end time.Time, values ...float64) []model.SamplePair {
+	samples := make([]model.SamplePair, 0, len(values))
+	for i, v := range values {
+		ts := end.Add(-time.Duration(len(values)-1-i) * time.Hour)
+		samples = append(samples, model.SamplePair{Timestamp: model.TimeFromUnixNano(ts.UnixNano()), Value: model.SampleValue(v)})
+	}
+	return samples
+}
+
+func TestForecastBudget(t *testing.T) {
+	end := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// 1% of the error budget is used every hour, the remaining 50% last 50 hours.
+	forecast, ok := forecastBudget(budgetSamples(end, 0.6, 0.59, 0.58, 0.57, 0.56, 0.55, 0.54, 0.53, 0.52, 0.51, 0.5))
+	require.True(t, ok)
+	require.InDelta(t, 0.5, forecast.Remaining, 1e-9)
+	require.InDelta(t, 0.01, forecast.Burn, 1e-9)
+	require.WithinDuration(t, end.Add(50*time.Hour), *forecast.Exhausted, time.Second)
+	require.WithinDuration(t, *forecast.Exhausted, *forecast.ExhaustedEarliest, time.Second)
+	require.WithinDuration(t, *forecast.Exhausted, *forecast.ExhaustedLatest, time.Second)
+
+	// Noisy burns have a wider confidence band.
+	forecast, ok = forecastBudget(budgetSamples(end, 0.6, 0.58, 0.59, 0.56, 0.57, 0.54, 0.55, 0.52, 0.53, 0.5, 0.51))
+	require.True(t, ok)
+	require.True(t, forecast.ExhaustedEarliest.Before(*forecast.Exhausted))
+	require.True(t, forecast.ExhaustedLatest.After(*forecast.Exhausted))
+
+	// Bursts of errors can make the slope uncertain enough to not be burning at all.
+	forecast, ok = forecastBudget(budgetSamples(end, 0.6, 0.6, 0.6, 0.6, 0.6, 0.6, 0.6, 0.5, 0.6, 0.59))
+	require.True(t, ok)
+	require.NotNil(t, forecast.Exhausted)
+	require.NotNil(t, forecast.ExhaustedEarliest)
+	require.Nil(t, forecast.ExhaustedLatest)
+
+	// Recovering error budgets aren't exhausted.
+	forecast, ok = forecastBudget(budgetSamples(end, 0.5, 0.51, 0.52))
+	require.True(t, ok)
+	require.InDelta(t, -0.01, forecast.Burn, 1e-9)
+	require.Nil(t, forecast.Exhausted)
+	require.Nil(t, forecast.ExhaustedEarliest)
+	require.Nil(t, forecast.ExhaustedLatest)
+
+	// Exceeded error budgets are exhausted already.
+	forecast, ok = forecastBudget(budgetSamples(end, 0.02, 0.01, 0, -0.01))
+	require.True(t, ok)
+	require.True(t, end.Equal(*forecast.Exhausted))
+
+	// Samples without requests are skipped.
+	_, ok = forecastBudget(budgetSamples(end, 0.5, 0.4))
+	require.False(t, ok)
+	_, ok = forecastBudget(budgetSamples(end, 0.5, 0.4, math.NaN()))
+	require.False(t, ok)
+}
+
+func TestForecastHandler(t *testing.T) {
+	objective := reportObjective("api", "")
+	h := &forecastHandler{
+		logger: log.NewNopLogger(),
+		client: staticBackend{objectives: []slo.Objective{objective}},
+		promAPI: rangeQueryFunc(func(query string, r prometheusapiv1.Range) model.Value {
+			require.Equal(t, objective.QueryErrorBudget(), query)
+			require.Equal(t, 6*time.Hour, r.End.Sub(r.Start))
+			return model.Matrix{{
+				Metric: model.Metric{"handler": "/b"},
+				Values: budgetSamples(r.End, 0.5, 0.5, 0.5),
+			}, {
+				Metric: model.Metric{"handler": "/a"},
+				Values: budgetSamples(r.End, 0.03, 0.02, 0.01),
+			}, {
+				// No requests at all.
+				Metric: model.Metric{"handler": "/c"},
+			}}
+		}),
+	}
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := serve(`/forecast?expr={__name__="api"}&lookback=6h`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp forecastResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, "api", resp.Objective)
+	require.Equal(t, "6h", resp.Lookback)
+	require.Len(t, resp.Forecasts, 2)
+
+	// The error budget of /a is used up an hour after the last sample.
+	require.Equal(t, map[string]string{"handler": "/a"}, resp.Forecasts[0].Labels)
+	require.InDelta(t, 0.01, resp.Forecasts[0].Burn, 1e-9)
+	require.WithinDuration(t, time.Now().Add(time.Hour), *resp.Forecasts[0].Exhausted, time.Minute)
+	require.Equal(t, map[string]string{"handler": "/b"}, resp.Forecasts[1].Labels)
+	require.Nil(t, resp.Forecasts[1].Exhausted)
+
+	rec = serve(`/forecast`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = serve(`/forecast?expr={__name__="api"}&lookback=1x`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Equal(t, "lookback must be a duration like 1d, not \"1x\"\n", rec.Body.String())
+
+	h.client = staticBackend{}
+	rec = serve(`/forecast?expr={__name__="api"}`)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Equal(t, "expr must match exactly one objective, it matches 0\n", rec.Body.String())
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// freezeHandler answers whether changes should be frozen, as the error budget policy of a matching objective says so.
+// The query parameters select the pyrra_budget_freeze series by their labels, like ?team=checkout or ?slo=checkout-availability.
+// It responds with 200 while changes can go ahead and with 423 Locked while they are frozen,
+// so deployment pipelines can gate releases with a single request like curl --fail.
+type freezeHandler struct {
+	logger  log.Logger
+	promAPI budgetQuerier
+}
+
+type freezeResponse struct {
+	Freeze bool `json:"freeze"`
+	// Objectives are the names of the matching objectives that freeze changes.
+	Objectives []string `json:"objectives"`
+}
+
+func (h *freezeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query, err := freezeQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, found, err := h.freeze(r.Context(), query)
+	if err != nil {
+		level.Warn(h.logger).Log("msg", "failed to query budget freeze", "query", query, "err", err)
+		http.Error(w, "failed to query budget freeze", http.StatusBadGateway)
+		return
+	}
+	if !found {
+		http.Error(w, "no objective with a budget freeze matches", http.StatusNotFound)
+		return
+	}
+
+	status := http.StatusOK
+	if resp.Freeze {
+		status = http.StatusLocked
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// freeze returns which of the objectives selected by the query freeze changes,
+// and whether the query selected any objectives at all.
+func (h *freezeHandler) freeze(ctx context.Context, query string) (freezeResponse, bool, error) {
+	value, _, err := h.promAPI.Query(ctx, query, time.Now())
+	if err != nil {
+		return freezeResponse{}, false, err
+	}
+	vector, ok := value.(model.Vector)
+	if !ok {
+		return freezeResponse{}, false, fmt.Errorf("expected vector, got %s", value.Type())
+	}
+
+	resp := freezeResponse{Objectives: []string{}}
+	for _, sample := range vector {
+		if sample.Value != 1 {
+			continue
+		}
+		resp.Freeze = true
+		resp.Objectives = append(resp.Objectives, string(sample.Metric["slo"]))
+	}
+	sort.Strings(resp.Objectives)
+
+	return resp, len(vector) > 0, nil
+}
+
+// freezeQuery returns the query selecting the pyrra_budget_freeze series with all labels of the parameters.
+func freezeQuery(params url.Values) (string, error) {
+	if len(params) == 0 {
+		return "", errors.New("at least one label to select objectives by is required, like ?slo=name")
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	matchers := []*labels.Matcher{
+		labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "pyrra_budget_freeze"),
+	}
+	for _, name := range names {
+		if !model.LabelName(name).IsValid() {
+			return "", fmt.Errorf("invalid label name %q", name)
+		}
+		matchers = append(matchers, labels.MustNewMatcher(labels.MatchEqual, name, params.Get(name)))
+	}
+
+	return (&parser.VectorSelector{Name: "pyrra_budget_freeze", LabelMatchers: matchers}).String(), nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreezeQuery(t *testing.T) {
+	query, err := freezeQuery(url.Values{"team": {"checkout"}, "slo": {`say "hi"`}})
+	require.NoError(t, err)
+	require.Equal(t, `pyrra_budget_freeze{slo="say \"hi\"",team="checkout"}`, query)
+
+	_, err = freezeQuery(url.Values{})
+	require.EqualError(t, err, "at least one label to select objectives by is required, like ?slo=name")
+
+	_, err = freezeQuery(url.Values{"team-name": {"checkout"}})
+	require.EqualError(t, err, `invalid label name "team-name"`)
+}
+
+func TestFreezeHandler(t *testing.T) {
+	var vector model.Vector
+	h := &freezeHandler{
+		logger: log.NewNopLogger(),
+		promAPI: queryFunc(func(query string) model.Value {
+			require.Equal(t, `pyrra_budget_freeze{team="checkout"}`, query)
+			return vector
+		}),
+	}
+
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/freeze?team=checkout", nil))
+		return rec
+	}
+
+	rec := serve()
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	vector = model.Vector{
+		{Metric: model.Metric{"slo": "checkout-latency", "team": "checkout"}, Value: 0},
+		{Metric: model.Metric{"slo": "checkout-errors", "team": "checkout"}, Value: 0},
+	}
+	rec = serve()
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"freeze":false,"objectives":[]}`, rec.Body.String())
+
+	vector[0].Value = 1
+	rec = serve()
+	require.Equal(t, http.StatusLocked, rec.Code)
+	require.JSONEq(t, `{"freeze":true,"objectives":["checkout-latency"]}`, rec.Body.String())
+}
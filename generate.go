@@ -14,25 +14,140 @@ limitations under the License.
 package main
 
 import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
 	"path/filepath"
+	"runtime"
+	"sync"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/pyrra-dev/pyrra/kubernetes/controllers"
 )
 
-func cmdGenerate(logger log.Logger, configFiles, prometheusFolder string, genericRules, operatorRule bool) int {
+// ruleFileFormat is the format the generated rule files are written in.
+type ruleFileFormat string
+
+const (
+	// ruleFormatPrometheus writes Prometheus rule files, loaded with rule_files.
+	ruleFormatPrometheus ruleFileFormat = "prometheus"
+	// ruleFormatOperator writes prometheus-operator PrometheusRules: https://prometheus-operator.dev/docs/operator/api/#monitoring.coreos.com/v1.PrometheusRule.
+	ruleFormatOperator ruleFileFormat = "prometheus-operator"
+	// ruleFormatMimirtool writes mimirtool namespace files, synced to Mimir's or Cortex' ruler with mimirtool rules sync.
+	// The namespace is the one of the objective.
+	ruleFormatMimirtool ruleFileFormat = "mimirtool"
+)
+
+type GenerateConfig struct {
+	OutputFormat string `default:"prometheus" help:"The format of the generated rule files. One of prometheus, prometheus-operator or mimirtool, whose namespace files use the objective's namespace."`
+	OperatorRule bool   `default:"false" help:"Deprecated: Use --output-format=prometheus-operator."`
+}
+
+// Validate checks the output format is known and supports --operator-rule.
+func (gc *GenerateConfig) Validate() error {
+	switch ruleFileFormat(gc.OutputFormat) {
+	case ruleFormatPrometheus, ruleFormatOperator, ruleFormatMimirtool:
+	default:
+		return fmt.Errorf("--output-format must be one of prometheus, prometheus-operator or mimirtool")
+	}
+	if gc.OperatorRule && ruleFileFormat(gc.OutputFormat) == ruleFormatMimirtool {
+		return fmt.Errorf("--operator-rule can't be used with --output-format=mimirtool")
+	}
+	return nil
+}
+
+// Format returns the format of the rule files, prometheus-operator if the deprecated --operator-rule is set.
+func (gc GenerateConfig) Format() ruleFileFormat {
+	if gc.OperatorRule {
+		return ruleFormatOperator
+	}
+	return ruleFileFormat(gc.OutputFormat)
+}
+
+func cmdGenerate(logger log.Logger, configFiles, prometheusFolder string, genericRules bool, format ruleFileFormat, workers int) int {
 	filenames, err := filepath.Glob(configFiles)
 	if err != nil {
 		level.Error(logger).Log("msg", "getting file names", "err", err)
 		return 1
 	}
 
-	for _, file := range filenames {
-		err := writeRuleFile(logger, file, prometheusFolder, genericRules, operatorRule)
-		if err != nil {
-			level.Error(logger).Log("msg", "generating rule files", "err", err)
-			return 1
-		}
+	// The output folder may be new, like one in a Git repository that rules are committed to.
+	if err := os.MkdirAll(prometheusFolder, 0o755); err != nil {
+		level.Error(logger).Log("msg", "creating output folder", "err", err)
+		return 1
+	}
+
+	err = forEachFile(filenames, workers, func(file string) error {
+		return writeRuleFile(logger, file, prometheusFolder, genericRules, format)
+	})
+	if err != nil {
+		level.Error(logger).Log("msg", "generating rule files", "err", err)
+		return 1
 	}
 	return 0
 }
+
+// forEachFile calls fn for every file, with up to the given number of workers in parallel.
+// All files are processed even if some fail and the errors of all failed files are returned joined.
+func forEachFile(files []string, workers int, fn func(file string) error) error {
+	errs := make([]error, len(files))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(workerCount(workers), len(files)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := fn(files[i]); err != nil {
+					errs[i] = fmt.Errorf("%s: %w", files[i], err)
+				}
+			}
+		}()
+	}
+	for i := range files {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// workerCount returns the number of workers to use, defaulting to the number of CPUs.
+func workerCount(workers int) int {
+	if workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return workers
+}
+
+// fileShard returns the worker a file is always processed by,
+// so that changes to the same file are processed in order.
+func fileShard(file string, workers int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(file))
+	return int(h.Sum32() % uint32(workers))
+}
+
+// writeMimirtoolNamespace writes the rule groups as a mimirtool namespace file of the ruler namespace.
+func writeMimirtoolNamespace(w io.Writer, namespace string, spec monitoringv1.PrometheusRuleSpec) (int64, error) {
+	b, err := yaml.Marshal(struct {
+		Namespace string `json:"namespace"`
+	}{Namespace: namespace})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal namespace: %w", err)
+	}
+	n, err := w.Write(b)
+	if err != nil {
+		return int64(n), err
+	}
+	size, err := controllers.WriteRuleSpec(w, spec, 0)
+	return int64(n) + size, err
+}
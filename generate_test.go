@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"sigs.k8s.io/yaml"
+)
+
+func TestForEachFile(t *testing.T) {
+	files := make([]string, 20)
+	for i := range files {
+		files[i] = fmt.Sprintf("%02d.yaml", i)
+	}
+
+	var (
+		mu        sync.Mutex
+		processed []string
+		running   atomic.Int32
+		maxRun    atomic.Int32
+	)
+	err := forEachFile(files, 3, func(file string) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRun.Load()
+			if n <= m || maxRun.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		processed = append(processed, file)
+		mu.Unlock()
+
+		if file == "03.yaml" || file == "17.yaml" {
+			return errors.New("invalid objective")
+		}
+		return nil
+	})
+
+	require.ElementsMatch(t, files, processed)
+	require.LessOrEqual(t, maxRun.Load(), int32(3))
+	require.EqualError(t, err, "03.yaml: invalid objective\n17.yaml: invalid objective")
+
+	require.NoError(t, forEachFile(nil, 0, func(string) error { return errors.New("unreachable") }))
+}
+
+func TestFileShard(t *testing.T) {
+	for _, file := range []string{"a.yaml", "b.yaml", "/etc/pyrra/c.yaml"} {
+		shard := fileShard(file, 4)
+		require.GreaterOrEqual(t, shard, 0)
+		require.Less(t, shard, 4)
+		require.Equal(t, shard, fileShard(file, 4))
+	}
+	require.Equal(t, 0, fileShard("a.yaml", 1))
+}
+
+func TestGenerateConfig_Validate(t *testing.T) {
+	for _, format := range []string{"prometheus", "prometheus-operator", "mimirtool"} {
+		require.NoError(t, (&GenerateConfig{OutputFormat: format}).Validate())
+	}
+	require.EqualError(t, (&GenerateConfig{OutputFormat: "thanos"}).Validate(), "--output-format must be one of prometheus, prometheus-operator or mimirtool")
+	require.EqualError(t, (&GenerateConfig{OutputFormat: "mimirtool", OperatorRule: true}).Validate(), "--operator-rule can't be used with --output-format=mimirtool")
+
+	require.Equal(t, ruleFormatMimirtool, GenerateConfig{OutputFormat: "mimirtool"}.Format())
+	require.Equal(t, ruleFormatOperator, GenerateConfig{OutputFormat: "prometheus", OperatorRule: true}.Format())
+}
+
+func TestCmdGenerate(t *testing.T) {
+	configFolder := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(configFolder, "http.yaml"), []byte(`apiVersion: pyrra.dev/v1alpha1
+kind: ServiceLevelObjective
+metadata:
+  name: http
+  namespace: monitoring
+  labels:
+    team: api
+spec:
+  target: '99'
+  window: 4w
+  indicator:
+    ratio:
+      errors:
+        metric: http_requests_total{job="api",code=~"5.."}
+      total:
+        metric: http_requests_total{job="api"}
+`), 0o644))
+	// Objectives of the filesystem operator don't need a namespace.
+	require.NoError(t, os.WriteFile(filepath.Join(configFolder, "grpc.yaml"), []byte(`metadata:
+  name: grpc
+spec:
+  target: '99'
+  window: 4w
+  indicator:
+    ratio:
+      errors:
+        metric: grpc_server_handled_total{job="api",grpc_code="Unavailable"}
+      total:
+        metric: grpc_server_handled_total{job="api"}
+`), 0o644))
+	configFiles := filepath.Join(configFolder, "*.yaml")
+
+	t.Run("prometheus", func(t *testing.T) {
+		// The output folder is created if it doesn't exist.
+		output := filepath.Join(t.TempDir(), "rules")
+		require.Equal(t, 0, cmdGenerate(log.NewNopLogger(), configFiles, output, false, ruleFormatPrometheus, 0))
+
+		b, err := os.ReadFile(filepath.Join(output, "http.yaml"))
+		require.NoError(t, err)
+		var spec monitoringv1.PrometheusRuleSpec
+		require.NoError(t, yaml.UnmarshalStrict(b, &spec))
+		require.Len(t, spec.Groups, 2)
+		require.Equal(t, "http-increase", spec.Groups[0].Name)
+	})
+
+	t.Run("prometheus-operator", func(t *testing.T) {
+		output := t.TempDir()
+		require.Equal(t, 0, cmdGenerate(log.NewNopLogger(), configFiles, output, false, ruleFormatOperator, 0))
+
+		b, err := os.ReadFile(filepath.Join(output, "http.yaml"))
+		require.NoError(t, err)
+		var rule monitoringv1.PrometheusRule
+		require.NoError(t, yaml.UnmarshalStrict(b, &rule))
+		require.Equal(t, monitoringv1.PrometheusRuleKind, rule.Kind)
+		require.Equal(t, "monitoring", rule.Namespace)
+		require.Equal(t, map[string]string{"team": "api"}, rule.Labels)
+		require.Len(t, rule.Spec.Groups, 2)
+	})
+
+	t.Run("mimirtool", func(t *testing.T) {
+		output := t.TempDir()
+		require.Equal(t, 0, cmdGenerate(log.NewNopLogger(), configFiles, output, true, ruleFormatMimirtool, 0))
+
+		var namespaceFile struct {
+			Namespace string                   `json:"namespace"`
+			Groups    []monitoringv1.RuleGroup `json:"groups"`
+		}
+		b, err := os.ReadFile(filepath.Join(output, "http.yaml"))
+		require.NoError(t, err)
+		require.NoError(t, yaml.UnmarshalStrict(b, &namespaceFile))
+		require.Equal(t, "monitoring", namespaceFile.Namespace)
+		require.Len(t, namespaceFile.Groups, 3)
+		require.Equal(t, "http-generic", namespaceFile.Groups[2].Name)
+
+		b, err = os.ReadFile(filepath.Join(output, "grpc.yaml"))
+		require.NoError(t, err)
+		require.NoError(t, yaml.UnmarshalStrict(b, &namespaceFile))
+		require.Equal(t, "default", namespaceFile.Namespace)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(configFolder, "invalid.yaml"), []byte("spec: ["), 0o644))
+		defer os.Remove(filepath.Join(configFolder, "invalid.yaml"))
+
+		output := t.TempDir()
+		require.Equal(t, 1, cmdGenerate(log.NewNopLogger(), configFiles, output, false, ruleFormatPrometheus, 0))
+		// The valid objectives are still generated.
+		require.FileExists(t, filepath.Join(output, "http.yaml"))
+	})
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	objectivesv1alpha1 "github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1"
+	"github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1/objectivesv1alpha1connect"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+// jiraLabel is added to all issues opened by Pyrra to find them again.
+const jiraLabel = "pyrra"
+
+type JiraConfig struct {
+	JiraURL               *url.URL      `help:"URL of the Jira instance to open issues in when burn rate alerts of the --jira-severities fire. Issues are resolved once the alerts stop firing."`
+	JiraSeverities        []string      `default:"warning" help:"Severities of the burn rate alerts that open Jira issues. Objectives whose alerting windows use none of them, like ones with custom severities or only critical alerts, open issues for the alerts of their slowest burning window instead."`
+	JiraProject           string        `help:"Key of the Jira project to open issues in."`
+	JiraIssueType         string        `default:"Bug" help:"Type of the Jira issues opened."`
+	JiraUsername          string        `help:"Username to authenticate against Jira with. If empty the API token is sent as bearer token."`
+	JiraAPIToken          string        `name:"jira-api-token" env:"PYRRA_JIRA_API_TOKEN" help:"API token to authenticate against Jira with."`
+	JiraResolveTransition string        `default:"Done" help:"Name of the workflow transition to resolve issues with."`
+	JiraPyrraURL          *url.URL      `help:"The URL of Pyrra's UI to link to from the issues."`
+	JiraInterval          time.Duration `default:"1m" help:"How often the alerts are checked to open or resolve Jira issues."`
+}
+
+// Validate checks the Jira URL comes with a project, an interval and severities.
+func (jc *JiraConfig) Validate() error {
+	if jc.JiraURL == nil {
+		return nil
+	}
+	if jc.JiraProject == "" {
+		return fmt.Errorf("--jira-url requires --jira-project")
+	}
+	if jc.JiraInterval <= 0 {
+		return fmt.Errorf("--jira-interval must be greater than 0")
+	}
+	if len(jc.JiraSeverities) == 0 {
+		return fmt.Errorf("--jira-severities must not be empty")
+	}
+	return nil
+}
+
+// client returns a Jira client if Jira is configured.
+func (jc JiraConfig) client(client *http.Client) *jiraClient {
+	if jc.JiraURL == nil {
+		return nil
+	}
+	return &jiraClient{
+		url:      strings.TrimSuffix(jc.JiraURL.String(), "/"),
+		username: jc.JiraUsername,
+		token:    jc.JiraAPIToken,
+		client:   client,
+	}
+}
+
+type jiraIssue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Labels []string `json:"labels"`
+	} `json:"fields"`
+}
+
+// jiraClient talks to Jira's REST API v2, which both Jira Cloud and Data Center serve.
+type jiraClient struct {
+	url      string
+	username string
+	token    string
+	client   *http.Client
+}
+
+// openIssues returns all unresolved issues that were opened by Pyrra in the project.
+func (c *jiraClient) openIssues(ctx context.Context, project string) ([]jiraIssue, error) {
+	var resp struct {
+		Issues []jiraIssue `json:"issues"`
+	}
+	err := c.do(ctx, http.MethodPost, "/rest/api/2/search", map[string]any{
+		"jql":        fmt.Sprintf(`project = %q AND labels = %q AND statusCategory != Done`, project, jiraLabel),
+		"fields":     []string{"labels"},
+		"maxResults": 1000,
+	}, &resp)
+	return resp.Issues, err
+}
+
+func (c *jiraClient) createIssue(ctx context.Context, fields map[string]any) (string, error) {
+	var resp jiraIssue
+	if err := c.do(ctx, http.MethodPost, "/rest/api/2/issue", map[string]any{"fields": fields}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Key, nil
+}
+
+// transitionIssue moves the issue through the transition with the given name and leaves a comment.
+func (c *jiraClient) transitionIssue(ctx context.Context, key, transition, comment string) error {
+	var transitions struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"transitions"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/rest/api/2/issue/"+key+"/transitions", nil, &transitions); err != nil {
+		return err
+	}
+
+	for _, t := range transitions.Transitions {
+		if !strings.EqualFold(t.Name, transition) {
+			continue
+		}
+		return c.do(ctx, http.MethodPost, "/rest/api/2/issue/"+key+"/transitions", map[string]any{
+			"transition": map[string]string{"id": t.ID},
+			"update": map[string]any{
+				"comment": []any{map[string]any{"add": map[string]string{"body": comment}}},
+			},
+		}, nil)
+	}
+
+	return fmt.Errorf("transition %q not available for issue %s", transition, key)
+}
+
+func (c *jiraClient) do(ctx context.Context, method, path string, in, out any) error {
+	req, err := newJSONRequest(ctx, method, c.url+path, in)
+	if err != nil {
+		return err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.token)
+	} else if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return doJSON(c.client, req, out)
+}
+
+// jiraBridge opens a Jira issue for every objective with firing burn rate alerts of its ticket severities
+// and resolves it once they stop firing.
+// It keeps no state of its own, issues are matched to objectives by their labels.
+type jiraBridge struct {
+	logger     log.Logger
+	client     objectivesv1alpha1connect.ObjectiveBackendServiceClient
+	promAPI    budgetQuerier
+	jira       *jiraClient
+	project    string
+	issueType  string
+	transition string
+	pyrraURL   string
+	// severities of the burn rate alerts that open issues, see ticketSeverities.
+	severities []string
+}
+
+func (b *jiraBridge) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := b.sync(ctx); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to sync Jira issues", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *jiraBridge) sync(ctx context.Context) error {
+	resp, err := b.client.List(ctx, connect.NewRequest(&objectivesv1alpha1.ListRequest{}))
+	if err != nil {
+		return fmt.Errorf("failed to list objectives: %w", err)
+	}
+
+	objectives := make([]slo.Objective, 0, len(resp.Msg.Objectives))
+	severities := map[string]map[string]bool{}
+	all := map[string]bool{}
+	for _, o := range resp.Msg.Objectives {
+		objective := objectivesv1alpha1.ToInternal(o)
+		objectives = append(objectives, objective)
+		severities[objectiveIssueLabel(objective)] = b.ticketSeverities(objective)
+		for s := range severities[objectiveIssueLabel(objective)] {
+			all[s] = true
+		}
+	}
+
+	// firing holds the objectives with firing alerts by their issue label.
+	firing := map[string]slo.Objective{}
+	if len(all) > 0 {
+		query := firingAlertsQuery(all)
+		value, _, err := b.promAPI.Query(ctx, query, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to query alerts: %w", err)
+		}
+		vector, ok := value.(model.Vector)
+		if !ok {
+			return fmt.Errorf("unexpected value type %s", value.Type())
+		}
+
+		for _, objective := range objectives {
+			label := objectiveIssueLabel(objective)
+			alerts := make(model.Vector, 0, len(vector))
+			for _, sample := range vector {
+				if severities[label][string(sample.Metric["severity"])] {
+					alerts = append(alerts, sample)
+				}
+			}
+			if len(alertsMatchingObjectives(alerts, []slo.Objective{objective}, nil, false)) > 0 {
+				firing[label] = objective
+			}
+		}
+	}
+
+	issues, err := b.jira.openIssues(ctx, b.project)
+	if err != nil {
+		return fmt.Errorf("failed to search open issues: %w", err)
+	}
+
+	open := map[string]bool{}
+	for _, issue := range issues {
+		label := issueObjectiveLabel(issue)
+		if label == "" {
+			continue
+		}
+		open[label] = true
+
+		if _, ok := firing[label]; ok {
+			continue
+		}
+		if err := b.jira.transitionIssue(ctx, issue.Key, b.transition, "The burn rate alerts stopped firing."); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to resolve issue", "issue", issue.Key, "err", err)
+			continue
+		}
+		level.Info(b.logger).Log("msg", "resolved issue", "issue", issue.Key)
+	}
+
+	for label, objective := range firing {
+		if open[label] {
+			continue
+		}
+		key, err := b.jira.createIssue(ctx, b.issueFields(objective, label))
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to create issue", "objective", objective.Name(), "err", err)
+			continue
+		}
+		level.Info(b.logger).Log("msg", "created issue", "issue", key, "objective", objective.Name())
+	}
+
+	return nil
+}
+
+// ticketSeverities returns the severities of the objective's burn rate alerts that open issues,
+// the bridge's severities its alerting windows use, or the severity of its slowest burning window if they use none of them.
+func (b *jiraBridge) ticketSeverities(objective slo.Objective) map[string]bool {
+	windows := objective.Windows()
+	severities := map[string]bool{}
+	for _, w := range windows {
+		if slices.Contains(b.severities, string(w.Severity)) {
+			severities[string(w.Severity)] = true
+		}
+	}
+	if len(severities) > 0 || len(windows) == 0 {
+		return severities
+	}
+
+	slowest := windows[0]
+	for _, w := range windows[1:] {
+		if w.Factor < slowest.Factor {
+			slowest = w
+		}
+	}
+	severities[string(slowest.Severity)] = true
+	return severities
+}
+
+// firingAlertsQuery returns the query of the firing burn rate alerts of the severities.
+func firingAlertsQuery(severities map[string]bool) string {
+	values := make([]string, 0, len(severities))
+	for s := range severities {
+		values = append(values, s)
+	}
+	if len(values) == 1 {
+		return fmt.Sprintf(`ALERTS{slo=~".+",alertstate="firing",severity=%q}`, values[0])
+	}
+
+	sort.Strings(values)
+	for i, v := range values {
+		values[i] = regexp.QuoteMeta(v)
+	}
+	return fmt.Sprintf(`ALERTS{slo=~".+",alertstate="firing",severity=~%q}`, strings.Join(values, "|"))
+}
+
+func (b *jiraBridge) issueFields(objective slo.Objective, label string) map[string]any {
+	name := objective.Name()
+	if ns := objective.Labels.Get("namespace"); ns != "" {
+		name = ns + "/" + name
+	}
+
+	var description strings.Builder
+	fmt.Fprintf(&description, "The error budget of %s is burning too fast.\n\n", name)
+	if objective.Description != "" {
+		fmt.Fprintf(&description, "%s\n\n", objective.Description)
+	}
+	fmt.Fprintf(&description, "*Target:* %.3f%%\n", 100*objective.Target)
+	fmt.Fprintf(&description, "*Window:* %s\n", objective.Window)
+
+	lset := objective.Labels.Map()
+	keys := make([]string, 0, len(lset))
+	for k := range lset {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&description, "*%s:* %s\n", k, lset[k])
+	}
+
+	if b.pyrraURL != "" {
+		fmt.Fprintf(&description, "\n[Open in Pyrra|%s]\n", objectivePageURL(b.pyrraURL, objective))
+	}
+
+	return map[string]any{
+		"project":     map[string]string{"key": b.project},
+		"issuetype":   map[string]string{"name": b.issueType},
+		"summary":     fmt.Sprintf("Error budget of %s is burning too fast", name),
+		"description": description.String(),
+		"labels":      []string{jiraLabel, label},
+	}
+}
+
+// objectiveIssueLabel returns the Jira label identifying issues of the objective.
+// Jira labels cannot contain spaces, so the objective's labels are hashed.
+func objectiveIssueLabel(objective slo.Objective) string {
+	return jiraLabel + "-" + objectiveHash(objective)
+}
+
+// objectiveHash returns a short stable identifier of the objective's labels
+// for external systems that cannot store the labels themselves.
+func objectiveHash(objective slo.Objective) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(labels.New(objective.Labels...).String()))
+	return fmt.Sprintf("%x", h.Sum64())
+}
+
+func issueObjectiveLabel(issue jiraIssue) string {
+	for _, l := range issue.Fields.Labels {
+		if strings.HasPrefix(l, jiraLabel+"-") {
+			return l
+		}
+	}
+	return ""
+}
+
+// objectivePageURL returns the URL of the objective's page in the UI.
+func objectivePageURL(base string, objective slo.Objective) string {
+	return fmt.Sprintf("%s/objectives?expr=%s", strings.TrimSuffix(base, "/"), url.QueryEscape(labels.New(objective.Labels...).String()))
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+func TestJiraBridge_Sync(t *testing.T) {
+	objective := func(name string) slo.Objective {
+		o := reportObjective(name, "")
+		o.Labels = labels.FromStrings(labels.MetricName, name, "namespace", "monitoring")
+		return o
+	}
+	var (
+		firingNew      = objective("firing-new")
+		firingExisting = objective("firing-existing")
+		recovered      = objective("recovered")
+		// custom only alerts with severities of its own, its slowest burning window opens issues.
+		custom = objective("custom")
+	)
+	// The API carries the custom alerting windows in the objective's config.
+	custom.Config = `
+spec:
+  alerting:
+    windows:
+      - {severity: page, short: 5m, long: 1h, factor: "14"}
+      - {severity: ticket, short: 6h, long: 3d, factor: "1"}
+`
+	customTicket := slo.Window{Severity: "ticket", Short: 6 * time.Hour, Long: 3 * 24 * time.Hour}
+
+	var (
+		mu          sync.Mutex
+		created     []map[string]any
+		transitions = map[string]string{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		user, pass, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "pyrra@example.com", user)
+		require.Equal(t, "secret", pass)
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/search":
+			var req map[string]any
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			require.Equal(t, `project = "SRE" AND labels = "pyrra" AND statusCategory != Done`, req["jql"])
+			_ = json.NewEncoder(w).Encode(map[string]any{"issues": []any{
+				map[string]any{"key": "SRE-1", "fields": map[string]any{"labels": []string{"pyrra", objectiveIssueLabel(firingExisting)}}},
+				map[string]any{"key": "SRE-2", "fields": map[string]any{"labels": []string{"pyrra", objectiveIssueLabel(recovered)}}},
+				map[string]any{"key": "SRE-3", "fields": map[string]any{"labels": []string{"pyrra"}}},
+			}})
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue":
+			var req struct {
+				Fields map[string]any `json:"fields"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			created = append(created, req.Fields)
+			_ = json.NewEncoder(w).Encode(map[string]string{"key": "SRE-4"})
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/api/2/issue/SRE-2/transitions":
+			_ = json.NewEncoder(w).Encode(map[string]any{"transitions": []any{
+				map[string]string{"id": "11", "name": "In Progress"},
+				map[string]string{"id": "31", "name": "Done"},
+			}})
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue/SRE-2/transitions":
+			var req struct {
+				Transition struct {
+					ID string `json:"id"`
+				} `json:"transition"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			transitions["SRE-2"] = req.Transition.ID
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	var ticket slo.Window
+	for _, w := range firingNew.Windows() {
+		if w.Severity == "warning" {
+			ticket = w
+		}
+	}
+	alert := func(name string, w slo.Window) *model.Sample {
+		return &model.Sample{Metric: model.Metric{
+			model.MetricNameLabel: "ALERTS",
+			"alertname":           "ErrorBudgetBurn",
+			"alertstate":          "firing",
+			"severity":            model.LabelValue(w.Severity),
+			"slo":                 model.LabelValue(name),
+			"namespace":           "monitoring",
+			"short":               model.LabelValue(model.Duration(w.Short).String()),
+			"long":                model.LabelValue(model.Duration(w.Long).String()),
+			"handler":             "/api",
+		}, Value: 1}
+	}
+
+	bridge := &jiraBridge{
+		logger: log.NewNopLogger(),
+		client: staticBackend{objectives: []slo.Objective{firingNew, firingExisting, recovered, custom}},
+		promAPI: queryFunc(func(query string) model.Value {
+			require.Equal(t, `ALERTS{slo=~".+",alertstate="firing",severity=~"ticket|warning"}`, query)
+			return model.Vector{
+				alert("firing-new", ticket),
+				alert("firing-existing", ticket),
+				alert("custom", customTicket),
+				// Alerts of other severities with the same windows don't open issues.
+				alert("recovered", slo.Window{Severity: "ticket", Short: ticket.Short, Long: ticket.Long}),
+			}
+		}),
+		jira: &jiraClient{
+			url:      server.URL,
+			username: "pyrra@example.com",
+			token:    "secret",
+			client:   server.Client(),
+		},
+		project:    "SRE",
+		issueType:  "Bug",
+		transition: "done",
+		pyrraURL:   "https://pyrra.example.com/",
+		severities: []string{"warning"},
+	}
+
+	require.NoError(t, bridge.sync(context.Background()))
+
+	require.Len(t, created, 2)
+	sort.Slice(created, func(i, j int) bool { return created[i]["summary"].(string) > created[j]["summary"].(string) })
+	require.Equal(t, "Error budget of monitoring/custom is burning too fast", created[1]["summary"])
+	require.Equal(t, map[string]any{"key": "SRE"}, created[0]["project"])
+	require.Equal(t, map[string]any{"name": "Bug"}, created[0]["issuetype"])
+	require.Equal(t, "Error budget of monitoring/firing-new is burning too fast", created[0]["summary"])
+	require.Equal(t, []any{"pyrra", objectiveIssueLabel(firingNew)}, created[0]["labels"])
+	require.Contains(t, created[0]["description"], "*Target:* 99.000%")
+	require.Contains(t, created[0]["description"], `[Open in Pyrra|https://pyrra.example.com/objectives?expr=%7B__name__%3D%22firing-new%22%2C+namespace%3D%22monitoring%22%7D]`)
+
+	require.Equal(t, map[string]string{"SRE-2": "31"}, transitions)
+}
+
+func TestJiraClient_TransitionMissing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		_ = json.NewEncoder(w).Encode(map[string]any{"transitions": []any{}})
+	}))
+	defer server.Close()
+
+	c := &jiraClient{url: server.URL, token: "token", client: server.Client()}
+	err := c.transitionIssue(context.Background(), "SRE-1", "Done", "")
+	require.EqualError(t, err, `transition "Done" not available for issue SRE-1`)
+}
+
+func TestObjectiveIssueLabel(t *testing.T) {
+	a := slo.Objective{Labels: labels.FromStrings(labels.MetricName, "foo", "namespace", "bar")}
+	b := slo.Objective{Labels: labels.FromStrings(labels.MetricName, "foo", "namespace", "baz")}
+
+	require.Regexp(t, `^pyrra-[0-9a-f]+$`, objectiveIssueLabel(a))
+	require.Equal(t, objectiveIssueLabel(a), objectiveIssueLabel(a))
+	require.NotEqual(t, objectiveIssueLabel(a), objectiveIssueLabel(b))
+	require.Equal(t, "", issueObjectiveLabel(jiraIssue{}))
+}
+
+func TestJiraConfig_Validate(t *testing.T) {
+	require.NoError(t, (&JiraConfig{}).Validate())
+
+	u, err := url.Parse("https://example.atlassian.net")
+	require.NoError(t, err)
+
+	jc := &JiraConfig{JiraURL: u, JiraInterval: time.Minute, JiraSeverities: []string{"warning"}}
+	require.EqualError(t, jc.Validate(), "--jira-url requires --jira-project")
+
+	jc.JiraProject = "SRE"
+	require.NoError(t, jc.Validate())
+
+	jc.JiraSeverities = nil
+	require.EqualError(t, jc.Validate(), "--jira-severities must not be empty")
+}
@@ -39,6 +39,12 @@
     crd: (
       import '../controller-gen/pyrra.dev_servicelevelobjectives.json'
     ),
+    revisionCrd: (
+      import '../controller-gen/pyrra.dev_servicelevelobjectiverevisions.json'
+    ),
+    templateCrd: (
+      import '../controller-gen/pyrra.dev_servicelevelobjectivetemplates.json'
+    ),
 
 
     _apiMetadata:: {
@@ -157,12 +163,24 @@
       metadata: pyrra._kubernetesMetadata,
       rules: [{
         apiGroups: ['monitoring.coreos.com'],
-        resources: ['prometheusrules'],
+        resources: ['alertmanagerconfigs', 'prometheusrules'],
         verbs: ['create', 'delete', 'get', 'list', 'patch', 'update', 'watch'],
       }, {
         apiGroups: ['monitoring.coreos.com'],
         resources: ['prometheusrules/status'],
         verbs: ['get'],
+      }, {
+        apiGroups: ['monitoring.coreos.com'],
+        resources: ['probes', 'prometheuses'],
+        verbs: ['get', 'list', 'watch'],
+      }, {
+        apiGroups: ['grafana.integreatly.org'],
+        resources: ['grafanaalertrulegroups', 'grafanadashboards', 'grafanafolders'],
+        verbs: ['create', 'delete', 'get', 'list', 'patch', 'update', 'watch'],
+      }, {
+        apiGroups: ['grafana.integreatly.org'],
+        resources: ['grafanadatasources'],
+        verbs: ['get', 'list', 'watch'],
       }, {
         apiGroups: ['pyrra.dev'],
         resources: ['servicelevelobjectives'],
@@ -171,6 +189,34 @@
         apiGroups: ['pyrra.dev'],
         resources: ['servicelevelobjectives/status'],
         verbs: ['get', 'patch', 'update'],
+      }, {
+        apiGroups: ['pyrra.dev'],
+        resources: ['servicelevelobjectiverevisions'],
+        verbs: ['create', 'delete', 'get', 'list', 'watch'],
+      }, {
+        apiGroups: ['pyrra.dev'],
+        resources: ['servicelevelobjectivetemplates'],
+        verbs: ['get', 'list', 'watch'],
+      }, {
+        apiGroups: ['pyrra.dev'],
+        resources: ['servicelevelobjectivetemplates/status'],
+        verbs: ['get', 'patch', 'update'],
+      }, {
+        apiGroups: [''],
+        resources: ['events'],
+        verbs: ['create', 'patch'],
+      }, {
+        apiGroups: [''],
+        resources: ['namespaces', 'services'],
+        verbs: ['get', 'list', 'watch'],
+      }, {
+        apiGroups: ['batch'],
+        resources: ['jobs'],
+        verbs: ['create', 'get', 'list', 'watch'],
+      }, {
+        apiGroups: ['coordination.k8s.io'],
+        resources: ['leases'],
+        verbs: ['create', 'get', 'update'],
       }],
     },
 
@@ -18,31 +18,55 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
 	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/bufbuild/connect-go"
 	"github.com/go-kit/log"
 	"github.com/oklog/run"
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	monitoringv1alpha1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1alpha1"
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/promql/parser"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
-	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/yaml"
 
 	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+	pyrrav1beta1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1beta1"
 	"github.com/pyrra-dev/pyrra/kubernetes/controllers"
 	objectivesv1alpha1 "github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1"
 	"github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1/objectivesv1alpha1connect"
@@ -51,32 +75,972 @@ import (
 
 var scheme = runtime.NewScheme()
 
+type CacheConfig struct {
+	CacheLabelSelector string        `name:"cache-label-selector" default:"" help:"Only watch PrometheusRules and ConfigMaps matching the label selector, like team=platform, to reduce memory in large clusters. It has to match the objects Pyrra generates, which get the labels of their objectives."`
+	CacheFieldSelector string        `name:"cache-field-selector" default:"" help:"Only watch PrometheusRules and ConfigMaps matching the field selector, like metadata.namespace!=kube-system."`
+	SyncPeriod         time.Duration `name:"sync-period" default:"10h" help:"How often all objectives are reconciled again even without changes."`
+	Namespaces         []string      `help:"Only reconcile objectives in these namespaces and only watch objects in them, instead of the whole cluster."`
+	SLOLabelSelector   string        `name:"slo-label-selector" default:"" help:"Only reconcile objectives matching the label selector, like team=platform. Operators with disjoint selectors can run side by side, each reconciling its own objectives."`
+}
+
+// Validate checks the sync period is positive and the cache options can be built from the selectors.
+func (cc *CacheConfig) Validate() error {
+	if cc.SyncPeriod <= 0 {
+		return fmt.Errorf("--sync-period must be greater than 0")
+	}
+	_, err := cc.options()
+	return err
+}
+
+type ReconcileConfig struct {
+	ReconcileDebounce time.Duration `default:"1s" help:"How long to wait after an objective changed before reconciling it, to coalesce bursts of updates like GitOps syncs into one reconcile."`
+	ResyncSpread      time.Duration `default:"1m" help:"Periodic resyncs of unchanged objectives are spread out randomly over this long, so they don't spike writes and are reconciled after changed objectives."`
+	ResyncInterval    time.Duration `default:"0" help:"Reconcile every objective again this long after it was written, plus a random part of --resync-spread, to revert manual changes of its PrometheusRules and ConfigMaps. Only the --sync-period resyncs all objectives if 0."`
+
+	KubeAPIQPS   float32 `name:"kube-api-qps" default:"20" help:"The maximum queries per second of the client to the Kubernetes API server."`
+	KubeAPIBurst int     `name:"kube-api-burst" default:"30" help:"The maximum burst of queries of the client to the Kubernetes API server."`
+
+	WorkqueueBaseDelay time.Duration `default:"5ms" help:"The delay before retrying a failed reconcile for the first time, doubling with every failure."`
+	WorkqueueMaxDelay  time.Duration `default:"1000s" help:"The maximum delay before retrying a failed reconcile."`
+	WorkqueueQPS       float64       `name:"workqueue-qps" default:"10" help:"The maximum retries of failed reconciles per second, across all objectives."`
+	WorkqueueBurst     int           `default:"100" help:"The maximum burst of retries of failed reconciles, across all objectives."`
+
+	MaxConcurrentReconciles int `default:"1" help:"How many objectives are reconciled in parallel. Each objective is only ever reconciled once at a time."`
+
+	DetectDrift bool `help:"Emit events on objectives and count pyrra_generated_object_drift_total as their generated PrometheusRules and ConfigMaps are found changed by others, before reverting them."`
+
+	ConfigMapGCInterval time.Duration `name:"configmap-gc-interval" default:"10m" help:"How often ConfigMaps with rules of objectives that no longer exist are deleted, in ConfigMap and Thanos Ruler mode. They aren't deleted if 0."`
+}
+
+// Validate checks the reconcile delays, rate limits and concurrency are in range.
+func (rc *ReconcileConfig) Validate() error {
+	if rc.ReconcileDebounce < 0 {
+		return fmt.Errorf("--reconcile-debounce must not be negative")
+	}
+	if rc.ResyncSpread < 0 {
+		return fmt.Errorf("--resync-spread must not be negative")
+	}
+	if rc.ResyncInterval < 0 {
+		return fmt.Errorf("--resync-interval must not be negative")
+	}
+	if rc.KubeAPIQPS <= 0 || rc.KubeAPIBurst <= 0 {
+		return fmt.Errorf("--kube-api-qps and --kube-api-burst must be greater than 0")
+	}
+	if rc.WorkqueueBaseDelay <= 0 || rc.WorkqueueMaxDelay < rc.WorkqueueBaseDelay {
+		return fmt.Errorf("--workqueue-base-delay must be greater than 0 and not greater than --workqueue-max-delay")
+	}
+	if rc.WorkqueueQPS <= 0 || rc.WorkqueueBurst <= 0 {
+		return fmt.Errorf("--workqueue-qps and --workqueue-burst must be greater than 0")
+	}
+	if rc.ConfigMapGCInterval < 0 {
+		return fmt.Errorf("--configmap-gc-interval must not be negative")
+	}
+	if rc.MaxConcurrentReconciles <= 0 {
+		return fmt.Errorf("--max-concurrent-reconciles must be greater than 0")
+	}
+	return nil
+}
+
+type LeaderElectionConfig struct {
+	EnableLeaderElection    bool   `default:"false" help:"Elect a leader among the replicas of the operator through a Lease. Only the leader reconciles objectives, while all replicas serve the API and webhooks."`
+	LeaderElectionNamespace string `default:"" help:"The namespace of the Lease for leader election. Defaults to the namespace Pyrra runs in."`
+	LeaderElectionName      string `default:"9d76195a.pyrra.dev" help:"The name of the Lease for leader election. Operators sharing the Lease elect one leader among them."`
+}
+
+// Validate checks leader election has a Lease name.
+func (lc *LeaderElectionConfig) Validate() error {
+	if lc.EnableLeaderElection && lc.LeaderElectionName == "" {
+		return fmt.Errorf("--enable-leader-election requires --leader-election-name")
+	}
+	return nil
+}
+
+type PolicyConfig struct {
+	PolicyInterval              time.Duration `default:"1m" help:"How often the error budget policies of objectives are evaluated against Prometheus."`
+	AlertmanagerURL             *url.URL      `help:"The URL to the Alertmanager the alerts of error budget policy thresholds with silence are silenced in while they're active. Requires --prometheus-url."`
+	AlertmanagerSilenceDuration time.Duration `default:"15m" help:"How long the silences of error budget policies last unless the next evaluation extends them, so they end if the operator stops. Must be longer than --policy-interval."`
+}
+
+// Validate checks the policy interval is positive and silences outlast it.
+func (pc *PolicyConfig) Validate() error {
+	if pc.PolicyInterval <= 0 {
+		return fmt.Errorf("--policy-interval must be greater than 0")
+	}
+	if pc.AlertmanagerURL != nil && pc.AlertmanagerSilenceDuration <= pc.PolicyInterval {
+		return fmt.Errorf("--alertmanager-silence-duration must be longer than --policy-interval")
+	}
+	return nil
+}
+
+// silencer returns the silencer of the error budget policies, nil without --alertmanager-url.
+func (pc PolicyConfig) silencer() *controllers.AlertmanagerSilencer {
+	if pc.AlertmanagerURL == nil {
+		return nil
+	}
+	return &controllers.AlertmanagerSilencer{
+		URL:      pc.AlertmanagerURL,
+		Client:   &http.Client{Timeout: 30 * time.Second, Transport: otelhttp.NewTransport(http.DefaultTransport)},
+		Duration: pc.AlertmanagerSilenceDuration,
+	}
+}
+
+type GrafanaConfig struct {
+	GrafanaAlertRules       bool              `default:"false" help:"Write the alerts of objectives to GrafanaAlertRuleGroups of the grafana-operator, for Grafana to evaluate them instead of Prometheus. The recording rules stay in Prometheus."`
+	GrafanaDashboards       bool              `default:"false" help:"Create a GrafanaDashboard of the grafana-operator with the availability, error budget and burn rates of each objective."`
+	GrafanaDatasourceUID    string            `name:"grafana-datasource-uid" default:"" help:"The UID of the Prometheus datasource in Grafana the alerts and dashboards query. Objectives can override it for their alerts with the pyrra.dev/grafana-datasource-uid annotation."`
+	GrafanaDatasourceType   string            `name:"grafana-datasource-type" default:"prometheus" help:"The type of the datasource in Grafana the alerts query."`
+	GrafanaFolder           string            `default:"" help:"The name of the GrafanaFolder in each objective's namespace the alert rules and dashboards are created in. It's a template rendered with the objective's .Namespace and .Name, like slos-{{.Namespace}}."`
+	GrafanaCreateFolders    bool              `default:"false" help:"Create the GrafanaFolders of --grafana-folder in the namespaces of the objectives, titled after their name. A folder is deleted once all its objectives are."`
+	GrafanaInstanceSelector map[string]string `default:"" help:"The labels of the Grafana resources to create the alert rules, dashboards and folders in, like dashboards=grafana."`
+}
+
+// Validate checks the Grafana outputs have a datasource, folder and instance selector, and the folder template renders.
+func (gc *GrafanaConfig) Validate() error {
+	var flag string
+	switch {
+	case gc.GrafanaAlertRules:
+		flag = "--grafana-alert-rules"
+	case gc.GrafanaDashboards:
+		flag = "--grafana-dashboards"
+	default:
+		return nil
+	}
+	if gc.GrafanaDatasourceUID == "" || gc.GrafanaFolder == "" {
+		return fmt.Errorf("%s requires --grafana-datasource-uid and --grafana-folder", flag)
+	}
+	if len(gc.GrafanaInstanceSelector) == 0 {
+		return fmt.Errorf("%s requires --grafana-instance-selector", flag)
+	}
+	if gc.GrafanaAlertRules && gc.GrafanaDatasourceType == "" {
+		return fmt.Errorf("--grafana-datasource-type must not be empty")
+	}
+	folders, err := gc.folders()
+	if err != nil || folders == nil {
+		return err
+	}
+	if _, err := folders.Name(pyrrav1alpha1.ServiceLevelObjective{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "http-errors"},
+	}); err != nil {
+		return fmt.Errorf("invalid --grafana-folder: %w", err)
+	}
+	return nil
+}
+
+// folders returns the GrafanaFolders of the alert rules and dashboards,
+// or nil if --grafana-folder names the same existing folder for all objectives.
+func (gc GrafanaConfig) folders() (*controllers.GrafanaFolders, error) {
+	if !gc.GrafanaCreateFolders && !strings.Contains(gc.GrafanaFolder, "{{") {
+		return nil, nil
+	}
+	tmpl, err := template.New("folder").Option("missingkey=error").Parse(gc.GrafanaFolder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse --grafana-folder: %w", err)
+	}
+	return &controllers.GrafanaFolders{
+		NameTemplate:     tmpl,
+		Create:           gc.GrafanaCreateFolders,
+		InstanceSelector: gc.GrafanaInstanceSelector,
+	}, nil
+}
+
+type LokiRulerGroupConfig struct {
+	LokiRulerNamespaceTemplate string        `default:"" help:"The template of the Loki ruler namespace the rule groups of objectives are in, like {{.Namespace}}/{{.Name}}, rendered with the objective's .Namespace and .Name. Defaults to the objective's namespace. Rule groups aren't moved out of the previous ruler namespaces as it changes."`
+	LokiRulerIncreaseInterval  time.Duration `default:"0" help:"The evaluation interval of the objectives' increase rule groups in the Loki ruler. Defaults to the interval depending on the objective's window."`
+	LokiRulerBurnRateInterval  time.Duration `default:"0" help:"The evaluation interval of the objectives' burn rate rule groups in the Loki ruler. Defaults to 30s."`
+	LokiRulerGenericInterval   time.Duration `default:"0" help:"The evaluation interval of the objectives' generic rule groups in the Loki ruler. Defaults to 30s."`
+	LokiRulerSyncInterval      time.Duration `default:"10m" help:"How often the rule groups in the Loki ruler are compared with the objectives', with one request per ruler namespace, to push rule groups that drifted and delete the ones of objectives that no longer exist. They aren't synced if 0."`
+}
+
+// Validate checks the rule group intervals aren't negative and the ruler namespace template renders a namespace.
+func (lc *LokiRulerGroupConfig) Validate() error {
+	if lc.LokiRulerIncreaseInterval < 0 || lc.LokiRulerBurnRateInterval < 0 || lc.LokiRulerGenericInterval < 0 {
+		return fmt.Errorf("the loki ruler rule group intervals must not be negative")
+	}
+	if lc.LokiRulerSyncInterval < 0 {
+		return fmt.Errorf("--loki-ruler-sync-interval must not be negative")
+	}
+	tmpl, err := lc.namespaceTemplate()
+	if err != nil || tmpl == nil {
+		return err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, controllers.LokiNamespaceData{Namespace: "monitoring", Name: "http-errors"}); err != nil {
+		return fmt.Errorf("failed to render --loki-ruler-namespace-template: %w", err)
+	}
+	if sb.Len() == 0 {
+		return fmt.Errorf("--loki-ruler-namespace-template must not render an empty ruler namespace")
+	}
+	return nil
+}
+
+// namespaceTemplate returns the parsed template of the ruler namespace or nil if there is none.
+func (lc LokiRulerGroupConfig) namespaceTemplate() (*template.Template, error) {
+	if lc.LokiRulerNamespaceTemplate == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("namespace").Option("missingkey=error").Parse(lc.LokiRulerNamespaceTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse --loki-ruler-namespace-template: %w", err)
+	}
+	return tmpl, nil
+}
+
+type LokiRulerClientConfig struct {
+	LokiRulerTLSCertFile  string            `help:"File containing the client certificate to authenticate to the Loki rulers with mTLS. It is read again once it is rotated."`
+	LokiRulerTLSKeyFile   string            `help:"File containing the private key matching --loki-ruler-tls-cert-file."`
+	LokiRulerTLSCAFile    string            `help:"File containing the CA bundle to verify the certificates of the Loki rulers with, instead of the system's."`
+	LokiRulerHeaders      map[string]string `name:"loki-ruler-headers" help:"Headers sent with every request to the Loki rulers, like X-Scope-OrgID=tenant. They take precedence over the credentials Secret and the tenants of namespaces."`
+	LokiRulerConfigSecret string            `help:"The namespace/name of a Secret with the url of the Loki ruler, and its tenant, token, or username and password. It's used instead of --loki-ruler-url while it exists and is read again as it changes, without restarting the operator. The credentials Secret of an objective's namespace takes precedence over its credentials."`
+}
+
+// Validate checks the client certificate comes with its key and the config Secret is namespace/name.
+func (lc *LokiRulerClientConfig) Validate() error {
+	if (lc.LokiRulerTLSCertFile == "") != (lc.LokiRulerTLSKeyFile == "") {
+		return fmt.Errorf("--loki-ruler-tls-cert-file and --loki-ruler-tls-key-file must be set together")
+	}
+	if lc.LokiRulerConfigSecret != "" && lc.configSecret().Name == "" {
+		return fmt.Errorf("--loki-ruler-config-secret must be namespace/name, got %q", lc.LokiRulerConfigSecret)
+	}
+	return nil
+}
+
+// configSecret returns the namespace and name of the --loki-ruler-config-secret, an empty name if it's invalid.
+func (lc LokiRulerClientConfig) configSecret() types.NamespacedName {
+	return parseNamespacedName(lc.LokiRulerConfigSecret)
+}
+
+// parseNamespacedName returns the namespace and name of the namespace/name, an empty name if it's invalid.
+func parseNamespacedName(s string) types.NamespacedName {
+	namespace, name, ok := strings.Cut(s, "/")
+	if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return types.NamespacedName{}
+	}
+	return types.NamespacedName{Namespace: namespace, Name: name}
+}
+
+// client returns the HTTP client for the Loki rulers, authenticating with the client certificate if any.
+func (lc LokiRulerClientConfig) client() (*http.Client, error) {
+	if lc.LokiRulerTLSCertFile == "" && lc.LokiRulerTLSCAFile == "" {
+		return &http.Client{Timeout: 30 * time.Second, Transport: otelhttp.NewTransport(http.DefaultTransport)}, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if lc.LokiRulerTLSCAFile != "" {
+		ca, err := os.ReadFile(lc.LokiRulerTLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read --loki-ruler-tls-ca-file: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("--loki-ruler-tls-ca-file has no PEM encoded certificates")
+		}
+	}
+	if lc.LokiRulerTLSCertFile != "" {
+		reloader := &certificateReloader{certFile: lc.LokiRulerTLSCertFile, keyFile: lc.LokiRulerTLSKeyFile}
+		// Fail at startup rather than on the first request if the certificate can't be loaded.
+		if _, err := reloader.GetClientCertificate(nil); err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = reloader.GetClientCertificate
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Timeout: 30 * time.Second, Transport: otelhttp.NewTransport(transport)}, nil
+}
+
+// certificateReloader loads the client certificate again once its files changed,
+// so rotated certificates, like the ones issued by cert-manager, are used without restarting.
+type certificateReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	modTime time.Time
+	cert    *tls.Certificate
+}
+
+func (r *certificateReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	var modTime time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client certificate: %w", err)
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cert != nil && modTime.Equal(r.modTime) {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+	r.cert, r.modTime = &cert, modTime
+	return r.cert, nil
+}
+
+type ThanosRulerConfig struct {
+	ThanosRuler                 bool              `default:"false" help:"Write the rules of objectives to ConfigMaps for Thanos Ruler instead of PrometheusRules. The rules of an objective are split into as many ConfigMaps as needed."`
+	ThanosRulerConfigMapLabels  map[string]string `name:"thanos-ruler-configmap-labels" default:"thanos_rule=true" help:"The labels of the ConfigMaps for Thanos Ruler to discover them, like with a sidecar loading them into the ruler."`
+	ThanosRulerMaxConfigMapSize int               `name:"thanos-ruler-max-configmap-size" default:"1048576" help:"The most bytes of rules written to a single ConfigMap for Thanos Ruler."`
+	ThanosRulerURL              *url.URL          `help:"The URL of the Thanos Ruler to reload with POST /-/reload as the ConfigMaps of objectives change. It isn't reloaded if empty."`
+}
+
+// Validate checks Thanos Ruler mode has ConfigMap labels and a ConfigMap size Kubernetes accepts.
+func (tc *ThanosRulerConfig) Validate() error {
+	if !tc.ThanosRuler {
+		return nil
+	}
+	if len(tc.ThanosRulerConfigMapLabels) == 0 {
+		return fmt.Errorf("--thanos-ruler requires --thanos-ruler-configmap-labels")
+	}
+	if tc.ThanosRulerMaxConfigMapSize <= 0 || tc.ThanosRulerMaxConfigMapSize > 1<<20 {
+		return fmt.Errorf("--thanos-ruler-max-configmap-size must be between 1 and 1048576 bytes, got %d", tc.ThanosRulerMaxConfigMapSize)
+	}
+	return nil
+}
+
+type OutputConfig struct {
+	RuleOutputs     []string          `help:"Where the rules of objectives evaluated by Prometheus are written to, any of prometheusrule, configmap and thanos-ruler, like prometheusrule,configmap to write them to both. Defaults to thanos-ruler with --thanos-ruler, configmap with --config-map-mode and prometheusrule otherwise."`
+	ExternalLabels  map[string]string `name:"external-label" default:"" help:"Labels added to all recording and alerting rules of all objectives, like cluster=eu1;region=eu, so the series of several clusters don't collide when aggregated in Thanos or Mimir. The externalLabels of objectives take precedence."`
+	ConfigMapShards int               `name:"config-map-shards" default:"0" help:"Merge the rule files of objectives written to ConfigMaps into this many ConfigMaps per namespace, picked by the hash of the objective's name, instead of one ConfigMap per objective. Their pyrra.dev/objectives annotation lists the objectives in them. It can't be used with --cache-label-selector, as they don't have the labels of objectives."`
+}
+
+// Validate checks the rule outputs are known and unique, and the external labels are valid.
+func (oc *OutputConfig) Validate() error {
+	seen := map[string]bool{}
+	for _, output := range oc.RuleOutputs {
+		switch output {
+		case controllers.RuleOutputPrometheusRule, controllers.RuleOutputConfigMap, controllers.RuleOutputThanosRuler:
+		default:
+			return fmt.Errorf("unknown --rule-outputs %q, must be any of prometheusrule, configmap and thanos-ruler", output)
+		}
+		if seen[output] {
+			return fmt.Errorf("--rule-outputs has %s more than once", output)
+		}
+		seen[output] = true
+	}
+	if oc.ConfigMapShards < 0 {
+		return fmt.Errorf("--config-map-shards must not be negative, got %d", oc.ConfigMapShards)
+	}
+	return pyrrav1alpha1.ValidateExternalLabels("--external-label", oc.ExternalLabels)
+}
+
+type CardinalityConfig struct {
+	GroupingMaxSeries     int  `default:"0" help:"Estimate from the Prometheus of --prometheus-url how many series each recording rule of objectives with grouping results in before writing their rules, and warn about the ones above this many with the HighCardinality condition and an event. Series aren't estimated if 0."`
+	GroupingEnforceSeries bool `default:"false" help:"Refuse to write the rules of objectives above --grouping-max-series instead of only warning about them."`
+}
+
+// Validate checks the series limit isn't negative and is set if it's enforced.
+func (cc *CardinalityConfig) Validate() error {
+	if cc.GroupingMaxSeries < 0 {
+		return fmt.Errorf("--grouping-max-series must not be negative, got %d", cc.GroupingMaxSeries)
+	}
+	if cc.GroupingEnforceSeries && cc.GroupingMaxSeries == 0 {
+		return fmt.Errorf("--grouping-enforce-series requires --grouping-max-series")
+	}
+	return nil
+}
+
+// cardinality returns the estimation of the series of objectives with grouping, nil without --grouping-max-series.
+func (cc CardinalityConfig) cardinality(querier controllers.BudgetPolicyQuerier) *controllers.Cardinality {
+	if cc.GroupingMaxSeries == 0 {
+		return nil
+	}
+	return &controllers.Cardinality{
+		Querier:   querier,
+		MaxSeries: cc.GroupingMaxSeries,
+		Enforce:   cc.GroupingEnforceSeries,
+	}
+}
+
+type RemoteClusterConfig struct {
+	RemoteCluster map[string]string `name:"remote-cluster" default:"" help:"Remote clusters the PrometheusRules of objectives are written to in addition to this one, by name to the namespace/name of a Secret with their kubeconfig in its kubeconfig key, like eu1=pyrra/eu1-kubeconfig. The pyrra.dev/clusters annotation of objectives selects the clusters they're written to, all of them without it. The Secrets are read again as they change."`
+}
+
+// Validate checks the remote cluster names are DNS labels and their Secrets namespace/name.
+func (rc *RemoteClusterConfig) Validate() error {
+	for name, secret := range rc.RemoteCluster {
+		// The names are part of the condition types of objectives.
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			return fmt.Errorf("invalid --remote-cluster name %q: %s", name, strings.Join(errs, ", "))
+		}
+		if parseNamespacedName(secret).Name == "" {
+			return fmt.Errorf("--remote-cluster %s must be a Secret's namespace/name, got %q", name, secret)
+		}
+	}
+	return nil
+}
+
+// remoteClusters returns the remote clusters of the --remote-cluster, nil without any.
+func (rc RemoteClusterConfig) remoteClusters() controllers.RemoteClusters {
+	if len(rc.RemoteCluster) == 0 {
+		return nil
+	}
+	clusters := make(controllers.RemoteClusters, len(rc.RemoteCluster))
+	for name, secret := range rc.RemoteCluster {
+		clusters[name] = &controllers.RemoteCluster{Secret: parseNamespacedName(secret)}
+	}
+	return clusters
+}
+
+// secrets returns the kubeconfig Secrets of the remote clusters, sorted for the cache config to be stable.
+func (rc RemoteClusterConfig) secrets() []types.NamespacedName {
+	secrets := make([]types.NamespacedName, 0, len(rc.RemoteCluster))
+	for _, secret := range rc.RemoteCluster {
+		secrets = append(secrets, parseNamespacedName(secret))
+	}
+	slices.SortFunc(secrets, func(a, b types.NamespacedName) int { return strings.Compare(a.String(), b.String()) })
+	return secrets
+}
+
+type HealthConfig struct {
+	HealthProbeAddr             string        `default:":8081" help:"The address the healthz and readyz endpoints for the liveness and readiness probes of the operator bind to."`
+	HealthProbeInterval         time.Duration `default:"30s" help:"How often the connectivity to the backends, the Kubernetes API, the Loki rulers, the grafana-operator's resources, remote clusters and Prometheus, is probed. The operator isn't ready while the last probe of any backend failed. Rulers are probed with their own credentials, not the ones of objectives' namespaces. Backends aren't probed if 0."`
+	HealthProbeTimeout          time.Duration `default:"10s" help:"How long each probe of a backend may take before it fails."`
+	HealthProbeFailureThreshold int           `default:"0" help:"Fail the healthz endpoint once a backend failed this many probes in a row, for Kubernetes to restart the operator, like to read rotated credentials. Failing backends only make the operator unready if 0."`
+}
+
+// Validate checks the health probes' interval, timeout and failure threshold are consistent.
+func (hc *HealthConfig) Validate() error {
+	if hc.HealthProbeInterval < 0 {
+		return fmt.Errorf("--health-probe-interval must not be negative")
+	}
+	if hc.HealthProbeFailureThreshold < 0 {
+		return fmt.Errorf("--health-probe-failure-threshold must not be negative")
+	}
+	if hc.HealthProbeInterval == 0 {
+		if hc.HealthProbeFailureThreshold > 0 {
+			return fmt.Errorf("--health-probe-failure-threshold requires --health-probe-interval")
+		}
+		return nil
+	}
+	if hc.HealthProbeTimeout <= 0 {
+		return fmt.Errorf("--health-probe-timeout must be greater than 0")
+	}
+	return nil
+}
+
+type AlertAnnotationConfig struct {
+	AlertAnnotation      map[string]string `name:"alert-annotation" default:"" help:"Annotations added to the alerts of all objectives, like runbook_url=https://runbooks.example.com/{{.Namespace}}/{{.Name}}. They're Go templates rendered per objective with its .Namespace, .Name, .UID, .Labels, .Target and .Window. The annotations of objectives take precedence."`
+	AlertAnnotationsFile string            `help:"YAML file of annotation names to templates added to the alerts of all objectives like --alert-annotation, like a mounted ConfigMap. The ones of --alert-annotation take precedence. It's only read on startup."`
+}
+
+// Validate checks the alert annotations can be read and parsed as templates.
+func (ac *AlertAnnotationConfig) Validate() error {
+	_, err := ac.annotations()
+	return err
+}
+
+// annotations returns the parsed templates of the annotations of the --alert-annotations-file and --alert-annotation.
+func (ac AlertAnnotationConfig) annotations() (controllers.AlertAnnotations, error) {
+	templates := map[string]string{}
+	if ac.AlertAnnotationsFile != "" {
+		content, err := os.ReadFile(ac.AlertAnnotationsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read --alert-annotations-file: %w", err)
+		}
+		if err := yaml.UnmarshalStrict(content, &templates); err != nil {
+			return nil, fmt.Errorf("failed to parse --alert-annotations-file: %w", err)
+		}
+	}
+	for name, value := range ac.AlertAnnotation {
+		templates[name] = value
+	}
+	if len(templates) == 0 {
+		return nil, nil
+	}
+
+	annotations := make(controllers.AlertAnnotations, len(templates))
+	for name, value := range templates {
+		if !model.LabelName(name).IsValid() {
+			return nil, fmt.Errorf("alert annotation %q is not a valid annotation name", name)
+		}
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse alert annotation %s: %w", name, err)
+		}
+		annotations[name] = tmpl
+	}
+	return annotations, nil
+}
+
+// outputs returns the rule outputs, the ones of --config-map-mode and --thanos-ruler if none are set.
+func (oc OutputConfig) outputs(configMapMode, thanosRuler bool) []string {
+	switch {
+	case len(oc.RuleOutputs) > 0:
+		return oc.RuleOutputs
+	case thanosRuler:
+		return []string{controllers.RuleOutputThanosRuler}
+	case configMapMode:
+		return []string{controllers.RuleOutputConfigMap}
+	default:
+		return []string{controllers.RuleOutputPrometheusRule}
+	}
+}
+
+type RemoteWriteConfig struct {
+	RemoteWriteURL         *url.URL          `help:"The URL of a Prometheus remote-write endpoint, like Mimir's http://mimir/api/v1/push. If set the series of the objectives, like pyrra_slo_info and pyrra_slo_objective_target, are pushed to it, for when the operator itself isn't scraped."`
+	RemoteWriteHeaders     map[string]string `name:"remote-write-headers" help:"Headers sent with every remote-write request, like X-Scope-OrgID=tenant."`
+	RemoteWriteUsername    string            `help:"The username to authenticate to the remote-write endpoint with basic authentication."`
+	RemoteWritePassword    string            `env:"PYRRA_REMOTE_WRITE_PASSWORD" help:"The password to authenticate to the remote-write endpoint with basic authentication."`
+	RemoteWriteBearerToken string            `env:"PYRRA_REMOTE_WRITE_BEARER_TOKEN" help:"The bearer token to authenticate to the remote-write endpoint with."`
+	RemoteWriteInterval    time.Duration     `default:"1m" help:"How often the series of the objectives are pushed to the remote-write endpoint."`
+}
+
+// Validate checks the remote-write URL is an HTTP URL with an interval and at most one kind of credentials.
+func (rc *RemoteWriteConfig) Validate() error {
+	if rc.RemoteWriteURL == nil {
+		return nil
+	}
+	if rc.RemoteWriteURL.Scheme != "http" && rc.RemoteWriteURL.Scheme != "https" {
+		return fmt.Errorf("--remote-write-url must be an http or https URL")
+	}
+	if rc.RemoteWriteInterval <= 0 {
+		return fmt.Errorf("--remote-write-interval must be greater than 0")
+	}
+	if rc.RemoteWriteBearerToken != "" && rc.RemoteWriteUsername != "" {
+		return fmt.Errorf("--remote-write-bearer-token and --remote-write-username are mutually exclusive")
+	}
+	if rc.RemoteWritePassword != "" && rc.RemoteWriteUsername == "" {
+		return fmt.Errorf("--remote-write-password requires --remote-write-username")
+	}
+	return nil
+}
+
+type AlertmanagerConfig struct {
+	AlertmanagerInhibitRules bool              `default:"false" help:"Create an AlertmanagerConfig for each objective with alerting.inhibit, with the inhibition rule that mutes its slow burning alerts while the fast burning one fires. Requires the AlertmanagerConfig CRD of the prometheus-operator."`
+	AlertmanagerConfigLabels map[string]string `name:"alertmanager-config-labels" help:"Labels added to the AlertmanagerConfigs, for the alertmanagerConfigSelector of the Alertmanager to select them, like alertmanager=main."`
+}
+
+// Validate checks the AlertmanagerConfig labels are only set with the inhibit rules they label.
+func (ac *AlertmanagerConfig) Validate() error {
+	if len(ac.AlertmanagerConfigLabels) > 0 && !ac.AlertmanagerInhibitRules {
+		return fmt.Errorf("--alertmanager-config-labels requires --alertmanager-inhibit-rules")
+	}
+	return nil
+}
+
+type BackfillConfig struct {
+	BackfillPrometheusURL *url.URL      `help:"Backfill the recording rules of new objectives over their window with a Job running promtool tsdb create-blocks-from rules against this Prometheus with their raw metrics. Only the recording rules of raw metrics are backfilled. Requires --backfill-pvc or --backfill-mimir-url."`
+	BackfillImage         string        `default:"quay.io/prometheus/prometheus:v2.50.1" help:"The image with promtool the backfill Jobs run."`
+	BackfillPVC           string        `name:"backfill-pvc" help:"The PersistentVolumeClaim in the objective's namespace the backfill Jobs write the blocks to, like the storage of the Prometheus evaluating the rules, which loads them from its data directory."`
+	BackfillPVCSubPath    string        `name:"backfill-pvc-sub-path" help:"The directory in the --backfill-pvc the blocks are written to, like prometheus-db for the prometheus-operator."`
+	BackfillMimirURL      *url.URL      `help:"Upload the backfilled blocks to Mimir's backfill API at this URL with mimirtool instead."`
+	BackfillMimirTenant   string        `default:"anonymous" help:"The Mimir tenant the backfilled blocks are uploaded for."`
+	BackfillMimirImage    string        `default:"grafana/mimirtool:2.11.0" help:"The image with mimirtool the backfill Jobs run to upload the blocks."`
+	BackfillMaxAge        time.Duration `default:"1h" help:"How long after their creation objectives are backfilled, so the existing objectives aren't backfilled as the backfill is enabled. Objectives of any age are backfilled if 0."`
+}
+
+// Validate checks backfills have a Prometheus and exactly one of a PVC or Mimir to write the blocks to.
+func (bc *BackfillConfig) Validate() error {
+	if bc.BackfillPrometheusURL == nil {
+		if bc.BackfillPVC != "" || bc.BackfillMimirURL != nil {
+			return fmt.Errorf("--backfill-pvc and --backfill-mimir-url require --backfill-prometheus-url")
+		}
+		return nil
+	}
+	if (bc.BackfillPVC == "") == (bc.BackfillMimirURL == nil) {
+		return fmt.Errorf("--backfill-prometheus-url requires exactly one of --backfill-pvc or --backfill-mimir-url")
+	}
+	if bc.BackfillPVCSubPath != "" && bc.BackfillPVC == "" {
+		return fmt.Errorf("--backfill-pvc-sub-path requires --backfill-pvc")
+	}
+	if bc.BackfillMaxAge < 0 {
+		return fmt.Errorf("--backfill-max-age must not be negative")
+	}
+	return nil
+}
+
+// backfill returns the backfill of the reconciler, nil if it isn't enabled.
+func (bc BackfillConfig) backfill() *controllers.Backfill {
+	if bc.BackfillPrometheusURL == nil {
+		return nil
+	}
+	backfill := &controllers.Backfill{
+		PrometheusURL: bc.BackfillPrometheusURL.String(),
+		Image:         bc.BackfillImage,
+		MaxAge:        bc.BackfillMaxAge,
+	}
+	if bc.BackfillMimirURL != nil {
+		backfill.MimirURL = bc.BackfillMimirURL.String()
+		backfill.MimirTenant = bc.BackfillMimirTenant
+		backfill.MimirImage = bc.BackfillMimirImage
+	} else {
+		backfill.Volume = corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: bc.BackfillPVC}}
+		backfill.VolumeSubPath = bc.BackfillPVCSubPath
+	}
+	return backfill
+}
+
+type PrometheusSelectorConfig struct {
+	PrometheusResource              string        `help:"The namespace/name of the Prometheus of the prometheus-operator evaluating the PrometheusRules of objectives without a destination. Its ruleSelector and ruleNamespaceSelector are checked to select them at startup and periodically, with warning events and the pyrra_prometheus_rules_unselected metric for the ones they don't."`
+	PrometheusRuleSelectorLabels    bool          `default:"false" help:"Add the labels the ruleSelector of the --prometheus-resource requires to the PrometheusRules, the ones of its matchLabels and of its In expressions with a single value."`
+	PrometheusSelectorCheckInterval time.Duration `default:"5m" help:"How often the ruleSelector and ruleNamespaceSelector of the --prometheus-resource are checked to select the PrometheusRules."`
+}
+
+// Validate checks the Prometheus resource is namespace/name with a check interval.
+func (pc *PrometheusSelectorConfig) Validate() error {
+	if pc.PrometheusResource == "" {
+		if pc.PrometheusRuleSelectorLabels {
+			return fmt.Errorf("--prometheus-rule-selector-labels requires --prometheus-resource")
+		}
+		return nil
+	}
+	if pc.prometheus().Name == "" {
+		return fmt.Errorf("--prometheus-resource must be namespace/name, got %q", pc.PrometheusResource)
+	}
+	if pc.PrometheusSelectorCheckInterval <= 0 {
+		return fmt.Errorf("--prometheus-selector-check-interval must be greater than 0")
+	}
+	return nil
+}
+
+// prometheus returns the namespace and name of the --prometheus-resource, an empty name if it's invalid.
+func (pc PrometheusSelectorConfig) prometheus() types.NamespacedName {
+	return parseNamespacedName(pc.PrometheusResource)
+}
+
+type RuleMutatorConfig struct {
+	RuleMutator        []string      `help:"Executables post-processing the rule groups of objectives before they're written to PrometheusRules, ConfigMaps, Thanos Ruler, Loki rulers and Grafana, in order. Each gets the objective and its rule groups as JSON on stdin and prints the mutated rule groups to stdout. Arguments follow the path, separated by spaces."`
+	RuleMutatorTimeout time.Duration `default:"10s" help:"How long a --rule-mutator may run before it's killed and the reconcile fails."`
+}
+
+// Validate checks the rule mutators are executables and have a timeout.
+func (mc *RuleMutatorConfig) Validate() error {
+	for _, m := range mc.RuleMutator {
+		fields := strings.Fields(m)
+		if len(fields) == 0 {
+			return fmt.Errorf("--rule-mutator must not be empty")
+		}
+		if _, err := exec.LookPath(fields[0]); err != nil {
+			return fmt.Errorf("invalid --rule-mutator: %w", err)
+		}
+	}
+	if len(mc.RuleMutator) > 0 && mc.RuleMutatorTimeout <= 0 {
+		return fmt.Errorf("--rule-mutator-timeout must be greater than 0")
+	}
+	return nil
+}
+
+// mutators returns the rule mutators running the executables.
+func (mc RuleMutatorConfig) mutators() []controllers.RuleMutator {
+	mutators := make([]controllers.RuleMutator, 0, len(mc.RuleMutator))
+	for _, m := range mc.RuleMutator {
+		fields := strings.Fields(m)
+		mutators = append(mutators, &controllers.ExecRuleMutator{
+			Command: fields[0],
+			Args:    fields[1:],
+			Timeout: mc.RuleMutatorTimeout,
+		})
+	}
+	return mutators
+}
+
+type TemplateConfig struct {
+	ObjectiveTemplates        bool          `default:"false" help:"Watch ServiceLevelObjectiveTemplates and maintain an objective for each value they discover. Prometheus discovery requires --prometheus-url."`
+	ObjectiveTemplateInterval time.Duration `default:"5m" help:"How often the values of ServiceLevelObjectiveTemplates are discovered again."`
+}
+
+// Validate checks objective templates have an interval.
+func (tc *TemplateConfig) Validate() error {
+	if tc.ObjectiveTemplates && tc.ObjectiveTemplateInterval <= 0 {
+		return fmt.Errorf("--objective-template-interval must be greater than 0")
+	}
+	return nil
+}
+
+type ShadowConfig struct {
+	ShadowSuffix   string        `default:"" help:"Write changed rules of objectives to their PrometheusRules next to the live rules first, with this suffix appended to their record names, like :canary, and without alerts. A pyrra_shadow_difference rule records their difference to the live rules until they replace them. Rules are replaced right away if empty."`
+	ShadowDuration time.Duration `default:"6h" help:"How long changed rules are compared with the live rules in shadow before they replace them."`
+}
+
+// Validate checks the shadow suffix can be part of rule names and comes with a duration.
+func (sc *ShadowConfig) Validate() error {
+	if sc.ShadowSuffix == "" {
+		return nil
+	}
+	if !shadowSuffixRegexp.MatchString(sc.ShadowSuffix) {
+		return fmt.Errorf("--shadow-suffix must only contain letters, digits, _ and :, got %q", sc.ShadowSuffix)
+	}
+	if sc.ShadowDuration <= 0 {
+		return fmt.Errorf("--shadow-duration must be positive")
+	}
+	return nil
+}
+
+var shadowSuffixRegexp = regexp.MustCompile(`^[a-zA-Z0-9_:]+$`)
+
+type DestinationConfig struct {
+	DestinationLabels        map[string]string `name:"destination-labels" default:"" help:"The destinations objectives select with their spec.destination and the labels added to their PrometheusRules and ConfigMaps, for the ruleSelector of the destination's Prometheus to select them, like staging=prometheus=staging,env=staging;prod=prometheus=prod. Separate destinations with ; and labels with a comma."`
+	DestinationLokiRulerURLs map[string]string `name:"destination-loki-ruler-urls" default:"" help:"The destinations objectives select with their spec.destination and the URLs of their Loki rulers, like staging=http://loki-staging:3100, to push the rules of objectives evaluated by Loki to instead of --loki-ruler-url."`
+}
+
+// Validate checks the destinations can be parsed.
+func (dc *DestinationConfig) Validate() error {
+	_, err := dc.destinations(nil, nil)
+	return err
+}
+
+// destinations returns the destinations by their name, with Loki rulers using the client and sending the headers.
+// Destinations are defined by their labels, their Loki ruler's URL or both.
+func (dc DestinationConfig) destinations(client *http.Client, headers map[string]string) (map[string]controllers.Destination, error) {
+	destinations := map[string]controllers.Destination{}
+	for name, value := range dc.DestinationLabels {
+		if name == "" {
+			return nil, fmt.Errorf("--destination-labels must name each destination")
+		}
+		labels, err := k8slabels.ConvertSelectorToLabelsMap(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --destination-labels of %s: %w", name, err)
+		}
+		d := destinations[name]
+		d.Labels = labels
+		destinations[name] = d
+	}
+	for name, value := range dc.DestinationLokiRulerURLs {
+		if name == "" {
+			return nil, fmt.Errorf("--destination-loki-ruler-urls must name each destination")
+		}
+		u, err := url.Parse(value)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid --destination-loki-ruler-urls of %s: %q is not an absolute URL", name, value)
+		}
+		d := destinations[name]
+		d.LokiRuler = newLokiRuler(u, client, headers)
+		destinations[name] = d
+	}
+	return destinations, nil
+}
+
+// secretCache returns the cache config of the Secrets the operator reads,
+// the Loki ruler credentials Secrets in the namespaces of objectives and the Secrets of its config,
+// like the Loki ruler config Secret and the kubeconfig Secrets of remote clusters.
+func secretCache(namespaces map[string]cache.Config, credentialsSecret string, secrets ...types.NamespacedName) cache.ByObject {
+	byObject := cache.ByObject{Namespaces: map[string]cache.Config{}}
+	if credentialsSecret != "" {
+		selector := fields.OneTermEqualSelector("metadata.name", credentialsSecret)
+		if len(namespaces) == 0 {
+			byObject.Namespaces[cache.AllNamespaces] = cache.Config{FieldSelector: selector}
+		}
+		for namespace := range namespaces {
+			byObject.Namespaces[namespace] = cache.Config{FieldSelector: selector}
+		}
+	}
+	for _, secret := range secrets {
+		if secret.Name == "" {
+			continue
+		}
+		_, cached := byObject.Namespaces[secret.Namespace]
+		if cached || (credentialsSecret != "" && len(namespaces) == 0) {
+			// A field selector can't select several Secrets, so all Secrets of the namespace are cached.
+			byObject.Namespaces[secret.Namespace] = cache.Config{}
+		} else {
+			byObject.Namespaces[secret.Namespace] = cache.Config{
+				FieldSelector: fields.OneTermEqualSelector("metadata.name", secret.Name),
+			}
+		}
+	}
+	return byObject
+}
+
+// newLokiRuler returns a client for the Loki ruler at the URL.
+func newLokiRuler(u *url.URL, client *http.Client, headers map[string]string) *controllers.LokiRuler {
+	return &controllers.LokiRuler{
+		URL:     u,
+		Client:  client,
+		Headers: headers,
+		// Retry failed writes a few times before requeuing the objective with the workqueue's backoff.
+		Backoff: wait.Backoff{Duration: 500 * time.Millisecond, Factor: 2, Jitter: 0.2, Steps: 4},
+	}
+}
+
+// rateLimiter returns the rate limiter of failed reconciles,
+// the same as controller-runtime's default but configurable.
+// Every objective is retried with exponential backoff, and retries of all objectives are limited by a token bucket.
+func (rc ReconcileConfig) rateLimiter() workqueue.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(rc.WorkqueueBaseDelay, rc.WorkqueueMaxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(rc.WorkqueueQPS), rc.WorkqueueBurst)},
+	)
+}
+
+// options returns the options of the manager's cache.
+// Managed fields are never read by Pyrra and are stripped from all cached objects as they make up a large part of them.
+func (cc CacheConfig) options() (cache.Options, error) {
+	syncPeriod := cc.SyncPeriod
+	opts := cache.Options{
+		SyncPeriod:       &syncPeriod,
+		DefaultTransform: stripManagedFields,
+	}
+
+	if len(cc.Namespaces) > 0 {
+		opts.DefaultNamespaces = make(map[string]cache.Config, len(cc.Namespaces))
+		for _, namespace := range cc.Namespaces {
+			opts.DefaultNamespaces[namespace] = cache.Config{}
+		}
+	}
+
+	var rules cache.ByObject
+	if cc.CacheLabelSelector != "" {
+		selector, err := k8slabels.Parse(cc.CacheLabelSelector)
+		if err != nil {
+			return cache.Options{}, fmt.Errorf("invalid --cache-label-selector: %w", err)
+		}
+		rules.Label = selector
+	}
+	if cc.CacheFieldSelector != "" {
+		selector, err := fields.ParseSelector(cc.CacheFieldSelector)
+		if err != nil {
+			return cache.Options{}, fmt.Errorf("invalid --cache-field-selector: %w", err)
+		}
+		rules.Field = selector
+	}
+	if rules.Label != nil || rules.Field != nil {
+		opts.ByObject = map[client.Object]cache.ByObject{
+			&monitoringv1.PrometheusRule{}: rules,
+			&corev1.ConfigMap{}:            rules,
+		}
+	}
+
+	objectiveSelector, err := cc.objectiveSelector()
+	if err != nil {
+		return cache.Options{}, err
+	}
+	if objectiveSelector != nil {
+		if opts.ByObject == nil {
+			opts.ByObject = map[client.Object]cache.ByObject{}
+		}
+		opts.ByObject[&pyrrav1alpha1.ServiceLevelObjective{}] = cache.ByObject{Label: objectiveSelector}
+	}
+
+	return opts, nil
+}
+
+// objectiveSelector returns the selector of the objectives to reconcile or nil to reconcile all of them.
+func (cc CacheConfig) objectiveSelector() (k8slabels.Selector, error) {
+	if cc.SLOLabelSelector == "" {
+		return nil, nil
+	}
+	selector, err := k8slabels.Parse(cc.SLOLabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --slo-label-selector: %w", err)
+	}
+	return selector, nil
+}
+
+func stripManagedFields(obj any) (any, error) {
+	if accessor, err := meta.Accessor(obj); err == nil {
+		accessor.SetManagedFields(nil)
+	}
+	return obj, nil
+}
+
 func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 	_ = pyrrav1alpha1.AddToScheme(scheme)
+	_ = pyrrav1beta1.AddToScheme(scheme)
 	_ = monitoringv1.AddToScheme(scheme)
+	_ = monitoringv1alpha1.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
-func cmdKubernetes(
-	logger log.Logger,
-	metricsAddr string,
-	_, genericRules, disableWebhooks bool,
-	certFile, privateKeyFile string,
-) int {
+// KubernetesConfig configures the kubernetes command, the operator and the backend for the API.
+type KubernetesConfig struct {
+	MetricsAddr                string   `default:":8080" help:"The address the metric endpoint binds to."`
+	ConfigMapMode              bool     `default:"false" help:"If the generated recording rules should instead be saved to config maps in the default Prometheus format."`
+	GenericRules               bool     `default:"false" help:"Enabled generic recording rules generation to make it easier for tools like Grafana."`
+	DisableWebhooks            bool     `default:"true" env:"DISABLE_WEBHOOKS" help:"Disable webhooks so the controller doesn't try to read certificates"`
+	TLSCertFile                string   `default:"" help:"File containing the default x509 Certificate for HTTPS."`
+	TLSPrivateKeyFile          string   `default:"" help:"File containing the default x509 private key matching --tls-cert-file."`
+	LokiRulerURL               *url.URL `help:"The URL to the Loki ruler. Rules of objectives annotated with pyrra.dev/ruler: loki are sent to its API. If empty they are written to ConfigMaps for the Loki rules sidecar."`
+	LokiRulerCredentialsSecret string   `help:"The name of the Secret in each objective's namespace with the credentials for the Loki ruler, like a tenant's API key. Its tenant key is sent as X-Scope-OrgID, its token as bearer token, or its username and password for basic authentication. Rotated credentials are used right away."`
+	LokiRulerNamespaceTenants  bool     `default:"false" help:"Send the rules of objectives to the Loki ruler tenant set by the pyrra.dev/ruler-tenant annotation of their namespace, as X-Scope-OrgID. It takes precedence over the tenant of the credentials Secret."`
+	PrometheusURL              *url.URL `help:"The URL to the Prometheus to evaluate the error budget policies of objectives against. Policies aren't evaluated if empty."`
+	VerifyMetrics              bool     `default:"false" help:"Warn in the webhook's response about metrics and label matchers of objectives that don't select any series in the Prometheus of --prometheus-url."`
+	ProbeObjectives            bool     `default:"false" help:"Watch prometheus-operator Probes annotated with pyrra.dev/probe-slo: \"true\" and maintain an availability objective per probed target."`
+	RevisionHistoryLimit       int      `default:"10" help:"How many revisions of each objective's spec are kept as ServiceLevelObjectiveRevisions. Revisions aren't recorded if 0."`
+	CacheConfig
+	ReconcileConfig
+	PolicyConfig
+	GrafanaConfig
+	ThanosRulerConfig
+	LokiRulerGroupConfig
+	LeaderElectionConfig
+	DestinationConfig
+	ShadowConfig
+	OutputConfig
+	TemplateConfig
+	RemoteWriteConfig
+	LokiRulerClientConfig
+	AlertmanagerConfig
+	PrometheusSelectorConfig
+	RuleMutatorConfig
+	BackfillConfig
+	AlertAnnotationConfig
+	CardinalityConfig
+	RemoteClusterConfig
+	HealthConfig
+}
+
+func cmdKubernetes(logger log.Logger, config KubernetesConfig, promAPI controllers.BudgetPolicyQuerier) int {
+	ruleOutputs := config.OutputConfig.outputs(config.ConfigMapMode, config.ThanosRuler)
 	setupLog := ctrl.Log.WithName("setup")
-	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
+	ctrl.SetLogger(controllerRuntimeLogger(log.With(logger, "component", "controller-runtime")))
 
 	webhookServer := webhook.NewServer(webhook.Options{Port: 9443})
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	cacheOptions, err := config.CacheConfig.options()
+	if err != nil {
+		setupLog.Error(err, "unable to configure cache")
+		return 1
+	}
+	if config.ConfigMapShards > 0 && config.CacheLabelSelector != "" {
+		// The sharded ConfigMaps wouldn't be cached and look like they don't exist.
+		setupLog.Error(fmt.Errorf("--config-map-shards can't be used with --cache-label-selector"), "unable to configure cache")
+		return 1
+	}
+	if config.LokiRulerCredentialsSecret != "" || config.LokiRulerConfigSecret != "" || len(config.RemoteCluster) > 0 {
+		// Only the credentials and the config are read, there's no need to cache all Secrets of the cluster.
+		if cacheOptions.ByObject == nil {
+			cacheOptions.ByObject = map[client.Object]cache.ByObject{}
+		}
+		secrets := append([]types.NamespacedName{config.LokiRulerClientConfig.configSecret()}, config.RemoteClusterConfig.secrets()...)
+		cacheOptions.ByObject[&corev1.Secret{}] = secretCache(cacheOptions.DefaultNamespaces, config.LokiRulerCredentialsSecret, secrets...)
+	}
+	if prometheus := config.PrometheusSelectorConfig.prometheus(); prometheus.Name != "" {
+		// Only the one Prometheus is read, even if it's outside the namespaces of objectives.
+		if cacheOptions.ByObject == nil {
+			cacheOptions.ByObject = map[client.Object]cache.ByObject{}
+		}
+		cacheOptions.ByObject[&monitoringv1.Prometheus{}] = cache.ByObject{Namespaces: map[string]cache.Config{
+			prometheus.Namespace: {FieldSelector: fields.OneTermEqualSelector("metadata.name", prometheus.Name)},
+		}}
+	}
+
+	restConfig := ctrl.GetConfigOrDie()
+	restConfig.QPS = config.KubeAPIQPS
+	restConfig.Burst = config.KubeAPIBurst
+
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme: scheme,
+		Cache:  cacheOptions,
 		Metrics: metricsserver.Options{
-			BindAddress: metricsAddr,
+			BindAddress: config.MetricsAddr,
 		},
-		WebhookServer:    webhookServer,
-		LeaderElection:   false,
-		LeaderElectionID: "9d76195a.pyrra.dev",
+		WebhookServer:           webhookServer,
+		LeaderElection:          config.EnableLeaderElection,
+		LeaderElectionNamespace: config.LeaderElectionNamespace,
+		LeaderElectionID:        config.LeaderElectionName,
+		// The manager stopping ends the process, so the next leader can take over right away.
+		LeaderElectionReleaseOnCancel: true,
+		HealthProbeBindAddress:        config.HealthProbeAddr,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -84,20 +1048,253 @@ func cmdKubernetes(
 	}
 
 	reconciler := &controllers.ServiceLevelObjectiveReconciler{
-		Client:       mgr.GetClient(),
-		Logger:       log.With(logger, "controllers", "ServiceLevelObjective"),
-		GenericRules: genericRules,
+		Client:        mgr.GetClient(),
+		Logger:        log.With(logger, "component", "reconciler", "controllers", "ServiceLevelObjective"),
+		ConfigMapMode: config.ConfigMapMode,
+		GenericRules:  config.GenericRules,
+		Debounce:      config.ReconcileDebounce,
+		ResyncDelay:   config.ResyncSpread,
+		RateLimiter:   config.ReconcileConfig.rateLimiter(),
+		Recorder:      mgr.GetEventRecorderFor("pyrra"),
+
+		ResyncInterval:          config.ResyncInterval,
+		DetectDrift:             config.DetectDrift,
+		ExternalLabels:          config.ExternalLabels,
+		ConfigMapShards:         config.ConfigMapShards,
+		Version:                 version,
+		MaxConcurrentReconciles: config.MaxConcurrentReconciles,
+		Syncs:                   &controllers.ObjectiveSyncs{},
+		RuleMutators:            config.RuleMutatorConfig.mutators(),
 	}
+	lokiClient, err := config.LokiRulerClientConfig.client()
+	if err != nil {
+		setupLog.Error(err, "invalid loki ruler client config")
+		return 1
+	}
+	if config.LokiRulerURL != nil {
+		reconciler.LokiRuler = newLokiRuler(config.LokiRulerURL, lokiClient, config.LokiRulerHeaders)
+	}
+	if config.LokiRulerConfigSecret != "" {
+		reconciler.LokiRulerConfig = &controllers.LokiRulerConfig{
+			Secret: config.LokiRulerClientConfig.configSecret(),
+			Base:   newLokiRuler(nil, lokiClient, config.LokiRulerHeaders),
+		}
+	}
+	// Validated with the rest of the destination config already.
+	reconciler.Destinations, _ = config.DestinationConfig.destinations(lokiClient, config.LokiRulerHeaders)
+	lokiRulers := config.LokiRulerURL != nil || reconciler.LokiRulerConfig != nil || len(config.DestinationLokiRulerURLs) > 0
+	if lokiRulers {
+		reconciler.LokiCredentialsSecret = config.LokiRulerCredentialsSecret
+		reconciler.LokiNamespaceTenants = config.LokiRulerNamespaceTenants
+		reconciler.LokiNamespaceTemplate, err = config.LokiRulerGroupConfig.namespaceTemplate()
+		if err != nil {
+			setupLog.Error(err, "invalid loki ruler namespace template")
+			return 1
+		}
+		reconciler.LokiGroupIntervals = controllers.LokiGroupIntervals{
+			Increase: config.LokiRulerIncreaseInterval,
+			BurnRate: config.LokiRulerBurnRateInterval,
+			Generic:  config.LokiRulerGenericInterval,
+		}
+	}
+	if config.ShadowSuffix != "" {
+		reconciler.Shadow = &controllers.Shadow{
+			Suffix:   config.ShadowSuffix,
+			Duration: config.ShadowDuration,
+		}
+	}
+	if slices.Contains(ruleOutputs, controllers.RuleOutputThanosRuler) {
+		reconciler.ThanosRuler = &controllers.ThanosRuler{
+			Labels:           config.ThanosRulerConfigMapLabels,
+			MaxConfigMapSize: config.ThanosRulerMaxConfigMapSize,
+			ReloadURL:        config.ThanosRulerURL,
+			Client:           &http.Client{Timeout: 30 * time.Second, Transport: otelhttp.NewTransport(http.DefaultTransport)},
+		}
+	}
+	if config.GrafanaAlertRules {
+		reconciler.GrafanaAlertRules = &controllers.GrafanaAlertRules{
+			DatasourceUID:    config.GrafanaDatasourceUID,
+			DatasourceType:   config.GrafanaDatasourceType,
+			FolderRef:        config.GrafanaFolder,
+			InstanceSelector: config.GrafanaInstanceSelector,
+		}
+	}
+	// Validated with the rest of the grafana config already.
+	reconciler.GrafanaFolders, _ = config.GrafanaConfig.folders()
+	if config.GrafanaDashboards {
+		reconciler.GrafanaDashboards = &controllers.GrafanaDashboards{
+			DatasourceUID:    config.GrafanaDatasourceUID,
+			FolderRef:        config.GrafanaFolder,
+			InstanceSelector: config.GrafanaInstanceSelector,
+		}
+	}
+	if config.AlertmanagerInhibitRules {
+		reconciler.AlertmanagerConfigs = &controllers.AlertmanagerConfigs{
+			Labels: config.AlertmanagerConfigLabels,
+		}
+	}
+	reconciler.Backfill = config.BackfillConfig.backfill()
+	// Validated with the rest of the alert annotation config already.
+	reconciler.AlertAnnotations, _ = config.AlertAnnotationConfig.annotations()
+	if config.GroupingMaxSeries > 0 && promAPI == nil {
+		setupLog.Error(fmt.Errorf("--grouping-max-series requires --prometheus-url"), "unable to create controller", "controller", "ServiceLevelObjective")
+		return 1
+	}
+	reconciler.Cardinality = config.CardinalityConfig.cardinality(promAPI)
+	reconciler.RemoteClusters = config.RemoteClusterConfig.remoteClusters()
+	var prometheusSelector *controllers.PrometheusSelector
+	if prometheus := config.PrometheusSelectorConfig.prometheus(); prometheus.Name != "" {
+		prometheusSelector = &controllers.PrometheusSelector{
+			Prometheus: prometheus,
+			AddLabels:  config.PrometheusRuleSelectorLabels,
+		}
+		reconciler.PrometheusSelector = prometheusSelector
+	}
+	reconciler.Writers = reconciler.RuleOutputs(ruleOutputs...)
 	if err = reconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ServiceLevelObjective")
 		os.Exit(1)
 	}
-	if !disableWebhooks {
+	if !config.DisableWebhooks {
+		if config.VerifyMetrics {
+			if promAPI == nil {
+				setupLog.Error(fmt.Errorf("--verify-metrics requires --prometheus-url"), "unable to create webhook", "webhook", "ServiceLevelObjective")
+				return 1
+			}
+			reconciler.MetricsQuerier = promAPI
+		}
 		if err = reconciler.SetupWebhookWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "ServiceLevelObjective")
 			os.Exit(1)
 		}
 	}
+	if lokiRulers && config.LokiRulerSyncInterval > 0 {
+		err := mgr.Add(&controllers.LokiRuleSyncer{
+			Reconciler: reconciler,
+			Logger:     log.With(logger, "component", "reconciler", "controllers", "LokiRuleSyncer"),
+			Interval:   config.LokiRulerSyncInterval,
+		})
+		if err != nil {
+			setupLog.Error(err, "unable to add loki rule syncer")
+			os.Exit(1)
+		}
+	}
+	if (slices.Contains(ruleOutputs, controllers.RuleOutputConfigMap) || slices.Contains(ruleOutputs, controllers.RuleOutputThanosRuler)) && config.ConfigMapGCInterval > 0 {
+		// Validated with the rest of the cache config already.
+		objectiveSelector, _ := config.CacheConfig.objectiveSelector()
+		err := mgr.Add(&controllers.ConfigMapCollector{
+			Client:            mgr.GetClient(),
+			Logger:            log.With(logger, "component", "reconciler", "controllers", "ConfigMapCollector"),
+			Interval:          config.ConfigMapGCInterval,
+			ObjectiveSelector: objectiveSelector,
+		})
+		if err != nil {
+			setupLog.Error(err, "unable to add config map collector")
+			os.Exit(1)
+		}
+	}
+	if prometheusSelector != nil {
+		err := mgr.Add(&controllers.PrometheusSelectorCheck{
+			Client:   mgr.GetClient(),
+			Logger:   log.With(logger, "component", "reconciler", "controllers", "PrometheusSelectorCheck"),
+			Recorder: mgr.GetEventRecorderFor("pyrra"),
+			Selector: prometheusSelector,
+			Interval: config.PrometheusSelectorCheckInterval,
+		})
+		if err != nil {
+			setupLog.Error(err, "unable to add prometheus selector check")
+			os.Exit(1)
+		}
+	}
+	if config.RemoteWriteURL != nil {
+		err := mgr.Add(&controllers.ObjectiveRemoteWriter{
+			Client:      &http.Client{Timeout: 30 * time.Second, Transport: otelhttp.NewTransport(http.DefaultTransport)},
+			Logger:      log.With(logger, "component", "reconciler", "controllers", "RemoteWrite"),
+			URL:         config.RemoteWriteURL.String(),
+			Interval:    config.RemoteWriteInterval,
+			Headers:     config.RemoteWriteHeaders,
+			Username:    config.RemoteWriteUsername,
+			Password:    config.RemoteWritePassword,
+			BearerToken: config.RemoteWriteBearerToken,
+		})
+		if err != nil {
+			setupLog.Error(err, "unable to add remote writer")
+			os.Exit(1)
+		}
+	}
+	if config.AlertmanagerURL != nil && promAPI == nil {
+		setupLog.Error(fmt.Errorf("--alertmanager-url requires --prometheus-url"), "unable to add error budget policy evaluator")
+		return 1
+	}
+	if promAPI != nil {
+		err := mgr.Add(&controllers.BudgetPolicyEvaluator{
+			Client:   mgr.GetClient(),
+			Logger:   log.With(logger, "component", "reconciler", "controllers", "BudgetPolicy"),
+			Querier:  promAPI,
+			Recorder: mgr.GetEventRecorderFor("pyrra"),
+			Interval: config.PolicyInterval,
+			Silencer: config.PolicyConfig.silencer(),
+		})
+		if err != nil {
+			setupLog.Error(err, "unable to add error budget policy evaluator")
+			os.Exit(1)
+		}
+	}
+	if config.ProbeObjectives {
+		probeReconciler := &controllers.ProbeReconciler{
+			Client: mgr.GetClient(),
+			Logger: log.With(logger, "component", "reconciler", "controllers", "Probe"),
+		}
+		if err = probeReconciler.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Probe")
+			os.Exit(1)
+		}
+	}
+	if config.RevisionHistoryLimit > 0 {
+		revisionReconciler := &controllers.RevisionReconciler{
+			Client:       mgr.GetClient(),
+			APIReader:    mgr.GetAPIReader(),
+			Logger:       log.With(logger, "component", "reconciler", "controllers", "Revision"),
+			HistoryLimit: config.RevisionHistoryLimit,
+		}
+		if err = revisionReconciler.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Revision")
+			os.Exit(1)
+		}
+	}
+	if config.ObjectiveTemplates {
+		templateReconciler := &controllers.TemplateReconciler{
+			Client:   mgr.GetClient(),
+			Logger:   log.With(logger, "component", "reconciler", "controllers", "Template"),
+			Querier:  promAPI,
+			Interval: config.ObjectiveTemplateInterval,
+		}
+		if err = templateReconciler.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Template")
+			os.Exit(1)
+		}
+	}
+	var health *controllers.BackendHealth
+	if config.HealthProbeInterval > 0 {
+		probes := reconciler.BackendProbes(mgr.GetAPIReader())
+		if promAPI != nil {
+			probes["prometheus"] = func(ctx context.Context) error {
+				_, _, err := promAPI.Query(ctx, "vector(1)", time.Now())
+				return err
+			}
+		}
+		health = &controllers.BackendHealth{
+			Logger:           log.With(logger, "component", "health"),
+			Probes:           probes,
+			Interval:         config.HealthProbeInterval,
+			Timeout:          config.HealthProbeTimeout,
+			FailureThreshold: config.HealthProbeFailureThreshold,
+		}
+		if err := mgr.Add(health); err != nil {
+			setupLog.Error(err, "unable to add backend health probes")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	var (
@@ -114,6 +1311,14 @@ func cmdKubernetes(
 			if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
 				return fmt.Errorf("unable to set up ready check: %w", err)
 			}
+			if health != nil {
+				if err := mgr.AddHealthzCheck("backends", health.Live); err != nil {
+					return fmt.Errorf("unable to set up backend health check: %w", err)
+				}
+				if err := mgr.AddReadyzCheck("backends", health.Ready); err != nil {
+					return fmt.Errorf("unable to set up backend ready check: %w", err)
+				}
+			}
 			setupLog.Info("starting manager")
 			return mgr.Start(ctx)
 		}, func(_ error) {
@@ -125,16 +1330,21 @@ func cmdKubernetes(
 		router.Handle(objectivesv1alpha1connect.NewObjectiveBackendServiceHandler(&KubernetesObjectiveServer{
 			client: mgr.GetClient(),
 		}))
+		router.Handle("/revisions", &kubernetesRevisionsHandler{
+			logger: log.With(logger, "component", "revisions"),
+			client: mgr.GetClient(),
+		})
+		router.Handle("/syncs", reconciler.Syncs)
 
 		server := http.Server{
 			Addr:    ":9444",
-			Handler: h2c.NewHandler(router, &http2.Server{}),
+			Handler: h2c.NewHandler(otelhttp.NewHandler(router, "backend"), &http2.Server{}),
 		}
 
 		gr.Add(func() error {
-			if certFile != "" && privateKeyFile != "" {
-				setupLog.Info("serving with TLS", "cert", certFile, "key", privateKeyFile)
-				return server.ListenAndServeTLS(certFile, privateKeyFile)
+			if config.TLSCertFile != "" && config.TLSPrivateKeyFile != "" {
+				setupLog.Info("serving with TLS", "cert", config.TLSCertFile, "key", config.TLSPrivateKeyFile)
+				return server.ListenAndServeTLS(config.TLSCertFile, config.TLSPrivateKeyFile)
 			}
 			return server.ListenAndServe()
 		}, func(_ error) {
@@ -186,6 +1396,18 @@ func (s *KubernetesObjectiveServer) List(ctx context.Context, req *connect.Reque
 		}
 	}
 
+	if req.Msg.PageSize < 0 {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("page size must not be negative"))
+	}
+	var after types.NamespacedName
+	if req.Msg.PageToken != "" {
+		namespace, name, ok := strings.Cut(req.Msg.PageToken, "/")
+		if !ok || name == "" {
+			return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid page token %q", req.Msg.PageToken))
+		}
+		after = types.NamespacedName{Namespace: namespace, Name: name}
+	}
+
 	listOpts := client.ListOptions{}
 	for _, m := range matchers {
 		if m.Name == "namespace" && m.Type == labels.MatchEqual {
@@ -193,14 +1415,37 @@ func (s *KubernetesObjectiveServer) List(ctx context.Context, req *connect.Reque
 			break
 		}
 	}
+	if req.Msg.LabelSelector != "" {
+		selector, err := k8slabels.Parse(req.Msg.LabelSelector)
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("failed to parse label selector: %w", err))
+		}
+		listOpts.LabelSelector = selector
+	}
 
+	// The client reads from the operator's cache, listing doesn't reach the Kubernetes API.
 	var list pyrrav1alpha1.ServiceLevelObjectiveList
 	if err := s.client.List(ctx, &list, &listOpts); err != nil {
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
+	if req.Msg.PageSize > 0 || req.Msg.PageToken != "" {
+		// Pages continue after the last objective of the previous one, in the order of their namespace and name.
+		slices.SortFunc(list.Items, func(a, b pyrrav1alpha1.ServiceLevelObjective) int {
+			return strings.Compare(a.GetNamespace()+"/"+a.GetName(), b.GetNamespace()+"/"+b.GetName())
+		})
+	}
+
+	var (
+		last          types.NamespacedName
+		nextPageToken string
+	)
 	objectives := make([]*objectivesv1alpha1.Objective, 0, len(list.Items))
 	for _, s := range list.Items {
+		key := types.NamespacedName{Namespace: s.GetNamespace(), Name: s.GetName()}
+		if after.Name != "" && key.String() <= after.String() {
+			continue
+		}
 		if nameMatcher != nil {
 			if !nameMatcher.Matches(s.GetName()) {
 				continue
@@ -216,10 +1461,38 @@ func (s *KubernetesObjectiveServer) List(ctx context.Context, req *connect.Reque
 		if err != nil {
 			return nil, connect.NewError(connect.CodeInternal, err)
 		}
-		objectives = append(objectives, objectivesv1alpha1.FromInternal(internal))
+		if req.Msg.PageSize > 0 && len(objectives) == int(req.Msg.PageSize) {
+			// There's at least one more objective after the page.
+			nextPageToken = last.String()
+			break
+		}
+		objective := objectivesv1alpha1.FromInternal(internal)
+		objective.Sync = objectiveSync(s)
+		objectives = append(objectives, objective)
+		last = key
 	}
 
 	return connect.NewResponse(&objectivesv1alpha1.ListResponse{
-		Objectives: objectives,
+		Objectives:    objectives,
+		NextPageToken: nextPageToken,
 	}), nil
 }
+
+// objectiveSync returns the outcome of the last reconcile of the objective from its status,
+// nil if it wasn't reconciled yet.
+func objectiveSync(kubeObjective pyrrav1alpha1.ServiceLevelObjective) *objectivesv1alpha1.Sync {
+	ready := meta.FindStatusCondition(kubeObjective.Status.Conditions, pyrrav1alpha1.ConditionReady)
+	if ready == nil && kubeObjective.Status.RuleName == "" {
+		return nil
+	}
+	sync := &objectivesv1alpha1.Sync{
+		RuleType: kubeObjective.Status.Type,
+		RuleName: kubeObjective.Status.RuleName,
+	}
+	if ready != nil {
+		sync.Ready = ready.Status == metav1.ConditionTrue
+		sync.Reason = ready.Reason
+		sync.Message = ready.Message
+	}
+	return sync
+}
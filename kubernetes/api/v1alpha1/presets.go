@@ -0,0 +1,279 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// IstioIndicator is a preset for services running in an Istio service mesh.
+// It expands into a ratio indicator on 5xx response codes or,
+// if Latency is set, into a latency indicator using the request duration histogram.
+type IstioIndicator struct {
+	// Service is the name of the destination service as reported by Istio's destination_service_name label.
+	Service string `json:"service"`
+
+	// +optional
+	// Namespace of the destination service. Defaults to the namespace of the ServiceLevelObjective.
+	Namespace string `json:"namespace,omitempty"`
+
+	// +optional
+	// Latency the requests should be faster than, like 100ms.
+	// It needs to match one of the buckets of istio_request_duration_milliseconds.
+	Latency string `json:"latency,omitempty"`
+
+	// +optional
+	// Grouping allows an SLO to be defined for many SLI at once, like per source workload for example.
+	Grouping []string `json:"grouping"`
+}
+
+func (i IstioIndicator) matchers(namespace string) string {
+	ms := []string{
+		`reporter="destination"`,
+		fmt.Sprintf("destination_service_name=%q", i.Service),
+	}
+
+	if i.Namespace != "" {
+		namespace = i.Namespace
+	}
+	if namespace != "" {
+		ms = append(ms, fmt.Sprintf("destination_service_namespace=%q", namespace))
+	}
+
+	return strings.Join(ms, ",")
+}
+
+func (i IstioIndicator) expand(namespace string) (ServiceLevelIndicator, error) {
+	if i.Service == "" {
+		return ServiceLevelIndicator{}, fmt.Errorf("istio service must be set")
+	}
+
+	matchers := i.matchers(namespace)
+
+	if i.Latency == "" {
+		return presetRatio("istio_requests_total", matchers, `response_code=~"5.."`, i.Grouping), nil
+	}
+
+	le, err := presetBucket(i.Latency, time.Millisecond)
+	if err != nil {
+		return ServiceLevelIndicator{}, fmt.Errorf("istio latency must be a valid duration: %w", err)
+	}
+
+	return presetLatency("istio_request_duration_milliseconds", matchers, le, i.Grouping), nil
+}
+
+// LinkerdIndicator is a preset for workloads meshed by Linkerd.
+// It expands into a ratio indicator on responses the proxy classified as failure or,
+// if Latency is set, into a latency indicator using the response latency histogram.
+type LinkerdIndicator struct {
+	// Deployment is the name of the meshed deployment receiving the requests.
+	Deployment string `json:"deployment"`
+
+	// +optional
+	// Namespace of the deployment. Defaults to the namespace of the ServiceLevelObjective.
+	Namespace string `json:"namespace,omitempty"`
+
+	// +optional
+	// Latency the responses should be faster than, like 100ms.
+	// It needs to match one of the buckets of response_latency_ms.
+	Latency string `json:"latency,omitempty"`
+
+	// +optional
+	// Grouping allows an SLO to be defined for many SLI at once, like per route for example.
+	Grouping []string `json:"grouping"`
+}
+
+func (l LinkerdIndicator) matchers(namespace string) string {
+	ms := []string{
+		`direction="inbound"`,
+		fmt.Sprintf("deployment=%q", l.Deployment),
+	}
+
+	if l.Namespace != "" {
+		namespace = l.Namespace
+	}
+	if namespace != "" {
+		ms = append(ms, fmt.Sprintf("namespace=%q", namespace))
+	}
+
+	return strings.Join(ms, ",")
+}
+
+func (l LinkerdIndicator) expand(namespace string) (ServiceLevelIndicator, error) {
+	if l.Deployment == "" {
+		return ServiceLevelIndicator{}, fmt.Errorf("linkerd deployment must be set")
+	}
+
+	matchers := l.matchers(namespace)
+
+	if l.Latency == "" {
+		return presetRatio("response_total", matchers, `classification="failure"`, l.Grouping), nil
+	}
+
+	le, err := presetBucket(l.Latency, time.Millisecond)
+	if err != nil {
+		return ServiceLevelIndicator{}, fmt.Errorf("linkerd latency must be a valid duration: %w", err)
+	}
+
+	return presetLatency("response_latency_ms", matchers, le, l.Grouping), nil
+}
+
+// GRPCIndicator is a preset for gRPC servers instrumented with the Prometheus gRPC interceptors.
+// It expands into a ratio indicator on server error codes or,
+// if Latency is set, into a latency indicator using the handling time histogram.
+type GRPCIndicator struct {
+	// Service is the fully qualified gRPC service name, like helloworld.Greeter.
+	Service string `json:"service"`
+
+	// +optional
+	// Method of the gRPC service. All methods of the service are selected if empty.
+	Method string `json:"method,omitempty"`
+
+	// +optional
+	// Job selects the metrics of a specific scrape job.
+	Job string `json:"job,omitempty"`
+
+	// +optional
+	// Latency the requests should be faster than, like 100ms.
+	// It needs to match one of the buckets of grpc_server_handling_seconds.
+	Latency string `json:"latency,omitempty"`
+
+	// +optional
+	// Grouping allows an SLO to be defined for many SLI at once, like per gRPC method for example.
+	Grouping []string `json:"grouping"`
+}
+
+// grpcErrorCodes are the codes that indicate a failure of the server rather than of the client.
+const grpcErrorCodes = "Aborted|Unavailable|Internal|Unknown|Unimplemented|DataLoss"
+
+func (g GRPCIndicator) matchers() string {
+	var ms []string
+	if g.Job != "" {
+		ms = append(ms, fmt.Sprintf("job=%q", g.Job))
+	}
+	ms = append(ms, fmt.Sprintf("grpc_service=%q", g.Service))
+	if g.Method != "" {
+		ms = append(ms, fmt.Sprintf("grpc_method=%q", g.Method))
+	}
+	return strings.Join(ms, ",")
+}
+
+func (g GRPCIndicator) expand(_ string) (ServiceLevelIndicator, error) {
+	if g.Service == "" {
+		return ServiceLevelIndicator{}, fmt.Errorf("grpc service must be set")
+	}
+
+	matchers := g.matchers()
+
+	if g.Latency == "" {
+		return presetRatio("grpc_server_handled_total", matchers, fmt.Sprintf("grpc_code=~%q", grpcErrorCodes), g.Grouping), nil
+	}
+
+	le, err := presetBucket(g.Latency, time.Second)
+	if err != nil {
+		return ServiceLevelIndicator{}, fmt.Errorf("grpc latency must be a valid duration: %w", err)
+	}
+
+	return presetLatency("grpc_server_handling_seconds", matchers, le, g.Grouping), nil
+}
+
+// presetRatio returns a ratio indicator for a counter where errors are selected by an additional matcher.
+func presetRatio(metric, matchers, errorMatcher string, grouping []string) ServiceLevelIndicator {
+	return ServiceLevelIndicator{
+		Ratio: &RatioIndicator{
+			Errors:   Query{Metric: fmt.Sprintf(`%s{%s,%s}`, metric, matchers, errorMatcher)},
+			Total:    Query{Metric: fmt.Sprintf(`%s{%s}`, metric, matchers)},
+			Grouping: grouping,
+		},
+	}
+}
+
+// presetLatency returns a latency indicator for the histogram with the given base name.
+func presetLatency(histogram, matchers, le string, grouping []string) ServiceLevelIndicator {
+	return ServiceLevelIndicator{
+		Latency: &LatencyIndicator{
+			Success:  Query{Metric: fmt.Sprintf(`%s_bucket{%s,le=%q}`, histogram, matchers, le)},
+			Total:    Query{Metric: fmt.Sprintf(`%s_count{%s}`, histogram, matchers)},
+			Grouping: grouping,
+		},
+	}
+}
+
+// presetBucket converts a latency like 250ms into the le label value of a histogram bucket in the given unit.
+func presetBucket(latency string, unit time.Duration) (string, error) {
+	d, err := model.ParseDuration(latency)
+	if err != nil {
+		return "", err
+	}
+	if d <= 0 {
+		return "", fmt.Errorf("latency must be greater than 0")
+	}
+	return strconv.FormatFloat(float64(d)/float64(unit), 'f', -1, 64), nil
+}
+
+// expandPresets returns the ServiceLevelIndicator with a preset, if any,
+// replaced by the indicator it is a shorthand for.
+func (in ServiceLevelIndicator) expandPresets(namespace string) (ServiceLevelIndicator, error) {
+	var (
+		name   string
+		expand func(namespace string) (ServiceLevelIndicator, error)
+	)
+	switch {
+	case in.Istio != nil:
+		name, expand = "istio", in.Istio.expand
+	case in.Linkerd != nil:
+		name, expand = "linkerd", in.Linkerd.expand
+	case in.GRPC != nil:
+		name, expand = "grpc", in.GRPC.expand
+	default:
+		return in, nil
+	}
+
+	if in.count() > 1 {
+		return ServiceLevelIndicator{}, fmt.Errorf("%s cannot be combined with other indicators", name)
+	}
+
+	return expand(namespace)
+}
+
+// count returns how many indicators, including presets, are set.
+func (in ServiceLevelIndicator) count() int {
+	var n int
+	for _, set := range []bool{
+		in.Ratio != nil,
+		in.Latency != nil,
+		in.LatencyNative != nil,
+		in.BoolGauge != nil,
+		in.Quantile != nil,
+		in.Expression != nil,
+		in.Logs != nil,
+		in.Composite != nil,
+		in.Istio != nil,
+		in.Linkerd != nil,
+		in.GRPC != nil,
+	} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,317 @@
+package v1alpha1_test
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+func presetObjective(indicator v1alpha1.ServiceLevelIndicator) *v1alpha1.ServiceLevelObjective {
+	return &v1alpha1.ServiceLevelObjective{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "name",
+			Namespace: "namespace",
+		},
+		Spec: v1alpha1.ServiceLevelObjectiveSpec{
+			Target:                "99",
+			Window:                "2w",
+			ServiceLevelIndicator: indicator,
+		},
+	}
+}
+
+func TestIstioIndicator(t *testing.T) {
+	testcases := []struct {
+		name      string
+		istio     *v1alpha1.IstioIndicator
+		indicator slo.Indicator
+		err       string
+	}{{
+		name:  "ratio",
+		istio: &v1alpha1.IstioIndicator{Service: "reviews"},
+		indicator: slo.Indicator{
+			Ratio: &slo.RatioIndicator{
+				Errors: slo.Metric{
+					Name: "istio_requests_total",
+					LabelMatchers: []*labels.Matcher{
+						{Type: labels.MatchEqual, Name: "reporter", Value: "destination"},
+						{Type: labels.MatchEqual, Name: "destination_service_name", Value: "reviews"},
+						{Type: labels.MatchEqual, Name: "destination_service_namespace", Value: "namespace"},
+						{Type: labels.MatchRegexp, Name: "response_code", Value: "5.."},
+						{Type: labels.MatchEqual, Name: labels.MetricName, Value: "istio_requests_total"},
+					},
+				},
+				Total: slo.Metric{
+					Name: "istio_requests_total",
+					LabelMatchers: []*labels.Matcher{
+						{Type: labels.MatchEqual, Name: "reporter", Value: "destination"},
+						{Type: labels.MatchEqual, Name: "destination_service_name", Value: "reviews"},
+						{Type: labels.MatchEqual, Name: "destination_service_namespace", Value: "namespace"},
+						{Type: labels.MatchEqual, Name: labels.MetricName, Value: "istio_requests_total"},
+					},
+				},
+			},
+		},
+	}, {
+		name: "latency",
+		istio: &v1alpha1.IstioIndicator{
+			Service:   "reviews",
+			Namespace: "bookinfo",
+			Latency:   "250ms",
+			Grouping:  []string{"source_workload"},
+		},
+		indicator: slo.Indicator{
+			Latency: &slo.LatencyIndicator{
+				Success: slo.Metric{
+					Name: "istio_request_duration_milliseconds_bucket",
+					LabelMatchers: []*labels.Matcher{
+						{Type: labels.MatchEqual, Name: "reporter", Value: "destination"},
+						{Type: labels.MatchEqual, Name: "destination_service_name", Value: "reviews"},
+						{Type: labels.MatchEqual, Name: "destination_service_namespace", Value: "bookinfo"},
+						{Type: labels.MatchEqual, Name: "le", Value: "250"},
+						{Type: labels.MatchEqual, Name: labels.MetricName, Value: "istio_request_duration_milliseconds_bucket"},
+					},
+				},
+				Total: slo.Metric{
+					Name: "istio_request_duration_milliseconds_count",
+					LabelMatchers: []*labels.Matcher{
+						{Type: labels.MatchEqual, Name: "reporter", Value: "destination"},
+						{Type: labels.MatchEqual, Name: "destination_service_name", Value: "reviews"},
+						{Type: labels.MatchEqual, Name: "destination_service_namespace", Value: "bookinfo"},
+						{Type: labels.MatchEqual, Name: labels.MetricName, Value: "istio_request_duration_milliseconds_count"},
+					},
+				},
+				Grouping: []string{"source_workload"},
+			},
+		},
+	}, {
+		name:  "emptyService",
+		istio: &v1alpha1.IstioIndicator{},
+		err:   "istio service must be set",
+	}, {
+		name:  "invalidLatency",
+		istio: &v1alpha1.IstioIndicator{Service: "reviews", Latency: "foo"},
+		err:   `istio latency must be a valid duration: not a valid duration string: "foo"`,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			objective := presetObjective(v1alpha1.ServiceLevelIndicator{Istio: tc.istio})
+
+			_, err := objective.ValidateCreate()
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+
+			internal, err := objective.Internal()
+			require.NoError(t, err)
+			require.Equal(t, tc.indicator, internal.Indicator)
+		})
+	}
+
+	t.Run("combined", func(t *testing.T) {
+		objective := presetObjective(v1alpha1.ServiceLevelIndicator{
+			Istio: &v1alpha1.IstioIndicator{Service: "reviews"},
+			Ratio: &v1alpha1.RatioIndicator{
+				Errors: v1alpha1.Query{Metric: `foo{code=~"5.."}`},
+				Total:  v1alpha1.Query{Metric: `foo`},
+			},
+		})
+		_, err := objective.ValidateCreate()
+		require.EqualError(t, err, "istio cannot be combined with other indicators")
+	})
+}
+
+func TestLinkerdIndicator(t *testing.T) {
+	testcases := []struct {
+		name      string
+		linkerd   *v1alpha1.LinkerdIndicator
+		indicator slo.Indicator
+		err       string
+	}{{
+		name:    "ratio",
+		linkerd: &v1alpha1.LinkerdIndicator{Deployment: "web"},
+		indicator: slo.Indicator{
+			Ratio: &slo.RatioIndicator{
+				Errors: slo.Metric{
+					Name: "response_total",
+					LabelMatchers: []*labels.Matcher{
+						{Type: labels.MatchEqual, Name: "direction", Value: "inbound"},
+						{Type: labels.MatchEqual, Name: "deployment", Value: "web"},
+						{Type: labels.MatchEqual, Name: "namespace", Value: "namespace"},
+						{Type: labels.MatchEqual, Name: "classification", Value: "failure"},
+						{Type: labels.MatchEqual, Name: labels.MetricName, Value: "response_total"},
+					},
+				},
+				Total: slo.Metric{
+					Name: "response_total",
+					LabelMatchers: []*labels.Matcher{
+						{Type: labels.MatchEqual, Name: "direction", Value: "inbound"},
+						{Type: labels.MatchEqual, Name: "deployment", Value: "web"},
+						{Type: labels.MatchEqual, Name: "namespace", Value: "namespace"},
+						{Type: labels.MatchEqual, Name: labels.MetricName, Value: "response_total"},
+					},
+				},
+			},
+		},
+	}, {
+		name: "latency",
+		linkerd: &v1alpha1.LinkerdIndicator{
+			Deployment: "web",
+			Namespace:  "emojivoto",
+			Latency:    "1s",
+		},
+		indicator: slo.Indicator{
+			Latency: &slo.LatencyIndicator{
+				Success: slo.Metric{
+					Name: "response_latency_ms_bucket",
+					LabelMatchers: []*labels.Matcher{
+						{Type: labels.MatchEqual, Name: "direction", Value: "inbound"},
+						{Type: labels.MatchEqual, Name: "deployment", Value: "web"},
+						{Type: labels.MatchEqual, Name: "namespace", Value: "emojivoto"},
+						{Type: labels.MatchEqual, Name: "le", Value: "1000"},
+						{Type: labels.MatchEqual, Name: labels.MetricName, Value: "response_latency_ms_bucket"},
+					},
+				},
+				Total: slo.Metric{
+					Name: "response_latency_ms_count",
+					LabelMatchers: []*labels.Matcher{
+						{Type: labels.MatchEqual, Name: "direction", Value: "inbound"},
+						{Type: labels.MatchEqual, Name: "deployment", Value: "web"},
+						{Type: labels.MatchEqual, Name: "namespace", Value: "emojivoto"},
+						{Type: labels.MatchEqual, Name: labels.MetricName, Value: "response_latency_ms_count"},
+					},
+				},
+			},
+		},
+	}, {
+		name:    "emptyDeployment",
+		linkerd: &v1alpha1.LinkerdIndicator{},
+		err:     "linkerd deployment must be set",
+	}, {
+		name:    "invalidLatency",
+		linkerd: &v1alpha1.LinkerdIndicator{Deployment: "web", Latency: "0s"},
+		err:     "linkerd latency must be a valid duration: latency must be greater than 0",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			objective := presetObjective(v1alpha1.ServiceLevelIndicator{Linkerd: tc.linkerd})
+
+			_, err := objective.ValidateCreate()
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+
+			internal, err := objective.Internal()
+			require.NoError(t, err)
+			require.Equal(t, tc.indicator, internal.Indicator)
+		})
+	}
+
+	t.Run("combined", func(t *testing.T) {
+		objective := presetObjective(v1alpha1.ServiceLevelIndicator{
+			Linkerd: &v1alpha1.LinkerdIndicator{Deployment: "web"},
+			Istio:   &v1alpha1.IstioIndicator{Service: "web"},
+		})
+		_, err := objective.ValidateCreate()
+		require.EqualError(t, err, "istio cannot be combined with other indicators")
+	})
+}
+
+func TestGRPCIndicator(t *testing.T) {
+	testcases := []struct {
+		name      string
+		grpc      *v1alpha1.GRPCIndicator
+		indicator slo.Indicator
+		err       string
+	}{{
+		name: "ratio",
+		grpc: &v1alpha1.GRPCIndicator{Service: "helloworld.Greeter", Method: "SayHello"},
+		indicator: slo.Indicator{
+			Ratio: &slo.RatioIndicator{
+				Errors: slo.Metric{
+					Name: "grpc_server_handled_total",
+					LabelMatchers: []*labels.Matcher{
+						{Type: labels.MatchEqual, Name: "grpc_service", Value: "helloworld.Greeter"},
+						{Type: labels.MatchEqual, Name: "grpc_method", Value: "SayHello"},
+						{Type: labels.MatchRegexp, Name: "grpc_code", Value: "Aborted|Unavailable|Internal|Unknown|Unimplemented|DataLoss"},
+						{Type: labels.MatchEqual, Name: labels.MetricName, Value: "grpc_server_handled_total"},
+					},
+				},
+				Total: slo.Metric{
+					Name: "grpc_server_handled_total",
+					LabelMatchers: []*labels.Matcher{
+						{Type: labels.MatchEqual, Name: "grpc_service", Value: "helloworld.Greeter"},
+						{Type: labels.MatchEqual, Name: "grpc_method", Value: "SayHello"},
+						{Type: labels.MatchEqual, Name: labels.MetricName, Value: "grpc_server_handled_total"},
+					},
+				},
+			},
+		},
+	}, {
+		name: "latency",
+		grpc: &v1alpha1.GRPCIndicator{
+			Service:  "helloworld.Greeter",
+			Job:      "greeter",
+			Latency:  "250ms",
+			Grouping: []string{"grpc_method"},
+		},
+		indicator: slo.Indicator{
+			Latency: &slo.LatencyIndicator{
+				Success: slo.Metric{
+					Name: "grpc_server_handling_seconds_bucket",
+					LabelMatchers: []*labels.Matcher{
+						{Type: labels.MatchEqual, Name: "job", Value: "greeter"},
+						{Type: labels.MatchEqual, Name: "grpc_service", Value: "helloworld.Greeter"},
+						{Type: labels.MatchEqual, Name: "le", Value: "0.25"},
+						{Type: labels.MatchEqual, Name: labels.MetricName, Value: "grpc_server_handling_seconds_bucket"},
+					},
+				},
+				Total: slo.Metric{
+					Name: "grpc_server_handling_seconds_count",
+					LabelMatchers: []*labels.Matcher{
+						{Type: labels.MatchEqual, Name: "job", Value: "greeter"},
+						{Type: labels.MatchEqual, Name: "grpc_service", Value: "helloworld.Greeter"},
+						{Type: labels.MatchEqual, Name: labels.MetricName, Value: "grpc_server_handling_seconds_count"},
+					},
+				},
+				Grouping: []string{"grpc_method"},
+			},
+		},
+	}, {
+		name: "emptyService",
+		grpc: &v1alpha1.GRPCIndicator{Method: "SayHello"},
+		err:  "grpc service must be set",
+	}, {
+		name: "invalidLatency",
+		grpc: &v1alpha1.GRPCIndicator{Service: "helloworld.Greeter", Latency: "1"},
+		err:  `grpc latency must be a valid duration: not a valid duration string: "1"`,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			objective := presetObjective(v1alpha1.ServiceLevelIndicator{GRPC: tc.grpc})
+
+			_, err := objective.ValidateCreate()
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+
+			internal, err := objective.Internal()
+			require.NoError(t, err)
+			require.Equal(t, tc.indicator, internal.Indicator)
+		})
+	}
+}
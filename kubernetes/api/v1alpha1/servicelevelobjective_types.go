@@ -18,14 +18,20 @@ package v1alpha1
 
 import (
 	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/promql/parser"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 	"sigs.k8s.io/yaml"
@@ -53,10 +59,12 @@ type ServiceLevelObjectiveList struct {
 
 // +kubebuilder:object:root=true
 // +kubebuilder:resource:shortName=slo
+// +kubebuilder:storageversion
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="Window",type=string,JSONPath=`.spec.window`
 // +kubebuilder:printcolumn:name="Target",type=string,JSONPath=`.spec.target`
 // +kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.status.type`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // ServiceLevelObjective is the Schema for the ServiceLevelObjectives API.
@@ -90,6 +98,180 @@ type ServiceLevelObjectiveSpec struct {
 	// +optional
 	// Alerting customizes the alerting rules generated by Pyrra.
 	Alerting Alerting `json:"alerting"`
+
+	// +optional
+	// Policy is the error budget policy, taking actions as the remaining error budget falls below thresholds.
+	Policy *ErrorBudgetPolicy `json:"policy,omitempty"`
+
+	// +optional
+	// Owner is who is responsible for the objective. It's added to all alerts of the objective to route them.
+	Owner *Owner `json:"owner,omitempty"`
+
+	// +optional
+	// SLA is the external agreement promised for the service, usually looser than the Target.
+	// Its compliance is recorded next to the objective's own rules.
+	SLA *SLA `json:"sla,omitempty"`
+
+	// +optional
+	// +kubebuilder:validation:Enum=week;month;quarter
+	// Calendar additionally records the error budget since the start of the calendar week, month or quarter in UTC as pyrra_calendar_error_budget,
+	// which resets at the start of each period. The SLA's compliance is then per period too, like contractual SLAs usually are.
+	// The alerts still use the rolling Window.
+	Calendar string `json:"calendar,omitempty"`
+
+	// +optional
+	// StableRuleNames names the increase recording rules without the window, like http_requests:increase instead of http_requests:increase4w.
+	// Their series, and with them the error budget's history, then survive changes of the window.
+	// The target isn't part of any rule's name or labels and is recorded separately as pyrra_objective.
+	// Enabling it renames the recording rules once, their history before is only in the old series.
+	StableRuleNames bool `json:"stableRuleNames,omitempty"`
+
+	// +optional
+	// SharedBurnrates records the burn rates once for all objectives in the namespace with the same indicator and sharedBurnrates,
+	// like objectives of different targets or windows, in the PrometheusRule pyrra-shared-burnrates instead of each objective's own.
+	// The objective's alerts select them by their burnrate_id label instead of the slo label.
+	// It's only supported for objectives whose rules are written to PrometheusRules.
+	SharedBurnrates bool `json:"sharedBurnrates,omitempty"`
+
+	// +optional
+	// Destination is the name of the ruler the objective's rules are written to, one of the operator's --destination-labels or --destination-loki-ruler-urls.
+	// The rules are written like without destinations if it's empty.
+	Destination string `json:"destination,omitempty"`
+
+	// +optional
+	// ExternalLabels are added to all recording and alerting rules of the objective, like cluster or region,
+	// so the series of objectives in several clusters don't collide when aggregated in Thanos or Mimir.
+	// They take precedence over the operator's --external-label.
+	ExternalLabels map[string]string `json:"externalLabels,omitempty"`
+
+	// +optional
+	// RuleGroupInterval is the evaluation interval of all the objective's rule groups, like 2m, instead of the generated ones.
+	// Objectives with many series may be evaluated less often to keep the ruler's CPU usage down.
+	// It must not be longer than the shortest short window of the alerting.
+	RuleGroupInterval string `json:"ruleGroupInterval,omitempty"`
+
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// RuleGroupLimit limits the series each recording rule and the alerts each alerting rule of the objective may produce.
+	// Rules exceeding it fail their evaluation. It's unlimited if it's 0.
+	RuleGroupLimit int `json:"ruleGroupLimit,omitempty"`
+
+	// +optional
+	// Paused stops the operator from updating the objective's rules, they're kept as they were last written,
+	// like during a planned migration of the service, without deleting the objective and losing its definition.
+	// The pyrra.dev/paused annotation set to true pauses the objective too. The Paused condition reflects it.
+	Paused bool `json:"paused,omitempty"`
+
+	// +optional
+	// DeleteAlertsWhilePaused deletes the alerting rules of the paused objective once, keeping its recording rules,
+	// so its alerts don't fire while it's paused. The recording rules are written once more from the spec then.
+	// The alerting rules are written again once it's resumed.
+	// The pyrra.dev/paused annotation set to delete-alerts does the same.
+	DeleteAlertsWhilePaused bool `json:"deleteAlertsWhilePaused,omitempty"`
+}
+
+// Owner is added as team, slack_channel, escalation_policy and tier labels and a runbook_url annotation to all alerts of the objective,
+// so Alertmanager can route them and receivers know who to reach. The API serves it for the UI to show who owns the objective.
+type Owner struct {
+	// +kubebuilder:validation:MinLength=1
+	// Team owning the objective.
+	Team string `json:"team"`
+
+	// +optional
+	// +kubebuilder:validation:Pattern=`^#[a-z0-9][a-z0-9._-]{0,79}$`
+	// Slack is the Slack channel of the team, like #checkout-alerts.
+	Slack string `json:"slack,omitempty"`
+
+	// +optional
+	// EscalationPolicy is the escalation policy of the team, like the name or ID of a PagerDuty or Opsgenie policy.
+	EscalationPolicy string `json:"escalationPolicy,omitempty"`
+
+	// +optional
+	// RunbookURL is the runbook of the objective, like https://runbooks.example.com/checkout, for receivers of its alerts to follow.
+	RunbookURL string `json:"runbookURL,omitempty"`
+
+	// +optional
+	// Tier is the criticality of the service, like tier-1, to route the alerts of critical services differently.
+	Tier string `json:"tier,omitempty"`
+}
+
+// SLA is a service level agreement, the contractual target of the objective.
+type SLA struct {
+	// Target is a string that's casted to a float64 between 0 - 100.
+	// It must not be higher than the objective's target, which is kept as the internal buffer.
+	Target string `json:"target"`
+}
+
+func (s SLA) validate(objectiveTarget float64) error {
+	if s.Target == "" {
+		return fmt.Errorf("sla target must be set")
+	}
+	target, err := strconv.ParseFloat(s.Target, 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse sla target: %w", err)
+	}
+	if target < 0 || target > 100 {
+		return fmt.Errorf("sla target must be between 0 and 100")
+	}
+	if target > objectiveTarget {
+		return fmt.Errorf("sla target %v must not be higher than the objective's target %v", target, objectiveTarget)
+	}
+	return nil
+}
+
+var slackChannel = regexp.MustCompile(`^#[a-z0-9][a-z0-9._-]{0,79}$`)
+
+// grafanaUID is what Grafana allows as UIDs of datasources.
+var grafanaUID = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,40}$`)
+
+func (o Owner) validate() error {
+	if o.Team == "" {
+		return fmt.Errorf("owner team must be set")
+	}
+	if o.Slack != "" && !slackChannel.MatchString(o.Slack) {
+		return fmt.Errorf("owner slack must be a channel like #team-alerts, got %q", o.Slack)
+	}
+	if o.RunbookURL != "" {
+		u, err := url.Parse(o.RunbookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("owner runbookURL must be an http or https URL, got %q", o.RunbookURL)
+		}
+	}
+	if o.Tier != "" && !model.LabelValue(o.Tier).IsValid() {
+		return fmt.Errorf("owner tier %q is not a valid label value", o.Tier)
+	}
+	return nil
+}
+
+func (o Owner) alertLabels() map[string]string {
+	labels := map[string]string{"team": o.Team}
+	if o.Slack != "" {
+		labels["slack_channel"] = o.Slack
+	}
+	if o.EscalationPolicy != "" {
+		labels["escalation_policy"] = o.EscalationPolicy
+	}
+	if o.Tier != "" {
+		labels["tier"] = o.Tier
+	}
+	return labels
+}
+
+func (o Owner) alertAnnotations() map[string]string {
+	if o.RunbookURL == "" {
+		return nil
+	}
+	return map[string]string{"runbook_url": o.RunbookURL}
+}
+
+func (o Owner) internal() *slo.Owner {
+	return &slo.Owner{
+		Team:             o.Team,
+		Slack:            o.Slack,
+		EscalationPolicy: o.EscalationPolicy,
+		RunbookURL:       o.RunbookURL,
+		Tier:             o.Tier,
+	}
 }
 
 // ServiceLevelIndicator defines the underlying indicator that is a Prometheus metric.
@@ -111,6 +293,41 @@ type ServiceLevelIndicator struct {
 	// BoolGauge is the indicator that measures whether a boolean gauge is
 	// successful.
 	BoolGauge *BoolGaugeIndicator `json:"bool_gauge,omitempty"`
+
+	// +optional
+	// Quantile is the indicator that measures how often a quantile of the latency, like the p99,
+	// is faster than the expected latency. It uses classic or native histograms in Prometheus.
+	Quantile *QuantileIndicator `json:"quantile,omitempty"`
+
+	// +optional
+	// Expression is the indicator that measures against the ratio of two PromQL expressions,
+	// for errors and total events counted by different metrics.
+	Expression *ExpressionIndicator `json:"expression,omitempty"`
+
+	// +optional
+	// Logs is the indicator that measures against the ratio of the lines of two LogQL log queries,
+	// for services that only log their requests. Its rules are evaluated by the Loki ruler.
+	Logs *LogsIndicator `json:"logs,omitempty"`
+
+	// +optional
+	// Composite is the indicator that combines other objectives in the same namespace,
+	// like the steps of a user journey, into one with their weighted error ratio.
+	Composite *CompositeIndicator `json:"composite,omitempty"`
+
+	// +optional
+	// Istio is a preset for services in an Istio service mesh.
+	// It expands into a ratio or latency indicator on Istio's standard metrics.
+	Istio *IstioIndicator `json:"istio,omitempty"`
+
+	// +optional
+	// Linkerd is a preset for workloads in a Linkerd service mesh.
+	// It expands into a ratio or latency indicator on the Linkerd proxy's metrics.
+	Linkerd *LinkerdIndicator `json:"linkerd,omitempty"`
+
+	// +optional
+	// GRPC is a preset for gRPC servers.
+	// It expands into a ratio or latency indicator on the gRPC server metrics.
+	GRPC *GRPCIndicator `json:"grpc,omitempty"`
 }
 
 type Alerting struct {
@@ -134,6 +351,445 @@ type Alerting struct {
 	// +optional
 	// AbsentName is used as the name of the absent alert generated by Pyrra. Defaults to "SLOMetricAbsent".
 	AbsentName string `json:"absentName,omitempty"`
+
+	// +optional
+	// AbsentSeverity is the severity label of the absent alerts. Defaults to critical.
+	AbsentSeverity string `json:"absentSeverity,omitempty"`
+
+	// +optional
+	// AbsentOverTime makes the absent alerts fire once the metrics had no samples for the duration, like 15m,
+	// instead of as soon as they are absent or stale, for metrics that are scraped or pushed rarely.
+	AbsentOverTime string `json:"absentOverTime,omitempty"`
+
+	// +optional
+	// PagerDuty routes the alerts to a PagerDuty service with an urgency per severity.
+	PagerDuty *PagerDutyAlerting `json:"pagerduty,omitempty"`
+
+	// +optional
+	// Opsgenie sets the priority per severity and tags of the alerts for Opsgenie.
+	Opsgenie *OpsgenieAlerting `json:"opsgenie,omitempty"`
+
+	// +optional
+	// MuteWindows are recurring windows during which the burn rate alerts don't fire,
+	// like nightly batch jobs that are known to cause errors. The errors still consume the error budget.
+	MuteWindows []MuteWindow `json:"muteWindows,omitempty"`
+
+	// +optional
+	// MaintenanceWindows are planned downtimes during which the burn rate alerts don't fire.
+	// The errors still consume the error budget.
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+
+	// +optional
+	// Labels are added to all alerts of the objective, to route them in Alertmanager.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// +optional
+	// Annotations are added to all alerts of the objective, like a runbook_url.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// +optional
+	// Windows replace the multi-window multi-burn-rate alerts, which are derived from the objective's window by default.
+	// Each window alerts if the error budget burns faster than its factor over both its short and long window.
+	Windows []AlertingWindow `json:"windows,omitempty"`
+
+	// +optional
+	// KeepFiringFor keeps the burn rate alerts firing for the duration after their condition cleared, like 10m,
+	// so they don't flap while the burn rate is close to the threshold.
+	// Grafana alert rules only support it from Grafana 11.
+	KeepFiringFor string `json:"keepFiringFor,omitempty"`
+
+	// +optional
+	// +kubebuilder:validation:Enum=warn;abort
+	// PartialResponseStrategy is the partial_response_strategy of all the objective's rule groups.
+	// Only Thanos Ruler uses it, Prometheus ignores it.
+	PartialResponseStrategy string `json:"partialResponseStrategy,omitempty"`
+
+	// +optional
+	// Inhibit adds a burn_window label to the burn rate alerts, fast for the critical and slow for the other ones,
+	// so the fast burning alert can inhibit the slow burning ones of the objective in Alertmanager.
+	// The operator generates the inhibition rules as AlertmanagerConfig with --alertmanager-inhibit-rules.
+	Inhibit bool `json:"inhibit,omitempty"`
+}
+
+// AlertingWindow is a multi-window multi-burn-rate alert,
+// like the critical alert for burning 14 times the error budget over 5m and 1h for a 28d window.
+type AlertingWindow struct {
+	// Severity is the severity label of the alert, like critical, warning or ticket.
+	// PagerDuty and Opsgenie routing only knows about critical and warning.
+	Severity string `json:"severity"`
+
+	// Short is the short window the burn rate is checked over, like 5m.
+	// It makes the alert resolve quickly once the errors stop.
+	Short string `json:"short"`
+
+	// Long is the long window the burn rate is checked over, like 1h. It must be longer than short.
+	Long string `json:"long"`
+
+	// Factor is a string that's casted to a float64 of how many times faster than sustainable the error budget burns, like 14.
+	Factor string `json:"factor"`
+
+	// +optional
+	// For is how long both burn rates have to be above the factor for the alert to fire. Defaults to half of short.
+	For string `json:"for,omitempty"`
+
+	// +optional
+	// Labels are added to the alert of this window and take precedence over the labels of all alerts.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// +optional
+	// Annotations are added to the alert of this window and take precedence over the annotations of all alerts.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// reservedAlertLabels are set by Pyrra to tell the alerts apart, so they can't be overridden.
+var reservedAlertLabels = []string{"alertname", "exhaustion", "long", "severity", "short", "slo"}
+
+// validateAlertLabels returns an error if the labels aren't valid Prometheus labels or override the reserved ones.
+func validateAlertLabels(field string, alertLabels map[string]string) error {
+	for name := range alertLabels {
+		if !model.LabelName(name).IsValid() {
+			return fmt.Errorf("%s label name %q is invalid", field, name)
+		}
+		if slices.Contains(reservedAlertLabels, name) {
+			return fmt.Errorf("%s must not set the %s label, which is set by Pyrra", field, name)
+		}
+	}
+	return nil
+}
+
+// ValidateExternalLabels returns an error if the external labels, set by field, aren't valid Prometheus labels or override the reserved ones.
+func ValidateExternalLabels(field string, externalLabels map[string]string) error {
+	return validateAlertLabels(field, externalLabels)
+}
+
+// internal returns the alerting window of the objective with the target and window.
+func (aw AlertingWindow) internal(target float64, window time.Duration) (slo.Window, error) {
+	if aw.Severity == "" {
+		return slo.Window{}, fmt.Errorf("alerting window severity must be set")
+	}
+	if err := validateAlertLabels("alerting window", aw.Labels); err != nil {
+		return slo.Window{}, err
+	}
+	short, err := model.ParseDuration(aw.Short)
+	if err != nil {
+		return slo.Window{}, fmt.Errorf("failed to parse alerting window short %q: %w", aw.Short, err)
+	}
+	long, err := model.ParseDuration(aw.Long)
+	if err != nil {
+		return slo.Window{}, fmt.Errorf("failed to parse alerting window long %q: %w", aw.Long, err)
+	}
+	if short <= 0 || long <= short {
+		return slo.Window{}, fmt.Errorf("alerting window long %s must be longer than short %s", aw.Long, aw.Short)
+	}
+	if time.Duration(long) > window {
+		return slo.Window{}, fmt.Errorf("alerting window long %s must not be longer than the objective's window", aw.Long)
+	}
+
+	factor, err := strconv.ParseFloat(aw.Factor, 64)
+	if err != nil {
+		return slo.Window{}, fmt.Errorf("failed to parse alerting window factor %q: %w", aw.Factor, err)
+	}
+	if factor <= 0 {
+		return slo.Window{}, fmt.Errorf("alerting window factor must be greater than 0")
+	}
+	// The error ratio can't exceed 1, so the alert could never fire.
+	if factor*(1-target) >= 1 {
+		return slo.Window{}, fmt.Errorf("alerting window factor %s must be below %.4g, as the error ratio can never burn the error budget faster", aw.Factor, 1/(1-target))
+	}
+
+	forDuration := time.Duration(short) / 2
+	if aw.For != "" {
+		f, err := model.ParseDuration(aw.For)
+		if err != nil {
+			return slo.Window{}, fmt.Errorf("failed to parse alerting window for %q: %w", aw.For, err)
+		}
+		forDuration = time.Duration(f)
+	}
+
+	return slo.Window{
+		Severity:    slo.Severity(aw.Severity),
+		For:         forDuration,
+		Long:        time.Duration(long),
+		Short:       time.Duration(short),
+		Factor:      factor,
+		Labels:      aw.Labels,
+		Annotations: aw.Annotations,
+	}, nil
+}
+
+// alertingWindows returns the alerting windows of the objective with the target from 0 to 1 and window.
+// Windows must not repeat the same short and long windows, as their alerts would be the same.
+func alertingWindows(windows []AlertingWindow, target float64, window time.Duration) ([]slo.Window, error) {
+	var ws []slo.Window
+	seen := map[[2]time.Duration]bool{}
+	for _, aw := range windows {
+		w, err := aw.internal(target, window)
+		if err != nil {
+			return nil, err
+		}
+		if seen[[2]time.Duration{w.Short, w.Long}] {
+			return nil, fmt.Errorf("alerting windows must not repeat the short %s and long %s windows", aw.Short, aw.Long)
+		}
+		seen[[2]time.Duration{w.Short, w.Long}] = true
+		ws = append(ws, w)
+	}
+	return ws, nil
+}
+
+// MuteWindow is a recurring window in UTC, like the time intervals of Alertmanager.
+type MuteWindow struct {
+	// +optional
+	// Weekdays the window applies to, like monday or saturday. Defaults to every day.
+	Weekdays []string `json:"weekdays,omitempty"`
+
+	// StartTime is the time of day the window starts at in UTC, like 02:00.
+	StartTime string `json:"startTime"`
+
+	// EndTime is the time of day the window ends at in UTC, like 04:00 or 24:00.
+	// Windows crossing midnight have to be split into two.
+	EndTime string `json:"endTime"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+func (mw MuteWindow) internal() (slo.MuteWindow, error) {
+	start, err := parseTimeOfDay(mw.StartTime)
+	if err != nil {
+		return slo.MuteWindow{}, fmt.Errorf("mute window startTime: %w", err)
+	}
+	end, err := parseTimeOfDay(mw.EndTime)
+	if err != nil {
+		return slo.MuteWindow{}, fmt.Errorf("mute window endTime: %w", err)
+	}
+	if end <= start {
+		return slo.MuteWindow{}, fmt.Errorf("mute window endTime %s must be after startTime %s, split windows crossing midnight into two", mw.EndTime, mw.StartTime)
+	}
+
+	window := slo.MuteWindow{Start: start, End: end}
+	for _, day := range mw.Weekdays {
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return slo.MuteWindow{}, fmt.Errorf("mute window weekday must be one of monday to sunday, got %q", day)
+		}
+		window.Weekdays = append(window.Weekdays, weekday)
+	}
+	return window, nil
+}
+
+// MaintenanceWindow is a one-off interval of planned downtime.
+type MaintenanceWindow struct {
+	// Start of the downtime in RFC3339, like 2024-06-01T22:00:00Z.
+	Start string `json:"start"`
+
+	// End of the downtime in RFC3339, like 2024-06-02T02:00:00+02:00.
+	End string `json:"end"`
+}
+
+func (mw MaintenanceWindow) internal() (slo.MaintenanceWindow, error) {
+	start, err := time.Parse(time.RFC3339, mw.Start)
+	if err != nil {
+		return slo.MaintenanceWindow{}, fmt.Errorf("maintenance window start must be RFC3339: %w", err)
+	}
+	end, err := time.Parse(time.RFC3339, mw.End)
+	if err != nil {
+		return slo.MaintenanceWindow{}, fmt.Errorf("maintenance window end must be RFC3339: %w", err)
+	}
+	if !end.After(start) {
+		return slo.MaintenanceWindow{}, fmt.Errorf("maintenance window end %s must be after start %s", mw.End, mw.Start)
+	}
+	return slo.MaintenanceWindow{Start: start, End: end}, nil
+}
+
+// parseTimeOfDay parses times like 02:00, from 00:00 to 24:00.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	var hours, minutes int
+	if _, err := fmt.Sscanf(s, "%d:%d", &hours, &minutes); err != nil || len(s) != 5 {
+		return 0, fmt.Errorf("time must be formatted as HH:MM, got %q", s)
+	}
+	d := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute
+	if hours < 0 || minutes < 0 || minutes > 59 || d > 24*time.Hour {
+		return 0, fmt.Errorf("time must be between 00:00 and 24:00, got %q", s)
+	}
+	return d, nil
+}
+
+// PagerDutyAlerting adds the pagerduty_service and pagerduty_urgency labels to alerts,
+// which Alertmanager can route to the matching PagerDuty integration.
+type PagerDutyAlerting struct {
+	// Service is the name of the PagerDuty service the alerts are routed to.
+	Service string `json:"service"`
+
+	// +optional
+	// Urgency maps the severity of alerts to a PagerDuty urgency, either high or low.
+	// Defaults to high for critical and low for warning alerts.
+	Urgency map[string]string `json:"urgency,omitempty"`
+}
+
+var defaultPagerDutyUrgency = map[string]string{
+	"critical": "high",
+	"warning":  "low",
+}
+
+func (pd PagerDutyAlerting) validate() error {
+	if pd.Service == "" {
+		return fmt.Errorf("pagerduty service must be set")
+	}
+	for severity, urgency := range pd.Urgency {
+		if _, ok := defaultPagerDutyUrgency[severity]; !ok {
+			return fmt.Errorf("pagerduty urgency severity must be critical or warning, got %q", severity)
+		}
+		if urgency != "high" && urgency != "low" {
+			return fmt.Errorf("pagerduty urgency must be high or low, got %q", urgency)
+		}
+	}
+	return nil
+}
+
+// OpsgenieAlerting adds the opsgenie_priority and opsgenie_tags annotations to alerts,
+// which Alertmanager's opsgenie_configs can use as priority and tags.
+type OpsgenieAlerting struct {
+	// +optional
+	// Priority maps the severity of alerts to an Opsgenie priority, from P1 to P5.
+	// Defaults to P1 for critical and P3 for warning alerts.
+	Priority map[string]string `json:"priority,omitempty"`
+
+	// +optional
+	// Tags are added to all alerts as comma separated list.
+	Tags []string `json:"tags,omitempty"`
+}
+
+var defaultOpsgeniePriority = map[string]string{
+	"critical": "P1",
+	"warning":  "P3",
+}
+
+func (og OpsgenieAlerting) validate() error {
+	for severity, priority := range og.Priority {
+		if _, ok := defaultOpsgeniePriority[severity]; !ok {
+			return fmt.Errorf("opsgenie priority severity must be critical or warning, got %q", severity)
+		}
+		switch priority {
+		case "P1", "P2", "P3", "P4", "P5":
+		default:
+			return fmt.Errorf("opsgenie priority must be one of P1, P2, P3, P4 or P5, got %q", priority)
+		}
+	}
+	for _, tag := range og.Tags {
+		if tag == "" || strings.Contains(tag, ",") {
+			return fmt.Errorf("opsgenie tags must not be empty or contain commas, got %q", tag)
+		}
+	}
+	return nil
+}
+
+func (og OpsgenieAlerting) severityAnnotations() map[string]map[string]string {
+	annotations := make(map[string]map[string]string, len(defaultOpsgeniePriority))
+	for severity, priority := range defaultOpsgeniePriority {
+		if p, ok := og.Priority[severity]; ok {
+			priority = p
+		}
+		annotations[severity] = map[string]string{"opsgenie_priority": priority}
+		if len(og.Tags) > 0 {
+			annotations[severity]["opsgenie_tags"] = strings.Join(og.Tags, ",")
+		}
+	}
+	return annotations
+}
+
+func (pd PagerDutyAlerting) severityLabels() map[string]map[string]string {
+	labels := make(map[string]map[string]string, len(defaultPagerDutyUrgency))
+	for severity, urgency := range defaultPagerDutyUrgency {
+		if u, ok := pd.Urgency[severity]; ok {
+			urgency = u
+		}
+		labels[severity] = map[string]string{
+			"pagerduty_service": pd.Service,
+			"pagerduty_urgency": urgency,
+		}
+	}
+	return labels
+}
+
+// ErrorBudgetPolicy encodes what happens as the error budget runs out,
+// like a change freeze below 25% of the error budget remaining.
+type ErrorBudgetPolicy struct {
+	// Thresholds are evaluated independently, every threshold above the remaining error budget is active.
+	Thresholds []ErrorBudgetThreshold `json:"thresholds"`
+}
+
+type ErrorBudgetThreshold struct {
+	// Remaining is a string that's casted to a float64 between 0 - 100.
+	// The threshold is active while less than this percentage of the error budget remains.
+	Remaining string `json:"remaining"`
+
+	// +optional
+	// Notify emits an event on the ServiceLevelObjective when the threshold becomes active and when it recovers.
+	Notify bool `json:"notify,omitempty"`
+
+	// +optional
+	// Labels are set on the ServiceLevelObjective while the threshold is active,
+	// for deployment pipelines and other tools to select on.
+	// If active thresholds set the same label, the one with the lowest remaining error budget wins.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// +optional
+	// Freeze sets status.budgetPolicy.freeze while the threshold is active, to signal a change freeze.
+	Freeze bool `json:"freeze,omitempty"`
+
+	// +optional
+	// Silence are the severities of the objective's burn rate alerts that are silenced in Alertmanager while the threshold is active,
+	// like warning at 0 remaining to stop paging once the error budget is gone. Requires the operator's --alertmanager-url.
+	Silence []string `json:"silence,omitempty"`
+}
+
+func (p ErrorBudgetPolicy) validate() error {
+	if len(p.Thresholds) == 0 {
+		return fmt.Errorf("policy thresholds must be set")
+	}
+	for _, t := range p.Thresholds {
+		remaining, err := strconv.ParseFloat(t.Remaining, 64)
+		if err != nil {
+			return fmt.Errorf("policy threshold remaining must be a number: %w", err)
+		}
+		if remaining < 0 || remaining > 100 {
+			return fmt.Errorf("policy threshold remaining must be between 0 and 100, got %s", t.Remaining)
+		}
+		if !t.Notify && !t.Freeze && len(t.Labels) == 0 && len(t.Silence) == 0 {
+			return fmt.Errorf("policy threshold %s must notify, freeze, set labels or silence alerts", t.Remaining)
+		}
+		for _, severity := range t.Silence {
+			if severity == "" {
+				return fmt.Errorf("policy threshold %s must not silence an empty severity", t.Remaining)
+			}
+			if errs := validation.IsValidLabelValue(severity); len(errs) > 0 {
+				return fmt.Errorf("policy threshold silence severity %q is invalid: %s", severity, strings.Join(errs, ", "))
+			}
+		}
+		for name, value := range t.Labels {
+			// Labels with the prefix are propagated to the series of the generated rules, which would change with every threshold.
+			if strings.HasPrefix(name, slo.PropagationLabelsPrefix) {
+				return fmt.Errorf("policy threshold labels must not start with %s, got %q", slo.PropagationLabelsPrefix, name)
+			}
+			if errs := validation.IsQualifiedName(name); len(errs) > 0 {
+				return fmt.Errorf("policy threshold label name %q is invalid: %s", name, strings.Join(errs, ", "))
+			}
+			if value == "" {
+				return fmt.Errorf("policy threshold label %q must have a value", name)
+			}
+			if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+				return fmt.Errorf("policy threshold label value %q is invalid: %s", value, strings.Join(errs, ", "))
+			}
+		}
+	}
+	return nil
 }
 
 type RatioIndicator struct {
@@ -176,15 +832,174 @@ type BoolGaugeIndicator struct {
 	Grouping []string `json:"grouping"`
 }
 
+// QuantileIndicator is how often a quantile of the latency over an interval is faster than the latency,
+// like the p99 latency over 5m being faster than 500ms for 99% of the time.
+// Intervals without any requests are left out.
+type QuantileIndicator struct {
+	// Total is the histogram of the latency of all requests.
+	// It is a classic histogram if the metric ends with _bucket and a native histogram otherwise.
+	Total Query `json:"total"`
+
+	// Quantile of the latency, like 0.99 for the p99.
+	Quantile string `json:"quantile"`
+
+	// Latency the quantile should be faster than.
+	Latency string `json:"latency"`
+
+	// +optional
+	// Interval the quantile is calculated over, 5m by default.
+	Interval string `json:"interval,omitempty"`
+
+	// +optional
+	// Grouping allows an SLO to be defined for many SLI at once, like HTTP handlers for example.
+	Grouping []string `json:"grouping"`
+}
+
+// defaultQuantileInterval is the interval the quantiles are calculated over if it isn't set.
+const defaultQuantileInterval = "5m"
+
+// interval returns the interval the quantile is calculated over.
+func (q QuantileIndicator) interval() (model.Duration, error) {
+	if q.Interval == "" {
+		return model.ParseDuration(defaultQuantileInterval)
+	}
+	return model.ParseDuration(q.Interval)
+}
+
+// ExpressionIndicator is the ratio of the errors and total PromQL expressions.
+// The rules wrap each series selector of the expressions in increase or rate over their windows,
+// so the expressions select counters as instant vectors, like `a_errors_total + b_errors_total`.
+type ExpressionIndicator struct {
+	// Errors is the expression that returns how many errors there are.
+	Errors string `json:"errors"`
+	// Total is the expression that returns how many requests there are in total.
+	Total string `json:"total"`
+	// +optional
+	// Grouping allows an SLO to be defined for many SLI at once, like HTTP handlers for example.
+	Grouping []string `json:"grouping"`
+}
+
+// LogsIndicator is the ratio of the lines of the errors and total LogQL log queries.
+// The queries are stream selectors optionally followed by a pipeline, like `{job="app"} |= "error"`,
+// whose lines the rules count over their windows. Objectives with it need the pyrra.dev/ruler: loki annotation.
+type LogsIndicator struct {
+	// Errors is the log query whose lines are errors.
+	Errors string `json:"errors"`
+	// Total is the log query whose lines are all requests.
+	Total string `json:"total"`
+	// +optional
+	// Grouping allows an SLO to be defined for many SLI at once, like HTTP handlers for example.
+	Grouping []string `json:"grouping"`
+}
+
+// CompositeIndicator is the weighted average of the error ratios of other objectives in the same namespace.
+// Only objectives with a single ratio evaluated by Prometheus can be combined, so no objectives with grouping,
+// logs indicators or other composites. Its rules are written again whenever one of the objectives changes.
+type CompositeIndicator struct {
+	// Objectives are the names of the combined objectives and their weights.
+	Objectives []CompositeObjective `json:"objectives"`
+}
+
+// CompositeObjective is an objective combined by a CompositeIndicator.
+type CompositeObjective struct {
+	// Name of the objective in the composite's namespace.
+	Name string `json:"name"`
+	// +optional
+	// Weight of the objective's error ratio relative to the other objectives', 1 by default.
+	Weight string `json:"weight,omitempty"`
+
+	// Objective is the combined objective, set by the operator before generating the composite's rules.
+	Objective *ServiceLevelObjective `json:"-"`
+}
+
+// weight returns the parsed weight of the objective.
+func (in CompositeObjective) weight() (float64, error) {
+	if in.Weight == "" {
+		return 1, nil
+	}
+	w, err := strconv.ParseFloat(in.Weight, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse weight of objective %s: %w", in.Name, err)
+	}
+	if w <= 0 || math.IsInf(w, 0) || math.IsNaN(w) {
+		return 0, fmt.Errorf("weight of objective %s must be greater than 0", in.Name)
+	}
+	return w, nil
+}
+
 // Query contains a PromQL metric.
 type Query struct {
 	Metric string `json:"metric"`
 }
 
+// Condition types of ServiceLevelObjectives.
+const (
+	// ConditionReady is true once the rules of the objective's current generation are written.
+	ConditionReady = "Ready"
+	// ConditionRulesWritten is false if writing the generated rules failed.
+	ConditionRulesWritten = "RulesWritten"
+	// ConditionValidationFailed is true if no rules can be generated from the spec.
+	ConditionValidationFailed = "ValidationFailed"
+	// ConditionRulerSynced is the outcome of the last push of the rule groups to a ruler's API,
+	// only set for objectives whose rules are pushed to one.
+	ConditionRulerSynced = "RulerSynced"
+	// ConditionDegraded is true if writing to some of the outputs failed while the others were written,
+	// only set for objectives that are degraded.
+	ConditionDegraded = "Degraded"
+	// ConditionHighCardinality is true if the objective's grouping results in more series than the operator allows,
+	// only set for objectives whose series were estimated.
+	ConditionHighCardinality = "HighCardinality"
+	// ConditionClusterSyncedPrefix and the name of a remote cluster, like ClusterSynced-eu1,
+	// is the outcome of the last write of the objective's PrometheusRule to the remote cluster,
+	// only set for the remote clusters the objective is written to.
+	ConditionClusterSyncedPrefix = "ClusterSynced-"
+	// ConditionPaused is true while the objective is paused and its rules aren't updated,
+	// only set for objectives that are or were paused.
+	ConditionPaused = "Paused"
+)
+
 // ServiceLevelObjectiveStatus defines the observed state of ServiceLevelObjective.
 type ServiceLevelObjectiveStatus struct {
 	// Type is the generated resource type, like PrometheusRule or ConfigMap
 	Type string `json:"type,omitempty"`
+
+	// +optional
+	// RuleName is the name of the generated resource, like the PrometheusRule or ConfigMap in the objective's namespace.
+	RuleName string `json:"ruleName,omitempty"`
+
+	// +optional
+	// ObservedGeneration is the generation of the objective the status was last reconciled for.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// Conditions are the Ready, RulesWritten and ValidationFailed conditions of the objective.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// +optional
+	// BudgetPolicy is the state of the error budget policy as last evaluated.
+	BudgetPolicy *BudgetPolicyStatus `json:"budgetPolicy,omitempty"`
+
+	// +optional
+	// Window is the window the rules were last written for.
+	Window string `json:"window,omitempty"`
+
+	// +optional
+	// StableRuleNames is true if the rules were last written with stable rule names.
+	StableRuleNames bool `json:"stableRuleNames,omitempty"`
+}
+
+// BudgetPolicyStatus is the state of the error budget policy as last evaluated.
+// It only changes as thresholds become active or recover, not with every change of the remaining error budget.
+type BudgetPolicyStatus struct {
+	// +optional
+	// Thresholds are the remaining values of the active thresholds.
+	Thresholds []string `json:"thresholds,omitempty"`
+
+	// +optional
+	// Freeze is true while an active threshold freezes changes.
+	Freeze bool `json:"freeze,omitempty"`
 }
 
 func (in *ServiceLevelObjective) ValidateCreate() (admission.Warnings, error) {
@@ -208,15 +1023,18 @@ func (in *ServiceLevelObjective) validate() (admission.Warnings, error) {
 	if in.GetNamespace() == "" {
 		warnings = append(warnings, "namespace must be set")
 	}
+	if uid, ok := in.GetAnnotations()["pyrra.dev/grafana-datasource-uid"]; ok && !grafanaUID.MatchString(uid) {
+		return warnings, fmt.Errorf("pyrra.dev/grafana-datasource-uid %q must be at most 40 letters, digits, _ and -", uid)
+	}
 
 	if in.Spec.Target == "" {
 		return warnings, fmt.Errorf("target must be set")
 	}
 	target, err := strconv.ParseFloat(in.Spec.Target, 64)
 	if err != nil {
-		return warnings, err
+		return warnings, fmt.Errorf("failed to parse target %q, it must be a percentage like 99.5: %w", in.Spec.Target, err)
 	}
-	if target < 0 || target > 100 {
+	if target <= 0 || target > 100 {
 		return warnings, fmt.Errorf("target must be between 0 and 100")
 	}
 	if target > 0 && target < 1 {
@@ -226,20 +1044,146 @@ func (in *ServiceLevelObjective) validate() (admission.Warnings, error) {
 	if in.Spec.Window == "" {
 		return warnings, fmt.Errorf("window must be set")
 	}
-	_, err = model.ParseDuration(in.Spec.Window)
+	window, err := model.ParseDuration(in.Spec.Window)
+	if err != nil {
+		return warnings, fmt.Errorf("failed to parse window %q, it must be a duration like 28d: %w", in.Spec.Window, err)
+	}
+	if window <= 0 {
+		return warnings, fmt.Errorf("window must be longer than 0")
+	}
+
+	if in.Spec.Alerting.PagerDuty != nil {
+		if err := in.Spec.Alerting.PagerDuty.validate(); err != nil {
+			return warnings, err
+		}
+	}
+
+	if in.Spec.Alerting.Opsgenie != nil {
+		if err := in.Spec.Alerting.Opsgenie.validate(); err != nil {
+			return warnings, err
+		}
+	}
+
+	if in.Spec.Owner != nil {
+		if err := in.Spec.Owner.validate(); err != nil {
+			return warnings, err
+		}
+		// The team label is propagated to all rules, including the alerts.
+		if team, ok := in.GetLabels()[slo.PropagationLabelsPrefix+"team"]; ok && team != in.Spec.Owner.Team {
+			return warnings, fmt.Errorf("owner team %q must match the %steam label %q", in.Spec.Owner.Team, slo.PropagationLabelsPrefix, team)
+		}
+	}
+
+	if in.Spec.SLA != nil {
+		if err := in.Spec.SLA.validate(target); err != nil {
+			return warnings, err
+		}
+	}
+
+	if in.Spec.Calendar != "" {
+		if err := slo.Calendar(in.Spec.Calendar).Validate(); err != nil {
+			return warnings, err
+		}
+	}
+
+	for _, mw := range in.Spec.Alerting.MuteWindows {
+		if _, err := mw.internal(); err != nil {
+			return warnings, err
+		}
+	}
+	for _, mw := range in.Spec.Alerting.MaintenanceWindows {
+		if _, err := mw.internal(); err != nil {
+			return warnings, err
+		}
+	}
+
+	if err := validateAlertLabels("alerting", in.Spec.Alerting.Labels); err != nil {
+		return warnings, err
+	}
+	if err := ValidateExternalLabels("externalLabels", in.Spec.ExternalLabels); err != nil {
+		return warnings, err
+	}
+	if in.Spec.Owner != nil {
+		for name := range in.Spec.Owner.alertLabels() {
+			if _, ok := in.Spec.Alerting.Labels[name]; ok {
+				return warnings, fmt.Errorf("alerting must not set the %s label, which is set by the owner", name)
+			}
+		}
+		for name := range in.Spec.Owner.alertAnnotations() {
+			if _, ok := in.Spec.Alerting.Annotations[name]; ok {
+				return warnings, fmt.Errorf("alerting must not set the %s annotation, which is set by the owner", name)
+			}
+		}
+	}
+	windows, err := alertingWindows(in.Spec.Alerting.Windows, target/100, time.Duration(window))
 	if err != nil {
 		return warnings, err
 	}
+	if in.Spec.RuleGroupInterval != "" {
+		interval, err := model.ParseDuration(in.Spec.RuleGroupInterval)
+		if err != nil {
+			return warnings, fmt.Errorf("failed to parse ruleGroupInterval %q, it must be a duration like 1m: %w", in.Spec.RuleGroupInterval, err)
+		}
+		if interval <= 0 {
+			return warnings, fmt.Errorf("ruleGroupInterval must be greater than 0")
+		}
+		if len(windows) == 0 {
+			windows = slo.Windows(time.Duration(window))
+		}
+		for _, w := range windows {
+			// Rules evaluated less often than the short window would miss its burn rate.
+			if time.Duration(interval) > w.Short {
+				return warnings, fmt.Errorf("ruleGroupInterval %s must not be longer than the short alerting window %s", interval, model.Duration(w.Short))
+			}
+		}
+	}
+	if in.Spec.RuleGroupLimit < 0 {
+		return warnings, fmt.Errorf("ruleGroupLimit must not be negative")
+	}
+	if in.Spec.Alerting.KeepFiringFor != "" {
+		keepFiringFor, err := model.ParseDuration(in.Spec.Alerting.KeepFiringFor)
+		if err != nil {
+			return warnings, fmt.Errorf("failed to parse alerting keepFiringFor %q, it must be a duration like 10m: %w", in.Spec.Alerting.KeepFiringFor, err)
+		}
+		if time.Duration(keepFiringFor) > time.Duration(window) {
+			return warnings, fmt.Errorf("alerting keepFiringFor %s must not be longer than the objective's window", keepFiringFor)
+		}
+	}
+	if in.Spec.Alerting.AbsentSeverity != "" && !model.LabelValue(in.Spec.Alerting.AbsentSeverity).IsValid() {
+		return warnings, fmt.Errorf("alerting absentSeverity %q is not a valid label value", in.Spec.Alerting.AbsentSeverity)
+	}
+	if in.Spec.Alerting.AbsentOverTime != "" {
+		overTime, err := model.ParseDuration(in.Spec.Alerting.AbsentOverTime)
+		if err != nil {
+			return warnings, fmt.Errorf("failed to parse alerting absentOverTime %q, it must be a duration like 15m: %w", in.Spec.Alerting.AbsentOverTime, err)
+		}
+		if overTime <= 0 || time.Duration(overTime) > time.Duration(window) {
+			return warnings, fmt.Errorf("alerting absentOverTime %s must be greater than 0 and not longer than the objective's window", overTime)
+		}
+	}
+	switch in.Spec.Alerting.PartialResponseStrategy {
+	case "", "warn", "abort":
+	default:
+		return warnings, fmt.Errorf("alerting partialResponseStrategy must be warn or abort, got %q", in.Spec.Alerting.PartialResponseStrategy)
+	}
+
+	if in.Spec.Policy != nil {
+		if err := in.Spec.Policy.validate(); err != nil {
+			return warnings, err
+		}
+	}
+
+	if in.Spec.ServiceLevelIndicator.count() == 0 {
+		return warnings, fmt.Errorf("one of ratio, latency, latencyNative, bool_gauge, expression, logs, composite, istio, linkerd or grpc must be set")
+	}
 
-	if in.Spec.ServiceLevelIndicator.Ratio == nil &&
-		in.Spec.ServiceLevelIndicator.Latency == nil &&
-		in.Spec.ServiceLevelIndicator.LatencyNative == nil &&
-		in.Spec.ServiceLevelIndicator.BoolGauge == nil {
-		return warnings, fmt.Errorf("one of ratio, latency, latencyNative or bool_gauge must be set")
+	indicator, err := in.Spec.ServiceLevelIndicator.expandPresets(in.GetNamespace())
+	if err != nil {
+		return warnings, err
 	}
 
-	if in.Spec.ServiceLevelIndicator.Ratio != nil {
-		ratio := in.Spec.ServiceLevelIndicator.Ratio
+	if indicator.Ratio != nil {
+		ratio := indicator.Ratio
 		if ratio.Total.Metric == "" {
 			return warnings, fmt.Errorf("ratio total metric must be set")
 		}
@@ -261,8 +1205,8 @@ func (in *ServiceLevelObjective) validate() (admission.Warnings, error) {
 		}
 	}
 
-	if in.Spec.ServiceLevelIndicator.Latency != nil {
-		latency := in.Spec.ServiceLevelIndicator.Latency
+	if indicator.Latency != nil {
+		latency := indicator.Latency
 		if latency.Total.Metric == "" {
 			return warnings, fmt.Errorf("latency total metric must be set")
 		}
@@ -318,8 +1262,8 @@ func (in *ServiceLevelObjective) validate() (admission.Warnings, error) {
 		}
 	}
 
-	if in.Spec.ServiceLevelIndicator.LatencyNative != nil {
-		latencyNative := in.Spec.ServiceLevelIndicator.LatencyNative
+	if indicator.LatencyNative != nil {
+		latencyNative := indicator.LatencyNative
 		if latencyNative.Total.Metric == "" {
 			return warnings, fmt.Errorf("latencyNative total metric must be set")
 		}
@@ -337,8 +1281,8 @@ func (in *ServiceLevelObjective) validate() (admission.Warnings, error) {
 		}
 	}
 
-	if in.Spec.ServiceLevelIndicator.BoolGauge != nil {
-		boolGauge := in.Spec.ServiceLevelIndicator.BoolGauge
+	if indicator.BoolGauge != nil {
+		boolGauge := indicator.BoolGauge
 		if boolGauge.Query.Metric == "" {
 			return warnings, fmt.Errorf("boolGauge metric must be set")
 		}
@@ -348,9 +1292,164 @@ func (in *ServiceLevelObjective) validate() (admission.Warnings, error) {
 		}
 	}
 
+	if indicator.Quantile != nil {
+		quantile := indicator.Quantile
+		if quantile.Total.Metric == "" {
+			return warnings, fmt.Errorf("quantile total metric must be set")
+		}
+
+		expr, err := parser.ParseExpr(quantile.Total.Metric)
+		if err != nil {
+			return warnings, fmt.Errorf("failed to parse quantile total metric: %w", err)
+		}
+		if _, ok := expr.(*parser.VectorSelector); !ok {
+			return warnings, fmt.Errorf("quantile total metric must be a series selector")
+		}
+
+		q, err := strconv.ParseFloat(quantile.Quantile, 64)
+		if err != nil {
+			return warnings, fmt.Errorf("quantile must be a float: %w", err)
+		}
+		if q <= 0 || q >= 1 {
+			return warnings, fmt.Errorf("quantile must be between 0 and 1, like 0.99")
+		}
+
+		if quantile.Latency == "" {
+			return warnings, fmt.Errorf("quantile latency must be set")
+		}
+		if _, err := model.ParseDuration(quantile.Latency); err != nil {
+			return warnings, fmt.Errorf("quantile latency must be a valid duration: %w", err)
+		}
+
+		interval, err := quantile.interval()
+		if err != nil {
+			return warnings, fmt.Errorf("quantile interval must be a valid duration: %w", err)
+		}
+		if interval <= 0 {
+			return warnings, fmt.Errorf("quantile interval must be greater than 0")
+		}
+	}
+
+	if indicator.Expression != nil {
+		expression := indicator.Expression
+		if expression.Total == "" {
+			return warnings, fmt.Errorf("expression total must be set")
+		}
+		if expression.Errors == "" {
+			return warnings, fmt.Errorf("expression errors must be set")
+		}
+
+		if err := slo.ValidateExpression(expression.Total); err != nil {
+			return warnings, fmt.Errorf("invalid expression total: %w", err)
+		}
+		if err := slo.ValidateExpression(expression.Errors); err != nil {
+			return warnings, fmt.Errorf("invalid expression errors: %w", err)
+		}
+	}
+
+	if indicator.Logs != nil {
+		if err := in.validateLogs(*indicator.Logs); err != nil {
+			return warnings, err
+		}
+	}
+
+	if indicator.Composite != nil {
+		if err := in.validateComposite(*indicator.Composite); err != nil {
+			return warnings, err
+		}
+	}
+
+	// Loki only evaluates LogQL, the PromQL rules of the other indicators can't be pushed to its ruler.
+	if indicator.Logs == nil && in.GetAnnotations()["pyrra.dev/ruler"] == "loki" {
+		return warnings, fmt.Errorf("only logs indicators are evaluated by Loki and can have the pyrra.dev/ruler: loki annotation")
+	}
+
 	return warnings, nil
 }
 
+// validateComposite validates the combined objectives of the composite indicator.
+// Whether they exist and can be combined is only known to the operator, which resolves them.
+func (in *ServiceLevelObjective) validateComposite(composite CompositeIndicator) error {
+	if in.Spec.ServiceLevelIndicator.count() > 1 {
+		return fmt.Errorf("composite cannot be combined with other indicators")
+	}
+	if len(composite.Objectives) == 0 {
+		return fmt.Errorf("composite objectives must be set")
+	}
+	seen := make(map[string]bool, len(composite.Objectives))
+	for _, co := range composite.Objectives {
+		if co.Name == "" {
+			return fmt.Errorf("composite objective name must be set")
+		}
+		if co.Name == in.GetName() {
+			return fmt.Errorf("composite cannot combine itself")
+		}
+		if seen[co.Name] {
+			return fmt.Errorf("composite objective %s is combined more than once", co.Name)
+		}
+		seen[co.Name] = true
+		if _, err := co.weight(); err != nil {
+			return err
+		}
+	}
+	if in.GetAnnotations()["pyrra.dev/ruler"] == "loki" {
+		return fmt.Errorf("composite indicators are evaluated by Prometheus and can't have the pyrra.dev/ruler: loki annotation")
+	}
+	if in.Spec.Calendar != "" {
+		// Composites record the error ratio over the window, there are no increases to sum up since the start of the period.
+		return fmt.Errorf("composite indicators don't support a calendar")
+	}
+	if in.Spec.SharedBurnrates {
+		// The burn rates of composites are only known once the objectives they combine are resolved by the operator.
+		return fmt.Errorf("composite indicators don't support shared burn rates")
+	}
+	return nil
+}
+
+// validateLogs validates the logs indicator and that the objective is evaluated by Loki.
+// LogQL has no time functions for mute windows and the Loki ruler can't query the error budget
+// recorded in Prometheus, which the SLA and budget freeze rules are based on.
+// Neither can it sum up the increases since the start of a calendar period with subqueries,
+// nor are its rules written to the PrometheusRules of shared burn rates.
+func (in *ServiceLevelObjective) validateLogs(logs LogsIndicator) error {
+	if logs.Total == "" {
+		return fmt.Errorf("logs total must be set")
+	}
+	if logs.Errors == "" {
+		return fmt.Errorf("logs errors must be set")
+	}
+	if err := slo.ValidateLogQuery(logs.Total); err != nil {
+		return fmt.Errorf("invalid logs total: %w", err)
+	}
+	if err := slo.ValidateLogQuery(logs.Errors); err != nil {
+		return fmt.Errorf("invalid logs errors: %w", err)
+	}
+
+	if in.GetAnnotations()["pyrra.dev/ruler"] != "loki" {
+		return fmt.Errorf("logs indicators are evaluated by Loki and need the pyrra.dev/ruler: loki annotation")
+	}
+	if len(in.Spec.Alerting.MuteWindows) > 0 || len(in.Spec.Alerting.MaintenanceWindows) > 0 {
+		return fmt.Errorf("logs indicators don't support mute or maintenance windows")
+	}
+	if in.Spec.SLA != nil {
+		return fmt.Errorf("logs indicators don't support an SLA")
+	}
+	if in.Spec.Calendar != "" {
+		return fmt.Errorf("logs indicators don't support a calendar")
+	}
+	if in.Spec.SharedBurnrates {
+		return fmt.Errorf("logs indicators don't support shared burn rates")
+	}
+	if in.Spec.Policy != nil {
+		for _, t := range in.Spec.Policy.Thresholds {
+			if t.Freeze {
+				return fmt.Errorf("logs indicators don't support policy thresholds that freeze changes")
+			}
+		}
+	}
+	return nil
+}
+
 func (in *ServiceLevelObjective) Internal() (slo.Objective, error) {
 	target, err := strconv.ParseFloat(in.Spec.Target, 64)
 	if err != nil {
@@ -381,14 +1480,108 @@ func (in *ServiceLevelObjective) Internal() (slo.Objective, error) {
 	if in.Spec.Alerting.AbsentName != "" {
 		alerting.AbsentName = in.Spec.Alerting.AbsentName
 	}
+	alerting.AbsentSeverity = in.Spec.Alerting.AbsentSeverity
+	if in.Spec.Alerting.AbsentOverTime != "" {
+		overTime, err := model.ParseDuration(in.Spec.Alerting.AbsentOverTime)
+		if err != nil {
+			return slo.Objective{}, fmt.Errorf("failed to parse alerting absentOverTime: %w", err)
+		}
+		alerting.AbsentOverTime = time.Duration(overTime)
+	}
+	if in.Spec.Alerting.PagerDuty != nil {
+		alerting.SeverityLabels = in.Spec.Alerting.PagerDuty.severityLabels()
+	}
+	if in.Spec.Alerting.Opsgenie != nil {
+		alerting.SeverityAnnotations = in.Spec.Alerting.Opsgenie.severityAnnotations()
+	}
+	if in.Spec.Owner != nil {
+		alerting.Labels = in.Spec.Owner.alertLabels()
+	}
+	if len(in.Spec.Alerting.Labels) > 0 {
+		if alerting.Labels == nil {
+			alerting.Labels = make(map[string]string, len(in.Spec.Alerting.Labels))
+		}
+		for name, value := range in.Spec.Alerting.Labels {
+			alerting.Labels[name] = value
+		}
+	}
+	alerting.Annotations = in.Spec.Alerting.Annotations
+	if in.Spec.Owner != nil {
+		if annotations := in.Spec.Owner.alertAnnotations(); len(annotations) > 0 {
+			for name, value := range in.Spec.Alerting.Annotations {
+				annotations[name] = value
+			}
+			alerting.Annotations = annotations
+		}
+	}
+	for _, mw := range in.Spec.Alerting.MuteWindows {
+		window, err := mw.internal()
+		if err != nil {
+			return slo.Objective{}, err
+		}
+		alerting.MuteWindows = append(alerting.MuteWindows, window)
+	}
+	for _, mw := range in.Spec.Alerting.MaintenanceWindows {
+		window, err := mw.internal()
+		if err != nil {
+			return slo.Objective{}, err
+		}
+		alerting.MaintenanceWindows = append(alerting.MaintenanceWindows, window)
+	}
+	alerting.Windows, err = alertingWindows(in.Spec.Alerting.Windows, target/100, time.Duration(window))
+	if err != nil {
+		return slo.Objective{}, err
+	}
+	if in.Spec.Alerting.KeepFiringFor != "" {
+		keepFiringFor, err := model.ParseDuration(in.Spec.Alerting.KeepFiringFor)
+		if err != nil {
+			return slo.Objective{}, fmt.Errorf("failed to parse alerting keepFiringFor: %w", err)
+		}
+		alerting.KeepFiringFor = time.Duration(keepFiringFor)
+	}
+	alerting.PartialResponseStrategy = in.Spec.Alerting.PartialResponseStrategy
+	alerting.Inhibit = in.Spec.Alerting.Inhibit
+
+	var budgetFreeze *float64
+	if in.Spec.Policy != nil {
+		for _, t := range in.Spec.Policy.Thresholds {
+			if !t.Freeze {
+				continue
+			}
+			remaining, err := strconv.ParseFloat(t.Remaining, 64)
+			if err != nil {
+				return slo.Objective{}, fmt.Errorf("failed to parse policy threshold remaining: %w", err)
+			}
+			// Changes are frozen below the highest threshold that freezes.
+			if budgetFreeze == nil || remaining/100 > *budgetFreeze {
+				freeze := remaining / 100
+				budgetFreeze = &freeze
+			}
+		}
+	}
 
-	if in.Spec.ServiceLevelIndicator.Ratio != nil && in.Spec.ServiceLevelIndicator.Latency != nil {
+	var slaTarget *float64
+	if in.Spec.SLA != nil {
+		sla, err := strconv.ParseFloat(in.Spec.SLA.Target, 64)
+		if err != nil {
+			return slo.Objective{}, fmt.Errorf("failed to parse sla target: %w", err)
+		}
+		sla = sla / 100
+		slaTarget = &sla
+	}
+
+	indicator, err := in.Spec.ServiceLevelIndicator.expandPresets(in.GetNamespace())
+	if err != nil {
+		return slo.Objective{}, err
+	}
+
+	if indicator.Ratio != nil && indicator.Latency != nil {
 		return slo.Objective{}, fmt.Errorf("cannot have ratio and latency indicators at the same time")
 	}
 
 	var ratio *slo.RatioIndicator
-	if in.Spec.ServiceLevelIndicator.Ratio != nil {
-		totalExpr, err := parser.ParseExpr(in.Spec.ServiceLevelIndicator.Ratio.Total.Metric)
+	if indicator.Ratio != nil {
+		totalExpr, err := parser.ParseExpr(indicator.Ratio.Total.Metric)
 		if err != nil {
 			return slo.Objective{}, err
 		}
@@ -398,7 +1591,7 @@ func (in *ServiceLevelObjective) Internal() (slo.Objective, error) {
 			return slo.Objective{}, fmt.Errorf("ratio total metric is not a VectorSelector")
 		}
 
-		errorExpr, err := parser.ParseExpr(in.Spec.ServiceLevelIndicator.Ratio.Errors.Metric)
+		errorExpr, err := parser.ParseExpr(indicator.Ratio.Errors.Metric)
 		if err != nil {
 			return slo.Objective{}, err
 		}
@@ -423,13 +1616,13 @@ func (in *ServiceLevelObjective) Internal() (slo.Objective, error) {
 				Name:          totalVec.Name,
 				LabelMatchers: totalVec.LabelMatchers,
 			},
-			Grouping: in.Spec.ServiceLevelIndicator.Ratio.Grouping,
+			Grouping: indicator.Ratio.Grouping,
 		}
 	}
 
 	var latency *slo.LatencyIndicator
-	if in.Spec.ServiceLevelIndicator.Latency != nil {
-		totalExpr, err := parser.ParseExpr(in.Spec.ServiceLevelIndicator.Latency.Total.Metric)
+	if indicator.Latency != nil {
+		totalExpr, err := parser.ParseExpr(indicator.Latency.Total.Metric)
 		if err != nil {
 			return slo.Objective{}, err
 		}
@@ -445,7 +1638,7 @@ func (in *ServiceLevelObjective) Internal() (slo.Objective, error) {
 			totalMatchers[i] = &labels.Matcher{Type: matcher.Type, Name: matcher.Name, Value: matcher.Value}
 		}
 
-		successExpr, err := parser.ParseExpr(in.Spec.ServiceLevelIndicator.Latency.Success.Metric)
+		successExpr, err := parser.ParseExpr(indicator.Latency.Success.Metric)
 		if err != nil {
 			return slo.Objective{}, err
 		}
@@ -470,18 +1663,18 @@ func (in *ServiceLevelObjective) Internal() (slo.Objective, error) {
 				Name:          totalVec.Name,
 				LabelMatchers: totalMatchers,
 			},
-			Grouping: in.Spec.ServiceLevelIndicator.Latency.Grouping,
+			Grouping: indicator.Latency.Grouping,
 		}
 	}
 
 	var latencyNative *slo.LatencyNativeIndicator
-	if in.Spec.ServiceLevelIndicator.LatencyNative != nil {
-		latency, err := model.ParseDuration(in.Spec.ServiceLevelIndicator.LatencyNative.Latency)
+	if indicator.LatencyNative != nil {
+		latency, err := model.ParseDuration(indicator.LatencyNative.Latency)
 		if err != nil {
 			return slo.Objective{}, fmt.Errorf("failed to parse objective latency: %w", err)
 		}
 
-		totalExpr, err := parser.ParseExpr(in.Spec.ServiceLevelIndicator.LatencyNative.Total.Metric)
+		totalExpr, err := parser.ParseExpr(indicator.LatencyNative.Total.Metric)
 		if err != nil {
 			return slo.Objective{}, err
 		}
@@ -503,13 +1696,13 @@ func (in *ServiceLevelObjective) Internal() (slo.Objective, error) {
 				Name:          totalVec.Name,
 				LabelMatchers: totalMatchers,
 			},
-			Grouping: in.Spec.ServiceLevelIndicator.LatencyNative.Grouping,
+			Grouping: indicator.LatencyNative.Grouping,
 		}
 	}
 
 	var boolGauge *slo.BoolGaugeIndicator
-	if in.Spec.ServiceLevelIndicator.BoolGauge != nil {
-		expr, err := parser.ParseExpr(in.Spec.ServiceLevelIndicator.BoolGauge.Metric)
+	if indicator.BoolGauge != nil {
+		expr, err := parser.ParseExpr(indicator.BoolGauge.Metric)
 		if err != nil {
 			return slo.Objective{}, err
 		}
@@ -530,7 +1723,92 @@ func (in *ServiceLevelObjective) Internal() (slo.Objective, error) {
 				Name:          vec.Name,
 				LabelMatchers: matchers,
 			},
-			Grouping: in.Spec.ServiceLevelIndicator.BoolGauge.Grouping,
+			Grouping: indicator.BoolGauge.Grouping,
+		}
+	}
+
+	var quantile *slo.QuantileIndicator
+	if indicator.Quantile != nil {
+		q, err := strconv.ParseFloat(indicator.Quantile.Quantile, 64)
+		if err != nil {
+			return slo.Objective{}, fmt.Errorf("failed to parse quantile: %w", err)
+		}
+
+		latency, err := model.ParseDuration(indicator.Quantile.Latency)
+		if err != nil {
+			return slo.Objective{}, fmt.Errorf("failed to parse quantile latency: %w", err)
+		}
+
+		interval, err := indicator.Quantile.interval()
+		if err != nil {
+			return slo.Objective{}, fmt.Errorf("failed to parse quantile interval: %w", err)
+		}
+
+		totalExpr, err := parser.ParseExpr(indicator.Quantile.Total.Metric)
+		if err != nil {
+			return slo.Objective{}, err
+		}
+
+		totalVec, ok := totalExpr.(*parser.VectorSelector)
+		if !ok {
+			return slo.Objective{}, fmt.Errorf("quantile total metric is not a VectorSelector")
+		}
+
+		// Copy the matchers to get rid of the re field for unit testing...
+		totalMatchers := make([]*labels.Matcher, len(totalVec.LabelMatchers))
+		for i, matcher := range totalVec.LabelMatchers {
+			totalMatchers[i] = &labels.Matcher{Type: matcher.Type, Name: matcher.Name, Value: matcher.Value}
+		}
+
+		quantile = &slo.QuantileIndicator{
+			Total: slo.Metric{
+				Name:          totalVec.Name,
+				LabelMatchers: totalMatchers,
+			},
+			Quantile: q,
+			Latency:  latency,
+			Interval: interval,
+			Grouping: indicator.Quantile.Grouping,
+		}
+		// The objective is evaluated as the bool gauge recorded by the quantile's rule.
+		boolGauge = quantile.BoolGauge(in.GetName())
+	}
+
+	var expression *slo.ExpressionIndicator
+	if indicator.Expression != nil {
+		expression = &slo.ExpressionIndicator{
+			Errors:   indicator.Expression.Errors,
+			Total:    indicator.Expression.Total,
+			Grouping: indicator.Expression.Grouping,
+		}
+	}
+
+	var logs *slo.LogsIndicator
+	if indicator.Logs != nil {
+		logs = &slo.LogsIndicator{
+			Errors:   indicator.Logs.Errors,
+			Total:    indicator.Logs.Total,
+			Grouping: indicator.Logs.Grouping,
+		}
+	}
+
+	var composite *slo.CompositeIndicator
+	if indicator.Composite != nil {
+		composite = &slo.CompositeIndicator{}
+		for _, co := range indicator.Composite.Objectives {
+			weight, err := co.weight()
+			if err != nil {
+				return slo.Objective{}, err
+			}
+			c := slo.CompositeObjective{Name: co.Name, Weight: weight}
+			if co.Objective != nil {
+				objective, err := co.Objective.Internal()
+				if err != nil {
+					return slo.Objective{}, fmt.Errorf("failed to get objective %s of the composite: %w", co.Name, err)
+				}
+				c.Objective = &objective
+			}
+			composite.Objectives = append(composite.Objectives, c)
 		}
 	}
 
@@ -557,19 +1835,45 @@ func (in *ServiceLevelObjective) Internal() (slo.Objective, error) {
 		}
 	}
 
+	var owner *slo.Owner
+	if in.Spec.Owner != nil {
+		owner = in.Spec.Owner.internal()
+	}
+
+	var ruleGroupInterval model.Duration
+	if in.Spec.RuleGroupInterval != "" {
+		ruleGroupInterval, err = model.ParseDuration(in.Spec.RuleGroupInterval)
+		if err != nil {
+			return slo.Objective{}, fmt.Errorf("failed to parse ruleGroupInterval: %w", err)
+		}
+	}
+
 	return slo.Objective{
-		Labels:      ls,
-		Annotations: in.Annotations,
-		Description: in.Spec.Description,
-		Target:      target / 100,
-		Window:      window,
-		Config:      string(config),
-		Alerting:    alerting,
+		Labels:            ls,
+		Annotations:       in.Annotations,
+		Description:       in.Spec.Description,
+		Target:            target / 100,
+		Window:            window,
+		Config:            string(config),
+		Alerting:          alerting,
+		BudgetFreeze:      budgetFreeze,
+		SLATarget:         slaTarget,
+		Calendar:          slo.Calendar(in.Spec.Calendar),
+		StableRuleNames:   in.Spec.StableRuleNames,
+		SharedBurnrates:   in.Spec.SharedBurnrates,
+		ExternalLabels:    in.Spec.ExternalLabels,
+		RuleGroupInterval: time.Duration(ruleGroupInterval),
+		RuleGroupLimit:    in.Spec.RuleGroupLimit,
+		Owner:             owner,
 		Indicator: slo.Indicator{
 			Ratio:         ratio,
 			Latency:       latency,
 			LatencyNative: latencyNative,
 			BoolGauge:     boolGauge,
+			Quantile:      quantile,
+			Expression:    expression,
+			Logs:          logs,
+			Composite:     composite,
 		},
 	}, nil
 }
@@ -379,11 +379,31 @@ func TestServiceLevelObjective_Validate(t *testing.T) {
 
 		empty.Namespace = "namespace"
 
+		empty.Annotations = map[string]string{"pyrra.dev/grafana-datasource-uid": "prometheus/thanos"}
+		warn, err = empty.ValidateCreate()
+		require.EqualError(t, err, `pyrra.dev/grafana-datasource-uid "prometheus/thanos" must be at most 40 letters, digits, _ and -`)
+		require.Nil(t, warn)
+
+		empty.Annotations = map[string]string{"pyrra.dev/grafana-datasource-uid": "P1809F7CD0C75ACF3"}
+		_, err = empty.ValidateCreate()
+		require.EqualError(t, err, "target must be set")
+		empty.Annotations = nil
+
 		empty.Spec.Target = "-99"
 		warn, err = empty.ValidateCreate()
 		require.EqualError(t, err, "target must be between 0 and 100")
 		require.Nil(t, warn)
 
+		empty.Spec.Target = "0"
+		warn, err = empty.ValidateCreate()
+		require.EqualError(t, err, "target must be between 0 and 100")
+		require.Nil(t, warn)
+
+		empty.Spec.Target = "99.5%"
+		warn, err = empty.ValidateCreate()
+		require.EqualError(t, err, `failed to parse target "99.5%", it must be a percentage like 99.5: strconv.ParseFloat: parsing "99.5%": invalid syntax`)
+		require.Nil(t, warn)
+
 		empty.Spec.Target = "9999"
 		warn, err = empty.ValidateCreate()
 		require.EqualError(t, err, "target must be between 0 and 100")
@@ -399,12 +419,17 @@ func TestServiceLevelObjective_Validate(t *testing.T) {
 		empty.Spec.Window = "2t"
 		warn, err = empty.ValidateCreate()
 		require.Nil(t, warn)
-		require.EqualError(t, err, `unknown unit "t" in duration "2t"`)
+		require.EqualError(t, err, `failed to parse window "2t", it must be a duration like 28d: unknown unit "t" in duration "2t"`)
+
+		empty.Spec.Window = "0d"
+		warn, err = empty.ValidateCreate()
+		require.Nil(t, warn)
+		require.EqualError(t, err, "window must be longer than 0")
 
 		empty.Spec.Window = "2w"
 		warn, err = empty.ValidateCreate()
 		require.Nil(t, warn)
-		require.EqualError(t, err, "one of ratio, latency, latencyNative or bool_gauge must be set")
+		require.EqualError(t, err, "one of ratio, latency, latencyNative, bool_gauge, expression, logs, composite, istio, linkerd or grpc must be set")
 	})
 
 	t.Run("ratio", func(t *testing.T) {
@@ -723,4 +748,1123 @@ func TestServiceLevelObjective_Validate(t *testing.T) {
 			require.Nil(t, warn)
 		})
 	})
+
+	t.Run("quantile", func(t *testing.T) {
+		quantile := func() *v1alpha1.ServiceLevelObjective {
+			return &v1alpha1.ServiceLevelObjective{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "name",
+					Namespace: "namespace",
+				},
+				Spec: v1alpha1.ServiceLevelObjectiveSpec{
+					Target: "99",
+					Window: "2w",
+					ServiceLevelIndicator: v1alpha1.ServiceLevelIndicator{
+						Quantile: &v1alpha1.QuantileIndicator{
+							Total: v1alpha1.Query{
+								Metric: `http_request_duration_seconds_bucket{job="shop"}`,
+							},
+							Quantile: "0.99",
+							Latency:  "500ms",
+						},
+					},
+				},
+			}
+		}
+
+		warn, err := quantile().ValidateCreate()
+		require.NoError(t, err)
+		require.Nil(t, warn)
+
+		internal, err := quantile().Internal()
+		require.NoError(t, err)
+		require.Equal(t, 0.99, internal.Indicator.Quantile.Quantile)
+		require.Equal(t, model.Duration(500*time.Millisecond), internal.Indicator.Quantile.Latency)
+		require.Equal(t, model.Duration(5*time.Minute), internal.Indicator.Quantile.Interval)
+		require.Equal(t, "http_request_duration_seconds:quantile_within_latency5m", internal.Indicator.BoolGauge.Name)
+		require.Equal(t, `slo="name"`, internal.Indicator.BoolGauge.LabelMatchers[1].String())
+
+		for _, tc := range []struct {
+			name string
+			edit func(q *v1alpha1.QuantileIndicator)
+			err  string
+		}{{
+			name: "empty",
+			edit: func(q *v1alpha1.QuantileIndicator) { q.Total.Metric = "" },
+			err:  "quantile total metric must be set",
+		}, {
+			name: "notSelector",
+			edit: func(q *v1alpha1.QuantileIndicator) { q.Total.Metric = "rate(foo[5m])" },
+			err:  "quantile total metric must be a series selector",
+		}, {
+			name: "quantile",
+			edit: func(q *v1alpha1.QuantileIndicator) { q.Quantile = "99" },
+			err:  "quantile must be between 0 and 1, like 0.99",
+		}, {
+			name: "latency",
+			edit: func(q *v1alpha1.QuantileIndicator) { q.Latency = "" },
+			err:  "quantile latency must be set",
+		}, {
+			name: "interval",
+			edit: func(q *v1alpha1.QuantileIndicator) { q.Interval = "0s" },
+			err:  "quantile interval must be greater than 0",
+		}} {
+			t.Run(tc.name, func(t *testing.T) {
+				q := quantile()
+				tc.edit(q.Spec.ServiceLevelIndicator.Quantile)
+				warn, err := q.ValidateCreate()
+				require.EqualError(t, err, tc.err)
+				require.Nil(t, warn)
+			})
+		}
+	})
+
+	t.Run("expression", func(t *testing.T) {
+		expression := func() *v1alpha1.ServiceLevelObjective {
+			return &v1alpha1.ServiceLevelObjective{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "name",
+					Namespace: "namespace",
+				},
+				Spec: v1alpha1.ServiceLevelObjectiveSpec{
+					Target: "99",
+					Window: "2w",
+					ServiceLevelIndicator: v1alpha1.ServiceLevelIndicator{
+						Expression: &v1alpha1.ExpressionIndicator{
+							Errors: `failed_total{foo="bar"} + timeouts_total{foo="bar"}`,
+							Total:  `sum by (foo) (started_total{foo="bar"})`,
+						},
+					},
+				},
+			}
+		}
+
+		warn, err := expression().ValidateCreate()
+		require.NoError(t, err)
+		require.Nil(t, warn)
+
+		t.Run("empty", func(t *testing.T) {
+			e := expression()
+			e.Spec.ServiceLevelIndicator.Expression.Total = ""
+			_, err := e.ValidateCreate()
+			require.EqualError(t, err, "expression total must be set")
+
+			e = expression()
+			e.Spec.ServiceLevelIndicator.Expression.Errors = ""
+			_, err = e.ValidateCreate()
+			require.EqualError(t, err, "expression errors must be set")
+		})
+
+		t.Run("invalidExpression", func(t *testing.T) {
+			e := expression()
+			e.Spec.ServiceLevelIndicator.Expression.Total = "foo{"
+			_, err := e.ValidateCreate()
+			require.EqualError(t, err, "invalid expression total: 1:5: parse error: unexpected end of input inside braces")
+
+			e = expression()
+			e.Spec.ServiceLevelIndicator.Expression.Errors = `rate(failed_total[5m])`
+			_, err = e.ValidateCreate()
+			require.EqualError(t, err, "invalid expression errors: expression must not select ranges like failed_total[5m], the rules select the ranges of its series")
+
+			e.Spec.ServiceLevelIndicator.Expression.Errors = `scalar(failed_total)`
+			_, err = e.ValidateCreate()
+			require.EqualError(t, err, "invalid expression errors: expression must return an instant vector, not a scalar")
+		})
+
+		t.Run("internal", func(t *testing.T) {
+			internal, err := expression().Internal()
+			require.NoError(t, err)
+			require.Equal(t, &slo.ExpressionIndicator{
+				Errors: `failed_total{foo="bar"} + timeouts_total{foo="bar"}`,
+				Total:  `sum by (foo) (started_total{foo="bar"})`,
+			}, internal.Indicator.Expression)
+		})
+	})
+
+	t.Run("logs", func(t *testing.T) {
+		logs := func() *v1alpha1.ServiceLevelObjective {
+			return &v1alpha1.ServiceLevelObjective{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "name",
+					Namespace:   "namespace",
+					Annotations: map[string]string{"pyrra.dev/ruler": "loki"},
+				},
+				Spec: v1alpha1.ServiceLevelObjectiveSpec{
+					Target: "99",
+					Window: "2w",
+					ServiceLevelIndicator: v1alpha1.ServiceLevelIndicator{
+						Logs: &v1alpha1.LogsIndicator{
+							Errors:   `{job="nginx"} | json | status >= 500`,
+							Total:    `{job="nginx"}`,
+							Grouping: []string{"host"},
+						},
+					},
+				},
+			}
+		}
+
+		warn, err := logs().ValidateCreate()
+		require.NoError(t, err)
+		require.Nil(t, warn)
+
+		t.Run("empty", func(t *testing.T) {
+			l := logs()
+			l.Spec.ServiceLevelIndicator.Logs.Total = ""
+			_, err := l.ValidateCreate()
+			require.EqualError(t, err, "logs total must be set")
+
+			l = logs()
+			l.Spec.ServiceLevelIndicator.Logs.Errors = ""
+			_, err = l.ValidateCreate()
+			require.EqualError(t, err, "logs errors must be set")
+		})
+
+		t.Run("invalidQuery", func(t *testing.T) {
+			l := logs()
+			l.Spec.ServiceLevelIndicator.Logs.Total = `sum(rate({job="nginx"}[5m]))`
+			_, err := l.ValidateCreate()
+			require.EqualError(t, err, `invalid logs total: log query must start with a stream selector like {job="app"}`)
+
+			l = logs()
+			l.Spec.ServiceLevelIndicator.Logs.Errors = `{job="nginx"} status >= 500`
+			_, err = l.ValidateCreate()
+			require.EqualError(t, err, `invalid logs errors: log query must continue with a pipeline starting with |, not "status >= 500"`)
+		})
+
+		t.Run("ruler", func(t *testing.T) {
+			l := logs()
+			l.Annotations = nil
+			_, err := l.ValidateCreate()
+			require.EqualError(t, err, "logs indicators are evaluated by Loki and need the pyrra.dev/ruler: loki annotation")
+
+			// Loki can't evaluate the PromQL of the other indicators.
+			l = logs()
+			l.Spec.ServiceLevelIndicator = v1alpha1.ServiceLevelIndicator{Ratio: &v1alpha1.RatioIndicator{
+				Errors: v1alpha1.Query{Metric: `http_requests_total{status=~"5.."}`},
+				Total:  v1alpha1.Query{Metric: `http_requests_total`},
+			}}
+			_, err = l.ValidateCreate()
+			require.EqualError(t, err, "only logs indicators are evaluated by Loki and can have the pyrra.dev/ruler: loki annotation")
+		})
+
+		t.Run("unsupported", func(t *testing.T) {
+			l := logs()
+			l.Spec.Alerting.MuteWindows = []v1alpha1.MuteWindow{{StartTime: "02:00", EndTime: "04:00"}}
+			_, err := l.ValidateCreate()
+			require.EqualError(t, err, "logs indicators don't support mute or maintenance windows")
+
+			l = logs()
+			l.Spec.SLA = &v1alpha1.SLA{Target: "95"}
+			_, err = l.ValidateCreate()
+			require.EqualError(t, err, "logs indicators don't support an SLA")
+
+			l = logs()
+			l.Spec.Calendar = "month"
+			_, err = l.ValidateCreate()
+			require.EqualError(t, err, "logs indicators don't support a calendar")
+
+			l = logs()
+			l.Spec.SharedBurnrates = true
+			_, err = l.ValidateCreate()
+			require.EqualError(t, err, "logs indicators don't support shared burn rates")
+
+			l = logs()
+			l.Spec.Policy = &v1alpha1.ErrorBudgetPolicy{Thresholds: []v1alpha1.ErrorBudgetThreshold{{Remaining: "10", Notify: true}}}
+			_, err = l.ValidateCreate()
+			require.NoError(t, err)
+			l.Spec.Policy.Thresholds[0].Freeze = true
+			_, err = l.ValidateCreate()
+			require.EqualError(t, err, "logs indicators don't support policy thresholds that freeze changes")
+		})
+
+		t.Run("internal", func(t *testing.T) {
+			internal, err := logs().Internal()
+			require.NoError(t, err)
+			require.Equal(t, &slo.LogsIndicator{
+				Errors:   `{job="nginx"} | json | status >= 500`,
+				Total:    `{job="nginx"}`,
+				Grouping: []string{"host"},
+			}, internal.Indicator.Logs)
+		})
+	})
+
+	t.Run("composite", func(t *testing.T) {
+		composite := func() *v1alpha1.ServiceLevelObjective {
+			return &v1alpha1.ServiceLevelObjective{
+				ObjectMeta: metav1.ObjectMeta{Name: "checkout", Namespace: "namespace"},
+				Spec: v1alpha1.ServiceLevelObjectiveSpec{
+					Target: "99",
+					Window: "2w",
+					ServiceLevelIndicator: v1alpha1.ServiceLevelIndicator{
+						Composite: &v1alpha1.CompositeIndicator{Objectives: []v1alpha1.CompositeObjective{
+							{Name: "cart", Weight: "2"},
+							{Name: "payment"},
+						}},
+					},
+				},
+			}
+		}
+
+		warn, err := composite().ValidateCreate()
+		require.NoError(t, err)
+		require.Nil(t, warn)
+
+		t.Run("objectives", func(t *testing.T) {
+			c := composite()
+			c.Spec.ServiceLevelIndicator.Composite.Objectives = nil
+			_, err := c.ValidateCreate()
+			require.EqualError(t, err, "composite objectives must be set")
+
+			c = composite()
+			c.Spec.ServiceLevelIndicator.Composite.Objectives[1].Name = ""
+			_, err = c.ValidateCreate()
+			require.EqualError(t, err, "composite objective name must be set")
+
+			c = composite()
+			c.Spec.ServiceLevelIndicator.Composite.Objectives[1].Name = "checkout"
+			_, err = c.ValidateCreate()
+			require.EqualError(t, err, "composite cannot combine itself")
+
+			c = composite()
+			c.Spec.ServiceLevelIndicator.Composite.Objectives[1].Name = "cart"
+			_, err = c.ValidateCreate()
+			require.EqualError(t, err, "composite objective cart is combined more than once")
+		})
+
+		t.Run("weight", func(t *testing.T) {
+			c := composite()
+			c.Spec.ServiceLevelIndicator.Composite.Objectives[0].Weight = "0"
+			_, err := c.ValidateCreate()
+			require.EqualError(t, err, "weight of objective cart must be greater than 0")
+
+			c.Spec.ServiceLevelIndicator.Composite.Objectives[0].Weight = "heavy"
+			_, err = c.ValidateCreate()
+			require.EqualError(t, err, `failed to parse weight of objective cart: strconv.ParseFloat: parsing "heavy": invalid syntax`)
+		})
+
+		t.Run("unsupported", func(t *testing.T) {
+			c := composite()
+			c.Spec.ServiceLevelIndicator.Expression = &v1alpha1.ExpressionIndicator{Errors: "errors_total", Total: "requests_total"}
+			_, err := c.ValidateCreate()
+			require.EqualError(t, err, "composite cannot be combined with other indicators")
+
+			c = composite()
+			c.Annotations = map[string]string{"pyrra.dev/ruler": "loki"}
+			_, err = c.ValidateCreate()
+			require.EqualError(t, err, "composite indicators are evaluated by Prometheus and can't have the pyrra.dev/ruler: loki annotation")
+
+			c = composite()
+			c.Spec.Calendar = "quarter"
+			_, err = c.ValidateCreate()
+			require.EqualError(t, err, "composite indicators don't support a calendar")
+
+			c = composite()
+			c.Spec.SharedBurnrates = true
+			_, err = c.ValidateCreate()
+			require.EqualError(t, err, "composite indicators don't support shared burn rates")
+		})
+
+		t.Run("internal", func(t *testing.T) {
+			// Objectives are only converted once they're resolved.
+			internal, err := composite().Internal()
+			require.NoError(t, err)
+			require.Equal(t, &slo.CompositeIndicator{Objectives: []slo.CompositeObjective{
+				{Name: "cart", Weight: 2},
+				{Name: "payment", Weight: 1},
+			}}, internal.Indicator.Composite)
+
+			c := composite()
+			c.Spec.ServiceLevelIndicator.Composite.Objectives[1].Objective = &v1alpha1.ServiceLevelObjective{
+				ObjectMeta: metav1.ObjectMeta{Name: "payment", Namespace: "namespace"},
+				Spec: v1alpha1.ServiceLevelObjectiveSpec{
+					Target: "99.9",
+					Window: "2w",
+					ServiceLevelIndicator: v1alpha1.ServiceLevelIndicator{
+						Ratio: &v1alpha1.RatioIndicator{
+							Errors: v1alpha1.Query{Metric: `payments_total{status="failed"}`},
+							Total:  v1alpha1.Query{Metric: `payments_total`},
+						},
+					},
+				},
+			}
+			internal, err = c.Internal()
+			require.NoError(t, err)
+			payment := internal.Indicator.Composite.Objectives[1].Objective
+			require.Equal(t, "payment", payment.Name())
+			require.Equal(t, slo.Ratio, payment.IndicatorType())
+
+			// The resolved objectives aren't part of the composite's config.
+			require.NotContains(t, internal.Config, "payments_total")
+		})
+	})
+}
+
+func TestServiceLevelObjective_PagerDuty(t *testing.T) {
+	objective := func() *v1alpha1.ServiceLevelObjective {
+		return &v1alpha1.ServiceLevelObjective{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "name",
+				Namespace: "namespace",
+			},
+			Spec: v1alpha1.ServiceLevelObjectiveSpec{
+				Target: "99",
+				Window: "2w",
+				ServiceLevelIndicator: v1alpha1.ServiceLevelIndicator{
+					Ratio: &v1alpha1.RatioIndicator{
+						Errors: v1alpha1.Query{Metric: `foo{foo="bar",code=~"5.."}`},
+						Total:  v1alpha1.Query{Metric: `foo{foo="bar"}`},
+					},
+				},
+				Alerting: v1alpha1.Alerting{
+					PagerDuty: &v1alpha1.PagerDutyAlerting{
+						Service: "checkout",
+						Urgency: map[string]string{"warning": "high"},
+					},
+				},
+			},
+		}
+	}
+
+	o := objective()
+	warn, err := o.ValidateCreate()
+	require.NoError(t, err)
+	require.Nil(t, warn)
+
+	internal, err := o.Internal()
+	require.NoError(t, err)
+	require.Equal(t, map[string]map[string]string{
+		"critical": {"pagerduty_service": "checkout", "pagerduty_urgency": "high"},
+		"warning":  {"pagerduty_service": "checkout", "pagerduty_urgency": "high"},
+	}, internal.Alerting.SeverityLabels)
+
+	t.Run("emptyService", func(t *testing.T) {
+		o := objective()
+		o.Spec.Alerting.PagerDuty.Service = ""
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, "pagerduty service must be set")
+	})
+
+	t.Run("invalidUrgency", func(t *testing.T) {
+		o := objective()
+		o.Spec.Alerting.PagerDuty.Urgency = map[string]string{"critical": "urgent"}
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, `pagerduty urgency must be high or low, got "urgent"`)
+
+		o.Spec.Alerting.PagerDuty.Urgency = map[string]string{"info": "low"}
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, `pagerduty urgency severity must be critical or warning, got "info"`)
+	})
+}
+
+func TestServiceLevelObjective_Opsgenie(t *testing.T) {
+	objective := func() *v1alpha1.ServiceLevelObjective {
+		return &v1alpha1.ServiceLevelObjective{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "name",
+				Namespace: "namespace",
+			},
+			Spec: v1alpha1.ServiceLevelObjectiveSpec{
+				Target: "99",
+				Window: "2w",
+				ServiceLevelIndicator: v1alpha1.ServiceLevelIndicator{
+					Ratio: &v1alpha1.RatioIndicator{
+						Errors: v1alpha1.Query{Metric: `foo{foo="bar",code=~"5.."}`},
+						Total:  v1alpha1.Query{Metric: `foo{foo="bar"}`},
+					},
+				},
+				Alerting: v1alpha1.Alerting{
+					Opsgenie: &v1alpha1.OpsgenieAlerting{
+						Priority: map[string]string{"critical": "P2"},
+						Tags:     []string{"checkout", "slo"},
+					},
+				},
+			},
+		}
+	}
+
+	o := objective()
+	warn, err := o.ValidateCreate()
+	require.NoError(t, err)
+	require.Nil(t, warn)
+
+	internal, err := o.Internal()
+	require.NoError(t, err)
+	require.Equal(t, map[string]map[string]string{
+		"critical": {"opsgenie_priority": "P2", "opsgenie_tags": "checkout,slo"},
+		"warning":  {"opsgenie_priority": "P3", "opsgenie_tags": "checkout,slo"},
+	}, internal.Alerting.SeverityAnnotations)
+
+	t.Run("invalidPriority", func(t *testing.T) {
+		o := objective()
+		o.Spec.Alerting.Opsgenie.Priority = map[string]string{"critical": "P0"}
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, `opsgenie priority must be one of P1, P2, P3, P4 or P5, got "P0"`)
+
+		o.Spec.Alerting.Opsgenie.Priority = map[string]string{"page": "P1"}
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, `opsgenie priority severity must be critical or warning, got "page"`)
+	})
+
+	t.Run("invalidTags", func(t *testing.T) {
+		o := objective()
+		o.Spec.Alerting.Opsgenie.Tags = []string{"a,b"}
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, `opsgenie tags must not be empty or contain commas, got "a,b"`)
+	})
+}
+
+func TestServiceLevelObjective_Policy(t *testing.T) {
+	objective := func() *v1alpha1.ServiceLevelObjective {
+		return &v1alpha1.ServiceLevelObjective{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "name",
+				Namespace: "namespace",
+			},
+			Spec: v1alpha1.ServiceLevelObjectiveSpec{
+				Target: "99",
+				Window: "2w",
+				ServiceLevelIndicator: v1alpha1.ServiceLevelIndicator{
+					Ratio: &v1alpha1.RatioIndicator{
+						Errors: v1alpha1.Query{Metric: `foo{foo="bar",code=~"5.."}`},
+						Total:  v1alpha1.Query{Metric: `foo{foo="bar"}`},
+					},
+				},
+				Policy: &v1alpha1.ErrorBudgetPolicy{
+					Thresholds: []v1alpha1.ErrorBudgetThreshold{{
+						Remaining: "25",
+						Notify:    true,
+						Labels:    map[string]string{"example.com/deploy": "frozen"},
+						Freeze:    true,
+					}},
+				},
+			},
+		}
+	}
+
+	o := objective()
+	warn, err := o.ValidateCreate()
+	require.NoError(t, err)
+	require.Nil(t, warn)
+
+	// Changes are frozen below the highest threshold that freezes.
+	o.Spec.Policy.Thresholds = append(o.Spec.Policy.Thresholds,
+		v1alpha1.ErrorBudgetThreshold{Remaining: "10", Freeze: true},
+		v1alpha1.ErrorBudgetThreshold{Remaining: "50", Notify: true},
+	)
+	internal, err := o.Internal()
+	require.NoError(t, err)
+	require.NotNil(t, internal.BudgetFreeze)
+	require.Equal(t, 0.25, *internal.BudgetFreeze)
+
+	t.Run("noThresholds", func(t *testing.T) {
+		o := objective()
+		o.Spec.Policy.Thresholds = nil
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, "policy thresholds must be set")
+	})
+
+	t.Run("invalidRemaining", func(t *testing.T) {
+		o := objective()
+		o.Spec.Policy.Thresholds[0].Remaining = "120"
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, "policy threshold remaining must be between 0 and 100, got 120")
+
+		o.Spec.Policy.Thresholds[0].Remaining = "a quarter"
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, `policy threshold remaining must be a number: strconv.ParseFloat: parsing "a quarter": invalid syntax`)
+	})
+
+	t.Run("noAction", func(t *testing.T) {
+		o := objective()
+		o.Spec.Policy.Thresholds[0] = v1alpha1.ErrorBudgetThreshold{Remaining: "25"}
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, "policy threshold 25 must notify, freeze, set labels or silence alerts")
+
+		o.Spec.Policy.Thresholds[0].Silence = []string{"warning"}
+		_, err = o.ValidateCreate()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalidSilence", func(t *testing.T) {
+		o := objective()
+		o.Spec.Policy.Thresholds[0].Silence = []string{""}
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, "policy threshold 25 must not silence an empty severity")
+
+		o.Spec.Policy.Thresholds[0].Silence = []string{"not allowed"}
+		_, err = o.ValidateCreate()
+		require.ErrorContains(t, err, `policy threshold silence severity "not allowed" is invalid`)
+	})
+
+	t.Run("invalidLabels", func(t *testing.T) {
+		o := objective()
+		o.Spec.Policy.Thresholds[0].Labels = map[string]string{"pyrra.dev/team": "foo"}
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, `policy threshold labels must not start with pyrra.dev/, got "pyrra.dev/team"`)
+
+		o.Spec.Policy.Thresholds[0].Labels = map[string]string{"deploy": ""}
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, `policy threshold label "deploy" must have a value`)
+
+		o.Spec.Policy.Thresholds[0].Labels = map[string]string{"deploy": "not allowed"}
+		_, err = o.ValidateCreate()
+		require.ErrorContains(t, err, `policy threshold label value "not allowed" is invalid`)
+	})
+}
+
+func TestServiceLevelObjective_MuteWindows(t *testing.T) {
+	objective := func() *v1alpha1.ServiceLevelObjective {
+		return &v1alpha1.ServiceLevelObjective{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "name",
+				Namespace: "namespace",
+			},
+			Spec: v1alpha1.ServiceLevelObjectiveSpec{
+				Target: "99",
+				Window: "2w",
+				ServiceLevelIndicator: v1alpha1.ServiceLevelIndicator{
+					Ratio: &v1alpha1.RatioIndicator{
+						Errors: v1alpha1.Query{Metric: `foo{foo="bar",code=~"5.."}`},
+						Total:  v1alpha1.Query{Metric: `foo{foo="bar"}`},
+					},
+				},
+				Alerting: v1alpha1.Alerting{
+					MuteWindows: []v1alpha1.MuteWindow{
+						{StartTime: "02:00", EndTime: "04:30"},
+						{Weekdays: []string{"saturday", "Sunday"}, StartTime: "22:00", EndTime: "24:00"},
+					},
+				},
+			},
+		}
+	}
+
+	o := objective()
+	warn, err := o.ValidateCreate()
+	require.NoError(t, err)
+	require.Nil(t, warn)
+
+	internal, err := o.Internal()
+	require.NoError(t, err)
+	require.Equal(t, []slo.MuteWindow{
+		{Start: 2 * time.Hour, End: 4*time.Hour + 30*time.Minute},
+		{Weekdays: []time.Weekday{time.Saturday, time.Sunday}, Start: 22 * time.Hour, End: 24 * time.Hour},
+	}, internal.Alerting.MuteWindows)
+
+	t.Run("crossingMidnight", func(t *testing.T) {
+		o := objective()
+		o.Spec.Alerting.MuteWindows[0] = v1alpha1.MuteWindow{StartTime: "22:00", EndTime: "02:00"}
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, "mute window endTime 02:00 must be after startTime 22:00, split windows crossing midnight into two")
+	})
+
+	t.Run("invalidTime", func(t *testing.T) {
+		o := objective()
+		o.Spec.Alerting.MuteWindows[0].StartTime = "2am"
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, `mute window startTime: time must be formatted as HH:MM, got "2am"`)
+
+		o.Spec.Alerting.MuteWindows[0].StartTime = "02:00"
+		o.Spec.Alerting.MuteWindows[0].EndTime = "24:30"
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, `mute window endTime: time must be between 00:00 and 24:00, got "24:30"`)
+	})
+
+	t.Run("invalidWeekday", func(t *testing.T) {
+		o := objective()
+		o.Spec.Alerting.MuteWindows[1].Weekdays = []string{"weekend"}
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, `mute window weekday must be one of monday to sunday, got "weekend"`)
+	})
+}
+
+func TestServiceLevelObjective_MaintenanceWindows(t *testing.T) {
+	objective := func() *v1alpha1.ServiceLevelObjective {
+		return &v1alpha1.ServiceLevelObjective{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "name",
+				Namespace: "namespace",
+			},
+			Spec: v1alpha1.ServiceLevelObjectiveSpec{
+				Target: "99",
+				Window: "2w",
+				ServiceLevelIndicator: v1alpha1.ServiceLevelIndicator{
+					Ratio: &v1alpha1.RatioIndicator{
+						Errors: v1alpha1.Query{Metric: `foo{foo="bar",code=~"5.."}`},
+						Total:  v1alpha1.Query{Metric: `foo{foo="bar"}`},
+					},
+				},
+				Alerting: v1alpha1.Alerting{
+					MaintenanceWindows: []v1alpha1.MaintenanceWindow{
+						{Start: "2024-06-01T22:00:00Z", End: "2024-06-02T04:00:00+02:00"},
+					},
+				},
+			},
+		}
+	}
+
+	o := objective()
+	warn, err := o.ValidateCreate()
+	require.NoError(t, err)
+	require.Nil(t, warn)
+
+	internal, err := o.Internal()
+	require.NoError(t, err)
+	require.Len(t, internal.Alerting.MaintenanceWindows, 1)
+	require.Equal(t, int64(1717279200), internal.Alerting.MaintenanceWindows[0].Start.Unix())
+	require.Equal(t, int64(1717293600), internal.Alerting.MaintenanceWindows[0].End.Unix())
+
+	t.Run("invalidTime", func(t *testing.T) {
+		o := objective()
+		o.Spec.Alerting.MaintenanceWindows[0].Start = "2024-06-01 22:00"
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, `maintenance window start must be RFC3339: parsing time "2024-06-01 22:00" as "2006-01-02T15:04:05Z07:00": cannot parse " 22:00" as "T"`)
+	})
+
+	t.Run("endBeforeStart", func(t *testing.T) {
+		o := objective()
+		o.Spec.Alerting.MaintenanceWindows[0].End = "2024-06-02T00:00:00+02:00"
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, "maintenance window end 2024-06-02T00:00:00+02:00 must be after start 2024-06-01T22:00:00Z")
+	})
+}
+
+func TestServiceLevelObjective_AlertingWindows(t *testing.T) {
+	objective := func() *v1alpha1.ServiceLevelObjective {
+		return &v1alpha1.ServiceLevelObjective{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "name",
+				Namespace: "namespace",
+			},
+			Spec: v1alpha1.ServiceLevelObjectiveSpec{
+				Target: "99",
+				Window: "2w",
+				ServiceLevelIndicator: v1alpha1.ServiceLevelIndicator{
+					Ratio: &v1alpha1.RatioIndicator{
+						Errors: v1alpha1.Query{Metric: `foo{foo="bar",code=~"5.."}`},
+						Total:  v1alpha1.Query{Metric: `foo{foo="bar"}`},
+					},
+				},
+				Alerting: v1alpha1.Alerting{
+					Windows: []v1alpha1.AlertingWindow{
+						{Severity: "critical", Short: "5m", Long: "1h", Factor: "14.4", For: "2m"},
+						{Severity: "warning", Short: "6h", Long: "3d", Factor: "1"},
+					},
+				},
+			},
+		}
+	}
+
+	o := objective()
+	warn, err := o.ValidateCreate()
+	require.NoError(t, err)
+	require.Nil(t, warn)
+
+	internal, err := o.Internal()
+	require.NoError(t, err)
+	require.Equal(t, []slo.Window{
+		{Severity: "critical", For: 2 * time.Minute, Short: 5 * time.Minute, Long: time.Hour, Factor: 14.4},
+		{Severity: "warning", For: 3 * time.Hour, Short: 6 * time.Hour, Long: 3 * 24 * time.Hour, Factor: 1},
+	}, internal.Alerting.Windows)
+	require.Equal(t, internal.Alerting.Windows, internal.Windows())
+
+	t.Run("default", func(t *testing.T) {
+		o := objective()
+		o.Spec.Alerting.Windows = nil
+		internal, err := o.Internal()
+		require.NoError(t, err)
+		require.Nil(t, internal.Alerting.Windows)
+		require.Equal(t, slo.Windows(14*24*time.Hour), internal.Windows())
+	})
+
+	t.Run("severity", func(t *testing.T) {
+		o := objective()
+		o.Spec.Alerting.Windows[1].Severity = "ticket"
+		internal, err := o.Internal()
+		require.NoError(t, err)
+		require.Equal(t, slo.Severity("ticket"), internal.Alerting.Windows[1].Severity)
+
+		o.Spec.Alerting.Windows[1].Severity = ""
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, "alerting window severity must be set")
+	})
+
+	t.Run("labels", func(t *testing.T) {
+		o := objective()
+		o.Spec.Owner = &v1alpha1.Owner{Team: "foo"}
+		o.Spec.Alerting.Labels = map[string]string{"service": "api"}
+		o.Spec.Alerting.Annotations = map[string]string{"runbook_url": "https://example.com/runbook"}
+		o.Spec.Alerting.Windows[1].Labels = map[string]string{"route": "tickets"}
+		o.Spec.Alerting.Windows[1].Annotations = map[string]string{"summary": "Slow burn"}
+
+		_, err := o.ValidateCreate()
+		require.NoError(t, err)
+		internal, err := o.Internal()
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"team": "foo", "service": "api"}, internal.Alerting.Labels)
+		require.Equal(t, map[string]string{"runbook_url": "https://example.com/runbook"}, internal.Alerting.Annotations)
+		require.Equal(t, map[string]string{"route": "tickets"}, internal.Alerting.Windows[1].Labels)
+		require.Equal(t, map[string]string{"summary": "Slow burn"}, internal.Alerting.Windows[1].Annotations)
+
+		o.Spec.Alerting.Labels = map[string]string{"severity": "page"}
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, "alerting must not set the severity label, which is set by Pyrra")
+
+		o.Spec.Alerting.Labels = map[string]string{"team": "bar"}
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, "alerting must not set the team label, which is set by the owner")
+
+		o.Spec.Alerting.Labels = nil
+		o.Spec.Alerting.Windows[1].Labels = map[string]string{"route-to": "tickets"}
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, `alerting window label name "route-to" is invalid`)
+	})
+
+	t.Run("longShorterThanShort", func(t *testing.T) {
+		o := objective()
+		o.Spec.Alerting.Windows[0].Long = "5m"
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, "alerting window long 5m must be longer than short 5m")
+	})
+
+	t.Run("longerThanWindow", func(t *testing.T) {
+		o := objective()
+		o.Spec.Alerting.Windows[1].Long = "4w"
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, "alerting window long 4w must not be longer than the objective's window")
+	})
+
+	t.Run("factor", func(t *testing.T) {
+		o := objective()
+		o.Spec.Alerting.Windows[0].Factor = "fast"
+		_, err := o.ValidateCreate()
+		require.ErrorContains(t, err, `failed to parse alerting window factor "fast"`)
+
+		// A 99% target can burn at most 100 times faster than sustainable.
+		o.Spec.Alerting.Windows[0].Factor = "100"
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, "alerting window factor 100 must be below 100, as the error ratio can never burn the error budget faster")
+	})
+
+	t.Run("keepFiringFor", func(t *testing.T) {
+		o := objective()
+		o.Spec.Alerting.KeepFiringFor = "10m"
+		o.Spec.Alerting.PartialResponseStrategy = "warn"
+		o.Spec.Alerting.Inhibit = true
+		_, err := o.ValidateCreate()
+		require.NoError(t, err)
+		internal, err := o.Internal()
+		require.NoError(t, err)
+		require.Equal(t, 10*time.Minute, internal.Alerting.KeepFiringFor)
+		require.Equal(t, "warn", internal.Alerting.PartialResponseStrategy)
+		require.True(t, internal.Alerting.Inhibit)
+
+		o.Spec.Alerting.KeepFiringFor = "ten minutes"
+		_, err = o.ValidateCreate()
+		require.ErrorContains(t, err, `failed to parse alerting keepFiringFor "ten minutes", it must be a duration like 10m`)
+
+		o.Spec.Alerting.KeepFiringFor = "4w"
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, "alerting keepFiringFor 4w must not be longer than the objective's window")
+
+		o.Spec.Alerting.KeepFiringFor = ""
+		o.Spec.Alerting.PartialResponseStrategy = "ignore"
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, `alerting partialResponseStrategy must be warn or abort, got "ignore"`)
+	})
+
+	t.Run("absent", func(t *testing.T) {
+		o := objective()
+		o.Spec.Alerting.AbsentSeverity = "warning"
+		o.Spec.Alerting.AbsentOverTime = "15m"
+		_, err := o.ValidateCreate()
+		require.NoError(t, err)
+		internal, err := o.Internal()
+		require.NoError(t, err)
+		require.Equal(t, "warning", internal.Alerting.AbsentSeverity)
+		require.Equal(t, 15*time.Minute, internal.Alerting.AbsentOverTime)
+
+		o.Spec.Alerting.AbsentOverTime = "fifteen minutes"
+		_, err = o.ValidateCreate()
+		require.ErrorContains(t, err, `failed to parse alerting absentOverTime "fifteen minutes", it must be a duration like 15m`)
+
+		o.Spec.Alerting.AbsentOverTime = "8w"
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, "alerting absentOverTime 8w must be greater than 0 and not longer than the objective's window")
+	})
+
+	t.Run("externalLabels", func(t *testing.T) {
+		o := objective()
+		o.Spec.ExternalLabels = map[string]string{"cluster": "eu1"}
+		_, err := o.ValidateCreate()
+		require.NoError(t, err)
+		internal, err := o.Internal()
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"cluster": "eu1"}, internal.ExternalLabels)
+
+		o.Spec.ExternalLabels = map[string]string{"slo": "other"}
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, "externalLabels must not set the slo label, which is set by Pyrra")
+
+		o.Spec.ExternalLabels = map[string]string{"cluster-name": "eu1"}
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, `externalLabels label name "cluster-name" is invalid`)
+	})
+
+	t.Run("ruleGroup", func(t *testing.T) {
+		o := objective()
+		o.Spec.RuleGroupInterval = "2m"
+		o.Spec.RuleGroupLimit = 100
+		_, err := o.ValidateCreate()
+		require.NoError(t, err)
+		internal, err := o.Internal()
+		require.NoError(t, err)
+		require.Equal(t, 2*time.Minute, internal.RuleGroupInterval)
+		require.Equal(t, 100, internal.RuleGroupLimit)
+
+		o.Spec.RuleGroupInterval = "two minutes"
+		_, err = o.ValidateCreate()
+		require.ErrorContains(t, err, `failed to parse ruleGroupInterval "two minutes", it must be a duration like 1m`)
+
+		o.Spec.RuleGroupInterval = "10m"
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, "ruleGroupInterval 10m must not be longer than the short alerting window 5m")
+
+		o.Spec.RuleGroupInterval = ""
+		o.Spec.RuleGroupLimit = -1
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, "ruleGroupLimit must not be negative")
+	})
+
+	t.Run("repeated", func(t *testing.T) {
+		o := objective()
+		o.Spec.Alerting.Windows[1] = o.Spec.Alerting.Windows[0]
+		o.Spec.Alerting.Windows[1].Severity = "warning"
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, "alerting windows must not repeat the short 5m and long 1h windows")
+	})
+}
+
+func TestServiceLevelObjective_Owner(t *testing.T) {
+	objective := func() *v1alpha1.ServiceLevelObjective {
+		return &v1alpha1.ServiceLevelObjective{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "name",
+				Namespace: "namespace",
+			},
+			Spec: v1alpha1.ServiceLevelObjectiveSpec{
+				Target: "99",
+				Window: "2w",
+				ServiceLevelIndicator: v1alpha1.ServiceLevelIndicator{
+					Ratio: &v1alpha1.RatioIndicator{
+						Errors: v1alpha1.Query{Metric: `foo{foo="bar",code=~"5.."}`},
+						Total:  v1alpha1.Query{Metric: `foo{foo="bar"}`},
+					},
+				},
+				Owner: &v1alpha1.Owner{
+					Team:             "checkout",
+					Slack:            "#checkout-alerts",
+					EscalationPolicy: "P1234",
+				},
+			},
+		}
+	}
+
+	o := objective()
+	warn, err := o.ValidateCreate()
+	require.NoError(t, err)
+	require.Nil(t, warn)
+
+	internal, err := o.Internal()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"team":              "checkout",
+		"slack_channel":     "#checkout-alerts",
+		"escalation_policy": "P1234",
+	}, internal.Alerting.Labels)
+	require.Equal(t, &slo.Owner{Team: "checkout", Slack: "#checkout-alerts", EscalationPolicy: "P1234"}, internal.Owner)
+
+	t.Run("runbookAndTier", func(t *testing.T) {
+		o := objective()
+		o.Spec.Owner.RunbookURL = "https://runbooks.example.com/checkout"
+		o.Spec.Owner.Tier = "tier-1"
+		o.Spec.Alerting.Annotations = map[string]string{"dashboard": "https://grafana.example.com"}
+		_, err := o.ValidateCreate()
+		require.NoError(t, err)
+
+		internal, err := o.Internal()
+		require.NoError(t, err)
+		require.Equal(t, "tier-1", internal.Alerting.Labels["tier"])
+		require.Equal(t, map[string]string{
+			"runbook_url": "https://runbooks.example.com/checkout",
+			"dashboard":   "https://grafana.example.com",
+		}, internal.Alerting.Annotations)
+		require.Equal(t, "https://runbooks.example.com/checkout", internal.Owner.RunbookURL)
+		require.Equal(t, "tier-1", internal.Owner.Tier)
+
+		o.Spec.Alerting.Annotations = map[string]string{"runbook_url": "https://example.com"}
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, "alerting must not set the runbook_url annotation, which is set by the owner")
+
+		o.Spec.Alerting.Annotations = nil
+		o.Spec.Owner.RunbookURL = "runbooks/checkout"
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, `owner runbookURL must be an http or https URL, got "runbooks/checkout"`)
+	})
+
+	t.Run("emptyTeam", func(t *testing.T) {
+		o := objective()
+		o.Spec.Owner.Team = ""
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, "owner team must be set")
+	})
+
+	t.Run("invalidSlack", func(t *testing.T) {
+		o := objective()
+		o.Spec.Owner.Slack = "checkout alerts"
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, `owner slack must be a channel like #team-alerts, got "checkout alerts"`)
+	})
+
+	t.Run("conflictingTeamLabel", func(t *testing.T) {
+		o := objective()
+		o.Labels = map[string]string{"pyrra.dev/team": "payments"}
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, `owner team "checkout" must match the pyrra.dev/team label "payments"`)
+
+		o.Labels = map[string]string{"pyrra.dev/team": "checkout"}
+		_, err = o.ValidateCreate()
+		require.NoError(t, err)
+	})
+}
+
+func TestServiceLevelObjective_SLA(t *testing.T) {
+	objective := func() *v1alpha1.ServiceLevelObjective {
+		return &v1alpha1.ServiceLevelObjective{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "name",
+				Namespace: "namespace",
+			},
+			Spec: v1alpha1.ServiceLevelObjectiveSpec{
+				Target: "99.9",
+				Window: "2w",
+				ServiceLevelIndicator: v1alpha1.ServiceLevelIndicator{
+					Ratio: &v1alpha1.RatioIndicator{
+						Errors: v1alpha1.Query{Metric: `foo{foo="bar",code=~"5.."}`},
+						Total:  v1alpha1.Query{Metric: `foo{foo="bar"}`},
+					},
+				},
+				SLA: &v1alpha1.SLA{Target: "99.5"},
+			},
+		}
+	}
+
+	o := objective()
+	warn, err := o.ValidateCreate()
+	require.NoError(t, err)
+	require.Nil(t, warn)
+
+	internal, err := o.Internal()
+	require.NoError(t, err)
+	require.NotNil(t, internal.SLATarget)
+	require.InDelta(t, 0.995, *internal.SLATarget, 1e-9)
+
+	o.Spec.SLA = nil
+	internal, err = o.Internal()
+	require.NoError(t, err)
+	require.Nil(t, internal.SLATarget)
+
+	t.Run("emptyTarget", func(t *testing.T) {
+		o := objective()
+		o.Spec.SLA.Target = ""
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, "sla target must be set")
+	})
+
+	t.Run("invalidTarget", func(t *testing.T) {
+		o := objective()
+		o.Spec.SLA.Target = "foo"
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, `failed to parse sla target: strconv.ParseFloat: parsing "foo": invalid syntax`)
+
+		o.Spec.SLA.Target = "-1"
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, "sla target must be between 0 and 100")
+	})
+
+	t.Run("stricterThanObjective", func(t *testing.T) {
+		o := objective()
+		o.Spec.SLA.Target = "99.95"
+		_, err := o.ValidateCreate()
+		require.EqualError(t, err, "sla target 99.95 must not be higher than the objective's target 99.9")
+	})
+
+	t.Run("calendar", func(t *testing.T) {
+		o := objective()
+		o.Spec.Calendar = "month"
+		_, err := o.ValidateCreate()
+		require.NoError(t, err)
+
+		internal, err := o.Internal()
+		require.NoError(t, err)
+		require.Equal(t, slo.CalendarMonth, internal.Calendar)
+
+		o.Spec.Calendar = "year"
+		_, err = o.ValidateCreate()
+		require.EqualError(t, err, `calendar must be one of week, month or quarter, got "year"`)
+	})
+}
+
+func TestServiceLevelObjective_StableRuleNames(t *testing.T) {
+	o := &v1alpha1.ServiceLevelObjective{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "name",
+			Namespace: "namespace",
+		},
+		Spec: v1alpha1.ServiceLevelObjectiveSpec{
+			Target: "99.9",
+			Window: "2w",
+			ServiceLevelIndicator: v1alpha1.ServiceLevelIndicator{
+				Ratio: &v1alpha1.RatioIndicator{
+					Errors: v1alpha1.Query{Metric: `foo{foo="bar",code=~"5.."}`},
+					Total:  v1alpha1.Query{Metric: `foo{foo="bar"}`},
+				},
+			},
+		},
+	}
+
+	internal, err := o.Internal()
+	require.NoError(t, err)
+	require.False(t, internal.StableRuleNames)
+
+	o.Spec.StableRuleNames = true
+	internal, err = o.Internal()
+	require.NoError(t, err)
+	require.True(t, internal.StableRuleNames)
+}
+
+func TestServiceLevelObjective_SharedBurnrates(t *testing.T) {
+	o := &v1alpha1.ServiceLevelObjective{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "name",
+			Namespace: "namespace",
+		},
+		Spec: v1alpha1.ServiceLevelObjectiveSpec{
+			Target: "99.9",
+			Window: "2w",
+			ServiceLevelIndicator: v1alpha1.ServiceLevelIndicator{
+				Ratio: &v1alpha1.RatioIndicator{
+					Errors: v1alpha1.Query{Metric: `foo{foo="bar",code=~"5.."}`},
+					Total:  v1alpha1.Query{Metric: `foo{foo="bar"}`},
+				},
+			},
+			SharedBurnrates: true,
+		},
+	}
+
+	_, err := o.ValidateCreate()
+	require.NoError(t, err)
+
+	internal, err := o.Internal()
+	require.NoError(t, err)
+	require.True(t, internal.SharedBurnrates)
 }
@@ -0,0 +1,76 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&ServiceLevelObjectiveRevision{}, &ServiceLevelObjectiveRevisionList{})
+}
+
+// RevisionObjectiveLabel is set on revisions to select all revisions of an objective by its name.
+const RevisionObjectiveLabel = "pyrra.dev/objective"
+
+// +kubebuilder:object:root=true
+
+// ServiceLevelObjectiveRevisionList contains a list of ServiceLevelObjectiveRevision.
+type ServiceLevelObjectiveRevisionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServiceLevelObjectiveRevision `json:"items"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=slorev
+// +kubebuilder:printcolumn:name="Objective",type=string,JSONPath=`.spec.objective`
+// +kubebuilder:printcolumn:name="Generation",type=integer,JSONPath=`.spec.generation`
+// +kubebuilder:printcolumn:name="Changed By",type=string,JSONPath=`.spec.changedBy`
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ServiceLevelObjectiveRevision records a change of a ServiceLevelObjective's spec.
+// Revisions are created by Pyrra's Kubernetes operator and are owned by their objective.
+type ServiceLevelObjectiveRevision struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ServiceLevelObjectiveRevisionSpec `json:"spec,omitempty"`
+}
+
+// ServiceLevelObjectiveRevisionSpec describes a revision of a ServiceLevelObjective.
+type ServiceLevelObjectiveRevisionSpec struct {
+	// Objective is the name of the ServiceLevelObjective in the same namespace.
+	Objective string `json:"objective"`
+
+	// Generation of the ServiceLevelObjective this revision records.
+	Generation int64 `json:"generation"`
+
+	// +optional
+	// ChangedBy is the field manager that changed the spec last, like kubectl-edit or argocd-controller.
+	ChangedBy string `json:"changedBy,omitempty"`
+
+	// ChangedAt is when the spec was changed.
+	ChangedAt metav1.Time `json:"changedAt"`
+
+	// ObjectiveSpec is the spec of the ServiceLevelObjective at this revision.
+	ObjectiveSpec ServiceLevelObjectiveSpec `json:"objectiveSpec"`
+
+	// +optional
+	// Diff is the unified diff of the spec to the previous revision. It is empty for the first revision.
+	Diff string `json:"diff,omitempty"`
+}
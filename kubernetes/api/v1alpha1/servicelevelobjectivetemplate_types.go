@@ -0,0 +1,112 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&ServiceLevelObjectiveTemplate{}, &ServiceLevelObjectiveTemplateList{})
+}
+
+// +kubebuilder:object:root=true
+
+// ServiceLevelObjectiveTemplateList contains a list of ServiceLevelObjectiveTemplate.
+type ServiceLevelObjectiveTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServiceLevelObjectiveTemplate `json:"items"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=slotemplate
+// +kubebuilder:printcolumn:name="Objectives",type=integer,JSONPath=`.status.objectiveCount`
+// +kubebuilder:printcolumn:name="Last Discovery",type="date",JSONPath=".status.lastDiscovery"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ServiceLevelObjectiveTemplate creates a ServiceLevelObjective for each value it discovers,
+// like one per handler or tenant. The objectives are owned by the template and kept in sync with the discovered values.
+type ServiceLevelObjectiveTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ServiceLevelObjectiveTemplateSpec   `json:"spec,omitempty"`
+	Status ServiceLevelObjectiveTemplateStatus `json:"status,omitempty"`
+}
+
+// ServiceLevelObjectiveTemplateSpec describes the discovery and the objectives created for the discovered values.
+type ServiceLevelObjectiveTemplateSpec struct {
+	// Discovery finds the values to create objectives for.
+	Discovery TemplateDiscovery `json:"discovery"`
+
+	// Objective is the template of the objectives. Its strings are Go templates,
+	// rendered with the discovered .Value and the .Namespace and .Name of the template,
+	// like http_requests_total{handler="{{.Value}}"}.
+	Objective ObjectiveTemplate `json:"objective"`
+}
+
+// TemplateDiscovery finds the values of a template. Exactly one of its discoveries must be set.
+type TemplateDiscovery struct {
+	// +optional
+	// Prometheus discovers the values of a label of the series selected in Prometheus.
+	Prometheus *PrometheusDiscovery `json:"prometheus,omitempty"`
+
+	// +optional
+	// Services discovers the Services in the template's namespace matching the selector.
+	// Their names are the values.
+	Services *metav1.LabelSelector `json:"services,omitempty"`
+}
+
+// PrometheusDiscovery discovers the values of a label like Grafana's label_values(selector, label).
+type PrometheusDiscovery struct {
+	// Selector selects the series to discover the label values of, like http_requests_total{job="api"}.
+	Selector string `json:"selector"`
+
+	// Label whose values are discovered, like handler.
+	Label string `json:"label"`
+}
+
+// ObjectiveTemplate is the template of the objectives created by a ServiceLevelObjectiveTemplate.
+type ObjectiveTemplate struct {
+	// +optional
+	// Labels of the objectives, like pyrra.dev/team.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// +optional
+	// Annotations of the objectives, like pyrra.dev/ruler.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Spec of the objectives.
+	Spec ServiceLevelObjectiveSpec `json:"spec"`
+}
+
+// ServiceLevelObjectiveTemplateStatus is the result of the last discovery.
+type ServiceLevelObjectiveTemplateStatus struct {
+	// +optional
+	// Objectives are the names of the objectives created for the discovered values.
+	Objectives []string `json:"objectives,omitempty"`
+
+	// +optional
+	// ObjectiveCount is the number of objectives.
+	ObjectiveCount int `json:"objectiveCount,omitempty"`
+
+	// +optional
+	// LastDiscovery is when the values were discovered last.
+	LastDiscovery *metav1.Time `json:"lastDiscovery,omitempty"`
+}
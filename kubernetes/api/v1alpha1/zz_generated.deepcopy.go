@@ -22,6 +22,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -43,6 +44,49 @@ func (in *Alerting) DeepCopyInto(out *Alerting) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.PagerDuty != nil {
+		in, out := &in.PagerDuty, &out.PagerDuty
+		*out = new(PagerDutyAlerting)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Opsgenie != nil {
+		in, out := &in.Opsgenie, &out.Opsgenie
+		*out = new(OpsgenieAlerting)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MuteWindows != nil {
+		in, out := &in.MuteWindows, &out.MuteWindows
+		*out = make([]MuteWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindow, len(*in))
+		copy(*out, *in)
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Windows != nil {
+		in, out := &in.Windows, &out.Windows
+		*out = make([]AlertingWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Alerting.
@@ -55,6 +99,35 @@ func (in *Alerting) DeepCopy() *Alerting {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AlertingWindow) DeepCopyInto(out *AlertingWindow) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AlertingWindow.
+func (in *AlertingWindow) DeepCopy() *AlertingWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(AlertingWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BoolGaugeIndicator) DeepCopyInto(out *BoolGaugeIndicator) {
 	*out = *in
@@ -76,6 +149,177 @@ func (in *BoolGaugeIndicator) DeepCopy() *BoolGaugeIndicator {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BudgetPolicyStatus) DeepCopyInto(out *BudgetPolicyStatus) {
+	*out = *in
+	if in.Thresholds != nil {
+		in, out := &in.Thresholds, &out.Thresholds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BudgetPolicyStatus.
+func (in *BudgetPolicyStatus) DeepCopy() *BudgetPolicyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BudgetPolicyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CompositeIndicator) DeepCopyInto(out *CompositeIndicator) {
+	*out = *in
+	if in.Objectives != nil {
+		in, out := &in.Objectives, &out.Objectives
+		*out = make([]CompositeObjective, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CompositeIndicator.
+func (in *CompositeIndicator) DeepCopy() *CompositeIndicator {
+	if in == nil {
+		return nil
+	}
+	out := new(CompositeIndicator)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CompositeObjective) DeepCopyInto(out *CompositeObjective) {
+	*out = *in
+	if in.Objective != nil {
+		in, out := &in.Objective, &out.Objective
+		*out = new(ServiceLevelObjective)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CompositeObjective.
+func (in *CompositeObjective) DeepCopy() *CompositeObjective {
+	if in == nil {
+		return nil
+	}
+	out := new(CompositeObjective)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ErrorBudgetPolicy) DeepCopyInto(out *ErrorBudgetPolicy) {
+	*out = *in
+	if in.Thresholds != nil {
+		in, out := &in.Thresholds, &out.Thresholds
+		*out = make([]ErrorBudgetThreshold, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ErrorBudgetPolicy.
+func (in *ErrorBudgetPolicy) DeepCopy() *ErrorBudgetPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ErrorBudgetPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ErrorBudgetThreshold) DeepCopyInto(out *ErrorBudgetThreshold) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Silence != nil {
+		in, out := &in.Silence, &out.Silence
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ErrorBudgetThreshold.
+func (in *ErrorBudgetThreshold) DeepCopy() *ErrorBudgetThreshold {
+	if in == nil {
+		return nil
+	}
+	out := new(ErrorBudgetThreshold)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExpressionIndicator) DeepCopyInto(out *ExpressionIndicator) {
+	*out = *in
+	if in.Grouping != nil {
+		in, out := &in.Grouping, &out.Grouping
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExpressionIndicator.
+func (in *ExpressionIndicator) DeepCopy() *ExpressionIndicator {
+	if in == nil {
+		return nil
+	}
+	out := new(ExpressionIndicator)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GRPCIndicator) DeepCopyInto(out *GRPCIndicator) {
+	*out = *in
+	if in.Grouping != nil {
+		in, out := &in.Grouping, &out.Grouping
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GRPCIndicator.
+func (in *GRPCIndicator) DeepCopy() *GRPCIndicator {
+	if in == nil {
+		return nil
+	}
+	out := new(GRPCIndicator)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IstioIndicator) DeepCopyInto(out *IstioIndicator) {
+	*out = *in
+	if in.Grouping != nil {
+		in, out := &in.Grouping, &out.Grouping
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IstioIndicator.
+func (in *IstioIndicator) DeepCopy() *IstioIndicator {
+	if in == nil {
+		return nil
+	}
+	out := new(IstioIndicator)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LatencyIndicator) DeepCopyInto(out *LatencyIndicator) {
 	*out = *in
@@ -98,6 +342,81 @@ func (in *LatencyIndicator) DeepCopy() *LatencyIndicator {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LinkerdIndicator) DeepCopyInto(out *LinkerdIndicator) {
+	*out = *in
+	if in.Grouping != nil {
+		in, out := &in.Grouping, &out.Grouping
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LinkerdIndicator.
+func (in *LinkerdIndicator) DeepCopy() *LinkerdIndicator {
+	if in == nil {
+		return nil
+	}
+	out := new(LinkerdIndicator)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LogsIndicator) DeepCopyInto(out *LogsIndicator) {
+	*out = *in
+	if in.Grouping != nil {
+		in, out := &in.Grouping, &out.Grouping
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LogsIndicator.
+func (in *LogsIndicator) DeepCopy() *LogsIndicator {
+	if in == nil {
+		return nil
+	}
+	out := new(LogsIndicator)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MuteWindow) DeepCopyInto(out *MuteWindow) {
+	*out = *in
+	if in.Weekdays != nil {
+		in, out := &in.Weekdays, &out.Weekdays
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MuteWindow.
+func (in *MuteWindow) DeepCopy() *MuteWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MuteWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NativeLatencyIndicator) DeepCopyInto(out *NativeLatencyIndicator) {
 	*out = *in
@@ -119,6 +438,115 @@ func (in *NativeLatencyIndicator) DeepCopy() *NativeLatencyIndicator {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectiveTemplate) DeepCopyInto(out *ObjectiveTemplate) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectiveTemplate.
+func (in *ObjectiveTemplate) DeepCopy() *ObjectiveTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectiveTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OpsgenieAlerting) DeepCopyInto(out *OpsgenieAlerting) {
+	*out = *in
+	if in.Priority != nil {
+		in, out := &in.Priority, &out.Priority
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OpsgenieAlerting.
+func (in *OpsgenieAlerting) DeepCopy() *OpsgenieAlerting {
+	if in == nil {
+		return nil
+	}
+	out := new(OpsgenieAlerting)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Owner) DeepCopyInto(out *Owner) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Owner.
+func (in *Owner) DeepCopy() *Owner {
+	if in == nil {
+		return nil
+	}
+	out := new(Owner)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PagerDutyAlerting) DeepCopyInto(out *PagerDutyAlerting) {
+	*out = *in
+	if in.Urgency != nil {
+		in, out := &in.Urgency, &out.Urgency
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PagerDutyAlerting.
+func (in *PagerDutyAlerting) DeepCopy() *PagerDutyAlerting {
+	if in == nil {
+		return nil
+	}
+	out := new(PagerDutyAlerting)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrometheusDiscovery) DeepCopyInto(out *PrometheusDiscovery) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusDiscovery.
+func (in *PrometheusDiscovery) DeepCopy() *PrometheusDiscovery {
+	if in == nil {
+		return nil
+	}
+	out := new(PrometheusDiscovery)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Query) DeepCopyInto(out *Query) {
 	*out = *in
@@ -134,6 +562,27 @@ func (in *Query) DeepCopy() *Query {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QuantileIndicator) DeepCopyInto(out *QuantileIndicator) {
+	*out = *in
+	out.Total = in.Total
+	if in.Grouping != nil {
+		in, out := &in.Grouping, &out.Grouping
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QuantileIndicator.
+func (in *QuantileIndicator) DeepCopy() *QuantileIndicator {
+	if in == nil {
+		return nil
+	}
+	out := new(QuantileIndicator)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RatioIndicator) DeepCopyInto(out *RatioIndicator) {
 	*out = *in
@@ -156,6 +605,21 @@ func (in *RatioIndicator) DeepCopy() *RatioIndicator {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SLA) DeepCopyInto(out *SLA) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SLA.
+func (in *SLA) DeepCopy() *SLA {
+	if in == nil {
+		return nil
+	}
+	out := new(SLA)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceLevelIndicator) DeepCopyInto(out *ServiceLevelIndicator) {
 	*out = *in
@@ -179,6 +643,41 @@ func (in *ServiceLevelIndicator) DeepCopyInto(out *ServiceLevelIndicator) {
 		*out = new(BoolGaugeIndicator)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Quantile != nil {
+		in, out := &in.Quantile, &out.Quantile
+		*out = new(QuantileIndicator)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Expression != nil {
+		in, out := &in.Expression, &out.Expression
+		*out = new(ExpressionIndicator)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Logs != nil {
+		in, out := &in.Logs, &out.Logs
+		*out = new(LogsIndicator)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Composite != nil {
+		in, out := &in.Composite, &out.Composite
+		*out = new(CompositeIndicator)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Istio != nil {
+		in, out := &in.Istio, &out.Istio
+		*out = new(IstioIndicator)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Linkerd != nil {
+		in, out := &in.Linkerd, &out.Linkerd
+		*out = new(LinkerdIndicator)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GRPC != nil {
+		in, out := &in.GRPC, &out.GRPC
+		*out = new(GRPCIndicator)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceLevelIndicator.
@@ -197,7 +696,7 @@ func (in *ServiceLevelObjective) DeepCopyInto(out *ServiceLevelObjective) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceLevelObjective.
@@ -250,11 +749,108 @@ func (in *ServiceLevelObjectiveList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceLevelObjectiveRevision) DeepCopyInto(out *ServiceLevelObjectiveRevision) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceLevelObjectiveRevision.
+func (in *ServiceLevelObjectiveRevision) DeepCopy() *ServiceLevelObjectiveRevision {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceLevelObjectiveRevision)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ServiceLevelObjectiveRevision) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceLevelObjectiveRevisionList) DeepCopyInto(out *ServiceLevelObjectiveRevisionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ServiceLevelObjectiveRevision, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceLevelObjectiveRevisionList.
+func (in *ServiceLevelObjectiveRevisionList) DeepCopy() *ServiceLevelObjectiveRevisionList {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceLevelObjectiveRevisionList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ServiceLevelObjectiveRevisionList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceLevelObjectiveRevisionSpec) DeepCopyInto(out *ServiceLevelObjectiveRevisionSpec) {
+	*out = *in
+	in.ChangedAt.DeepCopyInto(&out.ChangedAt)
+	in.ObjectiveSpec.DeepCopyInto(&out.ObjectiveSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceLevelObjectiveRevisionSpec.
+func (in *ServiceLevelObjectiveRevisionSpec) DeepCopy() *ServiceLevelObjectiveRevisionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceLevelObjectiveRevisionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceLevelObjectiveSpec) DeepCopyInto(out *ServiceLevelObjectiveSpec) {
 	*out = *in
 	in.ServiceLevelIndicator.DeepCopyInto(&out.ServiceLevelIndicator)
 	in.Alerting.DeepCopyInto(&out.Alerting)
+	if in.Policy != nil {
+		in, out := &in.Policy, &out.Policy
+		*out = new(ErrorBudgetPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Owner != nil {
+		in, out := &in.Owner, &out.Owner
+		*out = new(Owner)
+		**out = **in
+	}
+	if in.SLA != nil {
+		in, out := &in.SLA, &out.SLA
+		*out = new(SLA)
+		**out = **in
+	}
+	if in.ExternalLabels != nil {
+		in, out := &in.ExternalLabels, &out.ExternalLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceLevelObjectiveSpec.
@@ -270,6 +866,18 @@ func (in *ServiceLevelObjectiveSpec) DeepCopy() *ServiceLevelObjectiveSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceLevelObjectiveStatus) DeepCopyInto(out *ServiceLevelObjectiveStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.BudgetPolicy != nil {
+		in, out := &in.BudgetPolicy, &out.BudgetPolicy
+		*out = new(BudgetPolicyStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceLevelObjectiveStatus.
@@ -281,3 +889,128 @@ func (in *ServiceLevelObjectiveStatus) DeepCopy() *ServiceLevelObjectiveStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceLevelObjectiveTemplate) DeepCopyInto(out *ServiceLevelObjectiveTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceLevelObjectiveTemplate.
+func (in *ServiceLevelObjectiveTemplate) DeepCopy() *ServiceLevelObjectiveTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceLevelObjectiveTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ServiceLevelObjectiveTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceLevelObjectiveTemplateList) DeepCopyInto(out *ServiceLevelObjectiveTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ServiceLevelObjectiveTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceLevelObjectiveTemplateList.
+func (in *ServiceLevelObjectiveTemplateList) DeepCopy() *ServiceLevelObjectiveTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceLevelObjectiveTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ServiceLevelObjectiveTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceLevelObjectiveTemplateSpec) DeepCopyInto(out *ServiceLevelObjectiveTemplateSpec) {
+	*out = *in
+	in.Discovery.DeepCopyInto(&out.Discovery)
+	in.Objective.DeepCopyInto(&out.Objective)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceLevelObjectiveTemplateSpec.
+func (in *ServiceLevelObjectiveTemplateSpec) DeepCopy() *ServiceLevelObjectiveTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceLevelObjectiveTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceLevelObjectiveTemplateStatus) DeepCopyInto(out *ServiceLevelObjectiveTemplateStatus) {
+	*out = *in
+	if in.Objectives != nil {
+		in, out := &in.Objectives, &out.Objectives
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastDiscovery != nil {
+		in, out := &in.LastDiscovery, &out.LastDiscovery
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceLevelObjectiveTemplateStatus.
+func (in *ServiceLevelObjectiveTemplateStatus) DeepCopy() *ServiceLevelObjectiveTemplateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceLevelObjectiveTemplateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateDiscovery) DeepCopyInto(out *TemplateDiscovery) {
+	*out = *in
+	if in.Prometheus != nil {
+		in, out := &in.Prometheus, &out.Prometheus
+		*out = new(PrometheusDiscovery)
+		**out = **in
+	}
+	if in.Services != nil {
+		in, out := &in.Services, &out.Services
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateDiscovery.
+func (in *TemplateDiscovery) DeepCopy() *TemplateDiscovery {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateDiscovery)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+var _ conversion.Convertible = &ServiceLevelObjective{}
+
+// ConvertTo converts the objective to the v1alpha1 hub version.
+func (in *ServiceLevelObjective) ConvertTo(hub conversion.Hub) error {
+	dst, ok := hub.(*v1alpha1.ServiceLevelObjective)
+	if !ok {
+		return fmt.Errorf("expected v1alpha1 ServiceLevelObjective, got %T", hub)
+	}
+	src := in.DeepCopy()
+
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Status = src.Status
+	dst.Spec = v1alpha1.ServiceLevelObjectiveSpec{
+		Description: src.Spec.Description,
+		Target:      src.Spec.Target,
+		Window:      src.Spec.Window,
+		ServiceLevelIndicator: v1alpha1.ServiceLevelIndicator{
+			Ratio:         src.Spec.ServiceLevelIndicator.Ratio,
+			Latency:       src.Spec.ServiceLevelIndicator.Latency,
+			LatencyNative: src.Spec.ServiceLevelIndicator.LatencyNative,
+			BoolGauge:     src.Spec.ServiceLevelIndicator.BoolGauge,
+			Quantile:      src.Spec.ServiceLevelIndicator.Quantile,
+			Expression:    src.Spec.ServiceLevelIndicator.Expression,
+			Logs:          src.Spec.ServiceLevelIndicator.Logs,
+			Composite:     src.Spec.ServiceLevelIndicator.Composite,
+			Istio:         src.Spec.ServiceLevelIndicator.Istio,
+			Linkerd:       src.Spec.ServiceLevelIndicator.Linkerd,
+			GRPC:          src.Spec.ServiceLevelIndicator.GRPC,
+		},
+		Alerting: v1alpha1.Alerting{
+			Burnrates:               src.Spec.Alerting.BurnRate.Enabled,
+			Name:                    src.Spec.Alerting.BurnRate.Name,
+			Windows:                 src.Spec.Alerting.BurnRate.Windows,
+			KeepFiringFor:           src.Spec.Alerting.BurnRate.KeepFiringFor,
+			Inhibit:                 src.Spec.Alerting.BurnRate.Inhibit,
+			Absent:                  src.Spec.Alerting.Absent.Enabled,
+			AbsentName:              src.Spec.Alerting.Absent.Name,
+			AbsentSeverity:          src.Spec.Alerting.Absent.Severity,
+			AbsentOverTime:          src.Spec.Alerting.Absent.OverTime,
+			PagerDuty:               src.Spec.Alerting.PagerDuty,
+			Opsgenie:                src.Spec.Alerting.Opsgenie,
+			MuteWindows:             src.Spec.Alerting.MuteWindows,
+			MaintenanceWindows:      src.Spec.Alerting.MaintenanceWindows,
+			Labels:                  src.Spec.Alerting.Labels,
+			Annotations:             src.Spec.Alerting.Annotations,
+			PartialResponseStrategy: src.Spec.Alerting.PartialResponseStrategy,
+		},
+		Policy:                  src.Spec.Policy,
+		Owner:                   src.Spec.Owner,
+		SLA:                     src.Spec.SLA,
+		Calendar:                src.Spec.Calendar,
+		StableRuleNames:         src.Spec.StableRuleNames,
+		SharedBurnrates:         src.Spec.SharedBurnrates,
+		Destination:             src.Spec.Destination,
+		ExternalLabels:          src.Spec.ExternalLabels,
+		RuleGroupInterval:       src.Spec.RuleGroupInterval,
+		RuleGroupLimit:          src.Spec.RuleGroupLimit,
+		Paused:                  src.Spec.Paused,
+		DeleteAlertsWhilePaused: src.Spec.DeleteAlertsWhilePaused,
+	}
+	return nil
+}
+
+// ConvertFrom converts the v1alpha1 hub version to the objective.
+// The deprecated alerting.disabled of v1alpha1 disables the burn rate alerts, like it does in v1alpha1.
+func (in *ServiceLevelObjective) ConvertFrom(hub conversion.Hub) error {
+	src, ok := hub.(*v1alpha1.ServiceLevelObjective)
+	if !ok {
+		return fmt.Errorf("expected v1alpha1 ServiceLevelObjective, got %T", hub)
+	}
+	src = src.DeepCopy()
+
+	burnrates := src.Spec.Alerting.Burnrates
+	if src.Spec.Alerting.Disabled != nil && *src.Spec.Alerting.Disabled {
+		disabled := false
+		burnrates = &disabled
+	}
+
+	in.ObjectMeta = src.ObjectMeta
+	in.Status = src.Status
+	in.Spec = ServiceLevelObjectiveSpec{
+		Description: src.Spec.Description,
+		Target:      src.Spec.Target,
+		Window:      src.Spec.Window,
+		ServiceLevelIndicator: ServiceLevelIndicator{
+			Ratio:         src.Spec.ServiceLevelIndicator.Ratio,
+			Latency:       src.Spec.ServiceLevelIndicator.Latency,
+			LatencyNative: src.Spec.ServiceLevelIndicator.LatencyNative,
+			BoolGauge:     src.Spec.ServiceLevelIndicator.BoolGauge,
+			Quantile:      src.Spec.ServiceLevelIndicator.Quantile,
+			Expression:    src.Spec.ServiceLevelIndicator.Expression,
+			Logs:          src.Spec.ServiceLevelIndicator.Logs,
+			Composite:     src.Spec.ServiceLevelIndicator.Composite,
+			Istio:         src.Spec.ServiceLevelIndicator.Istio,
+			Linkerd:       src.Spec.ServiceLevelIndicator.Linkerd,
+			GRPC:          src.Spec.ServiceLevelIndicator.GRPC,
+		},
+		Alerting: Alerting{
+			BurnRate: BurnRateAlerting{
+				Enabled:       burnrates,
+				Name:          src.Spec.Alerting.Name,
+				Windows:       src.Spec.Alerting.Windows,
+				KeepFiringFor: src.Spec.Alerting.KeepFiringFor,
+				Inhibit:       src.Spec.Alerting.Inhibit,
+			},
+			Absent: AbsentAlerting{
+				Enabled:  src.Spec.Alerting.Absent,
+				Name:     src.Spec.Alerting.AbsentName,
+				Severity: src.Spec.Alerting.AbsentSeverity,
+				OverTime: src.Spec.Alerting.AbsentOverTime,
+			},
+			PagerDuty:               src.Spec.Alerting.PagerDuty,
+			Opsgenie:                src.Spec.Alerting.Opsgenie,
+			MuteWindows:             src.Spec.Alerting.MuteWindows,
+			MaintenanceWindows:      src.Spec.Alerting.MaintenanceWindows,
+			Labels:                  src.Spec.Alerting.Labels,
+			Annotations:             src.Spec.Alerting.Annotations,
+			PartialResponseStrategy: src.Spec.Alerting.PartialResponseStrategy,
+		},
+		Policy:                  src.Spec.Policy,
+		Owner:                   src.Spec.Owner,
+		SLA:                     src.Spec.SLA,
+		Calendar:                src.Spec.Calendar,
+		StableRuleNames:         src.Spec.StableRuleNames,
+		SharedBurnrates:         src.Spec.SharedBurnrates,
+		Destination:             src.Spec.Destination,
+		ExternalLabels:          src.Spec.ExternalLabels,
+		RuleGroupInterval:       src.Spec.RuleGroupInterval,
+		RuleGroupLimit:          src.Spec.RuleGroupLimit,
+		Paused:                  src.Spec.Paused,
+		DeleteAlertsWhilePaused: src.Spec.DeleteAlertsWhilePaused,
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package v1beta1_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+	"sigs.k8s.io/yaml"
+
+	"github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+	"github.com/pyrra-dev/pyrra/kubernetes/api/v1beta1"
+)
+
+func TestServiceLevelObjective_Conversion(t *testing.T) {
+	objective := &v1beta1.ServiceLevelObjective{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "http",
+			Namespace: "monitoring",
+			Labels:    map[string]string{"pyrra.dev/team": "checkout"},
+		},
+		Spec: v1beta1.ServiceLevelObjectiveSpec{
+			Description: "HTTP requests",
+			Target:      "99.5",
+			Window:      "28d",
+			ServiceLevelIndicator: v1beta1.ServiceLevelIndicator{
+				Ratio: &v1alpha1.RatioIndicator{
+					Errors:   v1alpha1.Query{Metric: `http_requests_total{code=~"5.."}`},
+					Total:    v1alpha1.Query{Metric: `http_requests_total`},
+					Grouping: []string{"handler"},
+				},
+			},
+			Alerting: v1beta1.Alerting{
+				BurnRate: v1beta1.BurnRateAlerting{
+					Enabled:       ptr.To(true),
+					Name:          "HTTPErrorBudgetBurn",
+					Windows:       []v1alpha1.AlertingWindow{{Severity: "critical", Short: "5m", Long: "1h", Factor: "14"}},
+					KeepFiringFor: "10m",
+					Inhibit:       true,
+				},
+				Absent:                  v1beta1.AbsentAlerting{Enabled: ptr.To(false), Name: "HTTPMetricAbsent", Severity: "warning", OverTime: "15m"},
+				PagerDuty:               &v1alpha1.PagerDutyAlerting{Service: "checkout"},
+				MuteWindows:             []v1alpha1.MuteWindow{{StartTime: "02:00", EndTime: "04:00"}},
+				MaintenanceWindows:      []v1alpha1.MaintenanceWindow{{Start: "2024-06-01T22:00:00Z", End: "2024-06-02T02:00:00Z"}},
+				Labels:                  map[string]string{"tier": "frontend"},
+				Annotations:             map[string]string{"runbook_url": "https://example.com"},
+				PartialResponseStrategy: "warn",
+			},
+			Owner:                   &v1alpha1.Owner{Team: "checkout"},
+			SLA:                     &v1alpha1.SLA{Target: "99"},
+			Calendar:                "month",
+			StableRuleNames:         true,
+			SharedBurnrates:         true,
+			Destination:             "staging",
+			ExternalLabels:          map[string]string{"cluster": "eu1"},
+			RuleGroupInterval:       "1m",
+			RuleGroupLimit:          100,
+			Paused:                  true,
+			DeleteAlertsWhilePaused: true,
+		},
+		Status: v1alpha1.ServiceLevelObjectiveStatus{Type: "Ratio", ObservedGeneration: 2},
+	}
+
+	var hub v1alpha1.ServiceLevelObjective
+	require.NoError(t, objective.ConvertTo(&hub))
+	require.Equal(t, "http", hub.GetName())
+	require.Equal(t, ptr.To(true), hub.Spec.Alerting.Burnrates)
+	require.Equal(t, "HTTPErrorBudgetBurn", hub.Spec.Alerting.Name)
+	require.Equal(t, ptr.To(false), hub.Spec.Alerting.Absent)
+	require.Equal(t, "HTTPMetricAbsent", hub.Spec.Alerting.AbsentName)
+	require.Equal(t, "warning", hub.Spec.Alerting.AbsentSeverity)
+	require.Equal(t, "15m", hub.Spec.Alerting.AbsentOverTime)
+	require.Equal(t, objective.Spec.Alerting.BurnRate.Windows, hub.Spec.Alerting.Windows)
+	require.Equal(t, "10m", hub.Spec.Alerting.KeepFiringFor)
+	require.True(t, hub.Spec.Alerting.Inhibit)
+	require.Equal(t, "warn", hub.Spec.Alerting.PartialResponseStrategy)
+	require.Equal(t, map[string]string{"cluster": "eu1"}, hub.Spec.ExternalLabels)
+	require.Equal(t, "1m", hub.Spec.RuleGroupInterval)
+	require.Equal(t, 100, hub.Spec.RuleGroupLimit)
+	require.True(t, hub.Spec.Paused)
+	require.True(t, hub.Spec.DeleteAlertsWhilePaused)
+	require.Equal(t, objective.Status, hub.Status)
+
+	// The hub version is valid like objectives created as v1alpha1.
+	_, err := hub.ValidateCreate()
+	require.NoError(t, err)
+
+	// Converting back doesn't lose anything.
+	var converted v1beta1.ServiceLevelObjective
+	require.NoError(t, converted.ConvertFrom(&hub))
+	require.Equal(t, objective, &converted)
+
+	t.Run("disabled", func(t *testing.T) {
+		hub := hub.DeepCopy()
+		hub.Spec.Alerting.Disabled = ptr.To(true)
+		var converted v1beta1.ServiceLevelObjective
+		require.NoError(t, converted.ConvertFrom(hub))
+		require.Equal(t, ptr.To(false), converted.Spec.Alerting.BurnRate.Enabled)
+	})
+
+	t.Run("boolGauge", func(t *testing.T) {
+		var objective v1beta1.ServiceLevelObjective
+		require.NoError(t, yaml.Unmarshal([]byte(`
+spec:
+  target: "99"
+  window: 28d
+  indicator:
+    boolGauge:
+      metric: probe_success{job="blackbox"}
+`), &objective))
+
+		var hub v1alpha1.ServiceLevelObjective
+		require.NoError(t, objective.ConvertTo(&hub))
+		require.Equal(t, `probe_success{job="blackbox"}`, hub.Spec.ServiceLevelIndicator.BoolGauge.Metric)
+	})
+
+	t.Run("quantile", func(t *testing.T) {
+		var objective v1beta1.ServiceLevelObjective
+		require.NoError(t, yaml.Unmarshal([]byte(`
+spec:
+  target: "99"
+  window: 28d
+  indicator:
+    quantile:
+      total:
+        metric: http_request_duration_seconds_bucket{job="shop"}
+      quantile: "0.99"
+      latency: 500ms
+`), &objective))
+
+		var hub v1alpha1.ServiceLevelObjective
+		require.NoError(t, objective.ConvertTo(&hub))
+		require.Equal(t, "0.99", hub.Spec.ServiceLevelIndicator.Quantile.Quantile)
+
+		var converted v1beta1.ServiceLevelObjective
+		require.NoError(t, converted.ConvertFrom(&hub))
+		require.Equal(t, objective.Spec.ServiceLevelIndicator.Quantile, converted.Spec.ServiceLevelIndicator.Quantile)
+	})
+}
+
+func TestServiceLevelObjective_Convertible(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	require.NoError(t, v1beta1.AddToScheme(scheme))
+
+	// The manager serves the conversion webhook for the objectives registered in its scheme.
+	convertible, err := conversion.IsConvertible(scheme, &v1alpha1.ServiceLevelObjective{})
+	require.NoError(t, err)
+	require.True(t, convertible)
+}
@@ -0,0 +1,265 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func init() {
+	SchemeBuilder.Register(&ServiceLevelObjective{}, &ServiceLevelObjectiveList{})
+}
+
+// +kubebuilder:object:root=true
+
+// ServiceLevelObjectiveList contains a list of ServiceLevelObjective.
+type ServiceLevelObjectiveList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServiceLevelObjective `json:"items"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=slo
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Window",type=string,JSONPath=`.spec.window`
+// +kubebuilder:printcolumn:name="Target",type=string,JSONPath=`.spec.target`
+// +kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.status.type`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ServiceLevelObjective is the Schema for the ServiceLevelObjectives API.
+type ServiceLevelObjective struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ServiceLevelObjectiveSpec            `json:"spec,omitempty"`
+	Status v1alpha1.ServiceLevelObjectiveStatus `json:"status,omitempty"`
+}
+
+// ServiceLevelObjectiveSpec defines the desired state of ServiceLevelObjective.
+// It's the v1alpha1 spec with the settings of each alert grouped together.
+type ServiceLevelObjectiveSpec struct {
+	// +optional
+	// Description describes the ServiceLevelObjective in more detail and
+	// gives extra context for engineers that might not directly work on the service.
+	Description string `json:"description"`
+
+	// Target is a string that's casted to a float64 between 0 - 100.
+	// It represents the desired availability of the service in the given window.
+	Target string `json:"target"`
+
+	// Window within which the Target is supposed to be kept. Usually something like 1d, 7d or 28d.
+	Window string `json:"window"`
+
+	// ServiceLevelIndicator is the underlying data source that indicates how the service is doing.
+	ServiceLevelIndicator ServiceLevelIndicator `json:"indicator"`
+
+	// +optional
+	// Alerting customizes the alerting rules generated by Pyrra.
+	Alerting Alerting `json:"alerting"`
+
+	// +optional
+	// Policy is the error budget policy, taking actions as the remaining error budget falls below thresholds.
+	Policy *v1alpha1.ErrorBudgetPolicy `json:"policy,omitempty"`
+
+	// +optional
+	// Owner is who is responsible for the objective. It's added to all alerts of the objective to route them.
+	Owner *v1alpha1.Owner `json:"owner,omitempty"`
+
+	// +optional
+	// SLA is the external agreement promised for the service, usually looser than the Target.
+	SLA *v1alpha1.SLA `json:"sla,omitempty"`
+
+	// +optional
+	// +kubebuilder:validation:Enum=week;month;quarter
+	// Calendar additionally records the error budget since the start of the calendar week, month or quarter in UTC.
+	Calendar string `json:"calendar,omitempty"`
+
+	// +optional
+	// StableRuleNames names the increase recording rules without the window, like http_requests:increase instead of http_requests:increase4w.
+	StableRuleNames bool `json:"stableRuleNames,omitempty"`
+
+	// +optional
+	// SharedBurnrates records the burn rates once for all objectives in the namespace with the same indicator and sharedBurnrates.
+	SharedBurnrates bool `json:"sharedBurnrates,omitempty"`
+
+	// +optional
+	// Destination is the name of the ruler the objective's rules are written to.
+	Destination string `json:"destination,omitempty"`
+
+	// +optional
+	// ExternalLabels are added to all recording and alerting rules of the objective, like cluster or region.
+	ExternalLabels map[string]string `json:"externalLabels,omitempty"`
+
+	// +optional
+	// RuleGroupInterval is the evaluation interval of all the objective's rule groups, like 2m, instead of the generated ones.
+	RuleGroupInterval string `json:"ruleGroupInterval,omitempty"`
+
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// RuleGroupLimit limits the series each recording rule and the alerts each alerting rule of the objective may produce.
+	RuleGroupLimit int `json:"ruleGroupLimit,omitempty"`
+
+	// +optional
+	// Paused stops the operator from updating the objective's rules, they're kept as they were last written.
+	Paused bool `json:"paused,omitempty"`
+
+	// +optional
+	// DeleteAlertsWhilePaused deletes the alerting rules of the paused objective, keeping its recording rules.
+	DeleteAlertsWhilePaused bool `json:"deleteAlertsWhilePaused,omitempty"`
+}
+
+// ServiceLevelIndicator defines the underlying indicator of the objective.
+// It's the v1alpha1 indicator with boolGauge named like the other fields.
+type ServiceLevelIndicator struct {
+	// +optional
+	// Ratio is the indicator that measures against errors / total events.
+	Ratio *v1alpha1.RatioIndicator `json:"ratio,omitempty"`
+
+	// +optional
+	// Latency is the indicator that measures a certain percentage to be faster than the expected latency.
+	Latency *v1alpha1.LatencyIndicator `json:"latency,omitempty"`
+
+	// +optional
+	// LatencyNative is the indicator that measures a certain percentage to be faster than the expected latency,
+	// using native histograms.
+	LatencyNative *v1alpha1.NativeLatencyIndicator `json:"latencyNative,omitempty"`
+
+	// +optional
+	// BoolGauge is the indicator that measures whether a boolean gauge is successful.
+	BoolGauge *v1alpha1.BoolGaugeIndicator `json:"boolGauge,omitempty"`
+
+	// +optional
+	// Quantile is the indicator that measures how often a quantile of the latency is faster than the expected latency.
+	Quantile *v1alpha1.QuantileIndicator `json:"quantile,omitempty"`
+
+	// +optional
+	// Expression is the indicator that measures against the ratio of two PromQL expressions.
+	Expression *v1alpha1.ExpressionIndicator `json:"expression,omitempty"`
+
+	// +optional
+	// Logs is the indicator that measures against the ratio of the lines of two LogQL log queries.
+	Logs *v1alpha1.LogsIndicator `json:"logs,omitempty"`
+
+	// +optional
+	// Composite is the indicator that combines other objectives in the same namespace.
+	Composite *v1alpha1.CompositeIndicator `json:"composite,omitempty"`
+
+	// +optional
+	// Istio is a preset for services in an Istio service mesh.
+	Istio *v1alpha1.IstioIndicator `json:"istio,omitempty"`
+
+	// +optional
+	// Linkerd is a preset for workloads in a Linkerd service mesh.
+	Linkerd *v1alpha1.LinkerdIndicator `json:"linkerd,omitempty"`
+
+	// +optional
+	// GRPC is a preset for gRPC servers.
+	GRPC *v1alpha1.GRPCIndicator `json:"grpc,omitempty"`
+}
+
+// Alerting customizes the alerting rules generated by Pyrra.
+type Alerting struct {
+	// +optional
+	// BurnRate configures the multi-window multi-burn-rate alerts.
+	BurnRate BurnRateAlerting `json:"burnRate"`
+
+	// +optional
+	// Absent configures the alerts for metrics of the indicator that are absent.
+	Absent AbsentAlerting `json:"absent"`
+
+	// +optional
+	// PagerDuty routes the alerts to a PagerDuty service with an urgency per severity.
+	PagerDuty *v1alpha1.PagerDutyAlerting `json:"pagerduty,omitempty"`
+
+	// +optional
+	// Opsgenie sets the priority per severity and tags of the alerts for Opsgenie.
+	Opsgenie *v1alpha1.OpsgenieAlerting `json:"opsgenie,omitempty"`
+
+	// +optional
+	// MuteWindows are recurring windows during which the burn rate alerts don't fire.
+	MuteWindows []v1alpha1.MuteWindow `json:"muteWindows,omitempty"`
+
+	// +optional
+	// MaintenanceWindows are planned downtimes during which the burn rate alerts don't fire.
+	MaintenanceWindows []v1alpha1.MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+
+	// +optional
+	// Labels are added to all alerts of the objective, to route them in Alertmanager.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// +optional
+	// Annotations are added to all alerts of the objective, like a runbook_url.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// +optional
+	// +kubebuilder:validation:Enum=warn;abort
+	// PartialResponseStrategy is the partial_response_strategy of all the objective's rule groups.
+	// Only Thanos Ruler uses it, Prometheus ignores it.
+	PartialResponseStrategy string `json:"partialResponseStrategy,omitempty"`
+}
+
+// BurnRateAlerting configures the multi-window multi-burn-rate alerts.
+type BurnRateAlerting struct {
+	// +optional
+	// +kubebuilder:default:=true
+	// Enabled generates the alerts, the recording rules are generated either way.
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// +optional
+	// Name of the alerts. Defaults to "ErrorBudgetBurn".
+	Name string `json:"name,omitempty"`
+
+	// +optional
+	// Windows replace the alerts derived from the objective's window.
+	// Each window alerts if the error budget burns faster than its factor over both its short and long window.
+	Windows []v1alpha1.AlertingWindow `json:"windows,omitempty"`
+
+	// +optional
+	// KeepFiringFor keeps the alerts firing for the duration after their condition cleared, like 10m,
+	// so they don't flap while the burn rate is close to the threshold.
+	KeepFiringFor string `json:"keepFiringFor,omitempty"`
+
+	// +optional
+	// Inhibit adds a burn_window label to the alerts, fast for the critical and slow for the other ones,
+	// so the fast burning alert can inhibit the slow burning ones in Alertmanager.
+	Inhibit bool `json:"inhibit,omitempty"`
+}
+
+// AbsentAlerting configures the alerts for absent metrics.
+type AbsentAlerting struct {
+	// +optional
+	// +kubebuilder:default:=true
+	// Enabled generates the alerts.
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// +optional
+	// Name of the alerts. Defaults to "SLOMetricAbsent".
+	Name string `json:"name,omitempty"`
+
+	// +optional
+	// Severity label of the alerts. Defaults to critical.
+	Severity string `json:"severity,omitempty"`
+
+	// +optional
+	// OverTime makes the alerts fire once the metrics had no samples for the duration, like 15m,
+	// instead of as soon as they are absent or stale.
+	OverTime string `json:"overTime,omitempty"`
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// AlertAnnotations are templates of the annotations added to the alerts of all objectives, by their name,
+// like runbook_url with https://runbooks.example.com/{{.Namespace}}/{{.Name}}.
+// They're rendered with the AlertAnnotationData of each objective.
+type AlertAnnotations map[string]*template.Template
+
+// AlertAnnotationData is what the templates of AlertAnnotations are rendered with.
+type AlertAnnotationData struct {
+	// Namespace of the objective.
+	Namespace string
+	// Name of the objective.
+	Name string
+	// UID of the objective, like for links to its dashboard.
+	UID string
+	// Labels of the objective.
+	Labels map[string]string
+	// Target of the objective, like 99.5.
+	Target string
+	// Window of the objective, like 28d.
+	Window string
+}
+
+// withAlertAnnotations adds the reconciler's AlertAnnotations rendered for the objective to its alerting annotations.
+// The objective's own annotations take precedence, as does the runbook_url of its owner.
+// Only the objective in memory is changed, for its rules to be generated with them.
+func (r *ServiceLevelObjectiveReconciler) withAlertAnnotations(kubeObjective *pyrrav1alpha1.ServiceLevelObjective) error {
+	if len(r.AlertAnnotations) == 0 {
+		return nil
+	}
+	data := AlertAnnotationData{
+		Namespace: kubeObjective.GetNamespace(),
+		Name:      kubeObjective.GetName(),
+		UID:       string(kubeObjective.GetUID()),
+		Labels:    kubeObjective.GetLabels(),
+		Target:    kubeObjective.Spec.Target,
+		Window:    kubeObjective.Spec.Window,
+	}
+
+	annotations := make(map[string]string, len(r.AlertAnnotations)+len(kubeObjective.Spec.Alerting.Annotations))
+	for name, tmpl := range r.AlertAnnotations {
+		if _, ok := kubeObjective.Spec.Alerting.Annotations[name]; ok {
+			continue
+		}
+		if name == "runbook_url" && kubeObjective.Spec.Owner != nil && kubeObjective.Spec.Owner.RunbookURL != "" {
+			continue
+		}
+		var sb strings.Builder
+		if err := tmpl.Execute(&sb, data); err != nil {
+			return invalidObjectiveError{err: fmt.Errorf("failed to render alert annotation %s: %w", name, err)}
+		}
+		annotations[name] = sb.String()
+	}
+	for name, value := range kubeObjective.Spec.Alerting.Annotations {
+		annotations[name] = value
+	}
+	kubeObjective.Spec.Alerting.Annotations = annotations
+	return nil
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"text/template"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func TestServiceLevelObjectiveReconciler_AlertAnnotations(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+	objective.Spec.Alerting.Annotations = map[string]string{"summary": "The http objective is burning"}
+	owned := objective.DeepCopy()
+	owned.Name = "owned"
+	owned.UID = "456"
+	owned.Spec.Owner = &pyrrav1alpha1.Owner{Team: "checkout", RunbookURL: "https://runbooks.example.com/checkout"}
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective, owned).
+		WithStatusSubresource(objective, owned).
+		Build()
+
+	r := &ServiceLevelObjectiveReconciler{
+		Client: c,
+		Logger: kitlog.NewNopLogger(),
+		AlertAnnotations: AlertAnnotations{
+			"runbook_url": template.Must(template.New("runbook_url").Parse("https://runbooks.example.com/{{.Namespace}}/{{.Name}}")),
+			"dashboard":   template.Must(template.New("dashboard").Parse("https://grafana.example.com/d/{{.UID}}?target={{.Target}}&window={{.Window}}")),
+			"summary":     template.Must(template.New("summary").Parse("{{.Name}} is burning its error budget")),
+		},
+	}
+	alertAnnotations := func(o *pyrrav1alpha1.ServiceLevelObjective) map[string]string {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(o)})
+		require.NoError(t, err)
+
+		var rule monitoringv1.PrometheusRule
+		require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(o), &rule))
+		for _, g := range rule.Spec.Groups {
+			for _, r := range g.Rules {
+				if r.Alert == "ErrorBudgetBurn" {
+					return r.Annotations
+				}
+			}
+		}
+		t.Fatal("no burn rate alert")
+		return nil
+	}
+
+	annotations := alertAnnotations(objective)
+	require.Equal(t, "https://runbooks.example.com/monitoring/http", annotations["runbook_url"])
+	require.Equal(t, "https://grafana.example.com/d/123?target=99.5&window=28d", annotations["dashboard"])
+	// The objective's own annotations take precedence.
+	require.Equal(t, "The http objective is burning", annotations["summary"])
+
+	// So does the runbook of the objective's owner.
+	annotations = alertAnnotations(owned)
+	require.Equal(t, "https://runbooks.example.com/checkout", annotations["runbook_url"])
+	require.Equal(t, "https://grafana.example.com/d/456?target=99.5&window=28d", annotations["dashboard"])
+}
+
+func TestServiceLevelObjectiveReconciler_withAlertAnnotations(t *testing.T) {
+	r := &ServiceLevelObjectiveReconciler{AlertAnnotations: AlertAnnotations{
+		"team": template.Must(template.New("team").Option("missingkey=error").Parse("{{.Labels.team}}")),
+	}}
+
+	objective := httpSLO.DeepCopy()
+	objective.Labels = map[string]string{"team": "checkout"}
+	require.NoError(t, r.withAlertAnnotations(objective))
+	require.Equal(t, map[string]string{"team": "checkout"}, objective.Spec.Alerting.Annotations)
+
+	objective = httpSLO.DeepCopy()
+	objective.Labels = nil
+	err := r.withAlertAnnotations(objective)
+	require.True(t, isInvalidObjective(err))
+	require.ErrorContains(t, err, "failed to render alert annotation team")
+}
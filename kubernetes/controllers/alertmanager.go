@@ -0,0 +1,151 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	monitoringv1alpha1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=alertmanagerconfigs,verbs=get;list;watch;create;update;patch;delete
+
+// AlertmanagerConfigs configures the AlertmanagerConfigs with the inhibition rules of objectives with alerting.inhibit,
+// which mute their slow burning alerts while the fast burning one fires.
+type AlertmanagerConfigs struct {
+	// Labels are added to the AlertmanagerConfigs, for the alertmanagerConfigSelector of the Alertmanager to select them.
+	Labels map[string]string
+}
+
+// AlertmanagerConfigWriter writes the inhibition rules of objectives to AlertmanagerConfigs for the reconciler's AlertmanagerConfigs.
+type AlertmanagerConfigWriter struct {
+	Reconciler *ServiceLevelObjectiveReconciler
+}
+
+func (w AlertmanagerConfigWriter) Apply(ctx context.Context, o *WriterObjective) (ctrl.Result, error) {
+	return ctrl.Result{}, w.Reconciler.reconcileAlertmanagerConfig(ctx, o.Logger, o.Objective)
+}
+
+// Delete does nothing, AlertmanagerConfigs are garbage collected with their objective through their owner reference.
+func (w AlertmanagerConfigWriter) Delete(context.Context, *WriterObjective) error { return nil }
+
+func (r *ServiceLevelObjectiveReconciler) reconcileAlertmanagerConfig(
+	ctx context.Context,
+	logger kitlog.Logger,
+	kubeObjective pyrrav1alpha1.ServiceLevelObjective,
+) (err error) {
+	ctx, end := startSpan(ctx, "write AlertmanagerConfig", &err)
+	defer end()
+
+	objective, err := kubeObjective.Internal()
+	if err != nil {
+		return invalidObjectiveError{err: fmt.Errorf("failed to get objective: %w", err)}
+	}
+
+	var existing monitoringv1alpha1.AlertmanagerConfig
+	exists := true
+	if err := r.Get(ctx, client.ObjectKeyFromObject(&kubeObjective), &existing); err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get alertmanager config: %w", err)
+		}
+		exists = false
+	}
+
+	inhibitRules := objective.InhibitRules()
+	if len(inhibitRules) == 0 {
+		// The objective no longer inhibits its alerts, its AlertmanagerConfig is deleted.
+		if !exists || !metav1.IsControlledBy(&existing, &kubeObjective) {
+			return nil
+		}
+		level.Info(logger).Log("msg", "deleting alertmanager config", "namespace", existing.GetNamespace(), "name", existing.GetName())
+		if err := r.Delete(ctx, &existing); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete alertmanager config: %w", err)
+		}
+		return nil
+	}
+
+	config := newAlertmanagerConfig(kubeObjective, *r.AlertmanagerConfigs, inhibitRules)
+	hash, err := setAppliedHash(config, config.Spec)
+	if err != nil {
+		return err
+	}
+	if exists && existing.GetAnnotations()[AppliedHashAnnotation] == hash && equality.Semantic.DeepEqual(existing.Spec, config.Spec) {
+		level.Debug(logger).Log("msg", "alertmanager config is up to date", "namespace", config.GetNamespace(), "name", config.GetName())
+		return nil
+	}
+
+	level.Info(logger).Log("msg", "applying alertmanager config", "namespace", config.GetNamespace(), "name", config.GetName())
+	if err := r.applyObject(ctx, config); err != nil {
+		return fmt.Errorf("failed to apply alertmanager config: %w", err)
+	}
+	if exists {
+		r.event(&kubeObjective, corev1.EventTypeNormal, reasonRulesUpdated, "Updated AlertmanagerConfig %s", config.GetName())
+	} else {
+		r.event(&kubeObjective, corev1.EventTypeNormal, reasonRulesCreated, "Created AlertmanagerConfig %s", config.GetName())
+	}
+	return nil
+}
+
+// newAlertmanagerConfig returns the AlertmanagerConfig of the objective with its inhibition rules.
+// The prometheus-operator restricts the rules to the alerts of the objective's namespace.
+func newAlertmanagerConfig(
+	kubeObjective pyrrav1alpha1.ServiceLevelObjective,
+	configs AlertmanagerConfigs,
+	inhibitRules []monitoringv1alpha1.InhibitRule,
+) *monitoringv1alpha1.AlertmanagerConfig {
+	var labels map[string]string
+	if len(configs.Labels) > 0 {
+		labels = make(map[string]string, len(configs.Labels))
+		for k, v := range configs.Labels {
+			labels[k] = v
+		}
+	}
+
+	isController := true
+	return &monitoringv1alpha1.AlertmanagerConfig{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       monitoringv1alpha1.AlertmanagerConfigKind,
+			APIVersion: monitoringv1alpha1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      kubeObjective.GetName(),
+			Namespace: kubeObjective.GetNamespace(),
+			Labels:    labels,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: kubeObjective.APIVersion,
+					Kind:       kubeObjective.Kind,
+					Name:       kubeObjective.Name,
+					UID:        kubeObjective.UID,
+					Controller: &isController,
+				},
+			},
+		},
+		Spec: monitoringv1alpha1.AlertmanagerConfigSpec{InhibitRules: inhibitRules},
+	}
+}
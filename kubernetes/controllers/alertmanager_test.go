@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	monitoringv1alpha1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1alpha1"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func TestServiceLevelObjectiveReconciler_AlertmanagerConfigs(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1alpha1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+	objective.Spec.Alerting.Inhibit = true
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective).
+		WithStatusSubresource(objective).
+		Build()
+
+	r := &ServiceLevelObjectiveReconciler{
+		Client:              c,
+		Logger:              kitlog.NewNopLogger(),
+		AlertmanagerConfigs: &AlertmanagerConfigs{Labels: map[string]string{"alertmanager": "main"}},
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+
+	_, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+
+	var config monitoringv1alpha1.AlertmanagerConfig
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, &config))
+	require.Equal(t, "main", config.GetLabels()["alertmanager"])
+	require.Len(t, config.GetOwnerReferences(), 1)
+	require.Equal(t, "http", config.GetOwnerReferences()[0].Name)
+	require.Len(t, config.Spec.InhibitRules, 1)
+	rule := config.Spec.InhibitRules[0]
+	require.Contains(t, rule.SourceMatch, monitoringv1alpha1.Matcher{Name: "burn_window", Value: "fast", MatchType: monitoringv1alpha1.MatchEqual})
+	require.Contains(t, rule.TargetMatch, monitoringv1alpha1.Matcher{Name: "burn_window", Value: "slow", MatchType: monitoringv1alpha1.MatchEqual})
+	require.Equal(t, []string{"slo"}, rule.Equal)
+
+	// The alerts have the labels the inhibition rule matches.
+	var prometheusRule monitoringv1.PrometheusRule
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, &prometheusRule))
+	var burnWindows []string
+	for _, group := range prometheusRule.Spec.Groups {
+		for _, r := range group.Rules {
+			if r.Alert == "ErrorBudgetBurn" {
+				burnWindows = append(burnWindows, r.Labels["burn_window"])
+			}
+		}
+	}
+	require.Equal(t, []string{"fast", "fast", "slow", "slow"}, burnWindows)
+
+	// Unchanged objectives don't update the AlertmanagerConfig.
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	var unchanged monitoringv1alpha1.AlertmanagerConfig
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, &unchanged))
+	require.Equal(t, config.GetResourceVersion(), unchanged.GetResourceVersion())
+
+	// The AlertmanagerConfig is deleted once the objective no longer inhibits its alerts.
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+	objective.Spec.Alerting.Inhibit = false
+	require.NoError(t, c.Update(context.Background(), objective))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	err = c.Get(context.Background(), req.NamespacedName, &config)
+	require.True(t, errors.IsNotFound(err), err)
+}
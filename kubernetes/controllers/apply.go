@@ -0,0 +1,148 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"go.opentelemetry.io/otel/attribute"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+const (
+	// FieldManager owns the fields of the PrometheusRules and ConfigMaps the reconciler applies.
+	// Labels and annotations other tools add to them are kept.
+	FieldManager = "pyrra"
+	// AppliedHashAnnotation is set on the applied PrometheusRules and ConfigMaps to the hash of what was applied,
+	// so they aren't applied again as long as the generated object doesn't change.
+	AppliedHashAnnotation = "pyrra.dev/applied-hash"
+	// VersionAnnotation is set on the applied PrometheusRules and ConfigMaps to the version of Pyrra that generated them.
+	VersionAnnotation = "pyrra.dev/version"
+	// SpecHashAnnotation is set on the applied PrometheusRules and ConfigMaps to the hash of the spec of the objective they were generated from.
+	SpecHashAnnotation = "pyrra.dev/spec-hash"
+	// GeneratedAtAnnotation is set on the applied PrometheusRules and ConfigMaps to when they were applied last, like 2024-06-01T12:00:00Z.
+	// It isn't part of the applied hash, so it only changes as the objects are applied again.
+	GeneratedAtAnnotation = "pyrra.dev/generated-at"
+)
+
+// setAppliedHash sets the hash of the object's labels, annotations, owner references and content, like its spec,
+// as the object's AppliedHashAnnotation and returns it. The GeneratedAtAnnotation isn't hashed.
+func setAppliedHash(obj client.Object, content any) (string, error) {
+	annotations := make(map[string]string, len(obj.GetAnnotations())+1)
+	for k, v := range obj.GetAnnotations() {
+		if k != AppliedHashAnnotation && k != GeneratedAtAnnotation {
+			annotations[k] = v
+		}
+	}
+
+	b, err := json.Marshal(struct {
+		Labels          map[string]string
+		Annotations     map[string]string
+		OwnerReferences []metav1.OwnerReference
+		Content         any
+	}{
+		Labels:          obj.GetLabels(),
+		Annotations:     annotations,
+		OwnerReferences: obj.GetOwnerReferences(),
+		Content:         content,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", obj.GetName(), err)
+	}
+	sum := sha256.Sum256(b)
+	hash := hex.EncodeToString(sum[:])
+
+	annotations[AppliedHashAnnotation] = hash
+	obj.SetAnnotations(annotations)
+	return hash, nil
+}
+
+// setProvenance sets the annotations of the object that tell which version of Pyrra generated it from which spec of the objective.
+// The spec is hashed as the rules are generated from it, so specs written differently but the same, like windows of 2w and 14d, have the same hash.
+func (r *ServiceLevelObjectiveReconciler) setProvenance(obj client.Object, kubeObjective *pyrrav1alpha1.ServiceLevelObjective) error {
+	objective, err := kubeObjective.Internal()
+	if err != nil {
+		return invalidObjectiveError{err: fmt.Errorf("failed to get objective: %w", err)}
+	}
+	// The config is the whole object as YAML, with its status and resource version.
+	objective.Config = ""
+	b, err := json.Marshal(objective)
+	if err != nil {
+		return fmt.Errorf("failed to hash the spec of %s: %w", kubeObjective.GetName(), err)
+	}
+	sum := sha256.Sum256(b)
+
+	annotations := make(map[string]string, len(obj.GetAnnotations())+2)
+	for k, v := range obj.GetAnnotations() {
+		annotations[k] = v
+	}
+	if r.Version != "" {
+		annotations[VersionAnnotation] = r.Version
+	}
+	annotations[SpecHashAnnotation] = hex.EncodeToString(sum[:])
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// setGeneratedAt sets the GeneratedAtAnnotation of the object about to be applied to now.
+func setGeneratedAt(obj client.Object) {
+	annotations := make(map[string]string, len(obj.GetAnnotations())+1)
+	for k, v := range obj.GetAnnotations() {
+		annotations[k] = v
+	}
+	annotations[GeneratedAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	obj.SetAnnotations(annotations)
+}
+
+// drifted reports the generated object if its content was changed by others since it was applied,
+// as it still has the applied hash of what's generated now but not the generated content.
+// Nothing is reported unless the reconciler detects drift.
+func (r *ServiceLevelObjectiveReconciler) drifted(logger kitlog.Logger, kubeObjective *pyrrav1alpha1.ServiceLevelObjective, kind string, obj client.Object) {
+	if !r.DetectDrift {
+		return
+	}
+	level.Warn(logger).Log("msg", "generated object was changed by others", "kind", kind, "namespace", obj.GetNamespace(), "name", obj.GetName())
+	driftedObjects.WithLabelValues(kubeObjective.GetNamespace(), kubeObjective.GetName(), kind).Inc()
+	r.event(kubeObjective, corev1.EventTypeWarning, reasonRulesDrifted, "%s %s was changed outside of Pyrra, reverting it", kind, obj.GetName())
+}
+
+// applyObject writes the object with server-side apply as the FieldManager within a span of its own,
+// taking over the fields other managers changed.
+// The fields the manager applied before and the object no longer has are removed.
+func (r *ServiceLevelObjectiveReconciler) applyObject(ctx context.Context, obj client.Object) (err error) {
+	ctx, end := startSpan(ctx, "apply", &err,
+		attribute.String("kind", obj.GetObjectKind().GroupVersionKind().Kind),
+		attribute.String("namespace", obj.GetNamespace()),
+		attribute.String("name", obj.GetName()),
+	)
+	defer end()
+
+	obj.SetResourceVersion("")
+	obj.SetManagedFields(nil)
+	return r.Patch(ctx, obj, client.Apply, client.FieldOwner(FieldManager), client.ForceOwnership)
+}
@@ -0,0 +1,240 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// newFakeClientBuilder returns a builder of fake clients that support server-side apply, see fakeApply.
+func newFakeClientBuilder() *fake.ClientBuilder {
+	return fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{Patch: fakeApply})
+}
+
+// appliedKeys are the labels and annotations last applied to the objects of fake clients,
+// to remove the ones that aren't applied anymore like server-side apply does.
+var appliedKeys sync.Map
+
+// fakeApply emulates server-side apply for the fake client, which doesn't support it.
+// The applied object replaces the existing one, except for the labels and annotations others added.
+// Other patches are passed on.
+func fakeApply(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Patch(ctx, obj, patch, opts...)
+	}
+
+	id := fmt.Sprintf("%p/%T/%s", c, obj, client.ObjectKeyFromObject(obj))
+	applied := map[string]bool{}
+	for k := range obj.GetLabels() {
+		applied["label/"+k] = true
+	}
+	for k := range obj.GetAnnotations() {
+		applied["annotation/"+k] = true
+	}
+	previous, _ := appliedKeys.Swap(id, applied)
+
+	existing := obj.DeepCopyObject().(client.Object)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		return c.Create(ctx, obj)
+	}
+
+	keep := func(kind string, existing, applied map[string]string) map[string]string {
+		merged := map[string]string{}
+		for k, v := range existing {
+			if p, ok := previous.(map[string]bool); !ok || !p[kind+"/"+k] {
+				merged[k] = v
+			}
+		}
+		for k, v := range applied {
+			merged[k] = v
+		}
+		return merged
+	}
+	obj.SetLabels(keep("label", existing.GetLabels(), obj.GetLabels()))
+	obj.SetAnnotations(keep("annotation", existing.GetAnnotations(), obj.GetAnnotations()))
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	return c.Update(ctx, obj)
+}
+
+func TestServiceLevelObjectiveReconciler_Apply(t *testing.T) {
+	for _, configMapMode := range []bool{false, true} {
+		t.Run(fmt.Sprintf("configMapMode=%t", configMapMode), func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			require.NoError(t, clientgoscheme.AddToScheme(scheme))
+			require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+			require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+			objective := httpSLO.DeepCopy()
+			objective.TypeMeta = metav1.TypeMeta{}
+			objective.Namespace = "monitoring"
+
+			var applies []client.PatchOptions
+			c := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(objective).
+				WithStatusSubresource(objective).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+						if patch.Type() == types.ApplyPatchType {
+							applies = append(applies, *(&client.PatchOptions{}).ApplyOptions(opts))
+						}
+						return fakeApply(ctx, c, obj, patch, opts...)
+					},
+				}).
+				Build()
+
+			r := &ServiceLevelObjectiveReconciler{Client: c, Logger: kitlog.NewNopLogger(), ConfigMapMode: configMapMode}
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+			reconcileObjective := func() {
+				_, err := r.Reconcile(context.Background(), req)
+				require.NoError(t, err)
+			}
+
+			var obj client.Object = &monitoringv1.PrometheusRule{}
+			key := req.NamespacedName
+			if configMapMode {
+				obj = &corev1.ConfigMap{}
+				key.Name = "pyrra-recording-rule-http"
+			}
+
+			reconcileObjective()
+			require.Len(t, applies, 1)
+			require.Equal(t, FieldManager, applies[0].FieldManager)
+			require.True(t, *applies[0].Force)
+
+			require.NoError(t, c.Get(context.Background(), key, obj))
+			require.NotEmpty(t, obj.GetAnnotations()[AppliedHashAnnotation])
+			require.Equal(t, "bar", obj.GetLabels()["team"])
+
+			// Labels and annotations of other tools are kept, and unchanged objects aren't applied again.
+			obj.GetLabels()["backup"] = "true"
+			obj.GetAnnotations()["argocd.argoproj.io/tracking-id"] = "pyrra"
+			require.NoError(t, c.Update(context.Background(), obj))
+			reconcileObjective()
+			require.Len(t, applies, 1)
+
+			require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+			objective.Spec.Target = "99.9"
+			require.NoError(t, c.Update(context.Background(), objective))
+			reconcileObjective()
+			require.Len(t, applies, 2)
+
+			require.NoError(t, c.Get(context.Background(), key, obj))
+			require.Equal(t, "true", obj.GetLabels()["backup"])
+			require.Equal(t, "pyrra", obj.GetAnnotations()["argocd.argoproj.io/tracking-id"])
+		})
+	}
+}
+
+func TestServiceLevelObjectiveReconciler_Provenance(t *testing.T) {
+	for _, configMapMode := range []bool{false, true} {
+		t.Run(fmt.Sprintf("configMapMode=%t", configMapMode), func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			require.NoError(t, clientgoscheme.AddToScheme(scheme))
+			require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+			require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+			objective := httpSLO.DeepCopy()
+			objective.TypeMeta = metav1.TypeMeta{}
+			objective.Namespace = "provenance"
+
+			c := newFakeClientBuilder().
+				WithScheme(scheme).
+				WithObjects(objective).
+				WithStatusSubresource(objective).
+				Build()
+
+			recorder := record.NewFakeRecorder(10)
+			r := &ServiceLevelObjectiveReconciler{
+				Client:        c,
+				Logger:        kitlog.NewNopLogger(),
+				ConfigMapMode: configMapMode,
+				Recorder:      recorder,
+				Version:       "v0.8.0",
+				DetectDrift:   true,
+			}
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+			reconcileObjective := func() client.Object {
+				_, err := r.Reconcile(context.Background(), req)
+				require.NoError(t, err)
+
+				if configMapMode {
+					var cm corev1.ConfigMap
+					require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "provenance", Name: "pyrra-recording-rule-http"}, &cm))
+					return &cm
+				}
+				var rule monitoringv1.PrometheusRule
+				require.NoError(t, c.Get(context.Background(), req.NamespacedName, &rule))
+				return &rule
+			}
+			kind := "PrometheusRule"
+			if configMapMode {
+				kind = "ConfigMap"
+			}
+
+			obj := reconcileObjective()
+			<-recorder.Events
+			annotations := obj.GetAnnotations()
+			require.Equal(t, "v0.8.0", annotations[VersionAnnotation])
+			require.Len(t, annotations[SpecHashAnnotation], 64)
+			generatedAt, err := time.Parse(time.RFC3339, annotations[GeneratedAtAnnotation])
+			require.NoError(t, err)
+			require.WithinDuration(t, time.Now(), generatedAt, time.Minute)
+
+			// Specs written differently but generating the same rules have the same hash.
+			require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+			objective.Spec.Window = "4w"
+			require.NoError(t, c.Update(context.Background(), objective))
+			obj = reconcileObjective()
+			require.Equal(t, annotations, obj.GetAnnotations())
+			require.Empty(t, recorder.Events)
+
+			// Content changed by others is reported and reverted.
+			if configMapMode {
+				cm := obj.(*corev1.ConfigMap)
+				cm.Data = map[string]string{"http.yaml": "groups: []"}
+			} else {
+				rule := obj.(*monitoringv1.PrometheusRule)
+				rule.Spec.Groups = rule.Spec.Groups[:1]
+			}
+			require.NoError(t, c.Update(context.Background(), obj))
+			obj = reconcileObjective()
+			require.Equal(t, fmt.Sprintf("Warning RulesDrifted %s %s was changed outside of Pyrra, reverting it", kind, obj.GetName()), <-recorder.Events)
+			require.Equal(t, fmt.Sprintf("Normal RulesUpdated Updated %s %s", kind, obj.GetName()), <-recorder.Events)
+			require.Equal(t, 1.0, testutil.ToFloat64(driftedObjects.WithLabelValues("provenance", "http", kind)))
+			require.Equal(t, annotations[SpecHashAnnotation], obj.GetAnnotations()[SpecHashAnnotation])
+
+			// Changes of the spec aren't drift.
+			require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+			objective.Spec.Target = "99.9"
+			require.NoError(t, c.Update(context.Background(), objective))
+			obj = reconcileObjective()
+			require.Equal(t, fmt.Sprintf("Normal RulesUpdated Updated %s %s", kind, obj.GetName()), <-recorder.Events)
+			require.Equal(t, 1.0, testutil.ToFloat64(driftedObjects.WithLabelValues("provenance", "http", kind)))
+			require.NotEqual(t, annotations[SpecHashAnnotation], obj.GetAnnotations()[SpecHashAnnotation])
+		})
+	}
+}
@@ -0,0 +1,307 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/prometheus/promql/parser"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create
+
+const (
+	reasonBackfillCreated = "BackfillCreated"
+
+	// backfillRulesFile is the key of the rules in the backfill's ConfigMap.
+	backfillRulesFile = "rules.yaml"
+	// backfillBlocksDir is where the backfill's blocks are written to in its Job.
+	backfillBlocksDir = "/blocks"
+)
+
+// Backfill configures the Jobs backfilling the recording rules of new objectives over their window with promtool,
+// so their error budget and burn rates have history right away instead of only after a window.
+// Only the recording rules of the raw metrics are backfilled, as promtool can't evaluate rules of the series it backfills.
+type Backfill struct {
+	// PrometheusURL is the Prometheus with the raw metrics of the objectives the rules are evaluated against.
+	PrometheusURL string
+	// Image is the image with promtool, like quay.io/prometheus/prometheus.
+	Image string
+	// Volume is where the blocks are written to without MimirURL, like the storage of the Prometheus evaluating the rules,
+	// which loads the blocks from its data directory.
+	Volume corev1.VolumeSource
+	// VolumeSubPath is the directory in the Volume the blocks are written to, like prometheus-db for the prometheus-operator.
+	VolumeSubPath string
+	// MimirURL uploads the blocks to Mimir's backfill API with mimirtool instead, for the MimirTenant.
+	MimirURL    string
+	MimirTenant string
+	// MimirImage is the image with mimirtool, like grafana/mimirtool.
+	MimirImage string
+	// MaxAge is how long after their creation objectives are backfilled,
+	// so the existing objectives aren't backfilled as the backfill is enabled. Objectives of any age are backfilled if 0.
+	MaxAge time.Duration
+}
+
+// BackfillWriter creates the Jobs backfilling the recording rules of new objectives evaluated by Prometheus
+// for the reconciler's Backfill.
+type BackfillWriter struct {
+	Reconciler *ServiceLevelObjectiveReconciler
+}
+
+func (w BackfillWriter) Apply(ctx context.Context, o *WriterObjective) (ctrl.Result, error) {
+	if IsLokiObjective(o.Objective.GetAnnotations()) || o.Objective.Spec.ServiceLevelIndicator.Composite != nil {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{}, w.Reconciler.reconcileBackfill(ctx, o.Logger, o.Objective)
+}
+
+// Delete does nothing, the backfill's Job and ConfigMap are garbage collected with their objective through their owner reference.
+func (w BackfillWriter) Delete(context.Context, *WriterObjective) error { return nil }
+
+// reconcileBackfill creates the Job backfilling the objective's recording rules once.
+// The Job is never updated, the rules of later changes of the objective are only recorded from then on.
+func (r *ServiceLevelObjectiveReconciler) reconcileBackfill(
+	ctx context.Context,
+	logger kitlog.Logger,
+	kubeObjective pyrrav1alpha1.ServiceLevelObjective,
+) (err error) {
+	ctx, end := startSpan(ctx, "create backfill Job", &err)
+	defer end()
+
+	created := kubeObjective.GetCreationTimestamp().Time
+	if r.Backfill.MaxAge > 0 && time.Since(created) > r.Backfill.MaxAge {
+		return nil
+	}
+
+	name := backfillName(kubeObjective.GetName())
+	var existing batchv1.Job
+	if err := r.Get(ctx, client.ObjectKey{Namespace: kubeObjective.GetNamespace(), Name: name}, &existing); err == nil {
+		return nil
+	} else if !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to get backfill job: %w", err)
+	}
+
+	objective, err := kubeObjective.Internal()
+	if err != nil {
+		return invalidObjectiveError{err: fmt.Errorf("failed to get objective: %w", err)}
+	}
+	groups, err := r.ruleGroups(ctx, kubeObjective)
+	if err != nil {
+		return err
+	}
+	groups, err = backfillRuleGroups(groups)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+
+	configMap, err := newBackfillConfigMap(name, kubeObjective, groups)
+	if err != nil {
+		return err
+	}
+	if err := r.applyObject(ctx, configMap); err != nil {
+		return fmt.Errorf("failed to apply backfill config map: %w", err)
+	}
+
+	start := created.Add(-time.Duration(objective.Window))
+	job := newBackfillJob(name, kubeObjective, *r.Backfill, start, created)
+	level.Info(logger).Log("msg", "creating backfill job", "namespace", job.GetNamespace(), "name", job.GetName())
+	if err := r.Create(ctx, job); err != nil {
+		if errors.IsAlreadyExists(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to create backfill job: %w", err)
+	}
+	r.event(&kubeObjective, corev1.EventTypeNormal, reasonBackfillCreated,
+		"Created Job %s to backfill the recording rules from %s to %s", job.GetName(), start.UTC().Format(time.RFC3339), created.UTC().Format(time.RFC3339))
+	return nil
+}
+
+// backfillName returns the name of the objective's backfill Job and ConfigMap.
+// Names of Jobs are limited to 63 characters, as they're the value of their Pods' job-name label,
+// longer ones are shortened with a hash of the objective's name to keep them unique.
+func backfillName(objective string) string {
+	name := objective + "-backfill"
+	if len(name) <= 63 {
+		return name
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(objective))
+	suffix := fmt.Sprintf("-%08x-backfill", h.Sum32())
+	return strings.TrimRight(objective[:63-len(suffix)], "-.") + suffix
+}
+
+// backfillRuleGroups returns the recording rules of the groups that only select raw metrics,
+// as promtool evaluates all rules against the Prometheus, which doesn't have the backfilled series of the other rules.
+func backfillRuleGroups(groups []monitoringv1.RuleGroup) ([]monitoringv1.RuleGroup, error) {
+	records := map[string]bool{}
+	for _, g := range groups {
+		for _, rule := range g.Rules {
+			if rule.Record != "" {
+				records[rule.Record] = true
+			}
+		}
+	}
+
+	var backfill []monitoringv1.RuleGroup
+	for _, g := range groups {
+		var rules []monitoringv1.Rule
+		for _, rule := range g.Rules {
+			if rule.Record == "" {
+				continue
+			}
+			expr, err := parser.ParseExpr(rule.Expr.String())
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse expression of %s: %w", rule.Record, err)
+			}
+			recorded := false
+			parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+				if vs, ok := node.(*parser.VectorSelector); ok && records[vs.Name] {
+					recorded = true
+				}
+				return nil
+			})
+			if !recorded {
+				rules = append(rules, rule)
+			}
+		}
+		if len(rules) > 0 {
+			backfill = append(backfill, monitoringv1.RuleGroup{Name: g.Name, Interval: g.Interval, Rules: rules})
+		}
+	}
+	return backfill, nil
+}
+
+// newBackfillConfigMap returns the ConfigMap with the rules the objective's backfill Job evaluates.
+func newBackfillConfigMap(name string, kubeObjective pyrrav1alpha1.ServiceLevelObjective, groups []monitoringv1.RuleGroup) (*corev1.ConfigMap, error) {
+	var sb strings.Builder
+	if _, err := WriteRuleSpec(&sb, monitoringv1.PrometheusRuleSpec{Groups: groups}, 0); err != nil {
+		return nil, fmt.Errorf("failed to marshal backfill rules: %w", err)
+	}
+
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       kubeObjective.GetNamespace(),
+			Labels:          map[string]string{ObjectiveLabel: kubeObjective.GetName()},
+			OwnerReferences: []metav1.OwnerReference{backfillOwner(kubeObjective)},
+		},
+		Data: map[string]string{backfillRulesFile: sb.String()},
+	}, nil
+}
+
+// newBackfillJob returns the Job backfilling the rules of its ConfigMap from start to end.
+// promtool writes the blocks to the backfill's Volume, or to an emptyDir the blocks are uploaded to Mimir from with mimirtool.
+func newBackfillJob(name string, kubeObjective pyrrav1alpha1.ServiceLevelObjective, backfill Backfill, start, end time.Time) *batchv1.Job {
+	promtool := corev1.Container{
+		Name:    "promtool",
+		Image:   backfill.Image,
+		Command: []string{"promtool"},
+		Args: []string{
+			"tsdb", "create-blocks-from", "rules",
+			"--start=" + strconv.FormatInt(start.Unix(), 10),
+			"--end=" + strconv.FormatInt(end.Unix(), 10),
+			"--url=" + backfill.PrometheusURL,
+			"--output-dir=" + backfillBlocksDir,
+			"/etc/pyrra/" + backfillRulesFile,
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "rules", MountPath: "/etc/pyrra", ReadOnly: true},
+			{Name: "blocks", MountPath: backfillBlocksDir, SubPath: backfill.VolumeSubPath},
+		},
+	}
+
+	blocks := backfill.Volume
+	pod := corev1.PodSpec{
+		RestartPolicy: corev1.RestartPolicyOnFailure,
+		Containers:    []corev1.Container{promtool},
+	}
+	if backfill.MimirURL != "" {
+		blocks = corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
+		// The blocks are uploaded once promtool wrote all of them.
+		pod.InitContainers = []corev1.Container{promtool}
+		pod.Containers = []corev1.Container{{
+			Name:    "mimirtool",
+			Image:   backfill.MimirImage,
+			Command: []string{"sh", "-c", `exec mimirtool backfill --address="$MIMIR_ADDRESS" --id="$MIMIR_TENANT" ` + backfillBlocksDir + "/*/"},
+			Env: []corev1.EnvVar{
+				{Name: "MIMIR_ADDRESS", Value: backfill.MimirURL},
+				{Name: "MIMIR_TENANT", Value: backfill.MimirTenant},
+			},
+			VolumeMounts: []corev1.VolumeMount{{Name: "blocks", MountPath: backfillBlocksDir, ReadOnly: true}},
+		}}
+	}
+	pod.Volumes = []corev1.Volume{
+		{Name: "rules", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: name},
+		}}},
+		{Name: "blocks", VolumeSource: blocks},
+	}
+
+	labels := map[string]string{ObjectiveLabel: kubeObjective.GetName()}
+	return &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Job",
+			APIVersion: batchv1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       kubeObjective.GetNamespace(),
+			Labels:          labels,
+			OwnerReferences: []metav1.OwnerReference{backfillOwner(kubeObjective)},
+		},
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       pod,
+			},
+		},
+	}
+}
+
+// backfillOwner returns the owner reference of the backfill's objects to their objective.
+// They aren't controlled by the objective, its other ConfigMaps are.
+func backfillOwner(kubeObjective pyrrav1alpha1.ServiceLevelObjective) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: pyrrav1alpha1.GroupVersion.String(),
+		Kind:       "ServiceLevelObjective",
+		Name:       kubeObjective.GetName(),
+		UID:        kubeObjective.GetUID(),
+	}
+}
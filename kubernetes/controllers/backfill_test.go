@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func Test_backfillName(t *testing.T) {
+	require.Equal(t, "http-backfill", backfillName("http"))
+
+	long := strings.Repeat("a", 60)
+	name := backfillName(long)
+	require.Len(t, name, 63)
+	require.True(t, strings.HasSuffix(name, "-backfill"))
+	require.NotEqual(t, name, backfillName(long+"b"))
+}
+
+func Test_backfillRuleGroups(t *testing.T) {
+	groups, err := makeRuleGroups(httpSLO, true)
+	require.NoError(t, err)
+
+	backfill, err := backfillRuleGroups(groups)
+	require.NoError(t, err)
+	require.Equal(t, []string{"http-increase", "http", "http-generic"}, ruleGroupNames(backfill))
+	for _, g := range backfill {
+		for _, rule := range g.Rules {
+			require.NotEmpty(t, rule.Record)
+			require.NotContains(t, rule.Expr.String(), "http_requests:")
+		}
+	}
+	// The availability selects the recorded increases, the other generic rules are constants or select the raw metrics.
+	var generic []string
+	for _, rule := range backfill[2].Rules {
+		generic = append(generic, rule.Record)
+	}
+	require.Equal(t, []string{"pyrra_objective", "pyrra_window", "pyrra_requests_total", "pyrra_errors_total"}, generic)
+}
+
+func ruleGroupNames(groups []monitoringv1.RuleGroup) []string {
+	names := make([]string, 0, len(groups))
+	for _, g := range groups {
+		names = append(names, g.Name)
+	}
+	return names
+}
+
+func TestServiceLevelObjectiveReconciler_Backfill(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+	objective.CreationTimestamp = metav1.NewTime(created)
+	old := objective.DeepCopy()
+	old.Name = "old"
+	old.CreationTimestamp = metav1.NewTime(created.Add(-2 * time.Hour))
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective, old).
+		WithStatusSubresource(objective, old).
+		Build()
+
+	r := &ServiceLevelObjectiveReconciler{
+		Client: c,
+		Logger: kitlog.NewNopLogger(),
+		Backfill: &Backfill{
+			PrometheusURL: "http://prometheus:9090",
+			Image:         "prometheus",
+			Volume:        corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "prometheus-db"}},
+			VolumeSubPath: "prometheus-db",
+			MaxAge:        time.Since(created) + time.Hour,
+		},
+	}
+	for i := 0; i < 2; i++ {
+		for _, o := range []*pyrrav1alpha1.ServiceLevelObjective{objective, old} {
+			_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(o)})
+			require.NoError(t, err)
+		}
+	}
+
+	var job batchv1.Job
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "monitoring", Name: "http-backfill"}, &job))
+	require.Len(t, job.GetOwnerReferences(), 1)
+	require.Equal(t, objective.GetUID(), job.GetOwnerReferences()[0].UID)
+
+	pod := job.Spec.Template.Spec
+	require.Empty(t, pod.InitContainers)
+	require.Len(t, pod.Containers, 1)
+	require.Equal(t, []string{
+		"tsdb", "create-blocks-from", "rules",
+		"--start=1706875200",
+		"--end=1709294400",
+		"--url=http://prometheus:9090",
+		"--output-dir=/blocks",
+		"/etc/pyrra/rules.yaml",
+	}, pod.Containers[0].Args)
+	require.Equal(t, "prometheus-db", pod.Containers[0].VolumeMounts[1].SubPath)
+	require.Equal(t, "prometheus-db", pod.Volumes[1].PersistentVolumeClaim.ClaimName)
+
+	var configMap corev1.ConfigMap
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "monitoring", Name: "http-backfill"}, &configMap))
+	require.Contains(t, configMap.Data[backfillRulesFile], "record: http_requests:increase4w")
+	require.NotContains(t, configMap.Data[backfillRulesFile], "alert:")
+
+	// Objectives older than the MaxAge aren't backfilled.
+	err := c.Get(context.Background(), client.ObjectKey{Namespace: "monitoring", Name: "old-backfill"}, &job)
+	require.True(t, errors.IsNotFound(err))
+}
+
+func Test_newBackfillJob_Mimir(t *testing.T) {
+	start := time.Date(2024, 2, 2, 12, 0, 0, 0, time.UTC)
+	job := newBackfillJob("http-backfill", httpSLO, Backfill{
+		PrometheusURL: "http://prometheus:9090",
+		Image:         "prometheus",
+		MimirURL:      "http://mimir:8080",
+		MimirTenant:   "team-a",
+		MimirImage:    "mimirtool",
+	}, start, start.Add(28*24*time.Hour))
+
+	pod := job.Spec.Template.Spec
+	require.Len(t, pod.InitContainers, 1)
+	require.Equal(t, "promtool", pod.InitContainers[0].Name)
+	require.Len(t, pod.Containers, 1)
+	require.Equal(t, "mimirtool", pod.Containers[0].Image)
+	require.Equal(t, []corev1.EnvVar{
+		{Name: "MIMIR_ADDRESS", Value: "http://mimir:8080"},
+		{Name: "MIMIR_TENANT", Value: "team-a"},
+	}, pod.Containers[0].Env)
+	require.NotNil(t, pod.Volumes[1].EmptyDir)
+}
@@ -0,0 +1,321 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	prometheusv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// BudgetPolicyQuerier queries Prometheus for the remaining error budget of objectives.
+type BudgetPolicyQuerier interface {
+	Query(ctx context.Context, query string, ts time.Time, opts ...prometheusv1.Option) (model.Value, prometheusv1.Warnings, error)
+}
+
+// BudgetPolicyEvaluator periodically evaluates the error budget policies of all objectives.
+// While thresholds are active it sets their labels on the objective and the freeze flag in its status,
+// silences the alerts of their silence severities with the Silencer,
+// and it emits events as thresholds with notify become active and recover.
+// Labels of thresholds that are removed from a policy aren't cleaned up, as the evaluator no longer knows them.
+type BudgetPolicyEvaluator struct {
+	client.Client
+	Logger   kitlog.Logger
+	Querier  BudgetPolicyQuerier
+	Recorder record.EventRecorder
+	Interval time.Duration
+	// Silencer silences the alerts of thresholds with silence in Alertmanager. They aren't silenced if it is nil.
+	Silencer *AlertmanagerSilencer
+}
+
+var (
+	_ manager.Runnable               = &BudgetPolicyEvaluator{}
+	_ manager.LeaderElectionRunnable = &BudgetPolicyEvaluator{}
+)
+
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// NeedLeaderElection makes sure only one replica patches objectives and emits events.
+func (e *BudgetPolicyEvaluator) NeedLeaderElection() bool {
+	return true
+}
+
+func (e *BudgetPolicyEvaluator) Start(ctx context.Context) error {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.evaluateAll(ctx); err != nil {
+			level.Warn(e.Logger).Log("msg", "failed to evaluate error budget policies", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *BudgetPolicyEvaluator) evaluateAll(ctx context.Context) error {
+	var list pyrrav1alpha1.ServiceLevelObjectiveList
+	if err := e.List(ctx, &list); err != nil {
+		return fmt.Errorf("failed to list objectives: %w", err)
+	}
+
+	for i := range list.Items {
+		kubeObjective := list.Items[i]
+		if kubeObjective.Spec.Policy == nil && kubeObjective.Status.BudgetPolicy == nil {
+			continue
+		}
+		if err := e.evaluate(ctx, kubeObjective); err != nil {
+			level.Warn(e.Logger).Log(
+				"msg", "failed to evaluate error budget policy",
+				"namespace", kubeObjective.GetNamespace(),
+				"name", kubeObjective.GetName(),
+				"err", err,
+			)
+		}
+	}
+	return nil
+}
+
+func (e *BudgetPolicyEvaluator) evaluate(ctx context.Context, kubeObjective pyrrav1alpha1.ServiceLevelObjective) error {
+	if kubeObjective.Spec.Policy == nil {
+		// The policy was removed, nothing is frozen or silenced anymore.
+		if err := e.silence(ctx, kubeObjective, nil); err != nil {
+			return err
+		}
+		return e.patchStatus(ctx, kubeObjective, nil)
+	}
+	policy := *kubeObjective.Spec.Policy
+
+	objective, err := kubeObjective.Internal()
+	if err != nil {
+		return fmt.Errorf("failed to get objective: %w", err)
+	}
+
+	query := objective.QueryErrorBudget()
+	value, _, err := e.Querier.Query(ctx, query, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to query error budget: %w", err)
+	}
+	vector, ok := value.(model.Vector)
+	if !ok {
+		return fmt.Errorf("expected vector, got %s", value.Type())
+	}
+	if len(vector) == 0 || math.IsNaN(float64(vector[0].Value)) {
+		// Without data, like for objectives without any requests, the previous state is kept.
+		return nil
+	}
+	remaining := 100 * float64(vector[0].Value)
+
+	active, err := activeThresholds(policy, remaining)
+	if err != nil {
+		return err
+	}
+
+	if err := e.patchLabels(ctx, &kubeObjective, budgetPolicyLabels(policy, active)); err != nil {
+		return err
+	}
+	if err := e.silence(ctx, kubeObjective, silencedSeverities(active)); err != nil {
+		return err
+	}
+
+	status := &pyrrav1alpha1.BudgetPolicyStatus{}
+	for _, t := range active {
+		status.Thresholds = append(status.Thresholds, t.Remaining)
+		status.Freeze = status.Freeze || t.Freeze
+	}
+
+	previous := kubeObjective.Status.BudgetPolicy
+	if err := e.patchStatus(ctx, kubeObjective, status); err != nil {
+		return err
+	}
+	e.recordEvents(&kubeObjective, policy, previous, status, remaining)
+
+	return nil
+}
+
+// silence silences the objective's alerts with the severities, or expires its silences if there are none.
+func (e *BudgetPolicyEvaluator) silence(ctx context.Context, kubeObjective pyrrav1alpha1.ServiceLevelObjective, severities []string) error {
+	if e.Silencer == nil {
+		return nil
+	}
+	objective, err := kubeObjective.Internal()
+	if err != nil {
+		return fmt.Errorf("failed to get objective: %w", err)
+	}
+	created, expired, err := e.Silencer.Silence(ctx, kubeObjective, objective, severities)
+	if err != nil {
+		return fmt.Errorf("failed to silence alerts: %w", err)
+	}
+	if e.Recorder == nil {
+		return nil
+	}
+	switch {
+	case created:
+		e.Recorder.Eventf(&kubeObjective, corev1.EventTypeWarning, "ErrorBudgetPolicy",
+			"Silenced the %s alerts in Alertmanager until the error budget recovers", strings.Join(severities, ", "))
+	case expired && len(severities) == 0:
+		e.Recorder.Eventf(&kubeObjective, corev1.EventTypeNormal, "ErrorBudgetPolicy", "Expired the silences of the alerts in Alertmanager")
+	}
+	return nil
+}
+
+// activeThresholds returns the thresholds above the remaining error budget in percent,
+// ordered from the highest to the lowest.
+func activeThresholds(policy pyrrav1alpha1.ErrorBudgetPolicy, remaining float64) ([]pyrrav1alpha1.ErrorBudgetThreshold, error) {
+	type threshold struct {
+		value float64
+		pyrrav1alpha1.ErrorBudgetThreshold
+	}
+
+	var active []threshold
+	for _, t := range policy.Thresholds {
+		value, err := strconv.ParseFloat(t.Remaining, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse policy threshold remaining: %w", err)
+		}
+		if remaining < value {
+			active = append(active, threshold{value: value, ErrorBudgetThreshold: t})
+		}
+	}
+	sort.SliceStable(active, func(i, j int) bool {
+		return active[i].value > active[j].value
+	})
+
+	thresholds := make([]pyrrav1alpha1.ErrorBudgetThreshold, len(active))
+	for i, t := range active {
+		thresholds[i] = t.ErrorBudgetThreshold
+	}
+	return thresholds, nil
+}
+
+// budgetPolicyLabels returns all labels the policy sets, with the value of the active thresholds or empty if none sets it.
+// Active thresholds are ordered from the highest to the lowest, so the lowest wins.
+func budgetPolicyLabels(policy pyrrav1alpha1.ErrorBudgetPolicy, active []pyrrav1alpha1.ErrorBudgetThreshold) map[string]string {
+	desired := map[string]string{}
+	for _, t := range policy.Thresholds {
+		for name := range t.Labels {
+			desired[name] = ""
+		}
+	}
+	for _, t := range active {
+		for name, value := range t.Labels {
+			desired[name] = value
+		}
+	}
+	return desired
+}
+
+// patchLabels sets the labels with a value and removes those that are empty.
+func (e *BudgetPolicyEvaluator) patchLabels(ctx context.Context, kubeObjective *pyrrav1alpha1.ServiceLevelObjective, desired map[string]string) error {
+	labels := make(map[string]string, len(kubeObjective.GetLabels()))
+	for name, value := range kubeObjective.GetLabels() {
+		labels[name] = value
+	}
+	var changed bool
+	for name, value := range desired {
+		current, ok := labels[name]
+		switch {
+		case value == "" && ok:
+			delete(labels, name)
+			changed = true
+		case value != "" && current != value:
+			labels[name] = value
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	patch := client.MergeFrom(kubeObjective.DeepCopy())
+	kubeObjective.SetLabels(labels)
+	if err := e.Patch(ctx, kubeObjective, patch); err != nil {
+		return fmt.Errorf("failed to patch labels: %w", err)
+	}
+	return nil
+}
+
+// patchStatus writes the policy status of the objective if it changed.
+func (e *BudgetPolicyEvaluator) patchStatus(ctx context.Context, kubeObjective pyrrav1alpha1.ServiceLevelObjective, status *pyrrav1alpha1.BudgetPolicyStatus) error {
+	if equality.Semantic.DeepEqual(kubeObjective.Status.BudgetPolicy, status) {
+		return nil
+	}
+
+	patch := client.MergeFrom(kubeObjective.DeepCopy())
+	kubeObjective.Status.BudgetPolicy = status
+	if err := e.Status().Patch(ctx, &kubeObjective, patch); err != nil {
+		return client.IgnoreNotFound(fmt.Errorf("failed to patch status: %w", err))
+	}
+	return nil
+}
+
+// recordEvents emits a warning for every threshold with notify that became active and a normal event for every one that recovered.
+func (e *BudgetPolicyEvaluator) recordEvents(
+	kubeObjective *pyrrav1alpha1.ServiceLevelObjective,
+	policy pyrrav1alpha1.ErrorBudgetPolicy,
+	previous, current *pyrrav1alpha1.BudgetPolicyStatus,
+	remaining float64,
+) {
+	if e.Recorder == nil {
+		return
+	}
+
+	wasActive := map[string]bool{}
+	if previous != nil {
+		for _, t := range previous.Thresholds {
+			wasActive[t] = true
+		}
+	}
+	isActive := map[string]bool{}
+	for _, t := range current.Thresholds {
+		isActive[t] = true
+	}
+
+	for _, t := range policy.Thresholds {
+		if !t.Notify {
+			continue
+		}
+		switch {
+		case isActive[t.Remaining] && !wasActive[t.Remaining]:
+			e.Recorder.Eventf(kubeObjective, corev1.EventTypeWarning, "ErrorBudgetPolicy",
+				"Error budget remaining %.2f%% fell below the threshold of %s%%", remaining, t.Remaining)
+		case !isActive[t.Remaining] && wasActive[t.Remaining]:
+			e.Recorder.Eventf(kubeObjective, corev1.EventTypeNormal, "ErrorBudgetPolicy",
+				"Error budget remaining %.2f%% recovered above the threshold of %s%%", remaining, t.Remaining)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	prometheusv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+type budgetQuerierFunc func() float64
+
+func (f budgetQuerierFunc) Query(_ context.Context, _ string, _ time.Time, _ ...prometheusv1.Option) (model.Value, prometheusv1.Warnings, error) {
+	return model.Vector{{Value: model.SampleValue(f())}}, nil, nil
+}
+
+func TestBudgetPolicyEvaluator(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+	objective.Labels = map[string]string{"team": "foo"}
+	objective.Spec.Policy = &pyrrav1alpha1.ErrorBudgetPolicy{
+		Thresholds: []pyrrav1alpha1.ErrorBudgetThreshold{{
+			Remaining: "25",
+			Notify:    true,
+			Labels:    map[string]string{"deploy": "frozen", "budget": "exhausted"},
+			Freeze:    true,
+		}, {
+			Remaining: "50",
+			Labels:    map[string]string{"budget": "low"},
+		}},
+	}
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective).
+		WithStatusSubresource(objective).
+		Build()
+
+	remaining := 0.8
+	recorder := record.NewFakeRecorder(10)
+	e := &BudgetPolicyEvaluator{
+		Client:   c,
+		Logger:   kitlog.NewNopLogger(),
+		Querier:  budgetQuerierFunc(func() float64 { return remaining }),
+		Recorder: recorder,
+		Interval: time.Minute,
+	}
+
+	evaluate := func(r float64) *pyrrav1alpha1.ServiceLevelObjective {
+		remaining = r
+		require.NoError(t, e.evaluateAll(context.Background()))
+
+		var o pyrrav1alpha1.ServiceLevelObjective
+		require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(objective), &o))
+		return &o
+	}
+
+	o := evaluate(0.8)
+	require.Equal(t, map[string]string{"team": "foo"}, o.GetLabels())
+	require.Equal(t, &pyrrav1alpha1.BudgetPolicyStatus{}, o.Status.BudgetPolicy)
+	require.Empty(t, recorder.Events)
+
+	o = evaluate(0.4)
+	require.Equal(t, map[string]string{"team": "foo", "budget": "low"}, o.GetLabels())
+	require.Equal(t, &pyrrav1alpha1.BudgetPolicyStatus{Thresholds: []string{"50"}}, o.Status.BudgetPolicy)
+	require.Empty(t, recorder.Events)
+
+	// The lowest active threshold wins the budget label.
+	o = evaluate(0.1)
+	require.Equal(t, map[string]string{"team": "foo", "budget": "exhausted", "deploy": "frozen"}, o.GetLabels())
+	require.Equal(t, &pyrrav1alpha1.BudgetPolicyStatus{Thresholds: []string{"50", "25"}, Freeze: true}, o.Status.BudgetPolicy)
+	require.Equal(t, "Warning ErrorBudgetPolicy Error budget remaining 10.00% fell below the threshold of 25%", <-recorder.Events)
+
+	// Nothing changes while the thresholds stay active.
+	resourceVersion := o.GetResourceVersion()
+	o = evaluate(0.05)
+	require.Equal(t, resourceVersion, o.GetResourceVersion())
+	require.Empty(t, recorder.Events)
+
+	o = evaluate(0.9)
+	require.Equal(t, map[string]string{"team": "foo"}, o.GetLabels())
+	require.Equal(t, &pyrrav1alpha1.BudgetPolicyStatus{}, o.Status.BudgetPolicy)
+	require.Equal(t, "Normal ErrorBudgetPolicy Error budget remaining 90.00% recovered above the threshold of 25%", <-recorder.Events)
+
+	// Removing the policy clears its status.
+	o = evaluate(0.1)
+	o.Spec.Policy = nil
+	require.NoError(t, c.Update(context.Background(), o))
+	o = evaluate(0.1)
+	require.Nil(t, o.Status.BudgetPolicy)
+}
+
+func TestActiveThresholds(t *testing.T) {
+	policy := pyrrav1alpha1.ErrorBudgetPolicy{
+		Thresholds: []pyrrav1alpha1.ErrorBudgetThreshold{
+			{Remaining: "10"},
+			{Remaining: "50"},
+			{Remaining: "25"},
+		},
+	}
+
+	active, err := activeThresholds(policy, 20)
+	require.NoError(t, err)
+	require.Equal(t, []pyrrav1alpha1.ErrorBudgetThreshold{{Remaining: "50"}, {Remaining: "25"}}, active)
+
+	active, err = activeThresholds(policy, -10)
+	require.NoError(t, err)
+	require.Len(t, active, 3)
+
+	active, err = activeThresholds(policy, 50)
+	require.NoError(t, err)
+	require.Empty(t, active)
+}
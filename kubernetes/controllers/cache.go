@@ -0,0 +1,130 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// ruleGroupCache holds the rule groups last generated and mutated for each objective,
+// so resyncs of unchanged objectives don't generate their rules again.
+type ruleGroupCache struct {
+	mu      sync.Mutex
+	entries map[types.NamespacedName]ruleGroupCacheEntry
+}
+
+type ruleGroupCacheEntry struct {
+	hash   string
+	groups []monitoringv1.RuleGroup
+}
+
+// get returns the rule groups of the objective, generating them and running the mutators only if the objective or settings changed.
+func (c *ruleGroupCache) get(ctx context.Context, kubeObjective pyrrav1alpha1.ServiceLevelObjective, genericRules bool, mutators []RuleMutator) ([]monitoringv1.RuleGroup, error) {
+	hash, err := ruleGroupsHash(kubeObjective, genericRules)
+	if err != nil {
+		return nil, err
+	}
+	key := types.NamespacedName{Namespace: kubeObjective.GetNamespace(), Name: kubeObjective.GetName()}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && entry.hash == hash {
+		return copyRuleGroups(entry.groups), nil
+	}
+
+	groups, err := makeRuleGroups(kubeObjective, genericRules)
+	if err != nil {
+		return nil, err
+	}
+	groups, err = mutateRuleGroups(ctx, kubeObjective, groups, mutators)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = map[types.NamespacedName]ruleGroupCacheEntry{}
+	}
+	c.entries[key] = ruleGroupCacheEntry{hash: hash, groups: copyRuleGroups(groups)}
+	c.mu.Unlock()
+
+	return groups, nil
+}
+
+// delete drops the rule groups of a deleted objective.
+func (c *ruleGroupCache) delete(key types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// ruleGroupsHash returns a hash of everything the rule groups are generated from,
+// including the specs of the objectives a composite combines.
+func ruleGroupsHash(kubeObjective pyrrav1alpha1.ServiceLevelObjective, genericRules bool) (string, error) {
+	var composite []*pyrrav1alpha1.ServiceLevelObjectiveSpec
+	if c := kubeObjective.Spec.ServiceLevelIndicator.Composite; c != nil {
+		for _, co := range c.Objectives {
+			if co.Objective == nil {
+				composite = append(composite, nil)
+				continue
+			}
+			composite = append(composite, &co.Objective.Spec)
+		}
+	}
+
+	b, err := json.Marshal(struct {
+		Name         string
+		Namespace    string
+		Labels       map[string]string
+		Annotations  map[string]string
+		Spec         pyrrav1alpha1.ServiceLevelObjectiveSpec
+		Composite    []*pyrrav1alpha1.ServiceLevelObjectiveSpec
+		GenericRules bool
+	}{
+		Name:         kubeObjective.GetName(),
+		Namespace:    kubeObjective.GetNamespace(),
+		Labels:       kubeObjective.GetLabels(),
+		Annotations:  kubeObjective.GetAnnotations(),
+		Spec:         kubeObjective.Spec,
+		Composite:    composite,
+		GenericRules: genericRules,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash objective: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func copyRuleGroups(groups []monitoringv1.RuleGroup) []monitoringv1.RuleGroup {
+	copied := make([]monitoringv1.RuleGroup, len(groups))
+	for i := range groups {
+		groups[i].DeepCopyInto(&copied[i])
+	}
+	return copied
+}
@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestRuleGroupCache(t *testing.T) {
+	var cache ruleGroupCache
+	key := types.NamespacedName{Name: httpSLO.GetName()}
+
+	expected, err := makeRuleGroups(httpSLO, false)
+	require.NoError(t, err)
+
+	groups, err := cache.get(context.Background(), httpSLO, false, nil)
+	require.NoError(t, err)
+	require.Equal(t, expected, groups)
+
+	// Mark the cached entry to see that it's returned for the unchanged objective.
+	entry := cache.entries[key]
+	entry.groups = []monitoringv1.RuleGroup{{Name: "cached"}}
+	cache.entries[key] = entry
+
+	groups, err = cache.get(context.Background(), httpSLO, false, nil)
+	require.NoError(t, err)
+	require.Equal(t, []monitoringv1.RuleGroup{{Name: "cached"}}, groups)
+
+	// Modifying the returned groups doesn't modify the cache.
+	groups[0].Name = "modified"
+	groups, err = cache.get(context.Background(), httpSLO, false, nil)
+	require.NoError(t, err)
+	require.Equal(t, "cached", groups[0].Name)
+
+	// Changing the settings generates the rules again.
+	groups, err = cache.get(context.Background(), httpSLO, true, nil)
+	require.NoError(t, err)
+	require.Len(t, groups, 3)
+
+	// Changing the objective generates the rules again.
+	changed := *httpSLO.DeepCopy()
+	changed.Spec.Target = "99.9"
+	groups, err = cache.get(context.Background(), changed, true, nil)
+	require.NoError(t, err)
+	expected, err = makeRuleGroups(changed, true)
+	require.NoError(t, err)
+	require.Equal(t, expected, groups)
+
+	cache.delete(key)
+	require.Empty(t, cache.entries)
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+// cardinalityTimeout bounds how long reconciles wait for Prometheus to estimate the series of an objective.
+const cardinalityTimeout = 10 * time.Second
+
+// Cardinality estimates the series of objectives with grouping from Prometheus before their rules are written,
+// as a single grouping label with many values, like a request ID, makes each of their recording rules
+// result in as many series and overloads the ruler.
+type Cardinality struct {
+	Querier BudgetPolicyQuerier
+	// MaxSeries is the most series each recording rule of an objective may result in.
+	MaxSeries int
+	// Enforce refuses to write the rules of objectives above MaxSeries, they're only warned about otherwise.
+	Enforce bool
+}
+
+// EstimateSeries returns how many series each recording rule of the objective results in,
+// the number of distinct values of its grouping labels among the series of its metrics.
+// Objectives without grouping result in a single series.
+func EstimateSeries(ctx context.Context, querier BudgetPolicyQuerier, objective slo.Objective) (int, error) {
+	grouping := objective.Grouping()
+	if len(grouping) == 0 {
+		return 1, nil
+	}
+
+	var metrics []slo.Metric
+	switch objective.IndicatorType() {
+	case slo.Ratio:
+		metrics = []slo.Metric{objective.Indicator.Ratio.Total}
+	case slo.Latency:
+		metrics = []slo.Metric{objective.Indicator.Latency.Total}
+	case slo.LatencyNative:
+		metrics = []slo.Metric{objective.Indicator.LatencyNative.Total}
+	case slo.BoolGauge:
+		metrics = []slo.Metric{objective.Indicator.BoolGauge.Metric}
+		if objective.Indicator.Quantile != nil {
+			metrics = []slo.Metric{objective.Indicator.Quantile.Total}
+		}
+	case slo.Expression:
+		var err error
+		metrics, err = expressionMetrics(objective.Indicator.Expression.Total)
+		if err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("series of %s can't be estimated", objective.Name())
+	}
+
+	var series int
+	for _, m := range metrics {
+		query := fmt.Sprintf("count(count by (%s) (%s))", strings.Join(grouping, ", "), m.Metric())
+		value, _, err := querier.Query(ctx, query, time.Now())
+		if err != nil {
+			return 0, fmt.Errorf("failed to query %s: %w", query, err)
+		}
+		vector, ok := value.(model.Vector)
+		if !ok {
+			return 0, fmt.Errorf("expected vector, got %s", value.Type())
+		}
+		if len(vector) > 0 && int(vector[0].Value) > series {
+			series = int(vector[0].Value)
+		}
+	}
+	return series, nil
+}
+
+// checkCardinality estimates the series of the objective's recording rules if it has grouping
+// and sets the HighCardinality condition of the status from them, with an event once they exceed the MaxSeries.
+// Objectives above the MaxSeries are invalid if the Cardinality is enforced.
+// Failing to estimate the series doesn't keep the rules from being written, the condition is left as it is then.
+func (r *ServiceLevelObjectiveReconciler) checkCardinality(
+	ctx context.Context,
+	logger kitlog.Logger,
+	kubeObjective *pyrrav1alpha1.ServiceLevelObjective,
+	status *pyrrav1alpha1.ServiceLevelObjectiveStatus,
+) (err error) {
+	// The metrics of objectives evaluated by Loki are LogQL queries Prometheus doesn't know.
+	if r.Cardinality == nil || IsLokiObjective(kubeObjective.GetAnnotations()) {
+		return nil
+	}
+	objective, err := kubeObjective.Internal()
+	if err != nil {
+		// The writers report invalid objectives.
+		return nil
+	}
+	if len(objective.Grouping()) == 0 {
+		meta.RemoveStatusCondition(&status.Conditions, pyrrav1alpha1.ConditionHighCardinality)
+		return nil
+	}
+
+	ctx, end := startSpan(ctx, "estimate series", &err)
+	defer end()
+	ctx, cancel := context.WithTimeout(ctx, cardinalityTimeout)
+	defer cancel()
+
+	series, err := EstimateSeries(ctx, r.Cardinality.Querier, objective)
+	if err != nil {
+		level.Warn(logger).Log("msg", "failed to estimate series", "err", err)
+		return nil
+	}
+
+	if series <= r.Cardinality.MaxSeries {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               pyrrav1alpha1.ConditionHighCardinality,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: kubeObjective.GetGeneration(),
+			Reason:             reasonCardinalityOK,
+			Message:            fmt.Sprintf("Grouping by %s results in %d series.", strings.Join(objective.Grouping(), ", "), series),
+		})
+		return nil
+	}
+
+	message := fmt.Sprintf("Grouping by %s results in %d series, more than the limit of %d.", strings.Join(objective.Grouping(), ", "), series, r.Cardinality.MaxSeries)
+	exceeded := meta.IsStatusConditionTrue(status.Conditions, pyrrav1alpha1.ConditionHighCardinality)
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               pyrrav1alpha1.ConditionHighCardinality,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: kubeObjective.GetGeneration(),
+		Reason:             reasonHighCardinality,
+		Message:            message,
+	})
+	if r.Cardinality.Enforce {
+		// The reconcile emits the event of invalid objectives.
+		return invalidObjectiveError{err: fmt.Errorf("grouping by %s results in %d series, more than the limit of %d, no rules are written",
+			strings.Join(objective.Grouping(), ", "), series, r.Cardinality.MaxSeries)}
+	}
+	if !exceeded {
+		r.event(kubeObjective, corev1.EventTypeWarning, reasonHighCardinality, "%s", message)
+	}
+	level.Warn(logger).Log("msg", "objective has high cardinality", "series", series, "max_series", r.Cardinality.MaxSeries)
+	return nil
+}
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	prometheusv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+const groupedSeriesQuery = `count(count by (handler) (http_requests_total{job="app"}))`
+
+func TestEstimateSeries(t *testing.T) {
+	objective, err := httpSLO.Internal()
+	require.NoError(t, err)
+	series, err := EstimateSeries(context.Background(), seriesQuerier{}, objective)
+	require.NoError(t, err)
+	require.Equal(t, 1, series)
+
+	grouped := httpSLO.DeepCopy()
+	grouped.Spec.ServiceLevelIndicator.Ratio.Grouping = []string{"handler"}
+	objective, err = grouped.Internal()
+	require.NoError(t, err)
+	series, err = EstimateSeries(context.Background(), seriesQuerier{groupedSeriesQuery: 42}, objective)
+	require.NoError(t, err)
+	require.Equal(t, 42, series)
+
+	grouped.Spec.ServiceLevelIndicator = pyrrav1alpha1.ServiceLevelIndicator{
+		Expression: &pyrrav1alpha1.ExpressionIndicator{
+			Errors:   `sum by (handler) (http_requests_total{job="app",status=~"5.."})`,
+			Total:    `sum by (handler) (http_requests_total{job="app"}) + sum by (handler) (grpc_requests_total)`,
+			Grouping: []string{"handler"},
+		},
+	}
+	objective, err = grouped.Internal()
+	require.NoError(t, err)
+	series, err = EstimateSeries(context.Background(), seriesQuerier{
+		`count(count by (handler) (http_requests_total{job="app"}))`: 42,
+		`count(count by (handler) (grpc_requests_total))`:            100,
+	}, objective)
+	require.NoError(t, err)
+	require.Equal(t, 100, series)
+}
+
+// failingQuerier fails all queries, like an unavailable Prometheus.
+type failingQuerier struct{}
+
+func (failingQuerier) Query(context.Context, string, time.Time, ...prometheusv1.Option) (model.Value, prometheusv1.Warnings, error) {
+	return nil, nil, fmt.Errorf("prometheus is down")
+}
+
+func TestServiceLevelObjectiveReconciler_Cardinality(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+	objective.Spec.ServiceLevelIndicator.Ratio.Grouping = []string{"handler"}
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective).
+		WithStatusSubresource(objective).
+		Build()
+
+	series := seriesQuerier{groupedSeriesQuery: 5000}
+	recorder := record.NewFakeRecorder(10)
+	r := &ServiceLevelObjectiveReconciler{
+		Client:      c,
+		Logger:      kitlog.NewNopLogger(),
+		Recorder:    recorder,
+		Cardinality: &Cardinality{Querier: series, MaxSeries: 1000},
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+	reconcile := func() (pyrrav1alpha1.ServiceLevelObjectiveStatus, error) {
+		_, err := r.Reconcile(context.Background(), req)
+		require.NoError(t, err)
+		var o pyrrav1alpha1.ServiceLevelObjective
+		require.NoError(t, c.Get(context.Background(), req.NamespacedName, &o))
+		var rule monitoringv1.PrometheusRule
+		return o.Status, c.Get(context.Background(), req.NamespacedName, &rule)
+	}
+
+	// Objectives above the limit are only warned about by default.
+	status, err := reconcile()
+	require.NoError(t, err)
+	condition := meta.FindStatusCondition(status.Conditions, pyrrav1alpha1.ConditionHighCardinality)
+	require.NotNil(t, condition)
+	require.Equal(t, metav1.ConditionTrue, condition.Status)
+	require.Equal(t, "Grouping by handler results in 5000 series, more than the limit of 1000.", condition.Message)
+	require.True(t, meta.IsStatusConditionTrue(status.Conditions, pyrrav1alpha1.ConditionReady))
+	require.Equal(t, "Warning HighCardinality Grouping by handler results in 5000 series, more than the limit of 1000.", <-recorder.Events)
+	require.Equal(t, "Normal RulesCreated Created PrometheusRule http", <-recorder.Events)
+
+	// The event is only emitted once the limit is exceeded.
+	_, err = reconcile()
+	require.NoError(t, err)
+	require.Empty(t, recorder.Events)
+
+	// Failing to estimate the series keeps the condition.
+	r.Cardinality.Querier = failingQuerier{}
+	status, err = reconcile()
+	require.NoError(t, err)
+	require.True(t, meta.IsStatusConditionTrue(status.Conditions, pyrrav1alpha1.ConditionHighCardinality))
+	r.Cardinality.Querier = series
+
+	series[groupedSeriesQuery] = 10
+	status, err = reconcile()
+	require.NoError(t, err)
+	require.True(t, meta.IsStatusConditionFalse(status.Conditions, pyrrav1alpha1.ConditionHighCardinality))
+
+	// Enforced, the rules of objectives above the limit aren't written.
+	require.NoError(t, c.Delete(context.Background(), &monitoringv1.PrometheusRule{ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "http"}}))
+	r.Cardinality.Enforce = true
+	series[groupedSeriesQuery] = 5000
+	status, err = reconcile()
+	require.True(t, errors.IsNotFound(err))
+	require.True(t, meta.IsStatusConditionTrue(status.Conditions, pyrrav1alpha1.ConditionHighCardinality))
+	require.True(t, meta.IsStatusConditionTrue(status.Conditions, pyrrav1alpha1.ConditionValidationFailed))
+	require.Equal(t, "Warning InvalidSpec grouping by handler results in 5000 series, more than the limit of 1000, no rules are written", <-recorder.Events)
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-kit/log/level"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// resolveComposite sets the objectives combined by a composite objective from the objectives in its namespace.
+// Objectives that don't exist yet make the composite invalid until they're created, which reconciles it again.
+func (r *ServiceLevelObjectiveReconciler) resolveComposite(ctx context.Context, kubeObjective *pyrrav1alpha1.ServiceLevelObjective) error {
+	if kubeObjective.Spec.ServiceLevelIndicator.Composite == nil {
+		return nil
+	}
+
+	var list pyrrav1alpha1.ServiceLevelObjectiveList
+	if err := r.List(ctx, &list, client.InNamespace(kubeObjective.GetNamespace())); err != nil {
+		return fmt.Errorf("failed to list objectives of the composite: %w", err)
+	}
+	if err := resolveComposite(kubeObjective, list.Items); err != nil {
+		return invalidObjectiveError{err: err}
+	}
+	return nil
+}
+
+// resolveComposite sets the objectives combined by the composite objective from the objectives by their name.
+func resolveComposite(kubeObjective *pyrrav1alpha1.ServiceLevelObjective, objectives []pyrrav1alpha1.ServiceLevelObjective) error {
+	composite := kubeObjective.Spec.ServiceLevelIndicator.Composite
+	if composite == nil {
+		return nil
+	}
+
+	byName := make(map[string]*pyrrav1alpha1.ServiceLevelObjective, len(objectives))
+	for i, o := range objectives {
+		if o.GetNamespace() == kubeObjective.GetNamespace() {
+			byName[o.GetName()] = &objectives[i]
+		}
+	}
+
+	// The composite is copied, so resolving doesn't change the objectives it was copied from.
+	composite = composite.DeepCopy()
+	for i, co := range composite.Objectives {
+		o, ok := byName[co.Name]
+		if !ok {
+			return fmt.Errorf("objective %s of the composite doesn't exist in namespace %s", co.Name, kubeObjective.GetNamespace())
+		}
+		composite.Objectives[i].Objective = o.DeepCopy()
+	}
+	kubeObjective.Spec.ServiceLevelIndicator.Composite = composite
+	return nil
+}
+
+// compositesForObjective returns the requests of all composite objectives combining the objective,
+// whose rules depend on the objective's indicator.
+func (r *ServiceLevelObjectiveReconciler) compositesForObjective(ctx context.Context, obj client.Object) []reconcile.Request {
+	var list pyrrav1alpha1.ServiceLevelObjectiveList
+	if err := r.List(ctx, &list, client.InNamespace(obj.GetNamespace())); err != nil {
+		level.Warn(r.Logger).Log("msg", "failed to list objectives for composites", "namespace", obj.GetNamespace(), "err", err)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, o := range list.Items {
+		composite := o.Spec.ServiceLevelIndicator.Composite
+		if composite == nil {
+			continue
+		}
+		for _, co := range composite.Objectives {
+			if co.Name == obj.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&o)})
+				break
+			}
+		}
+	}
+	return requests
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func TestServiceLevelObjectiveReconciler_Composite(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	child := httpSLO.DeepCopy()
+	child.TypeMeta = metav1.TypeMeta{}
+	child.Namespace = "monitoring"
+
+	composite := &pyrrav1alpha1.ServiceLevelObjective{
+		ObjectMeta: metav1.ObjectMeta{Name: "journey", Namespace: "monitoring"},
+		Spec: pyrrav1alpha1.ServiceLevelObjectiveSpec{
+			Target: "99",
+			Window: "28d",
+			ServiceLevelIndicator: pyrrav1alpha1.ServiceLevelIndicator{
+				Composite: &pyrrav1alpha1.CompositeIndicator{Objectives: []pyrrav1alpha1.CompositeObjective{
+					{Name: "http", Weight: "2"},
+					{Name: "checkout"},
+				}},
+			},
+		},
+	}
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(child, composite).
+		WithStatusSubresource(child, composite).
+		Build()
+
+	r := &ServiceLevelObjectiveReconciler{Client: c, Logger: kitlog.NewNopLogger()}
+	reconcileObjective := func(obj client.Object) {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+		require.NoError(t, err)
+	}
+
+	// Composites are invalid until all their objectives exist.
+	reconcileObjective(composite)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(composite), composite))
+	invalid := meta.FindStatusCondition(composite.Status.Conditions, pyrrav1alpha1.ConditionValidationFailed)
+	require.Equal(t, metav1.ConditionTrue, invalid.Status)
+	require.Equal(t, "objective checkout of the composite doesn't exist in namespace monitoring", invalid.Message)
+
+	// Creating the missing objective reconciles the composite.
+	checkout := child.DeepCopy()
+	checkout.ResourceVersion = ""
+	checkout.Name = "checkout"
+	require.NoError(t, c.Create(context.Background(), checkout))
+	require.Equal(t, []reconcile.Request{{NamespacedName: client.ObjectKeyFromObject(composite)}}, r.compositesForObjective(context.Background(), checkout))
+	require.Empty(t, r.compositesForObjective(context.Background(), composite))
+
+	reconcileObjective(composite)
+	var rule monitoringv1.PrometheusRule
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(composite), &rule))
+	increase := rule.Spec.Groups[0].Rules[0]
+	require.Equal(t, "pyrra_composite:burnrate4w", increase.Record)
+	require.Equal(t,
+		`(2 * ((sum(rate(http_requests_total{job="app",status=~"5.."}[4w])) / sum(rate(http_requests_total{job="app"}[4w]))) or vector(0)) + `+
+			`1 * ((sum(rate(http_requests_total{job="app",status=~"5.."}[4w])) / sum(rate(http_requests_total{job="app"}[4w]))) or vector(0))) / 3`,
+		increase.Expr.String(),
+	)
+
+	// Changes of the objectives change the composite's rules.
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(checkout), checkout))
+	checkout.Spec.ServiceLevelIndicator.Ratio.Total.Metric = `checkout_requests_total{job="app"}`
+	require.NoError(t, c.Update(context.Background(), checkout))
+	reconcileObjective(composite)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(composite), &rule))
+	require.Contains(t, rule.Spec.Groups[0].Rules[0].Expr.String(), "checkout_requests_total")
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// Reasons of the objectives' conditions and events.
+const (
+	reasonValid        = "Valid"
+	reasonInvalidSpec  = "InvalidSpec"
+	reasonRulesWritten = "RulesWritten"
+	reasonRulesCreated = "RulesCreated"
+	reasonRulesUpdated = "RulesUpdated"
+	reasonRulesDeleted = "RulesDeleted"
+	reasonWriteFailed  = "WriteFailed"
+	reasonPushFailed   = "PushFailed"
+	reasonRulesPushed  = "RulesPushed"
+
+	reasonPartiallyWritten = "PartiallyWritten"
+	reasonRulesDrifted     = "RulesDrifted"
+
+	reasonDashboardCreated  = "DashboardCreated"
+	reasonDashboardUpdated  = "DashboardUpdated"
+	reasonFolderCreated     = "FolderCreated"
+	reasonUnknownDatasource = "UnknownDatasource"
+
+	reasonRulesRenamed  = "RulesRenamed"
+	reasonWindowChanged = "WindowChanged"
+
+	reasonRulesShadowed = "RulesShadowed"
+	reasonRulesPromoted = "RulesPromoted"
+
+	reasonRulesNotSelected = "RulesNotSelected"
+
+	reasonHighCardinality = "HighCardinality"
+	reasonCardinalityOK   = "CardinalityOK"
+
+	reasonPaused        = "Paused"
+	reasonAlertsDeleted = "AlertsDeleted"
+	reasonResumed       = "Resumed"
+)
+
+// invalidObjectiveError is returned for objectives no rules can be generated for.
+// Retrying doesn't help until their spec changes, which triggers another reconcile.
+type invalidObjectiveError struct {
+	err error
+}
+
+func (e invalidObjectiveError) Error() string { return e.err.Error() }
+
+func (e invalidObjectiveError) Unwrap() error { return e.err }
+
+func isInvalidObjective(err error) bool {
+	var invalid invalidObjectiveError
+	return errors.As(err, &invalid)
+}
+
+// rulerPushError is returned if pushing rule groups to a ruler's API failed.
+type rulerPushError struct {
+	err error
+}
+
+func (e rulerPushError) Error() string { return e.err.Error() }
+
+func (e rulerPushError) Unwrap() error { return e.err }
+
+// partialWriteError is returned if writing the objective to some outputs failed while the others were written.
+// It wraps the errors of the failed outputs.
+type partialWriteError struct {
+	err error
+}
+
+func (e partialWriteError) Error() string { return e.err.Error() }
+
+func (e partialWriteError) Unwrap() error { return e.err }
+
+// failureReason returns the reason of the conditions and events of a failed reconcile.
+func failureReason(err error) string {
+	var push rulerPushError
+	switch {
+	case isInvalidObjective(err):
+		return reasonInvalidSpec
+	case errors.As(err, &push):
+		return reasonPushFailed
+	default:
+		return reasonWriteFailed
+	}
+}
+
+// setConditions sets the conditions of the status from the outcome of the reconcile.
+// The transition times of conditions only change as their status changes.
+func setConditions(status *pyrrav1alpha1.ServiceLevelObjectiveStatus, generation int64, err error) {
+	set := func(conditionType string, conditionStatus metav1.ConditionStatus, reason, message string) {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               conditionType,
+			Status:             conditionStatus,
+			ObservedGeneration: generation,
+			Reason:             reason,
+			Message:            message,
+		})
+	}
+
+	switch {
+	case err == nil:
+		message := fmt.Sprintf("Rules written to %s %s.", status.Type, status.RuleName)
+		set(pyrrav1alpha1.ConditionValidationFailed, metav1.ConditionFalse, reasonValid, "")
+		set(pyrrav1alpha1.ConditionRulesWritten, metav1.ConditionTrue, reasonRulesWritten, message)
+		set(pyrrav1alpha1.ConditionReady, metav1.ConditionTrue, reasonRulesWritten, message)
+	case isInvalidObjective(err):
+		set(pyrrav1alpha1.ConditionValidationFailed, metav1.ConditionTrue, reasonInvalidSpec, err.Error())
+		set(pyrrav1alpha1.ConditionRulesWritten, metav1.ConditionFalse, reasonInvalidSpec, "No rules can be generated from the spec.")
+		set(pyrrav1alpha1.ConditionReady, metav1.ConditionFalse, reasonInvalidSpec, err.Error())
+	default:
+		set(pyrrav1alpha1.ConditionValidationFailed, metav1.ConditionFalse, reasonValid, "")
+		set(pyrrav1alpha1.ConditionRulesWritten, metav1.ConditionFalse, failureReason(err), err.Error())
+		set(pyrrav1alpha1.ConditionReady, metav1.ConditionFalse, failureReason(err), err.Error())
+	}
+
+	var push rulerPushError
+	switch {
+	case errors.As(err, &push):
+		set(pyrrav1alpha1.ConditionRulerSynced, metav1.ConditionFalse, reasonPushFailed, err.Error())
+	case err == nil && status.Type == lokiRulerStatusType:
+		set(pyrrav1alpha1.ConditionRulerSynced, metav1.ConditionTrue, reasonRulesPushed, fmt.Sprintf("Rule groups pushed to the Loki ruler as %s.", status.RuleName))
+	case err == nil:
+		// The objective's rules aren't pushed to a ruler (anymore).
+		meta.RemoveStatusCondition(&status.Conditions, pyrrav1alpha1.ConditionRulerSynced)
+	}
+
+	var partial partialWriteError
+	if errors.As(err, &partial) {
+		set(pyrrav1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonPartiallyWritten, fmt.Sprintf("Only some outputs were written: %s", err))
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, pyrrav1alpha1.ConditionDegraded)
+	}
+}
@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func TestServiceLevelObjectiveReconciler_Conditions(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+	objective.Generation = 3
+
+	var applyErr error
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective).
+		WithStatusSubresource(objective).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				if applyErr != nil {
+					return applyErr
+				}
+				return fakeApply(ctx, c, obj, patch, opts...)
+			},
+		}).
+		Build()
+
+	r := &ServiceLevelObjectiveReconciler{Client: c, Logger: kitlog.NewNopLogger()}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+
+	status := func() pyrrav1alpha1.ServiceLevelObjectiveStatus {
+		var o pyrrav1alpha1.ServiceLevelObjective
+		require.NoError(t, c.Get(context.Background(), req.NamespacedName, &o))
+		return o.Status
+	}
+
+	// Failed writes are retried and recorded.
+	applyErr = errors.New("admission webhook denied the request")
+	_, err := r.Reconcile(context.Background(), req)
+	require.Error(t, err)
+
+	s := status()
+	require.Equal(t, int64(3), s.ObservedGeneration)
+	written := meta.FindStatusCondition(s.Conditions, pyrrav1alpha1.ConditionRulesWritten)
+	require.NotNil(t, written)
+	require.Equal(t, metav1.ConditionFalse, written.Status)
+	require.Equal(t, reasonWriteFailed, written.Reason)
+	require.Contains(t, written.Message, "admission webhook denied the request")
+	require.True(t, meta.IsStatusConditionFalse(s.Conditions, pyrrav1alpha1.ConditionReady))
+	require.True(t, meta.IsStatusConditionFalse(s.Conditions, pyrrav1alpha1.ConditionValidationFailed))
+
+	applyErr = nil
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+
+	s = status()
+	require.Equal(t, "PrometheusRule", s.Type)
+	require.Equal(t, "http", s.RuleName)
+	require.True(t, meta.IsStatusConditionTrue(s.Conditions, pyrrav1alpha1.ConditionReady))
+	require.True(t, meta.IsStatusConditionTrue(s.Conditions, pyrrav1alpha1.ConditionRulesWritten))
+	require.True(t, meta.IsStatusConditionFalse(s.Conditions, pyrrav1alpha1.ConditionValidationFailed))
+	ready := meta.FindStatusCondition(s.Conditions, pyrrav1alpha1.ConditionReady)
+	require.Equal(t, "Rules written to PrometheusRule http.", ready.Message)
+	require.Equal(t, int64(3), ready.ObservedGeneration)
+
+	// Invalid objectives aren't retried until they change.
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+	objective.Spec.ServiceLevelIndicator.Ratio.Total.Metric = "http_requests_total{"
+	require.NoError(t, c.Update(context.Background(), objective))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+
+	s = status()
+	invalid := meta.FindStatusCondition(s.Conditions, pyrrav1alpha1.ConditionValidationFailed)
+	require.Equal(t, metav1.ConditionTrue, invalid.Status)
+	require.Equal(t, reasonInvalidSpec, invalid.Reason)
+	require.Contains(t, invalid.Message, "failed to get objective")
+	require.True(t, meta.IsStatusConditionFalse(s.Conditions, pyrrav1alpha1.ConditionReady))
+}
+
+func TestServiceLevelObjectiveReconciler_Events(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective).
+		WithStatusSubresource(objective).
+		Build()
+
+	recorder := record.NewFakeRecorder(10)
+	r := &ServiceLevelObjectiveReconciler{Client: c, Logger: kitlog.NewNopLogger(), Recorder: recorder}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+
+	events := func() []string {
+		var events []string
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+		return events
+	}
+
+	_, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Normal RulesCreated Created PrometheusRule http"}, events())
+
+	// Objectives whose rules are up to date don't emit events on every reconcile.
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Empty(t, events())
+
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+	objective.Spec.Target = "99.9"
+	require.NoError(t, c.Update(context.Background(), objective))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Normal RulesUpdated Updated PrometheusRule http"}, events())
+
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+	objective.Spec.ServiceLevelIndicator.Ratio.Total.Metric = "http_requests_total{"
+	require.NoError(t, c.Update(context.Background(), objective))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	invalid := events()
+	require.Len(t, invalid, 1)
+	require.Contains(t, invalid[0], "Warning InvalidSpec failed to get objective")
+
+	// Failures to push rule groups to the Loki ruler are warnings too.
+	unavailable := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			http.Error(w, "no rule groups found", http.StatusNotFound)
+			return
+		}
+		if unavailable {
+			http.Error(w, "ruler unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	r.LokiRuler = &LokiRuler{URL: u, Client: server.Client()}
+
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+	objective.Annotations = map[string]string{LokiRulerAnnotation: lokiRulerValue}
+	objective.Spec.ServiceLevelIndicator.Ratio.Total.Metric = `http_requests_total{job="app"}`
+	require.NoError(t, c.Update(context.Background(), objective))
+	_, err = r.Reconcile(context.Background(), req)
+	require.Error(t, err)
+	failed := events()
+	require.Len(t, failed, 1)
+	require.Contains(t, failed[0], "Warning PushFailed failed to update loki rule group http-increase")
+
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+	ready := meta.FindStatusCondition(objective.Status.Conditions, pyrrav1alpha1.ConditionReady)
+	require.Equal(t, reasonPushFailed, ready.Reason)
+	synced := meta.FindStatusCondition(objective.Status.Conditions, pyrrav1alpha1.ConditionRulerSynced)
+	require.Equal(t, metav1.ConditionFalse, synced.Status)
+	require.Equal(t, reasonPushFailed, synced.Reason)
+	require.Contains(t, synced.Message, "503 Service Unavailable")
+
+	unavailable = false
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	events()
+
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+	require.True(t, meta.IsStatusConditionTrue(objective.Status.Conditions, pyrrav1alpha1.ConditionReady))
+	synced = meta.FindStatusCondition(objective.Status.Conditions, pyrrav1alpha1.ConditionRulerSynced)
+	require.Equal(t, metav1.ConditionTrue, synced.Status)
+	require.Equal(t, reasonRulesPushed, synced.Reason)
+
+	// The condition is removed once the objective's rules aren't pushed to the ruler anymore.
+	objective.Annotations = nil
+	require.NoError(t, c.Update(context.Background(), objective))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+	require.Nil(t, meta.FindStatusCondition(objective.Status.Conditions, pyrrav1alpha1.ConditionRulerSynced))
+}
+
+func TestServiceLevelObjectiveReconciler_RuleNamesEvents(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective).
+		WithStatusSubresource(objective).
+		Build()
+
+	recorder := record.NewFakeRecorder(10)
+	r := &ServiceLevelObjectiveReconciler{Client: c, Logger: kitlog.NewNopLogger(), Recorder: recorder}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+
+	events := func() []string {
+		var events []string
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+		return events
+	}
+	update := func(f func(*pyrrav1alpha1.ServiceLevelObjective)) []string {
+		require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+		f(objective)
+		require.NoError(t, c.Update(context.Background(), objective))
+		_, err := r.Reconcile(context.Background(), req)
+		require.NoError(t, err)
+		return events()
+	}
+
+	_, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Normal RulesCreated Created PrometheusRule http"}, events())
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+	require.Equal(t, "28d", objective.Status.Window)
+	require.False(t, objective.Status.StableRuleNames)
+
+	require.Equal(t, []string{
+		"Normal RulesUpdated Updated PrometheusRule http",
+		"Warning RulesRenamed The window changed from 4w to 2w, renaming the recording rules from :increase4w to :increase2w, which start without history. Set spec.stableRuleNames to keep it across window changes.",
+	}, update(func(o *pyrrav1alpha1.ServiceLevelObjective) { o.Spec.Window = "2w" }))
+
+	// Windows are compared by their duration, not how they are written.
+	require.Empty(t, update(func(o *pyrrav1alpha1.ServiceLevelObjective) { o.Spec.Window = "14d" }))
+
+	require.Equal(t, []string{
+		"Normal RulesUpdated Updated PrometheusRule http",
+		"Normal RulesRenamed Recording rules are named without the window now, like :increase instead of :increase2w, and keep their history as the window changes. Their history until now is only in the series of the old names.",
+	}, update(func(o *pyrrav1alpha1.ServiceLevelObjective) { o.Spec.StableRuleNames = true }))
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+	require.True(t, objective.Status.StableRuleNames)
+
+	require.Equal(t, []string{
+		"Normal RulesUpdated Updated PrometheusRule http",
+		"Normal WindowChanged The window changed from 2w to 4w. The recording rules keep their names and history, which covers the 2w window until 4w passed.",
+	}, update(func(o *pyrrav1alpha1.ServiceLevelObjective) { o.Spec.Window = "4w" }))
+
+	require.Equal(t, []string{
+		"Normal RulesUpdated Updated PrometheusRule http",
+		"Normal RulesRenamed Recording rules are named with the window again, like :increase4w instead of :increase. Their history until now is only in the series of the old names.",
+	}, update(func(o *pyrrav1alpha1.ServiceLevelObjective) { o.Spec.StableRuleNames = false }))
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// ObjectiveLabel is set on the ConfigMaps with the rules of an objective to the objective's name.
+const ObjectiveLabel = "pyrra.dev/objective"
+
+// ConfigMapCollector periodically deletes the ConfigMaps with rules of objectives that no longer exist,
+// and removes their rules from sharded ConfigMaps.
+// They're usually garbage collected through their owner references,
+// but leak if objectives are deleted without cascading or renamed while the operator isn't running.
+type ConfigMapCollector struct {
+	client.Client
+	Logger   kitlog.Logger
+	Interval time.Duration
+	// ObjectiveSelector selects the objectives of this operator, whose ConfigMaps have the same labels.
+	// ConfigMaps of other objectives are left alone, as they aren't cached and look deleted. All are collected if it is nil.
+	ObjectiveSelector labels.Selector
+}
+
+var (
+	_ manager.Runnable               = &ConfigMapCollector{}
+	_ manager.LeaderElectionRunnable = &ConfigMapCollector{}
+)
+
+// NeedLeaderElection makes sure only one replica deletes ConfigMaps.
+func (c *ConfigMapCollector) NeedLeaderElection() bool {
+	return true
+}
+
+func (c *ConfigMapCollector) Start(ctx context.Context) error {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.collect(ctx); err != nil {
+			level.Warn(c.Logger).Log("msg", "failed to collect config maps", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// collect deletes the ConfigMaps labeled with an objective whose objective doesn't exist anymore.
+func (c *ConfigMapCollector) collect(ctx context.Context) error {
+	var list corev1.ConfigMapList
+	if err := c.List(ctx, &list, client.HasLabels{ObjectiveLabel}); err != nil {
+		return fmt.Errorf("failed to list config maps: %w", err)
+	}
+
+	for _, cm := range list.Items {
+		if c.ObjectiveSelector != nil && !c.ObjectiveSelector.Matches(labels.Set(cm.GetLabels())) {
+			continue
+		}
+
+		orphaned, err := c.orphaned(ctx, cm)
+		if err != nil {
+			return err
+		}
+		if !orphaned {
+			continue
+		}
+
+		level.Info(c.Logger).Log("msg", "deleting config map of deleted objective", "namespace", cm.GetNamespace(), "name", cm.GetName())
+		if err := c.Delete(ctx, &cm); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete config map: %w", err)
+		}
+	}
+	return c.collectShards(ctx)
+}
+
+// collectShards removes the rules of objectives that don't exist anymore from the sharded ConfigMaps.
+// Sharded ConfigMaps don't have the labels of objectives, so with an ObjectiveSelector
+// the objectives of other operators sharing them would look deleted and they're left alone.
+func (c *ConfigMapCollector) collectShards(ctx context.Context) error {
+	if c.ObjectiveSelector != nil {
+		return nil
+	}
+
+	var list corev1.ConfigMapList
+	if err := c.List(ctx, &list, client.HasLabels{ShardLabel}); err != nil {
+		return fmt.Errorf("failed to list config maps: %w", err)
+	}
+
+	for _, cm := range list.Items {
+		var deleted []string
+		for _, name := range shardObjectives(cm) {
+			var objective pyrrav1alpha1.ServiceLevelObjective
+			if err := c.Get(ctx, client.ObjectKey{Namespace: cm.GetNamespace(), Name: name}, &objective); err != nil {
+				if !errors.IsNotFound(err) {
+					return fmt.Errorf("failed to get objective: %w", err)
+				}
+				deleted = append(deleted, name)
+			}
+		}
+		if len(deleted) == 0 {
+			continue
+		}
+		if err := removeFromConfigMapShard(ctx, c.Client, c.Logger, &cm, deleted...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orphaned returns true if the objective of the ConfigMap doesn't exist.
+// The objective is the controller of the ConfigMap, or the one of its label if it has no owner,
+// as owner references are removed from ConfigMaps of objectives deleted without cascading.
+func (c *ConfigMapCollector) orphaned(ctx context.Context, cm corev1.ConfigMap) (bool, error) {
+	name := cm.GetLabels()[ObjectiveLabel]
+	owner := metav1.GetControllerOf(&cm)
+	if owner != nil {
+		name = owner.Name
+	}
+
+	var objective pyrrav1alpha1.ServiceLevelObjective
+	if err := c.Get(ctx, client.ObjectKey{Namespace: cm.GetNamespace(), Name: name}, &objective); err != nil {
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to get objective: %w", err)
+	}
+	// An objective with the same name was created after the owner was deleted.
+	return owner != nil && owner.UID != objective.GetUID(), nil
+}
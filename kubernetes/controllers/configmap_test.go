@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func TestConfigMapCollector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+
+	configMap := func(name, objective string, ownerUID string) *corev1.ConfigMap {
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "monitoring",
+			Labels:    map[string]string{ObjectiveLabel: objective},
+		}}
+		if ownerUID != "" {
+			isController := true
+			cm.OwnerReferences = []metav1.OwnerReference{{
+				APIVersion: "pyrra.dev/v1alpha1",
+				Kind:       "ServiceLevelObjective",
+				Name:       objective,
+				UID:        types.UID(ownerUID),
+				Controller: &isController,
+			}}
+		}
+		return cm
+	}
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			objective,
+			configMap("pyrra-recording-rule-http", "http", "123"),
+			// Owned by a previous objective of the same name.
+			configMap("pyrra-thanos-rule-http-0", "http", "456"),
+			// Left behind by an objective that was renamed.
+			configMap("pyrra-recording-rule-http-old", "http-old", "789"),
+			// Its owner reference was removed as the objective was deleted without cascading.
+			configMap("pyrra-recording-rule-orphan", "orphan", ""),
+			// ConfigMaps without the label aren't Pyrra's.
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "prometheus-config", Namespace: "monitoring"}},
+		).
+		Build()
+
+	collector := &ConfigMapCollector{Client: c, Logger: kitlog.NewNopLogger()}
+	require.NoError(t, collector.collect(context.Background()))
+
+	var list corev1.ConfigMapList
+	require.NoError(t, c.List(context.Background(), &list, client.InNamespace("monitoring")))
+	var names []string
+	for _, cm := range list.Items {
+		names = append(names, cm.GetName())
+	}
+	require.Equal(t, []string{"prometheus-config", "pyrra-recording-rule-http"}, names)
+}
+
+func TestConfigMapCollector_ObjectiveSelector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+
+	configMap := func(name, team string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "monitoring",
+			Labels:    map[string]string{ObjectiveLabel: name, "team": team},
+		}}
+	}
+
+	// The objectives of other teams aren't cached by the operator, so they look deleted.
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(configMap("payments", "payments"), configMap("search", "search")).
+		Build()
+
+	collector := &ConfigMapCollector{
+		Client:            c,
+		Logger:            kitlog.NewNopLogger(),
+		ObjectiveSelector: labels.SelectorFromSet(labels.Set{"team": "payments"}),
+	}
+	require.NoError(t, collector.collect(context.Background()))
+
+	var list corev1.ConfigMapList
+	require.NoError(t, c.List(context.Background(), &list, client.InNamespace("monitoring")))
+	require.Len(t, list.Items, 1)
+	require.Equal(t, "search", list.Items[0].GetName())
+}
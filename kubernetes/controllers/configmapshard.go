@@ -0,0 +1,265 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+const (
+	// ShardLabel is set on the sharded ConfigMaps to their shard, from 0 to the number of shards.
+	ShardLabel = "pyrra.dev/config-map-shard"
+	// ShardObjectivesAnnotation indexes the objectives whose rules are in a sharded ConfigMap,
+	// as their names sorted and separated by commas.
+	ShardObjectivesAnnotation = "pyrra.dev/objectives"
+)
+
+const (
+	shardKeyPrefix = "pyrra-recording-rule-"
+	shardKeySuffix = ".rules.yaml"
+)
+
+// configMapShard returns the shard of the objective among the shards, from the hash of its name,
+// so an objective stays in the same shard as long as their number doesn't change.
+func configMapShard(name string, shards int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// configMapShardName returns the name of the sharded ConfigMap of the objectives of the destination.
+// Objectives of different destinations don't share ConfigMaps, as they have the destination's labels.
+func configMapShardName(destination string, shard int) string {
+	if destination == "" {
+		return fmt.Sprintf("pyrra-recording-rules-%d", shard)
+	}
+	return fmt.Sprintf("pyrra-recording-rules-%s-%d", destination, shard)
+}
+
+// configMapShardKey returns the key of the objective's rule file in its sharded ConfigMap,
+// named like the one of its own ConfigMap.
+func configMapShardKey(name string) string {
+	return shardKeyPrefix + name + shardKeySuffix
+}
+
+// setShardObjectives sets the ShardObjectivesAnnotation of the sharded ConfigMap to the objectives of its rule files.
+func setShardObjectives(cm *corev1.ConfigMap) {
+	names := make([]string, 0, len(cm.Data))
+	for key := range cm.Data {
+		names = append(names, strings.TrimSuffix(strings.TrimPrefix(key, shardKeyPrefix), shardKeySuffix))
+	}
+	sort.Strings(names)
+
+	annotations := make(map[string]string, len(cm.GetAnnotations())+1)
+	for k, v := range cm.GetAnnotations() {
+		annotations[k] = v
+	}
+	annotations[ShardObjectivesAnnotation] = strings.Join(names, ",")
+	cm.SetAnnotations(annotations)
+}
+
+// shardObjectives returns the objectives of the sharded ConfigMap's index.
+func shardObjectives(cm corev1.ConfigMap) []string {
+	index := cm.GetAnnotations()[ShardObjectivesAnnotation]
+	if index == "" {
+		return nil
+	}
+	return strings.Split(index, ",")
+}
+
+// configMapSize returns the bytes of the ConfigMap's data that count towards the most a ConfigMap can hold.
+func configMapSize(data map[string]string) int {
+	var size int
+	for k, v := range data {
+		size += len(k) + len(v)
+	}
+	return size
+}
+
+// reconcileConfigMapShard writes the rules of the objective as a rule file to its sharded ConfigMap,
+// shared with the other objectives of the same shard in its namespace.
+// The sharded ConfigMaps are updated with optimistic concurrency, conflicts with other objectives are retried.
+func (r *ServiceLevelObjectiveReconciler) reconcileConfigMapShard(
+	ctx context.Context,
+	logger kitlog.Logger,
+	req ctrl.Request,
+	kubeObjective pyrrav1alpha1.ServiceLevelObjective,
+	destination Destination,
+	status *pyrrav1alpha1.ServiceLevelObjectiveStatus,
+) (ctrl.Result, error) {
+	shard := configMapShard(kubeObjective.GetName(), r.ConfigMapShards)
+	name := configMapShardName(kubeObjective.Spec.Destination, shard)
+
+	rules, err := generate(ctx, func() (string, error) {
+		groups, err := r.prometheusRuleGroups(ctx, kubeObjective)
+		if err != nil {
+			return "", err
+		}
+		var sb strings.Builder
+		if _, err := WriteRuleSpec(&sb, monitoringv1.PrometheusRuleSpec{Groups: groups}, 0); err != nil {
+			return "", fmt.Errorf("failed to marshal recording rule: %w", err)
+		}
+		return sb.String(), nil
+	})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var cm corev1.ConfigMap
+	created := false
+	if err := r.Get(ctx, client.ObjectKey{Namespace: req.Namespace, Name: name}, &cm); err != nil {
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("failed to get config map: %w", err)
+		}
+		created = true
+		cm = corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: req.Namespace,
+				Labels:    map[string]string{ShardLabel: strconv.Itoa(shard)},
+			},
+		}
+		destination.withLabels(&cm)
+	}
+
+	key := configMapShardKey(kubeObjective.GetName())
+	if existing, ok := cm.Data[key]; ok && existing == rules {
+		level.Debug(logger).Log("msg", "config map shard is up to date", "namespace", cm.GetNamespace(), "name", cm.GetName())
+	} else {
+		data := make(map[string]string, len(cm.Data)+1)
+		for k, v := range cm.Data {
+			data[k] = v
+		}
+		data[key] = rules
+		if size := configMapSize(data); size > maxConfigMapSize {
+			return ctrl.Result{}, fmt.Errorf("config map %s would be %d bytes with the rules of %s, more than the %d bytes a ConfigMap can hold, it needs more shards", name, size, kubeObjective.GetName(), maxConfigMapSize)
+		}
+		cm.Data = data
+		setShardObjectives(&cm)
+		setGeneratedAt(&cm)
+
+		level.Info(logger).Log("msg", "writing config map shard", "namespace", cm.GetNamespace(), "name", cm.GetName())
+		if created {
+			if err := r.Create(ctx, &cm); err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to create config map: %w", err)
+			}
+			r.event(&kubeObjective, corev1.EventTypeNormal, reasonRulesCreated, "Created ConfigMap %s", name)
+		} else {
+			if err := r.Update(ctx, &cm); err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to update config map: %w", err)
+			}
+			r.event(&kubeObjective, corev1.EventTypeNormal, reasonRulesUpdated, "Updated ConfigMap %s", name)
+		}
+	}
+
+	// The objective's rules were in another shard, like before the number of shards changed, or in its own ConfigMap.
+	if err := r.removeFromConfigMapShards(ctx, logger, req.NamespacedName, name); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.deleteObjectiveConfigMap(ctx, logger, &kubeObjective); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	status.Type = "ConfigMap"
+	status.RuleName = name
+
+	return ctrl.Result{}, nil
+}
+
+// deleteObjectiveConfigMap deletes the objective's own ConfigMap, whose rules are in a sharded ConfigMap now.
+func (r *ServiceLevelObjectiveReconciler) deleteObjectiveConfigMap(ctx context.Context, logger kitlog.Logger, kubeObjective *pyrrav1alpha1.ServiceLevelObjective) error {
+	var cm corev1.ConfigMap
+	key := client.ObjectKey{Namespace: kubeObjective.GetNamespace(), Name: fmt.Sprintf("pyrra-recording-rule-%s", kubeObjective.GetName())}
+	if err := r.Get(ctx, key, &cm); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get config map: %w", err)
+	}
+	if !metav1.IsControlledBy(&cm, kubeObjective) {
+		return nil
+	}
+
+	level.Info(logger).Log("msg", "deleting config map", "namespace", cm.GetNamespace(), "name", cm.GetName())
+	if err := r.Delete(ctx, &cm); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to delete config map: %w", err)
+	}
+	r.event(kubeObjective, corev1.EventTypeNormal, reasonRulesDeleted, "Deleted ConfigMap %s", cm.GetName())
+	return nil
+}
+
+// removeFromConfigMapShards removes the rules of the objective from the sharded ConfigMaps of its namespace, except the one to keep.
+func (r *ServiceLevelObjectiveReconciler) removeFromConfigMapShards(ctx context.Context, logger kitlog.Logger, objective types.NamespacedName, keep string) error {
+	var list corev1.ConfigMapList
+	if err := r.List(ctx, &list, client.InNamespace(objective.Namespace), client.HasLabels{ShardLabel}); err != nil {
+		return fmt.Errorf("failed to list config maps: %w", err)
+	}
+	for _, cm := range list.Items {
+		if _, ok := cm.Data[configMapShardKey(objective.Name)]; !ok || cm.GetName() == keep {
+			continue
+		}
+		if err := removeFromConfigMapShard(ctx, r.Client, logger, &cm, objective.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeFromConfigMapShard removes the rule files of the objectives from the sharded ConfigMap.
+// The ConfigMap is deleted once it has no rule files left.
+func removeFromConfigMapShard(ctx context.Context, c client.Client, logger kitlog.Logger, cm *corev1.ConfigMap, names ...string) error {
+	data := make(map[string]string, len(cm.Data))
+	for k, v := range cm.Data {
+		data[k] = v
+	}
+	for _, name := range names {
+		delete(data, configMapShardKey(name))
+	}
+
+	if len(data) == 0 {
+		level.Info(logger).Log("msg", "deleting config map shard", "namespace", cm.GetNamespace(), "name", cm.GetName())
+		if err := c.Delete(ctx, cm); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete config map: %w", err)
+		}
+		return nil
+	}
+
+	level.Info(logger).Log("msg", "removing objectives from config map shard", "namespace", cm.GetNamespace(), "name", cm.GetName(), "objectives", strings.Join(names, ","))
+	cm.Data = data
+	setShardObjectives(cm)
+	if err := c.Update(ctx, cm); err != nil {
+		return fmt.Errorf("failed to update config map: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func TestConfigMapShard(t *testing.T) {
+	counts := make([]int, 4)
+	for i := 0; i < 1000; i++ {
+		name := fmt.Sprintf("objective-%d", i)
+		shard := configMapShard(name, 4)
+		require.Equal(t, shard, configMapShard(name, 4))
+		counts[shard]++
+	}
+	// The objectives are spread across all shards.
+	for _, count := range counts {
+		require.Greater(t, count, 150)
+	}
+
+	require.Equal(t, "pyrra-recording-rules-3", configMapShardName("", 3))
+	require.Equal(t, "pyrra-recording-rules-staging-3", configMapShardName("staging", 3))
+}
+
+func TestServiceLevelObjectiveReconciler_ConfigMapShards(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	objective := func(name string) *pyrrav1alpha1.ServiceLevelObjective {
+		o := httpSLO.DeepCopy()
+		o.TypeMeta = metav1.TypeMeta{}
+		o.Namespace = "monitoring"
+		o.Name = name
+		o.UID = types.UID(name)
+		return o
+	}
+	http, grpc := objective("http"), objective("grpc")
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(http, grpc).
+		WithStatusSubresource(http, grpc).
+		Build()
+
+	r := &ServiceLevelObjectiveReconciler{Client: c, Logger: kitlog.NewNopLogger(), ConfigMapMode: true}
+	reconcileObjective := func(name string) {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "monitoring", Name: name}})
+		require.NoError(t, err)
+	}
+	configMaps := func() map[string]corev1.ConfigMap {
+		var list corev1.ConfigMapList
+		require.NoError(t, c.List(context.Background(), &list, client.InNamespace("monitoring")))
+		cms := map[string]corev1.ConfigMap{}
+		for _, cm := range list.Items {
+			cms[cm.GetName()] = cm
+		}
+		return cms
+	}
+
+	reconcileObjective("http")
+	require.Contains(t, configMaps(), "pyrra-recording-rule-http")
+
+	// With a single shard, the rules of all objectives are merged into the same ConfigMap.
+	r.ConfigMapShards = 1
+	reconcileObjective("http")
+	reconcileObjective("grpc")
+
+	cms := configMaps()
+	require.Len(t, cms, 1)
+	shard := cms["pyrra-recording-rules-0"]
+	require.Equal(t, "0", shard.GetLabels()[ShardLabel])
+	require.Equal(t, "grpc,http", shard.GetAnnotations()[ShardObjectivesAnnotation])
+	require.Len(t, shard.Data, 2)
+	require.True(t, strings.HasPrefix(shard.Data["pyrra-recording-rule-http.rules.yaml"], "groups:\n"))
+	require.Contains(t, shard.Data, "pyrra-recording-rule-grpc.rules.yaml")
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(http), http))
+	require.Equal(t, "pyrra-recording-rules-0", http.Status.RuleName)
+
+	// Unchanged objectives don't update their shard.
+	reconcileObjective("http")
+	require.Equal(t, shard.GetResourceVersion(), configMaps()["pyrra-recording-rules-0"].ResourceVersion)
+
+	// The rules of deleted objectives are removed and empty shards are deleted.
+	require.NoError(t, c.Delete(context.Background(), http))
+	reconcileObjective("http")
+	shard = configMaps()["pyrra-recording-rules-0"]
+	require.Equal(t, "grpc", shard.GetAnnotations()[ShardObjectivesAnnotation])
+	require.Len(t, shard.Data, 1)
+
+	// Without shards the objective's rules are moved back to its own ConfigMap.
+	r.ConfigMapShards = 0
+	reconcileObjective("grpc")
+	cms = configMaps()
+	require.Len(t, cms, 1)
+	require.Contains(t, cms, "pyrra-recording-rule-grpc")
+}
+
+func TestConfigMapCollector_Shards(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+
+	shard := func(name string, objectives ...string) *corev1.ConfigMap {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "monitoring",
+				Labels:    map[string]string{ShardLabel: "0"},
+			},
+			Data: map[string]string{},
+		}
+		for _, o := range objectives {
+			cm.Data[configMapShardKey(o)] = "groups: []\n"
+		}
+		setShardObjectives(cm)
+		return cm
+	}
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			objective,
+			shard("pyrra-recording-rules-0", "http", "deleted"),
+			shard("pyrra-recording-rules-1", "deleted-too"),
+		).
+		Build()
+
+	collector := &ConfigMapCollector{Client: c, Logger: kitlog.NewNopLogger()}
+	require.NoError(t, collector.collect(context.Background()))
+
+	var cm corev1.ConfigMap
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "monitoring", Name: "pyrra-recording-rules-0"}, &cm))
+	require.Equal(t, []string{"http"}, shardObjectives(cm))
+	require.Equal(t, map[string]string{"pyrra-recording-rule-http.rules.yaml": "groups: []\n"}, cm.Data)
+
+	err := c.Get(context.Background(), client.ObjectKey{Namespace: "monitoring", Name: "pyrra-recording-rules-1"}, &cm)
+	require.True(t, errors.IsNotFound(err), err)
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// debounceHandler enqueues objectives like handler.EnqueueRequestForObject,
+// but delays their reconciles so that bursts of updates to an objective are coalesced into one reconcile.
+// Periodic resyncs, which don't change the objective, are spread out over the resync delay,
+// so that objectives that actually changed are reconciled first.
+// The queue keeps the earliest time an objective is added for, so a change always overtakes a pending resync.
+type debounceHandler struct {
+	delay       time.Duration
+	resyncDelay time.Duration
+}
+
+var _ handler.EventHandler = debounceHandler{}
+
+func (h debounceHandler) Create(_ context.Context, e event.CreateEvent, q workqueue.RateLimitingInterface) {
+	h.add(q, e.Object, h.delay)
+}
+
+func (h debounceHandler) Update(_ context.Context, e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	if e.ObjectOld.GetResourceVersion() == e.ObjectNew.GetResourceVersion() {
+		var delay time.Duration
+		if h.resyncDelay > 0 {
+			delay = time.Duration(rand.Int63n(int64(h.resyncDelay)))
+		}
+		h.add(q, e.ObjectNew, max(delay, h.delay))
+		return
+	}
+	h.add(q, e.ObjectNew, h.delay)
+}
+
+func (h debounceHandler) Delete(_ context.Context, e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	// Deletes aren't followed by further updates, there's nothing to coalesce.
+	h.add(q, e.Object, 0)
+}
+
+func (h debounceHandler) Generic(_ context.Context, e event.GenericEvent, q workqueue.RateLimitingInterface) {
+	h.add(q, e.Object, h.delay)
+}
+
+func (h debounceHandler) add(q workqueue.RateLimitingInterface, obj client.Object, delay time.Duration) {
+	if obj == nil {
+		return
+	}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	}}
+	if delay <= 0 {
+		q.Add(req)
+		return
+	}
+	q.AddAfter(req, delay)
+}
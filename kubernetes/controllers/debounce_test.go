@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+type recordingQueue struct {
+	workqueue.RateLimitingInterface
+	added []time.Duration
+}
+
+func (q *recordingQueue) Add(item interface{}) {
+	q.added = append(q.added, 0)
+	q.RateLimitingInterface.Add(item)
+}
+
+func (q *recordingQueue) AddAfter(item interface{}, d time.Duration) {
+	q.added = append(q.added, d)
+	q.RateLimitingInterface.AddAfter(item, d)
+}
+
+func TestDebounceHandler(t *testing.T) {
+	objective := func(resourceVersion string) *pyrrav1alpha1.ServiceLevelObjective {
+		return &pyrrav1alpha1.ServiceLevelObjective{ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "monitoring",
+			Name:            "http",
+			ResourceVersion: resourceVersion,
+		}}
+	}
+
+	ctx := context.Background()
+	q := &recordingQueue{RateLimitingInterface: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())}
+	defer q.ShutDown()
+
+	h := debounceHandler{delay: 50 * time.Millisecond, resyncDelay: time.Hour}
+
+	// A burst of updates is coalesced into one reconcile.
+	h.Create(ctx, event.CreateEvent{Object: objective("1")}, q)
+	h.Update(ctx, event.UpdateEvent{ObjectOld: objective("1"), ObjectNew: objective("2")}, q)
+	h.Update(ctx, event.UpdateEvent{ObjectOld: objective("2"), ObjectNew: objective("3")}, q)
+	require.Equal(t, []time.Duration{50 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond}, q.added)
+	require.Equal(t, 0, q.Len())
+
+	require.Eventually(t, func() bool { return q.Len() == 1 }, time.Second, 5*time.Millisecond)
+	item, _ := q.Get()
+	require.Equal(t, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "monitoring", Name: "http"}}, item)
+	q.Done(item)
+
+	// Resyncs are spread out over the resync delay.
+	q.added = nil
+	h.Update(ctx, event.UpdateEvent{ObjectOld: objective("3"), ObjectNew: objective("3")}, q)
+	require.Len(t, q.added, 1)
+	require.GreaterOrEqual(t, q.added[0], h.delay)
+	require.Less(t, q.added[0], time.Hour)
+
+	// Deletes are reconciled right away.
+	q.added = nil
+	h.Delete(ctx, event.DeleteEvent{Object: objective("3")}, q)
+	require.Equal(t, []time.Duration{0}, q.added)
+	require.Equal(t, 1, q.Len())
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// Destination is a ruler the rules of objectives are written to, selected by the name in their spec.destination.
+// One operator can write the rules of objectives to several rulers, like the ones of a production and a staging environment.
+type Destination struct {
+	// Labels are added to the PrometheusRules and ConfigMaps of the objectives,
+	// for the ruleSelector of the destination's Prometheus, or the sidecar of its ruler, to select them.
+	Labels map[string]string
+	// LokiRuler receives the rule groups of the destination's objectives evaluated by Loki,
+	// instead of the reconciler's LokiRuler.
+	LokiRuler *LokiRuler
+}
+
+// destination returns the destination of the objective's rules.
+// Objectives without a destination are written like without any destinations configured.
+func (r *ServiceLevelObjectiveReconciler) destination(ctx context.Context, kubeObjective pyrrav1alpha1.ServiceLevelObjective) (Destination, error) {
+	lokiRuler, err := r.defaultLokiRuler(ctx)
+	if err != nil {
+		return Destination{}, err
+	}
+
+	name := kubeObjective.Spec.Destination
+	if name == "" {
+		return Destination{LokiRuler: lokiRuler}, nil
+	}
+
+	destination, ok := r.Destinations[name]
+	if !ok {
+		names := make([]string, 0, len(r.Destinations))
+		for n := range r.Destinations {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Destination{}, invalidObjectiveError{err: fmt.Errorf("unknown destination %q, the operator's destinations are [%s]", name, strings.Join(names, ", "))}
+	}
+	if destination.LokiRuler == nil {
+		destination.LokiRuler = lokiRuler
+	}
+	return destination, nil
+}
+
+// lokiEnabled returns true if rule groups are pushed to any Loki ruler, even one whose config wasn't read yet.
+func (r *ServiceLevelObjectiveReconciler) lokiEnabled() bool {
+	return r.LokiRulerConfig != nil || len(r.lokiRulers()) > 0
+}
+
+// lokiRulers returns all Loki rulers rule groups are pushed to, the reconciler's and the destinations' ones.
+// The reconciler's is the one of the LokiRulerConfig last read, if there is one.
+func (r *ServiceLevelObjectiveReconciler) lokiRulers() []*LokiRuler {
+	var rulers []*LokiRuler
+	if r.LokiRulerConfig != nil && r.LokiRulerConfig.current() != nil {
+		rulers = append(rulers, r.LokiRulerConfig.current())
+	} else if r.LokiRuler != nil {
+		rulers = append(rulers, r.LokiRuler)
+	}
+	names := make([]string, 0, len(r.Destinations))
+	for name := range r.Destinations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if ruler := r.Destinations[name].LokiRuler; ruler != nil {
+			rulers = append(rulers, ruler)
+		}
+	}
+	return rulers
+}
+
+// withLabels adds the destination's labels to the object's, which it copies so the object doesn't share them with the objective.
+func (d Destination) withLabels(obj client.Object) {
+	if len(d.Labels) == 0 {
+		return
+	}
+	labels := make(map[string]string, len(obj.GetLabels())+len(d.Labels))
+	for k, v := range obj.GetLabels() {
+		labels[k] = v
+	}
+	for k, v := range d.Labels {
+		labels[k] = v
+	}
+	obj.SetLabels(labels)
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+func TestServiceLevelObjectiveReconciler_Destinations(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	prodRuler := &fakeLokiRuler{namespaces: map[string][]monitoringv1.RuleGroup{}}
+	prodServer := httptest.NewServer(prodRuler)
+	defer prodServer.Close()
+	prodURL, err := url.Parse(prodServer.URL)
+	require.NoError(t, err)
+
+	stagingRuler := &fakeLokiRuler{namespaces: map[string][]monitoringv1.RuleGroup{}}
+	stagingServer := httptest.NewServer(stagingRuler)
+	defer stagingServer.Close()
+	stagingURL, err := url.Parse(stagingServer.URL)
+	require.NoError(t, err)
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+	objective.Spec.Destination = "staging"
+
+	logs := httpSLO.DeepCopy()
+	logs.TypeMeta = metav1.TypeMeta{}
+	logs.Namespace = "monitoring"
+	logs.Name = "logs"
+	logs.Annotations = map[string]string{LokiRulerAnnotation: lokiRulerValue}
+	logs.Spec.Destination = "staging"
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective, logs).
+		WithStatusSubresource(objective, logs).
+		Build()
+
+	r := &ServiceLevelObjectiveReconciler{
+		Client:    c,
+		Logger:    kitlog.NewNopLogger(),
+		LokiRuler: &LokiRuler{URL: prodURL},
+		Destinations: map[string]Destination{
+			"staging": {Labels: map[string]string{"prometheus": "staging"}, LokiRuler: &LokiRuler{URL: stagingURL}},
+			"prod":    {Labels: map[string]string{"prometheus": "prod"}},
+		},
+	}
+	reconcile := func(obj client.Object) {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+		require.NoError(t, err)
+	}
+
+	// The PrometheusRule gets the destination's labels for its Prometheus to select it.
+	reconcile(objective)
+	var rule monitoringv1.PrometheusRule
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(objective), &rule))
+	require.Equal(t, "staging", rule.GetLabels()["prometheus"])
+	require.Equal(t, "bar", rule.GetLabels()["team"])
+	// The objective's labels aren't changed.
+	require.NotContains(t, httpSLO.GetLabels(), "prometheus")
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(objective), objective))
+	objective.Spec.Destination = "prod"
+	require.NoError(t, c.Update(context.Background(), objective))
+	reconcile(objective)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(objective), &rule))
+	require.Equal(t, "prod", rule.GetLabels()["prometheus"])
+
+	// Objectives of destinations the operator doesn't have are invalid.
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(objective), objective))
+	objective.Spec.Destination = "dev"
+	require.NoError(t, c.Update(context.Background(), objective))
+	reconcile(objective)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(objective), objective))
+	invalid := meta.FindStatusCondition(objective.Status.Conditions, pyrrav1alpha1.ConditionValidationFailed)
+	require.Equal(t, metav1.ConditionTrue, invalid.Status)
+	require.Equal(t, `unknown destination "dev", the operator's destinations are [prod, staging]`, invalid.Message)
+
+	// Objectives evaluated by Loki are pushed to the destination's ruler.
+	reconcile(logs)
+	require.Empty(t, prodRuler.reset())
+	require.Equal(t, []string{"logs-increase", "logs"}, stagingRuler.groupNames("monitoring"))
+	stagingRuler.reset()
+
+	// The destination of deleted objectives is unknown, their rule groups are deleted from all rulers.
+	require.NoError(t, c.Delete(context.Background(), logs))
+	reconcile(logs)
+	require.Len(t, prodRuler.reset(), len(slo.RuleGroupSuffixes))
+	require.Len(t, stagingRuler.reset(), len(slo.RuleGroupSuffixes))
+	require.Empty(t, stagingRuler.groupNames("monitoring"))
+}
@@ -0,0 +1,426 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// grafanaAlertRuleGroupGVK is the grafana-operator's resource for Grafana-managed alert rules.
+// The grafana-operator isn't a dependency, so its resources are handled as unstructured objects.
+var grafanaAlertRuleGroupGVK = schema.GroupVersionKind{
+	Group:   "grafana.integreatly.org",
+	Version: "v1beta1",
+	Kind:    "GrafanaAlertRuleGroup",
+}
+
+// grafanaDatasourceGVK is the grafana-operator's resource for datasources,
+// listed to warn about alert rules querying datasources that don't exist.
+var grafanaDatasourceGVK = schema.GroupVersionKind{
+	Group:   "grafana.integreatly.org",
+	Version: "v1beta1",
+	Kind:    "GrafanaDatasourceList",
+}
+
+// GrafanaDatasourceUIDAnnotation sets the UID of the datasource in Grafana the alerts of the annotated objective query,
+// instead of the GrafanaAlertRules' DatasourceUID.
+const GrafanaDatasourceUIDAnnotation = "pyrra.dev/grafana-datasource-uid"
+
+const (
+	// grafanaExpressionDatasourceUID is the datasource of Grafana's server-side expressions, like reduce and threshold.
+	grafanaExpressionDatasourceUID = "__expr__"
+	// grafanaDefaultInterval is used for rule groups without an interval.
+	grafanaDefaultInterval = "1m"
+)
+
+// GrafanaAlertRules configures the GrafanaAlertRuleGroups the alerts of objectives are written to.
+// Grafana then evaluates the alerts against the recording rules in Prometheus,
+// so the alerts are removed from the PrometheusRules and ConfigMaps to not alert twice.
+type GrafanaAlertRules struct {
+	// DatasourceUID is the UID of the Prometheus datasource in Grafana the alerts query,
+	// unless objectives have the pyrra.dev/grafana-datasource-uid annotation.
+	DatasourceUID string
+	// DatasourceType is the type of the datasource in Grafana the alerts query, prometheus if it's empty.
+	DatasourceType string
+	// FolderRef is the name of the GrafanaFolder in the objective's namespace the rules are created in.
+	FolderRef string
+	// InstanceSelector selects the Grafana instances, by the labels of their Grafana resources, to create the rules in.
+	InstanceSelector map[string]string
+}
+
+// +kubebuilder:rbac:groups=grafana.integreatly.org,resources=grafanaalertrulegroups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=grafana.integreatly.org,resources=grafanadatasources,verbs=get;list;watch
+
+// grafanaAlertRuleGroupSpec is the subset of the GrafanaAlertRuleGroup spec Pyrra sets.
+type grafanaAlertRuleGroupSpec struct {
+	FolderRef        string               `json:"folderRef"`
+	InstanceSelector metav1.LabelSelector `json:"instanceSelector"`
+	Interval         string               `json:"interval"`
+	Rules            []grafanaAlertRule   `json:"rules"`
+}
+
+type grafanaAlertRule struct {
+	UID       string              `json:"uid"`
+	Title     string              `json:"title"`
+	Condition string              `json:"condition"`
+	Data      []grafanaAlertQuery `json:"data"`
+	For       string              `json:"for,omitempty"`
+	// KeepFiringFor needs Grafana 11 and a grafana-operator supporting it, it's omitted otherwise.
+	KeepFiringFor string            `json:"keepFiringFor,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+	NoDataState   string            `json:"noDataState"`
+	ExecErrState  string            `json:"execErrState"`
+}
+
+type grafanaAlertQuery struct {
+	RefID             string                    `json:"refId"`
+	DatasourceUID     string                    `json:"datasourceUid"`
+	RelativeTimeRange *grafanaRelativeTimeRange `json:"relativeTimeRange,omitempty"`
+	Model             map[string]interface{}    `json:"model"`
+}
+
+type grafanaRelativeTimeRange struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// makeGrafanaAlertRuleGroup returns the GrafanaAlertRuleGroup with the alerts of the objective's rule groups,
+// or nil if the objective has no alerts.
+func makeGrafanaAlertRuleGroup(
+	kubeObjective pyrrav1alpha1.ServiceLevelObjective,
+	groups []monitoringv1.RuleGroup,
+	config GrafanaAlertRules,
+) (*unstructured.Unstructured, error) {
+	spec := grafanaAlertRuleGroupSpec{
+		FolderRef:        config.FolderRef,
+		InstanceSelector: metav1.LabelSelector{MatchLabels: config.InstanceSelector},
+		Interval:         grafanaDefaultInterval,
+	}
+	for _, group := range groups {
+		rules := prometheusRuleGroupToGrafanaAlertRules(kubeObjective, group, config.datasource(kubeObjective))
+		if len(rules) == 0 {
+			continue
+		}
+		// The burn rate alerts are evaluated as often as their group in Prometheus would be.
+		if group.Interval != nil && *group.Interval != "" {
+			spec.Interval = string(*group.Interval)
+		}
+		spec.Rules = append(spec.Rules, rules...)
+	}
+	if len(spec.Rules) == 0 {
+		return nil, nil
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert grafana alert rule group: %w", err)
+	}
+
+	isController := true
+	group := &unstructured.Unstructured{}
+	group.SetGroupVersionKind(grafanaAlertRuleGroupGVK)
+	group.SetName(kubeObjective.GetName())
+	group.SetNamespace(kubeObjective.GetNamespace())
+	group.SetLabels(kubeObjective.GetLabels())
+	group.SetOwnerReferences([]metav1.OwnerReference{{
+		APIVersion: pyrrav1alpha1.GroupVersion.String(),
+		Kind:       "ServiceLevelObjective",
+		Name:       kubeObjective.GetName(),
+		UID:        kubeObjective.GetUID(),
+		Controller: &isController,
+	}})
+	group.Object["spec"] = content
+	return group, nil
+}
+
+// grafanaDatasource is the datasource the alert rules query.
+type grafanaDatasource struct {
+	Type string
+	UID  string
+}
+
+// datasource returns the datasource the objective's alert rules query.
+func (c GrafanaAlertRules) datasource(kubeObjective pyrrav1alpha1.ServiceLevelObjective) grafanaDatasource {
+	datasource := grafanaDatasource{Type: c.DatasourceType, UID: c.DatasourceUID}
+	if datasource.Type == "" {
+		datasource.Type = "prometheus"
+	}
+	if uid := kubeObjective.GetAnnotations()[GrafanaDatasourceUIDAnnotation]; uid != "" {
+		datasource.UID = uid
+	}
+	return datasource
+}
+
+// prometheusRuleGroupToGrafanaAlertRules converts the alerting rules of the group to Grafana alert rules.
+// The PromQL expression of an alert only returns series while it's firing, like the burn rates above their threshold,
+// so the last value of each series is compared to 0 as the condition.
+func prometheusRuleGroupToGrafanaAlertRules(
+	kubeObjective pyrrav1alpha1.ServiceLevelObjective,
+	group monitoringv1.RuleGroup,
+	datasource grafanaDatasource,
+) []grafanaAlertRule {
+	var rules []grafanaAlertRule
+	for _, rule := range group.Rules {
+		if rule.Alert == "" {
+			continue
+		}
+
+		title := []string{rule.Alert, kubeObjective.GetName()}
+		if rule.Labels["short"] != "" && rule.Labels["long"] != "" {
+			title = append(title, rule.Labels["short"]+"/"+rule.Labels["long"])
+		}
+
+		var forDuration string
+		if rule.For != nil {
+			forDuration = string(*rule.For)
+		}
+		var keepFiringFor string
+		if rule.KeepFiringFor != nil {
+			keepFiringFor = string(*rule.KeepFiringFor)
+		}
+
+		rules = append(rules, grafanaAlertRule{
+			UID:       grafanaAlertRuleUID(kubeObjective, strings.Join(title, " ")),
+			Title:     strings.Join(title, " "),
+			Condition: "C",
+			Data: []grafanaAlertQuery{{
+				RefID:         "A",
+				DatasourceUID: datasource.UID,
+				// The recording rules already aggregate the burn rates over their windows.
+				RelativeTimeRange: &grafanaRelativeTimeRange{From: 600, To: 0},
+				Model: map[string]interface{}{
+					"refId":      "A",
+					"expr":       rule.Expr.String(),
+					"instant":    true,
+					"datasource": map[string]interface{}{"type": datasource.Type, "uid": datasource.UID},
+				},
+			}, {
+				RefID:         "B",
+				DatasourceUID: grafanaExpressionDatasourceUID,
+				Model: map[string]interface{}{
+					"refId":      "B",
+					"type":       "reduce",
+					"expression": "A",
+					"reducer":    "last",
+					"settings":   map[string]interface{}{"mode": "dropNN"},
+				},
+			}, {
+				RefID:         "C",
+				DatasourceUID: grafanaExpressionDatasourceUID,
+				Model: map[string]interface{}{
+					"refId":      "C",
+					"type":       "threshold",
+					"expression": "B",
+					"conditions": []interface{}{map[string]interface{}{
+						"evaluator": map[string]interface{}{"type": "gt", "params": []interface{}{int64(0)}},
+					}},
+				},
+			}},
+			For:           forDuration,
+			KeepFiringFor: keepFiringFor,
+			Labels:        rule.Labels,
+			Annotations:   rule.Annotations,
+			// Like in Prometheus, alerts don't fire without data.
+			NoDataState:  "OK",
+			ExecErrState: "Error",
+		})
+	}
+	return rules
+}
+
+// grafanaAlertRuleUID returns a stable UID for the alert of the objective, within Grafana's limit of 40 characters.
+func grafanaAlertRuleUID(kubeObjective pyrrav1alpha1.ServiceLevelObjective, title string) string {
+	sum := sha1.Sum([]byte(kubeObjective.GetNamespace() + "/" + kubeObjective.GetName() + "/" + title))
+	return hex.EncodeToString(sum[:])
+}
+
+// withoutAlerts returns the rule groups with only their recording rules.
+func withoutAlerts(groups []monitoringv1.RuleGroup) []monitoringv1.RuleGroup {
+	filtered := make([]monitoringv1.RuleGroup, 0, len(groups))
+	for _, group := range groups {
+		rules := make([]monitoringv1.Rule, 0, len(group.Rules))
+		for _, rule := range group.Rules {
+			if rule.Alert == "" {
+				rules = append(rules, rule)
+			}
+		}
+		group.Rules = rules
+		filtered = append(filtered, group)
+	}
+	return filtered
+}
+
+func (r *ServiceLevelObjectiveReconciler) reconcileGrafanaAlertRuleGroup(
+	ctx context.Context,
+	logger kitlog.Logger,
+	kubeObjective pyrrav1alpha1.ServiceLevelObjective,
+) (err error) {
+	ctx, end := startSpan(ctx, "write GrafanaAlertRuleGroup", &err)
+	defer end()
+
+	groups, err := r.ruleGroups(ctx, kubeObjective)
+	if err != nil {
+		return err
+	}
+	newGroup, err := makeGrafanaAlertRuleGroup(kubeObjective, groups, *r.GrafanaAlertRules)
+	if err != nil {
+		return err
+	}
+
+	if newGroup == nil {
+		// The objective's alerts were disabled.
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(grafanaAlertRuleGroupGVK)
+		existing.SetNamespace(kubeObjective.GetNamespace())
+		existing.SetName(kubeObjective.GetName())
+		if err := r.Delete(ctx, existing); err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to delete grafana alert rule group: %w", err)
+		}
+		level.Info(logger).Log("msg", "deleted grafana alert rule group", "namespace", existing.GetNamespace(), "name", existing.GetName())
+		r.event(&kubeObjective, corev1.EventTypeNormal, reasonRulesDeleted, "Deleted GrafanaAlertRuleGroup %s", existing.GetName())
+		return nil
+	}
+
+	r.verifyGrafanaDatasource(ctx, logger, &kubeObjective, r.GrafanaAlertRules.datasource(kubeObjective).UID)
+
+	folder, err := r.grafanaFolder(ctx, logger, kubeObjective, r.GrafanaAlertRules.FolderRef)
+	if err != nil {
+		return err
+	}
+	if err := unstructured.SetNestedField(newGroup.Object, folder, "spec", "folderRef"); err != nil {
+		return fmt.Errorf("failed to set folder of grafana alert rule group: %w", err)
+	}
+
+	result, err := r.writeGrafanaResource(ctx, logger, newGroup)
+	switch result {
+	case controllerutil.OperationResultCreated:
+		r.event(&kubeObjective, corev1.EventTypeNormal, reasonRulesCreated, "Created GrafanaAlertRuleGroup %s", newGroup.GetName())
+	case controllerutil.OperationResultUpdated:
+		r.event(&kubeObjective, corev1.EventTypeNormal, reasonRulesUpdated, "Updated GrafanaAlertRuleGroup %s", newGroup.GetName())
+	}
+	return err
+}
+
+// verifyGrafanaDatasource warns with an event if the objective's namespace has GrafanaDatasources but none with the UID.
+// Datasources configured in Grafana directly aren't known, so it's only verified if there are GrafanaDatasources.
+func (r *ServiceLevelObjectiveReconciler) verifyGrafanaDatasource(
+	ctx context.Context,
+	logger kitlog.Logger,
+	kubeObjective *pyrrav1alpha1.ServiceLevelObjective,
+	uid string,
+) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(grafanaDatasourceGVK)
+	if err := r.List(ctx, list, client.InNamespace(kubeObjective.GetNamespace())); err != nil {
+		level.Debug(logger).Log("msg", "failed to list grafana datasources", "err", err)
+		return
+	}
+	if len(list.Items) == 0 {
+		return
+	}
+	for _, item := range list.Items {
+		// The UID is part of the datasource in Grafana and, with newer grafana-operators, set on the resource itself.
+		datasourceUID, _, _ := unstructured.NestedString(item.Object, "spec", "datasource", "uid")
+		resourceUID, _, _ := unstructured.NestedString(item.Object, "spec", "uid")
+		if datasourceUID == uid || resourceUID == uid {
+			return
+		}
+	}
+	level.Warn(logger).Log("msg", "grafana datasource not found", "uid", uid)
+	r.event(kubeObjective, corev1.EventTypeWarning, reasonUnknownDatasource, "None of the GrafanaDatasources in namespace %s has the UID %s the alert rules query", kubeObjective.GetNamespace(), uid)
+}
+
+// writeGrafanaResource creates or updates the grafana-operator resource,
+// unless the existing one has the same spec, labels and owner.
+func (r *ServiceLevelObjectiveReconciler) writeGrafanaResource(
+	ctx context.Context,
+	logger kitlog.Logger,
+	newObj *unstructured.Unstructured,
+) (controllerutil.OperationResult, error) {
+	kind := newObj.GetKind()
+	logger = kitlog.With(logger, "kind", kind, "namespace", newObj.GetNamespace(), "name", newObj.GetName())
+
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(newObj.GroupVersionKind())
+	if err := r.Get(ctx, client.ObjectKeyFromObject(newObj), existing); err != nil {
+		if !errors.IsNotFound(err) {
+			return controllerutil.OperationResultNone, fmt.Errorf("failed to get %s: %w", kind, err)
+		}
+		level.Info(logger).Log("msg", "creating grafana resource")
+		if err := r.Create(ctx, newObj); err != nil {
+			return controllerutil.OperationResultNone, fmt.Errorf("failed to create %s: %w", kind, err)
+		}
+		return controllerutil.OperationResultCreated, nil
+	}
+
+	if jsonEqual(existing.Object["spec"], newObj.Object["spec"]) &&
+		equality.Semantic.DeepEqual(existing.GetLabels(), newObj.GetLabels()) &&
+		equality.Semantic.DeepEqual(existing.GetOwnerReferences(), newObj.GetOwnerReferences()) {
+		level.Debug(logger).Log("msg", "grafana resource is up to date")
+		return controllerutil.OperationResultNone, nil
+	}
+
+	newObj.SetResourceVersion(existing.GetResourceVersion())
+
+	level.Info(logger).Log("msg", "updating grafana resource")
+	if err := r.Update(ctx, newObj); err != nil {
+		return controllerutil.OperationResultNone, fmt.Errorf("failed to update %s: %w", kind, err)
+	}
+	return controllerutil.OperationResultUpdated, nil
+}
+
+// jsonEqual compares the values as JSON, as numbers of unstructured objects read from the API
+// are int64 or float64 depending on how they're written.
+func jsonEqual(a, b interface{}) bool {
+	aj, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bj, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	var av, bv interface{}
+	if json.Unmarshal(aj, &av) != nil || json.Unmarshal(bj, &bv) != nil {
+		return false
+	}
+	return equality.Semantic.DeepEqual(av, bv)
+}
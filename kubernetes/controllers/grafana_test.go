@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+var grafanaConfig = GrafanaAlertRules{
+	DatasourceUID:    "prometheus",
+	FolderRef:        "slos",
+	InstanceSelector: map[string]string{"dashboards": "grafana"},
+}
+
+func TestMakeGrafanaAlertRuleGroup(t *testing.T) {
+	objective := httpSLO.DeepCopy()
+	objective.Namespace = "monitoring"
+
+	groups, err := makeRuleGroups(*objective, false)
+	require.NoError(t, err)
+
+	group, err := makeGrafanaAlertRuleGroup(*objective, groups, grafanaConfig)
+	require.NoError(t, err)
+	require.Equal(t, "GrafanaAlertRuleGroup", group.GetKind())
+	require.Equal(t, "grafana.integreatly.org/v1beta1", group.GetAPIVersion())
+	require.Equal(t, "monitoring", group.GetNamespace())
+	require.Equal(t, "http", group.GetName())
+	require.Len(t, group.GetOwnerReferences(), 1)
+	require.Equal(t, "ServiceLevelObjective", group.GetOwnerReferences()[0].Kind)
+	require.True(t, *group.GetOwnerReferences()[0].Controller)
+
+	var spec grafanaAlertRuleGroupSpec
+	require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(group.Object["spec"].(map[string]interface{}), &spec))
+	require.Equal(t, "slos", spec.FolderRef)
+	require.Equal(t, map[string]string{"dashboards": "grafana"}, spec.InstanceSelector.MatchLabels)
+	require.Equal(t, "30s", spec.Interval)
+	require.Len(t, spec.Rules, 5)
+	require.Equal(t, "SLOMetricAbsent http", spec.Rules[0].Title)
+
+	rule := spec.Rules[1]
+	require.Equal(t, "ErrorBudgetBurn http 5m/1h", rule.Title)
+	require.Len(t, rule.UID, 40)
+	require.Equal(t, "2m0s", rule.For)
+	require.Equal(t, "C", rule.Condition)
+	require.Equal(t, "critical", rule.Labels["severity"])
+	require.Len(t, rule.Data, 3)
+	require.Equal(t, "prometheus", rule.Data[0].DatasourceUID)
+	require.Equal(t,
+		`http_requests:burnrate5m{job="app",slo="http"} > (14 * (1-0.995)) and http_requests:burnrate1h{job="app",slo="http"} > (14 * (1-0.995))`,
+		rule.Data[0].Model["expr"],
+	)
+	require.Equal(t, map[string]interface{}{"type": "prometheus", "uid": "prometheus"}, rule.Data[0].Model["datasource"])
+	require.Equal(t, grafanaExpressionDatasourceUID, rule.Data[2].DatasourceUID)
+	require.Equal(t, "threshold", rule.Data[2].Model["type"])
+
+	// Objectives can query another datasource than the configured one.
+	thanos := objective.DeepCopy()
+	thanos.Annotations = map[string]string{GrafanaDatasourceUIDAnnotation: "thanos"}
+	config := grafanaConfig
+	config.DatasourceType = "thanos-querier"
+	group, err = makeGrafanaAlertRuleGroup(*thanos, groups, config)
+	require.NoError(t, err)
+	var thanosSpec grafanaAlertRuleGroupSpec
+	require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(group.Object["spec"].(map[string]interface{}), &thanosSpec))
+	require.Equal(t, "thanos", thanosSpec.Rules[1].Data[0].DatasourceUID)
+	require.Equal(t, map[string]interface{}{"type": "thanos-querier", "uid": "thanos"}, thanosSpec.Rules[1].Data[0].Model["datasource"])
+
+	// Burn rate alerts keep firing in Grafana too, the partial response strategy only applies to Thanos Ruler.
+	objective.Spec.Alerting.KeepFiringFor = "10m"
+	objective.Spec.Alerting.PartialResponseStrategy = "warn"
+	groups, err = makeRuleGroups(*objective, false)
+	require.NoError(t, err)
+	for _, g := range groups {
+		require.Equal(t, "warn", g.PartialResponseStrategy)
+	}
+	group, err = makeGrafanaAlertRuleGroup(*objective, groups, grafanaConfig)
+	require.NoError(t, err)
+	var keepFiringSpec grafanaAlertRuleGroupSpec
+	require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(group.Object["spec"].(map[string]interface{}), &keepFiringSpec))
+	require.Empty(t, keepFiringSpec.Rules[0].KeepFiringFor)
+	require.Equal(t, "10m", keepFiringSpec.Rules[1].KeepFiringFor)
+
+	titles := map[string]bool{}
+	for _, r := range spec.Rules {
+		require.False(t, titles[r.Title], "duplicate title %s", r.Title)
+		titles[r.Title] = true
+	}
+
+	// Objectives without alerts get no rule group.
+	disabled := false
+	objective.Spec.Alerting.Burnrates = &disabled
+	objective.Spec.Alerting.Absent = &disabled
+	groups, err = makeRuleGroups(*objective, false)
+	require.NoError(t, err)
+	group, err = makeGrafanaAlertRuleGroup(*objective, groups, grafanaConfig)
+	require.NoError(t, err)
+	require.Nil(t, group)
+}
+
+func TestWithoutAlerts(t *testing.T) {
+	groups, err := makeRuleGroups(httpSLO, false)
+	require.NoError(t, err)
+
+	filtered := withoutAlerts(groups)
+	require.Len(t, filtered, len(groups))
+	for i, group := range filtered {
+		require.Equal(t, groups[i].Name, group.Name)
+		require.NotEmpty(t, group.Rules)
+		for _, rule := range group.Rules {
+			require.Empty(t, rule.Alert)
+			require.NotEmpty(t, rule.Record)
+		}
+	}
+}
+
+func TestServiceLevelObjectiveReconciler_GrafanaAlertRules(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective).
+		WithStatusSubresource(objective).
+		Build()
+
+	config := grafanaConfig
+	r := &ServiceLevelObjectiveReconciler{
+		Client:            c,
+		Logger:            kitlog.NewNopLogger(),
+		GrafanaAlertRules: &config,
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+
+	getGroup := func() (*unstructured.Unstructured, error) {
+		group := &unstructured.Unstructured{}
+		group.SetGroupVersionKind(grafanaAlertRuleGroupGVK)
+		return group, c.Get(context.Background(), req.NamespacedName, group)
+	}
+
+	_, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+
+	group, err := getGroup()
+	require.NoError(t, err)
+	rules, _, _ := unstructured.NestedSlice(group.Object, "spec", "rules")
+	require.Len(t, rules, 5)
+
+	// Prometheus only records the rules, Grafana evaluates the alerts.
+	var rule monitoringv1.PrometheusRule
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, &rule))
+	for _, g := range rule.Spec.Groups {
+		for _, r := range g.Rules {
+			require.Empty(t, r.Alert)
+		}
+	}
+
+	// Unchanged objectives don't update the group.
+	resourceVersion := group.GetResourceVersion()
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	group, err = getGroup()
+	require.NoError(t, err)
+	require.Equal(t, resourceVersion, group.GetResourceVersion())
+
+	// The group is deleted once the objective's alerts are disabled.
+	disabled := false
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+	objective.Spec.Alerting.Burnrates = &disabled
+	objective.Spec.Alerting.Absent = &disabled
+	require.NoError(t, c.Update(context.Background(), objective))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	_, err = getGroup()
+	require.True(t, client.IgnoreNotFound(err) == nil && err != nil, "expected not found, got %v", err)
+}
+
+func TestServiceLevelObjectiveReconciler_GrafanaDatasource(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+
+	datasource := &unstructured.Unstructured{}
+	datasource.SetGroupVersionKind(schema.GroupVersionKind{Group: "grafana.integreatly.org", Version: "v1beta1", Kind: "GrafanaDatasource"})
+	datasource.SetNamespace("monitoring")
+	datasource.SetName("prometheus")
+	require.NoError(t, unstructured.SetNestedField(datasource.Object, "prometheus", "spec", "datasource", "uid"))
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective, datasource).
+		WithStatusSubresource(objective).
+		Build()
+
+	recorder := record.NewFakeRecorder(100)
+	config := grafanaConfig
+	r := &ServiceLevelObjectiveReconciler{
+		Client:            c,
+		Logger:            kitlog.NewNopLogger(),
+		Recorder:          recorder,
+		GrafanaAlertRules: &config,
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+	warnings := func() []string {
+		var events []string
+		for {
+			select {
+			case e := <-recorder.Events:
+				if strings.HasPrefix(e, corev1.EventTypeWarning) {
+					events = append(events, e)
+				}
+			default:
+				return events
+			}
+		}
+	}
+
+	_, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Empty(t, warnings())
+
+	// Unknown datasources are warned about, the alert rules are written anyway.
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+	objective.Annotations = map[string]string{GrafanaDatasourceUIDAnnotation: "thanos"}
+	require.NoError(t, c.Update(context.Background(), objective))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"Warning UnknownDatasource None of the GrafanaDatasources in namespace monitoring has the UID thanos the alert rules query",
+	}, warnings())
+
+	group := &unstructured.Unstructured{}
+	group.SetGroupVersionKind(grafanaAlertRuleGroupGVK)
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, group))
+	rules, _, _ := unstructured.NestedSlice(group.Object, "spec", "rules")
+	data, _, _ := unstructured.NestedSlice(rules[0].(map[string]interface{}), "data")
+	require.Equal(t, "thanos", data[0].(map[string]interface{})["datasourceUid"])
+}
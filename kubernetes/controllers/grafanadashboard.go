@@ -0,0 +1,303 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+// grafanaDashboardGVK is the grafana-operator's resource for dashboards.
+var grafanaDashboardGVK = schema.GroupVersionKind{
+	Group:   "grafana.integreatly.org",
+	Version: "v1beta1",
+	Kind:    "GrafanaDashboard",
+}
+
+// GrafanaDashboards configures the GrafanaDashboards created for each objective.
+// The dashboards query the objective's recording rules, so they only show data once Prometheus evaluates them.
+type GrafanaDashboards struct {
+	// DatasourceUID is the UID of the Prometheus datasource in Grafana the panels query.
+	DatasourceUID string
+	// FolderRef is the name of the GrafanaFolder in the objective's namespace the dashboards are created in.
+	FolderRef string
+	// InstanceSelector selects the Grafana instances, by the labels of their Grafana resources, to create the dashboards in.
+	InstanceSelector map[string]string
+}
+
+// +kubebuilder:rbac:groups=grafana.integreatly.org,resources=grafanadashboards,verbs=get;list;watch;create;update;patch;delete
+
+// grafanaDashboardSpec is the subset of the GrafanaDashboard spec Pyrra sets.
+type grafanaDashboardSpec struct {
+	FolderRef        string               `json:"folderRef"`
+	InstanceSelector metav1.LabelSelector `json:"instanceSelector"`
+	JSON             string               `json:"json"`
+}
+
+type grafanaDashboard struct {
+	UID           string               `json:"uid"`
+	Title         string               `json:"title"`
+	Tags          []string             `json:"tags"`
+	Editable      bool                 `json:"editable"`
+	Refresh       string               `json:"refresh"`
+	SchemaVersion int                  `json:"schemaVersion"`
+	Time          grafanaDashboardTime `json:"time"`
+	Panels        []grafanaPanel       `json:"panels"`
+}
+
+type grafanaDashboardTime struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type grafanaPanel struct {
+	ID          int                    `json:"id"`
+	Type        string                 `json:"type"`
+	Title       string                 `json:"title"`
+	Description string                 `json:"description,omitempty"`
+	GridPos     grafanaGridPos         `json:"gridPos"`
+	Datasource  grafanaDatasourceRef   `json:"datasource"`
+	Targets     []grafanaTarget        `json:"targets"`
+	FieldConfig map[string]interface{} `json:"fieldConfig"`
+}
+
+type grafanaGridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type grafanaDatasourceRef struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type grafanaTarget struct {
+	RefID        string               `json:"refId"`
+	Datasource   grafanaDatasourceRef `json:"datasource"`
+	Expr         string               `json:"expr"`
+	LegendFormat string               `json:"legendFormat,omitempty"`
+	Instant      bool                 `json:"instant,omitempty"`
+}
+
+// makeGrafanaDashboard returns the GrafanaDashboard with the availability, error budget and burn rate panels of the objective.
+func makeGrafanaDashboard(kubeObjective pyrrav1alpha1.ServiceLevelObjective, config GrafanaDashboards) (*unstructured.Unstructured, error) {
+	objective, err := kubeObjective.Internal()
+	if err != nil {
+		return nil, invalidObjectiveError{err: fmt.Errorf("failed to get objective: %w", err)}
+	}
+
+	dashboard, err := json.Marshal(grafanaObjectiveDashboard(kubeObjective, objective, config.DatasourceUID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal grafana dashboard: %w", err)
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&grafanaDashboardSpec{
+		FolderRef:        config.FolderRef,
+		InstanceSelector: metav1.LabelSelector{MatchLabels: config.InstanceSelector},
+		JSON:             string(dashboard),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert grafana dashboard: %w", err)
+	}
+
+	isController := true
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(grafanaDashboardGVK)
+	obj.SetName(kubeObjective.GetName())
+	obj.SetNamespace(kubeObjective.GetNamespace())
+	obj.SetLabels(kubeObjective.GetLabels())
+	obj.SetOwnerReferences([]metav1.OwnerReference{{
+		APIVersion: pyrrav1alpha1.GroupVersion.String(),
+		Kind:       "ServiceLevelObjective",
+		Name:       kubeObjective.GetName(),
+		UID:        kubeObjective.GetUID(),
+		Controller: &isController,
+	}})
+	obj.Object["spec"] = content
+	return obj, nil
+}
+
+func grafanaObjectiveDashboard(kubeObjective pyrrav1alpha1.ServiceLevelObjective, objective slo.Objective, datasourceUID string) grafanaDashboard {
+	datasource := grafanaDatasourceRef{Type: "prometheus", UID: datasourceUID}
+	window := model.Duration(objective.Window).String()
+	percent := map[string]interface{}{
+		"defaults":  map[string]interface{}{"unit": "percentunit", "decimals": 3},
+		"overrides": []interface{}{},
+	}
+
+	availability := fmt.Sprintf("1 - (%s / %s)", objective.QueryErrors(objective.Window), objective.QueryTotal(objective.Window))
+
+	var burnrates []grafanaTarget
+	for i, w := range dashboardBurnrateWindows(objective.Windows()) {
+		query, err := objective.QueryBurnrate(w, nil)
+		if err != nil {
+			continue
+		}
+		burnrates = append(burnrates, grafanaTarget{
+			RefID:        string(rune('A' + i)),
+			Datasource:   datasource,
+			Expr:         query,
+			LegendFormat: model.Duration(w).String(),
+		})
+	}
+
+	title := kubeObjective.GetName()
+	if kubeObjective.GetNamespace() != "" {
+		title = kubeObjective.GetNamespace() + "/" + title
+	}
+	sum := sha1.Sum([]byte(kubeObjective.GetNamespace() + "/" + kubeObjective.GetName()))
+
+	return grafanaDashboard{
+		// Grafana limits UIDs to 40 characters, the length of the hex encoded hash.
+		UID:           hex.EncodeToString(sum[:]),
+		Title:         "SLO " + title,
+		Tags:          []string{"pyrra", "slo"},
+		Refresh:       "1m",
+		SchemaVersion: 39,
+		Time:          grafanaDashboardTime{From: "now-" + window, To: "now"},
+		Panels: []grafanaPanel{{
+			ID:          1,
+			Type:        "stat",
+			Title:       "Objective",
+			Description: objective.Description,
+			GridPos:     grafanaGridPos{H: 6, W: 8, X: 0, Y: 0},
+			Datasource:  datasource,
+			Targets: []grafanaTarget{{
+				RefID:      "A",
+				Datasource: datasource,
+				Expr:       fmt.Sprintf("vector(%g)", objective.Target),
+				Instant:    true,
+			}},
+			FieldConfig: percent,
+		}, {
+			ID:          2,
+			Type:        "stat",
+			Title:       "Availability",
+			Description: fmt.Sprintf("The availability over the objective's %s window.", window),
+			GridPos:     grafanaGridPos{H: 6, W: 8, X: 8, Y: 0},
+			Datasource:  datasource,
+			Targets: []grafanaTarget{{
+				RefID:      "A",
+				Datasource: datasource,
+				Expr:       availability,
+				Instant:    true,
+			}},
+			FieldConfig: percent,
+		}, {
+			ID:          3,
+			Type:        "stat",
+			Title:       "Error Budget",
+			Description: fmt.Sprintf("The error budget remaining of the objective's %s window.", window),
+			GridPos:     grafanaGridPos{H: 6, W: 8, X: 16, Y: 0},
+			Datasource:  datasource,
+			Targets: []grafanaTarget{{
+				RefID:      "A",
+				Datasource: datasource,
+				Expr:       objective.QueryErrorBudget(),
+				Instant:    true,
+			}},
+			FieldConfig: percent,
+		}, {
+			ID:         4,
+			Type:       "timeseries",
+			Title:      "Error Budget",
+			GridPos:    grafanaGridPos{H: 8, W: 24, X: 0, Y: 6},
+			Datasource: datasource,
+			Targets: []grafanaTarget{{
+				RefID:      "A",
+				Datasource: datasource,
+				Expr:       objective.QueryErrorBudget(),
+			}},
+			FieldConfig: percent,
+		}, {
+			ID:          5,
+			Type:        "timeseries",
+			Title:       "Burn Rates",
+			Description: "The error rates over the windows of the burn rate alerts.",
+			GridPos:     grafanaGridPos{H: 8, W: 24, X: 0, Y: 14},
+			Datasource:  datasource,
+			Targets:     burnrates,
+			FieldConfig: percent,
+		}},
+	}
+}
+
+// dashboardBurnrateWindows returns the distinct short and long windows of the burn rate alerts, from the shortest.
+func dashboardBurnrateWindows(ws []slo.Window) []time.Duration {
+	seen := map[time.Duration]bool{}
+	var windows []time.Duration
+	for _, w := range ws {
+		for _, d := range []time.Duration{w.Short, w.Long} {
+			if !seen[d] {
+				seen[d] = true
+				windows = append(windows, d)
+			}
+		}
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i] < windows[j] })
+	return windows
+}
+
+func (r *ServiceLevelObjectiveReconciler) reconcileGrafanaDashboard(
+	ctx context.Context,
+	logger kitlog.Logger,
+	kubeObjective pyrrav1alpha1.ServiceLevelObjective,
+) (err error) {
+	ctx, end := startSpan(ctx, "write GrafanaDashboard", &err)
+	defer end()
+
+	dashboard, err := makeGrafanaDashboard(kubeObjective, *r.GrafanaDashboards)
+	if err != nil {
+		return err
+	}
+
+	folder, err := r.grafanaFolder(ctx, logger, kubeObjective, r.GrafanaDashboards.FolderRef)
+	if err != nil {
+		return err
+	}
+	if err := unstructured.SetNestedField(dashboard.Object, folder, "spec", "folderRef"); err != nil {
+		return fmt.Errorf("failed to set folder of grafana dashboard: %w", err)
+	}
+
+	result, err := r.writeGrafanaResource(ctx, logger, dashboard)
+	switch result {
+	case controllerutil.OperationResultCreated:
+		r.event(&kubeObjective, corev1.EventTypeNormal, reasonDashboardCreated, "Created GrafanaDashboard %s", dashboard.GetName())
+	case controllerutil.OperationResultUpdated:
+		r.event(&kubeObjective, corev1.EventTypeNormal, reasonDashboardUpdated, "Updated GrafanaDashboard %s", dashboard.GetName())
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+var dashboardConfig = GrafanaDashboards{
+	DatasourceUID:    "prometheus",
+	FolderRef:        "slos",
+	InstanceSelector: map[string]string{"dashboards": "grafana"},
+}
+
+func TestMakeGrafanaDashboard(t *testing.T) {
+	objective := httpSLO.DeepCopy()
+	objective.Namespace = "monitoring"
+
+	obj, err := makeGrafanaDashboard(*objective, dashboardConfig)
+	require.NoError(t, err)
+	require.Equal(t, "GrafanaDashboard", obj.GetKind())
+	require.Equal(t, "grafana.integreatly.org/v1beta1", obj.GetAPIVersion())
+	require.Equal(t, "monitoring", obj.GetNamespace())
+	require.Equal(t, "http", obj.GetName())
+	require.Len(t, obj.GetOwnerReferences(), 1)
+	require.True(t, *obj.GetOwnerReferences()[0].Controller)
+
+	var spec grafanaDashboardSpec
+	require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object["spec"].(map[string]interface{}), &spec))
+	require.Equal(t, "slos", spec.FolderRef)
+	require.Equal(t, map[string]string{"dashboards": "grafana"}, spec.InstanceSelector.MatchLabels)
+
+	var dashboard grafanaDashboard
+	require.NoError(t, json.Unmarshal([]byte(spec.JSON), &dashboard))
+	require.Equal(t, "SLO monitoring/http", dashboard.Title)
+	require.Len(t, dashboard.UID, 40)
+	require.Equal(t, "now-4w", dashboard.Time.From)
+	require.Len(t, dashboard.Panels, 5)
+	for _, panel := range dashboard.Panels {
+		require.Equal(t, "prometheus", panel.Datasource.UID)
+	}
+
+	require.Equal(t, "vector(0.995)", dashboard.Panels[0].Targets[0].Expr)
+	require.Equal(t, "Availability", dashboard.Panels[1].Title)
+	require.Equal(t,
+		`1 - (sum(http_requests:increase4w{job="app",slo="http",status=~"5.."}) / sum(http_requests:increase4w{job="app",slo="http"}))`,
+		dashboard.Panels[1].Targets[0].Expr,
+	)
+	require.Contains(t, dashboard.Panels[2].Targets[0].Expr, `http_requests:increase4w`)
+
+	// One series per distinct window of the burn rate alerts.
+	burnrates := dashboard.Panels[4].Targets
+	require.Len(t, burnrates, 7)
+	require.Equal(t, "5m", burnrates[0].LegendFormat)
+	require.Equal(t, `http_requests:burnrate5m{job="app",slo="http"}`, burnrates[0].Expr)
+	require.Equal(t, "4d", burnrates[6].LegendFormat)
+}
+
+func TestServiceLevelObjectiveReconciler_GrafanaDashboards(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective).
+		WithStatusSubresource(objective).
+		Build()
+
+	config := dashboardConfig
+	recorder := record.NewFakeRecorder(10)
+	r := &ServiceLevelObjectiveReconciler{
+		Client:            c,
+		Logger:            kitlog.NewNopLogger(),
+		GrafanaDashboards: &config,
+		Recorder:          recorder,
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+
+	getDashboard := func() *unstructured.Unstructured {
+		dashboard := &unstructured.Unstructured{}
+		dashboard.SetGroupVersionKind(grafanaDashboardGVK)
+		require.NoError(t, c.Get(context.Background(), req.NamespacedName, dashboard))
+		return dashboard
+	}
+
+	_, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	dashboard := getDashboard()
+	require.Contains(t, <-recorder.Events, "Normal RulesCreated Created PrometheusRule http")
+	require.Equal(t, "Normal DashboardCreated Created GrafanaDashboard http", <-recorder.Events)
+
+	// Unchanged objectives don't update the dashboard.
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, dashboard.GetResourceVersion(), getDashboard().GetResourceVersion())
+
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+	objective.Spec.Target = "99.9"
+	require.NoError(t, c.Update(context.Background(), objective))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.NotEqual(t, dashboard.GetResourceVersion(), getDashboard().GetResourceVersion())
+	require.Equal(t, "Normal RulesUpdated Updated PrometheusRule http", <-recorder.Events)
+	require.Equal(t, "Normal DashboardUpdated Updated GrafanaDashboard http", <-recorder.Events)
+}
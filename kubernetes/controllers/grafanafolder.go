@@ -0,0 +1,196 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// grafanaFolderGVK is the grafana-operator's resource for folders.
+var grafanaFolderGVK = schema.GroupVersionKind{
+	Group:   "grafana.integreatly.org",
+	Version: "v1beta1",
+	Kind:    "GrafanaFolder",
+}
+
+// GrafanaFolders configures the GrafanaFolders the alert rules and dashboards of objectives are created in.
+// Without folders, Grafana puts them in its General folder.
+type GrafanaFolders struct {
+	// NameTemplate renders the name of the objective's GrafanaFolder from GrafanaFolderData, like slos-{{.Namespace}}.
+	NameTemplate *template.Template
+	// Create makes the reconciler create the GrafanaFolders in the objectives' namespaces, titled after their name.
+	// Each objective owns its folder, so it is deleted with its last objective.
+	// The folders have to exist otherwise.
+	Create bool
+	// InstanceSelector selects the Grafana instances, by the labels of their Grafana resources, to create the folders in.
+	InstanceSelector map[string]string
+}
+
+// +kubebuilder:rbac:groups=grafana.integreatly.org,resources=grafanafolders,verbs=get;list;watch;create;update;patch;delete
+
+// GrafanaFolderData is the data the name template of GrafanaFolders is rendered with.
+type GrafanaFolderData struct {
+	// Namespace of the objective.
+	Namespace string
+	// Name of the objective.
+	Name string
+}
+
+// grafanaFolderSpec is the subset of the GrafanaFolder spec Pyrra sets.
+type grafanaFolderSpec struct {
+	Title            string               `json:"title"`
+	InstanceSelector metav1.LabelSelector `json:"instanceSelector"`
+}
+
+// Name returns the name of the objective's GrafanaFolder.
+func (f *GrafanaFolders) Name(kubeObjective pyrrav1alpha1.ServiceLevelObjective) (string, error) {
+	var sb strings.Builder
+	if err := f.NameTemplate.Execute(&sb, GrafanaFolderData{
+		Namespace: kubeObjective.GetNamespace(),
+		Name:      kubeObjective.GetName(),
+	}); err != nil {
+		return "", fmt.Errorf("failed to render grafana folder name: %w", err)
+	}
+	name := sb.String()
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", fmt.Errorf("invalid grafana folder name %q: %s", name, strings.Join(errs, ", "))
+	}
+	return name, nil
+}
+
+// makeGrafanaFolder returns the GrafanaFolder with the objective as one of its owners.
+// Objectives sharing the folder are owners too, so the owner references of the existing folder are kept.
+func makeGrafanaFolder(
+	kubeObjective pyrrav1alpha1.ServiceLevelObjective,
+	name string,
+	owners []metav1.OwnerReference,
+	config GrafanaFolders,
+) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&grafanaFolderSpec{
+		Title:            name,
+		InstanceSelector: metav1.LabelSelector{MatchLabels: config.InstanceSelector},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert grafana folder: %w", err)
+	}
+
+	owner := metav1.OwnerReference{
+		APIVersion: pyrrav1alpha1.GroupVersion.String(),
+		Kind:       "ServiceLevelObjective",
+		Name:       kubeObjective.GetName(),
+		UID:        kubeObjective.GetUID(),
+	}
+	ownerReferences := owners
+	owned := false
+	for _, o := range owners {
+		if o.UID == owner.UID {
+			owned = true
+		}
+	}
+	if !owned {
+		ownerReferences = append(append([]metav1.OwnerReference{}, owners...), owner)
+	}
+
+	folder := &unstructured.Unstructured{}
+	folder.SetGroupVersionKind(grafanaFolderGVK)
+	folder.SetName(name)
+	folder.SetNamespace(kubeObjective.GetNamespace())
+	folder.SetOwnerReferences(ownerReferences)
+	folder.Object["spec"] = content
+	return folder, nil
+}
+
+// grafanaFolder returns the name of the GrafanaFolder the objective's Grafana resources are created in,
+// the folderRef of their config if there are no GrafanaFolders.
+// It creates the folder if the GrafanaFolders are created by the reconciler.
+func (r *ServiceLevelObjectiveReconciler) grafanaFolder(
+	ctx context.Context,
+	logger kitlog.Logger,
+	kubeObjective pyrrav1alpha1.ServiceLevelObjective,
+	folderRef string,
+) (_ string, err error) {
+	if r.GrafanaFolders == nil {
+		return folderRef, nil
+	}
+	name, err := r.GrafanaFolders.Name(kubeObjective)
+	if err != nil {
+		return "", invalidObjectiveError{err: err}
+	}
+	if !r.GrafanaFolders.Create {
+		return name, nil
+	}
+
+	ctx, end := startSpan(ctx, "write GrafanaFolder", &err)
+	defer end()
+
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(grafanaFolderGVK)
+	if err := r.Get(ctx, client.ObjectKey{Namespace: kubeObjective.GetNamespace(), Name: name}, existing); err != nil && !errors.IsNotFound(err) {
+		return "", fmt.Errorf("failed to get grafana folder: %w", err)
+	}
+
+	folder, err := makeGrafanaFolder(kubeObjective, name, existing.GetOwnerReferences(), *r.GrafanaFolders)
+	if err != nil {
+		return "", err
+	}
+	result, err := r.writeGrafanaResource(ctx, logger, folder)
+	if result == controllerutil.OperationResultCreated {
+		r.event(&kubeObjective, corev1.EventTypeNormal, reasonFolderCreated, "Created GrafanaFolder %s", name)
+	}
+	return name, err
+}
+
+// deleteGrafanaResource deletes the grafana-operator resource named after the deleted objective,
+// if the objective controlled it.
+func (r *ServiceLevelObjectiveReconciler) deleteGrafanaResource(
+	ctx context.Context,
+	logger kitlog.Logger,
+	gvk schema.GroupVersionKind,
+	key client.ObjectKey,
+) error {
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(gvk)
+	if err := r.Get(ctx, key, existing); err != nil {
+		return client.IgnoreNotFound(fmt.Errorf("failed to get %s: %w", gvk.Kind, err))
+	}
+	owner := metav1.GetControllerOf(existing)
+	if owner == nil || owner.Kind != "ServiceLevelObjective" || owner.Name != key.Name {
+		return nil
+	}
+	if err := r.Delete(ctx, existing); err != nil {
+		return client.IgnoreNotFound(fmt.Errorf("failed to delete %s: %w", gvk.Kind, err))
+	}
+	level.Info(logger).Log("msg", "deleted grafana resource", "kind", gvk.Kind, "namespace", key.Namespace, "name", key.Name)
+	return nil
+}
@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"text/template"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func TestGrafanaFolders_Name(t *testing.T) {
+	objective := pyrrav1alpha1.ServiceLevelObjective{ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "http"}}
+
+	f := &GrafanaFolders{NameTemplate: template.Must(template.New("folder").Parse("slos-{{.Namespace}}"))}
+	name, err := f.Name(objective)
+	require.NoError(t, err)
+	require.Equal(t, "slos-monitoring", name)
+
+	f = &GrafanaFolders{NameTemplate: template.Must(template.New("folder").Parse("SLOs {{.Namespace}}"))}
+	_, err = f.Name(objective)
+	require.ErrorContains(t, err, `invalid grafana folder name "SLOs monitoring"`)
+}
+
+func TestServiceLevelObjectiveReconciler_GrafanaFolders(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	http := httpSLO.DeepCopy()
+	http.TypeMeta = metav1.TypeMeta{}
+	http.Namespace = "monitoring"
+	http.UID = "http-uid"
+	checkout := http.DeepCopy()
+	checkout.Name = "checkout"
+	checkout.UID = "checkout-uid"
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(http, checkout).
+		WithStatusSubresource(http, checkout).
+		Build()
+
+	alerts := grafanaConfig
+	dashboards := dashboardConfig
+	r := &ServiceLevelObjectiveReconciler{
+		Client:            c,
+		Logger:            kitlog.NewNopLogger(),
+		GrafanaAlertRules: &alerts,
+		GrafanaDashboards: &dashboards,
+		GrafanaFolders: &GrafanaFolders{
+			NameTemplate:     template.Must(template.New("folder").Parse("slos-{{.Namespace}}")),
+			Create:           true,
+			InstanceSelector: map[string]string{"dashboards": "grafana"},
+		},
+	}
+	reconcileObjective := func(obj client.Object) {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+		require.NoError(t, err)
+	}
+	get := func(gvk schema.GroupVersionKind, name string) (*unstructured.Unstructured, error) {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		return obj, c.Get(context.Background(), client.ObjectKey{Namespace: "monitoring", Name: name}, obj)
+	}
+
+	reconcileObjective(http)
+	reconcileObjective(checkout)
+
+	// Both objectives own the folder of their namespace, which their alert rules and dashboards are in.
+	folder, err := get(grafanaFolderGVK, "slos-monitoring")
+	require.NoError(t, err)
+	title, _, _ := unstructured.NestedString(folder.Object, "spec", "title")
+	require.Equal(t, "slos-monitoring", title)
+	var owners []types.UID
+	for _, o := range folder.GetOwnerReferences() {
+		require.Nil(t, o.Controller)
+		owners = append(owners, o.UID)
+	}
+	require.Equal(t, []types.UID{"http-uid", "checkout-uid"}, owners)
+
+	for _, gvk := range []schema.GroupVersionKind{grafanaAlertRuleGroupGVK, grafanaDashboardGVK} {
+		obj, err := get(gvk, "http")
+		require.NoError(t, err)
+		folderRef, _, _ := unstructured.NestedString(obj.Object, "spec", "folderRef")
+		require.Equal(t, "slos-monitoring", folderRef)
+	}
+
+	// Reconciling again doesn't add the objective as owner twice.
+	reconcileObjective(http)
+	folder, err = get(grafanaFolderGVK, "slos-monitoring")
+	require.NoError(t, err)
+	require.Len(t, folder.GetOwnerReferences(), 2)
+
+	// Deleted objectives' alert rules and dashboards are deleted right away.
+	require.NoError(t, c.Delete(context.Background(), http))
+	reconcileObjective(http)
+	for _, gvk := range []schema.GroupVersionKind{grafanaAlertRuleGroupGVK, grafanaDashboardGVK} {
+		_, err := get(gvk, "http")
+		require.True(t, client.IgnoreNotFound(err) == nil && err != nil, "expected not found, got %v", err)
+		_, err = get(gvk, "checkout")
+		require.NoError(t, err)
+	}
+}
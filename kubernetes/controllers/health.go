@@ -0,0 +1,288 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// lokiPingNamespace is the ruler namespace whose rule groups are requested to probe a ruler.
+// It isn't expected to exist, the ruler responding with 404 is all that's checked.
+const lokiPingNamespace = "pyrra-health-probe"
+
+var backendUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "pyrra_backend_up",
+	Help: "Whether the last probe of the backend, like the Kubernetes API or a Loki ruler, succeeded.",
+}, []string{"backend"})
+
+func init() {
+	metrics.Registry.MustRegister(backendUp)
+}
+
+// Ping requests the rule groups of a ruler namespace once, without retries,
+// to check that the ruler is reachable and accepts the ruler's credentials.
+func (l *LokiRuler) Ping(ctx context.Context) error {
+	ruler := *l
+	ruler.Backoff = wait.Backoff{}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ruler.rulesURL(lokiPingNamespace), nil)
+	if err != nil {
+		return err
+	}
+	_, err = ruler.do(req)
+	return err
+}
+
+// authenticated returns true if the ruler has credentials of its own, from its config or headers.
+func (l *LokiRuler) authenticated() bool {
+	if l.credentials != (LokiCredentials{}) {
+		return true
+	}
+	for name := range l.Headers {
+		switch http.CanonicalHeaderKey(name) {
+		case "Authorization", "X-Scope-Orgid":
+			return true
+		}
+	}
+	return false
+}
+
+// BackendProbe checks the connectivity to a backend, returning an error if it can't be reached.
+type BackendProbe func(ctx context.Context) error
+
+// BackendProbes returns the probes of the backends the reconciler reads objectives from and writes their rules to,
+// by name: the Kubernetes API through the reader, the Loki rulers, the grafana-operator's resources and the remote clusters.
+// The reader should bypass the cache, for the probes to request the Kubernetes API.
+func (r *ServiceLevelObjectiveReconciler) BackendProbes(reader client.Reader) map[string]BackendProbe {
+	probes := map[string]BackendProbe{
+		"kubernetes": func(ctx context.Context) error {
+			return reader.List(ctx, &pyrrav1alpha1.ServiceLevelObjectiveList{}, client.Limit(1))
+		},
+	}
+
+	// With the credentials or tenants of the objectives' namespaces, rulers without credentials of their own
+	// can't authenticate the probe, so the ruler responding at all is all that's probed then.
+	// The credentials of the namespaces aren't probed, their failures are part of the objectives' status.
+	perNamespace := r.LokiCredentialsSecret != "" || r.LokiNamespaceTenants
+	pingRuler := func(ctx context.Context, ruler *LokiRuler) error {
+		err := ruler.Ping(ctx)
+		var statusErr lokiRulerStatusError
+		if perNamespace && !ruler.authenticated() && errors.As(err, &statusErr) &&
+			(statusErr.code == http.StatusUnauthorized || statusErr.code == http.StatusForbidden) {
+			return nil
+		}
+		return err
+	}
+	if r.LokiRuler != nil || r.LokiRulerConfig != nil {
+		probes["loki-ruler"] = func(ctx context.Context) error {
+			ruler, err := r.defaultLokiRuler(ctx)
+			if err != nil || ruler == nil {
+				return err
+			}
+			return pingRuler(ctx, ruler)
+		}
+	}
+	for name, destination := range r.Destinations {
+		if ruler := destination.LokiRuler; ruler != nil {
+			probes["loki-ruler-"+name] = func(ctx context.Context) error { return pingRuler(ctx, ruler) }
+		}
+	}
+
+	var grafanaLists []*unstructured.UnstructuredList
+	if r.GrafanaAlertRules != nil {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(grafanaAlertRuleGroupGVK)
+		grafanaLists = append(grafanaLists, list)
+	}
+	if r.GrafanaDashboards != nil {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(grafanaDashboardGVK)
+		grafanaLists = append(grafanaLists, list)
+	}
+	if len(grafanaLists) > 0 {
+		// The grafana-operator's resources not being served, like after uninstalling it, fails every write to Grafana.
+		probes["grafana"] = func(ctx context.Context) error {
+			for _, list := range grafanaLists {
+				if err := reader.List(ctx, list.DeepCopy(), client.Limit(1)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	for name, cluster := range r.RemoteClusters {
+		probes["cluster-"+name] = func(ctx context.Context) error {
+			remote, err := cluster.remoteClient(ctx, r.Client, r.Client.Scheme())
+			if err != nil {
+				return err
+			}
+			return remote.List(ctx, &monitoringv1.PrometheusRuleList{}, client.Limit(1))
+		}
+	}
+	return probes
+}
+
+// BackendHealth probes the backends on an interval and caches the results for the operator's readyz and healthz endpoints,
+// so the backends aren't requested on every probe of the kubelet.
+// Backends failing make the operator unready, and once they failed FailureThreshold times in a row, unhealthy,
+// for Kubernetes to restart it rather than it failing every reconcile, like with expired credentials of a Loki ruler.
+type BackendHealth struct {
+	Logger kitlog.Logger
+	Probes map[string]BackendProbe
+	// Interval is how often the backends are probed.
+	Interval time.Duration
+	// Timeout is how long each probe may take.
+	Timeout time.Duration
+	// FailureThreshold is how many consecutive failures of a backend make the operator unhealthy, never if 0.
+	FailureThreshold int
+
+	mu      sync.Mutex
+	results map[string]backendResult
+}
+
+type backendResult struct {
+	err      error
+	failures int
+}
+
+var (
+	_ manager.Runnable               = &BackendHealth{}
+	_ manager.LeaderElectionRunnable = &BackendHealth{}
+)
+
+// NeedLeaderElection makes sure all replicas probe the backends, as all of them are probed by the kubelet.
+func (h *BackendHealth) NeedLeaderElection() bool {
+	return false
+}
+
+func (h *BackendHealth) Start(ctx context.Context) error {
+	ticker := time.NewTicker(h.Interval)
+	defer ticker.Stop()
+
+	for {
+		h.probe(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// probe probes all backends concurrently and records their results.
+func (h *BackendHealth) probe(ctx context.Context) {
+	var wg sync.WaitGroup
+	for name, probe := range h.Probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+			defer cancel()
+			h.record(name, probe(ctx))
+		}()
+	}
+	wg.Wait()
+}
+
+func (h *BackendHealth) record(name string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.results == nil {
+		h.results = make(map[string]backendResult, len(h.Probes))
+	}
+	result := h.results[name]
+	if err != nil {
+		if result.err == nil {
+			level.Warn(h.Logger).Log("msg", "backend probe failed", "backend", name, "err", err)
+		}
+		result.failures++
+		backendUp.WithLabelValues(name).Set(0)
+	} else {
+		if result.err != nil {
+			level.Info(h.Logger).Log("msg", "backend probe succeeded again", "backend", name)
+		}
+		result.failures = 0
+		backendUp.WithLabelValues(name).Set(1)
+	}
+	result.err = err
+	h.results[name] = result
+}
+
+// Ready is the readyz check failing while any backend wasn't probed yet or its last probe failed.
+func (h *BackendHealth) Ready(_ *http.Request) error {
+	return h.check(func(name string, result backendResult, probed bool) string {
+		switch {
+		case !probed:
+			return fmt.Sprintf("%s: not probed yet", name)
+		case result.err != nil:
+			return fmt.Sprintf("%s: %s", name, result.err)
+		default:
+			return ""
+		}
+	})
+}
+
+// Live is the healthz check failing once any backend failed FailureThreshold probes in a row.
+func (h *BackendHealth) Live(_ *http.Request) error {
+	return h.check(func(name string, result backendResult, _ bool) string {
+		if h.FailureThreshold > 0 && result.failures >= h.FailureThreshold {
+			return fmt.Sprintf("%s: failed %d probes in a row: %s", name, result.failures, result.err)
+		}
+		return ""
+	})
+}
+
+// check returns the failures of the backends, sorted by their name.
+func (h *BackendHealth) check(failure func(name string, result backendResult, probed bool) string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var failures []string
+	for name := range h.Probes {
+		result, probed := h.results[name]
+		if f := failure(name, result, probed); f != "" {
+			failures = append(failures, f)
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	sort.Strings(failures)
+	return fmt.Errorf("backends failing: %s", strings.Join(failures, "; "))
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func TestLokiRuler_Ping(t *testing.T) {
+	var requests atomic.Int32
+	status := http.StatusNotFound
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		require.Equal(t, http.MethodGet, r.Method)
+		require.Equal(t, "/loki/api/v1/rules/"+lokiPingNamespace, r.URL.Path)
+		require.Equal(t, "tenant", r.Header.Get("X-Scope-OrgID"))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	ruler := (&LokiRuler{
+		URL:     u,
+		Backoff: wait.Backoff{Duration: time.Millisecond, Steps: 3},
+	}).WithCredentials(LokiCredentials{Tenant: "tenant"})
+
+	// The namespace not existing is the ruler responding.
+	require.NoError(t, ruler.Ping(context.Background()))
+
+	status = http.StatusUnauthorized
+	require.EqualError(t, ruler.Ping(context.Background()), "loki ruler returned 401 Unauthorized")
+
+	// Probes aren't retried, the next probe is.
+	requests.Store(0)
+	status = http.StatusServiceUnavailable
+	require.Error(t, ruler.Ping(context.Background()))
+	require.Equal(t, int32(1), requests.Load())
+}
+
+func TestServiceLevelObjectiveReconciler_BackendProbes(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	c := newFakeClientBuilder().WithScheme(scheme).Build()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "no org id", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	r := &ServiceLevelObjectiveReconciler{
+		Client:            c,
+		Logger:            kitlog.NewNopLogger(),
+		LokiRuler:         &LokiRuler{URL: u},
+		Destinations:      map[string]Destination{"staging": {LokiRuler: &LokiRuler{URL: u}}, "prod": {}},
+		GrafanaAlertRules: &GrafanaAlertRules{},
+	}
+	probes := r.BackendProbes(c)
+	require.Len(t, probes, 4)
+	require.Contains(t, probes, "grafana")
+	require.NoError(t, probes["kubernetes"](context.Background()))
+	require.EqualError(t, probes["loki-ruler"](context.Background()), "loki ruler returned 401 Unauthorized: no org id")
+	require.EqualError(t, probes["loki-ruler-staging"](context.Background()), "loki ruler returned 401 Unauthorized: no org id")
+
+	// The rulers authenticate the objectives' namespaces with their tenants only, the ruler responding is enough then.
+	r.LokiNamespaceTenants = true
+	probes = r.BackendProbes(c)
+	require.NoError(t, probes["loki-ruler"](context.Background()))
+	require.NoError(t, probes["loki-ruler-staging"](context.Background()))
+
+	// The rulers' own credentials being rejected still fails the probes, like when they expired.
+	r.LokiRuler = r.LokiRuler.WithCredentials(LokiCredentials{Token: "expired"})
+	r.Destinations["staging"] = Destination{LokiRuler: &LokiRuler{URL: u, Headers: map[string]string{"x-scope-orgid": "staging"}}}
+	probes = r.BackendProbes(c)
+	require.EqualError(t, probes["loki-ruler"](context.Background()), "loki ruler returned 401 Unauthorized: no org id")
+	require.EqualError(t, probes["loki-ruler-staging"](context.Background()), "loki ruler returned 401 Unauthorized: no org id")
+}
+
+func TestBackendHealth(t *testing.T) {
+	var kubernetesErr, rulerErr error
+	h := &BackendHealth{
+		Logger: kitlog.NewNopLogger(),
+		Probes: map[string]BackendProbe{
+			"kubernetes": func(context.Context) error { return kubernetesErr },
+			"loki-ruler": func(ctx context.Context) error {
+				if _, ok := ctx.Deadline(); !ok {
+					return fmt.Errorf("no timeout")
+				}
+				return rulerErr
+			},
+		},
+		Timeout:          time.Second,
+		FailureThreshold: 2,
+	}
+
+	require.EqualError(t, h.Ready(nil), "backends failing: kubernetes: not probed yet; loki-ruler: not probed yet")
+	require.NoError(t, h.Live(nil))
+
+	h.probe(context.Background())
+	require.NoError(t, h.Ready(nil))
+	require.NoError(t, h.Live(nil))
+
+	// Failing backends make the operator unready right away, and unhealthy after the failure threshold.
+	rulerErr = fmt.Errorf("loki ruler returned 401 Unauthorized")
+	h.probe(context.Background())
+	require.EqualError(t, h.Ready(nil), "backends failing: loki-ruler: loki ruler returned 401 Unauthorized")
+	require.NoError(t, h.Live(nil))
+
+	h.probe(context.Background())
+	require.EqualError(t, h.Live(nil), "backends failing: loki-ruler: failed 2 probes in a row: loki ruler returned 401 Unauthorized")
+
+	// A single success resets the failures.
+	rulerErr = nil
+	h.probe(context.Background())
+	require.NoError(t, h.Ready(nil))
+	require.NoError(t, h.Live(nil))
+
+	// The operator is never unhealthy without a failure threshold.
+	kubernetesErr = fmt.Errorf("connection refused")
+	h.FailureThreshold = 0
+	for i := 0; i < 5; i++ {
+		h.probe(context.Background())
+	}
+	require.EqualError(t, h.Ready(nil), "backends failing: kubernetes: connection refused")
+	require.NoError(t, h.Live(nil))
+}
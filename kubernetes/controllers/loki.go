@@ -0,0 +1,379 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/yaml"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+const (
+	// LokiRulerAnnotation marks ServiceLevelObjectives whose rules are evaluated by Loki instead of Prometheus.
+	LokiRulerAnnotation = "pyrra.dev/ruler"
+	lokiRulerValue      = "loki"
+	// RulerTenantAnnotation on a namespace sets the tenant the rules of its objectives are sent to, as X-Scope-OrgID.
+	RulerTenantAnnotation = "pyrra.dev/ruler-tenant"
+
+	// lokiRuleLabel is picked up by the Loki rules sidecar to load ConfigMaps into the ruler.
+	lokiRuleLabel = "loki_rule"
+
+	// lokiRulerStatusType is the status type of objectives whose rule groups are pushed to the Loki ruler.
+	lokiRulerStatusType = "LokiRuler"
+)
+
+var (
+	lokiRulerWriteFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pyrra_loki_ruler_write_failures_total",
+		Help: "Total number of rule group writes to the Loki ruler that failed after all retries.",
+	}, []string{"operation"})
+	lokiRulerWriteDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pyrra_loki_ruler_write_duration_seconds",
+		Help:    "Duration of rule group writes to the Loki ruler, including retries.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(lokiRulerWriteFailures, lokiRulerWriteDuration)
+	for _, operation := range []string{"set", "delete"} {
+		lokiRulerWriteFailures.WithLabelValues(operation)
+		lokiRulerWriteDuration.WithLabelValues(operation)
+	}
+}
+
+// IsLokiObjective returns true if the objective with the annotations is evaluated by Loki.
+func IsLokiObjective(annotations map[string]string) bool {
+	return annotations[LokiRulerAnnotation] == lokiRulerValue
+}
+
+// LokiRuler is a client for the rules API of the Loki ruler.
+type LokiRuler struct {
+	URL    *url.URL
+	Client *http.Client
+	// Backoff retries requests that failed with connection errors, 429 or 5xx responses.
+	// Requests are retried up to Steps times, not at all if they are 0.
+	Backoff wait.Backoff
+	// Headers are sent with every request. They take precedence over the credentials, like to override the tenant.
+	Headers map[string]string
+
+	credentials LokiCredentials
+}
+
+// LokiCredentials authenticate the requests to the ruler, like with the API key of a tenant.
+type LokiCredentials struct {
+	// Tenant is sent as X-Scope-OrgID header to multi-tenant rulers.
+	Tenant string
+	// Token is sent as bearer token. It takes precedence over the username and password.
+	Token    string
+	Username string
+	Password string
+}
+
+// lokiCredentialsFromSecret reads the credentials from the tenant, token, username and password keys of the Secret.
+func lokiCredentialsFromSecret(secret corev1.Secret) LokiCredentials {
+	return LokiCredentials{
+		Tenant:   string(secret.Data["tenant"]),
+		Token:    string(secret.Data["token"]),
+		Username: string(secret.Data["username"]),
+		Password: string(secret.Data["password"]),
+	}
+}
+
+// WithCredentials returns a copy of the ruler that authenticates its requests with the credentials.
+func (l *LokiRuler) WithCredentials(credentials LokiCredentials) *LokiRuler {
+	ruler := *l
+	ruler.credentials = credentials
+	return &ruler
+}
+
+// rulesURL returns the URL of the rules API with the escaped path elements appended,
+// so ruler namespaces can contain slashes.
+func (l *LokiRuler) rulesURL(elem ...string) string {
+	u := *l.URL
+	escaped := make([]string, len(elem))
+	for i, e := range elem {
+		escaped[i] = url.PathEscape(e)
+	}
+	u.RawPath = path.Join(append([]string{u.EscapedPath(), "/loki/api/v1/rules"}, escaped...)...)
+	u.Path = path.Join(append([]string{u.Path, "/loki/api/v1/rules"}, elem...)...)
+	return u.String()
+}
+
+func (l *LokiRuler) client() *http.Client {
+	if l.Client != nil {
+		return l.Client
+	}
+	return http.DefaultClient
+}
+
+// SetRuleGroup creates or replaces the rule group within the ruler namespace.
+func (l *LokiRuler) SetRuleGroup(ctx context.Context, namespace string, group monitoringv1.RuleGroup) error {
+	body, err := yaml.Marshal(group)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rule group: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.rulesURL(namespace), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/yaml")
+
+	return l.write("set", req)
+}
+
+// GetRuleGroup returns the rule group within the ruler namespace or nil if it doesn't exist.
+func (l *LokiRuler) GetRuleGroup(ctx context.Context, namespace, name string) (*monitoringv1.RuleGroup, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.rulesURL(namespace, name), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := l.do(req)
+	if err != nil || body == nil {
+		return nil, err
+	}
+
+	var group monitoringv1.RuleGroup
+	if err := yaml.Unmarshal(body, &group); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rule group: %w", err)
+	}
+	return &group, nil
+}
+
+// ListRuleGroups returns all rule groups within the ruler namespace, in a single request.
+func (l *LokiRuler) ListRuleGroups(ctx context.Context, namespace string) ([]monitoringv1.RuleGroup, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.rulesURL(namespace), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := l.do(req)
+	if err != nil || body == nil {
+		return nil, err
+	}
+
+	// The ruler responds with the rule groups by their namespace.
+	var namespaces map[string][]monitoringv1.RuleGroup
+	if err := yaml.Unmarshal(body, &namespaces); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rule groups: %w", err)
+	}
+	return namespaces[namespace], nil
+}
+
+// DeleteRuleGroup deletes the rule group within the ruler namespace.
+// Rule groups that don't exist are ignored.
+func (l *LokiRuler) DeleteRuleGroup(ctx context.Context, namespace, name string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, l.rulesURL(namespace, name), nil)
+	if err != nil {
+		return err
+	}
+
+	return l.write("delete", req)
+}
+
+// write sends the request changing rule groups and records its duration and whether it failed after all retries.
+func (l *LokiRuler) write(operation string, req *http.Request) error {
+	start := time.Now()
+	_, err := l.do(req)
+	lokiRulerWriteDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		lokiRulerWriteFailures.WithLabelValues(operation).Inc()
+	}
+	return err
+}
+
+// do sends the request, retrying it with the ruler's backoff, and returns the response body.
+// Rule groups that aren't found are no error for reads and deletes, the body is nil then.
+// The request and its attempts are traced within a span of the ruler's tenant,
+// to tell slow rulers, like the one of a Mimir tenant, apart in the reconciles' traces.
+func (l *LokiRuler) do(req *http.Request) (_ []byte, err error) {
+	attempts := 0
+	ctx, end := startSpan(req.Context(), "ruler request", &err,
+		attribute.String("method", req.Method),
+		attribute.String("path", req.URL.Path),
+		attribute.String("tenant", l.credentials.Tenant),
+	)
+	defer func() {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Int("attempts", attempts))
+		end()
+	}()
+	req = req.WithContext(ctx)
+
+	if l.credentials.Tenant != "" {
+		req.Header.Set("X-Scope-OrgID", l.credentials.Tenant)
+	}
+	switch {
+	case l.credentials.Token != "":
+		req.Header.Set("Authorization", "Bearer "+l.credentials.Token)
+	case l.credentials.Username != "":
+		req.SetBasicAuth(l.credentials.Username, l.credentials.Password)
+	}
+	for name, value := range l.Headers {
+		req.Header.Set(name, value)
+	}
+
+	backoff := l.Backoff
+	for {
+		attempt := req.Clone(req.Context())
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attempt.Body = body
+		}
+
+		attempts++
+		body, retry, err := l.send(attempt)
+		if err == nil || !retry || backoff.Steps < 1 {
+			return body, err
+		}
+
+		timer := time.NewTimer(backoff.Step())
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// send sends the request once and returns the response body or the error and whether the request should be retried.
+func (l *LokiRuler) send(req *http.Request) ([]byte, bool, error) {
+	resp, err := l.client().Do(req)
+	if err != nil {
+		return nil, req.Context().Err() == nil, fmt.Errorf("failed to request loki ruler: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound && (req.Method == http.MethodDelete || req.Method == http.MethodGet) {
+		return nil, false, nil
+	}
+	if resp.StatusCode/100 != 2 {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, retry, lokiRulerStatusError{code: resp.StatusCode, status: resp.Status, msg: bytes.TrimSpace(msg)}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to read loki ruler response: %w", err)
+	}
+	return body, false, nil
+}
+
+// lokiRulerStatusError is the error of a request the ruler responded to with a status other than 2xx.
+type lokiRulerStatusError struct {
+	code   int
+	status string
+	msg    []byte
+}
+
+func (e lokiRulerStatusError) Error() string {
+	if len(e.msg) > 0 {
+		return fmt.Sprintf("loki ruler returned %s: %s", e.status, e.msg)
+	}
+	return fmt.Sprintf("loki ruler returned %s", e.status)
+}
+
+// LokiGroupIntervals override the evaluation intervals of the rule groups of objectives in the Loki ruler.
+// The generated intervals are kept for the ones that are 0, and for objectives with their own ruleGroupInterval.
+type LokiGroupIntervals struct {
+	Increase time.Duration
+	BurnRate time.Duration
+	Generic  time.Duration
+}
+
+// apply returns the rule group of the objective with its interval overridden.
+func (i LokiGroupIntervals) apply(kubeObjective pyrrav1alpha1.ServiceLevelObjective, group monitoringv1.RuleGroup) monitoringv1.RuleGroup {
+	if kubeObjective.Spec.RuleGroupInterval != "" {
+		return group
+	}
+
+	var interval time.Duration
+	switch group.Name {
+	case kubeObjective.GetName() + slo.IncreaseRuleGroupSuffix:
+		interval = i.Increase
+	case kubeObjective.GetName():
+		interval = i.BurnRate
+	case kubeObjective.GetName() + slo.GenericRuleGroupSuffix:
+		interval = i.Generic
+	}
+	if interval == 0 {
+		return group
+	}
+	d := monitoringv1.Duration(model.Duration(interval).String())
+	group.Interval = &d
+	return group
+}
+
+// LokiNamespaceData is what ruler namespace templates are rendered with.
+type LokiNamespaceData struct {
+	// Namespace of the objective.
+	Namespace string
+	// Name of the objective.
+	Name string
+}
+
+// equalRuleGroups returns true if the rule groups are the same.
+// Durations are compared by their value, as the ruler formats them differently, like 2m for 2m0s.
+func equalRuleGroups(a, b monitoringv1.RuleGroup) bool {
+	return equality.Semantic.DeepEqual(normalizeRuleGroup(a), normalizeRuleGroup(b))
+}
+
+func normalizeRuleGroup(group monitoringv1.RuleGroup) monitoringv1.RuleGroup {
+	group = *group.DeepCopy()
+	group.Interval = normalizeDuration(group.Interval)
+	for i := range group.Rules {
+		group.Rules[i].For = normalizeDuration(group.Rules[i].For)
+	}
+	return group
+}
+
+func normalizeDuration(d *monitoringv1.Duration) *monitoringv1.Duration {
+	if d == nil {
+		return nil
+	}
+	parsed, err := model.ParseDuration(string(*d))
+	if err != nil {
+		return d
+	}
+	normalized := monitoringv1.Duration(parsed.String())
+	return &normalized
+}
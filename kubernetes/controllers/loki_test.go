@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestLokiRuler(t *testing.T) {
+	type request struct {
+		method string
+		path   string
+		body   string
+	}
+	var requests []request
+
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		requests = append(requests, request{method: r.Method, path: r.URL.Path, body: string(body)})
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL + "/prefix")
+	require.NoError(t, err)
+	ruler := &LokiRuler{URL: u}
+
+	err = ruler.SetRuleGroup(context.Background(), "monitoring", monitoringv1.RuleGroup{
+		Name:     "http-errors",
+		Interval: monitoringDuration("30s"),
+		Rules: []monitoringv1.Rule{{
+			Record: "http_requests:rate5m",
+			Expr:   intstr.FromString(`sum(rate({job="http"} |= "error" [5m]))`),
+		}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []request{{
+		method: http.MethodPost,
+		path:   "/prefix/loki/api/v1/rules/monitoring",
+		body: `interval: 30s
+name: http-errors
+rules:
+- expr: sum(rate({job="http"} |= "error" [5m]))
+  record: http_requests:rate5m
+`,
+	}}, requests)
+
+	requests = nil
+	status = http.StatusNotFound
+	require.NoError(t, ruler.DeleteRuleGroup(context.Background(), "monitoring", "http-errors"))
+	require.Equal(t, []request{{
+		method: http.MethodDelete,
+		path:   "/prefix/loki/api/v1/rules/monitoring/http-errors",
+	}}, requests)
+
+	status = http.StatusInternalServerError
+	err = ruler.DeleteRuleGroup(context.Background(), "monitoring", "http-errors")
+	require.EqualError(t, err, "loki ruler returned 500 Internal Server Error")
+}
+
+func TestLokiRuler_GetRuleGroup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodGet, r.Method)
+		if r.URL.Path != "/loki/api/v1/rules/monitoring/http-errors" {
+			http.Error(w, "no rule groups found", http.StatusNotFound)
+			return
+		}
+		_, _ = io.WriteString(w, `name: http-errors
+interval: 30s
+rules:
+- alert: HTTPErrors
+  expr: sum(rate({job="http"} |= "error" [5m])) > 1
+  for: 2m
+`)
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	ruler := &LokiRuler{URL: u}
+
+	group, err := ruler.GetRuleGroup(context.Background(), "monitoring", "missing")
+	require.NoError(t, err)
+	require.Nil(t, group)
+
+	group, err = ruler.GetRuleGroup(context.Background(), "monitoring", "http-errors")
+	require.NoError(t, err)
+
+	expected := monitoringv1.RuleGroup{
+		Name:     "http-errors",
+		Interval: monitoringDuration("30s"),
+		Rules: []monitoringv1.Rule{{
+			Alert: "HTTPErrors",
+			Expr:  intstr.FromString(`sum(rate({job="http"} |= "error" [5m])) > 1`),
+			For:   monitoringDuration("2m0s"),
+		}},
+	}
+	require.True(t, equalRuleGroups(*group, expected))
+
+	expected.Rules[0].For = monitoringDuration("5m")
+	require.False(t, equalRuleGroups(*group, expected))
+}
+
+func TestLokiRuler_WithCredentials(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	ruler := &LokiRuler{URL: u}
+
+	require.NoError(t, ruler.DeleteRuleGroup(context.Background(), "monitoring", "http-errors"))
+	require.Empty(t, header.Get("X-Scope-OrgID"))
+	require.Empty(t, header.Get("Authorization"))
+
+	tenant := ruler.WithCredentials(lokiCredentialsFromSecret(corev1.Secret{Data: map[string][]byte{
+		"tenant":   []byte("team-a"),
+		"username": []byte("team-a"),
+		"password": []byte("secret"),
+	}}))
+	require.NoError(t, tenant.DeleteRuleGroup(context.Background(), "monitoring", "http-errors"))
+	require.Equal(t, "team-a", header.Get("X-Scope-OrgID"))
+	username, password, ok := (&http.Request{Header: header}).BasicAuth()
+	require.True(t, ok)
+	require.Equal(t, "team-a", username)
+	require.Equal(t, "secret", password)
+
+	token := ruler.WithCredentials(LokiCredentials{Token: "api-key", Username: "ignored"})
+	require.NoError(t, token.DeleteRuleGroup(context.Background(), "monitoring", "http-errors"))
+	require.Empty(t, header.Get("X-Scope-OrgID"))
+	require.Equal(t, "Bearer api-key", header.Get("Authorization"))
+
+	// The original ruler is unchanged.
+	require.NoError(t, ruler.DeleteRuleGroup(context.Background(), "monitoring", "http-errors"))
+	require.Empty(t, header.Get("Authorization"))
+
+	// Headers take precedence over the credentials.
+	ruler.Headers = map[string]string{"X-Scope-OrgID": "override", "X-Gateway": "pyrra"}
+	require.NoError(t, ruler.WithCredentials(LokiCredentials{Tenant: "team-a"}).DeleteRuleGroup(context.Background(), "monitoring", "http-errors"))
+	require.Equal(t, "override", header.Get("X-Scope-OrgID"))
+	require.Equal(t, "pyrra", header.Get("X-Gateway"))
+}
+
+func TestLokiRuler_Backoff(t *testing.T) {
+	var attempts int
+	status := []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusAccepted}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		// Retried requests send the whole body again.
+		require.Contains(t, string(body), "name: http-errors")
+		w.WriteHeader(status[attempts])
+		attempts++
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	ruler := &LokiRuler{URL: u, Backoff: wait.Backoff{Duration: time.Millisecond, Factor: 2, Steps: 2}}
+
+	failures := testutil.ToFloat64(lokiRulerWriteFailures.WithLabelValues("set"))
+	require.NoError(t, ruler.SetRuleGroup(context.Background(), "monitoring", monitoringv1.RuleGroup{Name: "http-errors"}))
+	require.Equal(t, 3, attempts)
+	require.Equal(t, failures, testutil.ToFloat64(lokiRulerWriteFailures.WithLabelValues("set")))
+
+	// Client errors aren't retried.
+	attempts = 0
+	status = []int{http.StatusBadRequest}
+	err = ruler.SetRuleGroup(context.Background(), "monitoring", monitoringv1.RuleGroup{Name: "http-errors"})
+	require.EqualError(t, err, "loki ruler returned 400 Bad Request")
+	require.Equal(t, 1, attempts)
+	require.Equal(t, failures+1, testutil.ToFloat64(lokiRulerWriteFailures.WithLabelValues("set")))
+
+	// Writes fail once the retries of the backoff are exhausted.
+	attempts = 0
+	status = []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}
+	err = ruler.SetRuleGroup(context.Background(), "monitoring", monitoringv1.RuleGroup{Name: "http-errors"})
+	require.EqualError(t, err, "loki ruler returned 502 Bad Gateway")
+	require.Equal(t, 3, attempts)
+	require.Equal(t, failures+2, testutil.ToFloat64(lokiRulerWriteFailures.WithLabelValues("set")))
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var (
+	lokiRulerConfigGeneration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "pyrra_loki_ruler_config_generation",
+		Help: "How often the Loki ruler config was loaded from its Secret since the operator started, 0 while there's none.",
+	})
+	lokiRulerConfigSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "pyrra_loki_ruler_config_last_reload_successful",
+		Help: "Whether the last load of the Loki ruler config from its Secret succeeded.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(lokiRulerConfigGeneration, lokiRulerConfigSuccess)
+}
+
+// LokiRulerConfig reads the URL and credentials of the Loki ruler of objectives without a destination from a Secret.
+// The ruler is rebuilt as the Secret changes, so the operator doesn't need to be restarted for a new endpoint or credentials.
+// Its url key is the URL of the ruler, its tenant, token, username and password keys are the credentials,
+// like the ones of the LokiCredentialsSecret.
+type LokiRulerConfig struct {
+	Secret types.NamespacedName
+	// Base has the client, headers and backoff of the ruler, its URL is the Secret's.
+	Base *LokiRuler
+
+	mu              sync.Mutex
+	resourceVersion string
+	ruler           *LokiRuler
+}
+
+// lokiRuler returns the ruler of the Secret's config, nil if there's no such Secret.
+// The ruler is only rebuilt if the Secret changed since it was last read.
+func (c *LokiRulerConfig) lokiRuler(ctx context.Context, reader client.Reader) (*LokiRuler, error) {
+	var secret corev1.Secret
+	err := reader.Get(ctx, c.Secret, &secret)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get loki ruler config: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if apierrors.IsNotFound(err) {
+		c.resourceVersion, c.ruler = "", nil
+		return nil, nil
+	}
+	if c.ruler != nil && c.resourceVersion == secret.GetResourceVersion() {
+		return c.ruler, nil
+	}
+
+	ruler, err := c.fromSecret(secret)
+	if err != nil {
+		lokiRulerConfigSuccess.Set(0)
+		return nil, err
+	}
+	c.resourceVersion, c.ruler = secret.GetResourceVersion(), ruler
+	lokiRulerConfigGeneration.Inc()
+	lokiRulerConfigSuccess.Set(1)
+	return ruler, nil
+}
+
+// current returns the ruler of the config last read, nil if there's none.
+func (c *LokiRulerConfig) current() *LokiRuler {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ruler
+}
+
+func (c *LokiRulerConfig) fromSecret(secret corev1.Secret) (*LokiRuler, error) {
+	raw := string(secret.Data["url"])
+	if raw == "" {
+		return nil, fmt.Errorf("loki ruler config %s has no url", c.Secret)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("loki ruler config %s has an invalid url: %w", c.Secret, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("loki ruler config %s url must be an http or https URL", c.Secret)
+	}
+
+	var ruler LokiRuler
+	if c.Base != nil {
+		ruler = *c.Base
+	}
+	ruler.URL = u
+	ruler.credentials = lokiCredentialsFromSecret(secret)
+	return &ruler, nil
+}
+
+// defaultLokiRuler returns the Loki ruler of objectives without a destination,
+// the one of the LokiRulerConfig while its Secret exists and the LokiRuler otherwise.
+func (r *ServiceLevelObjectiveReconciler) defaultLokiRuler(ctx context.Context) (*LokiRuler, error) {
+	if r.LokiRulerConfig != nil {
+		ruler, err := r.LokiRulerConfig.lokiRuler(ctx, r.Client)
+		if err != nil || ruler != nil {
+			return ruler, err
+		}
+	}
+	return r.LokiRuler, nil
+}
+
+// lokiObjectivesForConfigSecret returns the requests of all Loki objectives, if the Secret is the one of the LokiRulerConfig,
+// so their rule groups are pushed to the ruler of the changed config.
+func (r *ServiceLevelObjectiveReconciler) lokiObjectivesForConfigSecret(ctx context.Context, secret client.Object) []reconcile.Request {
+	if client.ObjectKeyFromObject(secret) != r.LokiRulerConfig.Secret {
+		return nil
+	}
+	return r.lokiObjectivesInNamespace(ctx, "")
+}
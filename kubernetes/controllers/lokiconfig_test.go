@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func TestLokiRulerConfig(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	var tenants []string
+	newRuler := func() (*fakeLokiRuler, *httptest.Server) {
+		ruler := &fakeLokiRuler{namespaces: map[string][]monitoringv1.RuleGroup{}}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				tenants = append(tenants, r.Header.Get("X-Scope-OrgID"))
+			}
+			ruler.ServeHTTP(w, r)
+		}))
+		return ruler, server
+	}
+	oldRuler, oldServer := newRuler()
+	defer oldServer.Close()
+	newRulerFake, newServer := newRuler()
+	defer newServer.Close()
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+	objective.Annotations = map[string]string{LokiRulerAnnotation: lokiRulerValue}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "pyrra", Name: "loki-ruler"},
+		Data: map[string][]byte{
+			"url":    []byte(oldServer.URL),
+			"tenant": []byte("team-a"),
+		},
+	}
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective, secret).
+		WithStatusSubresource(objective).
+		Build()
+
+	r := &ServiceLevelObjectiveReconciler{
+		Client: c,
+		Logger: kitlog.NewNopLogger(),
+		LokiRulerConfig: &LokiRulerConfig{
+			Secret: types.NamespacedName{Namespace: "pyrra", Name: "loki-ruler"},
+			Base:   &LokiRuler{Headers: map[string]string{"X-Source": "pyrra"}},
+		},
+	}
+	require.True(t, r.lokiEnabled())
+	require.Empty(t, r.lokiRulers())
+
+	generation := testutil.ToFloat64(lokiRulerConfigGeneration)
+	reconcileObjective := func() error {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)})
+		return err
+	}
+
+	require.NoError(t, reconcileObjective())
+	require.Len(t, oldRuler.namespaces["monitoring"], 2)
+	require.Equal(t, []string{"team-a", "team-a"}, tenants)
+	require.Equal(t, generation+1, testutil.ToFloat64(lokiRulerConfigGeneration))
+	require.Equal(t, 1.0, testutil.ToFloat64(lokiRulerConfigSuccess))
+	require.Equal(t, "pyrra", r.lokiRulers()[0].Headers["X-Source"])
+
+	// The unchanged config isn't loaded again.
+	require.NoError(t, reconcileObjective())
+	require.Equal(t, generation+1, testutil.ToFloat64(lokiRulerConfigGeneration))
+
+	// Changes of the Secret requeue all Loki objectives and their rule groups are pushed to the new ruler.
+	require.Equal(t, []reconcile.Request{{NamespacedName: client.ObjectKeyFromObject(objective)}}, r.lokiObjectivesForConfigSecret(context.Background(), secret))
+	require.Empty(t, r.lokiObjectivesForConfigSecret(context.Background(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "loki-ruler"},
+	}))
+	tenants = nil
+	secret.Data = map[string][]byte{
+		"url":    []byte(newServer.URL),
+		"tenant": []byte("team-b"),
+	}
+	require.NoError(t, c.Update(context.Background(), secret))
+	require.NoError(t, reconcileObjective())
+	require.Len(t, newRulerFake.namespaces["monitoring"], 2)
+	require.Equal(t, []string{"team-b", "team-b"}, tenants)
+	require.Equal(t, generation+2, testutil.ToFloat64(lokiRulerConfigGeneration))
+	require.Equal(t, newServer.URL, r.lokiRulers()[0].URL.String())
+
+	// Invalid configs fail the reconciles until they're fixed.
+	secret.Data = map[string][]byte{"url": []byte("loki:3100")}
+	require.NoError(t, c.Update(context.Background(), secret))
+	require.ErrorContains(t, reconcileObjective(), "url must be an http or https URL")
+	require.Equal(t, 0.0, testutil.ToFloat64(lokiRulerConfigSuccess))
+	require.Equal(t, generation+2, testutil.ToFloat64(lokiRulerConfigGeneration))
+
+	// Without the Secret the reconciler's ruler is used.
+	require.NoError(t, c.Delete(context.Background(), secret))
+	ruler, err := r.defaultLokiRuler(context.Background())
+	require.NoError(t, err)
+	require.Nil(t, ruler)
+	r.LokiRuler = &LokiRuler{}
+	ruler, err = r.defaultLokiRuler(context.Background())
+	require.NoError(t, err)
+	require.Same(t, r.LokiRuler, ruler)
+}
@@ -0,0 +1,312 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/yaml"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+// lokiRuleGroups holds the hashes of the rule groups in the Loki ruler's namespaces,
+// so reconciles only push rule groups that changed instead of requesting each of them from the ruler.
+// A ruler namespace is listed with a single request when it's first needed and again by the LokiRuleSyncer.
+type lokiRuleGroups struct {
+	mu         sync.Mutex
+	namespaces map[lokiRulerNamespaceKey]map[string]string
+}
+
+// lokiRulerNamespaceKey is a ruler namespace of a tenant in the ruler at the URL.
+type lokiRulerNamespaceKey struct {
+	url       string
+	tenant    string
+	namespace string
+}
+
+func (l *LokiRuler) namespaceKey(namespace string) lokiRulerNamespaceKey {
+	return lokiRulerNamespaceKey{url: l.URL.String(), tenant: l.credentials.Tenant, namespace: namespace}
+}
+
+// hashes returns the hashes of the rule groups in the ruler namespace by their name,
+// listing the ruler namespace if it isn't known yet.
+func (g *lokiRuleGroups) hashes(ctx context.Context, ruler *LokiRuler, namespace string) (map[string]string, error) {
+	key := ruler.namespaceKey(namespace)
+
+	g.mu.Lock()
+	hashes, ok := g.namespaces[key]
+	g.mu.Unlock()
+	if ok {
+		return maps.Clone(hashes), nil
+	}
+
+	groups, err := ruler.ListRuleGroups(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list loki rule groups: %w", err)
+	}
+	hashes = make(map[string]string, len(groups))
+	for _, group := range groups {
+		hashes[group.Name] = ruleGroupHash(group)
+	}
+	g.replace(key, hashes)
+	return maps.Clone(hashes), nil
+}
+
+// replace sets the hashes of all rule groups in the ruler namespace.
+func (g *lokiRuleGroups) replace(key lokiRulerNamespaceKey, hashes map[string]string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.namespaces == nil {
+		g.namespaces = map[lokiRulerNamespaceKey]map[string]string{}
+	}
+	g.namespaces[key] = maps.Clone(hashes)
+}
+
+// set records the hash of a rule group written to a known ruler namespace. Deleted rule groups have no hash.
+func (g *lokiRuleGroups) set(key lokiRulerNamespaceKey, name, hash string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	hashes, ok := g.namespaces[key]
+	if !ok {
+		return
+	}
+	if hash == "" {
+		delete(hashes, name)
+		return
+	}
+	hashes[name] = hash
+}
+
+// forget drops the ruler namespace, whose rule groups are unknown after failed writes, so it's listed again.
+func (g *lokiRuleGroups) forget(key lokiRulerNamespaceKey) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.namespaces, key)
+}
+
+// ruleGroupHash returns a hash of the rule group's content.
+// Like equalRuleGroups, durations are hashed by their value as the ruler formats them differently.
+func ruleGroupHash(group monitoringv1.RuleGroup) string {
+	b, err := yaml.Marshal(normalizeRuleGroup(group))
+	if err != nil {
+		// Rule groups always marshal, they're read from and written to the ruler as YAML.
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// lokiRuleGroupObjective returns the name of the objective the rule group was generated for,
+// or false if it isn't one of Pyrra's rule groups.
+// They're named after the objective with one of slo.RuleGroupSuffixes and all their rules have the objective's slo label.
+func lokiRuleGroupObjective(group monitoringv1.RuleGroup) (string, bool) {
+	if len(group.Rules) == 0 {
+		return "", false
+	}
+	for _, suffix := range slo.RuleGroupSuffixes {
+		if !strings.HasSuffix(group.Name, suffix) {
+			continue
+		}
+		name := strings.TrimSuffix(group.Name, suffix)
+		if name != "" && allRulesOfObjective(group, name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// allRulesOfObjective returns true if all rules of the rule group have the objective's slo label.
+func allRulesOfObjective(group monitoringv1.RuleGroup, name string) bool {
+	for _, rule := range group.Rules {
+		if rule.Labels["slo"] != name {
+			return false
+		}
+	}
+	return true
+}
+
+// LokiRuleSyncer periodically compares the rule groups in the Loki ruler with the objectives', with a single request per ruler namespace.
+// It pushes rule groups that drifted, like ones changed or lost by the ruler,
+// and deletes the rule groups of objectives that no longer exist from the ruler namespaces of the other objectives.
+// Reconciles only push the rule groups of objectives that changed since the ruler namespace was last listed.
+type LokiRuleSyncer struct {
+	Reconciler *ServiceLevelObjectiveReconciler
+	Logger     kitlog.Logger
+	Interval   time.Duration
+}
+
+var (
+	_ manager.Runnable               = &LokiRuleSyncer{}
+	_ manager.LeaderElectionRunnable = &LokiRuleSyncer{}
+)
+
+// NeedLeaderElection makes sure only one replica writes to the ruler.
+func (s *LokiRuleSyncer) NeedLeaderElection() bool {
+	return true
+}
+
+func (s *LokiRuleSyncer) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.sync(ctx); err != nil {
+			level.Warn(s.Logger).Log("msg", "failed to sync loki rule groups", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// lokiRulerNamespace is a ruler namespace with the rule groups of the objectives in it.
+type lokiRulerNamespace struct {
+	ruler      *LokiRuler
+	namespace  string
+	groups     map[string]monitoringv1.RuleGroup
+	objectives map[string]bool
+}
+
+// sync syncs the ruler namespaces of all objectives evaluated by Loki.
+// Ruler namespaces that fail to sync don't stop the others from syncing.
+func (s *LokiRuleSyncer) sync(ctx context.Context) error {
+	r := s.Reconciler
+
+	var list pyrrav1alpha1.ServiceLevelObjectiveList
+	if err := r.List(ctx, &list); err != nil {
+		return fmt.Errorf("failed to list objectives: %w", err)
+	}
+
+	namespaces := map[lokiRulerNamespaceKey]*lokiRulerNamespace{}
+	for _, kubeObjective := range list.Items {
+		if !IsLokiObjective(kubeObjective.GetAnnotations()) {
+			continue
+		}
+		req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&kubeObjective)}
+
+		destination, err := r.destination(ctx, kubeObjective)
+		if err != nil || destination.LokiRuler == nil {
+			// The rules of objectives with unknown destinations are left alone, the reconciler reports them.
+			continue
+		}
+		ruler, err := r.lokiRuler(ctx, destination.LokiRuler, req.Namespace)
+		if err != nil {
+			return err
+		}
+		rulerNamespace, err := r.lokiRulerNamespace(req)
+		if err != nil {
+			return err
+		}
+
+		key := ruler.namespaceKey(rulerNamespace)
+		ns, ok := namespaces[key]
+		if !ok {
+			ns = &lokiRulerNamespace{
+				ruler:      ruler,
+				namespace:  rulerNamespace,
+				groups:     map[string]monitoringv1.RuleGroup{},
+				objectives: map[string]bool{},
+			}
+			namespaces[key] = ns
+		}
+		// The rule groups of invalid objectives are left alone, the reconciler reports them.
+		ns.objectives[req.Name] = true
+
+		groups, err := r.ruleGroups(ctx, kubeObjective)
+		if err != nil {
+			continue
+		}
+		for _, group := range groups {
+			ns.groups[group.Name] = r.LokiGroupIntervals.apply(kubeObjective, group)
+		}
+	}
+
+	for key, ns := range namespaces {
+		if err := s.syncNamespace(ctx, key, ns); err != nil {
+			r.lokiRuleGroups.forget(key)
+			level.Warn(s.Logger).Log("msg", "failed to sync loki ruler namespace", "namespace", ns.namespace, "err", err)
+		}
+	}
+	return nil
+}
+
+// syncNamespace lists the rule groups of the ruler namespace and only writes the ones that differ from the objectives'.
+func (s *LokiRuleSyncer) syncNamespace(ctx context.Context, key lokiRulerNamespaceKey, ns *lokiRulerNamespace) error {
+	existing, err := ns.ruler.ListRuleGroups(ctx, ns.namespace)
+	if err != nil {
+		return fmt.Errorf("failed to list loki rule groups: %w", err)
+	}
+
+	hashes := make(map[string]string, len(existing))
+	for _, group := range existing {
+		hashes[group.Name] = ruleGroupHash(group)
+		if _, ok := ns.groups[group.Name]; ok {
+			continue
+		}
+		objective, ok := lokiRuleGroupObjective(group)
+		if !ok || ns.objectives[objective] {
+			continue
+		}
+
+		level.Info(s.Logger).Log("msg", "deleting loki rule group of deleted objective", "namespace", ns.namespace, "name", group.Name)
+		if err := ns.ruler.DeleteRuleGroup(ctx, ns.namespace, group.Name); err != nil {
+			return fmt.Errorf("failed to delete loki rule group %s: %w", group.Name, err)
+		}
+		delete(hashes, group.Name)
+	}
+
+	names := make([]string, 0, len(ns.groups))
+	for name := range ns.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		hash := ruleGroupHash(ns.groups[name])
+		if hashes[name] == hash {
+			continue
+		}
+
+		level.Info(s.Logger).Log("msg", "updating drifted loki rule group", "namespace", ns.namespace, "name", name)
+		if err := ns.ruler.SetRuleGroup(ctx, ns.namespace, ns.groups[name]); err != nil {
+			return fmt.Errorf("failed to update loki rule group %s: %w", name, err)
+		}
+		hashes[name] = hash
+	}
+
+	s.Reconciler.lokiRuleGroups.replace(key, hashes)
+	return nil
+}
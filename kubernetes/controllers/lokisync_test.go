@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// fakeLokiRuler keeps the rule groups of the ruler namespaces in memory, like the rules API of the Loki ruler.
+type fakeLokiRuler struct {
+	mu         sync.Mutex
+	namespaces map[string][]monitoringv1.RuleGroup
+	requests   []string
+}
+
+func (f *fakeLokiRuler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	elem := strings.Split(strings.TrimPrefix(r.URL.Path, "/loki/api/v1/rules/"), "/")
+	namespace := elem[0]
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	switch {
+	case r.Method == http.MethodGet && len(elem) == 1:
+		if len(f.namespaces[namespace]) == 0 {
+			http.Error(w, "no rule groups found", http.StatusNotFound)
+			return
+		}
+		body, _ := yaml.Marshal(map[string][]monitoringv1.RuleGroup{namespace: f.namespaces[namespace]})
+		_, _ = w.Write(body)
+		return
+	case r.Method == http.MethodPost:
+		body, _ := io.ReadAll(r.Body)
+		var group monitoringv1.RuleGroup
+		if err := yaml.Unmarshal(body, &group); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.delete(namespace, group.Name)
+		f.namespaces[namespace] = append(f.namespaces[namespace], group)
+	case r.Method == http.MethodDelete && len(elem) == 2:
+		f.delete(namespace, elem[1])
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (f *fakeLokiRuler) delete(namespace, name string) {
+	groups := f.namespaces[namespace][:0]
+	for _, group := range f.namespaces[namespace] {
+		if group.Name != name {
+			groups = append(groups, group)
+		}
+	}
+	f.namespaces[namespace] = groups
+}
+
+func (f *fakeLokiRuler) reset() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	requests := f.requests
+	f.requests = nil
+	return requests
+}
+
+func (f *fakeLokiRuler) groupNames(namespace string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var names []string
+	for _, group := range f.namespaces[namespace] {
+		names = append(names, group.Name)
+	}
+	return names
+}
+
+func TestLokiRuleGroupObjective(t *testing.T) {
+	objective := httpSLO.DeepCopy()
+	groups, err := makeRuleGroups(*objective, true)
+	require.NoError(t, err)
+	require.Len(t, groups, 3)
+	for _, group := range groups {
+		name, ok := lokiRuleGroupObjective(group)
+		require.True(t, ok, group.Name)
+		require.Equal(t, "http", name)
+	}
+
+	// Objectives can be named like the suffixes of other objectives' rule groups.
+	name, ok := lokiRuleGroupObjective(monitoringv1.RuleGroup{
+		Name:  "http-sla",
+		Rules: []monitoringv1.Rule{{Record: "pyrra_sla_target", Labels: map[string]string{"slo": "http-sla"}}},
+	})
+	require.True(t, ok)
+	require.Equal(t, "http-sla", name)
+
+	_, ok = lokiRuleGroupObjective(monitoringv1.RuleGroup{
+		Name:  "http-increase",
+		Rules: []monitoringv1.Rule{{Record: "http_requests:rate5m", Expr: intstr.FromString(`sum(rate({job="http"}[5m]))`)}},
+	})
+	require.False(t, ok)
+	_, ok = lokiRuleGroupObjective(monitoringv1.RuleGroup{Name: "http"})
+	require.False(t, ok)
+}
+
+func TestServiceLevelObjectiveReconciler_LokiRuleGroupHashes(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+
+	ruler := &fakeLokiRuler{namespaces: map[string][]monitoringv1.RuleGroup{}}
+	server := httptest.NewServer(ruler)
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+	objective.Annotations = map[string]string{LokiRulerAnnotation: lokiRulerValue}
+	other := objective.DeepCopy()
+	other.Name = "grpc"
+	other.UID = "456"
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective, other).
+		WithStatusSubresource(objective, other).
+		Build()
+
+	r := &ServiceLevelObjectiveReconciler{
+		Client:    c,
+		Logger:    kitlog.NewNopLogger(),
+		LokiRuler: &LokiRuler{URL: u},
+	}
+	reconcile := func(obj client.Object) {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+		require.NoError(t, err)
+	}
+
+	// The ruler namespace is listed once for all objectives in it.
+	reconcile(objective)
+	reconcile(other)
+	require.Equal(t, []string{
+		"GET /loki/api/v1/rules/monitoring",
+		"POST /loki/api/v1/rules/monitoring",
+		"POST /loki/api/v1/rules/monitoring",
+		"POST /loki/api/v1/rules/monitoring",
+		"POST /loki/api/v1/rules/monitoring",
+	}, ruler.reset())
+
+	// Unchanged objectives don't request the ruler at all.
+	reconcile(objective)
+	reconcile(other)
+	require.Empty(t, ruler.reset())
+
+	// Only the changed rule group of a changed objective is pushed.
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(objective), objective))
+	objective.Spec.Target = "99.9"
+	require.NoError(t, c.Update(context.Background(), objective))
+	reconcile(objective)
+	require.Equal(t, []string{"POST /loki/api/v1/rules/monitoring"}, ruler.reset())
+}
+
+func TestLokiRuleSyncer(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+	objective.Annotations = map[string]string{LokiRulerAnnotation: lokiRulerValue}
+
+	groups, err := makeRuleGroups(*objective, false)
+	require.NoError(t, err)
+	deleted := objective.DeepCopy()
+	deleted.Name = "deleted"
+	deleted.Spec.SLA = &pyrrav1alpha1.SLA{Target: "99"}
+	orphaned, err := makeRuleGroups(*deleted, false)
+	require.NoError(t, err)
+	orphanedSLA := orphaned[len(orphaned)-1]
+	require.Equal(t, "deleted-sla", orphanedSLA.Name)
+
+	// The burn rate rule group drifted and the objective of another one is gone.
+	drifted := *groups[1].DeepCopy()
+	drifted.Rules = drifted.Rules[:1]
+	foreign := monitoringv1.RuleGroup{
+		Name:  "team-rules",
+		Rules: []monitoringv1.Rule{{Record: "http_requests:rate5m", Expr: intstr.FromString(`sum(rate({job="http"}[5m]))`)}},
+	}
+	ruler := &fakeLokiRuler{namespaces: map[string][]monitoringv1.RuleGroup{
+		"monitoring": {groups[0], drifted, orphaned[0], orphanedSLA, foreign},
+	}}
+	server := httptest.NewServer(ruler)
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective).
+		Build()
+
+	r := &ServiceLevelObjectiveReconciler{
+		Client:    c,
+		Logger:    kitlog.NewNopLogger(),
+		LokiRuler: &LokiRuler{URL: u},
+	}
+	syncer := &LokiRuleSyncer{Reconciler: r, Logger: kitlog.NewNopLogger()}
+
+	require.NoError(t, syncer.sync(context.Background()))
+	require.Equal(t, []string{
+		"GET /loki/api/v1/rules/monitoring",
+		"DELETE /loki/api/v1/rules/monitoring/deleted-increase",
+		"DELETE /loki/api/v1/rules/monitoring/deleted-sla",
+		"POST /loki/api/v1/rules/monitoring",
+	}, ruler.reset())
+	require.ElementsMatch(t, []string{"http-increase", "team-rules", "http"}, ruler.groupNames("monitoring"))
+
+	// Rule groups that are in sync aren't written.
+	require.NoError(t, syncer.sync(context.Background()))
+	require.Equal(t, []string{"GET /loki/api/v1/rules/monitoring"}, ruler.reset())
+
+	// Reconciles use the rule groups listed by the syncer.
+	_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)})
+	require.NoError(t, err)
+	require.Empty(t, ruler.reset())
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+// The rules label of pyrra_slo_info says where the rules of an objective are written to, comma-separated if several.
+const (
+	rulesPrometheusRule = "prometheusrule"
+	rulesConfigMap      = "configmap"
+	rulesThanosRuler    = "thanos-ruler"
+	rulesLokiRuler      = "loki-ruler"
+)
+
+var (
+	objectiveTargetDesc = prometheus.NewDesc(
+		"pyrra_slo_objective_target",
+		"The target of the objective, like 0.995 for 99.5%.",
+		[]string{"namespace", "slo"}, nil,
+	)
+	objectiveWindowDesc = prometheus.NewDesc(
+		"pyrra_slo_window_seconds",
+		"The window of the objective in seconds.",
+		[]string{"namespace", "slo"}, nil,
+	)
+	objectiveInfoDesc = prometheus.NewDesc(
+		"pyrra_slo_info",
+		"Information about the objective, always 1. The rules label says where its rules are written to, the team label who owns it.",
+		[]string{"namespace", "slo", "indicator", "rules", "team"}, nil,
+	)
+
+	reconciledObjectives = &objectiveMetrics{}
+
+	driftedObjects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pyrra_generated_object_drift_total",
+		Help: "How often generated objects of the objective were found changed by others, with --detect-drift.",
+	}, []string{"namespace", "slo", "kind"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(reconciledObjectives, driftedObjects)
+}
+
+// objectiveMetrics exports the objectives reconciled by the operator as metrics,
+// so dashboards and alerts across objectives, like ones on objectives missing their rules,
+// don't need access to the Kubernetes API.
+type objectiveMetrics struct {
+	mu         sync.Mutex
+	objectives map[types.NamespacedName]objectiveMetric
+}
+
+type objectiveMetric struct {
+	target    float64
+	window    time.Duration
+	indicator string
+	rules     string
+	team      string
+}
+
+var _ prometheus.Collector = &objectiveMetrics{}
+
+func (m *objectiveMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- objectiveTargetDesc
+	ch <- objectiveWindowDesc
+	ch <- objectiveInfoDesc
+}
+
+func (m *objectiveMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name, o := range m.objectives {
+		ch <- prometheus.MustNewConstMetric(objectiveTargetDesc, prometheus.GaugeValue, o.target, name.Namespace, name.Name)
+		ch <- prometheus.MustNewConstMetric(objectiveWindowDesc, prometheus.GaugeValue, o.window.Seconds(), name.Namespace, name.Name)
+		ch <- prometheus.MustNewConstMetric(objectiveInfoDesc, prometheus.GaugeValue, 1, name.Namespace, name.Name, o.indicator, o.rules, o.team)
+	}
+}
+
+// set exports the objective with its rules written to rules.
+func (m *objectiveMetrics) set(name types.NamespacedName, objective slo.Objective, rules string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.objectives == nil {
+		m.objectives = map[types.NamespacedName]objectiveMetric{}
+	}
+	m.objectives[name] = objectiveMetric{
+		target:    objective.Target,
+		window:    time.Duration(objective.Window),
+		indicator: indicatorName(objective),
+		rules:     rules,
+		// The team of the owner, which is added to the objective's alerts.
+		team: objective.Alerting.Labels["team"],
+	}
+}
+
+// delete stops exporting the deleted objective.
+func (m *objectiveMetrics) delete(name types.NamespacedName) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objectives, name)
+}
+
+// indicatorName returns the name of the objective's indicator like the field of the ServiceLevelObjective's indicator.
+func indicatorName(objective slo.Objective) string {
+	if objective.Indicator.Quantile != nil {
+		// Quantile indicators are evaluated as the bool gauge their rules record.
+		return "quantile"
+	}
+
+	switch objective.IndicatorType() {
+	case slo.Ratio:
+		return "ratio"
+	case slo.Latency:
+		return "latency"
+	case slo.LatencyNative:
+		return "latencyNative"
+	case slo.BoolGauge:
+		return "bool_gauge"
+	case slo.Expression:
+		return "expression"
+	case slo.Logs:
+		return "logs"
+	case slo.Composite:
+		return "composite"
+	default:
+		return "unknown"
+	}
+}
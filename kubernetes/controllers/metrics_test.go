@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func TestObjectiveMetrics(t *testing.T) {
+	objective, err := httpSLO.Internal()
+	require.NoError(t, err)
+
+	m := &objectiveMetrics{}
+	m.set(types.NamespacedName{Namespace: "monitoring", Name: "http"}, objective, rulesPrometheusRule)
+	m.set(types.NamespacedName{Namespace: "logs", Name: "http"}, objective, rulesLokiRuler)
+	m.delete(types.NamespacedName{Namespace: "logs", Name: "http"})
+	// Objectives that are deleted without being exported are nothing to delete.
+	m.delete(types.NamespacedName{Namespace: "logs", Name: "grpc"})
+
+	require.NoError(t, testutil.CollectAndCompare(m, strings.NewReader(`
+# HELP pyrra_slo_info Information about the objective, always 1. The rules label says where its rules are written to, the team label who owns it.
+# TYPE pyrra_slo_info gauge
+pyrra_slo_info{indicator="ratio",namespace="monitoring",rules="prometheusrule",slo="http",team=""} 1
+# HELP pyrra_slo_objective_target The target of the objective, like 0.995 for 99.5%.
+# TYPE pyrra_slo_objective_target gauge
+pyrra_slo_objective_target{namespace="monitoring",slo="http"} 0.995
+# HELP pyrra_slo_window_seconds The window of the objective in seconds.
+# TYPE pyrra_slo_window_seconds gauge
+pyrra_slo_window_seconds{namespace="monitoring",slo="http"} 2.4192e+06
+`)))
+}
+
+func TestServiceLevelObjectiveReconciler_Metrics(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "metrics"
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective).
+		WithStatusSubresource(objective).
+		Build()
+
+	r := &ServiceLevelObjectiveReconciler{
+		Client:        c,
+		Logger:        kitlog.NewNopLogger(),
+		ConfigMapMode: true,
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+	exported := func() (objectiveMetric, bool) {
+		reconciledObjectives.mu.Lock()
+		defer reconciledObjectives.mu.Unlock()
+		o, ok := reconciledObjectives.objectives[req.NamespacedName]
+		return o, ok
+	}
+
+	_, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	o, ok := exported()
+	require.True(t, ok)
+	require.Equal(t, 0.995, o.target)
+	require.Equal(t, "ratio", o.indicator)
+	require.Equal(t, rulesConfigMap, o.rules)
+
+	require.NoError(t, c.Delete(context.Background(), objective))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	_, ok = exported()
+	require.False(t, ok)
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// RuleMutator post-processes the rule groups generated for an objective before they're written to any output,
+// like to add labels, rewrite metric names or drop alerts.
+// The mutated rule groups are cached with the generated ones, so mutators only run again as the objective changes.
+type RuleMutator interface {
+	Mutate(ctx context.Context, kubeObjective pyrrav1alpha1.ServiceLevelObjective, groups []monitoringv1.RuleGroup) ([]monitoringv1.RuleGroup, error)
+}
+
+// RuleMutatorFunc is a function used as a RuleMutator.
+type RuleMutatorFunc func(ctx context.Context, kubeObjective pyrrav1alpha1.ServiceLevelObjective, groups []monitoringv1.RuleGroup) ([]monitoringv1.RuleGroup, error)
+
+func (f RuleMutatorFunc) Mutate(ctx context.Context, kubeObjective pyrrav1alpha1.ServiceLevelObjective, groups []monitoringv1.RuleGroup) ([]monitoringv1.RuleGroup, error) {
+	return f(ctx, kubeObjective, groups)
+}
+
+// mutateRuleGroups runs the mutators in order and verifies that they left rule groups that can be written.
+func mutateRuleGroups(ctx context.Context, kubeObjective pyrrav1alpha1.ServiceLevelObjective, groups []monitoringv1.RuleGroup, mutators []RuleMutator) ([]monitoringv1.RuleGroup, error) {
+	for i, m := range mutators {
+		mutated, err := m.Mutate(ctx, kubeObjective, groups)
+		if err != nil {
+			return nil, fmt.Errorf("rule mutator %d failed: %w", i, err)
+		}
+		if err := validateMutatedRuleGroups(mutated); err != nil {
+			return nil, fmt.Errorf("rule mutator %d returned invalid rule groups: %w", i, err)
+		}
+		groups = mutated
+	}
+	return groups, nil
+}
+
+// validateMutatedRuleGroups returns an error if the rule groups have no or duplicate names,
+// or rules that neither record nor alert or have no expression.
+func validateMutatedRuleGroups(groups []monitoringv1.RuleGroup) error {
+	names := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		if g.Name == "" {
+			return fmt.Errorf("rule group without name")
+		}
+		if names[g.Name] {
+			return fmt.Errorf("duplicate rule group %s", g.Name)
+		}
+		names[g.Name] = true
+		for i, rule := range g.Rules {
+			if (rule.Record == "") == (rule.Alert == "") {
+				return fmt.Errorf("rule %d of rule group %s must have either record or alert", i, g.Name)
+			}
+			if rule.Expr == (intstr.IntOrString{}) || strings.TrimSpace(rule.Expr.String()) == "" {
+				return fmt.Errorf("rule %d of rule group %s has no expr", i, g.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// ExecRuleMutator mutates the rule groups with an executable.
+// It gets the objective and its rule groups as JSON on stdin, like {"objective": {...}, "groups": [...]},
+// and prints the mutated rule groups as JSON or YAML to stdout, like {"groups": [...]}.
+// The mutation fails if it exits with a non-zero code, with its stderr as the error.
+type ExecRuleMutator struct {
+	// Command is the path of the executable.
+	Command string
+	// Args are passed to the executable.
+	Args []string
+	// Timeout kills the executable if it runs longer.
+	Timeout time.Duration
+}
+
+// ruleMutatorObjective is the objective as passed to executables.
+type ruleMutatorObjective struct {
+	Namespace   string                                  `json:"namespace"`
+	Name        string                                  `json:"name"`
+	Labels      map[string]string                       `json:"labels,omitempty"`
+	Annotations map[string]string                       `json:"annotations,omitempty"`
+	Spec        pyrrav1alpha1.ServiceLevelObjectiveSpec `json:"spec"`
+}
+
+type ruleMutatorInput struct {
+	Objective ruleMutatorObjective     `json:"objective"`
+	Groups    []monitoringv1.RuleGroup `json:"groups"`
+}
+
+type ruleMutatorOutput struct {
+	Groups []monitoringv1.RuleGroup `json:"groups"`
+}
+
+func (m *ExecRuleMutator) Mutate(ctx context.Context, kubeObjective pyrrav1alpha1.ServiceLevelObjective, groups []monitoringv1.RuleGroup) ([]monitoringv1.RuleGroup, error) {
+	input, err := json.Marshal(ruleMutatorInput{
+		Objective: ruleMutatorObjective{
+			Namespace:   kubeObjective.GetNamespace(),
+			Name:        kubeObjective.GetName(),
+			Labels:      kubeObjective.GetLabels(),
+			Annotations: kubeObjective.GetAnnotations(),
+			Spec:        kubeObjective.Spec,
+		},
+		Groups: groups,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal rule groups: %w", err)
+	}
+
+	if m.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.Timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, m.Command, m.Args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", m.Command, err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", m.Command, err)
+	}
+
+	var output ruleMutatorOutput
+	if err := yaml.UnmarshalStrict(stdout.Bytes(), &output); err != nil {
+		return nil, fmt.Errorf("%s: failed to unmarshal rule groups: %w", m.Command, err)
+	}
+	return output.Groups, nil
+}
@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// companyLabels adds the company label to all rules.
+var companyLabels = RuleMutatorFunc(func(_ context.Context, _ pyrrav1alpha1.ServiceLevelObjective, groups []monitoringv1.RuleGroup) ([]monitoringv1.RuleGroup, error) {
+	for i := range groups {
+		for j := range groups[i].Rules {
+			if groups[i].Rules[j].Labels == nil {
+				groups[i].Rules[j].Labels = map[string]string{}
+			}
+			groups[i].Rules[j].Labels["company"] = "acme"
+		}
+	}
+	return groups, nil
+})
+
+// withoutAlertsMutator drops all alerts.
+var withoutAlertsMutator = RuleMutatorFunc(func(_ context.Context, _ pyrrav1alpha1.ServiceLevelObjective, groups []monitoringv1.RuleGroup) ([]monitoringv1.RuleGroup, error) {
+	return withoutAlerts(groups), nil
+})
+
+func TestMutateRuleGroups(t *testing.T) {
+	groups, err := makeRuleGroups(httpSLO, false)
+	require.NoError(t, err)
+
+	mutated, err := mutateRuleGroups(context.Background(), httpSLO, groups, []RuleMutator{companyLabels, withoutAlertsMutator})
+	require.NoError(t, err)
+	require.Len(t, mutated, len(groups))
+	for _, g := range mutated {
+		for _, rule := range g.Rules {
+			require.Empty(t, rule.Alert)
+			require.Equal(t, "acme", rule.Labels["company"])
+		}
+	}
+
+	duplicate := RuleMutatorFunc(func(_ context.Context, _ pyrrav1alpha1.ServiceLevelObjective, groups []monitoringv1.RuleGroup) ([]monitoringv1.RuleGroup, error) {
+		return append(groups, groups[0]), nil
+	})
+	_, err = mutateRuleGroups(context.Background(), httpSLO, groups, []RuleMutator{companyLabels, duplicate})
+	require.EqualError(t, err, "rule mutator 1 returned invalid rule groups: duplicate rule group http-increase")
+
+	for _, tc := range []struct {
+		name     string
+		rule     monitoringv1.Rule
+		expected string
+	}{{
+		name:     "recordAndAlert",
+		rule:     monitoringv1.Rule{Record: "foo", Alert: "Foo", Expr: intstr.FromString("vector(1)")},
+		expected: "rule 0 of rule group g must have either record or alert",
+	}, {
+		name:     "noExpr",
+		rule:     monitoringv1.Rule{Record: "foo"},
+		expected: "rule 0 of rule group g has no expr",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.EqualError(t, validateMutatedRuleGroups([]monitoringv1.RuleGroup{{Name: "g", Rules: []monitoringv1.Rule{tc.rule}}}), tc.expected)
+		})
+	}
+}
+
+func TestExecRuleMutator(t *testing.T) {
+	script := func(content string) string {
+		path := filepath.Join(t.TempDir(), "mutator")
+		require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+content), 0o755))
+		return path
+	}
+
+	groups, err := makeRuleGroups(httpSLO, false)
+	require.NoError(t, err)
+
+	// The objective is passed on stdin and the mutated rule groups can be YAML.
+	m := &ExecRuleMutator{
+		Command: script(`grep -q '"objective":{"namespace":"","name":"http"' || exit 3
+printf 'groups:\n- name: %s\n  rules:\n  - record: up:sum\n    expr: sum(up)\n' "$1"
+`),
+		Args:    []string{"mutated"},
+		Timeout: 10 * time.Second,
+	}
+	mutated, err := m.Mutate(context.Background(), httpSLO, groups)
+	require.NoError(t, err)
+	require.Equal(t, []monitoringv1.RuleGroup{{
+		Name:  "mutated",
+		Rules: []monitoringv1.Rule{{Record: "up:sum", Expr: intstr.FromString("sum(up)")}},
+	}}, mutated)
+
+	m = &ExecRuleMutator{Command: script("echo 'unknown team' >&2\nexit 1\n")}
+	_, err = m.Mutate(context.Background(), httpSLO, groups)
+	require.ErrorContains(t, err, "exit status 1: unknown team")
+
+	m = &ExecRuleMutator{Command: script("echo '{\"rules\": []}'\n")}
+	_, err = m.Mutate(context.Background(), httpSLO, groups)
+	require.ErrorContains(t, err, "failed to unmarshal rule groups")
+
+	m = &ExecRuleMutator{Command: script("exec sleep 10\n"), Timeout: 50 * time.Millisecond}
+	_, err = m.Mutate(context.Background(), httpSLO, groups)
+	require.ErrorContains(t, err, "signal: killed")
+}
+
+func TestServiceLevelObjectiveReconciler_RuleMutators(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective).
+		WithStatusSubresource(objective).
+		Build()
+
+	r := &ServiceLevelObjectiveReconciler{
+		Client:       c,
+		Logger:       kitlog.NewNopLogger(),
+		RuleMutators: []RuleMutator{companyLabels, withoutAlertsMutator},
+	}
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)})
+	require.NoError(t, err)
+
+	var rule monitoringv1.PrometheusRule
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(objective), &rule))
+	require.NotEmpty(t, rule.Spec.Groups)
+	for _, g := range rule.Spec.Groups {
+		for _, r := range g.Rules {
+			require.Empty(t, r.Alert)
+			require.Equal(t, "acme", r.Labels["company"])
+		}
+	}
+}
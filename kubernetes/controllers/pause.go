@@ -0,0 +1,101 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+const (
+	// PausedAnnotation pauses objectives like their spec.paused when set to true,
+	// or like spec.paused with spec.deleteAlertsWhilePaused when set to delete-alerts.
+	PausedAnnotation = "pyrra.dev/paused"
+	// pausedDeleteAlerts is the value of the PausedAnnotation that deletes the alerting rules of paused objectives.
+	pausedDeleteAlerts = "delete-alerts"
+)
+
+// isPaused returns whether the objective is paused by its spec or annotation,
+// and whether its alerting rules are deleted while it is.
+func isPaused(kubeObjective pyrrav1alpha1.ServiceLevelObjective) (paused, deleteAlerts bool, err error) {
+	paused, deleteAlerts = kubeObjective.Spec.Paused, kubeObjective.Spec.Paused && kubeObjective.Spec.DeleteAlertsWhilePaused
+	switch annotation, ok := kubeObjective.GetAnnotations()[PausedAnnotation]; {
+	case !ok || annotation == "false":
+	case annotation == "true":
+		paused = true
+	case annotation == pausedDeleteAlerts:
+		paused, deleteAlerts = true, true
+	default:
+		return false, false, invalidObjectiveError{err: fmt.Errorf("%s must be true, false or %s, got %q", PausedAnnotation, pausedDeleteAlerts, annotation)}
+	}
+	return paused, deleteAlerts, nil
+}
+
+// alertsDeletedWhilePaused returns true if the alerting rules of the paused objective were deleted already.
+// They stay deleted until it's resumed, even if it's only paused without deleting them since.
+func alertsDeletedWhilePaused(status pyrrav1alpha1.ServiceLevelObjectiveStatus) bool {
+	condition := meta.FindStatusCondition(status.Conditions, pyrrav1alpha1.ConditionPaused)
+	return condition != nil && condition.Status == metav1.ConditionTrue && condition.Reason == reasonAlertsDeleted
+}
+
+// withoutAlerting disables all alerts of the objective, for only its recording rules to be written.
+// Only the objective in memory is changed.
+func withoutAlerting(kubeObjective *pyrrav1alpha1.ServiceLevelObjective) {
+	kubeObjective.Spec.Alerting.Burnrates = ptr.To(false)
+	kubeObjective.Spec.Alerting.Absent = ptr.To(false)
+}
+
+// setPaused sets the Paused condition of the objective, with an event as it's paused or its alerting rules are deleted.
+func (r *ServiceLevelObjectiveReconciler) setPaused(kubeObjective *pyrrav1alpha1.ServiceLevelObjective, status *pyrrav1alpha1.ServiceLevelObjectiveStatus, alertsDeleted bool) {
+	reason, message := reasonPaused, "The objective is paused, its rules aren't updated until it's resumed."
+	if alertsDeleted {
+		reason, message = reasonAlertsDeleted, "The objective is paused, its alerting rules are deleted and its recording rules aren't updated until it's resumed."
+	}
+	previous := meta.FindStatusCondition(status.Conditions, pyrrav1alpha1.ConditionPaused)
+	if previous == nil || previous.Status != metav1.ConditionTrue || previous.Reason != reason {
+		r.event(kubeObjective, corev1.EventTypeNormal, reason, "%s", message)
+	}
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               pyrrav1alpha1.ConditionPaused,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: kubeObjective.GetGeneration(),
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// setResumed sets the Paused condition of the objective to false, with an event, if it was paused.
+func (r *ServiceLevelObjectiveReconciler) setResumed(kubeObjective *pyrrav1alpha1.ServiceLevelObjective, status *pyrrav1alpha1.ServiceLevelObjectiveStatus) {
+	if !meta.IsStatusConditionTrue(status.Conditions, pyrrav1alpha1.ConditionPaused) {
+		return
+	}
+	message := "The objective was resumed, its rules are updated again."
+	r.event(kubeObjective, corev1.EventTypeNormal, reasonResumed, "%s", message)
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               pyrrav1alpha1.ConditionPaused,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: kubeObjective.GetGeneration(),
+		Reason:             reasonResumed,
+		Message:            message,
+	})
+}
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func TestServiceLevelObjectiveReconciler_Paused(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective).
+		WithStatusSubresource(objective).
+		Build()
+
+	recorder := record.NewFakeRecorder(10)
+	r := &ServiceLevelObjectiveReconciler{
+		Client:   c,
+		Logger:   kitlog.NewNopLogger(),
+		Recorder: recorder,
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+	reconcile := func() (pyrrav1alpha1.ServiceLevelObjectiveStatus, monitoringv1.PrometheusRule) {
+		_, err := r.Reconcile(context.Background(), req)
+		require.NoError(t, err)
+		var o pyrrav1alpha1.ServiceLevelObjective
+		require.NoError(t, c.Get(context.Background(), req.NamespacedName, &o))
+		var rule monitoringv1.PrometheusRule
+		require.NoError(t, c.Get(context.Background(), req.NamespacedName, &rule))
+		return o.Status, rule
+	}
+	update := func(f func(o *pyrrav1alpha1.ServiceLevelObjective)) {
+		var o pyrrav1alpha1.ServiceLevelObjective
+		require.NoError(t, c.Get(context.Background(), req.NamespacedName, &o))
+		f(&o)
+		require.NoError(t, c.Update(context.Background(), &o))
+	}
+	alerts := func(rule monitoringv1.PrometheusRule) (alerts, records int) {
+		for _, g := range rule.Spec.Groups {
+			for _, r := range g.Rules {
+				if r.Alert != "" {
+					alerts++
+				} else {
+					records++
+				}
+			}
+		}
+		return alerts, records
+	}
+
+	status, written := reconcile()
+	require.Nil(t, meta.FindStatusCondition(status.Conditions, pyrrav1alpha1.ConditionPaused))
+	require.Equal(t, "Normal RulesCreated Created PrometheusRule http", <-recorder.Events)
+	alertCount, recordCount := alerts(written)
+	require.NotZero(t, alertCount)
+
+	// The rules of paused objectives aren't updated as they change.
+	update(func(o *pyrrav1alpha1.ServiceLevelObjective) {
+		o.Spec.Paused = true
+		o.Spec.Alerting.Name = "HTTPErrorBudgetBurn"
+	})
+	status, rule := reconcile()
+	require.Equal(t, written.Spec, rule.Spec)
+	condition := meta.FindStatusCondition(status.Conditions, pyrrav1alpha1.ConditionPaused)
+	require.NotNil(t, condition)
+	require.Equal(t, metav1.ConditionTrue, condition.Status)
+	require.Equal(t, reasonPaused, condition.Reason)
+	require.Equal(t, "Normal Paused The objective is paused, its rules aren't updated until it's resumed.", <-recorder.Events)
+
+	_, _ = reconcile()
+	require.Empty(t, recorder.Events)
+
+	// Their alerting rules are deleted once, the recording rules are written once more from the spec.
+	update(func(o *pyrrav1alpha1.ServiceLevelObjective) {
+		o.Spec.DeleteAlertsWhilePaused = true
+		o.Spec.Alerting.Name = ""
+	})
+	status, rule = reconcile()
+	paused, records := alerts(rule)
+	require.Zero(t, paused)
+	require.Equal(t, recordCount, records)
+	condition = meta.FindStatusCondition(status.Conditions, pyrrav1alpha1.ConditionPaused)
+	require.Equal(t, reasonAlertsDeleted, condition.Reason)
+	require.True(t, meta.IsStatusConditionTrue(status.Conditions, pyrrav1alpha1.ConditionReady))
+	require.Equal(t, "Normal RulesUpdated Updated PrometheusRule http", <-recorder.Events)
+	require.Equal(t, "Normal AlertsDeleted The objective is paused, its alerting rules are deleted and its recording rules aren't updated until it's resumed.", <-recorder.Events)
+
+	deleted := rule
+	update(func(o *pyrrav1alpha1.ServiceLevelObjective) { o.Spec.Window = "7d" })
+	_, rule = reconcile()
+	require.Equal(t, deleted.Spec, rule.Spec)
+	require.Empty(t, recorder.Events)
+
+	// The alerting rules stay deleted until the objective is resumed.
+	update(func(o *pyrrav1alpha1.ServiceLevelObjective) { o.Spec.DeleteAlertsWhilePaused = false })
+	status, rule = reconcile()
+	require.Equal(t, deleted.Spec, rule.Spec)
+	require.Equal(t, reasonAlertsDeleted, meta.FindStatusCondition(status.Conditions, pyrrav1alpha1.ConditionPaused).Reason)
+
+	update(func(o *pyrrav1alpha1.ServiceLevelObjective) {
+		o.Spec.Paused = false
+		o.Spec.Window = "28d"
+	})
+	status, rule = reconcile()
+	require.Equal(t, written.Spec, rule.Spec)
+	condition = meta.FindStatusCondition(status.Conditions, pyrrav1alpha1.ConditionPaused)
+	require.Equal(t, metav1.ConditionFalse, condition.Status)
+	require.Equal(t, reasonResumed, condition.Reason)
+	require.Equal(t, "Normal Resumed The objective was resumed, its rules are updated again.", <-recorder.Events)
+	require.Equal(t, "Normal RulesUpdated Updated PrometheusRule http", <-recorder.Events)
+}
+
+func TestIsPaused(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		spec         pyrrav1alpha1.ServiceLevelObjectiveSpec
+		annotation   string
+		paused       bool
+		deleteAlerts bool
+		err          string
+	}{{
+		name: "unpaused",
+	}, {
+		name:   "spec",
+		spec:   pyrrav1alpha1.ServiceLevelObjectiveSpec{Paused: true},
+		paused: true,
+	}, {
+		name:         "specDeleteAlerts",
+		spec:         pyrrav1alpha1.ServiceLevelObjectiveSpec{Paused: true, DeleteAlertsWhilePaused: true},
+		paused:       true,
+		deleteAlerts: true,
+	}, {
+		name: "deleteAlertsOnly",
+		spec: pyrrav1alpha1.ServiceLevelObjectiveSpec{DeleteAlertsWhilePaused: true},
+	}, {
+		name:       "annotation",
+		annotation: "true",
+		paused:     true,
+	}, {
+		name:         "annotationDeleteAlerts",
+		annotation:   "delete-alerts",
+		paused:       true,
+		deleteAlerts: true,
+	}, {
+		name:       "annotationFalse",
+		spec:       pyrrav1alpha1.ServiceLevelObjectiveSpec{Paused: true},
+		annotation: "false",
+		paused:     true,
+	}, {
+		name:       "invalid",
+		annotation: "yes",
+		err:        `pyrra.dev/paused must be true, false or delete-alerts, got "yes"`,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			objective := pyrrav1alpha1.ServiceLevelObjective{Spec: tc.spec}
+			if tc.annotation != "" {
+				objective.Annotations = map[string]string{PausedAnnotation: tc.annotation}
+			}
+			paused, deleteAlerts, err := isPaused(objective)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				require.True(t, isInvalidObjective(err))
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.paused, paused)
+			require.Equal(t, tc.deleteAlerts, deleteAlerts)
+		})
+	}
+}
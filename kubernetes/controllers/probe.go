@@ -0,0 +1,293 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+const (
+	// ProbeObjectiveAnnotation opts a Probe into getting an availability objective per probed target.
+	ProbeObjectiveAnnotation = "pyrra.dev/probe-slo"
+	probeObjectiveValue      = "true"
+	// ProbeObjectiveTargetAnnotation overrides the target of the objectives, from 0 to 100.
+	ProbeObjectiveTargetAnnotation = "pyrra.dev/probe-slo-target"
+	// ProbeObjectiveWindowAnnotation overrides the window of the objectives.
+	ProbeObjectiveWindowAnnotation = "pyrra.dev/probe-slo-window"
+
+	defaultProbeObjectiveTarget = "99"
+	defaultProbeObjectiveWindow = "4w"
+
+	// maxObjectiveNameLength leaves room for the suffixes of the generated rules' names.
+	maxObjectiveNameLength = 200
+)
+
+var invalidObjectiveNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// ProbeReconciler maintains availability objectives for the targets of prometheus-operator Probes annotated with pyrra.dev/probe-slo: "true".
+// The objectives are owned by their Probe, so they're garbage collected with it,
+// and they're removed as targets are removed from the Probe or the annotation is removed.
+type ProbeReconciler struct {
+	client.Client
+	Logger kitlog.Logger
+}
+
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=probes,verbs=get;list;watch
+
+func (r *ProbeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := kitlog.With(r.Logger, "reconciler", "probe", "namespace", req.NamespacedName)
+	level.Debug(logger).Log("msg", "reconciling")
+
+	var probe monitoringv1.Probe
+	if err := r.Get(ctx, req.NamespacedName, &probe); err != nil {
+		// Objectives of deleted probes are garbage collected through their owner references.
+		return ctrl.Result{}, client.IgnoreNotFound(fmt.Errorf("getting probe: %w", err))
+	}
+
+	desired, err := probeObjectives(probe)
+	if err != nil {
+		// Retrying doesn't help until the probe is changed, which triggers another reconcile.
+		level.Warn(logger).Log("msg", "failed to generate objectives for probe", "err", err)
+		return ctrl.Result{}, nil
+	}
+
+	if err := syncObjectives(ctx, r.Client, logger, &probe, desired); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// syncObjectives creates and updates the desired objectives controlled by the owner
+// and deletes the ones controlled by it that aren't desired anymore.
+// Annotations others added to the objectives are kept.
+func syncObjectives(
+	ctx context.Context,
+	c client.Client,
+	logger kitlog.Logger,
+	owner client.Object,
+	desired []pyrrav1alpha1.ServiceLevelObjective,
+) error {
+	var list pyrrav1alpha1.ServiceLevelObjectiveList
+	if err := c.List(ctx, &list, client.InNamespace(owner.GetNamespace())); err != nil {
+		return fmt.Errorf("failed to list objectives: %w", err)
+	}
+	existing := map[string]pyrrav1alpha1.ServiceLevelObjective{}
+	for _, o := range list.Items {
+		if metav1.IsControlledBy(&o, owner) {
+			existing[o.GetName()] = o
+		}
+	}
+
+	for _, o := range desired {
+		current, ok := existing[o.GetName()]
+		delete(existing, o.GetName())
+
+		if !ok {
+			level.Info(logger).Log("msg", "creating objective", "name", o.GetName())
+			if err := c.Create(ctx, &o); err != nil {
+				return fmt.Errorf("failed to create objective: %w", err)
+			}
+			continue
+		}
+
+		annotations := current.GetAnnotations()
+		if len(o.GetAnnotations()) > 0 && annotations == nil {
+			annotations = make(map[string]string, len(o.GetAnnotations()))
+		}
+		annotationsChanged := false
+		for name, value := range o.GetAnnotations() {
+			if annotations[name] != value {
+				annotations[name] = value
+				annotationsChanged = true
+			}
+		}
+
+		if !annotationsChanged &&
+			equality.Semantic.DeepEqual(current.Spec, o.Spec) &&
+			equality.Semantic.DeepEqual(current.GetLabels(), o.GetLabels()) {
+			continue
+		}
+
+		current.Spec = o.Spec
+		current.SetLabels(o.GetLabels())
+		current.SetAnnotations(annotations)
+		level.Info(logger).Log("msg", "updating objective", "name", o.GetName())
+		if err := c.Update(ctx, &current); err != nil {
+			return fmt.Errorf("failed to update objective: %w", err)
+		}
+	}
+
+	// The remaining objectives aren't desired anymore, like the ones of targets no longer probed.
+	for _, o := range existing {
+		level.Info(logger).Log("msg", "deleting objective", "name", o.GetName())
+		if err := c.Delete(ctx, &o); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete objective: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *ProbeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("probe").
+		For(&monitoringv1.Probe{}).
+		Owns(&pyrrav1alpha1.ServiceLevelObjective{}).
+		Complete(r)
+}
+
+// probeObjectives returns the objectives the probe should have, none if it isn't annotated.
+// Static targets get an objective each. Targets discovered from Ingresses aren't known upfront,
+// so they share one objective grouped by instance.
+func probeObjectives(probe monitoringv1.Probe) ([]pyrrav1alpha1.ServiceLevelObjective, error) {
+	annotations := probe.GetAnnotations()
+	if annotations[ProbeObjectiveAnnotation] != probeObjectiveValue {
+		return nil, nil
+	}
+
+	target := defaultProbeObjectiveTarget
+	if t, ok := annotations[ProbeObjectiveTargetAnnotation]; ok {
+		value, err := strconv.ParseFloat(t, 64)
+		if err != nil || value < 0 || value > 100 {
+			return nil, fmt.Errorf("%s must be between 0 and 100, got %q", ProbeObjectiveTargetAnnotation, t)
+		}
+		target = t
+	}
+	window := defaultProbeObjectiveWindow
+	if w, ok := annotations[ProbeObjectiveWindowAnnotation]; ok {
+		if _, err := model.ParseDuration(w); err != nil {
+			return nil, fmt.Errorf("%s is invalid: %w", ProbeObjectiveWindowAnnotation, err)
+		}
+		window = w
+	}
+
+	// prometheus-operator uses the probe's namespace and name as job unless the job name is set.
+	job := probe.Spec.JobName
+	if job == "" {
+		job = fmt.Sprintf("probe/%s/%s", probe.GetNamespace(), probe.GetName())
+	}
+
+	// Labels propagated to the rules, like pyrra.dev/team, are passed on to the objectives.
+	objectiveLabels := map[string]string{}
+	for name, value := range probe.GetLabels() {
+		if strings.HasPrefix(name, slo.PropagationLabelsPrefix) {
+			objectiveLabels[name] = value
+		}
+	}
+
+	controller := true
+	objective := func(name, description string, matchers []*labels.Matcher, grouping []string) pyrrav1alpha1.ServiceLevelObjective {
+		metric := &parser.VectorSelector{Name: "probe_success", LabelMatchers: append([]*labels.Matcher{
+			labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "probe_success"),
+			labels.MustNewMatcher(labels.MatchEqual, "job", job),
+		}, matchers...)}
+
+		var ls map[string]string
+		if len(objectiveLabels) > 0 {
+			ls = make(map[string]string, len(objectiveLabels))
+			for n, v := range objectiveLabels {
+				ls[n] = v
+			}
+		}
+
+		return pyrrav1alpha1.ServiceLevelObjective{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: probe.GetNamespace(),
+				Labels:    ls,
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: monitoringv1.SchemeGroupVersion.String(),
+					Kind:       monitoringv1.ProbesKind,
+					Name:       probe.GetName(),
+					UID:        probe.GetUID(),
+					Controller: &controller,
+				}},
+			},
+			Spec: pyrrav1alpha1.ServiceLevelObjectiveSpec{
+				Description: description,
+				Target:      target,
+				Window:      window,
+				ServiceLevelIndicator: pyrrav1alpha1.ServiceLevelIndicator{
+					BoolGauge: &pyrrav1alpha1.BoolGaugeIndicator{
+						Query:    pyrrav1alpha1.Query{Metric: metric.String()},
+						Grouping: grouping,
+					},
+				},
+			},
+		}
+	}
+
+	if probe.Spec.Targets.StaticConfig == nil {
+		if probe.Spec.Targets.Ingress == nil {
+			return nil, nil
+		}
+		return []pyrrav1alpha1.ServiceLevelObjective{objective(
+			generatedObjectiveName(probe.GetName(), ""),
+			fmt.Sprintf("Availability of the ingresses probed by %s.", probe.GetName()),
+			nil,
+			[]string{"instance"},
+		)}, nil
+	}
+
+	objectives := make([]pyrrav1alpha1.ServiceLevelObjective, 0, len(probe.Spec.Targets.StaticConfig.Targets))
+	names := map[string]string{}
+	for _, t := range probe.Spec.Targets.StaticConfig.Targets {
+		name := generatedObjectiveName(probe.GetName(), t)
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("targets %q and %q result in the same objective name %q", other, t, name)
+		}
+		names[name] = t
+
+		objectives = append(objectives, objective(
+			name,
+			fmt.Sprintf("Availability of %s probed by %s.", t, probe.GetName()),
+			[]*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "instance", t)},
+			nil,
+		))
+	}
+	return objectives, nil
+}
+
+// generatedObjectiveName returns a valid object name for the objective of the probe's target or the template's value,
+// like checkout-https-example-com-health for https://example.com/health.
+func generatedObjectiveName(prefix, value string) string {
+	name := prefix
+	if suffix := strings.Trim(invalidObjectiveNameChars.ReplaceAllString(strings.ToLower(value), "-"), "-"); suffix != "" {
+		name = prefix + "-" + suffix
+	}
+	if len(name) > maxObjectiveNameLength {
+		name = strings.TrimRight(name[:maxObjectiveNameLength], "-")
+	}
+	return name
+}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func TestProbeReconciler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	probe := &monitoringv1.Probe{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "checkout",
+			Namespace:   "monitoring",
+			UID:         types.UID("probe-uid"),
+			Labels:      map[string]string{"pyrra.dev/team": "checkout", "app": "blackbox"},
+			Annotations: map[string]string{ProbeObjectiveAnnotation: "true", ProbeObjectiveTargetAnnotation: "99.5"},
+		},
+		Spec: monitoringv1.ProbeSpec{
+			Targets: monitoringv1.ProbeTargets{
+				StaticConfig: &monitoringv1.ProbeTargetStaticConfig{
+					Targets: []string{"https://example.com/health", "https://example.com/cart"},
+				},
+			},
+		},
+	}
+	// An objective not owned by the probe is left alone.
+	unrelated := &pyrrav1alpha1.ServiceLevelObjective{
+		ObjectMeta: metav1.ObjectMeta{Name: "checkout-errors", Namespace: "monitoring"},
+	}
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(probe, unrelated).
+		Build()
+
+	r := &ProbeReconciler{Client: c, Logger: kitlog.NewNopLogger()}
+	reconcile := func() []pyrrav1alpha1.ServiceLevelObjective {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(probe)})
+		require.NoError(t, err)
+
+		var list pyrrav1alpha1.ServiceLevelObjectiveList
+		require.NoError(t, c.List(context.Background(), &list, client.InNamespace("monitoring")))
+		return list.Items
+	}
+
+	objectives := reconcile()
+	require.Len(t, objectives, 3)
+	require.Equal(t, "checkout-errors", objectives[0].GetName())
+	require.Equal(t, "checkout-https-example-com-cart", objectives[1].GetName())
+	require.Equal(t, "checkout-https-example-com-health", objectives[2].GetName())
+
+	health := objectives[2]
+	require.Equal(t, map[string]string{"pyrra.dev/team": "checkout"}, health.GetLabels())
+	require.True(t, metav1.IsControlledBy(&health, probe))
+	require.Equal(t, pyrrav1alpha1.ServiceLevelObjectiveSpec{
+		Description: "Availability of https://example.com/health probed by checkout.",
+		Target:      "99.5",
+		Window:      "4w",
+		ServiceLevelIndicator: pyrrav1alpha1.ServiceLevelIndicator{
+			BoolGauge: &pyrrav1alpha1.BoolGaugeIndicator{
+				Query: pyrrav1alpha1.Query{Metric: `probe_success{instance="https://example.com/health",job="probe/monitoring/checkout"}`},
+			},
+		},
+	}, health.Spec)
+	_, err := health.Internal()
+	require.NoError(t, err)
+
+	// Manual changes are reverted and removed targets lose their objective.
+	health.Spec.Target = "90"
+	require.NoError(t, c.Update(context.Background(), &health))
+	probe.Spec.Targets.StaticConfig.Targets = []string{"https://example.com/health"}
+	require.NoError(t, c.Update(context.Background(), probe))
+
+	objectives = reconcile()
+	require.Len(t, objectives, 2)
+	require.Equal(t, "checkout-https-example-com-health", objectives[1].GetName())
+	require.Equal(t, "99.5", objectives[1].Spec.Target)
+
+	// Opting out removes all objectives of the probe.
+	probe.Annotations = nil
+	require.NoError(t, c.Update(context.Background(), probe))
+
+	objectives = reconcile()
+	require.Len(t, objectives, 1)
+	require.Equal(t, "checkout-errors", objectives[0].GetName())
+}
+
+func TestProbeObjectives(t *testing.T) {
+	probe := monitoringv1.Probe{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "ingresses",
+			Namespace:   "monitoring",
+			Annotations: map[string]string{ProbeObjectiveAnnotation: "true", ProbeObjectiveWindowAnnotation: "2w"},
+		},
+		Spec: monitoringv1.ProbeSpec{
+			JobName: "blackbox",
+			Targets: monitoringv1.ProbeTargets{Ingress: &monitoringv1.ProbeTargetIngress{}},
+		},
+	}
+
+	objectives, err := probeObjectives(probe)
+	require.NoError(t, err)
+	require.Len(t, objectives, 1)
+	require.Equal(t, "ingresses", objectives[0].GetName())
+	require.Equal(t, "2w", objectives[0].Spec.Window)
+	require.Equal(t, "99", objectives[0].Spec.Target)
+	require.Equal(t, &pyrrav1alpha1.BoolGaugeIndicator{
+		Query:    pyrrav1alpha1.Query{Metric: `probe_success{job="blackbox"}`},
+		Grouping: []string{"instance"},
+	}, objectives[0].Spec.ServiceLevelIndicator.BoolGauge)
+
+	probe.Annotations[ProbeObjectiveTargetAnnotation] = "999"
+	_, err = probeObjectives(probe)
+	require.EqualError(t, err, `pyrra.dev/probe-slo-target must be between 0 and 100, got "999"`)
+
+	probe.Annotations[ProbeObjectiveTargetAnnotation] = "99"
+	probe.Spec.Targets.StaticConfig = &monitoringv1.ProbeTargetStaticConfig{
+		Targets: []string{"http://example.com", "HTTP://EXAMPLE.COM"},
+	}
+	_, err = probeObjectives(probe)
+	require.EqualError(t, err, `targets "http://example.com" and "HTTP://EXAMPLE.COM" result in the same objective name "ingresses-http-example-com"`)
+
+	probe.Annotations[ProbeObjectiveAnnotation] = "false"
+	objectives, err = probeObjectives(probe)
+	require.NoError(t, err)
+	require.Empty(t, objectives)
+}
+
+func TestGeneratedObjectiveName(t *testing.T) {
+	require.Equal(t, "checkout-https-example-com-health", generatedObjectiveName("checkout", "https://example.com/health"))
+	require.Equal(t, "checkout-10-0-0-1-8080", generatedObjectiveName("checkout", "10.0.0.1:8080"))
+	require.Equal(t, "checkout", generatedObjectiveName("checkout", ""))
+	require.Len(t, generatedObjectiveName("checkout", "https://example.com/"+strings.Repeat("a", 300)), maxObjectiveNameLength)
+}
@@ -0,0 +1,268 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=prometheuses,verbs=get;list;watch
+
+var prometheusRulesUnselected = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "pyrra_prometheus_rules_unselected",
+	Help: "How many PrometheusRules of objectives the ruleSelector and ruleNamespaceSelector of the Prometheus don't select, as of the last check.",
+})
+
+func init() {
+	metrics.Registry.MustRegister(prometheusRulesUnselected)
+}
+
+// PrometheusSelector is the prometheus-operator Prometheus evaluating the PrometheusRules of objectives without a destination.
+// PrometheusRules its ruleSelector or ruleNamespaceSelector don't select are never loaded.
+type PrometheusSelector struct {
+	// Prometheus is the namespace and name of the Prometheus.
+	Prometheus types.NamespacedName
+	// AddLabels adds the labels the ruleSelector requires to the PrometheusRules,
+	// the ones of its matchLabels and of its In expressions with a single value.
+	AddLabels bool
+}
+
+// prometheus returns the Prometheus, nil if it doesn't exist.
+func (s *PrometheusSelector) prometheus(ctx context.Context, reader client.Reader) (*monitoringv1.Prometheus, error) {
+	var p monitoringv1.Prometheus
+	if err := reader.Get(ctx, s.Prometheus, &p); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get prometheus: %w", err)
+	}
+	return &p, nil
+}
+
+// applies returns true if the PrometheusRule of the objective is meant for the Prometheus,
+// as objectives with a destination are evaluated by the destination's Prometheus.
+func (s *PrometheusSelector) applies(kubeObjective pyrrav1alpha1.ServiceLevelObjective) bool {
+	return kubeObjective.Spec.Destination == "" && !IsLokiObjective(kubeObjective.GetAnnotations())
+}
+
+// requiredLabels returns the labels the ruleSelector requires the PrometheusRules to have.
+// Other expressions can't be satisfied by adding a label and are left to the check.
+func requiredLabels(selector *metav1.LabelSelector) map[string]string {
+	if selector == nil {
+		return nil
+	}
+	required := make(map[string]string, len(selector.MatchLabels))
+	for k, v := range selector.MatchLabels {
+		required[k] = v
+	}
+	for _, e := range selector.MatchExpressions {
+		if e.Operator == metav1.LabelSelectorOpIn && len(e.Values) == 1 {
+			required[e.Key] = e.Values[0]
+		}
+	}
+	return required
+}
+
+// selectRuleLabels adds the labels the ruleSelector of the Prometheus requires to the PrometheusRule,
+// which it copies so the PrometheusRule doesn't share them with the objective.
+// The PrometheusRule's own labels take precedence.
+func (r *ServiceLevelObjectiveReconciler) selectRuleLabels(ctx context.Context, rule *monitoringv1.PrometheusRule) error {
+	p, err := r.PrometheusSelector.prometheus(ctx, r.Client)
+	if err != nil || p == nil {
+		return err
+	}
+	required := requiredLabels(p.Spec.RuleSelector)
+	if len(required) == 0 {
+		return nil
+	}
+
+	ls := make(map[string]string, len(rule.GetLabels())+len(required))
+	for k, v := range required {
+		ls[k] = v
+	}
+	for k, v := range rule.GetLabels() {
+		ls[k] = v
+	}
+	rule.SetLabels(ls)
+	return nil
+}
+
+// objectivesForPrometheus returns the requests of all objectives the Prometheus applies to, if it is the one of the PrometheusSelector,
+// so the labels of their PrometheusRules follow changes of its ruleSelector.
+func (r *ServiceLevelObjectiveReconciler) objectivesForPrometheus(ctx context.Context, obj client.Object) []reconcile.Request {
+	if client.ObjectKeyFromObject(obj) != r.PrometheusSelector.Prometheus {
+		return nil
+	}
+
+	var list pyrrav1alpha1.ServiceLevelObjectiveList
+	if err := r.List(ctx, &list); err != nil {
+		level.Warn(r.Logger).Log("msg", "failed to list objectives for prometheus", "err", err)
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, o := range list.Items {
+		if r.PrometheusSelector.applies(o) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&o)})
+		}
+	}
+	return requests
+}
+
+// unselectedReason returns why the Prometheus doesn't select the PrometheusRule, empty if it does.
+// Like the prometheus-operator, a missing ruleSelector selects no PrometheusRules
+// and a missing ruleNamespaceSelector only the ones in the namespace of the Prometheus.
+func unselectedReason(ctx context.Context, reader client.Reader, p *monitoringv1.Prometheus, rule *monitoringv1.PrometheusRule) (string, error) {
+	if p.Spec.RuleSelector == nil {
+		return "its ruleSelector isn't set and selects no PrometheusRules", nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(p.Spec.RuleSelector)
+	if err != nil {
+		return "", fmt.Errorf("invalid ruleSelector of prometheus %s/%s: %w", p.GetNamespace(), p.GetName(), err)
+	}
+	if !selector.Matches(labels.Set(rule.GetLabels())) {
+		return fmt.Sprintf("its ruleSelector %s doesn't match the labels {%s}", selector, labels.Set(rule.GetLabels())), nil
+	}
+
+	if p.Spec.RuleNamespaceSelector == nil {
+		if rule.GetNamespace() != p.GetNamespace() {
+			return fmt.Sprintf("its ruleNamespaceSelector isn't set and only selects PrometheusRules in its namespace %s", p.GetNamespace()), nil
+		}
+		return "", nil
+	}
+	namespaceSelector, err := metav1.LabelSelectorAsSelector(p.Spec.RuleNamespaceSelector)
+	if err != nil {
+		return "", fmt.Errorf("invalid ruleNamespaceSelector of prometheus %s/%s: %w", p.GetNamespace(), p.GetName(), err)
+	}
+	if namespaceSelector.Empty() {
+		return "", nil
+	}
+	var namespace corev1.Namespace
+	if err := reader.Get(ctx, client.ObjectKey{Name: rule.GetNamespace()}, &namespace); err != nil {
+		return "", fmt.Errorf("failed to get namespace: %w", err)
+	}
+	if !namespaceSelector.Matches(labels.Set(namespace.GetLabels())) {
+		return fmt.Sprintf("its ruleNamespaceSelector %s doesn't match the labels of the namespace %s", namespaceSelector, rule.GetNamespace()), nil
+	}
+	return "", nil
+}
+
+// PrometheusSelectorCheck checks right away and then periodically that the Prometheus of the Selector
+// selects the PrometheusRules of all objectives it applies to.
+// PrometheusRules it doesn't select are logged, counted by pyrra_prometheus_rules_unselected
+// and reported as warning events on their objectives.
+type PrometheusSelectorCheck struct {
+	client.Client
+	Logger   kitlog.Logger
+	Recorder record.EventRecorder
+	Selector *PrometheusSelector
+	Interval time.Duration
+}
+
+var (
+	_ manager.Runnable               = &PrometheusSelectorCheck{}
+	_ manager.LeaderElectionRunnable = &PrometheusSelectorCheck{}
+)
+
+// NeedLeaderElection makes sure only one replica reports unselected PrometheusRules.
+func (c *PrometheusSelectorCheck) NeedLeaderElection() bool {
+	return true
+}
+
+func (c *PrometheusSelectorCheck) Start(ctx context.Context) error {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.check(ctx); err != nil {
+			level.Warn(c.Logger).Log("msg", "failed to check prometheus rule selector", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// check counts the PrometheusRules of objectives the Prometheus doesn't select and reports them.
+func (c *PrometheusSelectorCheck) check(ctx context.Context) error {
+	p, err := c.Selector.prometheus(ctx, c.Client)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return fmt.Errorf("prometheus %s doesn't exist", c.Selector.Prometheus)
+	}
+
+	var list pyrrav1alpha1.ServiceLevelObjectiveList
+	if err := c.List(ctx, &list); err != nil {
+		return fmt.Errorf("failed to list objectives: %w", err)
+	}
+
+	var unselected int
+	for _, o := range list.Items {
+		if !c.Selector.applies(o) {
+			continue
+		}
+		var rule monitoringv1.PrometheusRule
+		if err := c.Get(ctx, client.ObjectKeyFromObject(&o), &rule); err != nil {
+			if errors.IsNotFound(err) {
+				// The objective's rules are written elsewhere or weren't written yet.
+				continue
+			}
+			return fmt.Errorf("failed to get prometheus rule: %w", err)
+		}
+		if !metav1.IsControlledBy(&rule, &o) {
+			continue
+		}
+
+		reason, err := unselectedReason(ctx, c.Client, p, &rule)
+		if err != nil {
+			return err
+		}
+		if reason == "" {
+			continue
+		}
+		unselected++
+		level.Warn(c.Logger).Log("msg", "prometheus rule isn't selected by prometheus", "namespace", rule.GetNamespace(), "name", rule.GetName(), "prometheus", c.Selector.Prometheus, "reason", reason)
+		if c.Recorder != nil {
+			c.Recorder.Eventf(&o, corev1.EventTypeWarning, reasonRulesNotSelected, "PrometheusRule %s isn't loaded by Prometheus %s, %s", rule.GetName(), c.Selector.Prometheus, reason)
+		}
+	}
+	prometheusRulesUnselected.Set(float64(unselected))
+	return nil
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func TestUnselectedReason(t *testing.T) {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "payments",
+		Labels: map[string]string{"team": "payments"},
+	}}
+	c := newFakeClientBuilder().WithObjects(namespace).Build()
+
+	rule := &monitoringv1.PrometheusRule{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "payments",
+		Name:      "http",
+		Labels:    map[string]string{"role": "slo"},
+	}}
+
+	for _, tc := range []struct {
+		name     string
+		spec     monitoringv1.PrometheusSpec
+		expected string
+	}{{
+		name:     "noRuleSelector",
+		spec:     monitoringv1.PrometheusSpec{},
+		expected: "its ruleSelector isn't set and selects no PrometheusRules",
+	}, {
+		name: "ruleSelectorMismatch",
+		spec: monitoringv1.PrometheusSpec{
+			RuleSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"prometheus": "k8s"}},
+		},
+		expected: "its ruleSelector prometheus=k8s doesn't match the labels {role=slo}",
+	}, {
+		name: "noRuleNamespaceSelector",
+		spec: monitoringv1.PrometheusSpec{
+			RuleSelector: &metav1.LabelSelector{},
+		},
+		expected: "its ruleNamespaceSelector isn't set and only selects PrometheusRules in its namespace monitoring",
+	}, {
+		name: "ruleNamespaceSelectorMismatch",
+		spec: monitoringv1.PrometheusSpec{
+			RuleSelector:          &metav1.LabelSelector{MatchLabels: map[string]string{"role": "slo"}},
+			RuleNamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "checkout"}},
+		},
+		expected: "its ruleNamespaceSelector team=checkout doesn't match the labels of the namespace payments",
+	}, {
+		name: "selected",
+		spec: monitoringv1.PrometheusSpec{
+			RuleSelector:          &metav1.LabelSelector{MatchLabels: map[string]string{"role": "slo"}},
+			RuleNamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}},
+		},
+	}, {
+		name: "selectedInAllNamespaces",
+		spec: monitoringv1.PrometheusSpec{
+			RuleSelector:          &metav1.LabelSelector{},
+			RuleNamespaceSelector: &metav1.LabelSelector{},
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &monitoringv1.Prometheus{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "k8s"},
+				Spec:       tc.spec,
+			}
+			reason, err := unselectedReason(context.Background(), c, p, rule)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, reason)
+		})
+	}
+}
+
+func TestRequiredLabels(t *testing.T) {
+	require.Nil(t, requiredLabels(nil))
+	require.Equal(t, map[string]string{"prometheus": "k8s", "role": "slo"}, requiredLabels(&metav1.LabelSelector{
+		MatchLabels: map[string]string{"prometheus": "k8s"},
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "role", Operator: metav1.LabelSelectorOpIn, Values: []string{"slo"}},
+			{Key: "env", Operator: metav1.LabelSelectorOpIn, Values: []string{"prod", "staging"}},
+			{Key: "team", Operator: metav1.LabelSelectorOpExists},
+		},
+	}))
+}
+
+func TestPrometheusSelector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+	objective.UID = "http"
+
+	prometheus := &monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "k8s"},
+		Spec: monitoringv1.PrometheusSpec{
+			RuleSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"prometheus": "k8s"}},
+		},
+	}
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective, prometheus).
+		WithStatusSubresource(objective).
+		Build()
+
+	selector := &PrometheusSelector{Prometheus: types.NamespacedName{Namespace: "monitoring", Name: "k8s"}}
+	r := &ServiceLevelObjectiveReconciler{Client: c, Logger: kitlog.NewNopLogger(), PrometheusSelector: selector}
+	reconcileObjective := func() {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)})
+		require.NoError(t, err)
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	check := &PrometheusSelectorCheck{Client: c, Logger: kitlog.NewNopLogger(), Recorder: recorder, Selector: selector}
+
+	// Without adding labels the PrometheusRule isn't selected and reported.
+	reconcileObjective()
+	require.NoError(t, check.check(context.Background()))
+	require.Equal(t, 1.0, testutil.ToFloat64(prometheusRulesUnselected))
+	require.Equal(t, "Warning RulesNotSelected PrometheusRule http isn't loaded by Prometheus monitoring/k8s, its ruleSelector prometheus=k8s doesn't match the labels {pyrra.dev/team=foo,team=bar}", <-recorder.Events)
+
+	// The labels the ruleSelector requires are added.
+	selector.AddLabels = true
+	reconcileObjective()
+
+	var rule monitoringv1.PrometheusRule
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(objective), &rule))
+	require.Equal(t, "k8s", rule.GetLabels()["prometheus"])
+
+	require.NoError(t, check.check(context.Background()))
+	require.Equal(t, 0.0, testutil.ToFloat64(prometheusRulesUnselected))
+	require.Empty(t, recorder.Events)
+
+	// Objectives with a destination are evaluated by other Prometheus.
+	objective.Spec.Destination = "staging"
+	require.False(t, selector.applies(*objective))
+	require.Empty(t, r.objectivesForPrometheus(context.Background(), &monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "other"},
+	}))
+	require.Len(t, r.objectivesForPrometheus(context.Background(), prometheus), 1)
+}
@@ -0,0 +1,300 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+const (
+	// RemoteClustersAnnotation on an objective lists the names of the remote clusters its PrometheusRule is written to,
+	// like eu1,us1. Objectives without it are written to all remote clusters, the ones with it empty to none.
+	RemoteClustersAnnotation = "pyrra.dev/clusters"
+	// RemoteClusterLabel is set on the PrometheusRules written to remote clusters to the cluster's name.
+	// Only PrometheusRules with it are deleted from remote clusters.
+	RemoteClusterLabel = "pyrra.dev/remote-cluster"
+	// remoteClusterKubeconfigKey is the key of the kubeconfig in the Secrets of remote clusters.
+	remoteClusterKubeconfigKey = "kubeconfig"
+)
+
+// newRemoteClient creates the client of a remote cluster from its kubeconfig.
+var newRemoteClient = func(config *rest.Config, scheme *runtime.Scheme) (client.Client, error) {
+	return client.New(config, client.Options{Scheme: scheme})
+}
+
+// RemoteCluster is a Kubernetes cluster the PrometheusRules of objectives are written to in addition to the operator's own,
+// like a workload cluster whose Prometheus evaluates the rules of a central repository of objectives.
+// The PrometheusRules have the same namespace and name as the objectives, which must exist in the remote cluster.
+// As they can't be owned by the objectives, they're deleted from the remote clusters with them.
+type RemoteCluster struct {
+	// Secret has the kubeconfig of the cluster in its kubeconfig key.
+	// The client is recreated as the Secret changes, so rotated credentials don't need a restart.
+	Secret types.NamespacedName
+
+	mu              sync.Mutex
+	resourceVersion string
+	client          client.Client
+}
+
+// RemoteClusters are the remote clusters by their name.
+type RemoteClusters map[string]*RemoteCluster
+
+// remoteClient returns the client of the cluster from its Secret's kubeconfig.
+// The client is only recreated if the Secret changed since it was last read.
+func (c *RemoteCluster) remoteClient(ctx context.Context, reader client.Reader, scheme *runtime.Scheme) (client.Client, error) {
+	var secret corev1.Secret
+	if err := reader.Get(ctx, c.Secret, &secret); err != nil {
+		return nil, fmt.Errorf("failed to get kubeconfig secret %s: %w", c.Secret, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client != nil && c.resourceVersion == secret.GetResourceVersion() {
+		return c.client, nil
+	}
+	kubeconfig := secret.Data[remoteClusterKubeconfigKey]
+	if len(kubeconfig) == 0 {
+		return nil, fmt.Errorf("kubeconfig secret %s has no %s", c.Secret, remoteClusterKubeconfigKey)
+	}
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("kubeconfig secret %s is invalid: %w", c.Secret, err)
+	}
+	remote, err := newRemoteClient(config, scheme)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client from kubeconfig secret %s: %w", c.Secret, err)
+	}
+	c.resourceVersion, c.client = secret.GetResourceVersion(), remote
+	return remote, nil
+}
+
+// remoteClusterNames returns the sorted names of the remote clusters the objective is written to.
+// Unknown clusters in the objective's annotation make it invalid.
+func (r *ServiceLevelObjectiveReconciler) remoteClusterNames(kubeObjective pyrrav1alpha1.ServiceLevelObjective) ([]string, error) {
+	all := make([]string, 0, len(r.RemoteClusters))
+	for name := range r.RemoteClusters {
+		all = append(all, name)
+	}
+	sort.Strings(all)
+
+	annotation, ok := kubeObjective.GetAnnotations()[RemoteClustersAnnotation]
+	if !ok {
+		return all, nil
+	}
+	var names []string
+	for _, name := range strings.Split(annotation, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := r.RemoteClusters[name]; !ok {
+			return nil, invalidObjectiveError{err: fmt.Errorf("unknown cluster %q in %s, the operator's remote clusters are [%s]", name, RemoteClustersAnnotation, strings.Join(all, ", "))}
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// remoteClusterCondition returns the type of the condition of the objective's PrometheusRule in the remote cluster.
+func remoteClusterCondition(name string) string {
+	return pyrrav1alpha1.ConditionClusterSyncedPrefix + name
+}
+
+// RemoteClusterWriter writes the PrometheusRules of objectives evaluated by Prometheus to the reconciler's RemoteClusters.
+// The outcome for each cluster is the objective's ClusterSynced-<cluster> condition.
+// Writing to some clusters failing doesn't keep the others from being written.
+type RemoteClusterWriter struct {
+	Reconciler *ServiceLevelObjectiveReconciler
+}
+
+func (w RemoteClusterWriter) Apply(ctx context.Context, o *WriterObjective) (ctrl.Result, error) {
+	if IsLokiObjective(o.Objective.GetAnnotations()) {
+		return ctrl.Result{}, nil
+	}
+	r := w.Reconciler
+	names, err := r.remoteClusterNames(o.Objective)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	newRule, err := generate(ctx, func() (*monitoringv1.PrometheusRule, error) {
+		groups, err := r.prometheusRuleGroups(ctx, o.Objective)
+		if err != nil {
+			return nil, err
+		}
+		return newPrometheusRule(o.Objective, groups), nil
+	})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	o.Destination.withLabels(newRule)
+	// The objective doesn't exist in the remote clusters to own the PrometheusRules there.
+	newRule.SetOwnerReferences(nil)
+	if err := r.setProvenance(newRule, &o.Objective); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	targets := make(map[string]bool, len(names))
+	var errs []error
+	for _, name := range names {
+		targets[name] = true
+		err := r.writeRemotePrometheusRule(ctx, o.Logger, name, &o.Objective, newRule.DeepCopy())
+		status, reason, message := metav1.ConditionTrue, reasonRulesWritten, fmt.Sprintf("Rules written to PrometheusRule %s in cluster %s.", newRule.GetName(), name)
+		if err != nil {
+			err = fmt.Errorf("cluster %s: %w", name, err)
+			errs = append(errs, err)
+			status, reason, message = metav1.ConditionFalse, reasonWriteFailed, err.Error()
+		}
+		meta.SetStatusCondition(&o.Status.Conditions, metav1.Condition{
+			Type:               remoteClusterCondition(name),
+			Status:             status,
+			ObservedGeneration: o.Objective.GetGeneration(),
+			Reason:             reason,
+			Message:            message,
+		})
+	}
+
+	// The PrometheusRules are deleted from the clusters the objective isn't written to anymore.
+	for name := range r.RemoteClusters {
+		if targets[name] {
+			continue
+		}
+		if err := r.deleteRemotePrometheusRule(ctx, o.Logger, name, o.Request.NamespacedName); err != nil {
+			errs = append(errs, fmt.Errorf("cluster %s: %w", name, err))
+		}
+	}
+	// The conditions of clusters the objective isn't written to, or that were removed from the operator, are removed.
+	for _, c := range append([]metav1.Condition(nil), o.Status.Conditions...) {
+		name, ok := strings.CutPrefix(c.Type, pyrrav1alpha1.ConditionClusterSyncedPrefix)
+		if ok && !targets[name] {
+			meta.RemoveStatusCondition(&o.Status.Conditions, c.Type)
+		}
+	}
+	return ctrl.Result{}, goerrors.Join(errs...)
+}
+
+func (w RemoteClusterWriter) Delete(ctx context.Context, o *WriterObjective) error {
+	var errs []error
+	for name := range w.Reconciler.RemoteClusters {
+		if err := w.Reconciler.deleteRemotePrometheusRule(ctx, o.Logger, name, o.Request.NamespacedName); err != nil {
+			errs = append(errs, fmt.Errorf("cluster %s: %w", name, err))
+		}
+	}
+	return goerrors.Join(errs...)
+}
+
+// writeRemotePrometheusRule applies the PrometheusRule to the remote cluster unless it's up to date there.
+func (r *ServiceLevelObjectiveReconciler) writeRemotePrometheusRule(
+	ctx context.Context,
+	logger kitlog.Logger,
+	name string,
+	kubeObjective *pyrrav1alpha1.ServiceLevelObjective,
+	newRule *monitoringv1.PrometheusRule,
+) (err error) {
+	ctx, end := startSpan(ctx, "write remote PrometheusRule", &err)
+	defer end()
+
+	remote, err := r.RemoteClusters[name].remoteClient(ctx, r.Client, r.Client.Scheme())
+	if err != nil {
+		return err
+	}
+
+	labels := make(map[string]string, len(newRule.GetLabels())+1)
+	for k, v := range newRule.GetLabels() {
+		labels[k] = v
+	}
+	labels[RemoteClusterLabel] = name
+	newRule.SetLabels(labels)
+	hash, err := setAppliedHash(newRule, newRule.Spec)
+	if err != nil {
+		return err
+	}
+
+	var rule monitoringv1.PrometheusRule
+	created := false
+	if err := remote.Get(ctx, client.ObjectKeyFromObject(newRule), &rule); err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get prometheus rule: %w", err)
+		}
+		created = true
+	} else if rule.GetAnnotations()[AppliedHashAnnotation] == hash && equality.Semantic.DeepEqual(rule.Spec, newRule.Spec) {
+		level.Debug(logger).Log("msg", "remote prometheus rule is up to date", "cluster", name, "namespace", rule.GetNamespace(), "name", rule.GetName())
+		return nil
+	}
+
+	level.Info(logger).Log("msg", "applying remote prometheus rule", "cluster", name, "namespace", newRule.GetNamespace(), "name", newRule.GetName())
+	setGeneratedAt(newRule)
+	newRule.SetResourceVersion("")
+	newRule.SetManagedFields(nil)
+	if err := remote.Patch(ctx, newRule, client.Apply, client.FieldOwner(FieldManager), client.ForceOwnership); err != nil {
+		return fmt.Errorf("failed to apply prometheus rule: %w", err)
+	}
+	if created {
+		r.event(kubeObjective, corev1.EventTypeNormal, reasonRulesCreated, "Created PrometheusRule %s in cluster %s", newRule.GetName(), name)
+	} else {
+		r.event(kubeObjective, corev1.EventTypeNormal, reasonRulesUpdated, "Updated PrometheusRule %s in cluster %s", newRule.GetName(), name)
+	}
+	return nil
+}
+
+// deleteRemotePrometheusRule deletes the objective's PrometheusRule from the remote cluster,
+// unless it doesn't exist or wasn't written by the operator.
+func (r *ServiceLevelObjectiveReconciler) deleteRemotePrometheusRule(ctx context.Context, logger kitlog.Logger, name string, key types.NamespacedName) (err error) {
+	ctx, end := startSpan(ctx, "delete remote PrometheusRule", &err)
+	defer end()
+
+	remote, err := r.RemoteClusters[name].remoteClient(ctx, r.Client, r.Client.Scheme())
+	if err != nil {
+		return err
+	}
+	var rule monitoringv1.PrometheusRule
+	if err := remote.Get(ctx, key, &rule); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if _, ok := rule.GetLabels()[RemoteClusterLabel]; !ok {
+		return nil
+	}
+	level.Info(logger).Log("msg", "deleting remote prometheus rule", "cluster", name, "namespace", key.Namespace, "name", key.Name)
+	if err := remote.Delete(ctx, &rule); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to delete prometheus rule: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// kubeconfigSecret returns a Secret with the kubeconfig of a cluster at the server.
+func kubeconfigSecret(name, server string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "pyrra", Name: name},
+		Data: map[string][]byte{remoteClusterKubeconfigKey: []byte(fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: remote
+  cluster:
+    server: %s
+contexts:
+- name: remote
+  context:
+    cluster: remote
+    user: remote
+current-context: remote
+users:
+- name: remote
+  user:
+    token: secret
+`, server))},
+	}
+}
+
+func TestServiceLevelObjectiveReconciler_RemoteClusters(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective, kubeconfigSecret("eu1", "https://eu1.example.com"), kubeconfigSecret("us1", "https://us1.example.com")).
+		WithStatusSubresource(objective).
+		Build()
+
+	remotes := map[string]client.Client{
+		"https://eu1.example.com": newFakeClientBuilder().WithScheme(scheme).Build(),
+		"https://us1.example.com": newFakeClientBuilder().WithScheme(scheme).Build(),
+	}
+	created := 0
+	defer func(f func(*rest.Config, *runtime.Scheme) (client.Client, error)) { newRemoteClient = f }(newRemoteClient)
+	newRemoteClient = func(config *rest.Config, _ *runtime.Scheme) (client.Client, error) {
+		require.Equal(t, "secret", config.BearerToken)
+		created++
+		return remotes[config.Host], nil
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	r := &ServiceLevelObjectiveReconciler{
+		Client:   c,
+		Logger:   kitlog.NewNopLogger(),
+		Recorder: recorder,
+		RemoteClusters: RemoteClusters{
+			"eu1": {Secret: types.NamespacedName{Namespace: "pyrra", Name: "eu1"}},
+			"us1": {Secret: types.NamespacedName{Namespace: "pyrra", Name: "us1"}},
+		},
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+	reconcile := func() (pyrrav1alpha1.ServiceLevelObjectiveStatus, error) {
+		_, err := r.Reconcile(context.Background(), req)
+		var o pyrrav1alpha1.ServiceLevelObjective
+		require.NoError(t, client.IgnoreNotFound(c.Get(context.Background(), req.NamespacedName, &o)))
+		return o.Status, err
+	}
+	remoteRule := func(server string) (monitoringv1.PrometheusRule, error) {
+		var rule monitoringv1.PrometheusRule
+		return rule, remotes[server].Get(context.Background(), req.NamespacedName, &rule)
+	}
+
+	status, err := reconcile()
+	require.NoError(t, err)
+	require.Equal(t, "Normal RulesCreated Created PrometheusRule http", <-recorder.Events)
+	require.Equal(t, "Normal RulesCreated Created PrometheusRule http in cluster eu1", <-recorder.Events)
+	require.Equal(t, "Normal RulesCreated Created PrometheusRule http in cluster us1", <-recorder.Events)
+	for _, cluster := range []string{"eu1", "us1"} {
+		condition := meta.FindStatusCondition(status.Conditions, pyrrav1alpha1.ConditionClusterSyncedPrefix+cluster)
+		require.NotNil(t, condition)
+		require.Equal(t, metav1.ConditionTrue, condition.Status)
+		require.Equal(t, "Rules written to PrometheusRule http in cluster "+cluster+".", condition.Message)
+	}
+
+	var local monitoringv1.PrometheusRule
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, &local))
+	rule, err := remoteRule("https://eu1.example.com")
+	require.NoError(t, err)
+	require.Equal(t, local.Spec, rule.Spec)
+	require.Equal(t, "eu1", rule.GetLabels()[RemoteClusterLabel])
+	require.Empty(t, rule.GetOwnerReferences())
+
+	// Up to date remote PrometheusRules aren't written again, nor are the clients recreated.
+	_, err = reconcile()
+	require.NoError(t, err)
+	require.Empty(t, recorder.Events)
+	require.Equal(t, 2, created)
+
+	annotate := func(clusters string) {
+		objective = &pyrrav1alpha1.ServiceLevelObjective{}
+		require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+		objective.Annotations = map[string]string{RemoteClustersAnnotation: clusters}
+		require.NoError(t, c.Update(context.Background(), objective))
+	}
+
+	// The objective is only written to the clusters of its annotation and deleted from the others.
+	annotate("eu1")
+	status, err = reconcile()
+	require.NoError(t, err)
+	require.True(t, meta.IsStatusConditionTrue(status.Conditions, pyrrav1alpha1.ConditionClusterSyncedPrefix+"eu1"))
+	require.Nil(t, meta.FindStatusCondition(status.Conditions, pyrrav1alpha1.ConditionClusterSyncedPrefix+"us1"))
+	_, err = remoteRule("https://us1.example.com")
+	require.True(t, errors.IsNotFound(err))
+
+	// Unknown clusters make the objective invalid.
+	annotate("eu1,ap1")
+	status, err = reconcile()
+	require.NoError(t, err)
+	require.True(t, meta.IsStatusConditionTrue(status.Conditions, pyrrav1alpha1.ConditionValidationFailed))
+	for len(recorder.Events) > 0 {
+		<-recorder.Events
+	}
+
+	// Deleting the objective deletes its remote PrometheusRules.
+	require.NoError(t, c.Delete(context.Background(), objective))
+	_, err = reconcile()
+	require.NoError(t, err)
+	_, err = remoteRule("https://eu1.example.com")
+	require.True(t, errors.IsNotFound(err))
+}
+
+func TestRemoteClusterWriter_Failure(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+
+	remote := newFakeClientBuilder().WithScheme(scheme).Build()
+	defer func(f func(*rest.Config, *runtime.Scheme) (client.Client, error)) { newRemoteClient = f }(newRemoteClient)
+	newRemoteClient = func(*rest.Config, *runtime.Scheme) (client.Client, error) { return remote, nil }
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective, kubeconfigSecret("eu1", "https://eu1.example.com")).
+		WithStatusSubresource(objective).
+		Build()
+	r := &ServiceLevelObjectiveReconciler{
+		Client: c,
+		Logger: kitlog.NewNopLogger(),
+		RemoteClusters: RemoteClusters{
+			"eu1": {Secret: types.NamespacedName{Namespace: "pyrra", Name: "eu1"}},
+			// The kubeconfig Secret of us1 doesn't exist.
+			"us1": {Secret: types.NamespacedName{Namespace: "pyrra", Name: "us1"}},
+		},
+	}
+	o := &WriterObjective{
+		Request:   ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)},
+		Objective: *objective,
+		Logger:    kitlog.NewNopLogger(),
+		Status:    &pyrrav1alpha1.ServiceLevelObjectiveStatus{},
+	}
+	_, err := RemoteClusterWriter{Reconciler: r}.Apply(context.Background(), o)
+	require.ErrorContains(t, err, "cluster us1: failed to get kubeconfig secret pyrra/us1")
+
+	// Writing to eu1 isn't kept from succeeding.
+	require.True(t, meta.IsStatusConditionTrue(o.Status.Conditions, pyrrav1alpha1.ConditionClusterSyncedPrefix+"eu1"))
+	condition := meta.FindStatusCondition(o.Status.Conditions, pyrrav1alpha1.ConditionClusterSyncedPrefix+"us1")
+	require.NotNil(t, condition)
+	require.Equal(t, metav1.ConditionFalse, condition.Status)
+	require.Equal(t, reasonWriteFailed, condition.Reason)
+	var rule monitoringv1.PrometheusRule
+	require.NoError(t, remote.Get(context.Background(), o.Request.NamespacedName, &rule))
+}
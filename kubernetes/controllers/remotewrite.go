@@ -0,0 +1,169 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prometheus/prompb"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// ObjectiveRemoteWriter periodically pushes the series of the reconciled objectives,
+// like pyrra_slo_info and pyrra_slo_objective_target, to a Prometheus remote-write endpoint like Mimir's.
+// It is for environments where the operator itself isn't scraped.
+type ObjectiveRemoteWriter struct {
+	Client   *http.Client
+	Logger   kitlog.Logger
+	URL      string
+	Interval time.Duration
+	// Headers are sent with every request, like X-Scope-OrgID for the tenant.
+	Headers map[string]string
+	// Username and Password authenticate with basic authentication, BearerToken as bearer token.
+	Username    string
+	Password    string
+	BearerToken string
+	// Gatherer gathers the pushed series, the reconciled objectives if nil.
+	Gatherer prometheus.Gatherer
+}
+
+var (
+	_ manager.Runnable               = &ObjectiveRemoteWriter{}
+	_ manager.LeaderElectionRunnable = &ObjectiveRemoteWriter{}
+)
+
+// NeedLeaderElection makes sure only the replica reconciling the objectives pushes them.
+func (w *ObjectiveRemoteWriter) NeedLeaderElection() bool {
+	return true
+}
+
+func (w *ObjectiveRemoteWriter) Start(ctx context.Context) error {
+	gatherer := w.Gatherer
+	if gatherer == nil {
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(reconciledObjectives)
+		gatherer = reg
+	}
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if err := w.push(ctx, gatherer, time.Now()); err != nil {
+			level.Warn(w.Logger).Log("msg", "failed to remote-write objectives", "err", err)
+		}
+	}
+}
+
+// push sends the gathered series with their values at now.
+func (w *ObjectiveRemoteWriter) push(ctx context.Context, gatherer prometheus.Gatherer, now time.Time) (err error) {
+	ctx, end := startSpan(ctx, "remote write", &err)
+	defer end()
+
+	families, err := gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather objectives: %w", err)
+	}
+
+	req := prompb.WriteRequest{Timeseries: remoteWriteSeries(families, now)}
+	if len(req.Timeseries) == 0 {
+		return nil
+	}
+
+	data, err := req.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshal write request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(snappy.Encode(nil, data)))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Encoding", "snappy")
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	for name, value := range w.Headers {
+		httpReq.Header.Set(name, value)
+	}
+	switch {
+	case w.BearerToken != "":
+		httpReq.Header.Set("Authorization", "Bearer "+w.BearerToken)
+	case w.Username != "":
+		httpReq.SetBasicAuth(w.Username, w.Password)
+	}
+
+	resp, err := w.Client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// remoteWriteSeries returns the series of the gauges and counters of the metric families with their values at now.
+func remoteWriteSeries(families []*dto.MetricFamily, now time.Time) []prompb.TimeSeries {
+	var series []prompb.TimeSeries
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			var value float64
+			switch family.GetType() {
+			case dto.MetricType_GAUGE:
+				value = m.GetGauge().GetValue()
+			case dto.MetricType_COUNTER:
+				value = m.GetCounter().GetValue()
+			default:
+				continue
+			}
+
+			ls := make([]prompb.Label, 0, len(m.GetLabel())+1)
+			ls = append(ls, prompb.Label{Name: "__name__", Value: family.GetName()})
+			for _, l := range m.GetLabel() {
+				ls = append(ls, prompb.Label{Name: l.GetName(), Value: l.GetValue()})
+			}
+			// Remote-write requires the labels of a series sorted by name.
+			sort.Slice(ls, func(i, j int) bool { return ls[i].Name < ls[j].Name })
+
+			series = append(series, prompb.TimeSeries{
+				Labels:  ls,
+				Samples: []prompb.Sample{{Value: value, Timestamp: now.UnixMilli()}},
+			})
+		}
+	}
+	return series
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func TestObjectiveRemoteWriter(t *testing.T) {
+	kubeObjective := httpSLO.DeepCopy()
+	kubeObjective.Spec.Owner = &pyrrav1alpha1.Owner{Team: "checkout"}
+	objective, err := kubeObjective.Internal()
+	require.NoError(t, err)
+
+	m := &objectiveMetrics{}
+	m.set(types.NamespacedName{Namespace: "monitoring", Name: "http"}, objective, rulesPrometheusRule)
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(m)
+
+	var (
+		requests int
+		written  prompb.WriteRequest
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		require.Equal(t, "snappy", r.Header.Get("Content-Encoding"))
+		require.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		require.Equal(t, "tenant", r.Header.Get("X-Scope-OrgID"))
+		username, password, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "pyrra", username)
+		require.Equal(t, "secret", password)
+
+		compressed, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		data, err := snappy.Decode(nil, compressed)
+		require.NoError(t, err)
+		require.NoError(t, written.Unmarshal(data))
+	}))
+	defer server.Close()
+
+	w := &ObjectiveRemoteWriter{
+		Client:   server.Client(),
+		Logger:   kitlog.NewNopLogger(),
+		URL:      server.URL,
+		Interval: time.Minute,
+		Headers:  map[string]string{"X-Scope-OrgID": "tenant"},
+		Username: "pyrra",
+		Password: "secret",
+	}
+	now := time.Unix(1700000000, 0)
+	require.NoError(t, w.push(context.Background(), reg, now))
+	require.Equal(t, 1, requests)
+	require.Len(t, written.Timeseries, 3)
+
+	info := written.Timeseries[0]
+	require.Equal(t, []prompb.Label{
+		{Name: "__name__", Value: "pyrra_slo_info"},
+		{Name: "indicator", Value: "ratio"},
+		{Name: "namespace", Value: "monitoring"},
+		{Name: "rules", Value: "prometheusrule"},
+		{Name: "slo", Value: "http"},
+		{Name: "team", Value: "checkout"},
+	}, info.Labels)
+	require.Equal(t, []prompb.Sample{{Value: 1, Timestamp: now.UnixMilli()}}, info.Samples)
+	require.Equal(t, "pyrra_slo_objective_target", written.Timeseries[1].Labels[0].Value)
+	require.Equal(t, 0.995, written.Timeseries[1].Samples[0].Value)
+
+	// Nothing is pushed without objectives.
+	m.delete(types.NamespacedName{Namespace: "monitoring", Name: "http"})
+	require.NoError(t, w.push(context.Background(), reg, now))
+	require.Equal(t, 1, requests)
+
+	m.set(types.NamespacedName{Namespace: "monitoring", Name: "http"}, objective, rulesPrometheusRule)
+	failing := httptest.NewServer(http.NotFoundHandler())
+	defer failing.Close()
+	w.URL = failing.URL
+	require.ErrorContains(t, w.push(context.Background(), reg, now), "unexpected status code 404")
+}
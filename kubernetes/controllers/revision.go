@@ -0,0 +1,204 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/yaml"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// RevisionReconciler records a ServiceLevelObjectiveRevision for every change of an objective's spec.
+// Revisions are owned by their objective, so they're garbage collected with it,
+// and only the newest HistoryLimit revisions of an objective are kept.
+type RevisionReconciler struct {
+	client.Client
+	// APIReader reads objectives with their managed fields, which are stripped from the cache, to find who changed them.
+	// Revisions don't record who changed the spec if it is nil.
+	APIReader    client.Reader
+	Logger       kitlog.Logger
+	HistoryLimit int
+}
+
+// +kubebuilder:rbac:groups=pyrra.dev,resources=servicelevelobjectiverevisions,verbs=get;list;watch;create;delete
+
+func (r *RevisionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := kitlog.With(r.Logger, "reconciler", "revision", "namespace", req.NamespacedName)
+	level.Debug(logger).Log("msg", "reconciling")
+
+	var kubeObjective pyrrav1alpha1.ServiceLevelObjective
+	if err := r.Get(ctx, req.NamespacedName, &kubeObjective); err != nil {
+		// Revisions of deleted objectives are garbage collected through their owner references.
+		return ctrl.Result{}, client.IgnoreNotFound(fmt.Errorf("getting SLO: %w", err))
+	}
+
+	var list pyrrav1alpha1.ServiceLevelObjectiveRevisionList
+	if err := r.List(ctx, &list,
+		client.InNamespace(req.Namespace),
+		client.MatchingLabels{pyrrav1alpha1.RevisionObjectiveLabel: req.Name},
+	); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list revisions: %w", err)
+	}
+	revisions := list.Items
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Spec.Generation > revisions[j].Spec.Generation
+	})
+
+	if len(revisions) == 0 || revisions[0].Spec.Generation < kubeObjective.GetGeneration() {
+		var previous *pyrrav1alpha1.ServiceLevelObjectiveRevision
+		if len(revisions) > 0 {
+			previous = &revisions[0]
+		}
+
+		revision, err := r.makeRevision(ctx, kubeObjective, previous)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		level.Info(logger).Log("msg", "recording revision", "generation", revision.Spec.Generation, "changed_by", revision.Spec.ChangedBy)
+		if err := r.Create(ctx, revision); err != nil && !errors.IsAlreadyExists(err) {
+			return ctrl.Result{}, fmt.Errorf("failed to create revision: %w", err)
+		}
+		revisions = append([]pyrrav1alpha1.ServiceLevelObjectiveRevision{*revision}, revisions...)
+	}
+
+	if r.HistoryLimit > 0 && len(revisions) > r.HistoryLimit {
+		for _, revision := range revisions[r.HistoryLimit:] {
+			level.Debug(logger).Log("msg", "deleting revision", "generation", revision.Spec.Generation)
+			if err := r.Delete(ctx, &revision); client.IgnoreNotFound(err) != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to delete revision: %w", err)
+			}
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *RevisionReconciler) makeRevision(
+	ctx context.Context,
+	kubeObjective pyrrav1alpha1.ServiceLevelObjective,
+	previous *pyrrav1alpha1.ServiceLevelObjectiveRevision,
+) (*pyrrav1alpha1.ServiceLevelObjectiveRevision, error) {
+	changedBy, changedAt := "", metav1.NewTime(time.Now())
+	if r.APIReader != nil {
+		var uncached pyrrav1alpha1.ServiceLevelObjective
+		if err := r.APIReader.Get(ctx, client.ObjectKeyFromObject(&kubeObjective), &uncached); err != nil {
+			return nil, client.IgnoreNotFound(fmt.Errorf("getting SLO: %w", err))
+		}
+		if manager, at, ok := lastSpecChange(uncached.GetManagedFields()); ok {
+			changedBy, changedAt = manager, at
+		}
+	}
+
+	var diff string
+	if previous != nil {
+		var err error
+		diff, err = specDiff(previous.Spec, kubeObjective.Spec, kubeObjective.GetGeneration())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	controller := true
+	return &pyrrav1alpha1.ServiceLevelObjectiveRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", kubeObjective.GetName(), kubeObjective.GetGeneration()),
+			Namespace: kubeObjective.GetNamespace(),
+			Labels:    map[string]string{pyrrav1alpha1.RevisionObjectiveLabel: kubeObjective.GetName()},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: pyrrav1alpha1.GroupVersion.String(),
+				Kind:       "ServiceLevelObjective",
+				Name:       kubeObjective.GetName(),
+				UID:        kubeObjective.GetUID(),
+				Controller: &controller,
+			}},
+		},
+		Spec: pyrrav1alpha1.ServiceLevelObjectiveRevisionSpec{
+			Objective:     kubeObjective.GetName(),
+			Generation:    kubeObjective.GetGeneration(),
+			ChangedBy:     changedBy,
+			ChangedAt:     changedAt,
+			ObjectiveSpec: *kubeObjective.Spec.DeepCopy(),
+			Diff:          diff,
+		},
+	}, nil
+}
+
+// lastSpecChange returns the field manager that changed the spec most recently and when.
+func lastSpecChange(managedFields []metav1.ManagedFieldsEntry) (string, metav1.Time, bool) {
+	var (
+		last  metav1.ManagedFieldsEntry
+		found bool
+	)
+	for _, entry := range managedFields {
+		if entry.Subresource != "" || entry.Time == nil || entry.FieldsV1 == nil {
+			continue
+		}
+		if !strings.Contains(string(entry.FieldsV1.Raw), `"f:spec"`) {
+			continue
+		}
+		if !found || entry.Time.After(last.Time.Time) {
+			last, found = entry, true
+		}
+	}
+	if !found {
+		return "", metav1.Time{}, false
+	}
+	return last.Manager, *last.Time, true
+}
+
+// specDiff returns the unified diff of the previous revision's spec to the spec as YAML.
+func specDiff(previous pyrrav1alpha1.ServiceLevelObjectiveRevisionSpec, spec pyrrav1alpha1.ServiceLevelObjectiveSpec, generation int64) (string, error) {
+	a, err := yaml.Marshal(previous.ObjectiveSpec)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal spec: %w", err)
+	}
+	b, err := yaml.Marshal(spec)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal spec: %w", err)
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(a)),
+		B:        difflib.SplitLines(string(b)),
+		FromFile: fmt.Sprintf("generation %d", previous.Generation),
+		ToFile:   fmt.Sprintf("generation %d", generation),
+		Context:  3,
+	})
+}
+
+func (r *RevisionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("revision").
+		For(&pyrrav1alpha1.ServiceLevelObjective{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&pyrrav1alpha1.ServiceLevelObjectiveRevision{}).
+		Complete(r)
+}
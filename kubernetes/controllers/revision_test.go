@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func TestRevisionReconciler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+	objective.Generation = 1
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective).
+		Build()
+
+	r := &RevisionReconciler{Client: c, Logger: kitlog.NewNopLogger(), HistoryLimit: 2}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+	reconcile := func(generation int64, target string) []pyrrav1alpha1.ServiceLevelObjectiveRevision {
+		var o pyrrav1alpha1.ServiceLevelObjective
+		require.NoError(t, c.Get(context.Background(), req.NamespacedName, &o))
+		o.Generation = generation
+		o.Spec.Target = target
+		require.NoError(t, c.Update(context.Background(), &o))
+
+		_, err := r.Reconcile(context.Background(), req)
+		require.NoError(t, err)
+
+		var list pyrrav1alpha1.ServiceLevelObjectiveRevisionList
+		require.NoError(t, c.List(context.Background(), &list, client.InNamespace("monitoring")))
+		return list.Items
+	}
+
+	revisions := reconcile(1, "99")
+	require.Len(t, revisions, 1)
+	require.Equal(t, "http-1", revisions[0].GetName())
+	require.Equal(t, map[string]string{pyrrav1alpha1.RevisionObjectiveLabel: "http"}, revisions[0].GetLabels())
+	require.True(t, metav1.IsControlledBy(&revisions[0], objective))
+	require.Equal(t, "http", revisions[0].Spec.Objective)
+	require.Equal(t, int64(1), revisions[0].Spec.Generation)
+	require.Equal(t, "99", revisions[0].Spec.ObjectiveSpec.Target)
+	require.Empty(t, revisions[0].Spec.Diff)
+
+	// Nothing is recorded without a new generation.
+	revisions = reconcile(1, "99")
+	require.Len(t, revisions, 1)
+
+	revisions = reconcile(2, "95")
+	require.Len(t, revisions, 2)
+	require.Equal(t, "http-2", revisions[1].GetName())
+	require.Equal(t, `--- generation 1
++++ generation 2
+@@ -7,6 +7,6 @@
+     grouping: null
+     total:
+       metric: http_requests_total{job="app"}
+-target: "99"
++target: "95"
+ window: 28d
+ 
+`, revisions[1].Spec.Diff)
+
+	// Only the newest revisions are kept.
+	revisions = reconcile(3, "99")
+	require.Len(t, revisions, 2)
+	require.Equal(t, "http-2", revisions[0].GetName())
+	require.Equal(t, "http-3", revisions[1].GetName())
+}
+
+func TestLastSpecChange(t *testing.T) {
+	at := func(minute int) *metav1.Time {
+		t := metav1.NewTime(time.Date(2024, 3, 11, 9, minute, 0, 0, time.UTC))
+		return &t
+	}
+	fields := func(raw string) *metav1.FieldsV1 {
+		return &metav1.FieldsV1{Raw: []byte(raw)}
+	}
+
+	_, _, ok := lastSpecChange(nil)
+	require.False(t, ok)
+
+	manager, changedAt, ok := lastSpecChange([]metav1.ManagedFieldsEntry{{
+		Manager:  "kubectl-client-side-apply",
+		Time:     at(1),
+		FieldsV1: fields(`{"f:spec":{"f:target":{}}}`),
+	}, {
+		Manager:  "kubectl-edit",
+		Time:     at(5),
+		FieldsV1: fields(`{"f:spec":{"f:window":{}}}`),
+	}, {
+		Manager:  "kubectl-label",
+		Time:     at(10),
+		FieldsV1: fields(`{"f:metadata":{"f:labels":{}}}`),
+	}, {
+		Manager:     "pyrra",
+		Time:        at(15),
+		Subresource: "status",
+		FieldsV1:    fields(`{"f:status":{"f:type":{}}}`),
+	}})
+	require.True(t, ok)
+	require.Equal(t, "kubectl-edit", manager)
+	require.Equal(t, *at(5), changedAt)
+}
@@ -18,85 +18,554 @@ package controllers
 
 import (
 	"context"
+	goerrors "errors"
 	"fmt"
+	"math/rand"
+	"strings"
+	"text/template"
+	"time"
 
 	kitlog "github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
 	"github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring"
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/common/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
 	"github.com/pyrra-dev/pyrra/slo"
 )
 
+// tracer creates the spans of reconciles, one for generating the rules, one per output written
+// and one per write to Kubernetes or call to a ruler or remote-write endpoint.
+var tracer = otel.Tracer("github.com/pyrra-dev/pyrra/kubernetes/controllers")
+
+// startSpan starts a span whose status is set from the error pointed to once it ends.
+func startSpan(ctx context.Context, name string, err *error, attrs ...attribute.KeyValue) (context.Context, func()) {
+	ctx, span := tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+	return ctx, func() {
+		if *err != nil {
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+		span.End()
+	}
+}
+
 // ServiceLevelObjectiveReconciler reconciles a ServiceLevelObjective object.
 type ServiceLevelObjectiveReconciler struct {
 	client.Client
 	Logger        kitlog.Logger
 	Scheme        *runtime.Scheme
 	ConfigMapMode bool
-	GenericRules  bool
+	// ConfigMapShards merges the rule files of objectives written to ConfigMaps into this many ConfigMaps per namespace,
+	// picked by the hash of the objective's name, instead of one ConfigMap per objective. It's one per objective if 0.
+	ConfigMapShards int
+	GenericRules    bool
+	// LokiRuler is used for objectives annotated with pyrra.dev/ruler: loki.
+	// If it is nil, their rules are written to ConfigMaps for the Loki rules sidecar instead.
+	LokiRuler *LokiRuler
+	// LokiRulerConfig reads the Loki ruler from a Secret instead, the LokiRuler is used while the Secret doesn't exist.
+	LokiRulerConfig *LokiRulerConfig
+	// LokiCredentialsSecret is the name of the Secret in the objective's namespace with the credentials for the Loki ruler.
+	// The credentials are read at every reconcile and objectives are reconciled as the Secret changes, so rotated credentials are used right away.
+	// No credentials are used if it's empty or the namespace has no such Secret.
+	LokiCredentialsSecret string
+	// LokiNamespaceTenants routes the rules of objectives to the tenant of their namespace's pyrra.dev/ruler-tenant annotation.
+	// Objectives are reconciled as the annotation changes.
+	LokiNamespaceTenants bool
+	// LokiNamespaceTemplate renders the ruler namespace of the objectives' rule groups from LokiNamespaceData, like {{.Namespace}}/{{.Name}}.
+	// The rule groups are in the ruler namespace named after the objective's namespace if it is nil.
+	LokiNamespaceTemplate *template.Template
+	// LokiGroupIntervals override the evaluation intervals of the rule groups in the Loki ruler.
+	LokiGroupIntervals LokiGroupIntervals
+	// Debounce delays reconciles of changed objectives, to coalesce bursts of updates into one reconcile.
+	Debounce time.Duration
+	// ResyncDelay spreads out the reconciles of periodic resyncs over up to this long.
+	ResyncDelay time.Duration
+	// ResyncInterval reconciles objectives again this long after they were written, plus a random part of the ResyncDelay,
+	// to revert manual changes of their rules sooner than the informers' sync period. It is disabled if 0.
+	ResyncInterval time.Duration
+	// RateLimiter limits the retries of failed reconciles, controller-runtime's default is used if it is nil.
+	RateLimiter workqueue.RateLimiter
+	// MaxConcurrentReconciles is how many objectives are reconciled in parallel, one at a time if it is 0.
+	MaxConcurrentReconciles int
+	// ThanosRuler writes the rules of objectives to ConfigMaps for Thanos Ruler instead of PrometheusRules.
+	// Objectives evaluated by Loki aren't written to Thanos Ruler.
+	ThanosRuler *ThanosRuler
+	// GrafanaAlertRules makes Grafana evaluate the alerts of objectives, through GrafanaAlertRuleGroups of the grafana-operator.
+	// Objectives evaluated by Loki keep their alerts. Prometheus evaluates the alerts if it is nil.
+	GrafanaAlertRules *GrafanaAlertRules
+	// GrafanaDashboards creates a GrafanaDashboard of the grafana-operator for each objective.
+	// No dashboards are created if it is nil.
+	GrafanaDashboards *GrafanaDashboards
+	// GrafanaFolders are the GrafanaFolders the alert rules and dashboards of objectives are created in.
+	// The folderRef of the GrafanaAlertRules and GrafanaDashboards is used if it is nil.
+	GrafanaFolders *GrafanaFolders
+	// AlertmanagerConfigs creates an AlertmanagerConfig with the inhibition rules of each objective with alerting.inhibit.
+	// None are created if it is nil.
+	AlertmanagerConfigs *AlertmanagerConfigs
+	// Backfill creates a Job backfilling the recording rules of each new objective over its window.
+	// Nothing is backfilled if it is nil.
+	Backfill *Backfill
+	// Recorder emits events on objectives as their rules are written and as reconciles fail.
+	// No events are emitted if it is nil.
+	Recorder record.EventRecorder
+	// Destinations are the rulers objectives select with their spec.destination, by name.
+	// Objectives selecting a destination that doesn't exist are invalid.
+	Destinations map[string]Destination
+	// Writers write the rules of objectives to their outputs, in order.
+	// Without any, they're written to Loki rulers and either Thanos Ruler, ConfigMaps or PrometheusRules as set above,
+	// followed by the enabled Grafana outputs.
+	Writers []RuleWriter
+	// Shadow writes changed rules of objectives to PrometheusRules next to the live rules first,
+	// until they have been compared for a while. The rules are replaced right away if it is nil.
+	Shadow *Shadow
+	// MetricsQuerier is used by the webhook to warn about metrics of objectives that don't select any series.
+	// The metrics aren't verified if it is nil.
+	MetricsQuerier BudgetPolicyQuerier
+	// ExternalLabels are added to the rules of all objectives, like cluster or region.
+	// The objectives' own externalLabels take precedence.
+	ExternalLabels map[string]string
+	// RemoteClusters are written the PrometheusRules of objectives evaluated by Prometheus to,
+	// in addition to the operator's own cluster.
+	RemoteClusters RemoteClusters
+	// Cardinality estimates the series of objectives with grouping before their rules are written.
+	// They aren't estimated if it is nil.
+	Cardinality *Cardinality
+	// AlertAnnotations are added to the alerts of all objectives, rendered for each of them.
+	// The objectives' own annotations take precedence.
+	AlertAnnotations AlertAnnotations
+	// Version of Pyrra, set on the generated PrometheusRules and ConfigMaps.
+	Version string
+	// DetectDrift emits events and counts pyrra_generated_object_drift_total as generated PrometheusRules and ConfigMaps
+	// are found changed by others, before they're reverted.
+	DetectDrift bool
+	// Syncs records the outcome of the last reconcile of each objective, for the operator's API.
+	// Nothing is recorded if it is nil.
+	Syncs *ObjectiveSyncs
+	// RuleMutators post-process the rule groups of objectives in order, before they're written to any output.
+	RuleMutators []RuleMutator
+	// PrometheusSelector is the Prometheus evaluating the PrometheusRules of objectives without a destination.
+	// If it adds labels, the ones its ruleSelector requires are added to the PrometheusRules. Nothing is added if it is nil.
+	PrometheusSelector *PrometheusSelector
+
+	cache          ruleGroupCache
+	lokiRuleGroups lokiRuleGroups
 }
 
 // +kubebuilder:rbac:groups=pyrra.dev,resources=servicelevelobjectives,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=pyrra.dev,resources=servicelevelobjectives/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=monitoring.coreos.com,resources=prometheusrules,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=monitoring.coreos.com,resources=prometheusrules/status,verbs=get
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;create;update
+
+func (r *ServiceLevelObjectiveReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, err error) {
+	// The reconcile ID tells the log lines of concurrent reconciles of the same objective apart, even without tracing.
+	reconcileID := uuid.NewString()
+	ctx, end := startSpan(ctx, "Reconcile", &err,
+		attribute.String("namespace", req.Namespace),
+		attribute.String("name", req.Name),
+		attribute.String("reconcile_id", reconcileID),
+	)
+	defer end()
 
-func (r *ServiceLevelObjectiveReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	logger := kitlog.With(r.Logger, "reconciler", "servicelevelobjective", "namespace", req.NamespacedName)
+	logger := kitlog.With(r.Logger, "reconciler", "servicelevelobjective", "namespace", req.NamespacedName, "reconcile_id", reconcileID)
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		logger = kitlog.With(logger, "trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+	}
 	level.Debug(logger).Log("msg", "reconciling")
 
 	var slo pyrrav1alpha1.ServiceLevelObjective
 	if err := r.Get(ctx, req.NamespacedName, &slo); err != nil {
+		if errors.IsNotFound(err) {
+			r.cache.delete(req.NamespacedName)
+			reconciledObjectives.delete(req.NamespacedName)
+			if r.Syncs != nil {
+				r.Syncs.delete(req.NamespacedName)
+			}
+			deleted := &WriterObjective{Request: req, Logger: logger}
+			for _, w := range r.writers() {
+				if err := w.Delete(ctx, deleted); err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+			return ctrl.Result{}, nil
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(fmt.Errorf("getting SLO: %w", err))
 	}
+	logger = kitlog.With(logger, "uid", slo.GetUID())
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("uid", string(slo.GetUID())))
+
+	// The reconcilers only change the status in memory, it's written once at the end.
+	status := *slo.Status.DeepCopy()
+	status.ObservedGeneration = slo.GetGeneration()
+
+	paused, deleteAlerts, err := isPaused(slo)
+	if err == nil && paused && (!deleteAlerts || alertsDeletedWhilePaused(status)) {
+		// The rules of paused objectives are kept as they were last written.
+		level.Debug(logger).Log("msg", "objective is paused")
+		r.setPaused(&slo, &status, alertsDeletedWhilePaused(status))
+		return ctrl.Result{}, r.patchStatus(ctx, slo, status)
+	}
+	if !paused {
+		r.setResumed(&slo, &status)
+	}
+	if deleteAlerts {
+		// The rules are written once more without the alerts before the objective is paused.
+		withoutAlerting(&slo)
+	}
+
+	var (
+		result      ctrl.Result
+		destination Destination
+	)
+	if err == nil {
+		destination, err = r.destination(ctx, slo)
+	}
+	if err == nil {
+		err = r.resolveComposite(ctx, &slo)
+	}
+	if err == nil {
+		err = r.withAlertAnnotations(&slo)
+	}
+	if err == nil {
+		err = r.checkCardinality(ctx, logger, &slo, &status)
+	}
+	r.withExternalLabels(&slo)
+	writerObjective := &WriterObjective{
+		Request:     req,
+		Logger:      logger,
+		Objective:   slo,
+		Destination: destination,
+		Status:      &status,
+	}
+	if err == nil {
+		result, err = r.write(ctx, writerObjective)
+	}
+
+	if err == nil {
+		r.ruleNamesEvent(&slo)
+		status.Window = slo.Spec.Window
+		status.StableRuleNames = slo.Spec.StableRuleNames
+		if paused {
+			r.setPaused(&slo, &status, true)
+		}
+	}
+
+	// Failures are recorded in the conditions and events, so they show up with kubectl describe.
+	setConditions(&status, slo.GetGeneration(), err)
+	r.recordSync(ctx, slo, status, writerObjective.Rules, err)
+	if err != nil {
+		r.event(&slo, corev1.EventTypeWarning, failureReason(err), "%s", err)
+		if patchErr := r.patchStatus(ctx, slo, status); patchErr != nil {
+			level.Warn(logger).Log("msg", "failed to record failure in status", "err", patchErr)
+		}
+		if isInvalidObjective(err) {
+			level.Warn(logger).Log("msg", "objective is invalid", "err", err)
+			return ctrl.Result{}, nil
+		}
+		return result, err
+	}
+
+	if r.ResyncInterval > 0 {
+		resync := r.ResyncInterval
+		if r.ResyncDelay > 0 {
+			resync += time.Duration(rand.Int63n(int64(r.ResyncDelay)))
+		}
+		if result.RequeueAfter == 0 || resync < result.RequeueAfter {
+			result.RequeueAfter = resync
+		}
+	}
+	return result, r.patchStatus(ctx, slo, status)
+}
+
+// write applies the reconciler's writers to the objective.
+// A failing output doesn't keep the others from being written, like a ruler that is down the PrometheusRules,
+// only invalid objectives stop at the first writer as none can write them,
+// or before the first one if any of the writers can't write them.
+// The errors of all failed writers are returned, as partialWriteError if the rules were written to another output,
+// so they're still evaluated.
+// It requeues the objective after the shortest time any writer asked for.
+func (r *ServiceLevelObjectiveReconciler) write(ctx context.Context, o *WriterObjective) (ctrl.Result, error) {
+	var (
+		result  ctrl.Result
+		errs    []error
+		written bool
+	)
+	writers := r.writers()
+	if err := checkOutputs(writers, o); err != nil {
+		return result, err
+	}
+
+	logger := o.Logger
+	defer func() { o.Logger = logger }()
+	for _, w := range writers {
+		outputs := len(o.Rules)
+		o.Logger = kitlog.With(logger, "output", outputName(w))
+		res, err := r.apply(ctx, w, o)
+		if res.RequeueAfter > 0 && (result.RequeueAfter == 0 || res.RequeueAfter < result.RequeueAfter) {
+			result.RequeueAfter = res.RequeueAfter
+		}
+		result.Requeue = result.Requeue || res.Requeue
+		if isInvalidObjective(err) {
+			return result, err
+		}
+		if err != nil {
+			level.Warn(o.Logger).Log("msg", "failed to write objective", "err", err)
+			errs = append(errs, err)
+			continue
+		}
+		// Writers only add their output to the rules if they wrote the objective's rules.
+		written = written || len(o.Rules) > outputs
+	}
+	err := goerrors.Join(errs...)
+	if err != nil && written {
+		err = partialWriteError{err: err}
+	}
+	if objective, internalErr := o.Objective.Internal(); internalErr == nil && len(o.Rules) > 0 {
+		reconciledObjectives.set(o.Request.NamespacedName, objective, strings.Join(o.Rules, ","))
+	}
+	return result, err
+}
+
+// apply applies the writer to the objective within a span of its output,
+// so slow outputs of a reconcile stand out in its trace.
+func (r *ServiceLevelObjectiveReconciler) apply(ctx context.Context, w RuleWriter, o *WriterObjective) (_ ctrl.Result, err error) {
+	ctx, end := startSpan(ctx, "write output", &err, attribute.String("output", outputName(w)))
+	defer end()
+	return w.Apply(ctx, o)
+}
+
+// withExternalLabels adds the reconciler's ExternalLabels to the ones of the objective, which take precedence.
+// Only the objective in memory is changed, for its rules to be generated with them.
+func (r *ServiceLevelObjectiveReconciler) withExternalLabels(kubeObjective *pyrrav1alpha1.ServiceLevelObjective) {
+	if len(r.ExternalLabels) == 0 {
+		return
+	}
+	externalLabels := make(map[string]string, len(r.ExternalLabels)+len(kubeObjective.Spec.ExternalLabels))
+	for name, value := range r.ExternalLabels {
+		externalLabels[name] = value
+	}
+	for name, value := range kubeObjective.Spec.ExternalLabels {
+		externalLabels[name] = value
+	}
+	kubeObjective.Spec.ExternalLabels = externalLabels
+}
+
+// recordSync records the outcome of the reconcile with the objective's rule groups, if the reconciler has Syncs.
+func (r *ServiceLevelObjectiveReconciler) recordSync(ctx context.Context, kubeObjective pyrrav1alpha1.ServiceLevelObjective, status pyrrav1alpha1.ServiceLevelObjectiveStatus, outputs []string, err error) {
+	if r.Syncs == nil {
+		return
+	}
+	var groups []monitoringv1.RuleGroup
+	if IsLokiObjective(kubeObjective.GetAnnotations()) {
+		groups, _ = r.ruleGroups(ctx, kubeObjective)
+	} else {
+		groups, _ = r.prometheusRuleGroups(ctx, kubeObjective)
+	}
+	r.Syncs.record(ObjectiveSync{
+		Namespace:   kubeObjective.GetNamespace(),
+		Name:        kubeObjective.GetName(),
+		Generation:  kubeObjective.GetGeneration(),
+		LastSync:    time.Now(),
+		Backend:     status.Type,
+		RuleName:    status.RuleName,
+		Outputs:     outputs,
+		Destination: kubeObjective.Spec.Destination,
+	}, groups, err)
+}
+
+// event records an event on the objective, if the reconciler has a recorder.
+func (r *ServiceLevelObjectiveReconciler) event(kubeObjective *pyrrav1alpha1.ServiceLevelObjective, eventType, reason, messageFmt string, args ...interface{}) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(kubeObjective, eventType, reason, messageFmt, args...)
+}
+
+// ruleNamesEvent records an event with a migration note if the names of the objective's recording rules changed
+// since they were last written, as the renamed rules start without the history of the previous ones.
+func (r *ServiceLevelObjectiveReconciler) ruleNamesEvent(kubeObjective *pyrrav1alpha1.ServiceLevelObjective) {
+	previous, current := kubeObjective.Status, kubeObjective.Spec
+	if previous.Window == "" {
+		return
+	}
+	previousWindow, err := model.ParseDuration(previous.Window)
+	if err != nil {
+		return
+	}
+	window, err := model.ParseDuration(current.Window)
+	if err != nil {
+		return
+	}
 
-	if r.ConfigMapMode {
-		return r.reconcileConfigMap(ctx, logger, req, slo)
+	switch {
+	case !previous.StableRuleNames && current.StableRuleNames:
+		r.event(kubeObjective, corev1.EventTypeNormal, reasonRulesRenamed,
+			"Recording rules are named without the window now, like :increase instead of :increase%s, and keep their history as the window changes. Their history until now is only in the series of the old names.", previousWindow)
+	case previous.StableRuleNames && !current.StableRuleNames:
+		r.event(kubeObjective, corev1.EventTypeNormal, reasonRulesRenamed,
+			"Recording rules are named with the window again, like :increase%s instead of :increase. Their history until now is only in the series of the old names.", window)
+	case previousWindow == window:
+	case current.StableRuleNames:
+		r.event(kubeObjective, corev1.EventTypeNormal, reasonWindowChanged,
+			"The window changed from %s to %s. The recording rules keep their names and history, which covers the %s window until %s passed.", previousWindow, window, previousWindow, window)
+	default:
+		r.event(kubeObjective, corev1.EventTypeWarning, reasonRulesRenamed,
+			"The window changed from %s to %s, renaming the recording rules from :increase%s to :increase%s, which start without history. Set spec.stableRuleNames to keep it across window changes.", previousWindow, window, previousWindow, window)
 	}
+}
+
+// grafanaAlerts returns true if Grafana evaluates the alerts of the objective instead of Prometheus.
+func (r *ServiceLevelObjectiveReconciler) grafanaAlerts(kubeObjective pyrrav1alpha1.ServiceLevelObjective) bool {
+	return r.GrafanaAlertRules != nil && !IsLokiObjective(kubeObjective.GetAnnotations())
+}
 
-	return r.reconcilePrometheusRule(ctx, logger, req, slo)
+// ruleGroups returns the rule groups of the objective, mutated by the RuleMutators.
+func (r *ServiceLevelObjectiveReconciler) ruleGroups(ctx context.Context, kubeObjective pyrrav1alpha1.ServiceLevelObjective) ([]monitoringv1.RuleGroup, error) {
+	return r.cache.get(ctx, kubeObjective, r.GenericRules, r.RuleMutators)
 }
 
-func (r *ServiceLevelObjectiveReconciler) reconcilePrometheusRule(ctx context.Context, logger kitlog.Logger, req ctrl.Request, kubeObjective pyrrav1alpha1.ServiceLevelObjective) (ctrl.Result, error) {
-	newRule, err := makePrometheusRule(kubeObjective, r.GenericRules)
+// prometheusRuleGroups returns the rule groups of the objective for Prometheus,
+// without the alerts if Grafana evaluates them.
+func (r *ServiceLevelObjectiveReconciler) prometheusRuleGroups(ctx context.Context, kubeObjective pyrrav1alpha1.ServiceLevelObjective) ([]monitoringv1.RuleGroup, error) {
+	groups, err := r.ruleGroups(ctx, kubeObjective)
+	if err != nil {
+		return nil, err
+	}
+	if r.grafanaAlerts(kubeObjective) {
+		return withoutAlerts(groups), nil
+	}
+	return groups, nil
+}
+
+// patchStatus writes the status of the objective if it changed.
+// The merge patch only contains the changed fields and no resource version,
+// so it doesn't conflict with updates of the objective that happened since it was read.
+func (r *ServiceLevelObjectiveReconciler) patchStatus(ctx context.Context, kubeObjective pyrrav1alpha1.ServiceLevelObjective, status pyrrav1alpha1.ServiceLevelObjectiveStatus) (err error) {
+	if equality.Semantic.DeepEqual(kubeObjective.Status, status) {
+		return nil
+	}
+
+	ctx, end := startSpan(ctx, "patch status", &err)
+	defer end()
+
+	patch := client.MergeFrom(kubeObjective.DeepCopy())
+	kubeObjective.Status = status
+	if err := r.Status().Patch(ctx, &kubeObjective, patch); err != nil {
+		return client.IgnoreNotFound(fmt.Errorf("failed to patch status: %w", err))
+	}
+	return nil
+}
+
+func (r *ServiceLevelObjectiveReconciler) reconcilePrometheusRule(
+	ctx context.Context,
+	logger kitlog.Logger,
+	req ctrl.Request,
+	kubeObjective pyrrav1alpha1.ServiceLevelObjective,
+	destination Destination,
+	status *pyrrav1alpha1.ServiceLevelObjectiveStatus,
+) (ctrl.Result, error) {
+	newRule, err := generate(ctx, func() (*monitoringv1.PrometheusRule, error) {
+		groups, err := r.prometheusRuleGroups(ctx, kubeObjective)
+		if err != nil {
+			return nil, err
+		}
+		return newPrometheusRule(kubeObjective, groups), nil
+	})
 	if err != nil {
 		return ctrl.Result{}, err
 	}
+	destination.withLabels(newRule)
+	if r.PrometheusSelector != nil && r.PrometheusSelector.AddLabels && r.PrometheusSelector.applies(kubeObjective) {
+		if err := r.selectRuleLabels(ctx, newRule); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
 
-	var rule monitoringv1.PrometheusRule
-	if err := r.Get(ctx, req.NamespacedName, &rule); err != nil {
-		if errors.IsNotFound(err) {
-			level.Info(logger).Log("msg", "creating prometheus rule", "namespace", rule.GetNamespace(), "name", rule.GetName())
-			if err := r.Create(ctx, newRule); err != nil {
-				return ctrl.Result{}, err
-			}
-		} else {
-			return ctrl.Result{}, fmt.Errorf("failed to get prometheus rule: %w", err)
+	var result ctrl.Result
+	if r.Shadow != nil {
+		result.RequeueAfter, err = r.shadowPrometheusRule(ctx, logger, &kubeObjective, newRule)
+		if err != nil {
+			return ctrl.Result{}, err
 		}
 	}
 
-	newRule.ResourceVersion = rule.ResourceVersion
+	if err := r.writePrometheusRule(ctx, logger, req, &kubeObjective, newRule); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	status.Type = "PrometheusRule"
+	status.RuleName = newRule.GetName()
+
+	return result, nil
+}
 
-	level.Info(logger).Log("msg", "updating prometheus rule", "namespace", rule.GetNamespace(), "name", rule.GetName())
-	if err := r.Update(ctx, newRule); err != nil {
-		return ctrl.Result{}, fmt.Errorf("failed to update prometheus rule: %w", err)
+func (r *ServiceLevelObjectiveReconciler) writePrometheusRule(
+	ctx context.Context,
+	logger kitlog.Logger,
+	req ctrl.Request,
+	kubeObjective *pyrrav1alpha1.ServiceLevelObjective,
+	newRule *monitoringv1.PrometheusRule,
+) (err error) {
+	ctx, end := startSpan(ctx, "write PrometheusRule", &err)
+	defer end()
+
+	if err := r.setProvenance(newRule, kubeObjective); err != nil {
+		return err
+	}
+	hash, err := setAppliedHash(newRule, newRule.Spec)
+	if err != nil {
+		return err
 	}
 
-	kubeObjective.Status.Type = "PrometheusRule"
-	if err := r.Status().Update(ctx, &kubeObjective); err != nil {
-		return ctrl.Result{}, err
+	var rule monitoringv1.PrometheusRule
+	created := false
+	if err := r.Get(ctx, req.NamespacedName, &rule); err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get prometheus rule: %w", err)
+		}
+		created = true
+	} else if rule.GetAnnotations()[AppliedHashAnnotation] == hash {
+		if equality.Semantic.DeepEqual(rule.Spec, newRule.Spec) {
+			level.Debug(logger).Log("msg", "prometheus rule is up to date", "namespace", rule.GetNamespace(), "name", rule.GetName())
+			return nil
+		}
+		r.drifted(logger, kubeObjective, "PrometheusRule", &rule)
 	}
 
-	return ctrl.Result{}, nil
+	level.Info(logger).Log("msg", "applying prometheus rule", "namespace", newRule.GetNamespace(), "name", newRule.GetName())
+	setGeneratedAt(newRule)
+	if err := r.applyObject(ctx, newRule); err != nil {
+		return fmt.Errorf("failed to apply prometheus rule: %w", err)
+	}
+	if created {
+		r.event(kubeObjective, corev1.EventTypeNormal, reasonRulesCreated, "Created PrometheusRule %s", newRule.GetName())
+	} else {
+		r.event(kubeObjective, corev1.EventTypeNormal, reasonRulesUpdated, "Updated PrometheusRule %s", newRule.GetName())
+	}
+	return nil
 }
 
 func (r *ServiceLevelObjectiveReconciler) reconcileConfigMap(
@@ -104,60 +573,321 @@ func (r *ServiceLevelObjectiveReconciler) reconcileConfigMap(
 	logger kitlog.Logger,
 	req ctrl.Request,
 	kubeObjective pyrrav1alpha1.ServiceLevelObjective,
+	destination Destination,
+	status *pyrrav1alpha1.ServiceLevelObjectiveStatus,
 ) (ctrl.Result, error) {
 	name := fmt.Sprintf("pyrra-recording-rule-%s", kubeObjective.GetName())
 
-	newConfigMap, err := makeConfigMap(name, kubeObjective, r.GenericRules)
+	newConfigMap, err := generate(ctx, func() (*corev1.ConfigMap, error) {
+		groups, err := r.prometheusRuleGroups(ctx, kubeObjective)
+		if err != nil {
+			return nil, err
+		}
+		return newConfigMap(name, kubeObjective, groups)
+	})
 	if err != nil {
 		return ctrl.Result{}, err
 	}
+	if IsLokiObjective(kubeObjective.GetAnnotations()) {
+		labels := make(map[string]string, len(newConfigMap.Labels)+1)
+		for k, v := range newConfigMap.Labels {
+			labels[k] = v
+		}
+		labels[lokiRuleLabel] = ""
+		newConfigMap.Labels = labels
+	}
+	destination.withLabels(newConfigMap)
+
+	if _, err := r.writeConfigMap(ctx, logger, &kubeObjective, newConfigMap); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	status.Type = "ConfigMap"
+	status.RuleName = newConfigMap.GetName()
+
+	return ctrl.Result{}, nil
+}
+
+func (r *ServiceLevelObjectiveReconciler) writeConfigMap(
+	ctx context.Context,
+	logger kitlog.Logger,
+	kubeObjective *pyrrav1alpha1.ServiceLevelObjective,
+	newConfigMap *corev1.ConfigMap,
+) (changed bool, err error) {
+	ctx, end := startSpan(ctx, "write ConfigMap", &err)
+	defer end()
+
+	if err := r.setProvenance(newConfigMap, kubeObjective); err != nil {
+		return false, err
+	}
+	hash, err := setAppliedHash(newConfigMap, newConfigMap.Data)
+	if err != nil {
+		return false, err
+	}
 
 	var existingConfigMap corev1.ConfigMap
-	if err := r.Get(ctx, client.ObjectKey{
-		Namespace: req.Namespace,
-		Name:      name,
-	}, &existingConfigMap); err != nil {
-		if errors.IsNotFound(err) {
-			level.Info(logger).Log("msg", "creating config map", "namespace", newConfigMap.GetNamespace(), "name", newConfigMap.GetName())
-			if err := r.Create(ctx, newConfigMap); err != nil {
-				return ctrl.Result{}, fmt.Errorf("failed to create config map: %w", err)
-			}
-		} else {
-			return ctrl.Result{}, fmt.Errorf("failed to get config map: %w", err)
+	created := false
+	if err := r.Get(ctx, client.ObjectKeyFromObject(newConfigMap), &existingConfigMap); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to get config map: %w", err)
 		}
+		created = true
+	} else if existingConfigMap.GetAnnotations()[AppliedHashAnnotation] == hash {
+		if equality.Semantic.DeepEqual(existingConfigMap.Data, newConfigMap.Data) {
+			level.Debug(logger).Log("msg", "config map is up to date", "namespace", existingConfigMap.GetNamespace(), "name", existingConfigMap.GetName())
+			return false, nil
+		}
+		r.drifted(logger, kubeObjective, "ConfigMap", &existingConfigMap)
 	}
 
-	newConfigMap.ResourceVersion = existingConfigMap.ResourceVersion
+	level.Info(logger).Log("msg", "applying config map", "namespace", newConfigMap.GetNamespace(), "name", newConfigMap.GetName())
+	setGeneratedAt(newConfigMap)
+	if err := r.applyObject(ctx, newConfigMap); err != nil {
+		return false, fmt.Errorf("failed to apply config map: %w", err)
+	}
+	if created {
+		r.event(kubeObjective, corev1.EventTypeNormal, reasonRulesCreated, "Created ConfigMap %s", newConfigMap.GetName())
+	} else {
+		r.event(kubeObjective, corev1.EventTypeNormal, reasonRulesUpdated, "Updated ConfigMap %s", newConfigMap.GetName())
+	}
+	return true, nil
+}
 
-	level.Info(logger).Log("msg", "updating config map", "namespace", newConfigMap.GetNamespace(), "name", newConfigMap.GetName())
-	if err := r.Update(ctx, newConfigMap); err != nil {
-		return ctrl.Result{}, fmt.Errorf("failed to update config map: %w", err)
+func (r *ServiceLevelObjectiveReconciler) reconcileLokiRuler(
+	ctx context.Context,
+	logger kitlog.Logger,
+	req ctrl.Request,
+	kubeObjective pyrrav1alpha1.ServiceLevelObjective,
+	destination Destination,
+	status *pyrrav1alpha1.ServiceLevelObjectiveStatus,
+) (ctrl.Result, error) {
+	groups, err := generate(ctx, func() ([]monitoringv1.RuleGroup, error) {
+		return r.ruleGroups(ctx, kubeObjective)
+	})
+	if err != nil {
+		return ctrl.Result{}, err
 	}
 
-	kubeObjective.Status.Type = "ConfigMap"
-	if err := r.Status().Update(ctx, &kubeObjective); err != nil {
+	ruler, err := r.lokiRuler(ctx, destination.LokiRuler, req.Namespace)
+	if err != nil {
 		return ctrl.Result{}, err
 	}
+	rulerNamespace, err := r.lokiRulerNamespace(req)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	existing, err := r.lokiRuleGroups.hashes(ctx, ruler, rulerNamespace)
+	if err != nil {
+		return ctrl.Result{}, rulerPushError{err: err}
+	}
+	for _, group := range groups {
+		group = r.LokiGroupIntervals.apply(kubeObjective, group)
+		if err := r.pushLokiRuleGroup(ctx, logger, ruler, &kubeObjective, rulerNamespace, existing, group); err != nil {
+			r.lokiRuleGroups.forget(ruler.namespaceKey(rulerNamespace))
+			return ctrl.Result{}, rulerPushError{err: fmt.Errorf("failed to update loki rule group %s: %w", group.Name, err)}
+		}
+	}
+
+	status.Type = lokiRulerStatusType
+	// The rule groups are named after the objective, in the ruler namespace of the objective.
+	status.RuleName = kubeObjective.GetName()
 
 	return ctrl.Result{}, nil
 }
 
+func (r *ServiceLevelObjectiveReconciler) pushLokiRuleGroup(
+	ctx context.Context,
+	logger kitlog.Logger,
+	ruler *LokiRuler,
+	kubeObjective *pyrrav1alpha1.ServiceLevelObjective,
+	namespace string,
+	existing map[string]string,
+	group monitoringv1.RuleGroup,
+) (err error) {
+	ctx, end := startSpan(ctx, "push Loki rule group", &err, attribute.String("group", group.Name))
+	defer end()
+
+	hash := ruleGroupHash(group)
+	previous, exists := existing[group.Name]
+	if previous == hash {
+		level.Debug(logger).Log("msg", "loki rule group is up to date", "namespace", namespace, "name", group.Name)
+		return nil
+	}
+
+	level.Info(logger).Log("msg", "updating loki rule group", "namespace", namespace, "name", group.Name)
+	if err := ruler.SetRuleGroup(ctx, namespace, group); err != nil {
+		return err
+	}
+	r.lokiRuleGroups.set(ruler.namespaceKey(namespace), group.Name, hash)
+	if !exists {
+		r.event(kubeObjective, corev1.EventTypeNormal, reasonRulesCreated, "Created rule group %s in the Loki ruler", group.Name)
+	} else {
+		r.event(kubeObjective, corev1.EventTypeNormal, reasonRulesUpdated, "Updated rule group %s in the Loki ruler", group.Name)
+	}
+	return nil
+}
+
+// lokiRulerNamespace returns the ruler namespace of the objective's rule groups.
+func (r *ServiceLevelObjectiveReconciler) lokiRulerNamespace(req ctrl.Request) (string, error) {
+	if r.LokiNamespaceTemplate == nil {
+		return req.Namespace, nil
+	}
+	var sb strings.Builder
+	if err := r.LokiNamespaceTemplate.Execute(&sb, LokiNamespaceData{Namespace: req.Namespace, Name: req.Name}); err != nil {
+		return "", fmt.Errorf("failed to render loki ruler namespace: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// lokiRuler returns the Loki ruler with the credentials of the namespace, if it has a credentials Secret,
+// and the tenant of the namespace's pyrra.dev/ruler-tenant annotation, which takes precedence over the Secret's tenant.
+func (r *ServiceLevelObjectiveReconciler) lokiRuler(ctx context.Context, ruler *LokiRuler, namespace string) (*LokiRuler, error) {
+	credentials := ruler.credentials
+	if r.LokiCredentialsSecret != "" {
+		var secret corev1.Secret
+		err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: r.LokiCredentialsSecret}, &secret)
+		if client.IgnoreNotFound(err) != nil {
+			return nil, fmt.Errorf("failed to get loki ruler credentials: %w", err)
+		}
+		if err == nil {
+			credentials = lokiCredentialsFromSecret(secret)
+		}
+	}
+
+	if r.LokiNamespaceTenants {
+		var ns corev1.Namespace
+		err := r.Get(ctx, client.ObjectKey{Name: namespace}, &ns)
+		if client.IgnoreNotFound(err) != nil {
+			return nil, fmt.Errorf("failed to get namespace: %w", err)
+		}
+		if tenant := ns.GetAnnotations()[RulerTenantAnnotation]; tenant != "" {
+			credentials.Tenant = tenant
+		}
+	}
+
+	if credentials == ruler.credentials {
+		return ruler, nil
+	}
+	return ruler.WithCredentials(credentials), nil
+}
+
+// lokiObjectivesForSecret returns the requests of all Loki objectives using the credentials of the Secret.
+func (r *ServiceLevelObjectiveReconciler) lokiObjectivesForSecret(ctx context.Context, secret client.Object) []reconcile.Request {
+	if secret.GetName() != r.LokiCredentialsSecret {
+		return nil
+	}
+	return r.lokiObjectivesInNamespace(ctx, secret.GetNamespace())
+}
+
+// lokiObjectivesForNamespace returns the requests of all Loki objectives in the namespace, whose tenant might have changed.
+func (r *ServiceLevelObjectiveReconciler) lokiObjectivesForNamespace(ctx context.Context, namespace client.Object) []reconcile.Request {
+	return r.lokiObjectivesInNamespace(ctx, namespace.GetName())
+}
+
+func (r *ServiceLevelObjectiveReconciler) lokiObjectivesInNamespace(ctx context.Context, namespace string) []reconcile.Request {
+	var list pyrrav1alpha1.ServiceLevelObjectiveList
+	if err := r.List(ctx, &list, client.InNamespace(namespace)); err != nil {
+		level.Warn(r.Logger).Log("msg", "failed to list objectives for loki ruler", "namespace", namespace, "err", err)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, o := range list.Items {
+		if IsLokiObjective(o.GetAnnotations()) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&o)})
+		}
+	}
+	return requests
+}
+
+// generate runs the generation of rules within its own span.
+func generate[T any](ctx context.Context, f func() (T, error)) (_ T, err error) {
+	_, end := startSpan(ctx, "generate", &err)
+	defer end()
+	return f()
+}
+
+// deleteLokiRuleGroups deletes the rule groups of the deleted objective from all Loki rulers,
+// as its destination is unknown once it's gone.
+func (r *ServiceLevelObjectiveReconciler) deleteLokiRuleGroups(ctx context.Context, logger kitlog.Logger, req ctrl.Request) error {
+	rulerNamespace, err := r.lokiRulerNamespace(req)
+	if err != nil {
+		return err
+	}
+	// The config is read first, so the rule groups are deleted from the ruler of the current config.
+	if _, err := r.defaultLokiRuler(ctx); err != nil {
+		return err
+	}
+
+	for _, base := range r.lokiRulers() {
+		ruler, err := r.lokiRuler(ctx, base, req.Namespace)
+		if err != nil {
+			return err
+		}
+		// All rule groups that can be generated are deleted, as the deleted objective's spec is unknown.
+		for _, suffix := range slo.RuleGroupSuffixes {
+			name := req.Name + suffix
+			level.Debug(logger).Log("msg", "deleting loki rule group", "ruler", ruler.URL, "namespace", rulerNamespace, "name", name)
+			if err := ruler.DeleteRuleGroup(ctx, rulerNamespace, name); err != nil {
+				r.lokiRuleGroups.forget(ruler.namespaceKey(rulerNamespace))
+				return fmt.Errorf("failed to delete loki rule group: %w", err)
+			}
+			r.lokiRuleGroups.set(ruler.namespaceKey(rulerNamespace), name, "")
+		}
+	}
+	return nil
+}
+
 func (r *ServiceLevelObjectiveReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&pyrrav1alpha1.ServiceLevelObjective{}).
-		Complete(r)
+	b := ctrl.NewControllerManagedBy(mgr).
+		Named("servicelevelobjective").
+		Watches(&pyrrav1alpha1.ServiceLevelObjective{}, debounceHandler{
+			delay:       r.Debounce,
+			resyncDelay: r.ResyncDelay,
+		}).
+		WithOptions(controller.Options{
+			RateLimiter:             r.RateLimiter,
+			MaxConcurrentReconciles: r.MaxConcurrentReconciles,
+		})
+	if r.LokiRulerConfig != nil {
+		b = b.Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.lokiObjectivesForConfigSecret))
+	}
+	if r.lokiEnabled() && r.LokiCredentialsSecret != "" {
+		b = b.Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.lokiObjectivesForSecret))
+	}
+	if r.lokiEnabled() && r.LokiNamespaceTenants {
+		b = b.Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.lokiObjectivesForNamespace),
+			builder.WithPredicates(predicate.AnnotationChangedPredicate{}))
+	}
+	if r.PrometheusSelector != nil && r.PrometheusSelector.AddLabels {
+		b = b.Watches(&monitoringv1.Prometheus{}, handler.EnqueueRequestsFromMapFunc(r.objectivesForPrometheus),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+	}
+	// Composites are generated from the objectives they combine, which are reconciled again when those change.
+	b = b.Watches(&pyrrav1alpha1.ServiceLevelObjective{}, handler.EnqueueRequestsFromMapFunc(r.compositesForObjective),
+		builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+	return b.Complete(r)
 }
 
+// SetupWebhookWithManager registers the validating webhook of objectives,
+// and the conversion webhook if other versions than v1alpha1 are in the manager's scheme.
 func (r *ServiceLevelObjectiveReconciler) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(&pyrrav1alpha1.ServiceLevelObjective{}).
+		WithValidator(&objectiveValidator{
+			logger:  kitlog.With(r.Logger, "webhook", "servicelevelobjective"),
+			client:  mgr.GetClient(),
+			querier: r.MetricsQuerier,
+		}).
 		Complete()
 }
 
-func makeConfigMap(name string, kubeObjective pyrrav1alpha1.ServiceLevelObjective, genericRules bool) (*corev1.ConfigMap, error) {
+// makeRuleGroups returns the increase, burn rate and optionally budget freeze, SLA and generic rule groups of an objective.
+func makeRuleGroups(kubeObjective pyrrav1alpha1.ServiceLevelObjective, genericRules bool) ([]monitoringv1.RuleGroup, error) {
 	objective, err := kubeObjective.Internal()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get objective: %w", err)
+		return nil, invalidObjectiveError{err: fmt.Errorf("failed to get objective: %w", err)}
 	}
 
 	increases, err := objective.IncreaseRules()
@@ -169,8 +899,30 @@ func makeConfigMap(name string, kubeObjective pyrrav1alpha1.ServiceLevelObjectiv
 		return nil, fmt.Errorf("failed to get burn rate rules: %w", err)
 	}
 
-	rule := monitoringv1.PrometheusRuleSpec{
-		Groups: []monitoringv1.RuleGroup{increases, burnrates},
+	groups := []monitoringv1.RuleGroup{increases, burnrates}
+
+	if objective.BudgetFreeze != nil {
+		freeze, err := objective.BudgetFreezeRules()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get budget freeze rules: %w", err)
+		}
+		groups = append(groups, freeze)
+	}
+
+	if objective.Calendar != "" {
+		calendar, err := objective.CalendarRules()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get calendar rules: %w", err)
+		}
+		groups = append(groups, calendar)
+	}
+
+	if objective.SLATarget != nil {
+		sla, err := objective.SLARules()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get SLA rules: %w", err)
+		}
+		groups = append(groups, sla)
 	}
 
 	if genericRules {
@@ -181,18 +933,50 @@ func makeConfigMap(name string, kubeObjective pyrrav1alpha1.ServiceLevelObjectiv
 			}
 			// ignore these rules
 		} else {
-			rule.Groups = append(rule.Groups, rules)
+			groups = append(groups, rules)
 		}
 	}
 
-	bytes, err := yaml.Marshal(rule)
+	objective.RuleGroupOptions(groups)
+	return groups, nil
+}
+
+func makeConfigMap(name string, kubeObjective pyrrav1alpha1.ServiceLevelObjective, genericRules bool) (*corev1.ConfigMap, error) {
+	groups, err := makeRuleGroups(kubeObjective, genericRules)
+	if err != nil {
+		return nil, err
+	}
+	return newConfigMap(name, kubeObjective, groups)
+}
+
+// maxConfigMapSize is the most data a ConfigMap can hold.
+const maxConfigMapSize = 1 << 20
+
+// newConfigMap returns the ConfigMap holding the rule groups of the objective.
+func newConfigMap(name string, kubeObjective pyrrav1alpha1.ServiceLevelObjective, groups []monitoringv1.RuleGroup) (*corev1.ConfigMap, error) {
+	rule := monitoringv1.PrometheusRuleSpec{
+		Groups: groups,
+	}
+
+	var sb strings.Builder
+	size, err := WriteRuleSpec(&sb, rule, 0)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal recording rule: %w", err)
 	}
+	if size > maxConfigMapSize {
+		return nil, fmt.Errorf("rules of %s are %d bytes, more than the %d bytes a ConfigMap can hold", name, size, maxConfigMapSize)
+	}
 
 	data := map[string]string{
-		fmt.Sprintf("%s.rules.yaml", name): string(bytes),
+		fmt.Sprintf("%s.rules.yaml", name): sb.String(),
+	}
+
+	// The objective's label finds ConfigMaps of deleted objectives that lost their owner reference.
+	labels := make(map[string]string, len(kubeObjective.GetLabels())+1)
+	for k, v := range kubeObjective.GetLabels() {
+		labels[k] = v
 	}
+	labels[ObjectiveLabel] = kubeObjective.GetName()
 
 	isController := true
 	return &corev1.ConfigMap{
@@ -203,7 +987,7 @@ func makeConfigMap(name string, kubeObjective pyrrav1alpha1.ServiceLevelObjectiv
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
 			Namespace: kubeObjective.GetNamespace(),
-			Labels:    kubeObjective.GetLabels(),
+			Labels:    labels,
 			OwnerReferences: []metav1.OwnerReference{
 				{
 					APIVersion: kubeObjective.APIVersion,
@@ -218,35 +1002,19 @@ func makeConfigMap(name string, kubeObjective pyrrav1alpha1.ServiceLevelObjectiv
 	}, nil
 }
 
-func makePrometheusRule(kubeObjective pyrrav1alpha1.ServiceLevelObjective, genericRules bool) (*monitoringv1.PrometheusRule, error) {
-	objective, err := kubeObjective.Internal()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get objective: %w", err)
-	}
-
-	increases, err := objective.IncreaseRules()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get increase rules: %w", err)
-	}
-	burnrates, err := objective.Burnrates()
+// MakePrometheusRule returns the PrometheusRule the controller writes for the objective.
+func MakePrometheusRule(kubeObjective pyrrav1alpha1.ServiceLevelObjective, genericRules bool) (*monitoringv1.PrometheusRule, error) {
+	groups, err := makeRuleGroups(kubeObjective, genericRules)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get burn rate rules: %w", err)
+		return nil, err
 	}
+	return newPrometheusRule(kubeObjective, groups), nil
+}
 
+// newPrometheusRule returns the PrometheusRule holding the rule groups of the objective.
+func newPrometheusRule(kubeObjective pyrrav1alpha1.ServiceLevelObjective, groups []monitoringv1.RuleGroup) *monitoringv1.PrometheusRule {
 	rule := monitoringv1.PrometheusRuleSpec{
-		Groups: []monitoringv1.RuleGroup{increases, burnrates},
-	}
-
-	if genericRules {
-		rules, err := objective.GenericRules()
-		if err != nil {
-			if err != slo.ErrGroupingUnsupported {
-				return nil, fmt.Errorf("failed to get generic rules: %w", err)
-			}
-			// ignore these rules
-		} else {
-			rule.Groups = append(rule.Groups, rules)
-		}
+		Groups: groups,
 	}
 
 	isController := true
@@ -270,5 +1038,5 @@ func makePrometheusRule(kubeObjective pyrrav1alpha1.ServiceLevelObjective, gener
 			},
 		},
 		Spec: rule,
-	}, nil
+	}
 }
@@ -1,15 +1,31 @@
 package controllers
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"text/template"
+	"time"
 
+	kitlog "github.com/go-kit/log"
 	"github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring"
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
 
 	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
 	"github.com/pyrra-dev/pyrra/slo"
@@ -188,7 +204,7 @@ func Test_makePrometheusRule(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			prometheusRule, err := makePrometheusRule(tt.objective, false)
+			prometheusRule, err := MakePrometheusRule(tt.objective, false)
 			require.NoError(t, err)
 			require.Equal(t, tt.rules, prometheusRule)
 		})
@@ -352,6 +368,7 @@ func Test_makeConfigMap(t *testing.T) {
 						},
 					},
 					Labels: map[string]string{
+						ObjectiveLabel:                       "http",
 						slo.PropagationLabelsPrefix + "team": "foo",
 						"team":                               "bar",
 					},
@@ -379,7 +396,450 @@ func Test_makeConfigMap(t *testing.T) {
 	}
 }
 
+func Test_makeRuleGroupsNativeLatency(t *testing.T) {
+	objective := httpSLO.DeepCopy()
+	objective.Spec.ServiceLevelIndicator = pyrrav1alpha1.ServiceLevelIndicator{
+		LatencyNative: &pyrrav1alpha1.NativeLatencyIndicator{
+			Total: pyrrav1alpha1.Query{
+				Metric: `http_request_duration_seconds{job="app"}`,
+			},
+			Latency: "500ms",
+		},
+	}
+
+	rule, err := MakePrometheusRule(*objective, true)
+	require.NoError(t, err)
+
+	var exprs []string
+	for _, group := range rule.Spec.Groups {
+		for _, r := range group.Rules {
+			if r.Record != "" {
+				exprs = append(exprs, r.Expr.String())
+			}
+		}
+	}
+	require.Contains(t, exprs, `histogram_count(increase(http_request_duration_seconds{job="app"}[4w]))`)
+	require.Contains(t, exprs, `histogram_fraction(0, 0.5, increase(http_request_duration_seconds{job="app"}[4w])) * histogram_count(increase(http_request_duration_seconds{job="app"}[4w]))`)
+	require.Contains(t, exprs, `1 - histogram_fraction(0, 0.5, rate(http_request_duration_seconds{job="app"}[5m]))`)
+
+	// ConfigMaps contain the same rule groups.
+	configMap, err := makeConfigMap("http", *objective, true)
+	require.NoError(t, err)
+	require.Contains(t, configMap.Data["http.rules.yaml"], `histogram_fraction(0, 0.5, rate(http_request_duration_seconds{job="app"}[5m]))`)
+	require.NotContains(t, configMap.Data["http.rules.yaml"], "_bucket")
+}
+
 func monitoringDuration(d string) *monitoringv1.Duration {
 	md := monitoringv1.Duration(d)
 	return &md
 }
+
+func TestServiceLevelObjectiveReconciler_SkipsNoopWrites(t *testing.T) {
+	for _, configMapMode := range []bool{false, true} {
+		t.Run(fmt.Sprintf("configMapMode=%t", configMapMode), func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			require.NoError(t, clientgoscheme.AddToScheme(scheme))
+			require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+			require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+			objective := httpSLO.DeepCopy()
+			objective.TypeMeta = metav1.TypeMeta{}
+			objective.Namespace = "monitoring"
+
+			var applies, statusUpdates int
+			c := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(objective).
+				WithStatusSubresource(objective).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+						applies++
+						return fakeApply(ctx, c, obj, patch, opts...)
+					},
+					SubResourcePatch: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+						statusUpdates++
+						return c.SubResource(subResourceName).Patch(ctx, obj, patch, opts...)
+					},
+				}).
+				Build()
+
+			r := &ServiceLevelObjectiveReconciler{
+				Client:        c,
+				Logger:        kitlog.NewNopLogger(),
+				Scheme:        scheme,
+				ConfigMapMode: configMapMode,
+			}
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+			reconcile := func() (int, int) {
+				applies, statusUpdates = 0, 0
+				_, err := r.Reconcile(context.Background(), req)
+				require.NoError(t, err)
+				return applies, statusUpdates
+			}
+
+			a1, s1 := reconcile()
+			require.Equal(t, []int{1, 1}, []int{a1, s1})
+
+			expectedType := "PrometheusRule"
+			if configMapMode {
+				expectedType = "ConfigMap"
+			}
+			require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+			require.Equal(t, expectedType, objective.Status.Type)
+
+			// Nothing changed, so nothing is written.
+			a2, s2 := reconcile()
+			require.Equal(t, []int{0, 0}, []int{a2, s2})
+
+			require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+			objective.Spec.Target = "99.9"
+			require.NoError(t, c.Update(context.Background(), objective))
+
+			a3, s3 := reconcile()
+			require.Equal(t, []int{1, 0}, []int{a3, s3})
+
+			// Patching the status of an outdated copy doesn't conflict.
+			stale := objective.DeepCopy()
+			objective.Spec.Target = "99"
+			require.NoError(t, c.Update(context.Background(), objective))
+			require.NoError(t, r.patchStatus(context.Background(), *stale, pyrrav1alpha1.ServiceLevelObjectiveStatus{Type: "Other"}))
+			require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+			require.Equal(t, "Other", objective.Status.Type)
+			require.Equal(t, "99", objective.Spec.Target)
+		})
+	}
+}
+
+func TestServiceLevelObjectiveReconciler_LokiCredentials(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+
+	var tenants []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenants = append(tenants, r.Header.Get("X-Scope-OrgID"))
+		if r.Method == http.MethodGet {
+			http.Error(w, "no rule groups found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+	objective.Annotations = map[string]string{LokiRulerAnnotation: "loki"}
+	other := httpSLO.DeepCopy()
+	other.TypeMeta = metav1.TypeMeta{}
+	other.Namespace = "monitoring"
+	other.Name = "prometheus"
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "loki-credentials", Namespace: "monitoring"},
+		Data:       map[string][]byte{"tenant": []byte("team-a")},
+	}
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective, other, secret).
+		WithStatusSubresource(objective).
+		Build()
+
+	r := &ServiceLevelObjectiveReconciler{
+		Client:                c,
+		Logger:                kitlog.NewNopLogger(),
+		LokiRuler:             &LokiRuler{URL: u},
+		LokiCredentialsSecret: "loki-credentials",
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.NotEmpty(t, tenants)
+	for _, tenant := range tenants {
+		require.Equal(t, "team-a", tenant)
+	}
+
+	// Rotated credentials are used by the next reconcile, which the Secret's change triggers.
+	secret.Data["tenant"] = []byte("team-b")
+	require.NoError(t, c.Update(context.Background(), secret))
+	require.Equal(t, []reconcile.Request{req}, r.lokiObjectivesForSecret(context.Background(), secret))
+	require.Empty(t, r.lokiObjectivesForSecret(context.Background(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "monitoring"},
+	}))
+
+	tenants = nil
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.NotEmpty(t, tenants)
+	for _, tenant := range tenants {
+		require.Equal(t, "team-b", tenant)
+	}
+
+	// Rule groups of deleted objectives are deleted with the credentials too.
+	tenants = nil
+	require.NoError(t, c.Delete(context.Background(), objective))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, tenants, len(slo.RuleGroupSuffixes))
+	for _, tenant := range tenants {
+		require.Equal(t, "team-b", tenant)
+	}
+}
+
+func TestServiceLevelObjectiveReconciler_LokiNamespaceTenants(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+
+	var tenants []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenants = append(tenants, r.Header.Get("X-Scope-OrgID"))
+		if r.Method == http.MethodGet {
+			http.Error(w, "no rule groups found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "checkout"
+	objective.Annotations = map[string]string{LokiRulerAnnotation: "loki"}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "checkout",
+		Annotations: map[string]string{RulerTenantAnnotation: "team-checkout"},
+	}}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "loki-credentials", Namespace: "checkout"},
+		Data:       map[string][]byte{"tenant": []byte("team-a"), "token": []byte("secret")},
+	}
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective, namespace, secret).
+		WithStatusSubresource(objective).
+		Build()
+
+	r := &ServiceLevelObjectiveReconciler{
+		Client:                c,
+		Logger:                kitlog.NewNopLogger(),
+		LokiRuler:             &LokiRuler{URL: u},
+		LokiCredentialsSecret: "loki-credentials",
+		LokiNamespaceTenants:  true,
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+
+	// The namespace's tenant takes precedence over the Secret's.
+	ruler, err := r.lokiRuler(context.Background(), r.LokiRuler, "checkout")
+	require.NoError(t, err)
+	require.Equal(t, LokiCredentials{Tenant: "team-checkout", Token: "secret"}, ruler.credentials)
+
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.NotEmpty(t, tenants)
+	for _, tenant := range tenants {
+		require.Equal(t, "team-checkout", tenant)
+	}
+
+	// Changes of the annotation reconcile the namespace's objectives.
+	require.Equal(t, []reconcile.Request{req}, r.lokiObjectivesForNamespace(context.Background(), namespace))
+
+	// Without the annotation and Secret the ruler is used without credentials.
+	require.NoError(t, c.Delete(context.Background(), secret))
+	namespace.Annotations = nil
+	require.NoError(t, c.Update(context.Background(), namespace))
+	ruler, err = r.lokiRuler(context.Background(), r.LokiRuler, "checkout")
+	require.NoError(t, err)
+	require.Same(t, r.LokiRuler, ruler)
+}
+
+func TestServiceLevelObjectiveReconciler_LokiRuleGroups(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+
+	pushed := map[string]monitoringv1.RuleGroup{}
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			http.Error(w, "no rule groups found", http.StatusNotFound)
+			return
+		case http.MethodPost:
+			var group monitoringv1.RuleGroup
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.NoError(t, yaml.Unmarshal(body, &group))
+			pushed[r.URL.EscapedPath()+"/"+group.Name] = group
+		case http.MethodDelete:
+			deleted = append(deleted, r.URL.EscapedPath())
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "checkout"
+	objective.Annotations = map[string]string{LokiRulerAnnotation: "loki"}
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective).
+		WithStatusSubresource(objective).
+		Build()
+
+	r := &ServiceLevelObjectiveReconciler{
+		Client:                c,
+		Logger:                kitlog.NewNopLogger(),
+		LokiRuler:             &LokiRuler{URL: u},
+		LokiNamespaceTemplate: template.Must(template.New("namespace").Parse("{{.Namespace}}/{{.Name}}")),
+		LokiGroupIntervals:    LokiGroupIntervals{BurnRate: time.Minute},
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+
+	// The rule groups are in the ruler namespace of the template, with the slash escaped.
+	require.Len(t, pushed, 2)
+	increase, ok := pushed["/loki/api/v1/rules/checkout%2Fhttp/http-increase"]
+	require.True(t, ok)
+	require.Equal(t, monitoringDuration("2m30s"), increase.Interval)
+	burnrates, ok := pushed["/loki/api/v1/rules/checkout%2Fhttp/http"]
+	require.True(t, ok)
+	require.Equal(t, monitoringDuration("1m"), burnrates.Interval)
+
+	// The cached rule groups aren't changed by the overridden intervals.
+	groups, err := r.cache.get(context.Background(), *objective, false, nil)
+	require.NoError(t, err)
+	require.Equal(t, monitoringDuration("30s"), groups[1].Interval)
+
+	// The objective's own ruleGroupInterval takes precedence over the overridden intervals.
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+	objective.Spec.RuleGroupInterval = "2m"
+	require.NoError(t, c.Update(context.Background(), objective))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, monitoringDuration("2m"), pushed["/loki/api/v1/rules/checkout%2Fhttp/http"].Interval)
+	require.Equal(t, monitoringDuration("2m"), pushed["/loki/api/v1/rules/checkout%2Fhttp/http-increase"].Interval)
+
+	require.NoError(t, c.Delete(context.Background(), objective))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"/loki/api/v1/rules/checkout%2Fhttp/http",
+		"/loki/api/v1/rules/checkout%2Fhttp/http-increase",
+		"/loki/api/v1/rules/checkout%2Fhttp/http-generic",
+		"/loki/api/v1/rules/checkout%2Fhttp/http-budget-freeze",
+		"/loki/api/v1/rules/checkout%2Fhttp/http-sla",
+		"/loki/api/v1/rules/checkout%2Fhttp/http-calendar",
+	}, deleted)
+}
+
+func TestServiceLevelObjectiveReconciler_ResyncInterval(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective).
+		WithStatusSubresource(objective).
+		Build()
+
+	r := &ServiceLevelObjectiveReconciler{Client: c, Logger: kitlog.NewNopLogger(), Scheme: scheme}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+
+	result, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, ctrl.Result{}, result)
+
+	r.ResyncInterval = 5 * time.Minute
+	result, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Minute, result.RequeueAfter)
+
+	// The resyncs of objectives are spread out over the resync delay.
+	r.ResyncDelay = time.Minute
+	for i := 0; i < 10; i++ {
+		result, err = r.Reconcile(context.Background(), req)
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, result.RequeueAfter, 5*time.Minute)
+		require.Less(t, result.RequeueAfter, 6*time.Minute)
+	}
+
+	// Manual changes of the PrometheusRule are reverted by the resync.
+	var rule monitoringv1.PrometheusRule
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, &rule))
+	rule.Spec.Groups = rule.Spec.Groups[:1]
+	require.NoError(t, c.Update(context.Background(), &rule))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, &rule))
+	require.Len(t, rule.Spec.Groups, 2)
+
+	// Invalid objectives have no rules to revert.
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+	objective.Spec.ServiceLevelIndicator.Ratio.Total.Metric = "http_requests_total{"
+	require.NoError(t, c.Update(context.Background(), objective))
+	result, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, ctrl.Result{}, result)
+}
+
+func TestServiceLevelObjectiveReconciler_ExternalLabels(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+	objective.Spec.ExternalLabels = map[string]string{"cluster": "us1"}
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective).
+		WithStatusSubresource(objective).
+		Build()
+
+	r := &ServiceLevelObjectiveReconciler{
+		Client:         c,
+		Logger:         kitlog.NewNopLogger(),
+		ExternalLabels: map[string]string{"cluster": "eu1", "region": "eu"},
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+	_, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+
+	// The objective's external labels take precedence over the reconciler's.
+	var rule monitoringv1.PrometheusRule
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, &rule))
+	for _, g := range rule.Spec.Groups {
+		for _, r := range g.Rules {
+			require.Equal(t, "us1", r.Labels["cluster"])
+			require.Equal(t, "eu", r.Labels["region"])
+		}
+	}
+
+	// They're only added to the rules, not the objective.
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+	require.Equal(t, map[string]string{"cluster": "us1"}, objective.Spec.ExternalLabels)
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+const (
+	// ShadowSinceAnnotation is set on PrometheusRules to the time their changed rules have been in shadow since.
+	ShadowSinceAnnotation = "pyrra.dev/shadow-since"
+	// ShadowHashAnnotation is set on PrometheusRules to the hash of the changed rules in shadow,
+	// to start over as they change again.
+	ShadowHashAnnotation = "pyrra.dev/shadow-hash"
+
+	// shadowDifferenceMetric is recorded for each recording rule in shadow,
+	// with the absolute difference to the live recording rule named by its record label.
+	shadowDifferenceMetric = "pyrra_shadow_difference"
+)
+
+// Shadow writes changed rules of objectives next to their live rules first,
+// with their record names suffixed and without alerts, to compare them before they replace the live rules.
+type Shadow struct {
+	// Suffix is appended to the names of the rule groups and recording rules in shadow, like :canary.
+	Suffix string
+	// Duration is how long changed rules are in shadow before they replace the live rules.
+	Duration time.Duration
+
+	now func() time.Time
+}
+
+func (s *Shadow) time() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// shadowPrometheusRule replaces the rule groups of the new PrometheusRule with the live rule groups of the existing one
+// and the changed rule groups in shadow, until they have been in shadow for the shadow's duration.
+// It returns how long until the rules in shadow replace the live rules, or 0 if the new rule groups are written as they are.
+func (r *ServiceLevelObjectiveReconciler) shadowPrometheusRule(
+	ctx context.Context,
+	logger kitlog.Logger,
+	kubeObjective *pyrrav1alpha1.ServiceLevelObjective,
+	newRule *monitoringv1.PrometheusRule,
+) (time.Duration, error) {
+	var rule monitoringv1.PrometheusRule
+	if err := r.Get(ctx, client.ObjectKeyFromObject(newRule), &rule); err != nil {
+		if errors.IsNotFound(err) {
+			// There are no live rules to compare with.
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get prometheus rule: %w", err)
+	}
+
+	live := r.Shadow.liveGroups(rule.Spec.Groups)
+	if equality.Semantic.DeepEqual(live, newRule.Spec.Groups) {
+		return 0, nil
+	}
+
+	hash, err := shadowHash(newRule.Spec.Groups)
+	if err != nil {
+		return 0, err
+	}
+
+	now := r.Shadow.time()
+	since := now
+	if rule.GetAnnotations()[ShadowHashAnnotation] == hash {
+		if t, err := time.Parse(time.RFC3339, rule.GetAnnotations()[ShadowSinceAnnotation]); err == nil {
+			since = t
+		}
+	}
+
+	remaining := r.Shadow.Duration - now.Sub(since)
+	if remaining <= 0 {
+		level.Info(logger).Log("msg", "promoting rules in shadow", "namespace", newRule.GetNamespace(), "name", newRule.GetName(), "since", since.Format(time.RFC3339))
+		r.event(kubeObjective, corev1.EventTypeNormal, reasonRulesPromoted, "Replaced the rules of PrometheusRule %s with the ones in shadow since %s", newRule.GetName(), since.Format(time.RFC3339))
+		return 0, nil
+	}
+
+	shadow, err := r.Shadow.shadowGroups(newRule.Spec.Groups)
+	if err != nil {
+		return 0, err
+	}
+	newRule.Spec.Groups = append(live, shadow...)
+
+	annotations := make(map[string]string, len(newRule.GetAnnotations())+2)
+	for k, v := range newRule.GetAnnotations() {
+		annotations[k] = v
+	}
+	annotations[ShadowSinceAnnotation] = since.Format(time.RFC3339)
+	annotations[ShadowHashAnnotation] = hash
+	newRule.SetAnnotations(annotations)
+
+	if since.Equal(now) {
+		level.Info(logger).Log("msg", "writing changed rules in shadow", "namespace", newRule.GetNamespace(), "name", newRule.GetName(), "suffix", r.Shadow.Suffix)
+		r.event(kubeObjective, corev1.EventTypeNormal, reasonRulesShadowed, "Writing the changed rules of PrometheusRule %s with suffix %s until %s", newRule.GetName(), r.Shadow.Suffix, now.Add(r.Shadow.Duration).Format(time.RFC3339))
+	}
+	return remaining, nil
+}
+
+// liveGroups returns the rule groups that aren't in shadow.
+func (s *Shadow) liveGroups(groups []monitoringv1.RuleGroup) []monitoringv1.RuleGroup {
+	live := make([]monitoringv1.RuleGroup, 0, len(groups))
+	for _, group := range groups {
+		if !strings.HasSuffix(group.Name, s.Suffix) {
+			live = append(live, group)
+		}
+	}
+	return live
+}
+
+// shadowGroups returns the rule groups in shadow: the recording rules with the suffix appended to their names
+// and to the names of the other recording rules they select, and a rule recording their difference to the live ones.
+// Alerts aren't evaluated in shadow.
+func (s *Shadow) shadowGroups(groups []monitoringv1.RuleGroup) ([]monitoringv1.RuleGroup, error) {
+	records := map[string]bool{}
+	for _, group := range groups {
+		for _, rule := range group.Rules {
+			if rule.Record != "" {
+				records[rule.Record] = true
+			}
+		}
+	}
+
+	shadow := make([]monitoringv1.RuleGroup, 0, len(groups))
+	for _, group := range groups {
+		var rules []monitoringv1.Rule
+		for _, rule := range group.Rules {
+			if rule.Record == "" {
+				continue
+			}
+			expr, err := parser.ParseExpr(rule.Expr.String())
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse expression of %s: %w", rule.Record, err)
+			}
+			parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+				if vs, ok := node.(*parser.VectorSelector); ok {
+					s.renameSelector(vs, records)
+				}
+				return nil
+			})
+
+			shadowRule := *rule.DeepCopy()
+			shadowRule.Record = rule.Record + s.Suffix
+			shadowRule.Expr = intstr.FromString(expr.String())
+			rules = append(rules, shadowRule)
+
+			difference := monitoringv1.Rule{
+				Record: shadowDifferenceMetric,
+				Expr: intstr.FromString(fmt.Sprintf("abs(%s - %s)",
+					shadowSelector(shadowRule.Record, rule.Labels),
+					shadowSelector(rule.Record, rule.Labels),
+				)),
+				Labels: map[string]string{"record": rule.Record},
+			}
+			for k, v := range rule.Labels {
+				difference.Labels[k] = v
+			}
+			rules = append(rules, difference)
+		}
+		if len(rules) == 0 {
+			continue
+		}
+
+		group := *group.DeepCopy()
+		group.Name = group.Name + s.Suffix
+		group.Rules = rules
+		shadow = append(shadow, group)
+	}
+	return shadow, nil
+}
+
+// renameSelector appends the suffix to the metric name of the selector if it selects one of the records.
+func (s *Shadow) renameSelector(vs *parser.VectorSelector, records map[string]bool) {
+	if records[vs.Name] {
+		vs.Name = vs.Name + s.Suffix
+	}
+	for i, m := range vs.LabelMatchers {
+		if m.Name == labels.MetricName && m.Type == labels.MatchEqual && records[m.Value] {
+			vs.LabelMatchers[i] = labels.MustNewMatcher(m.Type, m.Name, m.Value+s.Suffix)
+		}
+	}
+}
+
+// shadowSelector returns the selector of the metric with the static labels of its recording rule,
+// so the difference is only computed for the series of the rule.
+func shadowSelector(metric string, ruleLabels map[string]string) string {
+	names := make([]string, 0, len(ruleLabels))
+	for name := range ruleLabels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metric)}
+	for _, name := range names {
+		matchers = append(matchers, labels.MustNewMatcher(labels.MatchEqual, name, ruleLabels[name]))
+	}
+	return (&parser.VectorSelector{Name: metric, LabelMatchers: matchers}).String()
+}
+
+// shadowHash returns the hash of the changed rule groups, to notice as they change again while in shadow.
+func shadowHash(groups []monitoringv1.RuleGroup) (string, error) {
+	b, err := json.Marshal(groups)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash rule groups: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func TestShadow_shadowGroups(t *testing.T) {
+	s := &Shadow{Suffix: ":canary"}
+	interval := monitoringv1.Duration("30s")
+	groups := []monitoringv1.RuleGroup{{
+		Name:     "http-increase",
+		Interval: &interval,
+		Rules: []monitoringv1.Rule{{
+			Record: "http_requests:increase4w",
+			Expr:   intstr.FromString(`sum(increase(http_requests_total{job="app"}[4w]))`),
+			Labels: map[string]string{"slo": "http"},
+		}, {
+			Record: "http_requests:burnrate5m",
+			Expr:   intstr.FromString(`http_requests:increase4w{slo="http"} / 2 + {__name__="http_requests:increase4w"}`),
+			Labels: map[string]string{"slo": "http"},
+		}, {
+			Alert: "ErrorBudgetBurn",
+			Expr:  intstr.FromString(`http_requests:burnrate5m > 14`),
+		}},
+	}}
+
+	shadow, err := s.shadowGroups(groups)
+	require.NoError(t, err)
+	require.Equal(t, []monitoringv1.RuleGroup{{
+		Name:     "http-increase:canary",
+		Interval: &interval,
+		Rules: []monitoringv1.Rule{{
+			Record: "http_requests:increase4w:canary",
+			Expr:   intstr.FromString(`sum(increase(http_requests_total{job="app"}[4w]))`),
+			Labels: map[string]string{"slo": "http"},
+		}, {
+			Record: "pyrra_shadow_difference",
+			Expr:   intstr.FromString(`abs(http_requests:increase4w:canary{slo="http"} - http_requests:increase4w{slo="http"})`),
+			Labels: map[string]string{"record": "http_requests:increase4w", "slo": "http"},
+		}, {
+			Record: "http_requests:burnrate5m:canary",
+			Expr:   intstr.FromString(`http_requests:increase4w:canary{slo="http"} / 2 + {__name__="http_requests:increase4w:canary"}`),
+			Labels: map[string]string{"slo": "http"},
+		}, {
+			Record: "pyrra_shadow_difference",
+			Expr:   intstr.FromString(`abs(http_requests:burnrate5m:canary{slo="http"} - http_requests:burnrate5m{slo="http"})`),
+			Labels: map[string]string{"record": "http_requests:burnrate5m", "slo": "http"},
+		}},
+	}}, shadow)
+
+	// The groups themselves are left alone.
+	require.Equal(t, "http_requests:increase4w", groups[0].Rules[0].Record)
+	require.Equal(t, groups, s.liveGroups(append(groups, shadow...)))
+}
+
+func TestServiceLevelObjectiveReconciler_Shadow(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	slo := httpSLO.DeepCopy()
+	slo.TypeMeta = metav1.TypeMeta{}
+	slo.Namespace = "monitoring"
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(slo).
+		WithStatusSubresource(slo).
+		Build()
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	recorder := record.NewFakeRecorder(10)
+	r := &ServiceLevelObjectiveReconciler{
+		Client:   c,
+		Logger:   kitlog.NewNopLogger(),
+		Recorder: recorder,
+		Shadow: &Shadow{
+			Suffix:   ":canary",
+			Duration: 6 * time.Hour,
+			now:      func() time.Time { return now },
+		},
+	}
+	reconcileObjective := func() ctrl.Result {
+		result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(slo)})
+		require.NoError(t, err)
+		return result
+	}
+	getRule := func() monitoringv1.PrometheusRule {
+		var rule monitoringv1.PrometheusRule
+		require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(slo), &rule))
+		return rule
+	}
+	events := func() string {
+		var events []string
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+		return strings.Join(events, "\n")
+	}
+
+	// The first rules are written right away, there's nothing to compare them with.
+	require.Equal(t, ctrl.Result{}, reconcileObjective())
+	live := getRule()
+	require.NotContains(t, live.GetAnnotations(), ShadowSinceAnnotation)
+
+	// Changed rules are written in shadow next to the live rules.
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(slo), slo))
+	slo.Spec.ServiceLevelIndicator.Ratio.Errors.Metric = `http_requests_total{job="app",status=~"5..|429"}`
+	require.NoError(t, c.Update(context.Background(), slo))
+
+	require.Equal(t, ctrl.Result{RequeueAfter: 6 * time.Hour}, reconcileObjective())
+	shadowed := getRule()
+	require.Equal(t, "2024-06-01T12:00:00Z", shadowed.GetAnnotations()[ShadowSinceAnnotation])
+	require.Equal(t, live.Spec.Groups, r.Shadow.liveGroups(shadowed.Spec.Groups))
+	require.Greater(t, len(shadowed.Spec.Groups), len(live.Spec.Groups))
+	for _, group := range shadowed.Spec.Groups[len(live.Spec.Groups):] {
+		require.True(t, strings.HasSuffix(group.Name, ":canary"))
+		for _, rule := range group.Rules {
+			require.Empty(t, rule.Alert)
+		}
+	}
+	require.Contains(t, events(), reasonRulesShadowed)
+
+	// Reconciling again keeps the time the rules have been in shadow since.
+	now = now.Add(2 * time.Hour)
+	require.Equal(t, ctrl.Result{RequeueAfter: 4 * time.Hour}, reconcileObjective())
+	require.NotContains(t, events(), reasonRulesShadowed)
+	unchanged := getRule()
+	require.Equal(t, shadowed.Spec, unchanged.Spec)
+	require.Equal(t, "2024-06-01T12:00:00Z", unchanged.GetAnnotations()[ShadowSinceAnnotation])
+
+	// Once the duration passed, the rules in shadow replace the live rules.
+	now = now.Add(4 * time.Hour)
+	require.Equal(t, ctrl.Result{}, reconcileObjective())
+	promoted := getRule()
+	require.NotContains(t, promoted.GetAnnotations(), ShadowSinceAnnotation)
+	require.NotContains(t, promoted.GetAnnotations(), ShadowHashAnnotation)
+	groups, err := makeRuleGroups(*slo, false)
+	require.NoError(t, err)
+	require.Equal(t, groups, promoted.Spec.Groups)
+	require.Contains(t, events(), reasonRulesPromoted)
+
+	// Nothing is shadowed while the rules don't change.
+	require.Equal(t, ctrl.Result{}, reconcileObjective())
+	require.Equal(t, promoted.Spec, getRule().Spec)
+}
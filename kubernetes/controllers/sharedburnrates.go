@@ -0,0 +1,185 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+// SharedBurnratesLabel is set on the PrometheusRules recording the shared burn rates of the objectives in their namespace
+// to the destination of the objectives, empty for the ones without.
+const SharedBurnratesLabel = "pyrra.dev/shared-burnrates"
+
+// sharedBurnratesRuleName returns the name of the PrometheusRule recording the shared burn rates of the objectives of the destination.
+// Objectives of different destinations don't share burn rates, as they're evaluated by different rulers.
+func sharedBurnratesRuleName(destination string) string {
+	if destination == "" {
+		return "pyrra-shared-burnrates"
+	}
+	return "pyrra-shared-burnrates-" + destination
+}
+
+// reconcileSharedBurnrates writes the PrometheusRules recording the burn rates of the objectives in the namespace with sharedBurnrates,
+// one per destination, owned by all of their objectives. PrometheusRules of destinations without such objectives left are deleted.
+// After an objective was deleted, they're left to the garbage collector if no objective in the namespace shares burn rates anymore,
+// as all their owners are gone then, which spares operators without PrometheusRules from listing them.
+// The RuleMutators don't apply to the shared burn rates, as they're recorded for several objectives.
+func (r *ServiceLevelObjectiveReconciler) reconcileSharedBurnrates(ctx context.Context, logger kitlog.Logger, namespace string, deleted bool) error {
+	var list pyrrav1alpha1.ServiceLevelObjectiveList
+	if err := r.List(ctx, &list, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("failed to list objectives: %w", err)
+	}
+
+	destinations := map[string][]pyrrav1alpha1.ServiceLevelObjective{}
+	for _, o := range list.Items {
+		if !o.Spec.SharedBurnrates || o.Spec.ServiceLevelIndicator.Composite != nil ||
+			IsLokiObjective(o.GetAnnotations()) || o.GetDeletionTimestamp() != nil {
+			continue
+		}
+		destinations[o.Spec.Destination] = append(destinations[o.Spec.Destination], o)
+	}
+	if deleted && len(destinations) == 0 {
+		return nil
+	}
+
+	var rules monitoringv1.PrometheusRuleList
+	if err := r.List(ctx, &rules, client.InNamespace(namespace), client.HasLabels{SharedBurnratesLabel}); err != nil {
+		return fmt.Errorf("failed to list prometheus rules: %w", err)
+	}
+	for _, rule := range rules.Items {
+		name := rule.GetLabels()[SharedBurnratesLabel]
+		if _, ok := destinations[name]; !ok {
+			destinations[name] = nil
+		}
+	}
+
+	names := make([]string, 0, len(destinations))
+	for name := range destinations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := r.writeSharedBurnrates(ctx, logger, namespace, name, destinations[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSharedBurnrates writes the PrometheusRule recording the shared burn rates of the destination's objectives,
+// or deletes it if there are none.
+func (r *ServiceLevelObjectiveReconciler) writeSharedBurnrates(
+	ctx context.Context,
+	logger kitlog.Logger,
+	namespace, destinationName string,
+	kubeObjectives []pyrrav1alpha1.ServiceLevelObjective,
+) error {
+	name := sharedBurnratesRuleName(destinationName)
+
+	var objectives []slo.Objective
+	var owners []metav1.OwnerReference
+	for _, o := range kubeObjectives {
+		r.withExternalLabels(&o)
+		objective, err := o.Internal()
+		if err != nil {
+			// Invalid objectives fail their own reconcile.
+			continue
+		}
+		objectives = append(objectives, objective)
+		owners = append(owners, metav1.OwnerReference{
+			APIVersion: pyrrav1alpha1.GroupVersion.String(),
+			Kind:       "ServiceLevelObjective",
+			Name:       o.GetName(),
+			UID:        o.GetUID(),
+		})
+	}
+
+	if len(objectives) == 0 {
+		var rule monitoringv1.PrometheusRule
+		if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &rule); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if _, ok := rule.GetLabels()[SharedBurnratesLabel]; !ok {
+			return nil
+		}
+		level.Info(logger).Log("msg", "deleting shared burn rates prometheus rule", "namespace", namespace, "name", name)
+		if err := r.Delete(ctx, &rule); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete prometheus rule: %w", err)
+		}
+		return nil
+	}
+
+	group, err := slo.SharedBurnrates(name, objectives)
+	if err != nil {
+		return err
+	}
+	newRule := &monitoringv1.PrometheusRule{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       monitoringv1.PrometheusRuleKind,
+			APIVersion: monitoring.GroupName + "/" + monitoringv1.Version,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       namespace,
+			Labels:          map[string]string{SharedBurnratesLabel: destinationName},
+			OwnerReferences: owners,
+		},
+		Spec: monitoringv1.PrometheusRuleSpec{Groups: []monitoringv1.RuleGroup{group}},
+	}
+	if destinationName != "" {
+		r.Destinations[destinationName].withLabels(newRule)
+	} else if r.PrometheusSelector != nil && r.PrometheusSelector.AddLabels {
+		if err := r.selectRuleLabels(ctx, newRule); err != nil {
+			return err
+		}
+	}
+
+	hash, err := setAppliedHash(newRule, newRule.Spec)
+	if err != nil {
+		return err
+	}
+	var rule monitoringv1.PrometheusRule
+	if err := r.Get(ctx, client.ObjectKeyFromObject(newRule), &rule); err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get prometheus rule: %w", err)
+		}
+	} else if rule.GetAnnotations()[AppliedHashAnnotation] == hash && equality.Semantic.DeepEqual(rule.Spec, newRule.Spec) {
+		level.Debug(logger).Log("msg", "shared burn rates prometheus rule is up to date", "namespace", namespace, "name", name)
+		return nil
+	}
+
+	level.Info(logger).Log("msg", "applying shared burn rates prometheus rule", "namespace", namespace, "name", name)
+	setGeneratedAt(newRule)
+	if err := r.applyObject(ctx, newRule); err != nil {
+		return fmt.Errorf("failed to apply prometheus rule: %w", err)
+	}
+	return nil
+}
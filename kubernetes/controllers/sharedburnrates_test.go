@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+func TestServiceLevelObjectiveReconciler_SharedBurnrates(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	// Both objectives have the same indicator with different targets.
+	http := httpSLO.DeepCopy()
+	http.TypeMeta = metav1.TypeMeta{}
+	http.Namespace = "monitoring"
+	http.Spec.SharedBurnrates = true
+	strict := http.DeepCopy()
+	strict.Name = "http-strict"
+	strict.UID = "456"
+	strict.Spec.Target = "99.9"
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(http, strict).
+		WithStatusSubresource(http, strict).
+		Build()
+
+	r := &ServiceLevelObjectiveReconciler{
+		Client: c,
+		Logger: kitlog.NewNopLogger(),
+	}
+	for _, o := range []*pyrrav1alpha1.ServiceLevelObjective{http, strict} {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(o)})
+		require.NoError(t, err)
+	}
+
+	sharedKey := client.ObjectKey{Namespace: "monitoring", Name: "pyrra-shared-burnrates"}
+	var shared monitoringv1.PrometheusRule
+	require.NoError(t, c.Get(context.Background(), sharedKey, &shared))
+	require.Equal(t, "", shared.GetLabels()[SharedBurnratesLabel])
+	require.Len(t, shared.GetOwnerReferences(), 2)
+	require.Equal(t, types.UID("123"), shared.GetOwnerReferences()[0].UID)
+	require.Len(t, shared.Spec.Groups, 1)
+
+	records := map[string]bool{}
+	for _, rule := range shared.Spec.Groups[0].Rules {
+		require.NotEmpty(t, rule.Labels[slo.SharedBurnrateLabel])
+		require.Empty(t, rule.Labels["slo"])
+		require.False(t, records[rule.Record], "burn rate %s recorded twice", rule.Record)
+		records[rule.Record] = true
+	}
+	require.NotEmpty(t, records)
+
+	// The objectives' own rules only alert on the shared burn rates.
+	var rule monitoringv1.PrometheusRule
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(strict), &rule))
+	for _, g := range rule.Spec.Groups {
+		for _, r := range g.Rules {
+			require.False(t, records[r.Record], "burn rate %s recorded by the objective", r.Record)
+		}
+	}
+
+	// The shared burn rates are owned by the objectives left sharing them,
+	// and garbage collected with the last one.
+	require.NoError(t, c.Delete(context.Background(), http))
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(http)})
+	require.NoError(t, err)
+	require.NoError(t, c.Get(context.Background(), sharedKey, &shared))
+	require.Len(t, shared.GetOwnerReferences(), 1)
+	require.Equal(t, types.UID("456"), shared.GetOwnerReferences()[0].UID)
+
+	// Without shared burn rates the objective's rules record their own and the shared ones are deleted.
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(strict), strict))
+	strict.Spec.SharedBurnrates = false
+	require.NoError(t, c.Update(context.Background(), strict))
+	_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(strict)})
+	require.NoError(t, err)
+	require.True(t, errors.IsNotFound(c.Get(context.Background(), sharedKey, &shared)))
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(strict), &rule))
+	var recorded bool
+	for _, g := range rule.Spec.Groups {
+		for _, r := range g.Rules {
+			recorded = recorded || records[r.Record]
+		}
+	}
+	require.True(t, recorded)
+}
+
+func TestServiceLevelObjectiveReconciler_SharedBurnratesOutputs(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+	objective.Spec.SharedBurnrates = true
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective).
+		WithStatusSubresource(objective).
+		Build()
+
+	r := &ServiceLevelObjectiveReconciler{
+		Client: c,
+		Logger: kitlog.NewNopLogger(),
+	}
+	r.Writers = r.RuleOutputs(RuleOutputPrometheusRule, RuleOutputConfigMap)
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)})
+	require.NoError(t, err)
+
+	// The objective is rejected before the PrometheusRule writer runs, so none of its rules are live.
+	require.True(t, errors.IsNotFound(c.Get(context.Background(), client.ObjectKeyFromObject(objective), &monitoringv1.PrometheusRule{})))
+	require.True(t, errors.IsNotFound(c.Get(context.Background(), client.ObjectKey{Namespace: "monitoring", Name: "pyrra-shared-burnrates"}, &monitoringv1.PrometheusRule{})))
+	require.True(t, errors.IsNotFound(c.Get(context.Background(), client.ObjectKeyFromObject(objective), &corev1.ConfigMap{})))
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(objective), objective))
+	invalid := meta.FindStatusCondition(objective.Status.Conditions, pyrrav1alpha1.ConditionValidationFailed)
+	require.NotNil(t, invalid)
+	require.Equal(t, metav1.ConditionTrue, invalid.Status)
+	require.Equal(t, "shared burn rates are only written to PrometheusRules, not to the configmap output", invalid.Message)
+}
@@ -0,0 +1,270 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+// silenceCreatedBy is the author of the silences pyrra creates, to tell them apart from the ones of people.
+const silenceCreatedBy = "pyrra"
+
+// AlertmanagerSilencer silences the burn rate alerts of objectives in Alertmanager
+// while the thresholds of their error budget policy with silence are active.
+// The silences end after the Duration unless the next evaluation extends them,
+// so they don't outlive their objective, its policy or the operator.
+type AlertmanagerSilencer struct {
+	URL    *url.URL
+	Client *http.Client
+	// Duration is how long the silences last from each evaluation, longer than the interval of the evaluations.
+	Duration time.Duration
+}
+
+// silence is a silence of the Alertmanager API v2.
+type silence struct {
+	ID        string           `json:"id,omitempty"`
+	Matchers  []silenceMatcher `json:"matchers"`
+	StartsAt  time.Time        `json:"startsAt"`
+	EndsAt    time.Time        `json:"endsAt"`
+	CreatedBy string           `json:"createdBy"`
+	Comment   string           `json:"comment"`
+	Status    *struct {
+		State string `json:"state"`
+	} `json:"status,omitempty"`
+}
+
+type silenceMatcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+	IsEqual bool   `json:"isEqual"`
+}
+
+// silencedSeverities returns the severities the active thresholds silence, sorted and without duplicates.
+func silencedSeverities(active []pyrrav1alpha1.ErrorBudgetThreshold) []string {
+	seen := map[string]bool{}
+	var severities []string
+	for _, t := range active {
+		for _, severity := range t.Silence {
+			if !seen[severity] {
+				seen[severity] = true
+				severities = append(severities, severity)
+			}
+		}
+	}
+	sort.Strings(severities)
+	return severities
+}
+
+// silenceMatchers returns the matchers of the objective's burn rate alerts with the severities.
+// The alerts don't have the objective's namespace, objectives with the same name in other namespaces are silenced too
+// unless their externalLabels tell them apart.
+func silenceMatchers(objective slo.Objective, severities []string) []silenceMatcher {
+	matchers := []silenceMatcher{
+		{Name: labels.AlertName, Value: objective.AlertName(), IsEqual: true},
+		{Name: "slo", Value: objective.Name(), IsEqual: true},
+	}
+	names := make([]string, 0, len(objective.ExternalLabels))
+	for name := range objective.ExternalLabels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		matchers = append(matchers, silenceMatcher{Name: name, Value: objective.ExternalLabels[name], IsEqual: true})
+	}
+
+	if len(severities) == 1 {
+		return append(matchers, silenceMatcher{Name: "severity", Value: severities[0], IsEqual: true})
+	}
+	quoted := make([]string, len(severities))
+	for i, severity := range severities {
+		quoted[i] = regexp.QuoteMeta(severity)
+	}
+	return append(matchers, silenceMatcher{Name: "severity", Value: strings.Join(quoted, "|"), IsRegex: true, IsEqual: true})
+}
+
+// silenceComment identifies the silences of the objective among the ones pyrra created.
+func silenceComment(kubeObjective pyrrav1alpha1.ServiceLevelObjective) string {
+	return fmt.Sprintf("Error budget policy of %s/%s", kubeObjective.GetNamespace(), kubeObjective.GetName())
+}
+
+// Silence makes sure the objective's burn rate alerts with the severities are silenced until the Duration from now,
+// extending its silence if it ends within half of the Duration. Its other silences are expired,
+// all of them if there are no severities. It returns whether a silence was created and whether one was expired.
+func (s *AlertmanagerSilencer) Silence(
+	ctx context.Context,
+	kubeObjective pyrrav1alpha1.ServiceLevelObjective,
+	objective slo.Objective,
+	severities []string,
+) (created, expired bool, err error) {
+	existing, err := s.silences(ctx, kubeObjective, objective)
+	if err != nil {
+		return false, false, err
+	}
+
+	var desired []silenceMatcher
+	if len(severities) > 0 {
+		desired = silenceMatchers(objective, severities)
+	}
+
+	now := time.Now()
+	var current *silence
+	for i := range existing {
+		if desired != nil && current == nil && sameMatchers(existing[i].Matchers, desired) {
+			current = &existing[i]
+			continue
+		}
+		if err := s.expire(ctx, existing[i].ID); err != nil {
+			return false, false, err
+		}
+		expired = true
+	}
+	if desired == nil || (current != nil && current.EndsAt.After(now.Add(s.Duration/2))) {
+		return false, expired, nil
+	}
+
+	update := silence{
+		Matchers:  desired,
+		StartsAt:  now,
+		EndsAt:    now.Add(s.Duration),
+		CreatedBy: silenceCreatedBy,
+		Comment:   silenceComment(kubeObjective),
+	}
+	if current != nil {
+		update.ID = current.ID
+		update.StartsAt = current.StartsAt
+	}
+	if err := s.set(ctx, update); err != nil {
+		return false, expired, err
+	}
+	return current == nil, expired, nil
+}
+
+// silences returns the active silences pyrra created for the objective.
+func (s *AlertmanagerSilencer) silences(ctx context.Context, kubeObjective pyrrav1alpha1.ServiceLevelObjective, objective slo.Objective) ([]silence, error) {
+	query := url.Values{}
+	query.Add("filter", fmt.Sprintf("%s=%q", labels.AlertName, objective.AlertName()))
+	query.Add("filter", fmt.Sprintf("slo=%q", objective.Name()))
+	u := s.URL.JoinPath("/api/v2/silences")
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	body, err := s.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list silences: %w", err)
+	}
+
+	var all []silence
+	if err := json.Unmarshal(body, &all); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal silences: %w", err)
+	}
+	comment := silenceComment(kubeObjective)
+	var silences []silence
+	for _, sil := range all {
+		if sil.CreatedBy == silenceCreatedBy && sil.Comment == comment && (sil.Status == nil || sil.Status.State == "active") {
+			silences = append(silences, sil)
+		}
+	}
+	return silences, nil
+}
+
+// set creates the silence or updates the one with its ID.
+func (s *AlertmanagerSilencer) set(ctx context.Context, sil silence) error {
+	body, err := json.Marshal(sil)
+	if err != nil {
+		return fmt.Errorf("failed to marshal silence: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL.JoinPath("/api/v2/silences").String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if _, err := s.do(req); err != nil {
+		return fmt.Errorf("failed to set silence: %w", err)
+	}
+	return nil
+}
+
+// expire ends the silence with the ID.
+func (s *AlertmanagerSilencer) expire(ctx context.Context, id string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.URL.JoinPath("/api/v2/silence", id).String(), nil)
+	if err != nil {
+		return err
+	}
+	if _, err := s.do(req); err != nil {
+		return fmt.Errorf("failed to expire silence %s: %w", id, err)
+	}
+	return nil
+}
+
+// do sends the request and returns the response body, an error for responses other than 200.
+func (s *AlertmanagerSilencer) do(req *http.Request) ([]byte, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// sameMatchers returns true if the matchers are the same, in any order.
+func sameMatchers(a, b []silenceMatcher) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[silenceMatcher]int, len(a))
+	for _, m := range a {
+		seen[m]++
+	}
+	for _, m := range b {
+		if seen[m] == 0 {
+			return false
+		}
+		seen[m]--
+	}
+	return true
+}
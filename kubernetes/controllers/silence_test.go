@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// fakeAlertmanager keeps the silences of the Alertmanager API v2 in memory.
+type fakeAlertmanager struct {
+	mu       sync.Mutex
+	silences map[string]silence
+	sets     int
+}
+
+func (a *fakeAlertmanager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v2/silences":
+		silences := []silence{}
+		for _, s := range a.silences {
+			silences = append(silences, s)
+		}
+		_ = json.NewEncoder(w).Encode(silences)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v2/silences":
+		var s silence
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.sets++
+		if s.ID == "" {
+			s.ID = fmt.Sprintf("silence-%d", a.sets)
+		}
+		s.Status = &struct {
+			State string `json:"state"`
+		}{State: "active"}
+		a.silences[s.ID] = s
+		_ = json.NewEncoder(w).Encode(map[string]string{"silenceID": s.ID})
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/api/v2/silence/"):
+		s, ok := a.silences[strings.TrimPrefix(r.URL.Path, "/api/v2/silence/")]
+		if !ok {
+			http.Error(w, "silence not found", http.StatusNotFound)
+			return
+		}
+		s.Status.State = "expired"
+		a.silences[s.ID] = s
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (a *fakeAlertmanager) active() []silence {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var active []silence
+	for _, s := range a.silences {
+		if s.Status.State == "active" {
+			active = append(active, s)
+		}
+	}
+	return active
+}
+
+func TestBudgetPolicyEvaluator_Silence(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+	objective.Spec.Policy = &pyrrav1alpha1.ErrorBudgetPolicy{
+		Thresholds: []pyrrav1alpha1.ErrorBudgetThreshold{{
+			Remaining: "0",
+			Silence:   []string{"warning", "critical"},
+		}, {
+			Remaining: "10",
+			Silence:   []string{"warning"},
+		}},
+	}
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective).
+		WithStatusSubresource(objective).
+		Build()
+
+	alertmanager := &fakeAlertmanager{silences: map[string]silence{}}
+	server := httptest.NewServer(alertmanager)
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	remaining := 0.5
+	recorder := record.NewFakeRecorder(10)
+	e := &BudgetPolicyEvaluator{
+		Client:   c,
+		Logger:   kitlog.NewNopLogger(),
+		Querier:  budgetQuerierFunc(func() float64 { return remaining }),
+		Recorder: recorder,
+		Interval: time.Minute,
+		Silencer: &AlertmanagerSilencer{URL: u, Client: server.Client(), Duration: 15 * time.Minute},
+	}
+	evaluate := func(r float64) []silence {
+		remaining = r
+		require.NoError(t, e.evaluateAll(context.Background()))
+		return alertmanager.active()
+	}
+
+	require.Empty(t, evaluate(0.5))
+	require.Empty(t, recorder.Events)
+
+	// Below 10% only the warning alerts are silenced.
+	active := evaluate(0.05)
+	require.Len(t, active, 1)
+	require.Equal(t, []silenceMatcher{
+		{Name: "alertname", Value: "ErrorBudgetBurn", IsEqual: true},
+		{Name: "slo", Value: "http", IsEqual: true},
+		{Name: "severity", Value: "warning", IsEqual: true},
+	}, active[0].Matchers)
+	require.Equal(t, "pyrra", active[0].CreatedBy)
+	require.Equal(t, "Error budget policy of monitoring/http", active[0].Comment)
+	require.WithinDuration(t, time.Now().Add(15*time.Minute), active[0].EndsAt, time.Minute)
+	require.Equal(t, "Warning ErrorBudgetPolicy Silenced the warning alerts in Alertmanager until the error budget recovers", <-recorder.Events)
+
+	// The silence isn't updated until it ends within half of the duration.
+	require.Equal(t, active, evaluate(0.05))
+	require.Equal(t, 1, alertmanager.sets)
+
+	// Once the error budget is gone the critical alerts are silenced too, replacing the previous silence.
+	active = evaluate(-0.1)
+	require.Len(t, active, 1)
+	require.Equal(t, silenceMatcher{Name: "severity", Value: "critical|warning", IsRegex: true, IsEqual: true}, active[0].Matchers[2])
+	require.Equal(t, "Warning ErrorBudgetPolicy Silenced the critical, warning alerts in Alertmanager until the error budget recovers", <-recorder.Events)
+
+	// Silences that are about to end are extended.
+	alertmanager.mu.Lock()
+	s := alertmanager.silences[active[0].ID]
+	s.EndsAt = time.Now().Add(time.Minute)
+	alertmanager.silences[active[0].ID] = s
+	alertmanager.mu.Unlock()
+	extended := evaluate(-0.1)
+	require.Len(t, extended, 1)
+	require.Equal(t, active[0].ID, extended[0].ID)
+	require.WithinDuration(t, time.Now().Add(15*time.Minute), extended[0].EndsAt, time.Minute)
+	require.Empty(t, recorder.Events)
+
+	// The silences are expired as the error budget recovers.
+	require.Empty(t, evaluate(0.5))
+	require.Equal(t, "Normal ErrorBudgetPolicy Expired the silences of the alerts in Alertmanager", <-recorder.Events)
+}
+
+func TestAlertmanagerSilencer_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	objective, err := httpSLO.Internal()
+	require.NoError(t, err)
+	s := &AlertmanagerSilencer{URL: u, Client: server.Client(), Duration: time.Hour}
+	_, _, err = s.Silence(context.Background(), httpSLO, objective, []string{"warning"})
+	require.EqualError(t, err, "failed to list silences: unexpected status 401 Unauthorized: unauthorized")
+}
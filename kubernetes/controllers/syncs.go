@@ -0,0 +1,114 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ObjectiveSync is the outcome of the last reconcile of an objective.
+type ObjectiveSync struct {
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	Generation int64  `json:"generation"`
+	// LastSync is when the objective was last reconciled.
+	LastSync time.Time `json:"lastSync"`
+	// Backend is the type of the resource the rules were last written to, like PrometheusRule or LokiRuler.
+	Backend string `json:"backend,omitempty"`
+	// RuleName is the name of the resource the rules were last written to.
+	RuleName string `json:"ruleName,omitempty"`
+	// Outputs are where the rules were written to, like prometheusrule, as in the rules label of pyrra_slo_info.
+	Outputs []string `json:"outputs,omitempty"`
+	// Destination is the destination of the objective, if it has one.
+	Destination string `json:"destination,omitempty"`
+	// Rules are the generated rule groups as YAML.
+	Rules string `json:"rules,omitempty"`
+	// Error is why the reconcile failed, if it did.
+	Error string `json:"error,omitempty"`
+}
+
+// ObjectiveSyncs records the last reconcile of each objective and serves them as JSON,
+// optionally filtered by ?namespace=monitoring&name=http-errors.
+// It tells why the rules of an objective are missing from a ruler without access to the operator's logs.
+type ObjectiveSyncs struct {
+	mu    sync.Mutex
+	syncs map[types.NamespacedName]ObjectiveSync
+}
+
+type objectiveSyncsResponse struct {
+	// Objectives are the last reconciles, sorted by namespace and name.
+	Objectives []ObjectiveSync `json:"objectives"`
+}
+
+// record records the reconcile of the objective with its rule groups and the error it failed with.
+func (s *ObjectiveSyncs) record(sync ObjectiveSync, groups []monitoringv1.RuleGroup, err error) {
+	if len(groups) > 0 {
+		var sb strings.Builder
+		if _, writeErr := WriteRuleSpec(&sb, monitoringv1.PrometheusRuleSpec{Groups: groups}, 0); writeErr == nil {
+			sync.Rules = sb.String()
+		}
+	}
+	if err != nil {
+		sync.Error = err.Error()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.syncs == nil {
+		s.syncs = map[types.NamespacedName]ObjectiveSync{}
+	}
+	s.syncs[types.NamespacedName{Namespace: sync.Namespace, Name: sync.Name}] = sync
+}
+
+// delete forgets the deleted objective.
+func (s *ObjectiveSyncs) delete(name types.NamespacedName) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.syncs, name)
+}
+
+func (s *ObjectiveSyncs) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	namespace, name := r.URL.Query().Get("namespace"), r.URL.Query().Get("name")
+
+	s.mu.Lock()
+	resp := objectiveSyncsResponse{Objectives: make([]ObjectiveSync, 0, len(s.syncs))}
+	for n, sync := range s.syncs {
+		if (namespace != "" && n.Namespace != namespace) || (name != "" && n.Name != name) {
+			continue
+		}
+		resp.Objectives = append(resp.Objectives, sync)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(resp.Objectives, func(i, j int) bool {
+		if resp.Objectives[i].Namespace != resp.Objectives[j].Namespace {
+			return resp.Objectives[i].Namespace < resp.Objectives[j].Namespace
+		}
+		return resp.Objectives[i].Name < resp.Objectives[j].Name
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
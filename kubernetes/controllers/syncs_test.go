@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func TestObjectiveSyncs(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+	unknown := httpSLO.DeepCopy()
+	unknown.TypeMeta = metav1.TypeMeta{}
+	unknown.Namespace = "default"
+	unknown.Spec.Destination = "mimir"
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective, unknown).
+		WithStatusSubresource(objective, unknown).
+		Build()
+
+	r := &ServiceLevelObjectiveReconciler{
+		Client: c,
+		Logger: kitlog.NewNopLogger(),
+		Scheme: scheme,
+		Syncs:  &ObjectiveSyncs{},
+	}
+	for _, o := range []client.Object{objective, unknown} {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(o)})
+		require.NoError(t, err)
+	}
+
+	get := func(query string) []ObjectiveSync {
+		rec := httptest.NewRecorder()
+		r.Syncs.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/syncs"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		var resp objectiveSyncsResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp.Objectives
+	}
+
+	syncs := get("")
+	require.Len(t, syncs, 2)
+
+	require.Equal(t, "default", syncs[0].Namespace)
+	require.Equal(t, "mimir", syncs[0].Destination)
+	require.Equal(t, `unknown destination "mimir", the operator's destinations are []`, syncs[0].Error)
+	require.Empty(t, syncs[0].Outputs)
+
+	require.Equal(t, "monitoring", syncs[1].Namespace)
+	require.Equal(t, "http", syncs[1].Name)
+	require.Equal(t, "PrometheusRule", syncs[1].Backend)
+	require.Equal(t, "http", syncs[1].RuleName)
+	require.Equal(t, []string{rulesPrometheusRule}, syncs[1].Outputs)
+	require.Empty(t, syncs[1].Error)
+	require.False(t, syncs[1].LastSync.IsZero())
+	require.Contains(t, syncs[1].Rules, "  name: http-increase\n")
+	require.Contains(t, syncs[1].Rules, "alert: ErrorBudgetBurn")
+
+	syncs = get("?namespace=monitoring&name=http")
+	require.Len(t, syncs, 1)
+	require.Equal(t, "monitoring", syncs[0].Namespace)
+	require.Empty(t, get("?namespace=monitoring&name=grpc"))
+
+	// Deleted objectives are forgotten.
+	require.NoError(t, c.Delete(context.Background(), objective))
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)})
+	require.NoError(t, err)
+	syncs = get("")
+	require.Len(t, syncs, 1)
+	require.Equal(t, "default", syncs[0].Namespace)
+}
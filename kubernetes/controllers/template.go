@@ -0,0 +1,308 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// TemplateReconciler maintains an objective for each value discovered by ServiceLevelObjectiveTemplates.
+// The objectives are owned by their template, so they're garbage collected with it,
+// and they're removed as their values are no longer discovered.
+type TemplateReconciler struct {
+	client.Client
+	Logger kitlog.Logger
+	// Querier discovers the label values of templates with Prometheus discovery.
+	// Those templates get no objectives if it is nil.
+	Querier BudgetPolicyQuerier
+	// Interval is how often the values are discovered again.
+	// They're only discovered as templates and Services change if it is 0.
+	Interval time.Duration
+	// now returns the time of the discoveries, time.Now if nil.
+	now func() time.Time
+}
+
+// +kubebuilder:rbac:groups=pyrra.dev,resources=servicelevelobjectivetemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups=pyrra.dev,resources=servicelevelobjectivetemplates/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch
+
+// TemplateData is the data the strings of the objective templates are rendered with.
+type TemplateData struct {
+	// Value is the discovered value, like a handler or tenant.
+	Value string
+	// Namespace of the template.
+	Namespace string
+	// Name of the template.
+	Name string
+}
+
+func (r *TemplateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := kitlog.With(r.Logger, "reconciler", "template", "namespace", req.NamespacedName)
+	level.Debug(logger).Log("msg", "reconciling")
+
+	var tmpl pyrrav1alpha1.ServiceLevelObjectiveTemplate
+	if err := r.Get(ctx, req.NamespacedName, &tmpl); err != nil {
+		// Objectives of deleted templates are garbage collected through their owner references.
+		return ctrl.Result{}, client.IgnoreNotFound(fmt.Errorf("getting template: %w", err))
+	}
+
+	values, err := r.discover(ctx, tmpl)
+	if err != nil {
+		if isInvalidObjective(err) {
+			// Retrying doesn't help until the template is changed, which triggers another reconcile.
+			level.Warn(logger).Log("msg", "failed to discover template values", "err", err)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	result := ctrl.Result{RequeueAfter: r.Interval}
+
+	// Nothing discovered is more likely a gap in the metrics than all values being gone,
+	// so the objectives are kept until the values are discovered again.
+	if len(values) == 0 && len(tmpl.Status.Objectives) > 0 {
+		level.Warn(logger).Log("msg", "no values discovered, keeping the objectives", "objectives", len(tmpl.Status.Objectives))
+		return result, nil
+	}
+
+	desired, err := templateObjectives(tmpl, values)
+	if err != nil {
+		level.Warn(logger).Log("msg", "failed to generate objectives for template", "err", err)
+		return ctrl.Result{}, nil
+	}
+	if err := syncObjectives(ctx, r.Client, logger, &tmpl, desired); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	names := make([]string, 0, len(desired))
+	for _, o := range desired {
+		names = append(names, o.GetName())
+	}
+	now := time.Now
+	if r.now != nil {
+		now = r.now
+	}
+	lastDiscovery := metav1.NewTime(now())
+
+	patch := client.MergeFrom(tmpl.DeepCopy())
+	tmpl.Status.Objectives = names
+	tmpl.Status.ObjectiveCount = len(names)
+	tmpl.Status.LastDiscovery = &lastDiscovery
+	if err := r.Status().Patch(ctx, &tmpl, patch); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(fmt.Errorf("failed to update template status: %w", err))
+	}
+	return result, nil
+}
+
+// discover returns the sorted values discovered for the template.
+// Templates that can't be discovered until they're changed return an invalidObjectiveError.
+func (r *TemplateReconciler) discover(ctx context.Context, tmpl pyrrav1alpha1.ServiceLevelObjectiveTemplate) ([]string, error) {
+	discovery := tmpl.Spec.Discovery
+	if (discovery.Prometheus == nil) == (discovery.Services == nil) {
+		return nil, invalidObjectiveError{err: fmt.Errorf("exactly one of prometheus or services discovery must be set")}
+	}
+
+	seen := map[string]bool{}
+	if discovery.Prometheus != nil {
+		if r.Querier == nil {
+			return nil, invalidObjectiveError{err: fmt.Errorf("prometheus discovery requires --prometheus-url")}
+		}
+		if !model.LabelName(discovery.Prometheus.Label).IsValid() {
+			return nil, invalidObjectiveError{err: fmt.Errorf("invalid discovery label %q", discovery.Prometheus.Label)}
+		}
+		if _, err := parser.ParseMetricSelector(discovery.Prometheus.Selector); err != nil {
+			return nil, invalidObjectiveError{err: fmt.Errorf("failed to parse discovery selector: %w", err)}
+		}
+
+		query := fmt.Sprintf("group by (%s) (%s)", discovery.Prometheus.Label, discovery.Prometheus.Selector)
+		value, _, err := r.Querier.Query(ctx, query, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover label values: %w", err)
+		}
+		vector, ok := value.(model.Vector)
+		if !ok {
+			return nil, fmt.Errorf("unexpected discovery result type %s", value.Type())
+		}
+		for _, sample := range vector {
+			if v := string(sample.Metric[model.LabelName(discovery.Prometheus.Label)]); v != "" {
+				seen[v] = true
+			}
+		}
+	} else {
+		selector, err := metav1.LabelSelectorAsSelector(discovery.Services)
+		if err != nil {
+			return nil, invalidObjectiveError{err: fmt.Errorf("invalid services selector: %w", err)}
+		}
+		var services corev1.ServiceList
+		if err := r.List(ctx, &services,
+			client.InNamespace(tmpl.GetNamespace()),
+			client.MatchingLabelsSelector{Selector: selector},
+		); err != nil {
+			return nil, fmt.Errorf("failed to list services: %w", err)
+		}
+		for _, s := range services.Items {
+			seen[s.GetName()] = true
+		}
+	}
+
+	values := make([]string, 0, len(seen))
+	for v := range seen {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values, nil
+}
+
+// templateObjectives returns the objectives of the template for the values, controlled by the template.
+func templateObjectives(tmpl pyrrav1alpha1.ServiceLevelObjectiveTemplate, values []string) ([]pyrrav1alpha1.ServiceLevelObjective, error) {
+	spec, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&tmpl.Spec.Objective)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert objective template: %w", err)
+	}
+
+	controller := true
+	objectives := make([]pyrrav1alpha1.ServiceLevelObjective, 0, len(values))
+	names := map[string]string{}
+	for _, value := range values {
+		name := generatedObjectiveName(tmpl.GetName(), value)
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("values %q and %q result in the same objective name %q", other, value, name)
+		}
+		names[name] = value
+
+		rendered, err := renderTemplateStrings(spec, TemplateData{
+			Value:     value,
+			Namespace: tmpl.GetNamespace(),
+			Name:      tmpl.GetName(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to render objective for %q: %w", value, err)
+		}
+		var objective pyrrav1alpha1.ObjectiveTemplate
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rendered.(map[string]interface{}), &objective); err != nil {
+			return nil, fmt.Errorf("failed to convert objective for %q: %w", value, err)
+		}
+
+		o := pyrrav1alpha1.ServiceLevelObjective{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   tmpl.GetNamespace(),
+				Labels:      objective.Labels,
+				Annotations: objective.Annotations,
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: pyrrav1alpha1.GroupVersion.String(),
+					Kind:       "ServiceLevelObjectiveTemplate",
+					Name:       tmpl.GetName(),
+					UID:        tmpl.GetUID(),
+					Controller: &controller,
+				}},
+			},
+			Spec: objective.Spec,
+		}
+		// Invalid objectives would be rejected by the webhook, if it is enabled, or fail to reconcile.
+		if _, err := o.ValidateCreate(); err != nil {
+			return nil, fmt.Errorf("invalid objective for %q: %w", value, err)
+		}
+		objectives = append(objectives, o)
+	}
+	return objectives, nil
+}
+
+// renderTemplateStrings returns a copy of the unstructured value with its strings rendered as Go templates with the data.
+// Strings without templates are copied as they are.
+func renderTemplateStrings(value interface{}, data TemplateData) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v, nil
+		}
+		t, err := template.New("objective").Option("missingkey=error").Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		var sb strings.Builder
+		if err := t.Execute(&sb, data); err != nil {
+			return nil, err
+		}
+		return sb.String(), nil
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			r, err := renderTemplateStrings(item, data)
+			if err != nil {
+				return nil, err
+			}
+			rendered[key] = r
+		}
+		return rendered, nil
+	case []interface{}:
+		rendered := make([]interface{}, len(v))
+		for i, item := range v {
+			r, err := renderTemplateStrings(item, data)
+			if err != nil {
+				return nil, err
+			}
+			rendered[i] = r
+		}
+		return rendered, nil
+	default:
+		return v, nil
+	}
+}
+
+func (r *TemplateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("template").
+		For(&pyrrav1alpha1.ServiceLevelObjectiveTemplate{}).
+		Owns(&pyrrav1alpha1.ServiceLevelObjective{}).
+		Watches(&corev1.Service{}, handler.EnqueueRequestsFromMapFunc(r.serviceTemplates)).
+		Complete(r)
+}
+
+// serviceTemplates returns the templates in the Service's namespace that discover Services.
+func (r *TemplateReconciler) serviceTemplates(ctx context.Context, service client.Object) []reconcile.Request {
+	var list pyrrav1alpha1.ServiceLevelObjectiveTemplateList
+	if err := r.List(ctx, &list, client.InNamespace(service.GetNamespace())); err != nil {
+		level.Warn(r.Logger).Log("msg", "failed to list templates", "err", err)
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, t := range list.Items {
+		if t.Spec.Discovery.Services != nil {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&t)})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	prometheusv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// labelValuesQuerier returns a series for each of its values of the label of the last query.
+type labelValuesQuerier struct {
+	label   model.LabelName
+	values  []string
+	queries []string
+}
+
+func (q *labelValuesQuerier) Query(_ context.Context, query string, _ time.Time, _ ...prometheusv1.Option) (model.Value, prometheusv1.Warnings, error) {
+	q.queries = append(q.queries, query)
+	vector := model.Vector{}
+	for _, v := range q.values {
+		vector = append(vector, &model.Sample{Metric: model.Metric{q.label: model.LabelValue(v)}, Value: 1})
+	}
+	return vector, nil, nil
+}
+
+func handlerTemplate() *pyrrav1alpha1.ServiceLevelObjectiveTemplate {
+	return &pyrrav1alpha1.ServiceLevelObjectiveTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "monitoring", UID: types.UID("template-uid")},
+		Spec: pyrrav1alpha1.ServiceLevelObjectiveTemplateSpec{
+			Discovery: pyrrav1alpha1.TemplateDiscovery{
+				Prometheus: &pyrrav1alpha1.PrometheusDiscovery{Selector: `http_requests_total{job="api"}`, Label: "handler"},
+			},
+			Objective: pyrrav1alpha1.ObjectiveTemplate{
+				Labels:      map[string]string{"pyrra.dev/team": "api"},
+				Annotations: map[string]string{"pyrra.dev/handler": "{{.Value}}"},
+				Spec: pyrrav1alpha1.ServiceLevelObjectiveSpec{
+					Description: "Errors of {{.Value}} of {{.Name}}.",
+					Target:      "99",
+					Window:      "4w",
+					ServiceLevelIndicator: pyrrav1alpha1.ServiceLevelIndicator{
+						Ratio: &pyrrav1alpha1.RatioIndicator{
+							Errors: pyrrav1alpha1.Query{Metric: `http_requests_total{job="api",handler="{{.Value}}",code=~"5.."}`},
+							Total:  pyrrav1alpha1.Query{Metric: `http_requests_total{job="api",handler="{{.Value}}"}`},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestTemplateReconciler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+
+	tmpl := handlerTemplate()
+	// An objective not owned by the template is left alone.
+	unrelated := &pyrrav1alpha1.ServiceLevelObjective{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-latency", Namespace: "monitoring"},
+	}
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(tmpl, unrelated).
+		WithStatusSubresource(tmpl).
+		Build()
+
+	querier := &labelValuesQuerier{label: "handler", values: []string{"/users", "/orders"}}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := &TemplateReconciler{
+		Client:   c,
+		Logger:   kitlog.NewNopLogger(),
+		Querier:  querier,
+		Interval: 5 * time.Minute,
+		now:      func() time.Time { return now },
+	}
+	reconcileTemplate := func() []pyrrav1alpha1.ServiceLevelObjective {
+		result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tmpl)})
+		require.NoError(t, err)
+		require.Equal(t, 5*time.Minute, result.RequeueAfter)
+
+		var list pyrrav1alpha1.ServiceLevelObjectiveList
+		require.NoError(t, c.List(context.Background(), &list, client.InNamespace("monitoring")))
+		return list.Items
+	}
+
+	objectives := reconcileTemplate()
+	require.Equal(t, []string{`group by (handler) (http_requests_total{job="api"})`}, querier.queries)
+	require.Len(t, objectives, 3)
+	require.Equal(t, "api-latency", objectives[0].GetName())
+	require.Equal(t, "api-orders", objectives[1].GetName())
+	require.Equal(t, "api-users", objectives[2].GetName())
+
+	users := objectives[2]
+	require.True(t, metav1.IsControlledBy(&users, tmpl))
+	require.Equal(t, map[string]string{"pyrra.dev/team": "api"}, users.GetLabels())
+	require.Equal(t, "/users", users.GetAnnotations()["pyrra.dev/handler"])
+	require.Equal(t, "Errors of /users of api.", users.Spec.Description)
+	require.Equal(t, `http_requests_total{job="api",handler="/users"}`, users.Spec.ServiceLevelIndicator.Ratio.Total.Metric)
+	_, err := users.Internal()
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(tmpl), tmpl))
+	require.Equal(t, []string{"api-orders", "api-users"}, tmpl.Status.Objectives)
+	require.Equal(t, 2, tmpl.Status.ObjectiveCount)
+	require.True(t, now.Equal(tmpl.Status.LastDiscovery.Time))
+
+	// Manual changes are reverted, annotations of others are kept and values no longer discovered lose their objective.
+	users.Spec.Target = "90"
+	users.Annotations["argocd.argoproj.io/tracking-id"] = "api"
+	require.NoError(t, c.Update(context.Background(), &users))
+	querier.values = []string{"/users"}
+
+	objectives = reconcileTemplate()
+	require.Len(t, objectives, 2)
+	require.Equal(t, "api-users", objectives[1].GetName())
+	require.Equal(t, "99", objectives[1].Spec.Target)
+	require.Equal(t, "api", objectives[1].GetAnnotations()["argocd.argoproj.io/tracking-id"])
+
+	// Discovering nothing keeps the objectives, it's more likely a gap in the metrics.
+	querier.values = nil
+	objectives = reconcileTemplate()
+	require.Len(t, objectives, 2)
+}
+
+func TestTemplateReconciler_Services(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+
+	tmpl := handlerTemplate()
+	tmpl.Spec.Discovery = pyrrav1alpha1.TemplateDiscovery{
+		Services: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "api"}},
+	}
+	service := func(name, team string) *corev1.Service {
+		return &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "monitoring", Labels: map[string]string{"team": team}}}
+	}
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(tmpl, service("users", "api"), service("orders", "api"), service("billing", "payments")).
+		WithStatusSubresource(tmpl).
+		Build()
+
+	r := &TemplateReconciler{Client: c, Logger: kitlog.NewNopLogger()}
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tmpl)})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(tmpl), tmpl))
+	require.Equal(t, []string{"api-orders", "api-users"}, tmpl.Status.Objectives)
+
+	// Changed Services reconcile the templates discovering Services in their namespace.
+	requests := r.serviceTemplates(context.Background(), service("checkout", "api"))
+	require.Len(t, requests, 1)
+	require.Equal(t, client.ObjectKeyFromObject(tmpl), requests[0].NamespacedName)
+}
+
+func TestTemplateObjectives(t *testing.T) {
+	tmpl := handlerTemplate()
+
+	objectives, err := templateObjectives(*tmpl, []string{"/users"})
+	require.NoError(t, err)
+	require.Len(t, objectives, 1)
+	require.Equal(t, `http_requests_total{job="api",handler="/users",code=~"5.."}`, objectives[0].Spec.ServiceLevelIndicator.Ratio.Errors.Metric)
+	// The template itself isn't changed.
+	require.Equal(t, `http_requests_total{job="api",handler="{{.Value}}"}`, tmpl.Spec.Objective.Spec.ServiceLevelIndicator.Ratio.Total.Metric)
+
+	_, err = templateObjectives(*tmpl, []string{"/users", "users"})
+	require.EqualError(t, err, `values "/users" and "users" result in the same objective name "api-users"`)
+
+	tmpl.Spec.Objective.Spec.Description = "{{.Team}}"
+	_, err = templateObjectives(*tmpl, []string{"/users"})
+	require.ErrorContains(t, err, `failed to render objective for "/users"`)
+
+	tmpl = handlerTemplate()
+	tmpl.Spec.Objective.Spec.Target = "{{.Value}}"
+	_, err = templateObjectives(*tmpl, []string{"users"})
+	require.ErrorContains(t, err, `invalid objective for "users": failed to parse target "users"`)
+}
+
+func TestTemplateReconciler_Invalid(t *testing.T) {
+	r := &TemplateReconciler{}
+
+	tmpl := handlerTemplate()
+	_, err := r.discover(context.Background(), *tmpl)
+	require.EqualError(t, err, "prometheus discovery requires --prometheus-url")
+	require.True(t, isInvalidObjective(err))
+
+	r.Querier = &labelValuesQuerier{}
+	tmpl.Spec.Discovery.Services = &metav1.LabelSelector{}
+	_, err = r.discover(context.Background(), *tmpl)
+	require.EqualError(t, err, "exactly one of prometheus or services discovery must be set")
+
+	tmpl = handlerTemplate()
+	tmpl.Spec.Discovery.Prometheus.Label = "handler-name"
+	_, err = r.discover(context.Background(), *tmpl)
+	require.EqualError(t, err, `invalid discovery label "handler-name"`)
+
+	tmpl = handlerTemplate()
+	tmpl.Spec.Discovery.Prometheus.Selector = "sum(http_requests_total)"
+	_, err = r.discover(context.Background(), *tmpl)
+	require.ErrorContains(t, err, "failed to parse discovery selector")
+}
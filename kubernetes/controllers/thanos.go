@@ -0,0 +1,239 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// ThanosRuler writes the rules of objectives to ConfigMaps for Thanos Ruler,
+// split into as many ConfigMaps as needed to stay below MaxConfigMapSize.
+type ThanosRuler struct {
+	// Labels are set on the ConfigMaps for Thanos Ruler to discover them, like with a sidecar loading them into the ruler.
+	Labels map[string]string
+	// MaxConfigMapSize is the most bytes of rules written to a single ConfigMap.
+	// It defaults to the most data a ConfigMap can hold.
+	MaxConfigMapSize int
+	// ReloadURL is the URL of the Thanos Ruler to reload with POST /-/reload as ConfigMaps change.
+	// The ruler isn't reloaded if it is nil.
+	ReloadURL *url.URL
+	Client    *http.Client
+}
+
+func (t *ThanosRuler) maxConfigMapSize() int {
+	if t.MaxConfigMapSize > 0 && t.MaxConfigMapSize < maxConfigMapSize {
+		return t.MaxConfigMapSize
+	}
+	return maxConfigMapSize
+}
+
+func (t *ThanosRuler) client() *http.Client {
+	if t.Client != nil {
+		return t.Client
+	}
+	return http.DefaultClient
+}
+
+// Reload makes Thanos Ruler reload its rule files.
+func (t *ThanosRuler) Reload(ctx context.Context) error {
+	u := *t.ReloadURL
+	u.Path = path.Join(u.Path, "/-/reload")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to request thanos ruler: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if msg = bytes.TrimSpace(msg); len(msg) > 0 {
+			return fmt.Errorf("thanos ruler returned %s: %s", resp.Status, msg)
+		}
+		return fmt.Errorf("thanos ruler returned %s", resp.Status)
+	}
+	return nil
+}
+
+// thanosConfigMapName returns the name of the objective's i-th ConfigMap for Thanos Ruler.
+// The index is always appended, so names of different objectives never collide.
+func thanosConfigMapName(kubeObjective pyrrav1alpha1.ServiceLevelObjective, i int) string {
+	return fmt.Sprintf("pyrra-thanos-rule-%s-%d", kubeObjective.GetName(), i)
+}
+
+// splitRuleGroups splits the rule groups into chunks whose rule files are at most maxSize bytes.
+// Rule groups aren't split themselves, so a single group bigger than maxSize is an error.
+func splitRuleGroups(groups []monitoringv1.RuleGroup, maxSize int) ([][]monitoringv1.RuleGroup, error) {
+	size := func(groups []monitoringv1.RuleGroup) (int64, error) {
+		var sb strings.Builder
+		return WriteRuleSpec(&sb, monitoringv1.PrometheusRuleSpec{Groups: groups}, 0)
+	}
+
+	var chunks [][]monitoringv1.RuleGroup
+	var chunk []monitoringv1.RuleGroup
+	for _, group := range groups {
+		candidate := append(chunk[:len(chunk):len(chunk)], group)
+		s, err := size(candidate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal rule group: %w", err)
+		}
+		if s > int64(maxSize) && len(chunk) > 0 {
+			// Start a new chunk with the group.
+			chunks = append(chunks, chunk)
+			candidate = []monitoringv1.RuleGroup{group}
+			if s, err = size(candidate); err != nil {
+				return nil, fmt.Errorf("failed to marshal rule group: %w", err)
+			}
+		}
+		if s > int64(maxSize) {
+			return nil, fmt.Errorf("rule group %s is %d bytes, more than the %d bytes of a ConfigMap", group.Name, s, maxSize)
+		}
+		chunk = candidate
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+func (r *ServiceLevelObjectiveReconciler) reconcileThanosRuler(
+	ctx context.Context,
+	logger kitlog.Logger,
+	req ctrl.Request,
+	kubeObjective pyrrav1alpha1.ServiceLevelObjective,
+	destination Destination,
+	status *pyrrav1alpha1.ServiceLevelObjectiveStatus,
+) (ctrl.Result, error) {
+	configMaps, err := generate(ctx, func() ([]*corev1.ConfigMap, error) {
+		groups, err := r.prometheusRuleGroups(ctx, kubeObjective)
+		if err != nil {
+			return nil, err
+		}
+		chunks, err := splitRuleGroups(groups, r.ThanosRuler.maxConfigMapSize())
+		if err != nil {
+			return nil, err
+		}
+
+		configMaps := make([]*corev1.ConfigMap, 0, len(chunks))
+		for i, chunk := range chunks {
+			cm, err := newConfigMap(thanosConfigMapName(kubeObjective, i), kubeObjective, chunk)
+			if err != nil {
+				return nil, err
+			}
+			labels := make(map[string]string, len(cm.Labels)+len(r.ThanosRuler.Labels))
+			for k, v := range cm.Labels {
+				labels[k] = v
+			}
+			for k, v := range r.ThanosRuler.Labels {
+				labels[k] = v
+			}
+			cm.Labels = labels
+			destination.withLabels(cm)
+			configMaps = append(configMaps, cm)
+		}
+		return configMaps, nil
+	})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var changed bool
+	names := make([]string, 0, len(configMaps))
+	for _, cm := range configMaps {
+		c, err := r.writeConfigMap(ctx, logger, &kubeObjective, cm)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		changed = changed || c
+		names = append(names, cm.GetName())
+	}
+
+	// The objective's rules might have needed more ConfigMaps before.
+	deleted, err := r.deleteStaleThanosConfigMaps(ctx, logger, req, &kubeObjective, names)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	changed = changed || deleted
+
+	if changed && r.ThanosRuler.ReloadURL != nil {
+		level.Debug(logger).Log("msg", "reloading thanos ruler")
+		if err := r.ThanosRuler.Reload(ctx); err != nil {
+			return ctrl.Result{}, rulerPushError{err: fmt.Errorf("failed to reload thanos ruler: %w", err)}
+		}
+	}
+
+	status.Type = "ThanosRuler"
+	status.RuleName = strings.Join(names, ",")
+
+	return ctrl.Result{}, nil
+}
+
+// deleteStaleThanosConfigMaps deletes the objective's ConfigMaps for Thanos Ruler that aren't among the names.
+// It returns true if any ConfigMap was deleted.
+func (r *ServiceLevelObjectiveReconciler) deleteStaleThanosConfigMaps(
+	ctx context.Context,
+	logger kitlog.Logger,
+	req ctrl.Request,
+	kubeObjective *pyrrav1alpha1.ServiceLevelObjective,
+	names []string,
+) (bool, error) {
+	var list corev1.ConfigMapList
+	if err := r.List(ctx, &list, client.InNamespace(req.Namespace), client.MatchingLabels(r.ThanosRuler.Labels)); err != nil {
+		return false, fmt.Errorf("failed to list config maps: %w", err)
+	}
+
+	keep := make(map[string]bool, len(names))
+	for _, name := range names {
+		keep[name] = true
+	}
+
+	var deleted bool
+	for _, cm := range list.Items {
+		owner := metav1.GetControllerOf(&cm)
+		if keep[cm.GetName()] || owner == nil || owner.UID != kubeObjective.GetUID() {
+			continue
+		}
+		level.Info(logger).Log("msg", "deleting config map", "namespace", cm.GetNamespace(), "name", cm.GetName())
+		if err := r.Delete(ctx, &cm); client.IgnoreNotFound(err) != nil {
+			return false, fmt.Errorf("failed to delete config map: %w", err)
+		}
+		r.event(kubeObjective, corev1.EventTypeNormal, reasonRulesDeleted, "Deleted ConfigMap %s", cm.GetName())
+		deleted = true
+	}
+	return deleted, nil
+}
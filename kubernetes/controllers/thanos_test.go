@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func TestSplitRuleGroups(t *testing.T) {
+	groups := []monitoringv1.RuleGroup{
+		{Name: "a", Rules: []monitoringv1.Rule{{Record: "a", Expr: intstr.FromString(strings.Repeat("a", 100))}}},
+		{Name: "b", Rules: []monitoringv1.Rule{{Record: "b", Expr: intstr.FromString(strings.Repeat("b", 100))}}},
+		{Name: "c", Rules: []monitoringv1.Rule{{Record: "c", Expr: intstr.FromString(strings.Repeat("c", 100))}}},
+	}
+
+	chunks, err := splitRuleGroups(groups, 1<<20)
+	require.NoError(t, err)
+	require.Equal(t, [][]monitoringv1.RuleGroup{groups}, chunks)
+
+	// Every group is about 150 bytes, so two of them fit into a chunk.
+	chunks, err = splitRuleGroups(groups, 320)
+	require.NoError(t, err)
+	require.Equal(t, [][]monitoringv1.RuleGroup{groups[:2], groups[2:]}, chunks)
+
+	chunks, err = splitRuleGroups(groups, 200)
+	require.NoError(t, err)
+	require.Equal(t, [][]monitoringv1.RuleGroup{groups[:1], groups[1:2], groups[2:]}, chunks)
+
+	_, err = splitRuleGroups(groups, 100)
+	require.ErrorContains(t, err, "rule group a is")
+	require.ErrorContains(t, err, "more than the 100 bytes of a ConfigMap")
+}
+
+func TestServiceLevelObjectiveReconciler_ThanosRuler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+
+	objective := httpSLO.DeepCopy()
+	objective.TypeMeta = metav1.TypeMeta{}
+	objective.Namespace = "monitoring"
+
+	// A ConfigMap of the objective from when its rules needed more ConfigMaps.
+	isController := true
+	stale := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:      "pyrra-thanos-rule-http-5",
+		Namespace: "monitoring",
+		Labels:    map[string]string{"thanos_rule": "true"},
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: "pyrra.dev/v1alpha1", Kind: "ServiceLevelObjective", Name: "http", UID: objective.UID, Controller: &isController,
+		}},
+	}}
+	// ConfigMaps of other objectives aren't touched.
+	other := stale.DeepCopy()
+	other.Name = "pyrra-thanos-rule-other-0"
+	other.OwnerReferences[0].UID = "456"
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objective, stale, other).
+		WithStatusSubresource(objective).
+		Build()
+
+	var reloads int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/-/reload", r.URL.Path)
+		reloads++
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	r := &ServiceLevelObjectiveReconciler{
+		Client: c,
+		Logger: kitlog.NewNopLogger(),
+		ThanosRuler: &ThanosRuler{
+			Labels:           map[string]string{"thanos_rule": "true"},
+			MaxConfigMapSize: 3200,
+			ReloadURL:        u,
+			Client:           server.Client(),
+		},
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(objective)}
+
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, 1, reloads)
+
+	var list corev1.ConfigMapList
+	require.NoError(t, c.List(context.Background(), &list, client.InNamespace("monitoring")))
+	var names []string
+	for _, cm := range list.Items {
+		names = append(names, cm.GetName())
+		require.Equal(t, "true", cm.GetLabels()["thanos_rule"])
+		for key, data := range cm.Data {
+			require.Equal(t, cm.GetName()+".rules.yaml", key)
+			require.LessOrEqual(t, len(data), 3200)
+		}
+	}
+	require.Equal(t, []string{"pyrra-thanos-rule-http-0", "pyrra-thanos-rule-http-1", "pyrra-thanos-rule-other-0"}, names)
+
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+	require.Equal(t, "ThanosRuler", objective.Status.Type)
+	require.Equal(t, "pyrra-thanos-rule-http-0,pyrra-thanos-rule-http-1", objective.Status.RuleName)
+
+	// The ruler is only reloaded as the ConfigMaps change.
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, 1, reloads)
+
+	// Objectives evaluated by Loki aren't written to Thanos Ruler.
+	objective.Annotations = map[string]string{LokiRulerAnnotation: lokiRulerValue}
+	require.NoError(t, c.Update(context.Background(), objective))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, objective))
+	require.Equal(t, "ConfigMap", objective.Status.Type)
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// objectiveValidator validates objectives like their webhook.Validator
+// and additionally rejects objectives whose recording rules would write the same series as another objective's.
+// If it has a querier, it also warns about metrics that don't select any series.
+type objectiveValidator struct {
+	logger  kitlog.Logger
+	client  client.Reader
+	querier BudgetPolicyQuerier
+}
+
+var _ admission.CustomValidator = &objectiveValidator{}
+
+func (v *objectiveValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	kubeObjective, ok := obj.(*pyrrav1alpha1.ServiceLevelObjective)
+	if !ok {
+		return nil, fmt.Errorf("expected ServiceLevelObjective, got %T", obj)
+	}
+	warnings, err := kubeObjective.ValidateCreate()
+	if err != nil {
+		return warnings, err
+	}
+	return v.validate(ctx, kubeObjective, warnings)
+}
+
+func (v *objectiveValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	kubeObjective, ok := newObj.(*pyrrav1alpha1.ServiceLevelObjective)
+	if !ok {
+		return nil, fmt.Errorf("expected ServiceLevelObjective, got %T", newObj)
+	}
+	warnings, err := kubeObjective.ValidateUpdate(oldObj)
+	if err != nil {
+		return warnings, err
+	}
+	return v.validate(ctx, kubeObjective, warnings)
+}
+
+func (v *objectiveValidator) ValidateDelete(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	kubeObjective, ok := obj.(*pyrrav1alpha1.ServiceLevelObjective)
+	if !ok {
+		return nil, fmt.Errorf("expected ServiceLevelObjective, got %T", obj)
+	}
+	return kubeObjective.ValidateDelete()
+}
+
+func (v *objectiveValidator) validate(ctx context.Context, kubeObjective *pyrrav1alpha1.ServiceLevelObjective, warnings admission.Warnings) (admission.Warnings, error) {
+	if err := v.checkCollisions(ctx, kubeObjective); err != nil {
+		return warnings, err
+	}
+	if v.querier != nil {
+		warnings = append(warnings, v.verifyMetrics(ctx, kubeObjective)...)
+	}
+	return warnings, nil
+}
+
+// checkCollisions returns an error if any recording rule of the objective records the same metric with the same labels as one of another objective.
+// Rules don't have a namespace label, so this happens for objectives with the same name and metrics in different namespaces.
+func (v *objectiveValidator) checkCollisions(ctx context.Context, kubeObjective *pyrrav1alpha1.ServiceLevelObjective) error {
+	var list pyrrav1alpha1.ServiceLevelObjectiveList
+	if err := v.client.List(ctx, &list); err != nil {
+		return fmt.Errorf("failed to list objectives to check for colliding recording rules: %w", err)
+	}
+
+	// Composites can be created before the objectives they combine,
+	// their rules are checked once all of them exist.
+	resolved := *kubeObjective.DeepCopy()
+	if err := resolveComposite(&resolved, list.Items); err != nil {
+		return nil
+	}
+	series, err := recordedSeries(resolved)
+	if err != nil {
+		return err
+	}
+
+	for _, other := range list.Items {
+		if other.GetNamespace() == kubeObjective.GetNamespace() && other.GetName() == kubeObjective.GetName() {
+			continue
+		}
+		if err := resolveComposite(&other, list.Items); err != nil {
+			continue
+		}
+		otherSeries, err := recordedSeries(other)
+		if err != nil {
+			// Objectives that no rules can be generated for don't record anything.
+			continue
+		}
+		recorded := make(map[string]bool, len(otherSeries))
+		for _, s := range otherSeries {
+			recorded[s] = true
+		}
+		for _, s := range series {
+			if recorded[s] {
+				return fmt.Errorf(
+					"recording rule %s collides with the one of the objective %s/%s, rename the objective or set a pyrra.dev/ label to tell their rules apart",
+					s, other.GetNamespace(), other.GetName(),
+				)
+			}
+		}
+	}
+	return nil
+}
+
+// recordedSeries returns the metric names with the static labels of the objective's recording rules, like http_requests:increase4w{slo="http"}.
+func recordedSeries(kubeObjective pyrrav1alpha1.ServiceLevelObjective) ([]string, error) {
+	groups, err := makeRuleGroups(kubeObjective, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var series []string
+	for _, group := range groups {
+		for _, rule := range group.Rules {
+			if rule.Record == "" {
+				continue
+			}
+			series = append(series, rule.Record+labels.FromMap(rule.Labels).String())
+		}
+	}
+	return series, nil
+}
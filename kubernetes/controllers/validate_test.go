@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	kitlog "github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func TestObjectiveValidator_Collisions(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+
+	existing := httpSLO.DeepCopy()
+	existing.Namespace = "monitoring"
+
+	v := &objectiveValidator{
+		logger: kitlog.NewNopLogger(),
+		client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing).Build(),
+	}
+
+	// Updating the objective itself doesn't collide with its own rules.
+	_, err := v.ValidateUpdate(context.Background(), existing.DeepCopy(), existing.DeepCopy())
+	require.NoError(t, err)
+
+	// The same objective in another namespace records the same series.
+	objective := httpSLO.DeepCopy()
+	objective.Namespace = "default"
+	_, err = v.ValidateCreate(context.Background(), objective)
+	require.EqualError(t, err, `recording rule http_requests:increase4w{job="app", slo="http", team="foo"} collides with the one of the objective monitoring/http, rename the objective or set a pyrra.dev/ label to tell their rules apart`)
+
+	// Propagated labels tell their rules apart.
+	objective.Labels["pyrra.dev/namespace"] = "default"
+	_, err = v.ValidateCreate(context.Background(), objective)
+	require.NoError(t, err)
+
+	// Objectives with different names don't collide.
+	objective = httpSLO.DeepCopy()
+	objective.Namespace = "default"
+	objective.Name = "http-default"
+	_, err = v.ValidateCreate(context.Background(), objective)
+	require.NoError(t, err)
+
+	// Objectives of other metrics don't collide.
+	objective = httpSLO.DeepCopy()
+	objective.Namespace = "default"
+	objective.Spec.ServiceLevelIndicator.Ratio.Errors.Metric = `grpc_requests_total{job="app",code="Internal"}`
+	objective.Spec.ServiceLevelIndicator.Ratio.Total.Metric = `grpc_requests_total{job="app"}`
+	_, err = v.ValidateCreate(context.Background(), objective)
+	require.NoError(t, err)
+
+	// Invalid objectives are rejected before looking for collisions.
+	objective.Spec.Target = "0"
+	_, err = v.ValidateCreate(context.Background(), objective)
+	require.EqualError(t, err, "target must be between 0 and 100")
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+// verifyTimeout bounds how long admission waits for Prometheus.
+const verifyTimeout = 5 * time.Second
+
+// VerifyMetrics queries Prometheus for the series of the objective's metrics
+// and returns a problem for every metric or label matcher that doesn't select any series,
+// like typos or decommissioned metrics.
+//
+// Metrics counting errors only need to exist with the matched labels,
+// as they legitimately have no series while there are no errors.
+func VerifyMetrics(ctx context.Context, querier BudgetPolicyQuerier, objective slo.Objective) ([]string, error) {
+	type metric struct {
+		slo.Metric
+		errors bool
+	}
+
+	var metrics []metric
+	switch objective.IndicatorType() {
+	case slo.Ratio:
+		metrics = []metric{{Metric: objective.Indicator.Ratio.Total}, {Metric: objective.Indicator.Ratio.Errors, errors: true}}
+	case slo.Latency:
+		metrics = []metric{{Metric: objective.Indicator.Latency.Total}, {Metric: objective.Indicator.Latency.Success}}
+	case slo.LatencyNative:
+		metrics = []metric{{Metric: objective.Indicator.LatencyNative.Total}}
+	case slo.BoolGauge:
+		metrics = []metric{{Metric: objective.Indicator.BoolGauge.Metric}}
+		if objective.Indicator.Quantile != nil {
+			// The bool gauge is recorded by Pyrra itself from the histogram.
+			metrics = []metric{{Metric: objective.Indicator.Quantile.Total}}
+		}
+	case slo.Expression:
+		for _, expression := range []struct {
+			query  string
+			errors bool
+		}{
+			{query: objective.Indicator.Expression.Total},
+			{query: objective.Indicator.Expression.Errors, errors: true},
+		} {
+			ms, err := expressionMetrics(expression.query)
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range ms {
+				metrics = append(metrics, metric{Metric: m, errors: expression.errors})
+			}
+		}
+	}
+
+	var problems []string
+	checked := map[string]bool{}
+	for _, m := range metrics {
+		if checked[m.Name] {
+			continue
+		}
+		checked[m.Name] = true
+
+		ok, err := hasSeries(ctx, querier, slo.Metric{Name: m.Name})
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			problems = append(problems, fmt.Sprintf("metric %s has no series", m.Name))
+		}
+	}
+	if len(problems) > 0 {
+		// The matchers can't select anything of metrics that don't exist.
+		return problems, nil
+	}
+
+	for _, m := range metrics {
+		if m.errors {
+			for _, matcher := range m.LabelMatchers {
+				if matcher.Name == labels.MetricName {
+					continue
+				}
+				ok, err := hasSeries(ctx, querier, slo.Metric{Name: m.Name, LabelMatchers: []*labels.Matcher{
+					labels.MustNewMatcher(labels.MatchNotEqual, matcher.Name, ""),
+				}})
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					problems = append(problems, fmt.Sprintf("metric %s has no series with the label %s", m.Name, matcher.Name))
+				}
+			}
+			continue
+		}
+
+		ok, err := hasSeries(ctx, querier, m.Metric)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s selects no series", m.Metric.Metric()))
+		}
+	}
+
+	return problems, nil
+}
+
+// expressionMetrics returns the metrics the PromQL expression selects by their name.
+func expressionMetrics(query string) ([]slo.Metric, error) {
+	expr, err := parser.ParseExpr(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse expression: %w", err)
+	}
+
+	var metrics []slo.Metric
+	for _, matchers := range parser.ExtractSelectors(expr) {
+		for _, m := range matchers {
+			if m.Name == labels.MetricName && m.Type == labels.MatchEqual {
+				metrics = append(metrics, slo.Metric{Name: m.Value, LabelMatchers: matchers})
+			}
+		}
+	}
+	return metrics, nil
+}
+
+func hasSeries(ctx context.Context, querier BudgetPolicyQuerier, m slo.Metric) (bool, error) {
+	query := fmt.Sprintf("count(%s)", m.Metric())
+	value, _, err := querier.Query(ctx, query, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to query %s: %w", query, err)
+	}
+	vector, ok := value.(model.Vector)
+	if !ok {
+		return false, fmt.Errorf("expected vector, got %s", value.Type())
+	}
+	return len(vector) > 0 && vector[0].Value > 0, nil
+}
+
+// verifyMetrics returns warnings about metrics of the objective that don't select any series.
+// It never rejects objectives because of their metrics, as exporters might not be deployed yet.
+func (v *objectiveValidator) verifyMetrics(ctx context.Context, kubeObjective *pyrrav1alpha1.ServiceLevelObjective) admission.Warnings {
+	// The metrics of objectives evaluated by Loki are LogQL queries Prometheus doesn't know.
+	if IsLokiObjective(kubeObjective.GetAnnotations()) {
+		return nil
+	}
+	objective, err := kubeObjective.Internal()
+	if err != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+
+	problems, err := VerifyMetrics(ctx, v.querier, objective)
+	if err != nil {
+		level.Warn(v.logger).Log("msg", "failed to verify metrics", "namespace", kubeObjective.GetNamespace(), "name", kubeObjective.GetName(), "err", err)
+		return nil
+	}
+	return problems
+}
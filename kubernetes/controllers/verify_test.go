@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	prometheusv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// seriesQuerier returns the count of series for the queries it knows and no series otherwise.
+type seriesQuerier map[string]float64
+
+func (q seriesQuerier) Query(_ context.Context, query string, _ time.Time, _ ...prometheusv1.Option) (model.Value, prometheusv1.Warnings, error) {
+	count, ok := q[query]
+	if !ok {
+		return model.Vector{}, nil, nil
+	}
+	return model.Vector{{Value: model.SampleValue(count)}}, nil, nil
+}
+
+func TestVerifyMetrics(t *testing.T) {
+	objective, err := httpSLO.Internal()
+	require.NoError(t, err)
+
+	testcases := []struct {
+		name     string
+		series   seriesQuerier
+		problems []string
+	}{{
+		name: "valid",
+		series: seriesQuerier{
+			`count(http_requests_total)`:             10,
+			`count(http_requests_total{job="app"})`:  5,
+			`count(http_requests_total{status!=""})`: 10,
+			`count(http_requests_total{job!=""})`:    10,
+		},
+	}, {
+		name:     "missingMetric",
+		series:   seriesQuerier{},
+		problems: []string{"metric http_requests_total has no series"},
+	}, {
+		name: "typoInMatchers",
+		series: seriesQuerier{
+			`count(http_requests_total)`: 10,
+		},
+		problems: []string{
+			`http_requests_total{job="app"} selects no series`,
+			"metric http_requests_total has no series with the label job",
+			"metric http_requests_total has no series with the label status",
+		},
+	}}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			problems, err := VerifyMetrics(context.Background(), tc.series, objective)
+			require.NoError(t, err)
+			require.Equal(t, tc.problems, problems)
+		})
+	}
+}
+
+func TestVerifyMetrics_Expression(t *testing.T) {
+	objective := httpSLO.DeepCopy()
+	objective.Spec.ServiceLevelIndicator = pyrrav1alpha1.ServiceLevelIndicator{
+		Expression: &pyrrav1alpha1.ExpressionIndicator{
+			Errors: `checkout_failed_total{reason="payment"} + checkout_timeouts_total`,
+			Total:  `checkout_started_total{job="shop"}`,
+		},
+	}
+	internal, err := objective.Internal()
+	require.NoError(t, err)
+
+	problems, err := VerifyMetrics(context.Background(), seriesQuerier{
+		`count(checkout_started_total)`:             10,
+		`count(checkout_started_total{job="shop"})`: 10,
+		`count(checkout_failed_total)`:              2,
+		`count(checkout_failed_total{reason!=""})`:  2,
+	}, internal)
+	require.NoError(t, err)
+	require.Equal(t, []string{"metric checkout_timeouts_total has no series"}, problems)
+}
+
+func TestObjectiveValidator_Metrics(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+
+	v := &objectiveValidator{
+		logger:  kitlog.NewNopLogger(),
+		client:  fake.NewClientBuilder().WithScheme(scheme).Build(),
+		querier: seriesQuerier{},
+	}
+
+	objective := httpSLO.DeepCopy()
+	objective.Namespace = "monitoring"
+	warnings, err := v.ValidateCreate(context.Background(), objective)
+	require.NoError(t, err)
+	require.Equal(t, []string{"metric http_requests_total has no series"}, []string(warnings))
+
+	warnings, err = v.ValidateUpdate(context.Background(), httpSLO.DeepCopy(), objective)
+	require.NoError(t, err)
+	require.Contains(t, warnings, "metric http_requests_total has no series")
+
+	// Objectives evaluated by Loki aren't verified against Prometheus.
+	objective.Annotations = map[string]string{LokiRulerAnnotation: lokiRulerValue}
+	objective.Spec.ServiceLevelIndicator = pyrrav1alpha1.ServiceLevelIndicator{Logs: &pyrrav1alpha1.LogsIndicator{
+		Total:  `{job="http"}`,
+		Errors: `{job="http"} |= "error"`,
+	}}
+	warnings, err = v.ValidateCreate(context.Background(), objective)
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+
+	// Invalid objectives are still rejected.
+	objective.Spec.Target = "200"
+	_, err = v.ValidateCreate(context.Background(), objective)
+	require.Error(t, err)
+
+	_, err = v.ValidateCreate(context.Background(), &pyrrav1alpha1.ServiceLevelObjectiveRevision{})
+	require.Error(t, err)
+}
@@ -0,0 +1,282 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	kitlog "github.com/go-kit/log"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// RuleWriter writes the rules of objectives, or what's made of them like dashboards, to one output.
+// The reconciler applies all its writers to each objective in order, so several outputs can be enabled at once.
+type RuleWriter interface {
+	// Apply writes the objective. Writers leave objectives they aren't responsible for alone,
+	// like the Loki ruler the objectives evaluated by Prometheus.
+	Apply(ctx context.Context, objective *WriterObjective) (ctrl.Result, error)
+	// Delete removes what Apply wrote for the deleted objective.
+	// Only the objective's request and logger are set.
+	Delete(ctx context.Context, objective *WriterObjective) error
+}
+
+// WriterObjective is the objective RuleWriters write, with its destination and the status they update.
+type WriterObjective struct {
+	Request     ctrl.Request
+	Logger      kitlog.Logger
+	Objective   pyrrav1alpha1.ServiceLevelObjective
+	Destination Destination
+	Status      *pyrrav1alpha1.ServiceLevelObjectiveStatus
+	// Rules are where the writers wrote the objective's rules to, like prometheusrule, for the rules label of pyrra_slo_info.
+	Rules []string
+}
+
+// wrote adds the output to the objective's rules if the write succeeded, so failed outputs aren't reported as written.
+// It returns the write's error.
+func (o *WriterObjective) wrote(output string, err error) error {
+	if err == nil {
+		o.Rules = append(o.Rules, output)
+	}
+	return err
+}
+
+// Names of the rule outputs of the objectives evaluated by Prometheus.
+const (
+	RuleOutputPrometheusRule = rulesPrometheusRule
+	RuleOutputConfigMap      = rulesConfigMap
+	RuleOutputThanosRuler    = rulesThanosRuler
+)
+
+// RuleOutputs returns the writers of the named rule outputs for the reconciler,
+// after the one of objectives evaluated by Loki and before the remote cluster, Grafana, Alertmanager and backfill ones.
+// ThanosRuler must be set for thanos-ruler.
+func (r *ServiceLevelObjectiveReconciler) RuleOutputs(outputs ...string) []RuleWriter {
+	writers := []RuleWriter{LokiRulerWriter{Reconciler: r}}
+	for _, output := range outputs {
+		switch output {
+		case RuleOutputPrometheusRule:
+			writers = append(writers, PrometheusRuleWriter{Reconciler: r})
+		case RuleOutputConfigMap:
+			writers = append(writers, ConfigMapWriter{Reconciler: r})
+		case RuleOutputThanosRuler:
+			writers = append(writers, ThanosRulerWriter{Reconciler: r})
+		}
+	}
+	if len(r.RemoteClusters) > 0 {
+		writers = append(writers, RemoteClusterWriter{Reconciler: r})
+	}
+	if r.GrafanaAlertRules != nil {
+		writers = append(writers, GrafanaAlertRuleWriter{Reconciler: r})
+	}
+	if r.GrafanaDashboards != nil {
+		writers = append(writers, GrafanaDashboardWriter{Reconciler: r})
+	}
+	if r.AlertmanagerConfigs != nil {
+		writers = append(writers, AlertmanagerConfigWriter{Reconciler: r})
+	}
+	if r.Backfill != nil {
+		writers = append(writers, BackfillWriter{Reconciler: r})
+	}
+	return writers
+}
+
+// writers returns the reconciler's writers.
+// Without any, the rules of objectives evaluated by Prometheus are written to Thanos Ruler if it is set,
+// to ConfigMaps in ConfigMapMode or to PrometheusRules otherwise.
+func (r *ServiceLevelObjectiveReconciler) writers() []RuleWriter {
+	if len(r.Writers) > 0 {
+		return r.Writers
+	}
+	switch {
+	case r.ThanosRuler != nil:
+		return r.RuleOutputs(RuleOutputThanosRuler)
+	case r.ConfigMapMode:
+		return r.RuleOutputs(RuleOutputConfigMap)
+	default:
+		return r.RuleOutputs(RuleOutputPrometheusRule)
+	}
+}
+
+// PrometheusRuleWriter writes the rules of objectives evaluated by Prometheus to PrometheusRules.
+type PrometheusRuleWriter struct {
+	Reconciler *ServiceLevelObjectiveReconciler
+}
+
+func (w PrometheusRuleWriter) Apply(ctx context.Context, o *WriterObjective) (ctrl.Result, error) {
+	if IsLokiObjective(o.Objective.GetAnnotations()) {
+		return ctrl.Result{}, nil
+	}
+	if err := w.Reconciler.reconcileSharedBurnrates(ctx, o.Logger, o.Request.Namespace, false); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to reconcile shared burn rates: %w", err)
+	}
+	result, err := w.Reconciler.reconcilePrometheusRule(ctx, o.Logger, o.Request, o.Objective, o.Destination, o.Status)
+	return result, o.wrote(rulesPrometheusRule, err)
+}
+
+// Delete writes the shared burn rates of the namespace without the objective's,
+// its own PrometheusRule is garbage collected with it through its owner reference, like the shared ones without owners left.
+func (w PrometheusRuleWriter) Delete(ctx context.Context, o *WriterObjective) error {
+	return w.Reconciler.reconcileSharedBurnrates(ctx, o.Logger, o.Request.Namespace, true)
+}
+
+// ConfigMapWriter writes the rules of objectives evaluated by Prometheus to ConfigMaps in the default Prometheus format,
+// sharded ones if the reconciler has ConfigMapShards.
+type ConfigMapWriter struct {
+	Reconciler *ServiceLevelObjectiveReconciler
+}
+
+func (w ConfigMapWriter) Apply(ctx context.Context, o *WriterObjective) (ctrl.Result, error) {
+	if IsLokiObjective(o.Objective.GetAnnotations()) {
+		return ctrl.Result{}, nil
+	}
+	if w.Reconciler.ConfigMapShards > 0 {
+		result, err := w.Reconciler.reconcileConfigMapShard(ctx, o.Logger, o.Request, o.Objective, o.Destination, o.Status)
+		return result, o.wrote(rulesConfigMap, err)
+	}
+	result, err := w.Reconciler.reconcileConfigMap(ctx, o.Logger, o.Request, o.Objective, o.Destination, o.Status)
+	if err := o.wrote(rulesConfigMap, err); err != nil {
+		return result, err
+	}
+	// The objective's rules were in a sharded ConfigMap before the ConfigMaps stopped being sharded.
+	return result, w.Reconciler.removeFromConfigMapShards(ctx, o.Logger, o.Request.NamespacedName, "")
+}
+
+// Delete removes the objective's rules from its sharded ConfigMap,
+// its own ConfigMaps are garbage collected with it through their owner reference.
+func (w ConfigMapWriter) Delete(ctx context.Context, o *WriterObjective) error {
+	return w.Reconciler.removeFromConfigMapShards(ctx, o.Logger, o.Request.NamespacedName, "")
+}
+
+// ThanosRulerWriter writes the rules of objectives evaluated by Prometheus to ConfigMaps for the reconciler's ThanosRuler.
+type ThanosRulerWriter struct {
+	Reconciler *ServiceLevelObjectiveReconciler
+}
+
+func (w ThanosRulerWriter) Apply(ctx context.Context, o *WriterObjective) (ctrl.Result, error) {
+	if IsLokiObjective(o.Objective.GetAnnotations()) {
+		return ctrl.Result{}, nil
+	}
+	result, err := w.Reconciler.reconcileThanosRuler(ctx, o.Logger, o.Request, o.Objective, o.Destination, o.Status)
+	return result, o.wrote(rulesThanosRuler, err)
+}
+
+// Delete does nothing, ConfigMaps are garbage collected with their objective through their owner reference.
+func (w ThanosRulerWriter) Delete(context.Context, *WriterObjective) error { return nil }
+
+// LokiRulerWriter writes the rules of objectives evaluated by Loki to the Loki ruler of their destination,
+// or to ConfigMaps for the Loki rules sidecar if it has none.
+type LokiRulerWriter struct {
+	Reconciler *ServiceLevelObjectiveReconciler
+}
+
+func (w LokiRulerWriter) Apply(ctx context.Context, o *WriterObjective) (ctrl.Result, error) {
+	if !IsLokiObjective(o.Objective.GetAnnotations()) {
+		return ctrl.Result{}, nil
+	}
+	if o.Destination.LokiRuler == nil {
+		result, err := w.Reconciler.reconcileConfigMap(ctx, o.Logger, o.Request, o.Objective, o.Destination, o.Status)
+		return result, o.wrote(rulesConfigMap, err)
+	}
+	result, err := w.Reconciler.reconcileLokiRuler(ctx, o.Logger, o.Request, o.Objective, o.Destination, o.Status)
+	return result, o.wrote(rulesLokiRuler, err)
+}
+
+// Delete deletes the objective's rule groups from all Loki rulers, there's no owner reference to clean them up.
+func (w LokiRulerWriter) Delete(ctx context.Context, o *WriterObjective) error {
+	if !w.Reconciler.lokiEnabled() {
+		return nil
+	}
+	return w.Reconciler.deleteLokiRuleGroups(ctx, o.Logger, o.Request)
+}
+
+// GrafanaAlertRuleWriter writes the alerts of objectives evaluated by Prometheus to GrafanaAlertRuleGroups
+// for the reconciler's GrafanaAlertRules.
+type GrafanaAlertRuleWriter struct {
+	Reconciler *ServiceLevelObjectiveReconciler
+}
+
+func (w GrafanaAlertRuleWriter) Apply(ctx context.Context, o *WriterObjective) (ctrl.Result, error) {
+	if !w.Reconciler.grafanaAlerts(o.Objective) {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{}, w.Reconciler.reconcileGrafanaAlertRuleGroup(ctx, o.Logger, o.Objective)
+}
+
+// Delete deletes the objective's GrafanaAlertRuleGroup, for the grafana-operator to remove its alert rules from the folder
+// without waiting for the garbage collection through its owner reference.
+func (w GrafanaAlertRuleWriter) Delete(ctx context.Context, o *WriterObjective) error {
+	return w.Reconciler.deleteGrafanaResource(ctx, o.Logger, grafanaAlertRuleGroupGVK, o.Request.NamespacedName)
+}
+
+// GrafanaDashboardWriter creates a GrafanaDashboard for each objective for the reconciler's GrafanaDashboards.
+type GrafanaDashboardWriter struct {
+	Reconciler *ServiceLevelObjectiveReconciler
+}
+
+func (w GrafanaDashboardWriter) Apply(ctx context.Context, o *WriterObjective) (ctrl.Result, error) {
+	return ctrl.Result{}, w.Reconciler.reconcileGrafanaDashboard(ctx, o.Logger, o.Objective)
+}
+
+// Delete deletes the objective's GrafanaDashboard, for the grafana-operator to remove it from the folder
+// without waiting for the garbage collection through its owner reference.
+func (w GrafanaDashboardWriter) Delete(ctx context.Context, o *WriterObjective) error {
+	return w.Reconciler.deleteGrafanaResource(ctx, o.Logger, grafanaDashboardGVK, o.Request.NamespacedName)
+}
+
+// checkOutputs returns an invalidObjectiveError if any of the writers can't write the objective,
+// before any of them wrote it, so its rules aren't written to some of the outputs only.
+func checkOutputs(writers []RuleWriter, o *WriterObjective) error {
+	if !o.Objective.Spec.SharedBurnrates || IsLokiObjective(o.Objective.GetAnnotations()) {
+		return nil
+	}
+	for _, w := range writers {
+		switch w.(type) {
+		case ConfigMapWriter, ThanosRulerWriter:
+			return invalidObjectiveError{err: fmt.Errorf("shared burn rates are only written to PrometheusRules, not to the %s output", outputName(w))}
+		}
+	}
+	return nil
+}
+
+// outputName returns the name of the writer's output, like prometheusrule, for the logs and spans of its writes.
+func outputName(w RuleWriter) string {
+	switch w.(type) {
+	case PrometheusRuleWriter:
+		return rulesPrometheusRule
+	case ConfigMapWriter:
+		return rulesConfigMap
+	case ThanosRulerWriter:
+		return rulesThanosRuler
+	case LokiRulerWriter:
+		return rulesLokiRuler
+	case GrafanaAlertRuleWriter:
+		return "grafana-alert-rules"
+	case GrafanaDashboardWriter:
+		return "grafana-dashboard"
+	case AlertmanagerConfigWriter:
+		return "alertmanager-config"
+	case BackfillWriter:
+		return "backfill"
+	case RemoteClusterWriter:
+		return "remote-clusters"
+	default:
+		return fmt.Sprintf("%T", w)
+	}
+}
@@ -0,0 +1,269 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+type recordingWriter struct {
+	name    string
+	applied *[]string
+	deleted *[]string
+	result  ctrl.Result
+}
+
+func (w recordingWriter) Apply(_ context.Context, o *WriterObjective) (ctrl.Result, error) {
+	*w.applied = append(*w.applied, w.name+"/"+o.Objective.GetName())
+	return w.result, nil
+}
+
+func (w recordingWriter) Delete(_ context.Context, o *WriterObjective) error {
+	*w.deleted = append(*w.deleted, w.name+"/"+o.Request.Name)
+	return nil
+}
+
+func TestServiceLevelObjectiveReconciler_writers(t *testing.T) {
+	writerTypes := func(r *ServiceLevelObjectiveReconciler) []string {
+		var types []string
+		for _, w := range r.writers() {
+			types = append(types, fmt.Sprintf("%T", w))
+		}
+		return types
+	}
+
+	require.Equal(t, []string{"controllers.LokiRulerWriter", "controllers.PrometheusRuleWriter"},
+		writerTypes(&ServiceLevelObjectiveReconciler{}))
+	require.Equal(t, []string{"controllers.LokiRulerWriter", "controllers.ConfigMapWriter"},
+		writerTypes(&ServiceLevelObjectiveReconciler{ConfigMapMode: true}))
+	require.Equal(t, []string{"controllers.LokiRulerWriter", "controllers.ThanosRulerWriter", "controllers.GrafanaAlertRuleWriter", "controllers.GrafanaDashboardWriter"},
+		writerTypes(&ServiceLevelObjectiveReconciler{
+			ConfigMapMode:     true,
+			ThanosRuler:       &ThanosRuler{},
+			GrafanaAlertRules: &GrafanaAlertRules{},
+			GrafanaDashboards: &GrafanaDashboards{},
+		}))
+
+	r := &ServiceLevelObjectiveReconciler{}
+	r.Writers = r.RuleOutputs(RuleOutputPrometheusRule, RuleOutputConfigMap)
+	require.Equal(t, []string{"controllers.LokiRulerWriter", "controllers.PrometheusRuleWriter", "controllers.ConfigMapWriter"}, writerTypes(r))
+}
+
+func TestServiceLevelObjectiveReconciler_Writers(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	slo := httpSLO.DeepCopy()
+	slo.TypeMeta = metav1.TypeMeta{}
+	slo.Namespace = "monitoring"
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(slo).
+		WithStatusSubresource(slo).
+		Build()
+
+	var applied, deleted []string
+	r := &ServiceLevelObjectiveReconciler{Client: c, Logger: kitlog.NewNopLogger()}
+	r.Writers = append(r.RuleOutputs(RuleOutputPrometheusRule, RuleOutputConfigMap),
+		recordingWriter{name: "first", applied: &applied, deleted: &deleted, result: ctrl.Result{RequeueAfter: time.Hour}},
+		recordingWriter{name: "second", applied: &applied, deleted: &deleted, result: ctrl.Result{RequeueAfter: time.Minute}},
+	)
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(slo)}
+
+	// All writers are applied and the objective is requeued after the shortest time any of them asked for.
+	result, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, ctrl.Result{RequeueAfter: time.Minute}, result)
+	require.Equal(t, []string{"first/http", "second/http"}, applied)
+
+	var rule monitoringv1.PrometheusRule
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, &rule))
+	var cm corev1.ConfigMap
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "monitoring", Name: "pyrra-recording-rule-http"}, &cm))
+
+	reconciledObjectives.mu.Lock()
+	require.Equal(t, "prometheusrule,configmap", reconciledObjectives.objectives[req.NamespacedName].rules)
+	reconciledObjectives.mu.Unlock()
+
+	// All writers delete what they wrote for deleted objectives.
+	require.NoError(t, c.Delete(context.Background(), slo))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, []string{"first/http", "second/http"}, deleted)
+}
+
+// failingWriter fails to write the rules of objectives to its output while err is set.
+type failingWriter struct {
+	err *error
+}
+
+func (w failingWriter) Apply(_ context.Context, o *WriterObjective) (ctrl.Result, error) {
+	return ctrl.Result{}, o.wrote(rulesLokiRuler, *w.err)
+}
+
+func (w failingWriter) Delete(context.Context, *WriterObjective) error { return nil }
+
+func TestServiceLevelObjectiveReconciler_PartialWrites(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	slo := httpSLO.DeepCopy()
+	slo.TypeMeta = metav1.TypeMeta{}
+	slo.Namespace = "monitoring"
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(slo).
+		WithStatusSubresource(slo).
+		Build()
+
+	var applied, deleted []string
+	pushErr := error(rulerPushError{err: fmt.Errorf("ruler is down")})
+	r := &ServiceLevelObjectiveReconciler{Client: c, Logger: kitlog.NewNopLogger()}
+	r.Writers = append(
+		[]RuleWriter{failingWriter{err: &pushErr}},
+		append(r.RuleOutputs(RuleOutputPrometheusRule),
+			recordingWriter{name: "dashboard", applied: &applied, deleted: &deleted},
+		)...,
+	)
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(slo)}
+	status := func() pyrrav1alpha1.ServiceLevelObjectiveStatus {
+		var o pyrrav1alpha1.ServiceLevelObjective
+		require.NoError(t, c.Get(context.Background(), req.NamespacedName, &o))
+		return o.Status
+	}
+
+	// The ruler being down doesn't keep the PrometheusRule and the later writers from being written.
+	_, err := r.Reconcile(context.Background(), req)
+	require.EqualError(t, err, "ruler is down")
+	require.Equal(t, []string{"dashboard/http"}, applied)
+	var rule monitoringv1.PrometheusRule
+	require.NoError(t, c.Get(context.Background(), req.NamespacedName, &rule))
+
+	s := status()
+	require.Equal(t, "PrometheusRule", s.Type)
+	degraded := meta.FindStatusCondition(s.Conditions, pyrrav1alpha1.ConditionDegraded)
+	require.NotNil(t, degraded)
+	require.Equal(t, metav1.ConditionTrue, degraded.Status)
+	require.Equal(t, reasonPartiallyWritten, degraded.Reason)
+	require.Equal(t, "Only some outputs were written: ruler is down", degraded.Message)
+	require.True(t, meta.IsStatusConditionFalse(s.Conditions, pyrrav1alpha1.ConditionRulerSynced))
+	require.True(t, meta.IsStatusConditionFalse(s.Conditions, pyrrav1alpha1.ConditionReady))
+	// The failed output isn't reported as written.
+	rules := func() string {
+		reconciledObjectives.mu.Lock()
+		defer reconciledObjectives.mu.Unlock()
+		return reconciledObjectives.objectives[req.NamespacedName].rules
+	}
+	require.Equal(t, rulesPrometheusRule, rules())
+
+	// Once the ruler is back the objective isn't degraded anymore.
+	pushErr = nil
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	s = status()
+	require.Nil(t, meta.FindStatusCondition(s.Conditions, pyrrav1alpha1.ConditionDegraded))
+	require.True(t, meta.IsStatusConditionTrue(s.Conditions, pyrrav1alpha1.ConditionReady))
+	require.Equal(t, rulesLokiRuler+","+rulesPrometheusRule, rules())
+
+	// Objectives failing to write to all their outputs aren't degraded, they're not written at all.
+	pushErr = rulerPushError{err: fmt.Errorf("ruler is down")}
+	r.Writers = []RuleWriter{failingWriter{err: &pushErr}}
+	_, err = r.Reconcile(context.Background(), req)
+	require.Error(t, err)
+	require.Nil(t, meta.FindStatusCondition(status().Conditions, pyrrav1alpha1.ConditionDegraded))
+}
+
+func TestServiceLevelObjectiveReconciler_LogsAndSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+	require.NoError(t, monitoringv1.AddToScheme(scheme))
+
+	slo := httpSLO.DeepCopy()
+	slo.TypeMeta = metav1.TypeMeta{}
+	slo.Namespace = "monitoring"
+
+	c := newFakeClientBuilder().
+		WithScheme(scheme).
+		WithObjects(slo).
+		WithStatusSubresource(slo).
+		Build()
+
+	var logs bytes.Buffer
+	pushErr := error(rulerPushError{err: fmt.Errorf("ruler is down")})
+	r := &ServiceLevelObjectiveReconciler{Client: c, Logger: kitlog.NewJSONLogger(&logs)}
+	r.Writers = append([]RuleWriter{failingWriter{err: &pushErr}}, r.RuleOutputs(RuleOutputPrometheusRule)...)
+
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(slo)})
+	require.EqualError(t, err, "ruler is down")
+
+	// The failed write is logged with the objective's UID, the reconcile and the output.
+	var line map[string]interface{}
+	for _, l := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+		require.NoError(t, json.Unmarshal(l, &line))
+		if line["msg"] == "failed to write objective" {
+			break
+		}
+	}
+	require.Equal(t, "failed to write objective", line["msg"])
+	require.Equal(t, "123", line["uid"])
+	require.Equal(t, "controllers.failingWriter", line["output"])
+	require.Equal(t, "prometheusrule", outputName(PrometheusRuleWriter{}))
+	require.NotEmpty(t, line["reconcile_id"])
+	require.NotEmpty(t, line["trace_id"])
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		if _, ok := spans[span.Name()]; !ok {
+			spans[span.Name()] = span
+		}
+	}
+	reconcile := spans["Reconcile"]
+	require.NotNil(t, reconcile)
+	require.Contains(t, reconcile.Attributes(), attribute.String("uid", "123"))
+	require.Contains(t, reconcile.Attributes(), attribute.String("reconcile_id", line["reconcile_id"].(string)))
+	require.Equal(t, codes.Error, reconcile.Status().Code)
+	require.Equal(t, line["trace_id"], reconcile.SpanContext().TraceID().String())
+
+	// The PrometheusRule is written within the span of its output, which is within the reconcile's span.
+	output := spans["write output"]
+	require.NotNil(t, output)
+	require.Equal(t, reconcile.SpanContext().SpanID(), output.Parent().SpanID())
+	apply := spans["apply"]
+	require.NotNil(t, apply)
+	require.Contains(t, apply.Attributes(), attribute.String("kind", "PrometheusRule"))
+	require.Equal(t, reconcile.SpanContext().TraceID(), apply.SpanContext().TraceID())
+	require.NotNil(t, spans["patch status"])
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2024 Pyrra Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// WriteRuleSpec writes the spec as YAML to w, one rule group at a time,
+// so that large outputs are never held in memory as a whole.
+// Every line is indented by indent spaces, for the spec to be nested within another document.
+// The output unmarshals the same as of yaml.Marshal, only long lines may wrap at different places.
+// The number of bytes written is returned.
+func WriteRuleSpec(w io.Writer, spec monitoringv1.PrometheusRuleSpec, indent int) (int64, error) {
+	cw := &countingWriter{w: w}
+	prefix := bytes.Repeat([]byte(" "), indent)
+
+	if len(spec.Groups) == 0 {
+		b, err := yaml.Marshal(spec)
+		if err != nil {
+			return cw.n, fmt.Errorf("failed to marshal rules: %w", err)
+		}
+		err = writeIndented(cw, b, prefix, prefix)
+		return cw.n, err
+	}
+
+	if err := writeIndented(cw, []byte("groups:\n"), prefix, prefix); err != nil {
+		return cw.n, err
+	}
+
+	itemPrefix := append(append([]byte{}, prefix...), "- "...)
+	linePrefix := append(append([]byte{}, prefix...), "  "...)
+	for _, group := range spec.Groups {
+		b, err := yaml.Marshal(group)
+		if err != nil {
+			return cw.n, fmt.Errorf("failed to marshal rule group %s: %w", group.Name, err)
+		}
+		if err := writeIndented(cw, b, itemPrefix, linePrefix); err != nil {
+			return cw.n, err
+		}
+	}
+
+	return cw.n, nil
+}
+
+// writeIndented writes the lines of b with the first prefix before the first line and the other one before all others.
+// Empty lines aren't indented, the same as by the YAML encoder.
+func writeIndented(w io.Writer, b, first, other []byte) error {
+	prefix := first
+	for len(b) > 0 {
+		line := b
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			line = b[:i+1]
+		}
+		b = b[len(line):]
+
+		if len(line) > 1 {
+			if _, err := w.Write(prefix); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+		prefix = other
+	}
+	return nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// WritePrometheusRule writes the PrometheusRule as YAML to w, streaming its spec with WriteRuleSpec.
+// The number of bytes written is returned.
+func WritePrometheusRule(w io.Writer, rule *monitoringv1.PrometheusRule) (int64, error) {
+	cw := &countingWriter{w: w}
+
+	if len(rule.Spec.Groups) == 0 {
+		b, err := yaml.Marshal(rule)
+		if err != nil {
+			return cw.n, fmt.Errorf("failed to marshal rule: %w", err)
+		}
+		_, err = cw.Write(b)
+		return cw.n, err
+	}
+
+	// The spec is the last field marshaled, as fields are sorted by name.
+	// Marshal everything else and stream the spec in place of its empty value.
+	header := rule.DeepCopy()
+	header.Spec = monitoringv1.PrometheusRuleSpec{}
+	b, err := yaml.Marshal(header)
+	if err != nil {
+		return cw.n, fmt.Errorf("failed to marshal rule: %w", err)
+	}
+	emptySpec := []byte("spec: {}\n")
+	if !bytes.HasSuffix(b, emptySpec) {
+		return cw.n, errors.New("failed to marshal rule: unexpected spec position")
+	}
+	b = append(b[:len(b)-len(emptySpec)], "spec:\n"...)
+	if _, err := cw.Write(b); err != nil {
+		return cw.n, err
+	}
+
+	_, err = WriteRuleSpec(cw, rule.Spec, 2)
+	return cw.n, err
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+)
+
+func TestWriteRuleSpec(t *testing.T) {
+	groups, err := makeRuleGroups(httpSLO, true)
+	require.NoError(t, err)
+	groups = append(groups, monitoringv1.RuleGroup{
+		Name: "multiline",
+		Rules: []monitoringv1.Rule{{
+			Alert: "Multiline",
+			Expr:  intstr.FromString("sum(rate(errors[5m]))\n\n  /\nsum(rate(total[5m]))\n"),
+		}},
+	})
+
+	for _, spec := range []monitoringv1.PrometheusRuleSpec{{}, {Groups: groups}} {
+		var buf bytes.Buffer
+		size, err := WriteRuleSpec(&buf, spec, 0)
+		require.NoError(t, err)
+		require.Equal(t, int64(buf.Len()), size)
+
+		var spec2 monitoringv1.PrometheusRuleSpec
+		require.NoError(t, yaml.UnmarshalStrict(buf.Bytes(), &spec2))
+		require.Equal(t, spec, spec2)
+
+		rule := &monitoringv1.PrometheusRule{
+			TypeMeta: httpSLO.TypeMeta,
+			Spec:     spec,
+		}
+		rule.Name = "http"
+		rule.Labels = map[string]string{"team": "foo"}
+
+		buf.Reset()
+		size, err = WritePrometheusRule(&buf, rule)
+		require.NoError(t, err)
+		require.Equal(t, int64(buf.Len()), size)
+
+		var rule2 monitoringv1.PrometheusRule
+		require.NoError(t, yaml.UnmarshalStrict(buf.Bytes(), &rule2))
+		require.Equal(t, rule, &rule2)
+	}
+}
+
+func TestNewConfigMap_TooLarge(t *testing.T) {
+	rules := make([]monitoringv1.Rule, 0, 10000)
+	for i := 0; i < cap(rules); i++ {
+		rules = append(rules, monitoringv1.Rule{
+			Record: "http_requests:burnrate5m",
+			Expr:   intstr.FromString(strings.Repeat("x", 100)),
+		})
+	}
+
+	_, err := newConfigMap("http", httpSLO, []monitoringv1.RuleGroup{{Name: "http", Rules: rules}})
+	require.ErrorContains(t, err, "more than the 1048576 bytes a ConfigMap can hold")
+}
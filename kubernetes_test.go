@@ -2,6 +2,21 @@ package main
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -9,11 +24,17 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/types/known/durationpb"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/yaml"
 
 	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+	"github.com/pyrra-dev/pyrra/kubernetes/controllers"
 	objectivesv1alpha1 "github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1"
 )
 
@@ -126,3 +147,707 @@ func TestObjectiveServer_ListObjectives(t *testing.T) {
 		})
 	}
 }
+
+func TestObjectiveServer_ListObjectivesPages(t *testing.T) {
+	objective := func(namespace, name string, ls map[string]string) *pyrrav1alpha1.ServiceLevelObjective {
+		return &pyrrav1alpha1.ServiceLevelObjective{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: ls},
+			Spec:       pyrrav1alpha1.ServiceLevelObjectiveSpec{Target: "99", Window: "1w"},
+		}
+	}
+	ready := objective("monitoring", "api", map[string]string{"team": "platform"})
+	ready.Status = pyrrav1alpha1.ServiceLevelObjectiveStatus{
+		Type:     "PrometheusRule",
+		RuleName: "api",
+		Conditions: []metav1.Condition{{
+			Type:    pyrrav1alpha1.ConditionReady,
+			Status:  metav1.ConditionTrue,
+			Reason:  "RulesWritten",
+			Message: "Rules written to PrometheusRule api.",
+		}},
+	}
+
+	s := KubernetesObjectiveServer{client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		objective("monitoring", "web", map[string]string{"team": "frontend"}),
+		ready,
+		objective("default", "checkout", map[string]string{"team": "payments"}),
+		objective("default", "billing", map[string]string{"team": "payments"}),
+	).Build()}
+
+	list := func(req *objectivesv1alpha1.ListRequest) ([]string, string) {
+		response, err := s.List(context.Background(), connect.NewRequest(req))
+		require.NoError(t, err)
+		names := make([]string, 0, len(response.Msg.Objectives))
+		for _, o := range response.Msg.Objectives {
+			names = append(names, o.Labels["namespace"]+"/"+o.Labels[labels.MetricName])
+		}
+		return names, response.Msg.NextPageToken
+	}
+
+	names, token := list(&objectivesv1alpha1.ListRequest{PageSize: 3})
+	require.Equal(t, []string{"default/billing", "default/checkout", "monitoring/api"}, names)
+	require.Equal(t, "monitoring/api", token)
+	names, token = list(&objectivesv1alpha1.ListRequest{PageSize: 3, PageToken: token})
+	require.Equal(t, []string{"monitoring/web"}, names)
+	require.Empty(t, token)
+
+	names, token = list(&objectivesv1alpha1.ListRequest{LabelSelector: "team in (payments,platform)", PageSize: 2})
+	require.Equal(t, []string{"default/billing", "default/checkout"}, names)
+	require.Equal(t, "default/checkout", token)
+	names, token = list(&objectivesv1alpha1.ListRequest{LabelSelector: "team in (payments,platform)", PageSize: 2, PageToken: token})
+	require.Equal(t, []string{"monitoring/api"}, names)
+	require.Empty(t, token)
+
+	response, err := s.List(context.Background(), connect.NewRequest(&objectivesv1alpha1.ListRequest{Expr: `{__name__="api"}`}))
+	require.NoError(t, err)
+	require.Len(t, response.Msg.Objectives, 1)
+	require.Equal(t, &objectivesv1alpha1.Sync{
+		Ready:    true,
+		Reason:   "RulesWritten",
+		Message:  "Rules written to PrometheusRule api.",
+		RuleType: "PrometheusRule",
+		RuleName: "api",
+	}, response.Msg.Objectives[0].Sync)
+
+	_, err = s.List(context.Background(), connect.NewRequest(&objectivesv1alpha1.ListRequest{LabelSelector: "team in"}))
+	require.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	_, err = s.List(context.Background(), connect.NewRequest(&objectivesv1alpha1.ListRequest{PageToken: "api"}))
+	require.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+}
+
+func TestCacheConfig_Options(t *testing.T) {
+	opts, err := (&CacheConfig{SyncPeriod: time.Hour}).options()
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, *opts.SyncPeriod)
+	require.Nil(t, opts.ByObject)
+
+	opts, err = (&CacheConfig{
+		CacheLabelSelector: "team=platform",
+		CacheFieldSelector: "metadata.namespace!=kube-system",
+		SyncPeriod:         time.Hour,
+	}).options()
+	require.NoError(t, err)
+	require.Len(t, opts.ByObject, 2)
+	for _, by := range opts.ByObject {
+		require.Equal(t, "team=platform", by.Label.String())
+		require.Equal(t, "metadata.namespace!=kube-system", by.Field.String())
+	}
+
+	obj, err := opts.DefaultTransform(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:          "rules",
+		ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "pyrra"}},
+	}})
+	require.NoError(t, err)
+	require.Nil(t, obj.(*corev1.ConfigMap).ManagedFields)
+	require.Equal(t, "rules", obj.(*corev1.ConfigMap).Name)
+
+	require.ErrorContains(t, (&CacheConfig{CacheLabelSelector: "team in", SyncPeriod: time.Hour}).Validate(), "invalid --cache-label-selector")
+	require.ErrorContains(t, (&CacheConfig{CacheFieldSelector: "metadata.name", SyncPeriod: time.Hour}).Validate(), "invalid --cache-field-selector")
+	require.EqualError(t, (&CacheConfig{}).Validate(), "--sync-period must be greater than 0")
+}
+
+func TestCacheConfig_Scoping(t *testing.T) {
+	opts, err := (&CacheConfig{
+		SyncPeriod:       time.Hour,
+		Namespaces:       []string{"payments", "search"},
+		SLOLabelSelector: "team=payments",
+	}).options()
+	require.NoError(t, err)
+	require.Equal(t, map[string]cache.Config{"payments": {}, "search": {}}, opts.DefaultNamespaces)
+	require.Len(t, opts.ByObject, 1)
+	for obj, by := range opts.ByObject {
+		require.IsType(t, &pyrrav1alpha1.ServiceLevelObjective{}, obj)
+		require.Equal(t, "team=payments", by.Label.String())
+	}
+
+	opts, err = (&CacheConfig{
+		SyncPeriod:         time.Hour,
+		CacheLabelSelector: "team=payments",
+		SLOLabelSelector:   "team=payments",
+	}).options()
+	require.NoError(t, err)
+	require.Nil(t, opts.DefaultNamespaces)
+	require.Len(t, opts.ByObject, 3)
+
+	require.ErrorContains(t, (&CacheConfig{SLOLabelSelector: "team in", SyncPeriod: time.Hour}).Validate(), "invalid --slo-label-selector")
+}
+
+func TestReconcileConfig_Validate(t *testing.T) {
+	valid := ReconcileConfig{
+		ReconcileDebounce:  time.Second,
+		ResyncSpread:       time.Minute,
+		KubeAPIQPS:         20,
+		KubeAPIBurst:       30,
+		WorkqueueBaseDelay: 5 * time.Millisecond,
+		WorkqueueMaxDelay:  1000 * time.Second,
+		WorkqueueQPS:       10,
+		WorkqueueBurst:     100,
+
+		MaxConcurrentReconciles: 1,
+	}
+	require.NoError(t, valid.Validate())
+
+	rc := valid
+	rc.ReconcileDebounce, rc.ResyncSpread = 0, 0
+	require.NoError(t, rc.Validate())
+
+	rc = valid
+	rc.ReconcileDebounce = -time.Second
+	require.EqualError(t, rc.Validate(), "--reconcile-debounce must not be negative")
+
+	rc = valid
+	rc.ResyncSpread = -time.Second
+	require.EqualError(t, rc.Validate(), "--resync-spread must not be negative")
+
+	rc = valid
+	rc.ResyncInterval = -time.Minute
+	require.EqualError(t, rc.Validate(), "--resync-interval must not be negative")
+
+	rc = valid
+	rc.KubeAPIBurst = 0
+	require.EqualError(t, rc.Validate(), "--kube-api-qps and --kube-api-burst must be greater than 0")
+
+	rc = valid
+	rc.WorkqueueMaxDelay = time.Millisecond
+	require.EqualError(t, rc.Validate(), "--workqueue-base-delay must be greater than 0 and not greater than --workqueue-max-delay")
+
+	rc = valid
+	rc.WorkqueueQPS = 0
+	require.EqualError(t, rc.Validate(), "--workqueue-qps and --workqueue-burst must be greater than 0")
+
+	rc = valid
+	rc.MaxConcurrentReconciles = 0
+	require.EqualError(t, rc.Validate(), "--max-concurrent-reconciles must be greater than 0")
+}
+
+func TestLeaderElectionConfig_Validate(t *testing.T) {
+	require.NoError(t, (&LeaderElectionConfig{}).Validate())
+	require.NoError(t, (&LeaderElectionConfig{EnableLeaderElection: true, LeaderElectionName: "pyrra"}).Validate())
+	require.EqualError(t, (&LeaderElectionConfig{EnableLeaderElection: true}).Validate(), "--enable-leader-election requires --leader-election-name")
+}
+
+func TestReconcileConfig_RateLimiter(t *testing.T) {
+	limiter := ReconcileConfig{
+		WorkqueueBaseDelay: 10 * time.Millisecond,
+		WorkqueueMaxDelay:  30 * time.Millisecond,
+		WorkqueueQPS:       1000,
+		WorkqueueBurst:     1000,
+	}.rateLimiter()
+
+	require.Equal(t, 10*time.Millisecond, limiter.When("a"))
+	require.Equal(t, 20*time.Millisecond, limiter.When("a"))
+	require.Equal(t, 30*time.Millisecond, limiter.When("a"))
+	require.Equal(t, 30*time.Millisecond, limiter.When("a"))
+	require.Equal(t, 10*time.Millisecond, limiter.When("b"))
+
+	limiter.Forget("a")
+	require.Equal(t, 10*time.Millisecond, limiter.When("a"))
+}
+
+func TestGrafanaConfig_Validate(t *testing.T) {
+	require.NoError(t, (&GrafanaConfig{}).Validate())
+
+	gc := &GrafanaConfig{GrafanaAlertRules: true}
+	require.EqualError(t, gc.Validate(), "--grafana-alert-rules requires --grafana-datasource-uid and --grafana-folder")
+
+	gc.GrafanaDatasourceUID = "prometheus"
+	gc.GrafanaFolder = "slos"
+	require.EqualError(t, gc.Validate(), "--grafana-alert-rules requires --grafana-instance-selector")
+
+	gc.GrafanaInstanceSelector = map[string]string{"dashboards": "grafana"}
+	require.EqualError(t, gc.Validate(), "--grafana-datasource-type must not be empty")
+
+	gc.GrafanaDatasourceType = "prometheus"
+	require.NoError(t, gc.Validate())
+
+	gc = &GrafanaConfig{GrafanaDashboards: true}
+	require.EqualError(t, gc.Validate(), "--grafana-dashboards requires --grafana-datasource-uid and --grafana-folder")
+
+	gc = &GrafanaConfig{
+		GrafanaDashboards:       true,
+		GrafanaDatasourceUID:    "prometheus",
+		GrafanaFolder:           "slos-{{.Namespace}}",
+		GrafanaCreateFolders:    true,
+		GrafanaInstanceSelector: map[string]string{"dashboards": "grafana"},
+	}
+	require.NoError(t, gc.Validate())
+	folders, err := gc.folders()
+	require.NoError(t, err)
+	require.True(t, folders.Create)
+
+	gc.GrafanaFolder = "slos-{{.Team}}"
+	require.ErrorContains(t, gc.Validate(), "invalid --grafana-folder: failed to render grafana folder name")
+
+	gc.GrafanaFolder = "SLOs"
+	require.ErrorContains(t, gc.Validate(), `invalid --grafana-folder: invalid grafana folder name "SLOs"`)
+
+	// Without templates or folders to create, the existing folder is used.
+	folders, err = (GrafanaConfig{GrafanaFolder: "slos"}).folders()
+	require.NoError(t, err)
+	require.Nil(t, folders)
+}
+
+func TestThanosRulerConfig_Validate(t *testing.T) {
+	require.NoError(t, (&ThanosRulerConfig{}).Validate())
+
+	tc := &ThanosRulerConfig{ThanosRuler: true, ThanosRulerMaxConfigMapSize: 1 << 20}
+	require.EqualError(t, tc.Validate(), "--thanos-ruler requires --thanos-ruler-configmap-labels")
+
+	tc.ThanosRulerConfigMapLabels = map[string]string{"thanos_rule": "true"}
+	require.NoError(t, tc.Validate())
+
+	tc.ThanosRulerMaxConfigMapSize = 2 << 20
+	require.EqualError(t, tc.Validate(), "--thanos-ruler-max-configmap-size must be between 1 and 1048576 bytes, got 2097152")
+}
+
+func TestLokiRulerGroupConfig_Validate(t *testing.T) {
+	require.NoError(t, (&LokiRulerGroupConfig{}).Validate())
+	require.NoError(t, (&LokiRulerGroupConfig{LokiRulerNamespaceTemplate: "{{.Namespace}}/{{.Name}}"}).Validate())
+
+	lc := &LokiRulerGroupConfig{LokiRulerNamespaceTemplate: "{{.Namespace"}
+	require.ErrorContains(t, lc.Validate(), "failed to parse --loki-ruler-namespace-template")
+
+	lc = &LokiRulerGroupConfig{LokiRulerNamespaceTemplate: "{{.Team}}"}
+	require.ErrorContains(t, lc.Validate(), "failed to render --loki-ruler-namespace-template")
+
+	lc = &LokiRulerGroupConfig{LokiRulerNamespaceTemplate: "{{if false}}x{{end}}"}
+	require.EqualError(t, lc.Validate(), "--loki-ruler-namespace-template must not render an empty ruler namespace")
+
+	lc = &LokiRulerGroupConfig{LokiRulerBurnRateInterval: -time.Second}
+	require.EqualError(t, lc.Validate(), "the loki ruler rule group intervals must not be negative")
+
+	lc = &LokiRulerGroupConfig{LokiRulerSyncInterval: -time.Minute}
+	require.EqualError(t, lc.Validate(), "--loki-ruler-sync-interval must not be negative")
+}
+
+func TestOutputConfig_Validate(t *testing.T) {
+	require.NoError(t, (&OutputConfig{}).Validate())
+	require.NoError(t, (&OutputConfig{RuleOutputs: []string{"prometheusrule", "configmap"}}).Validate())
+
+	oc := &OutputConfig{RuleOutputs: []string{"mimir"}}
+	require.EqualError(t, oc.Validate(), `unknown --rule-outputs "mimir", must be any of prometheusrule, configmap and thanos-ruler`)
+
+	oc = &OutputConfig{RuleOutputs: []string{"configmap", "configmap"}}
+	require.EqualError(t, oc.Validate(), "--rule-outputs has configmap more than once")
+
+	require.NoError(t, (&OutputConfig{ExternalLabels: map[string]string{"cluster": "eu1", "region": "eu"}}).Validate())
+	oc = &OutputConfig{ExternalLabels: map[string]string{"severity": "critical"}}
+	require.EqualError(t, oc.Validate(), "--external-label must not set the severity label, which is set by Pyrra")
+
+	require.NoError(t, (&OutputConfig{ConfigMapShards: 16}).Validate())
+	require.EqualError(t, (&OutputConfig{ConfigMapShards: -1}).Validate(), "--config-map-shards must not be negative, got -1")
+}
+
+func TestOutputConfig_outputs(t *testing.T) {
+	require.Equal(t, []string{"prometheusrule"}, OutputConfig{}.outputs(false, false))
+	require.Equal(t, []string{"configmap"}, OutputConfig{}.outputs(true, false))
+	require.Equal(t, []string{"thanos-ruler"}, OutputConfig{}.outputs(true, true))
+	require.Equal(t, []string{"prometheusrule", "configmap"}, OutputConfig{RuleOutputs: []string{"prometheusrule", "configmap"}}.outputs(true, false))
+}
+
+func TestShadowConfig_Validate(t *testing.T) {
+	require.NoError(t, (&ShadowConfig{}).Validate())
+	require.NoError(t, (&ShadowConfig{ShadowSuffix: ":canary", ShadowDuration: 6 * time.Hour}).Validate())
+
+	sc := &ShadowConfig{ShadowSuffix: "-canary", ShadowDuration: 6 * time.Hour}
+	require.EqualError(t, sc.Validate(), `--shadow-suffix must only contain letters, digits, _ and :, got "-canary"`)
+
+	sc = &ShadowConfig{ShadowSuffix: ":canary"}
+	require.EqualError(t, sc.Validate(), "--shadow-duration must be positive")
+}
+
+func TestTemplateConfig_Validate(t *testing.T) {
+	require.NoError(t, (&TemplateConfig{}).Validate())
+	require.NoError(t, (&TemplateConfig{ObjectiveTemplates: true, ObjectiveTemplateInterval: 5 * time.Minute}).Validate())
+
+	tc := &TemplateConfig{ObjectiveTemplates: true}
+	require.EqualError(t, tc.Validate(), "--objective-template-interval must be greater than 0")
+}
+
+func TestAlertmanagerConfig_Validate(t *testing.T) {
+	require.NoError(t, (&AlertmanagerConfig{}).Validate())
+	require.NoError(t, (&AlertmanagerConfig{
+		AlertmanagerInhibitRules: true,
+		AlertmanagerConfigLabels: map[string]string{"alertmanager": "main"},
+	}).Validate())
+	require.EqualError(t,
+		(&AlertmanagerConfig{AlertmanagerConfigLabels: map[string]string{"alertmanager": "main"}}).Validate(),
+		"--alertmanager-config-labels requires --alertmanager-inhibit-rules",
+	)
+}
+
+func TestRemoteWriteConfig_Validate(t *testing.T) {
+	require.NoError(t, (&RemoteWriteConfig{}).Validate())
+
+	valid := func() *RemoteWriteConfig {
+		return &RemoteWriteConfig{
+			RemoteWriteURL:      &url.URL{Scheme: "http", Host: "mimir", Path: "/api/v1/push"},
+			RemoteWriteInterval: time.Minute,
+		}
+	}
+	require.NoError(t, valid().Validate())
+
+	rc := valid()
+	rc.RemoteWriteURL.Scheme = "ftp"
+	require.EqualError(t, rc.Validate(), "--remote-write-url must be an http or https URL")
+
+	rc = valid()
+	rc.RemoteWriteInterval = 0
+	require.EqualError(t, rc.Validate(), "--remote-write-interval must be greater than 0")
+
+	rc = valid()
+	rc.RemoteWriteUsername = "pyrra"
+	rc.RemoteWriteBearerToken = "token"
+	require.EqualError(t, rc.Validate(), "--remote-write-bearer-token and --remote-write-username are mutually exclusive")
+
+	rc = valid()
+	rc.RemoteWritePassword = "secret"
+	require.EqualError(t, rc.Validate(), "--remote-write-password requires --remote-write-username")
+}
+
+func TestSecretCache(t *testing.T) {
+	credentials := fields.OneTermEqualSelector("metadata.name", "loki-credentials")
+	config := types.NamespacedName{Namespace: "pyrra", Name: "loki-ruler"}
+
+	require.Equal(t, cache.ByObject{Namespaces: map[string]cache.Config{
+		cache.AllNamespaces: {FieldSelector: credentials},
+	}}, secretCache(nil, "loki-credentials", types.NamespacedName{}))
+
+	require.Equal(t, cache.ByObject{Namespaces: map[string]cache.Config{
+		"pyrra": {FieldSelector: fields.OneTermEqualSelector("metadata.name", "loki-ruler")},
+	}}, secretCache(nil, "", config))
+
+	// All Secrets of the config's namespace are cached, as it might have credentials too.
+	require.Equal(t, cache.ByObject{Namespaces: map[string]cache.Config{
+		cache.AllNamespaces: {FieldSelector: credentials},
+		"pyrra":             {},
+	}}, secretCache(nil, "loki-credentials", config))
+
+	// The config is cached even outside the namespaces of the objectives.
+	require.Equal(t, cache.ByObject{Namespaces: map[string]cache.Config{
+		"payments": {FieldSelector: credentials},
+		"pyrra":    {FieldSelector: fields.OneTermEqualSelector("metadata.name", "loki-ruler")},
+	}}, secretCache(map[string]cache.Config{"payments": {}}, "loki-credentials", config))
+
+	// All Secrets of a namespace with several Secrets are cached.
+	require.Equal(t, cache.ByObject{Namespaces: map[string]cache.Config{
+		"pyrra":      {},
+		"monitoring": {FieldSelector: fields.OneTermEqualSelector("metadata.name", "us1-kubeconfig")},
+	}}, secretCache(nil, "", config, types.NamespacedName{Namespace: "pyrra", Name: "eu1-kubeconfig"}, types.NamespacedName{Namespace: "monitoring", Name: "us1-kubeconfig"}))
+}
+
+func TestLokiRulerClientConfig(t *testing.T) {
+	require.NoError(t, (&LokiRulerClientConfig{}).Validate())
+	require.EqualError(t,
+		(&LokiRulerClientConfig{LokiRulerTLSCertFile: "tls.crt"}).Validate(),
+		"--loki-ruler-tls-cert-file and --loki-ruler-tls-key-file must be set together",
+	)
+	require.NoError(t, (&LokiRulerClientConfig{LokiRulerConfigSecret: "pyrra/loki-ruler"}).Validate())
+	for _, secret := range []string{"loki-ruler", "pyrra/", "/loki-ruler", "pyrra/loki/ruler"} {
+		require.EqualError(t,
+			(&LokiRulerClientConfig{LokiRulerConfigSecret: secret}).Validate(),
+			fmt.Sprintf("--loki-ruler-config-secret must be namespace/name, got %q", secret),
+		)
+	}
+
+	// The ruler requires a client certificate issued by the CA.
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+
+	var commonName string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commonName = r.TLS.PeerCertificates[0].Subject.CommonName
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	// Every request authenticates with a new connection.
+	server.Config.SetKeepAlivesEnabled(false)
+	server.StartTLS()
+	defer server.Close()
+
+	dir := t.TempDir()
+	config := LokiRulerClientConfig{
+		LokiRulerTLSCertFile: filepath.Join(dir, "tls.crt"),
+		LokiRulerTLSKeyFile:  filepath.Join(dir, "tls.key"),
+		LokiRulerTLSCAFile:   filepath.Join(dir, "ca.crt"),
+	}
+	require.NoError(t, os.WriteFile(config.LokiRulerTLSCAFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+	issue := func(name string, modTime time.Time) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: big.NewInt(modTime.Unix()),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}, ca, &key.PublicKey, caKey)
+		require.NoError(t, err)
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(config.LokiRulerTLSCertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+		require.NoError(t, os.WriteFile(config.LokiRulerTLSKeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+		require.NoError(t, os.Chtimes(config.LokiRulerTLSCertFile, modTime, modTime))
+		require.NoError(t, os.Chtimes(config.LokiRulerTLSKeyFile, modTime, modTime))
+	}
+	issue("pyrra", time.Now().Add(-time.Minute))
+
+	c, err := config.client()
+	require.NoError(t, err)
+	get := func() {
+		resp, err := c.Get(server.URL)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+	}
+	get()
+	require.Equal(t, "pyrra", commonName)
+
+	// Rotated certificates are used for new connections without a restart.
+	issue("rotated", time.Now())
+	get()
+	require.Equal(t, "rotated", commonName)
+}
+
+func TestDestinationConfig_Validate(t *testing.T) {
+	require.NoError(t, (&DestinationConfig{}).Validate())
+
+	dc := &DestinationConfig{
+		DestinationLabels:        map[string]string{"staging": "prometheus=staging,env=staging", "prod": "prometheus=prod"},
+		DestinationLokiRulerURLs: map[string]string{"staging": "http://loki-staging:3100"},
+	}
+	require.NoError(t, dc.Validate())
+
+	destinations, err := dc.destinations(nil, nil)
+	require.NoError(t, err)
+	require.Len(t, destinations, 2)
+	require.Equal(t, map[string]string{"prometheus": "staging", "env": "staging"}, destinations["staging"].Labels)
+	require.Equal(t, "http://loki-staging:3100", destinations["staging"].LokiRuler.URL.String())
+	require.Equal(t, map[string]string{"prometheus": "prod"}, destinations["prod"].Labels)
+	require.Nil(t, destinations["prod"].LokiRuler)
+
+	dc = &DestinationConfig{DestinationLabels: map[string]string{"staging": "prometheus"}}
+	require.ErrorContains(t, dc.Validate(), "invalid --destination-labels of staging")
+
+	dc = &DestinationConfig{DestinationLokiRulerURLs: map[string]string{"staging": "loki-staging"}}
+	require.EqualError(t, dc.Validate(), `invalid --destination-loki-ruler-urls of staging: "loki-staging" is not an absolute URL`)
+
+	dc = &DestinationConfig{DestinationLabels: map[string]string{"": "prometheus=staging"}}
+	require.EqualError(t, dc.Validate(), "--destination-labels must name each destination")
+}
+
+func TestPrometheusSelectorConfig_Validate(t *testing.T) {
+	require.NoError(t, (&PrometheusSelectorConfig{}).Validate())
+
+	pc := &PrometheusSelectorConfig{PrometheusResource: "monitoring/k8s", PrometheusSelectorCheckInterval: 5 * time.Minute}
+	require.NoError(t, pc.Validate())
+	require.Equal(t, types.NamespacedName{Namespace: "monitoring", Name: "k8s"}, pc.prometheus())
+
+	pc = &PrometheusSelectorConfig{PrometheusResource: "k8s", PrometheusSelectorCheckInterval: 5 * time.Minute}
+	require.EqualError(t, pc.Validate(), `--prometheus-resource must be namespace/name, got "k8s"`)
+
+	pc = &PrometheusSelectorConfig{PrometheusResource: "monitoring/k8s"}
+	require.EqualError(t, pc.Validate(), "--prometheus-selector-check-interval must be greater than 0")
+
+	pc = &PrometheusSelectorConfig{PrometheusRuleSelectorLabels: true}
+	require.EqualError(t, pc.Validate(), "--prometheus-rule-selector-labels requires --prometheus-resource")
+}
+
+func TestRuleMutatorConfig_Validate(t *testing.T) {
+	require.NoError(t, (&RuleMutatorConfig{}).Validate())
+
+	mc := &RuleMutatorConfig{RuleMutator: []string{"sh -c cat"}, RuleMutatorTimeout: 10 * time.Second}
+	require.NoError(t, mc.Validate())
+	mutators := mc.mutators()
+	require.Len(t, mutators, 1)
+	require.Equal(t, &controllers.ExecRuleMutator{Command: "sh", Args: []string{"-c", "cat"}, Timeout: 10 * time.Second}, mutators[0])
+
+	mc = &RuleMutatorConfig{RuleMutator: []string{" "}, RuleMutatorTimeout: 10 * time.Second}
+	require.EqualError(t, mc.Validate(), "--rule-mutator must not be empty")
+
+	mc = &RuleMutatorConfig{RuleMutator: []string{"/does/not/exist"}, RuleMutatorTimeout: 10 * time.Second}
+	require.ErrorContains(t, mc.Validate(), "invalid --rule-mutator")
+
+	mc = &RuleMutatorConfig{RuleMutator: []string{"sh"}}
+	require.EqualError(t, mc.Validate(), "--rule-mutator-timeout must be greater than 0")
+}
+
+func TestBackfillConfig_Validate(t *testing.T) {
+	require.NoError(t, (&BackfillConfig{}).Validate())
+	require.Nil(t, BackfillConfig{}.backfill())
+
+	prometheusURL, _ := url.Parse("http://prometheus:9090")
+	mimirURL, _ := url.Parse("http://mimir:8080")
+
+	bc := &BackfillConfig{BackfillPVC: "prometheus-db"}
+	require.EqualError(t, bc.Validate(), "--backfill-pvc and --backfill-mimir-url require --backfill-prometheus-url")
+
+	bc = &BackfillConfig{BackfillPrometheusURL: prometheusURL}
+	require.EqualError(t, bc.Validate(), "--backfill-prometheus-url requires exactly one of --backfill-pvc or --backfill-mimir-url")
+	bc = &BackfillConfig{BackfillPrometheusURL: prometheusURL, BackfillPVC: "prometheus-db", BackfillMimirURL: mimirURL}
+	require.EqualError(t, bc.Validate(), "--backfill-prometheus-url requires exactly one of --backfill-pvc or --backfill-mimir-url")
+
+	bc = &BackfillConfig{BackfillPrometheusURL: prometheusURL, BackfillMimirURL: mimirURL, BackfillPVCSubPath: "prometheus-db"}
+	require.EqualError(t, bc.Validate(), "--backfill-pvc-sub-path requires --backfill-pvc")
+
+	bc = &BackfillConfig{BackfillPrometheusURL: prometheusURL, BackfillPVC: "prometheus-db", BackfillMaxAge: -time.Hour}
+	require.EqualError(t, bc.Validate(), "--backfill-max-age must not be negative")
+
+	bc = &BackfillConfig{
+		BackfillPrometheusURL: prometheusURL,
+		BackfillImage:         "prometheus",
+		BackfillPVC:           "prometheus-db",
+		BackfillPVCSubPath:    "prometheus-db",
+		BackfillMaxAge:        time.Hour,
+	}
+	require.NoError(t, bc.Validate())
+	require.Equal(t, &controllers.Backfill{
+		PrometheusURL: "http://prometheus:9090",
+		Image:         "prometheus",
+		Volume:        corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "prometheus-db"}},
+		VolumeSubPath: "prometheus-db",
+		MaxAge:        time.Hour,
+	}, bc.backfill())
+
+	bc = &BackfillConfig{
+		BackfillPrometheusURL: prometheusURL,
+		BackfillImage:         "prometheus",
+		BackfillMimirURL:      mimirURL,
+		BackfillMimirTenant:   "anonymous",
+		BackfillMimirImage:    "mimirtool",
+	}
+	require.NoError(t, bc.Validate())
+	require.Equal(t, &controllers.Backfill{
+		PrometheusURL: "http://prometheus:9090",
+		Image:         "prometheus",
+		MimirURL:      "http://mimir:8080",
+		MimirTenant:   "anonymous",
+		MimirImage:    "mimirtool",
+	}, bc.backfill())
+}
+
+func TestPolicyConfig_Validate(t *testing.T) {
+	require.EqualError(t, (&PolicyConfig{}).Validate(), "--policy-interval must be greater than 0")
+	require.NoError(t, (&PolicyConfig{PolicyInterval: time.Minute}).Validate())
+	require.Nil(t, PolicyConfig{PolicyInterval: time.Minute}.silencer())
+
+	alertmanagerURL, _ := url.Parse("http://alertmanager:9093")
+	pc := &PolicyConfig{PolicyInterval: time.Minute, AlertmanagerURL: alertmanagerURL, AlertmanagerSilenceDuration: time.Minute}
+	require.EqualError(t, pc.Validate(), "--alertmanager-silence-duration must be longer than --policy-interval")
+
+	pc.AlertmanagerSilenceDuration = 15 * time.Minute
+	require.NoError(t, pc.Validate())
+	silencer := pc.silencer()
+	require.Equal(t, alertmanagerURL, silencer.URL)
+	require.Equal(t, 15*time.Minute, silencer.Duration)
+}
+
+func TestAlertAnnotationConfig_Validate(t *testing.T) {
+	require.NoError(t, (&AlertAnnotationConfig{}).Validate())
+	annotations, err := AlertAnnotationConfig{}.annotations()
+	require.NoError(t, err)
+	require.Nil(t, annotations)
+
+	file := filepath.Join(t.TempDir(), "annotations.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+runbook_url: https://runbooks.example.com/{{.Name}}
+dashboard: https://grafana.example.com/d/{{.UID}}
+`), 0o644))
+
+	ac := &AlertAnnotationConfig{
+		AlertAnnotationsFile: file,
+		AlertAnnotation:      map[string]string{"runbook_url": "https://runbooks.example.com/{{.Namespace}}/{{.Name}}"},
+	}
+	require.NoError(t, ac.Validate())
+	annotations, err = ac.annotations()
+	require.NoError(t, err)
+	require.Len(t, annotations, 2)
+
+	var sb strings.Builder
+	require.NoError(t, annotations["runbook_url"].Execute(&sb, controllers.AlertAnnotationData{Namespace: "monitoring", Name: "http"}))
+	require.Equal(t, "https://runbooks.example.com/monitoring/http", sb.String())
+
+	ac = &AlertAnnotationConfig{AlertAnnotation: map[string]string{"runbook-url": "https://runbooks.example.com"}}
+	require.EqualError(t, ac.Validate(), `alert annotation "runbook-url" is not a valid annotation name`)
+	ac = &AlertAnnotationConfig{AlertAnnotation: map[string]string{"runbook_url": "https://runbooks.example.com/{{.Name"}}
+	require.ErrorContains(t, ac.Validate(), "failed to parse alert annotation runbook_url")
+	ac = &AlertAnnotationConfig{AlertAnnotationsFile: filepath.Join(t.TempDir(), "missing.yaml")}
+	require.ErrorContains(t, ac.Validate(), "failed to read --alert-annotations-file")
+}
+
+func TestCardinalityConfig_Validate(t *testing.T) {
+	require.NoError(t, (&CardinalityConfig{}).Validate())
+	require.Nil(t, CardinalityConfig{}.cardinality(nil))
+
+	require.EqualError(t, (&CardinalityConfig{GroupingMaxSeries: -1}).Validate(), "--grouping-max-series must not be negative, got -1")
+	require.EqualError(t, (&CardinalityConfig{GroupingEnforceSeries: true}).Validate(), "--grouping-enforce-series requires --grouping-max-series")
+
+	cc := &CardinalityConfig{GroupingMaxSeries: 1000, GroupingEnforceSeries: true}
+	require.NoError(t, cc.Validate())
+	require.Equal(t, &controllers.Cardinality{MaxSeries: 1000, Enforce: true}, cc.cardinality(nil))
+}
+
+func TestRemoteClusterConfig_Validate(t *testing.T) {
+	require.NoError(t, (&RemoteClusterConfig{}).Validate())
+	require.Nil(t, RemoteClusterConfig{}.remoteClusters())
+
+	rc := &RemoteClusterConfig{RemoteCluster: map[string]string{"eu1": "pyrra/eu1-kubeconfig", "us1": "monitoring/us1-kubeconfig"}}
+	require.NoError(t, rc.Validate())
+	require.Equal(t, controllers.RemoteClusters{
+		"eu1": {Secret: types.NamespacedName{Namespace: "pyrra", Name: "eu1-kubeconfig"}},
+		"us1": {Secret: types.NamespacedName{Namespace: "monitoring", Name: "us1-kubeconfig"}},
+	}, rc.remoteClusters())
+	require.Equal(t, []types.NamespacedName{
+		{Namespace: "monitoring", Name: "us1-kubeconfig"},
+		{Namespace: "pyrra", Name: "eu1-kubeconfig"},
+	}, rc.secrets())
+
+	require.ErrorContains(t,
+		(&RemoteClusterConfig{RemoteCluster: map[string]string{"EU_1": "pyrra/eu1-kubeconfig"}}).Validate(),
+		`invalid --remote-cluster name "EU_1": a lowercase RFC 1123 label must consist of lower case alphanumeric characters`,
+	)
+	for _, secret := range []string{"eu1-kubeconfig", "pyrra/", "/eu1-kubeconfig", "pyrra/eu1/kubeconfig"} {
+		require.EqualError(t,
+			(&RemoteClusterConfig{RemoteCluster: map[string]string{"eu1": secret}}).Validate(),
+			fmt.Sprintf("--remote-cluster eu1 must be a Secret's namespace/name, got %q", secret),
+		)
+	}
+}
+
+func TestHealthConfig_Validate(t *testing.T) {
+	require.NoError(t, (&HealthConfig{HealthProbeInterval: 30 * time.Second, HealthProbeTimeout: 10 * time.Second}).Validate())
+	require.NoError(t, (&HealthConfig{HealthProbeInterval: 30 * time.Second, HealthProbeTimeout: 10 * time.Second, HealthProbeFailureThreshold: 3}).Validate())
+	require.NoError(t, (&HealthConfig{}).Validate())
+
+	for _, tc := range []struct {
+		config HealthConfig
+		err    string
+	}{{
+		config: HealthConfig{HealthProbeInterval: -time.Second},
+		err:    "--health-probe-interval must not be negative",
+	}, {
+		config: HealthConfig{HealthProbeInterval: 30 * time.Second, HealthProbeTimeout: 10 * time.Second, HealthProbeFailureThreshold: -1},
+		err:    "--health-probe-failure-threshold must not be negative",
+	}, {
+		config: HealthConfig{HealthProbeFailureThreshold: 3},
+		err:    "--health-probe-failure-threshold requires --health-probe-interval",
+	}, {
+		config: HealthConfig{HealthProbeInterval: 30 * time.Second},
+		err:    "--health-probe-timeout must be greater than 0",
+	}} {
+		require.EqualError(t, tc.config.Validate(), tc.err)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"sigs.k8s.io/yaml"
+
+	"github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+	"github.com/pyrra-dev/pyrra/kubernetes/controllers"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+const (
+	lintError   = "error"
+	lintWarning = "warning"
+)
+
+// lintFinding is a problem found in an objective file.
+// Findings are printed as JSON for CI pipelines to process them.
+type lintFinding struct {
+	File      string `json:"file"`
+	Objective string `json:"objective,omitempty"`
+	// Check is the check that found the problem: parse, validate, duplicate, target, window or metrics.
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+type lintResult struct {
+	Files    int           `json:"files"`
+	Errors   int           `json:"errors"`
+	Warnings int           `json:"warnings"`
+	Findings []lintFinding `json:"findings"`
+}
+
+// cmdLint lints the objective files and the ones in the directories, and prints the findings as JSON.
+// The metrics of the objectives are verified to select series if there is a querier.
+// It fails if there are findings with the error severity.
+func cmdLint(logger log.Logger, out io.Writer, paths []string, querier controllers.BudgetPolicyQuerier) int {
+	files, err := lintFiles(paths)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to find objective files", "err", err)
+		return 1
+	}
+
+	result := lintObjectiveFiles(context.Background(), files, querier)
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		level.Error(logger).Log("msg", "failed to write findings", "err", err)
+		return 1
+	}
+
+	if result.Errors > 0 {
+		return 1
+	}
+	return 0
+}
+
+// lintFiles returns the files and the YAML files within the directories, sorted.
+func lintFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if ext := filepath.Ext(file); !d.IsDir() && (ext == ".yaml" || ext == ".yml") {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func lintObjectiveFiles(ctx context.Context, files []string, querier controllers.BudgetPolicyQuerier) lintResult {
+	result := lintResult{Files: len(files), Findings: []lintFinding{}}
+	add := func(f lintFinding) {
+		if f.Severity == lintError {
+			result.Errors++
+		} else {
+			result.Warnings++
+		}
+		result.Findings = append(result.Findings, f)
+	}
+
+	// The files defining each objective, to find duplicates.
+	defined := map[string]string{}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			add(lintFinding{File: file, Check: "parse", Severity: lintError, Message: err.Error()})
+			continue
+		}
+		var kubeObjective v1alpha1.ServiceLevelObjective
+		if err := yaml.UnmarshalStrict(content, &kubeObjective); err != nil {
+			add(lintFinding{File: file, Check: "parse", Severity: lintError, Message: fmt.Sprintf("failed to unmarshal objective: %s", err)})
+			continue
+		}
+
+		name := kubeObjective.GetName()
+		if ns := kubeObjective.GetNamespace(); ns != "" {
+			name = ns + "/" + name
+		}
+		finding := func(check, severity, message string) {
+			add(lintFinding{File: file, Objective: name, Check: check, Severity: severity, Message: message})
+		}
+
+		if other, ok := defined[name]; ok {
+			finding("duplicate", lintError, fmt.Sprintf("objective is already defined in %s", other))
+		} else {
+			defined[name] = file
+		}
+
+		warnings, err := kubeObjective.ValidateCreate()
+		for _, w := range warnings {
+			finding("validate", lintWarning, w)
+		}
+		if err != nil {
+			finding("validate", lintError, err.Error())
+			continue
+		}
+		objective, err := kubeObjective.Internal()
+		if err != nil {
+			finding("validate", lintError, err.Error())
+			continue
+		}
+
+		for _, f := range lintObjective(objective) {
+			finding(f.Check, lintWarning, f.Message)
+		}
+
+		if querier == nil || controllers.IsLokiObjective(kubeObjective.GetAnnotations()) {
+			// The metrics of objectives evaluated by Loki are LogQL queries Prometheus doesn't know.
+			continue
+		}
+		problems, err := controllers.VerifyMetrics(ctx, querier, objective)
+		if err != nil {
+			finding("metrics", lintError, fmt.Sprintf("failed to verify metrics: %s", err))
+			continue
+		}
+		for _, p := range problems {
+			finding("metrics", lintError, p)
+		}
+	}
+
+	return result
+}
+
+// lintObjective returns findings for targets and windows that are valid but likely mistakes,
+// as they leave too little error budget or make the burn rate alerts useless.
+func lintObjective(objective slo.Objective) []lintFinding {
+	var findings []lintFinding
+	window := time.Duration(objective.Window)
+
+	if objective.Target >= 1 {
+		findings = append(findings, lintFinding{
+			Check:   "target",
+			Message: "target of 100% leaves no error budget, every error violates the objective",
+		})
+	} else if budget := time.Duration((1 - objective.Target) * float64(window)); budget < time.Minute {
+		findings = append(findings, lintFinding{
+			Check:   "target",
+			Message: fmt.Sprintf("target leaves an error budget of %s downtime within the window, less than a minute", budget.Round(time.Second)),
+		})
+	}
+
+	if short := slo.Windows(window)[0].Short; short < time.Minute {
+		findings = append(findings, lintFinding{
+			Check:   "window",
+			Message: fmt.Sprintf("window is too short for the burn rate alerts, their shortest window is %s", short),
+		})
+	}
+
+	return findings
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCmdLint(t *testing.T) {
+	dir := t.TempDir()
+	write := func(file, content string) string {
+		path := filepath.Join(dir, file)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+	valid := write("api.yaml", strings.Replace(ciObjective, "%s", "99", 1))
+
+	var out bytes.Buffer
+	require.Equal(t, 0, cmdLint(log.NewNopLogger(), &out, []string{dir}, nil))
+	require.JSONEq(t, `{"files": 1, "errors": 0, "warnings": 0, "findings": []}`, out.String())
+
+	duplicate := write("team/api.yaml", strings.Replace(ciObjective, "%s", "99.5", 1))
+	strict := write("team/strict.yml", strings.NewReplacer("%s", "99.9999", "api-errors", "strict").Replace(ciObjective))
+	invalid := write("team/invalid.yaml", strings.NewReplacer("%s", "99", "name: api-errors", "name: invalid", `code=~"5.."`, `code=~"5..`).Replace(ciObjective))
+	broken := write("team/broken.yaml", "spec: [")
+	// Other files in the directories aren't objectives.
+	write("team/README.md", "# Objectives")
+
+	out.Reset()
+	require.Equal(t, 1, cmdLint(log.NewNopLogger(), &out, []string{dir}, nil))
+
+	var result lintResult
+	require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+	require.Equal(t, 5, result.Files)
+	require.Equal(t, 3, result.Errors)
+	require.Equal(t, 1, result.Warnings)
+	require.Equal(t, []lintFinding{{
+		File:      duplicate,
+		Objective: "monitoring/api-errors",
+		Check:     "duplicate",
+		Severity:  "error",
+		Message:   "objective is already defined in " + valid,
+	}, {
+		File:     broken,
+		Check:    "parse",
+		Severity: "error",
+		Message:  "failed to unmarshal objective: error converting YAML to JSON: yaml: line 1: did not find expected node content",
+	}, {
+		File:      invalid,
+		Objective: "monitoring/invalid",
+		Check:     "validate",
+		Severity:  "error",
+		Message:   `failed to parse ratio error metric: 1:37: parse error: unterminated quoted string`,
+	}, {
+		File:      strict,
+		Objective: "monitoring/strict",
+		Check:     "target",
+		Severity:  "warning",
+		Message:   "target leaves an error budget of 2s downtime within the window, less than a minute",
+	}}, result.Findings)
+
+	// The metrics are verified with a querier.
+	out.Reset()
+	require.Equal(t, 1, cmdLint(log.NewNopLogger(), &out, []string{valid}, seriesQuerier{}))
+	require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+	require.Equal(t, 1, result.Files)
+	require.NotEmpty(t, result.Findings)
+	for _, f := range result.Findings {
+		require.Equal(t, "metrics", f.Check)
+		require.Equal(t, "error", f.Severity)
+	}
+
+	out.Reset()
+	require.Equal(t, 1, cmdLint(log.NewNopLogger(), &out, []string{filepath.Join(dir, "missing.yaml")}, nil))
+	require.Empty(t, out.String())
+}
+
+func TestLintObjective(t *testing.T) {
+	_, objective, err := objectiveFromBytes("api.yaml", []byte(strings.Replace(ciObjective, "%s", "99", 1)))
+	require.NoError(t, err)
+	require.Empty(t, lintObjective(objective))
+
+	objective.Target = 1
+	require.Equal(t, []lintFinding{{
+		Check:   "target",
+		Message: "target of 100% leaves no error budget, every error violates the objective",
+	}}, lintObjective(objective))
+
+	_, objective, err = objectiveFromBytes("api.yaml", []byte(strings.NewReplacer("%s", "99", "window: 4w", "window: 1d").Replace(ciObjective)))
+	require.NoError(t, err)
+	require.Equal(t, []lintFinding{{
+		Check:   "window",
+		Message: "window is too short for the burn rate alerts, their shortest window is 0s",
+	}}, lintObjective(objective))
+}
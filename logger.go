@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var errInvalidLogFormatFlag = errors.New("--log-format must be either 'json' or 'logfmt'")
@@ -14,6 +19,8 @@ var errInvalidLogFormatFlag = errors.New("--log-format must be either 'json' or
 type LoggerConfig struct {
 	LogLevel  string `default:"info" help:"Used to set the logging level of the application. Valid options are 'debug', 'info', 'warn' or 'error'"`
 	LogFormat string `default:"logfmt" help:"Used to set the logging format. Valid options are 'logfmt' or 'json'"`
+
+	LogComponentLevels map[string]string `help:"Overrides the logging level per component, like reconciler=debug;api=warn. Components are 'api', 'reconciler', 'controller-runtime' and those of the integrations like 'jira' or 'notifications'."`
 }
 
 // Validate is a method called automatically by the kong cli framework so this deals with validating our LoggerConfig struct.
@@ -25,21 +32,145 @@ func (lc *LoggerConfig) Validate() error {
 	if lc.LogFormat != "json" && lc.LogFormat != "logfmt" {
 		return errInvalidLogFormatFlag
 	}
+
+	for component, l := range lc.LogComponentLevels {
+		if _, err := level.Parse(l); err != nil {
+			return fmt.Errorf("%w: --log-component-levels for %s must be 'debug', 'info', 'warn' or 'error'", err, component)
+		}
+	}
 	return nil
 }
 
 // configureLogger returns a go-lit logger which is customizable via the loggerConfig struct.
 func configureLogger(loggerConfig LoggerConfig) log.Logger {
+	return newLogger(os.Stderr, loggerConfig)
+}
+
+func newLogger(w io.Writer, loggerConfig LoggerConfig) log.Logger {
 	var logger log.Logger
 	switch loggerConfig.LogFormat {
 	case "logfmt":
-		logger = log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+		logger = log.NewLogfmtLogger(log.NewSyncWriter(w))
 	case "json":
-		logger = log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+		logger = log.NewJSONLogger(log.NewSyncWriter(w))
 	}
 
-	logger = level.NewFilter(logger, level.Allow(level.ParseDefault(loggerConfig.LogLevel, level.InfoValue())))
+	filter := componentLevelFilter{
+		next:         logger,
+		defaultLevel: level.ParseDefault(loggerConfig.LogLevel, level.InfoValue()),
+		components:   make(map[string]level.Value, len(loggerConfig.LogComponentLevels)),
+	}
+	for component, l := range loggerConfig.LogComponentLevels {
+		filter.components[component] = level.ParseDefault(l, filter.defaultLevel)
+	}
+
+	logger = filter
 	logger = log.WithPrefix(logger, "caller", log.DefaultCaller)
 	logger = log.WithPrefix(logger, "ts", log.DefaultTimestampUTC)
 	return logger
 }
+
+// componentLevelFilter drops log lines below the level of their component,
+// which is the default level unless it's overridden for the component.
+type componentLevelFilter struct {
+	next         log.Logger
+	defaultLevel level.Value
+	components   map[string]level.Value
+}
+
+func (f componentLevelFilter) Log(keyvals ...interface{}) error {
+	allowed := f.defaultLevel
+	var lvl level.Value
+	for i := 1; i < len(keyvals); i += 2 {
+		switch keyvals[i-1] {
+		case "component":
+			if component, ok := keyvals[i].(string); ok {
+				if l, ok := f.components[component]; ok {
+					allowed = l
+				}
+			}
+		case level.Key():
+			lvl, _ = keyvals[i].(level.Value)
+		}
+	}
+	if lvl != nil && levelRank(lvl) < levelRank(allowed) {
+		return nil
+	}
+	return f.next.Log(keyvals...)
+}
+
+func levelRank(v level.Value) int {
+	switch v.String() {
+	case "debug":
+		return 0
+	case "info":
+		return 1
+	case "warn":
+		return 2
+	default:
+		return 3
+	}
+}
+
+// contextLogger returns the logger with the ID of the request and trace of the context,
+// to find all log lines of a request and correlate them with traces.
+func contextLogger(ctx context.Context, logger log.Logger) log.Logger {
+	if id := middleware.GetReqID(ctx); id != "" {
+		logger = log.With(logger, "request_id", id)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		logger = log.With(logger, "trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+	}
+	return logger
+}
+
+// controllerRuntimeLogger returns the logr logger controller-runtime logs with, writing to the go-kit logger.
+// This file is the only one importing logr, which is denied elsewhere by depguard.
+func controllerRuntimeLogger(logger log.Logger) logr.Logger {
+	return logr.New(&kitLogSink{logger: logger})
+}
+
+// kitLogSink makes controller-runtime log with the go-kit logger, with the same format and levels as Pyrra.
+// Its verbosity levels above 0 are debug logs.
+type kitLogSink struct {
+	logger log.Logger
+	name   string
+}
+
+var _ logr.LogSink = &kitLogSink{}
+
+func (s *kitLogSink) Init(logr.RuntimeInfo) {}
+
+func (s *kitLogSink) Enabled(int) bool { return true }
+
+func (s *kitLogSink) Info(l int, msg string, keysAndValues ...interface{}) {
+	logger := level.Info(s.logger)
+	if l > 0 {
+		logger = level.Debug(s.logger)
+	}
+	_ = logger.Log(s.keyvals(msg, keysAndValues)...)
+}
+
+func (s *kitLogSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	_ = level.Error(s.logger).Log(s.keyvals(msg, append([]interface{}{"err", err}, keysAndValues...))...)
+}
+
+func (s *kitLogSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &kitLogSink{logger: log.With(s.logger, keysAndValues...), name: s.name}
+}
+
+func (s *kitLogSink) WithName(name string) logr.LogSink {
+	if s.name != "" {
+		name = s.name + "." + name
+	}
+	return &kitLogSink{logger: s.logger, name: name}
+}
+
+func (s *kitLogSink) keyvals(msg string, keysAndValues []interface{}) []interface{} {
+	keyvals := make([]interface{}, 0, 4+len(keysAndValues))
+	if s.name != "" {
+		keyvals = append(keyvals, "logger", s.name)
+	}
+	keyvals = append(keyvals, "msg", msg)
+	return append(keyvals, keysAndValues...)
+}
@@ -1,16 +1,25 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 func TestLoggerConfig_Validate(t *testing.T) {
 	type fields struct {
-		LogLevel  string
-		LogFormat string
+		LogLevel           string
+		LogFormat          string
+		LogComponentLevels map[string]string
 	}
 	tests := []struct {
 		name     string
@@ -37,6 +46,17 @@ func TestLoggerConfig_Validate(t *testing.T) {
 				require.ErrorIs(t, err, errInvalidLogFormatFlag, "log format flag should be invalid")
 			},
 		},
+		{
+			name: "bad component log level",
+			fields: fields{
+				LogLevel:           "info",
+				LogFormat:          "logfmt",
+				LogComponentLevels: map[string]string{"api": "verbose"},
+			},
+			wantFunc: func(t *testing.T, err error) {
+				require.ErrorIs(t, err, level.ErrInvalidLevelString, "component log level should be invalid")
+			},
+		},
 		{
 			name: "good config",
 			fields: fields{
@@ -53,11 +73,80 @@ func TestLoggerConfig_Validate(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 			lc := &LoggerConfig{
-				LogLevel:  tt.fields.LogLevel,
-				LogFormat: tt.fields.LogFormat,
+				LogLevel:           tt.fields.LogLevel,
+				LogFormat:          tt.fields.LogFormat,
+				LogComponentLevels: tt.fields.LogComponentLevels,
 			}
 			err := lc.Validate()
 			tt.wantFunc(t, err)
 		})
 	}
 }
+
+func TestComponentLevels(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(&buf, LoggerConfig{
+		LogLevel:           "info",
+		LogFormat:          "logfmt",
+		LogComponentLevels: map[string]string{"reconciler": "debug", "api": "error"},
+	})
+
+	reconciler := log.WithPrefix(logger, "component", "reconciler")
+	api := log.WithPrefix(logger, "component", "api")
+	jira := log.WithPrefix(logger, "component", "jira")
+
+	level.Debug(reconciler).Log("msg", "reconciler debug")
+	level.Warn(api).Log("msg", "api warn")
+	level.Error(api).Log("msg", "api error")
+	level.Debug(jira).Log("msg", "jira debug")
+	level.Info(jira).Log("msg", "jira info")
+	level.Debug(logger).Log("msg", "default debug")
+	logger.Log("msg", "without level")
+
+	out := buf.String()
+	require.Contains(t, out, `msg="reconciler debug"`)
+	require.NotContains(t, out, `msg="api warn"`)
+	require.Contains(t, out, `msg="api error"`)
+	require.NotContains(t, out, `msg="jira debug"`)
+	require.Contains(t, out, `msg="jira info"`)
+	require.NotContains(t, out, `msg="default debug"`)
+	require.Contains(t, out, `msg="without level"`)
+}
+
+func TestContextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.NewLogfmtLogger(&buf)
+
+	contextLogger(context.Background(), logger).Log("msg", "plain")
+	require.Equal(t, "msg=plain\n", buf.String())
+	buf.Reset()
+
+	tp := sdktrace.NewTracerProvider()
+	ctx, span := tp.Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+
+	handler := middleware.RequestID(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		contextLogger(r.Context(), logger).Log("msg", "request")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	req.Header.Set(middleware.RequestIDHeader, "abc")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.Equal(t, "request_id=abc trace_id="+span.SpanContext().TraceID().String()+" span_id="+span.SpanContext().SpanID().String()+" msg=request\n", buf.String())
+}
+
+func TestKitLogSink(t *testing.T) {
+	var buf bytes.Buffer
+	logger := controllerRuntimeLogger(log.NewLogfmtLogger(&buf))
+
+	logger.WithName("controller").WithName("slo").WithValues("namespace", "monitoring").Info("starting", "workers", 1)
+	require.Equal(t, "level=info namespace=monitoring logger=controller.slo msg=starting workers=1\n", buf.String())
+	buf.Reset()
+
+	logger.V(1).Info("details")
+	require.Equal(t, "level=debug msg=details\n", buf.String())
+	buf.Reset()
+
+	logger.Error(errors.New("failed"), "reconciling")
+	require.Equal(t, "level=error msg=reconciling err=failed\n", buf.String())
+}
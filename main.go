@@ -23,6 +23,7 @@ import (
 	"github.com/bufbuild/connect-go"
 	"github.com/dgraph-io/ristretto"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -37,9 +38,16 @@ import (
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/promql/parser"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 	"google.golang.org/protobuf/types/known/durationpb"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	objectivesv1alpha1 "github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1"
 	"github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1/objectivesv1alpha1connect"
@@ -50,8 +58,14 @@ import (
 //go:embed ui/build
 var ui embed.FS
 
+// version is set when building releases, see .goreleaser.yml.
+var version = "dev"
+
 var CLI struct {
 	LoggerConfig
+	OTLPConfig
+	TracingConfig
+	DebugConfig
 	API struct {
 		PrometheusURL               *url.URL          `default:"http://localhost:9090" help:"The URL to the Prometheus to query."`
 		PrometheusExternalURL       *url.URL          `help:"The URL for the UI to redirect users to when opening Prometheus. If empty the same as prometheus.url"`
@@ -64,27 +78,67 @@ var CLI struct {
 		TLSCertFile                 string            `default:"" help:"File containing the default x509 Certificate for HTTPS."`
 		TLSPrivateKeyFile           string            `default:"" help:"File containing the default x509 private key matching --tls-cert-file."`
 		TLSClientCAFile             string            `default:"" help:"File containing the CA certificate for the client"`
+		NotificationConfig
+		ReportConfig
+		JiraConfig
+		DatadogConfig
+		StatuspageConfig
+		CloudEventsConfig
+		AlertmanagerWebhookConfig
+		StatusCacheConfig
 	} `cmd:"" help:"Runs Pyrra's API and UI."`
 	Filesystem struct {
 		ConfigFiles      string   `default:"/etc/pyrra/*.yaml" help:"The folder where Pyrra finds the config files to use. Any non yaml files will be ignored."`
 		PrometheusURL    *url.URL `default:"http://localhost:9090" help:"The URL to the Prometheus to query."`
 		PrometheusFolder string   `default:"/etc/prometheus/pyrra/" help:"The folder where Pyrra writes the generates Prometheus rules and alerts."`
 		GenericRules     bool     `default:"false" help:"Enabled generic recording rules generation to make it easier for tools like Grafana."`
+		Workers          int      `default:"0" help:"The number of config files processed in parallel. Defaults to the number of CPUs."`
 	} `cmd:"" help:"Runs Pyrra's filesystem operator and backend for the API."`
-	Kubernetes struct {
-		MetricsAddr       string `default:":8080" help:"The address the metric endpoint binds to."`
-		ConfigMapMode     bool   `default:"false" help:"If the generated recording rules should instead be saved to config maps in the default Prometheus format."`
-		GenericRules      bool   `default:"false" help:"Enabled generic recording rules generation to make it easier for tools like Grafana."`
-		DisableWebhooks   bool   `default:"true" env:"DISABLE_WEBHOOKS" help:"Disable webhooks so the controller doesn't try to read certificates"`
-		TLSCertFile       string `default:"" help:"File containing the default x509 Certificate for HTTPS."`
-		TLSPrivateKeyFile string `default:"" help:"File containing the default x509 private key matching --tls-cert-file."`
-	} `cmd:"" help:"Runs Pyrra's Kubernetes operator and backend for the API."`
-	Generate struct {
+	Kubernetes KubernetesConfig `cmd:"" help:"Runs Pyrra's Kubernetes operator and backend for the API."`
+	Generate   struct {
 		ConfigFiles      string `default:"/etc/pyrra/*.yaml" help:"The folder where Pyrra finds the config files to use."`
 		PrometheusFolder string `default:"/etc/prometheus/pyrra/" help:"The folder where Pyrra writes the generated Prometheus rules and alerts."`
 		GenericRules     bool   `default:"false" help:"Enabled generic recording rules generation to make it easier for tools like Grafana."`
-		OperatorRule     bool   `default:"false" help:"Generate rule files as prometheus-operator PrometheusRule: https://prometheus-operator.dev/docs/operator/api/#monitoring.coreos.com/v1.PrometheusRule."`
+		Workers          int    `default:"0" help:"The number of config files processed in parallel. Defaults to the number of CPUs."`
+		GenerateConfig
 	} `cmd:"" help:"Read SLO config files and rewrites them as Prometheus rules and alerts."`
+	Import struct {
+		Nobl9 struct {
+			Files     []string `arg:"" type:"existingfile" help:"Nobl9 SLO YAML exports to convert."`
+			Namespace string   `default:"" help:"The namespace to set on the converted objectives."`
+		} `cmd:"" name:"nobl9" help:"Converts Nobl9 SLOs with Prometheus count metrics to Pyrra objectives and prints them."`
+	} `cmd:"" help:"Imports SLOs from other tools as Pyrra objectives."`
+	CI struct {
+		Comment struct {
+			Files            []string `arg:"" optional:"" help:"The changed objective files to check."`
+			Base             string   `default:"" help:"Git ref to diff the generated rules against, like origin/main."`
+			PrometheusURL    *url.URL `help:"The URL to the Prometheus to backtest the objectives against. Backtests are skipped if empty."`
+			GitHubToken      string   `name:"github-token" env:"GITHUB_TOKEN" help:"Token to set the commit status on GitHub with. The commit status is only set if the token, repository and sha are set."`
+			GitHubRepository string   `name:"github-repository" env:"GITHUB_REPOSITORY" help:"The GitHub repository, like pyrra-dev/pyrra, to set the commit status in."`
+			GitHubSHA        string   `name:"github-sha" env:"GITHUB_SHA" help:"The commit to set the status of."`
+			GitHubAPIURL     string   `name:"github-api-url" env:"GITHUB_API_URL" default:"https://api.github.com" help:"The URL of the GitHub API."`
+		} `cmd:"" help:"Validates changed objectives, diffs their rules and backtests them, and prints a Markdown summary to comment on pull requests."`
+	} `cmd:"" name:"ci" help:"Commands for checking objectives in CI."`
+	Diff struct {
+		Files        []string `arg:"" optional:"" type:"existingfile" help:"The objective files to diff. All objectives in the cluster are diffed if there are none."`
+		Namespace    string   `default:"" help:"The namespace of the objectives in the cluster to diff, all if empty. Objective files without a namespace are in this namespace, or default."`
+		GenericRules bool     `default:"false" help:"Generate the generic recording rules, like the operator with --generic-rules."`
+	} `cmd:"" help:"Prints the diff of the PrometheusRules generated for objectives to the ones in the cluster, exiting with 1 if there are differences. The cluster is accessed with the kubeconfig."`
+	Verify struct {
+		Files         []string `arg:"" type:"existingfile" help:"The objective files to verify."`
+		PrometheusURL *url.URL `default:"http://localhost:9090" help:"The URL to the Prometheus to query."`
+	} `cmd:"" help:"Verifies that the metrics and label matchers of objectives select series in Prometheus, to catch typos and decommissioned metrics."`
+	Lint struct {
+		Paths         []string `arg:"" type:"path" help:"The objective files and directories with objective files to lint."`
+		PrometheusURL *url.URL `help:"The URL to the Prometheus to verify that the metrics of the objectives select series in. Skipped if empty."`
+	} `cmd:"" help:"Validates objectives, checks for duplicates and likely mistakes in targets and windows, and prints the findings as JSON, exiting with 1 if there are errors."`
+	Report struct {
+		Window        model.Duration `default:"30d" help:"The window until now to report the compliance of objectives over, like 90d."`
+		Format        string         `enum:"json,csv" default:"json" help:"The format of the report, json or csv."`
+		Expr          string         `default:"" help:"Selector of the objectives to report, like {team=\"checkout\"}. All objectives are reported if empty."`
+		APIURL        *url.URL       `name:"api-url" default:"http://localhost:9444" help:"The URL to the API service like a Kubernetes Operator to list the objectives from."`
+		PrometheusURL *url.URL       `default:"http://localhost:9090" help:"The URL to the Prometheus, Thanos or Mimir evaluating the objectives."`
+	} `cmd:"" help:"Prints the availability, error budget consumed and alerts of objectives over a window as compliance report, exiting with 1 if any objective couldn't be reported."`
 }
 
 func main() {
@@ -99,13 +153,42 @@ func main() {
 		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
 	)
 
+	// The Kubernetes operator serves the metrics of controller-runtime's registry instead.
+	var gatherer prometheus.Gatherer = reg
+	if ctx.Command() == "kubernetes" {
+		gatherer = ctrlmetrics.Registry
+	}
+	shutdownOTLP, err := startOTLPExport(context.Background(), CLI.OTLPConfig, gatherer)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to start OTLP export", "err", err)
+		os.Exit(1)
+	}
+	serveDebug(log.WithPrefix(logger, "component", "debug"), CLI.DebugConfig)
+
+	shutdownTracing, err := startTracing(context.Background(), CLI.TracingConfig)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to start tracing", "err", err)
+		os.Exit(1)
+	}
+
 	var prometheusURL *url.URL
 	switch ctx.Command() {
 	case "api":
 		prometheusURL = CLI.API.PrometheusURL
 	case "filesystem":
 		prometheusURL = CLI.Filesystem.PrometheusURL
-	default:
+	case "kubernetes":
+		prometheusURL = CLI.Kubernetes.PrometheusURL
+	case "ci comment", "ci comment <files>":
+		prometheusURL = CLI.CI.Comment.PrometheusURL
+	case "verify <files>":
+		prometheusURL = CLI.Verify.PrometheusURL
+	case "lint <paths>":
+		prometheusURL = CLI.Lint.PrometheusURL
+	case "report":
+		prometheusURL = CLI.Report.PrometheusURL
+	}
+	if prometheusURL == nil {
 		prometheusURL, _ = url.Parse("http://localhost:9090")
 	}
 
@@ -131,7 +214,7 @@ func main() {
 
 	client, err := api.NewClient(api.Config{
 		Address:      prometheusURL.String(),
-		RoundTripper: roundTripper,
+		RoundTripper: otelhttp.NewTransport(roundTripper),
 	})
 	if err != nil {
 		level.Error(logger).Log("msg", "failed to create API client", "err", err)
@@ -158,6 +241,14 @@ func main() {
 			CLI.API.UIRoutePrefix,
 			CLI.API.TLSCertFile,
 			CLI.API.TLSPrivateKeyFile,
+			CLI.API.NotificationConfig,
+			CLI.API.ReportConfig,
+			CLI.API.JiraConfig,
+			CLI.API.DatadogConfig,
+			CLI.API.StatuspageConfig,
+			CLI.API.CloudEventsConfig,
+			CLI.API.AlertmanagerWebhookConfig,
+			CLI.API.StatusCacheConfig,
 		)
 	case "filesystem":
 		code = cmdFilesystem(
@@ -167,25 +258,107 @@ func main() {
 			CLI.Filesystem.ConfigFiles,
 			CLI.Filesystem.PrometheusFolder,
 			CLI.Filesystem.GenericRules,
+			CLI.Filesystem.Workers,
 		)
 	case "kubernetes":
-		code = cmdKubernetes(
-			logger,
-			CLI.Kubernetes.MetricsAddr,
-			CLI.Kubernetes.ConfigMapMode,
-			CLI.Kubernetes.GenericRules,
-			CLI.Kubernetes.DisableWebhooks,
-			CLI.Kubernetes.TLSCertFile,
-			CLI.Kubernetes.TLSPrivateKeyFile,
-		)
+		var promAPI prometheusapiv1.API
+		if CLI.Kubernetes.PrometheusURL != nil {
+			promAPI = prometheusapiv1.NewAPI(client)
+		}
+		code = cmdKubernetes(logger, CLI.Kubernetes, promAPI)
 	case "generate":
 		code = cmdGenerate(
 			logger,
 			CLI.Generate.ConfigFiles,
 			CLI.Generate.PrometheusFolder,
 			CLI.Generate.GenericRules,
-			CLI.Generate.OperatorRule,
+			CLI.Generate.Format(),
+			CLI.Generate.Workers,
 		)
+	case "ci comment", "ci comment <files>":
+		var promAPI budgetQuerier
+		if CLI.CI.Comment.PrometheusURL != nil {
+			promAPI = promQuerier{api: prometheusapiv1.NewAPI(client)}
+		}
+		var status *githubStatus
+		if c := CLI.CI.Comment; c.GitHubToken != "" && c.GitHubRepository != "" && c.GitHubSHA != "" {
+			status = &githubStatus{
+				url:        c.GitHubAPIURL,
+				token:      c.GitHubToken,
+				repository: c.GitHubRepository,
+				sha:        c.GitHubSHA,
+				client:     &http.Client{Timeout: 10 * time.Second},
+			}
+		}
+		code = cmdCIComment(
+			logger,
+			os.Stdout,
+			CLI.CI.Comment.Files,
+			CLI.CI.Comment.Base,
+			promAPI,
+			status,
+		)
+	case "diff", "diff <files>":
+		c, err := ctrlclient.New(ctrl.GetConfigOrDie(), ctrlclient.Options{Scheme: scheme})
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to create kubernetes client", "err", err)
+			os.Exit(2)
+		}
+		code = cmdDiff(
+			logger,
+			os.Stdout,
+			c,
+			CLI.Diff.Files,
+			CLI.Diff.Namespace,
+			CLI.Diff.GenericRules,
+		)
+	case "verify <files>":
+		code = cmdVerify(
+			logger,
+			os.Stdout,
+			CLI.Verify.Files,
+			prometheusapiv1.NewAPI(client),
+		)
+	case "lint <paths>":
+		var querier prometheusapiv1.API
+		if CLI.Lint.PrometheusURL != nil {
+			querier = prometheusapiv1.NewAPI(client)
+		}
+		code = cmdLint(
+			logger,
+			os.Stdout,
+			CLI.Lint.Paths,
+			querier,
+		)
+	case "report":
+		code = cmdReport(
+			logger,
+			os.Stdout,
+			objectivesv1alpha1connect.NewObjectiveBackendServiceClient(
+				&http.Client{Timeout: 30 * time.Second, Transport: otelhttp.NewTransport(http.DefaultTransport)},
+				CLI.Report.APIURL.String(),
+			),
+			promQuerier{api: prometheusapiv1.NewAPI(client)},
+			CLI.Report.Expr,
+			time.Duration(CLI.Report.Window),
+			CLI.Report.Format,
+		)
+	case "import nobl9 <files>":
+		code = cmdImportNobl9(
+			logger,
+			os.Stdout,
+			CLI.Import.Nobl9.Files,
+			CLI.Import.Nobl9.Namespace,
+		)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := shutdownOTLP(shutdownCtx); err != nil {
+		level.Warn(logger).Log("msg", "failed to flush OTLP metrics", "err", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		level.Warn(logger).Log("msg", "failed to flush traces", "err", err)
 	}
 	os.Exit(code)
 }
@@ -197,6 +370,14 @@ func cmdAPI(
 	prometheusExternal, apiURL *url.URL,
 	routePrefix, uiRoutePrefix string,
 	tlsCertFile, tlsPrivateKeyFile string,
+	notifications NotificationConfig,
+	reports ReportConfig,
+	jira JiraConfig,
+	datadog DatadogConfig,
+	statuspage StatuspageConfig,
+	cloudEvents CloudEventsConfig,
+	alertmanager AlertmanagerWebhookConfig,
+	statusCacheConfig StatusCacheConfig,
 ) int {
 	build, err := fs.Sub(ui, "ui/build")
 	if err != nil {
@@ -241,6 +422,7 @@ func cmdAPI(
 	}
 
 	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
 	r.Use(cors.Handler(cors.Options{
 		AllowedHeaders: []string{
 			"Content-Type",
@@ -250,33 +432,49 @@ func cmdAPI(
 
 	prometheusInterceptor := connectprometheus.NewInterceptor(reg)
 
-	r.Route(routePrefix, func(r chi.Router) {
-		clientConfig := promconfig.HTTPClientConfig{
-			TLSConfig: promconfig.TLSConfig{
-				InsecureSkipVerify: true,
-			},
-		}
+	clientConfig := promconfig.HTTPClientConfig{
+		TLSConfig: promconfig.TLSConfig{
+			InsecureSkipVerify: true,
+		},
+	}
 
-		roundTripper, err := promconfig.NewRoundTripperFromConfig(clientConfig, "api")
-		if err != nil {
-			level.Error(logger).Log("msg", "failed to create API client round tripper", "err", err)
-			os.Exit(1)
-		}
+	roundTripper, err := promconfig.NewRoundTripperFromConfig(clientConfig, "api")
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to create API client round tripper", "err", err)
+		return 1
+	}
 
-		client := &http.Client{
-			Transport: roundTripper,
+	backendClient := newBackendClientCache(
+		objectivesv1alpha1connect.NewObjectiveBackendServiceClient(
+			&http.Client{Transport: otelhttp.NewTransport(roundTripper)},
+			apiURL.String(),
+			connect.WithInterceptors(prometheusInterceptor),
+		),
+	)
+
+	schema, err := objectiveSchema()
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to generate objective schema", "err", err)
+		return 1
+	}
+
+	var statuses *statusCache
+	if statusCacheConfig.StatusCacheInterval > 0 {
+		statuses = &statusCache{
+			logger:    log.WithPrefix(logger, "component", "statuscache"),
+			client:    backendClient,
+			promAPI:   promAPI,
+			interval:  statusCacheConfig.StatusCacheInterval,
+			batchSize: statusCacheConfig.StatusCacheBatchSize,
 		}
+	}
 
+	r.Route(routePrefix, func(r chi.Router) {
 		objectiveService := &objectiveServer{
-			logger:  log.WithPrefix(logger, "service", "objective"),
-			promAPI: promAPI,
-			client: newBackendClientCache(
-				objectivesv1alpha1connect.NewObjectiveBackendServiceClient(
-					client,
-					apiURL.String(),
-					connect.WithInterceptors(prometheusInterceptor),
-				),
-			),
+			logger:   log.WithPrefix(logger, "component", "api", "service", "objective"),
+			promAPI:  promAPI,
+			client:   backendClient,
+			statuses: statuses,
 		}
 
 		objectivePath, objectiveHandler := objectivesv1alpha1connect.NewObjectiveServiceHandler(
@@ -285,7 +483,7 @@ func cmdAPI(
 		)
 
 		prometheusService := &prometheusServer{
-			logger:  log.WithPrefix(logger, "service", "prometheus"),
+			logger:  log.WithPrefix(logger, "component", "api", "service", "prometheus"),
 			promAPI: promAPI,
 		}
 		prometheusPath, prometheusHandler := prometheusv1connect.NewPrometheusServiceHandler(prometheusService)
@@ -299,6 +497,42 @@ func cmdAPI(
 		}
 
 		r.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+		r.Get("/schema/servicelevelobjective.json", func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/schema+json")
+			_, _ = w.Write(schema)
+		})
+		if alertmanager.AlertmanagerWebhookForwardURL != nil {
+			enricher := &alertEnricher{
+				logger:     log.WithPrefix(logger, "component", "alertmanager"),
+				client:     backendClient,
+				promAPI:    promAPI,
+				forwardURL: alertmanager.AlertmanagerWebhookForwardURL.String(),
+				httpClient: &http.Client{Timeout: 10 * time.Second},
+			}
+			if alertmanager.AlertmanagerWebhookPyrraURL != nil {
+				enricher.pyrraURL = alertmanager.AlertmanagerWebhookPyrraURL.String()
+			}
+			r.Post("/alertmanager/webhook", enricher.ServeHTTP)
+		}
+		r.Get("/revisions", (&revisionsHandler{
+			logger: log.WithPrefix(logger, "component", "api", "service", "revisions"),
+			client: &http.Client{Timeout: 10 * time.Second, Transport: otelhttp.NewTransport(roundTripper)},
+			url:    strings.TrimSuffix(apiURL.String(), "/"),
+		}).ServeHTTP)
+		r.Get("/freeze", (&freezeHandler{
+			logger:  log.WithPrefix(logger, "component", "api", "service", "freeze"),
+			promAPI: promAPI,
+		}).ServeHTTP)
+		r.Get("/forecast", (&forecastHandler{
+			logger:  log.WithPrefix(logger, "component", "api", "service", "forecast"),
+			promAPI: promAPI,
+			client:  backendClient,
+		}).ServeHTTP)
+		r.Get("/report", (&complianceHandler{reporter: &complianceReporter{
+			logger:  log.WithPrefix(logger, "component", "api", "service", "report"),
+			client:  backendClient,
+			promAPI: promAPI,
+		}}).ServeHTTP)
 		r.Get("/objectives", func(w http.ResponseWriter, _ *http.Request) {
 			err := tmpl.Execute(w, struct {
 				PrometheusURL string
@@ -351,10 +585,152 @@ func cmdAPI(
 	)
 	gr.Add(run.SignalHandler(ctx, os.Interrupt, syscall.SIGTERM))
 
+	cloudEventsSender := cloudEvents.sender(&http.Client{Timeout: 10 * time.Second})
+
+	notifiers := notifications.notifiers(&http.Client{Timeout: 10 * time.Second})
+	if cloudEventsSender != nil {
+		notifiers = append(notifiers, cloudEventsSender)
+	}
+	if len(notifiers) > 0 {
+		watcher := newBudgetWatcher(
+			log.WithPrefix(logger, "component", "notifications"),
+			backendClient,
+			promAPI,
+			notifications.NotificationThresholds,
+			notifiers,
+		)
+
+		watchCtx, cancel := context.WithCancel(ctx)
+		gr.Add(func() error {
+			level.Info(logger).Log("msg", "sending error budget notifications", "thresholds", fmt.Sprint(notifications.NotificationThresholds))
+			return watcher.Run(watchCtx, notifications.NotificationInterval)
+		}, func(error) {
+			cancel()
+		})
+	}
+
+	if cloudEventsSender != nil {
+		watcher := &objectiveLifecycleWatcher{
+			logger: log.WithPrefix(logger, "component", "cloudevents"),
+			client: backendClient,
+			sender: cloudEventsSender,
+		}
+
+		cloudEventsCtx, cancel := context.WithCancel(ctx)
+		gr.Add(func() error {
+			level.Info(logger).Log("msg", "sending CloudEvents", "sink", cloudEvents.CloudEventsSinkURL.String())
+			return watcher.Run(cloudEventsCtx, cloudEvents.CloudEventsInterval)
+		}, func(error) {
+			cancel()
+		})
+	}
+
+	if senders := reports.senders(&http.Client{Timeout: 30 * time.Second}); len(senders) > 0 {
+		schedule, err := reports.schedule()
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to parse report schedule", "err", err)
+			return 1
+		}
+		location, err := time.LoadLocation(reports.ReportTimezone)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to load report time zone", "err", err)
+			return 1
+		}
+
+		rep := &reporter{
+			logger:    log.WithPrefix(logger, "component", "reports"),
+			client:    backendClient,
+			promAPI:   promAPI,
+			schedule:  schedule,
+			period:    reports.ReportPeriod,
+			calendar:  reports.ReportCalendar,
+			location:  location,
+			teamLabel: reports.ReportTeamLabel,
+			senders:   senders,
+		}
+
+		reportCtx, cancel := context.WithCancel(ctx)
+		gr.Add(func() error {
+			level.Info(logger).Log("msg", "sending scheduled reports", "schedule", reports.ReportSchedule)
+			return rep.Run(reportCtx)
+		}, func(error) {
+			cancel()
+		})
+	}
+
+	if jiraClient := jira.client(&http.Client{Timeout: 10 * time.Second}); jiraClient != nil {
+		bridge := &jiraBridge{
+			logger:     log.WithPrefix(logger, "component", "jira"),
+			client:     backendClient,
+			promAPI:    promAPI,
+			jira:       jiraClient,
+			project:    jira.JiraProject,
+			issueType:  jira.JiraIssueType,
+			transition: jira.JiraResolveTransition,
+			severities: jira.JiraSeverities,
+		}
+		if jira.JiraPyrraURL != nil {
+			bridge.pyrraURL = jira.JiraPyrraURL.String()
+		}
+
+		jiraCtx, cancel := context.WithCancel(ctx)
+		gr.Add(func() error {
+			level.Info(logger).Log("msg", "syncing Jira issues", "project", jira.JiraProject)
+			return bridge.Run(jiraCtx, jira.JiraInterval)
+		}, func(error) {
+			cancel()
+		})
+	}
+
+	if datadogClient := datadog.client(&http.Client{Timeout: 10 * time.Second}); datadogClient != nil {
+		exporter := &datadogExporter{
+			logger:       log.WithPrefix(logger, "component", "datadog"),
+			client:       backendClient,
+			datadog:      datadogClient,
+			metricPrefix: datadog.DatadogMetricPrefix,
+		}
+
+		datadogCtx, cancel := context.WithCancel(ctx)
+		gr.Add(func() error {
+			level.Info(logger).Log("msg", "syncing objectives to Datadog", "url", datadog.DatadogURL.String())
+			return exporter.Run(datadogCtx, datadog.DatadogInterval)
+		}, func(error) {
+			cancel()
+		})
+	}
+
+	if statuses != nil {
+		statusCtx, cancel := context.WithCancel(ctx)
+		gr.Add(func() error {
+			level.Info(logger).Log("msg", "caching objective statuses", "interval", statusCacheConfig.StatusCacheInterval)
+			return statuses.Run(statusCtx)
+		}, func(error) {
+			cancel()
+		})
+	}
+
+	if statuspageClient := statuspage.client(&http.Client{Timeout: 10 * time.Second}); statuspageClient != nil {
+		syncer := &statuspageSyncer{
+			logger:         log.WithPrefix(logger, "component", "statuspage"),
+			client:         backendClient,
+			promAPI:        promAPI,
+			statuspage:     statuspageClient,
+			componentLabel: statuspage.StatuspageComponentLabel,
+		}
+
+		statuspageCtx, cancel := context.WithCancel(ctx)
+		gr.Add(func() error {
+			level.Info(logger).Log("msg", "syncing Statuspage components", "page", statuspage.StatuspagePageID)
+			return syncer.Run(statuspageCtx, statuspage.StatuspageInterval)
+		}, func(error) {
+			cancel()
+		})
+	}
+
 	{
 		httpServer := &http.Server{
 			Addr:      ":9099",
-			Handler:   h2c.NewHandler(r, &http2.Server{}),
+			Handler:   h2c.NewHandler(otelhttp.NewHandler(r, "api"), &http2.Server{}),
 			TLSConfig: &tls.Config{},
 		}
 		gr.Add(
@@ -402,7 +778,7 @@ type backendClientCache struct {
 
 // List calls the backend service and caches the result for 10 seconds if the request is successful.
 func (b *backendClientCache) List(ctx context.Context, req *connect.Request[objectivesv1alpha1.ListRequest]) (*connect.Response[objectivesv1alpha1.ListResponse], error) {
-	key := req.Msg.Expr + req.Msg.Grouping
+	key := fmt.Sprintf("%s\x00%s\x00%s\x00%d\x00%s", req.Msg.Expr, req.Msg.Grouping, req.Msg.LabelSelector, req.Msg.PageSize, req.Msg.PageToken)
 
 	list, found := b.cache.Get(key)
 	if found {
@@ -528,7 +904,11 @@ func contextGetPromCache(ctx context.Context) time.Duration {
 }
 
 func (p *promCache) Query(ctx context.Context, query string, ts time.Time) (model.Value, prometheusapiv1.Warnings, error) {
+	ctx, span := tracer.Start(ctx, "prometheus.Query", trace.WithAttributes(attribute.String("query", query)))
+	defer span.End()
+
 	if value, exists := p.cache.Get(query); exists {
+		span.SetAttributes(attribute.Bool("cache.hit", true))
 		return value.(model.Value), nil, nil
 	}
 
@@ -536,6 +916,7 @@ func (p *promCache) Query(ctx context.Context, query string, ts time.Time) (mode
 	value, warnings, err := p.api.Query(ctx, query, ts)
 	duration := time.Since(start)
 	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		return nil, warnings, fmt.Errorf("prometheus query: %w", err)
 	}
 	if len(warnings) > 0 {
@@ -560,7 +941,14 @@ func (p *promCache) QueryRange(ctx context.Context, query string, r prometheusap
 	timeRange := r.End.Sub(r.Start).Round(10 * time.Second)
 	cacheKey := fmt.Sprintf("%d;%s", timeRange.Milliseconds(), query)
 
+	ctx, span := tracer.Start(ctx, "prometheus.QueryRange", trace.WithAttributes(
+		attribute.String("query", query),
+		attribute.String("range", timeRange.String()),
+	))
+	defer span.End()
+
 	if value, exists := p.cache.Get(cacheKey); exists {
+		span.SetAttributes(attribute.Bool("cache.hit", true))
 		return value.(model.Value), nil, nil
 	}
 
@@ -568,6 +956,7 @@ func (p *promCache) QueryRange(ctx context.Context, query string, r prometheusap
 	value, warnings, err := p.api.QueryRange(ctx, query, r)
 	duration := time.Since(start)
 	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		return nil, warnings, fmt.Errorf("prometheus query range: %w", err)
 	}
 	if len(warnings) > 0 {
@@ -587,9 +976,10 @@ func (p *promCache) QueryRange(ctx context.Context, query string, r prometheusap
 }
 
 type objectiveServer struct {
-	logger  log.Logger
-	promAPI *promCache
-	client  objectivesv1alpha1connect.ObjectiveBackendServiceClient
+	logger   log.Logger
+	promAPI  *promCache
+	client   objectivesv1alpha1connect.ObjectiveBackendServiceClient
+	statuses *statusCache
 }
 
 func (s *objectiveServer) getObjective(ctx context.Context, expr string) (slo.Objective, error) {
@@ -615,7 +1005,10 @@ func (s *objectiveServer) List(ctx context.Context, req *connect.Request[objecti
 	}
 
 	resp, err := s.client.List(ctx, connect.NewRequest(&objectivesv1alpha1.ListRequest{
-		Expr: req.Msg.Expr,
+		Expr:          req.Msg.Expr,
+		LabelSelector: req.Msg.LabelSelector,
+		PageSize:      req.Msg.PageSize,
+		PageToken:     req.Msg.PageToken,
 	}))
 	if err != nil {
 		return nil, err
@@ -735,7 +1128,8 @@ func (s *objectiveServer) List(ctx context.Context, req *connect.Request[objecti
 	}
 
 	return connect.NewResponse(&objectivesv1alpha1.ListResponse{
-		Objectives: resp.Msg.Objectives,
+		Objectives:    resp.Msg.Objectives,
+		NextPageToken: resp.Msg.NextPageToken,
 	}), nil
 }
 
@@ -773,16 +1167,52 @@ func (s *objectiveServer) GetStatus(ctx context.Context, req *connect.Request[ob
 		ts = req.Msg.Time.AsTime()
 	}
 
+	if req.Msg.Grouping == "" && req.Msg.Time == nil && s.statuses != nil {
+		if statuses, ok := s.statuses.get(objective); ok {
+			return connect.NewResponse(&objectivesv1alpha1.GetStatusResponse{
+				Status: statuses,
+			}), nil
+		}
+	}
+
+	statuses, err := objectiveStatuses(ctx, contextLogger(ctx, s.logger), s.promAPI, objective, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	return connect.NewResponse(&objectivesv1alpha1.GetStatusResponse{
+		Status: statuses,
+	}), nil
+}
+
+// objectiveStatuses queries the availability and error budget of the objective at the given time,
+// one status for every group of a grouped objective.
+func objectiveStatuses(ctx context.Context, logger log.Logger, promAPI budgetQuerier, objective slo.Objective, ts time.Time) ([]*objectivesv1alpha1.ObjectiveStatus, error) {
 	queryTotal := objective.QueryTotal(objective.Window)
-	value, _, err := s.promAPI.Query(contextSetPromCache(ctx, 15*time.Second), queryTotal, ts)
+	value, _, err := promAPI.Query(contextSetPromCache(ctx, 15*time.Second), queryTotal, ts)
+	if err != nil {
+		level.Warn(logger).Log("msg", "failed to query total", "query", queryTotal, "err", err)
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	total, _ := value.(model.Vector)
+
+	queryErrors := objective.QueryErrors(objective.Window)
+	value, _, err = promAPI.Query(contextSetPromCache(ctx, 15*time.Second), queryErrors, ts)
 	if err != nil {
-		level.Warn(s.logger).Log("msg", "failed to query total", "query", queryTotal, "err", err)
+		level.Warn(logger).Log("msg", "failed to query errors", "query", queryErrors, "err", err)
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
+	errs, _ := value.(model.Vector)
 
+	return statusesFromVectors(objective, total, errs), nil
+}
+
+// statusesFromVectors returns the statuses of the objective from the results of its total and errors queries.
+func statusesFromVectors(objective slo.Objective, total, errs model.Vector) []*objectivesv1alpha1.ObjectiveStatus {
 	statuses := map[model.Fingerprint]*objectivesv1alpha1.ObjectiveStatus{}
 
-	for _, v := range value.(model.Vector) {
+	for _, v := range total {
 		ls := make(map[string]string)
 		for k, v := range v.Metric {
 			ls[string(k)] = string(v)
@@ -797,29 +1227,11 @@ func (s *objectiveServer) GetStatus(ctx context.Context, req *connect.Request[ob
 		}
 	}
 
-	queryErrors := objective.QueryErrors(objective.Window)
-	value, _, err = s.promAPI.Query(contextSetPromCache(ctx, 15*time.Second), queryErrors, ts)
-	if err != nil {
-		level.Warn(s.logger).Log("msg", "failed to query errors", "query", queryErrors, "err", err)
-		return nil, connect.NewError(connect.CodeInternal, err)
-	}
-	for _, v := range value.(model.Vector) {
+	for _, v := range errs {
+		// Errors without any requests are skipped, just like objectives without requests.
 		if s, exists := statuses[v.Metric.Fingerprint()]; exists {
 			s.Availability.Errors = float64(v.Value)
 			s.Availability.Percentage = 1 - (s.Availability.Errors / s.Availability.Total)
-		} else {
-			objectiveLabels := make(map[string]string)
-			for k, v := range v.Metric {
-				objectiveLabels[string(k)] = string(v)
-			}
-
-			statuses[v.Metric.Fingerprint()] = &objectivesv1alpha1.ObjectiveStatus{
-				Labels: objectiveLabels,
-				Availability: &objectivesv1alpha1.Availability{
-					Percentage: 1 - (s.Availability.Errors / s.Availability.Total),
-					Total:      float64(v.Value),
-				},
-			}
 		}
 	}
 
@@ -845,9 +1257,7 @@ func (s *objectiveServer) GetStatus(ctx context.Context, req *connect.Request[ob
 		statusSlice = append(statusSlice, s)
 	}
 
-	return connect.NewResponse(&objectivesv1alpha1.GetStatusResponse{
-		Status: statusSlice,
-	}), nil
+	return statusSlice
 }
 
 func (s *objectiveServer) GraphErrorBudget(ctx context.Context, req *connect.Request[objectivesv1alpha1.GraphErrorBudgetRequest]) (*connect.Response[objectivesv1alpha1.GraphErrorBudgetResponse], error) {
@@ -932,19 +1342,19 @@ func (s *objectiveServer) GraphErrorBudget(ctx context.Context, req *connect.Req
 		Step:  step,
 	})
 	if err != nil {
-		level.Warn(s.logger).Log("msg", "failed to query error budget", "query", query, "err", err)
+		level.Warn(contextLogger(ctx, s.logger)).Log("msg", "failed to query error budget", "query", query, "err", err)
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
 	matrix, ok := value.(model.Matrix)
 	if !ok {
 		err := fmt.Errorf("no matrix returned")
-		level.Debug(s.logger).Log("msg", "returned data wasn't of type matrix", "query", query, "err", err)
+		level.Debug(contextLogger(ctx, s.logger)).Log("msg", "returned data wasn't of type matrix", "query", query, "err", err)
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
 	if len(matrix) == 0 {
-		level.Debug(s.logger).Log("msg", "returned no data", "query", query)
+		level.Debug(contextLogger(ctx, s.logger)).Log("msg", "returned no data", "query", query)
 		return nil, connect.NewError(connect.CodeNotFound, nil)
 	}
 
@@ -1014,14 +1424,14 @@ func (s *objectiveServer) GetAlerts(ctx context.Context, req *connect.Request[ob
 
 	value, _, err := s.promAPI.Query(contextSetPromCache(ctx, 5*time.Second), queryAlerts, time.Now())
 	if err != nil {
-		level.Warn(s.logger).Log("msg", "failed to query alerts", "query", queryAlerts, "err", err)
+		level.Warn(contextLogger(ctx, s.logger)).Log("msg", "failed to query alerts", "query", queryAlerts, "err", err)
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
 	vector, ok := value.(model.Vector)
 	if !ok {
 		err := fmt.Errorf("no vector returned")
-		level.Debug(s.logger).Log("msg", "returned data wasn't of type vector", "query", queryAlerts, "err", err)
+		level.Debug(contextLogger(ctx, s.logger)).Log("msg", "returned data wasn't of type vector", "query", queryAlerts, "err", err)
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
@@ -1044,24 +1454,24 @@ func (s *objectiveServer) GetAlerts(ctx context.Context, req *connect.Request[ob
 
 					query, err := objective.QueryBurnrate(w, groupingMatchers)
 					if err != nil {
-						level.Warn(s.logger).Log("msg", "failed to prepare current burn rate query", "err", err)
+						level.Warn(contextLogger(ctx, s.logger)).Log("msg", "failed to prepare current burn rate query", "err", err)
 						return
 					}
 					value, _, err := s.promAPI.Query(contextSetPromCache(ctx, instantCache(w)), query, time.Now())
 					if err != nil {
-						level.Warn(s.logger).Log("msg", "failed to query current burn rate", "query", query, "err", err)
+						level.Warn(contextLogger(ctx, s.logger)).Log("msg", "failed to query current burn rate", "query", query, "err", err)
 						return
 					}
 					vec, ok := value.(model.Vector)
 					if !ok {
-						level.Warn(s.logger).Log("msg", "failed to query current burn rate", "query", query, "err", "expected vector value from Prometheus")
+						level.Warn(contextLogger(ctx, s.logger)).Log("msg", "failed to query current burn rate", "query", query, "err", "expected vector value from Prometheus")
 						return
 					}
 					if vec.Len() == 0 {
 						return
 					}
 					if vec.Len() != 1 {
-						level.Warn(s.logger).Log("msg", "failed to query current burn rate", "query", query, "err", "expected vector with one value from Prometheus")
+						level.Warn(contextLogger(ctx, s.logger)).Log("msg", "failed to query current burn rate", "query", query, "err", "expected vector with one value from Prometheus")
 						return
 					}
 
@@ -1310,25 +1720,25 @@ func (s *objectiveServer) GraphRate(ctx context.Context, req *connect.Request[ob
 		Step:  step,
 	})
 	if err != nil {
-		level.Warn(s.logger).Log("msg", "failed to run range request", "query", query, "err", err)
+		level.Warn(contextLogger(ctx, s.logger)).Log("msg", "failed to run range request", "query", query, "err", err)
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
 	if value.Type() != model.ValMatrix {
 		err := fmt.Errorf("returned data is not a matrix")
-		level.Warn(s.logger).Log("query", query, "err", err)
+		level.Warn(contextLogger(ctx, s.logger)).Log("query", query, "err", err)
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
 	matrix, ok := value.(model.Matrix)
 	if !ok {
 		err := fmt.Errorf("no matrix returned")
-		level.Warn(s.logger).Log("query", query, "err", err)
+		level.Warn(contextLogger(ctx, s.logger)).Log("query", query, "err", err)
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
 	if len(matrix) == 0 {
-		level.Debug(s.logger).Log("msg", "no data returned", "query", query)
+		level.Debug(contextLogger(ctx, s.logger)).Log("msg", "no data returned", "query", query)
 		return nil, connect.NewError(connect.CodeNotFound, err)
 	}
 
@@ -1408,25 +1818,25 @@ func (s *objectiveServer) GraphErrors(ctx context.Context, req *connect.Request[
 		Step:  step,
 	})
 	if err != nil {
-		level.Warn(s.logger).Log("msg", "failed to run range error request", "query", query, "err", err)
+		level.Warn(contextLogger(ctx, s.logger)).Log("msg", "failed to run range error request", "query", query, "err", err)
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
 	if value.Type() != model.ValMatrix {
 		err := fmt.Errorf("returned data is not a matrix")
-		level.Warn(s.logger).Log("query", query, "err", err)
+		level.Warn(contextLogger(ctx, s.logger)).Log("query", query, "err", err)
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
 	matrix, ok := value.(model.Matrix)
 	if !ok {
 		err := fmt.Errorf("no matrix returned")
-		level.Warn(s.logger).Log("query", query, "err", err)
+		level.Warn(contextLogger(ctx, s.logger)).Log("query", query, "err", err)
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
 	if len(matrix) == 0 {
-		level.Debug(s.logger).Log("msg", "no data returned", "query", query)
+		level.Debug(contextLogger(ctx, s.logger)).Log("msg", "no data returned", "query", query)
 		return nil, connect.NewError(connect.CodeNotFound, err)
 	}
 
@@ -1529,25 +1939,25 @@ func (s *objectiveServer) GraphDuration(ctx context.Context, req *connect.Reques
 				Step:  step,
 			})
 			if err != nil {
-				level.Warn(s.logger).Log("msg", "failed to run range error request", "query", query, "err", err)
+				level.Warn(contextLogger(ctx, s.logger)).Log("msg", "failed to run range error request", "query", query, "err", err)
 				return nil, connect.NewError(connect.CodeInternal, err)
 			}
 
 			if value.Type() != model.ValMatrix {
 				err := fmt.Errorf("returned data is not a matrix")
-				level.Warn(s.logger).Log("query", query, "err", err)
+				level.Warn(contextLogger(ctx, s.logger)).Log("query", query, "err", err)
 				return nil, connect.NewError(connect.CodeInternal, err)
 			}
 
 			matrix, ok := value.(model.Matrix)
 			if !ok {
 				err := fmt.Errorf("no matrix returned")
-				level.Warn(s.logger).Log("query", query, "err", err)
+				level.Warn(contextLogger(ctx, s.logger)).Log("query", query, "err", err)
 				return nil, connect.NewError(connect.CodeInternal, err)
 			}
 
 			if len(matrix) == 0 {
-				level.Debug(s.logger).Log("msg", "no data returned", "query", query)
+				level.Debug(contextLogger(ctx, s.logger)).Log("msg", "no data returned", "query", query)
 				return nil, connect.NewError(connect.CodeNotFound, err)
 			}
 
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	"github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+// nobl9SLO is the subset of Nobl9's SLO YAML that is needed to convert it to Pyrra objectives.
+// https://docs.nobl9.com/yaml-guide/#slo
+type nobl9SLO struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name   string              `json:"name"`
+		Labels map[string][]string `json:"labels"`
+	} `json:"metadata"`
+	Spec struct {
+		Description     string `json:"description"`
+		BudgetingMethod string `json:"budgetingMethod"`
+		TimeWindows     []struct {
+			Unit      string `json:"unit"`
+			Count     int    `json:"count"`
+			IsRolling bool   `json:"isRolling"`
+		} `json:"timeWindows"`
+		Objectives []nobl9Objective `json:"objectives"`
+	} `json:"spec"`
+}
+
+type nobl9Objective struct {
+	Name         string             `json:"name"`
+	Target       float64            `json:"target"`
+	CountMetrics *nobl9CountMetrics `json:"countMetrics"`
+}
+
+type nobl9CountMetrics struct {
+	Good  *nobl9Metric `json:"good"`
+	Bad   *nobl9Metric `json:"bad"`
+	Total *nobl9Metric `json:"total"`
+}
+
+type nobl9Metric struct {
+	Prometheus *nobl9PrometheusQuery `json:"prometheus"`
+}
+
+type nobl9PrometheusQuery struct {
+	PromQL string `json:"promql"`
+}
+
+func cmdImportNobl9(logger log.Logger, out io.Writer, files []string, namespace string) int {
+	var documents [][]byte
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to read file", "file", file, "err", err)
+			return 1
+		}
+
+		slos, err := parseNobl9(content)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to parse Nobl9 SLOs", "file", file, "err", err)
+			return 1
+		}
+
+		for _, s := range slos {
+			objectives, err := convertNobl9(s, namespace)
+			if err != nil {
+				level.Warn(logger).Log("msg", "skipping SLO", "slo", s.Metadata.Name, "reason", err)
+				continue
+			}
+			for _, o := range objectives {
+				b, err := marshalObjective(o)
+				if err != nil {
+					level.Error(logger).Log("msg", "failed to marshal objective", "objective", o.GetName(), "err", err)
+					return 1
+				}
+				documents = append(documents, b)
+			}
+		}
+	}
+
+	if _, err := out.Write(bytes.Join(documents, []byte("---\n"))); err != nil {
+		level.Error(logger).Log("msg", "failed to write objectives", "err", err)
+		return 1
+	}
+	return 0
+}
+
+// parseNobl9 reads the SLOs out of multi-document YAML or lists of objects as exported by sloctl.
+// Objects of other kinds are ignored.
+func parseNobl9(content []byte) ([]nobl9SLO, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+
+	var slos []nobl9SLO
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return slos, nil
+			}
+			return nil, err
+		}
+
+		var objects []nobl9SLO
+		if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+			if err := json.Unmarshal(raw, &objects); err != nil {
+				return nil, err
+			}
+		} else {
+			var object nobl9SLO
+			if err := json.Unmarshal(raw, &object); err != nil {
+				return nil, err
+			}
+			objects = append(objects, object)
+		}
+
+		for _, o := range objects {
+			if o.Kind == "SLO" {
+				slos = append(slos, o)
+			}
+		}
+	}
+}
+
+// convertNobl9 converts each objective of the SLO into a Pyrra objective.
+// Only Prometheus count metrics that map to a ratio or latency indicator are supported.
+func convertNobl9(s nobl9SLO, namespace string) ([]v1alpha1.ServiceLevelObjective, error) {
+	if s.Spec.BudgetingMethod != "" && s.Spec.BudgetingMethod != "Occurrences" {
+		return nil, fmt.Errorf("budgeting method %s is not supported, only Occurrences", s.Spec.BudgetingMethod)
+	}
+	window, err := nobl9Window(s)
+	if err != nil {
+		return nil, err
+	}
+
+	lset := map[string]string{}
+	for name, values := range s.Metadata.Labels {
+		// Kubernetes labels only have a single value.
+		if len(values) == 1 {
+			lset[name] = values[0]
+		}
+	}
+	if len(lset) == 0 {
+		lset = nil
+	}
+
+	objectives := make([]v1alpha1.ServiceLevelObjective, 0, len(s.Spec.Objectives))
+	for _, o := range s.Spec.Objectives {
+		indicator, err := nobl9Indicator(o)
+		if err != nil {
+			return nil, fmt.Errorf("objective %s: %w", o.Name, err)
+		}
+
+		name := s.Metadata.Name
+		if len(s.Spec.Objectives) > 1 {
+			name = name + "-" + o.Name
+		}
+
+		objective := v1alpha1.ServiceLevelObjective{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: v1alpha1.GroupVersion.String(),
+				Kind:       "ServiceLevelObjective",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    lset,
+			},
+			Spec: v1alpha1.ServiceLevelObjectiveSpec{
+				Description:           s.Spec.Description,
+				Target:                strconv.FormatFloat(math.Round(o.Target*100*1e6)/1e6, 'f', -1, 64),
+				Window:                window.String(),
+				ServiceLevelIndicator: indicator,
+			},
+		}
+		if _, err := objective.ValidateCreate(); err != nil {
+			return nil, fmt.Errorf("objective %s: %w", o.Name, err)
+		}
+
+		objectives = append(objectives, objective)
+	}
+
+	return objectives, nil
+}
+
+func nobl9Window(s nobl9SLO) (model.Duration, error) {
+	if len(s.Spec.TimeWindows) != 1 {
+		return 0, fmt.Errorf("exactly one time window is required")
+	}
+	w := s.Spec.TimeWindows[0]
+	if !w.IsRolling {
+		return 0, fmt.Errorf("calendar aligned time windows are not supported")
+	}
+
+	units := map[string]string{"Minute": "m", "Hour": "h", "Day": "d", "Week": "w"}
+	unit, ok := units[w.Unit]
+	if !ok {
+		return 0, fmt.Errorf("time window unit %s is not supported", w.Unit)
+	}
+	return model.ParseDuration(strconv.Itoa(w.Count) + unit)
+}
+
+func nobl9Indicator(o nobl9Objective) (v1alpha1.ServiceLevelIndicator, error) {
+	var indicator v1alpha1.ServiceLevelIndicator
+	if o.CountMetrics == nil || o.CountMetrics.Total == nil {
+		return indicator, fmt.Errorf("only count metrics are supported")
+	}
+
+	total, err := nobl9Selector(o.CountMetrics.Total)
+	if err != nil {
+		return indicator, fmt.Errorf("total: %w", err)
+	}
+
+	if o.CountMetrics.Bad != nil {
+		bad, err := nobl9Selector(o.CountMetrics.Bad)
+		if err != nil {
+			return indicator, fmt.Errorf("bad: %w", err)
+		}
+		indicator.Ratio = &v1alpha1.RatioIndicator{
+			Errors: v1alpha1.Query{Metric: bad.String()},
+			Total:  v1alpha1.Query{Metric: total.String()},
+		}
+		return indicator, nil
+	}
+
+	if o.CountMetrics.Good == nil {
+		return indicator, fmt.Errorf("good or bad metric is required")
+	}
+	good, err := nobl9Selector(o.CountMetrics.Good)
+	if err != nil {
+		return indicator, fmt.Errorf("good: %w", err)
+	}
+
+	// Histogram buckets counting good requests below a latency map to a latency indicator.
+	if strings.HasSuffix(good.Name, "_bucket") && strings.HasSuffix(total.Name, "_count") &&
+		strings.TrimSuffix(good.Name, "_bucket") == strings.TrimSuffix(total.Name, "_count") {
+		extra := extraMatchers(good, total)
+		if len(extra) != 1 || extra[0].Name != model.BucketLabel || extra[0].Type != labels.MatchEqual {
+			return indicator, fmt.Errorf("good bucket needs to select the same series as total with an le label")
+		}
+		indicator.Latency = &v1alpha1.LatencyIndicator{
+			Success: v1alpha1.Query{Metric: good.String()},
+			Total:   v1alpha1.Query{Metric: total.String()},
+		}
+		return indicator, nil
+	}
+
+	// Pyrra needs the errors, which are the good requests with the one differing matcher inverted.
+	extra := extraMatchers(good, total)
+	if good.Name != total.Name || len(extra) != 1 {
+		return indicator, fmt.Errorf("good needs to select the same metric as total with one additional label matcher")
+	}
+	inverted := map[labels.MatchType]labels.MatchType{
+		labels.MatchEqual:     labels.MatchNotEqual,
+		labels.MatchNotEqual:  labels.MatchEqual,
+		labels.MatchRegexp:    labels.MatchNotRegexp,
+		labels.MatchNotRegexp: labels.MatchRegexp,
+	}
+	errorsSelector := &parser.VectorSelector{
+		Name: total.Name,
+		LabelMatchers: append(append([]*labels.Matcher{}, total.LabelMatchers...),
+			labels.MustNewMatcher(inverted[extra[0].Type], extra[0].Name, extra[0].Value)),
+	}
+	indicator.Ratio = &v1alpha1.RatioIndicator{
+		Errors: v1alpha1.Query{Metric: errorsSelector.String()},
+		Total:  v1alpha1.Query{Metric: total.String()},
+	}
+	return indicator, nil
+}
+
+// nobl9Selector returns the only selector of a Prometheus count metric.
+// Only queries summing up the rate or increase of a single counter are supported,
+// like sum(rate(http_requests_total{job="api"}[1m])).
+func nobl9Selector(m *nobl9Metric) (*parser.VectorSelector, error) {
+	if m.Prometheus == nil {
+		return nil, fmt.Errorf("only Prometheus metrics are supported")
+	}
+	expr, err := parser.ParseExpr(m.Prometheus.PromQL)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch e := expr.(type) {
+		case *parser.ParenExpr:
+			expr = e.Expr
+			continue
+		case *parser.AggregateExpr:
+			if e.Op != parser.SUM || len(e.Grouping) > 0 {
+				return nil, fmt.Errorf("query %q is not supported, only sum without grouping", m.Prometheus.PromQL)
+			}
+			expr = e.Expr
+			continue
+		case *parser.Call:
+			if e.Func.Name != "rate" && e.Func.Name != "increase" && e.Func.Name != "irate" {
+				return nil, fmt.Errorf("query %q is not supported, function %s is not a counter function", m.Prometheus.PromQL, e.Func.Name)
+			}
+			expr = e.Args[0]
+			continue
+		case *parser.MatrixSelector:
+			expr = e.VectorSelector
+			continue
+		case *parser.VectorSelector:
+			if e.Name == "" {
+				return nil, fmt.Errorf("query %q is not supported, metric name is required", m.Prometheus.PromQL)
+			}
+			matchers := make([]*labels.Matcher, 0, len(e.LabelMatchers))
+			for _, lm := range e.LabelMatchers {
+				if lm.Name != labels.MetricName {
+					matchers = append(matchers, lm)
+				}
+			}
+			return &parser.VectorSelector{Name: e.Name, LabelMatchers: matchers}, nil
+		default:
+			return nil, fmt.Errorf("query %q is not supported", m.Prometheus.PromQL)
+		}
+	}
+}
+
+// extraMatchers returns the matchers of a that b doesn't have.
+// It returns nil if b has matchers a doesn't have.
+func extraMatchers(a, b *parser.VectorSelector) []*labels.Matcher {
+	have := map[string]bool{}
+	for _, m := range a.LabelMatchers {
+		have[m.String()] = true
+	}
+	for _, m := range b.LabelMatchers {
+		if !have[m.String()] {
+			return nil
+		}
+		delete(have, m.String())
+	}
+
+	var extra []*labels.Matcher
+	for _, m := range a.LabelMatchers {
+		if have[m.String()] {
+			extra = append(extra, m)
+		}
+	}
+	return extra
+}
+
+// marshalObjective returns the objective as YAML without the empty status and null fields.
+func marshalObjective(o v1alpha1.ServiceLevelObjective) ([]byte, error) {
+	b, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]any
+	if err := json.Unmarshal(b, &object); err != nil {
+		return nil, err
+	}
+	delete(object, "status")
+	dropNulls(object)
+	return yaml.Marshal(object)
+}
+
+func dropNulls(object map[string]any) {
+	for k, v := range object {
+		switch v := v.(type) {
+		case nil:
+			delete(object, k)
+		case map[string]any:
+			dropNulls(v)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+const nobl9Export = `
+apiVersion: n9/v1alpha
+kind: Service
+metadata:
+  name: api
+  project: default
+---
+apiVersion: n9/v1alpha
+kind: SLO
+metadata:
+  name: api-availability
+  project: default
+  labels:
+    team: [platform]
+    region: [eu, us]
+spec:
+  description: API requests succeed.
+  service: api
+  budgetingMethod: Occurrences
+  timeWindows:
+    - unit: Day
+      count: 28
+      isRolling: true
+  objectives:
+    - name: good
+      target: 0.999
+      countMetrics:
+        incremental: true
+        good:
+          prometheus:
+            promql: sum(rate(http_requests_total{job="api",code!~"5.."}[1m]))
+        total:
+          prometheus:
+            promql: sum(rate(http_requests_total{job="api"}[1m]))
+`
+
+func TestParseNobl9(t *testing.T) {
+	slos, err := parseNobl9([]byte(nobl9Export))
+	require.NoError(t, err)
+	require.Len(t, slos, 1)
+	require.Equal(t, "api-availability", slos[0].Metadata.Name)
+
+	// sloctl exports a list of objects.
+	slos, err = parseNobl9([]byte(`
+- apiVersion: n9/v1alpha
+  kind: SLO
+  metadata:
+    name: foo
+- apiVersion: n9/v1alpha
+  kind: SLO
+  metadata:
+    name: bar
+`))
+	require.NoError(t, err)
+	require.Len(t, slos, 2)
+	require.Equal(t, "bar", slos[1].Metadata.Name)
+}
+
+func TestNobl9Indicator(t *testing.T) {
+	metric := func(promql string) *nobl9Metric {
+		return &nobl9Metric{Prometheus: &nobl9PrometheusQuery{PromQL: promql}}
+	}
+	objective := func(good, bad, total *nobl9Metric) nobl9Objective {
+		return nobl9Objective{CountMetrics: &nobl9CountMetrics{Good: good, Bad: bad, Total: total}}
+	}
+
+	testcases := []struct {
+		name      string
+		objective nobl9Objective
+		indicator v1alpha1.ServiceLevelIndicator
+		err       string
+	}{{
+		name: "good",
+		objective: objective(
+			metric(`sum(rate(http_requests_total{job="api",code!~"5.."}[1m]))`),
+			nil,
+			metric(`sum(rate(http_requests_total{job="api"}[1m]))`),
+		),
+		indicator: v1alpha1.ServiceLevelIndicator{Ratio: &v1alpha1.RatioIndicator{
+			Errors: v1alpha1.Query{Metric: `http_requests_total{code=~"5..",job="api"}`},
+			Total:  v1alpha1.Query{Metric: `http_requests_total{job="api"}`},
+		}},
+	}, {
+		name: "bad",
+		objective: objective(
+			nil,
+			metric(`sum(increase(grpc_server_handled_total{grpc_code="Internal"}[5m]))`),
+			metric(`sum(increase(grpc_server_handled_total[5m]))`),
+		),
+		indicator: v1alpha1.ServiceLevelIndicator{Ratio: &v1alpha1.RatioIndicator{
+			Errors: v1alpha1.Query{Metric: `grpc_server_handled_total{grpc_code="Internal"}`},
+			Total:  v1alpha1.Query{Metric: `grpc_server_handled_total`},
+		}},
+	}, {
+		name: "latency",
+		objective: objective(
+			metric(`sum(rate(http_request_duration_seconds_bucket{job="api",le="0.5"}[1m]))`),
+			nil,
+			metric(`sum(rate(http_request_duration_seconds_count{job="api"}[1m]))`),
+		),
+		indicator: v1alpha1.ServiceLevelIndicator{Latency: &v1alpha1.LatencyIndicator{
+			Success: v1alpha1.Query{Metric: `http_request_duration_seconds_bucket{job="api",le="0.5"}`},
+			Total:   v1alpha1.Query{Metric: `http_request_duration_seconds_count{job="api"}`},
+		}},
+	}, {
+		name: "different metrics",
+		objective: objective(
+			metric(`sum(rate(http_requests_success_total[1m]))`),
+			nil,
+			metric(`sum(rate(http_requests_total[1m]))`),
+		),
+		err: "good needs to select the same metric as total with one additional label matcher",
+	}, {
+		name: "grouping",
+		objective: objective(
+			nil,
+			metric(`sum by (job) (rate(http_requests_total{code=~"5.."}[1m]))`),
+			metric(`sum(rate(http_requests_total[1m]))`),
+		),
+		err: `bad: query "sum by (job) (rate(http_requests_total{code=~\"5..\"}[1m]))" is not supported, only sum without grouping`,
+	}, {
+		name: "gauge",
+		objective: objective(
+			nil,
+			metric(`sum(errors)`),
+			metric(`sum(avg_over_time(requests[1m]))`),
+		),
+		err: `total: query "sum(avg_over_time(requests[1m]))" is not supported, function avg_over_time is not a counter function`,
+	}, {
+		name: "raw metric",
+		err:  "only count metrics are supported",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			indicator, err := nobl9Indicator(tc.objective)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.indicator, indicator)
+		})
+	}
+}
+
+func TestConvertNobl9(t *testing.T) {
+	slos, err := parseNobl9([]byte(nobl9Export))
+	require.NoError(t, err)
+
+	objectives, err := convertNobl9(slos[0], "monitoring")
+	require.NoError(t, err)
+	require.Len(t, objectives, 1)
+	require.Equal(t, "api-availability", objectives[0].Name)
+	require.Equal(t, "monitoring", objectives[0].Namespace)
+	require.Equal(t, map[string]string{"team": "platform"}, objectives[0].Labels)
+	require.Equal(t, "99.9", objectives[0].Spec.Target)
+	require.Equal(t, "4w", objectives[0].Spec.Window)
+
+	s := slos[0]
+	s.Spec.TimeWindows[0].IsRolling = false
+	_, err = convertNobl9(s, "")
+	require.EqualError(t, err, "calendar aligned time windows are not supported")
+
+	s.Spec.BudgetingMethod = "Timeslices"
+	_, err = convertNobl9(s, "")
+	require.EqualError(t, err, "budgeting method Timeslices is not supported, only Occurrences")
+}
+
+func TestCmdImportNobl9(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "slos.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(nobl9Export), 0o644))
+
+	var out bytes.Buffer
+	require.Equal(t, 0, cmdImportNobl9(log.NewNopLogger(), &out, []string{file}, ""))
+	require.Equal(t, `apiVersion: pyrra.dev/v1alpha1
+kind: ServiceLevelObjective
+metadata:
+  labels:
+    team: platform
+  name: api-availability
+spec:
+  alerting: {}
+  description: API requests succeed.
+  indicator:
+    ratio:
+      errors:
+        metric: http_requests_total{code=~"5..",job="api"}
+      total:
+        metric: http_requests_total{job="api"}
+  target: "99.9"
+  window: 4w
+`, out.String())
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	prometheusapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	objectivesv1alpha1 "github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1"
+	"github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1/objectivesv1alpha1connect"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+type NotificationConfig struct {
+	SlackWebhookURL        *url.URL      `help:"Slack incoming webhook URL to notify when the remaining error budget of an objective crosses one of the thresholds."`
+	TeamsWebhookURL        *url.URL      `help:"Microsoft Teams webhook URL to send error budget notifications to as adaptive cards."`
+	WebhookURL             *url.URL      `help:"URL to POST error budget notifications to as generic JSON."`
+	NotificationThresholds []float64     `default:"0.5,0.25,0.1" help:"Remaining error budget ratios, from 0 to 1, to send notifications at."`
+	NotificationInterval   time.Duration `default:"1m" help:"How often the error budgets are checked for notifications."`
+}
+
+// Validate checks the notification thresholds are ratios between 0 and 1 and the interval is positive.
+func (nc *NotificationConfig) Validate() error {
+	for _, t := range nc.NotificationThresholds {
+		if t <= 0 || t >= 1 {
+			return fmt.Errorf("--notification-thresholds must be between 0 and 1, got %v", t)
+		}
+	}
+	if nc.NotificationInterval <= 0 {
+		return fmt.Errorf("--notification-interval must be greater than 0")
+	}
+	return nil
+}
+
+// notifiers returns the configured notifiers, if any.
+func (nc NotificationConfig) notifiers(client *http.Client) []budgetNotifier {
+	var notifiers []budgetNotifier
+	if nc.SlackWebhookURL != nil {
+		notifiers = append(notifiers, &slackNotifier{url: nc.SlackWebhookURL.String(), client: client})
+	}
+	if nc.TeamsWebhookURL != nil {
+		notifiers = append(notifiers, &teamsNotifier{url: nc.TeamsWebhookURL.String(), client: client})
+	}
+	if nc.WebhookURL != nil {
+		notifiers = append(notifiers, &webhookNotifier{url: nc.WebhookURL.String(), client: client})
+	}
+	return notifiers
+}
+
+// budgetEvent is sent when the remaining error budget of an objective crosses a threshold.
+type budgetEvent struct {
+	Objective slo.Objective
+	// Remaining error budget from 1 (untouched) to 0 (exhausted) and below.
+	Remaining float64
+	// Threshold that was crossed.
+	Threshold float64
+	// Recovered is true if the remaining error budget went back above the threshold.
+	Recovered bool
+}
+
+func (e budgetEvent) objectiveName() string {
+	name := e.Objective.Name()
+	if ns := e.Objective.Labels.Get("namespace"); ns != "" {
+		name = ns + "/" + name
+	}
+	return name
+}
+
+func (e budgetEvent) String() string {
+	if e.Recovered {
+		return fmt.Sprintf("Objective %s recovered above %.f%% error budget remaining (%.2f%% remaining)",
+			e.objectiveName(), 100*e.Threshold, 100*e.Remaining)
+	}
+	return fmt.Sprintf("Objective %s has less than %.f%% error budget remaining (%.2f%% remaining)",
+		e.objectiveName(), 100*e.Threshold, 100*e.Remaining)
+}
+
+type budgetQuerier interface {
+	Query(ctx context.Context, query string, ts time.Time) (model.Value, prometheusapiv1.Warnings, error)
+}
+
+type budgetNotifier interface {
+	Notify(ctx context.Context, event budgetEvent) error
+}
+
+// budgetWatcher periodically checks the remaining error budget of all objectives
+// and notifies once per threshold that is crossed, and again when it recovers.
+type budgetWatcher struct {
+	logger     log.Logger
+	client     objectivesv1alpha1connect.ObjectiveBackendServiceClient
+	promAPI    budgetQuerier
+	thresholds []float64
+	notifiers  []budgetNotifier
+
+	// crossed holds, per notifier, how many thresholds each objective had crossed when the notifier was last notified,
+	// so notifiers that failed are notified again without notifying the others twice.
+	crossed []map[string]int
+}
+
+func newBudgetWatcher(
+	logger log.Logger,
+	client objectivesv1alpha1connect.ObjectiveBackendServiceClient,
+	promAPI budgetQuerier,
+	thresholds []float64,
+	notifiers []budgetNotifier,
+) *budgetWatcher {
+	thresholds = append([]float64{}, thresholds...)
+	sort.Sort(sort.Reverse(sort.Float64Slice(thresholds)))
+
+	crossed := make([]map[string]int, len(notifiers))
+	for i := range crossed {
+		crossed[i] = map[string]int{}
+	}
+
+	return &budgetWatcher{
+		logger:     logger,
+		client:     client,
+		promAPI:    promAPI,
+		thresholds: thresholds,
+		notifiers:  notifiers,
+		crossed:    crossed,
+	}
+}
+
+func (w *budgetWatcher) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.check(ctx); err != nil {
+			level.Warn(w.logger).Log("msg", "failed to check error budgets", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *budgetWatcher) check(ctx context.Context) error {
+	resp, err := w.client.List(ctx, connect.NewRequest(&objectivesv1alpha1.ListRequest{}))
+	if err != nil {
+		return fmt.Errorf("failed to list objectives: %w", err)
+	}
+
+	for _, o := range resp.Msg.Objectives {
+		objective := objectivesv1alpha1.ToInternal(o)
+
+		query := objective.QueryErrorBudget()
+		value, _, err := w.promAPI.Query(ctx, query, time.Now())
+		if err != nil {
+			level.Warn(w.logger).Log("msg", "failed to query error budget", "query", query, "err", err)
+			continue
+		}
+		vector, ok := value.(model.Vector)
+		if !ok || len(vector) == 0 {
+			continue
+		}
+
+		w.observe(ctx, objective, float64(vector[0].Value))
+	}
+
+	return nil
+}
+
+// observe compares the remaining error budget to the last observation and sends notifications for changes.
+// Each notifier is compared to the observation it was last notified of,
+// so the ones that failed are notified again on the next observation.
+func (w *budgetWatcher) observe(ctx context.Context, objective slo.Objective, remaining float64) {
+	key := labels.New(objective.Labels...).String()
+
+	crossed := 0
+	for _, t := range w.thresholds {
+		if remaining < t {
+			crossed++
+		}
+	}
+
+	for i, n := range w.notifiers {
+		previous := w.crossed[i][key]
+		if crossed == previous {
+			continue
+		}
+
+		event := budgetEvent{Objective: objective, Remaining: remaining}
+		if crossed > previous {
+			event.Threshold = w.thresholds[crossed-1]
+		} else {
+			event.Threshold = w.thresholds[crossed]
+			event.Recovered = true
+		}
+
+		if err := n.Notify(ctx, event); err != nil {
+			level.Warn(w.logger).Log("msg", "failed to send notification", "objective", key, "err", err)
+			// Try again on the next check.
+			continue
+		}
+		w.crossed[i][key] = crossed
+	}
+}
+
+type slackNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (s *slackNotifier) Notify(ctx context.Context, event budgetEvent) error {
+	emoji := ":warning:"
+	if event.Recovered {
+		emoji = ":white_check_mark:"
+	}
+
+	return postJSON(ctx, s.client, s.url, struct {
+		Text string `json:"text"`
+	}{
+		Text: emoji + " " + event.String(),
+	})
+}
+
+type teamsNotifier struct {
+	url    string
+	client *http.Client
+}
+
+type teamsTextBlock struct {
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	Weight string `json:"weight,omitempty"`
+	Size   string `json:"size,omitempty"`
+	Color  string `json:"color,omitempty"`
+	Wrap   bool   `json:"wrap"`
+}
+
+type teamsFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+// Notify sends the event as adaptive card, which is what Teams' incoming webhooks and workflows accept.
+func (t *teamsNotifier) Notify(ctx context.Context, event budgetEvent) error {
+	title, color := "Error budget below threshold", "attention"
+	if event.Recovered {
+		title, color = "Error budget recovered", "good"
+	}
+
+	facts := []teamsFact{
+		{Title: "Objective", Value: event.objectiveName()},
+		{Title: "Remaining", Value: fmt.Sprintf("%.2f%%", 100*event.Remaining)},
+		{Title: "Threshold", Value: fmt.Sprintf("%.f%%", 100*event.Threshold)},
+	}
+
+	card := map[string]any{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body": []any{
+			teamsTextBlock{Type: "TextBlock", Text: title, Weight: "bolder", Size: "medium", Color: color, Wrap: true},
+			teamsTextBlock{Type: "TextBlock", Text: event.String(), Wrap: true},
+			map[string]any{"type": "FactSet", "facts": facts},
+		},
+	}
+
+	return postJSON(ctx, t.client, t.url, map[string]any{
+		"type": "message",
+		"attachments": []any{map[string]any{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     card,
+		}},
+	})
+}
+
+// webhookPayload is the JSON body sent by the generic webhook notifier.
+type webhookPayload struct {
+	Objective   string            `json:"objective"`
+	Labels      map[string]string `json:"labels"`
+	Description string            `json:"description,omitempty"`
+	Target      float64           `json:"target"`
+	Window      string            `json:"window"`
+	Remaining   float64           `json:"remaining"`
+	Threshold   float64           `json:"threshold"`
+	Recovered   bool              `json:"recovered"`
+	Message     string            `json:"message"`
+}
+
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (w *webhookNotifier) Notify(ctx context.Context, event budgetEvent) error {
+	return postJSON(ctx, w.client, w.url, webhookPayload{
+		Objective:   event.Objective.Name(),
+		Labels:      event.Objective.Labels.Map(),
+		Description: event.Objective.Description,
+		Target:      event.Objective.Target,
+		Window:      event.Objective.Window.String(),
+		Remaining:   event.Remaining,
+		Threshold:   event.Threshold,
+		Recovered:   event.Recovered,
+		Message:     event.String(),
+	})
+}
+
+func postJSON(ctx context.Context, client *http.Client, target string, payload any) error {
+	req, err := newJSONRequest(ctx, http.MethodPost, target, payload)
+	if err != nil {
+		return err
+	}
+	return doJSON(client, req, nil)
+}
+
+// newJSONRequest returns a request with the payload encoded as JSON body, if not nil.
+func newJSONRequest(ctx context.Context, method, target string, payload any) (*http.Request, error) {
+	var body io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// doJSON sends the request and decodes the JSON response into out, if not nil.
+func doJSON(client *http.Client, req *http.Request, out any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+type recordingNotifier struct {
+	events []budgetEvent
+	err    error
+}
+
+func (r *recordingNotifier) Notify(_ context.Context, event budgetEvent) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.events = append(r.events, event)
+	return nil
+}
+
+func TestBudgetWatcher_Observe(t *testing.T) {
+	objective := slo.Objective{Labels: labels.FromStrings(labels.MetricName, "foo", "namespace", "bar")}
+
+	notifier := &recordingNotifier{}
+	watcher := newBudgetWatcher(log.NewNopLogger(), nil, nil, []float64{0.1, 0.5, 0.25}, []budgetNotifier{notifier})
+
+	type event struct {
+		threshold float64
+		recovered bool
+	}
+	for _, step := range []struct {
+		remaining float64
+		event     *event
+	}{
+		{remaining: 0.9},
+		{remaining: 0.4, event: &event{threshold: 0.5}},
+		{remaining: 0.45},
+		{remaining: 0.05, event: &event{threshold: 0.1}},
+		{remaining: -0.2},
+		{remaining: 0.3, event: &event{threshold: 0.25, recovered: true}},
+		{remaining: 0.6, event: &event{threshold: 0.5, recovered: true}},
+		{remaining: 0.7},
+	} {
+		notifier.events = nil
+		watcher.observe(context.Background(), objective, step.remaining)
+
+		if step.event == nil {
+			require.Empty(t, notifier.events, "remaining %v", step.remaining)
+			continue
+		}
+		require.Len(t, notifier.events, 1, "remaining %v", step.remaining)
+		require.Equal(t, step.event.threshold, notifier.events[0].Threshold)
+		require.Equal(t, step.event.recovered, notifier.events[0].Recovered)
+		require.Equal(t, step.remaining, notifier.events[0].Remaining)
+	}
+}
+
+func TestBudgetWatcher_ObserveRetry(t *testing.T) {
+	objective := slo.Objective{Labels: labels.FromStrings(labels.MetricName, "foo")}
+
+	notifier := &recordingNotifier{err: context.DeadlineExceeded}
+	watcher := newBudgetWatcher(log.NewNopLogger(), nil, nil, []float64{0.5}, []budgetNotifier{notifier})
+
+	watcher.observe(context.Background(), objective, 0.3)
+	require.Empty(t, notifier.events)
+
+	// The failed notification is sent again with the next observation.
+	notifier.err = nil
+	watcher.observe(context.Background(), objective, 0.3)
+	require.Len(t, notifier.events, 1)
+}
+
+func TestBudgetWatcher_ObserveRetryFailedNotifier(t *testing.T) {
+	objective := slo.Objective{Labels: labels.FromStrings(labels.MetricName, "foo")}
+
+	failing := &recordingNotifier{err: context.DeadlineExceeded}
+	succeeding := &recordingNotifier{}
+	watcher := newBudgetWatcher(log.NewNopLogger(), nil, nil, []float64{0.5}, []budgetNotifier{failing, succeeding})
+
+	watcher.observe(context.Background(), objective, 0.3)
+	require.Empty(t, failing.events)
+	require.Len(t, succeeding.events, 1)
+
+	// Only the failed notifier is notified again, the other one isn't sent the same crossing twice.
+	watcher.observe(context.Background(), objective, 0.3)
+	require.Len(t, succeeding.events, 1)
+
+	failing.err = nil
+	watcher.observe(context.Background(), objective, 0.3)
+	require.Len(t, failing.events, 1)
+	require.Equal(t, 0.5, failing.events[0].Threshold)
+	require.Len(t, succeeding.events, 1)
+
+	watcher.observe(context.Background(), objective, 0.3)
+	require.Len(t, failing.events, 1)
+}
+
+func TestSlackNotifier(t *testing.T) {
+	var payload map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+	}))
+	defer server.Close()
+
+	notifier := &slackNotifier{url: server.URL, client: server.Client()}
+	objective := slo.Objective{Labels: labels.FromStrings(labels.MetricName, "foo", "namespace", "bar")}
+
+	err := notifier.Notify(context.Background(), budgetEvent{Objective: objective, Remaining: 0.2, Threshold: 0.25})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"text": ":warning: Objective bar/foo has less than 25% error budget remaining (20.00% remaining)",
+	}, payload)
+
+	err = notifier.Notify(context.Background(), budgetEvent{Objective: objective, Remaining: 0.6, Threshold: 0.5, Recovered: true})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"text": ":white_check_mark: Objective bar/foo recovered above 50% error budget remaining (60.00% remaining)",
+	}, payload)
+}
+
+func TestNotificationConfig_Validate(t *testing.T) {
+	nc := NotificationConfig{NotificationThresholds: []float64{0.5, 0.1}, NotificationInterval: 1}
+	require.NoError(t, nc.Validate())
+
+	nc.NotificationThresholds = []float64{50}
+	require.EqualError(t, nc.Validate(), "--notification-thresholds must be between 0 and 1, got 50")
+
+	nc.NotificationThresholds = nil
+	nc.NotificationInterval = 0
+	require.EqualError(t, nc.Validate(), "--notification-interval must be greater than 0")
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var payload webhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+	}))
+	defer server.Close()
+
+	notifier := &webhookNotifier{url: server.URL, client: server.Client()}
+	objective := slo.Objective{
+		Labels:      labels.FromStrings(labels.MetricName, "foo", "namespace", "bar"),
+		Description: "Foo is fast.",
+		Target:      0.99,
+		Window:      model.Duration(28 * 24 * time.Hour),
+	}
+
+	err := notifier.Notify(context.Background(), budgetEvent{Objective: objective, Remaining: 0.2, Threshold: 0.25})
+	require.NoError(t, err)
+	require.Equal(t, webhookPayload{
+		Objective:   "foo",
+		Labels:      map[string]string{"__name__": "foo", "namespace": "bar"},
+		Description: "Foo is fast.",
+		Target:      0.99,
+		Window:      "4w",
+		Remaining:   0.2,
+		Threshold:   0.25,
+		Message:     "Objective bar/foo has less than 25% error budget remaining (20.00% remaining)",
+	}, payload)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "nope", http.StatusBadRequest)
+	}))
+	defer failing.Close()
+
+	notifier = &webhookNotifier{url: failing.URL, client: failing.Client()}
+	err = notifier.Notify(context.Background(), budgetEvent{Objective: objective})
+	require.EqualError(t, err, "unexpected status 400 Bad Request: nope")
+}
+
+func TestTeamsNotifier(t *testing.T) {
+	var payload struct {
+		Type        string `json:"type"`
+		Attachments []struct {
+			ContentType string `json:"contentType"`
+			Content     struct {
+				Type string `json:"type"`
+				Body []struct {
+					Type  string `json:"type"`
+					Text  string `json:"text"`
+					Color string `json:"color"`
+					Facts []struct {
+						Title string `json:"title"`
+						Value string `json:"value"`
+					} `json:"facts"`
+				} `json:"body"`
+			} `json:"content"`
+		} `json:"attachments"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+	}))
+	defer server.Close()
+
+	notifier := &teamsNotifier{url: server.URL, client: server.Client()}
+	objective := slo.Objective{Labels: labels.FromStrings(labels.MetricName, "foo", "namespace", "bar")}
+
+	err := notifier.Notify(context.Background(), budgetEvent{Objective: objective, Remaining: 0.6, Threshold: 0.5, Recovered: true})
+	require.NoError(t, err)
+
+	require.Equal(t, "message", payload.Type)
+	require.Len(t, payload.Attachments, 1)
+	require.Equal(t, "application/vnd.microsoft.card.adaptive", payload.Attachments[0].ContentType)
+
+	card := payload.Attachments[0].Content
+	require.Equal(t, "AdaptiveCard", card.Type)
+	require.Len(t, card.Body, 3)
+	require.Equal(t, "Error budget recovered", card.Body[0].Text)
+	require.Equal(t, "good", card.Body[0].Color)
+	require.Equal(t, "Objective bar/foo recovered above 50% error budget remaining (60.00% remaining)", card.Body[1].Text)
+	require.Equal(t, "FactSet", card.Body[2].Type)
+	require.Len(t, card.Body[2].Facts, 3)
+	require.Equal(t, "60.00%", card.Body[2].Facts[1].Value)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	otelprometheus "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+type OTLPConfig struct {
+	OTLPEndpoint *url.URL          `name:"otlp-endpoint" help:"The URL of an OpenTelemetry collector, like http://otel-collector:4318. If set Pyrra's internal metrics are pushed to it via OTLP/HTTP in addition to being served on /metrics."`
+	OTLPHeaders  map[string]string `name:"otlp-headers" help:"Headers sent with every OTLP request, like for authentication."`
+	OTLPInterval time.Duration     `name:"otlp-interval" default:"30s" help:"How often the metrics are pushed via OTLP."`
+}
+
+// Validate checks the OTLP endpoint is an HTTP URL and the interval is positive.
+func (oc *OTLPConfig) Validate() error {
+	if oc.OTLPEndpoint == nil {
+		return nil
+	}
+	if oc.OTLPEndpoint.Scheme != "http" && oc.OTLPEndpoint.Scheme != "https" {
+		return fmt.Errorf("--otlp-endpoint must be an http or https URL")
+	}
+	if oc.OTLPInterval <= 0 {
+		return fmt.Errorf("--otlp-interval must be greater than 0")
+	}
+	return nil
+}
+
+// startOTLPExport periodically pushes the metrics gathered from the Prometheus registry to the OTLP endpoint.
+// The returned function flushes the remaining metrics and stops the export.
+func startOTLPExport(ctx context.Context, config OTLPConfig, gatherer prometheus.Gatherer) (func(context.Context) error, error) {
+	if config.OTLPEndpoint == nil {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpointURL(config.OTLPEndpoint.String())}
+	if len(config.OTLPHeaders) > 0 {
+		opts = append(opts, otlpmetrichttp.WithHeaders(config.OTLPHeaders))
+	}
+	exporter, err := otlpmetrichttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	provider := metric.NewMeterProvider(
+		metric.WithResource(resource.NewSchemaless(attribute.String("service.name", "pyrra"))),
+		metric.WithReader(metric.NewPeriodicReader(exporter,
+			metric.WithInterval(config.OTLPInterval),
+			metric.WithProducer(otelprometheus.NewMetricProducer(otelprometheus.WithGatherer(gatherer))),
+		)),
+	)
+
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestStartOTLPExport(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		metrics []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/metrics", r.URL.Path)
+		require.Equal(t, "secret", r.Header.Get("X-Scope-OrgID"))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var req collectormetrics.ExportMetricsServiceRequest
+		require.NoError(t, proto.Unmarshal(body, &req))
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rm := range req.GetResourceMetrics() {
+			for _, sm := range rm.GetScopeMetrics() {
+				for _, m := range sm.GetMetrics() {
+					metrics = append(metrics, m.GetName())
+				}
+			}
+		}
+	}))
+	defer server.Close()
+
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "pyrra_test_total"})
+	reg.MustRegister(counter)
+	counter.Inc()
+
+	endpoint, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	shutdown, err := startOTLPExport(context.Background(), OTLPConfig{
+		OTLPEndpoint: endpoint,
+		OTLPHeaders:  map[string]string{"X-Scope-OrgID": "secret"},
+		OTLPInterval: time.Hour,
+	}, reg)
+	require.NoError(t, err)
+
+	// Shutting down flushes the metrics without waiting for the interval.
+	require.NoError(t, shutdown(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Contains(t, metrics, "pyrra_test_total")
+}
+
+func TestStartOTLPExport_Disabled(t *testing.T) {
+	shutdown, err := startOTLPExport(context.Background(), OTLPConfig{}, prometheus.NewRegistry())
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+}
+
+func TestOTLPConfig_Validate(t *testing.T) {
+	require.NoError(t, (&OTLPConfig{}).Validate())
+
+	endpoint, _ := url.Parse("grpc://collector:4317")
+	require.EqualError(t, (&OTLPConfig{OTLPEndpoint: endpoint, OTLPInterval: time.Minute}).Validate(), "--otlp-endpoint must be an http or https URL")
+
+	endpoint, _ = url.Parse("http://collector:4318")
+	require.NoError(t, (&OTLPConfig{OTLPEndpoint: endpoint, OTLPInterval: time.Minute}).Validate())
+	require.EqualError(t, (&OTLPConfig{OTLPEndpoint: endpoint}).Validate(), "--otlp-interval must be greater than 0")
+}
@@ -1,6 +1,7 @@
 package objectivesv1alpha1
 
 import (
+	"strconv"
 	"strings"
 	"time"
 
@@ -8,6 +9,7 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/util/strutil"
 	"google.golang.org/protobuf/types/known/durationpb"
+	"sigs.k8s.io/yaml"
 
 	"github.com/pyrra-dev/pyrra/slo"
 )
@@ -107,16 +109,111 @@ func ToInternal(o *Objective) slo.Objective {
 		Target:      o.Target,
 		Window:      model.Duration(o.Window.AsDuration()),
 		Config:      o.Config,
-		Alerting:    slo.Alerting{}, // TODO
+		Alerting:    slo.Alerting{Windows: alertingWindows(o.Config)}, // TODO
 		Indicator: slo.Indicator{
 			Ratio:         ratio,
 			Latency:       latency,
 			LatencyNative: latencyNative,
 			BoolGauge:     boolGauge,
 		},
+		StableRuleNames: stableRuleNames(o.Config),
+		Owner:           ownerToInternal(o.GetOwner()),
 	}
 }
 
+func ownerToInternal(o *Owner) *slo.Owner {
+	if o == nil {
+		return nil
+	}
+	return &slo.Owner{
+		Team:             o.GetTeam(),
+		Slack:            o.GetSlack(),
+		EscalationPolicy: o.GetEscalationPolicy(),
+		RunbookURL:       o.GetRunbookUrl(),
+		Tier:             o.GetTier(),
+	}
+}
+
+func ownerFromInternal(o *slo.Owner) *Owner {
+	if o == nil {
+		return nil
+	}
+	return &Owner{
+		Team:             o.Team,
+		Slack:            o.Slack,
+		EscalationPolicy: o.EscalationPolicy,
+		RunbookUrl:       o.RunbookURL,
+		Tier:             o.Tier,
+	}
+}
+
+// stableRuleNames returns true if the objective's config enables stable rule names,
+// so the queries use the names of its recording rules.
+func stableRuleNames(config string) bool {
+	var c struct {
+		Spec struct {
+			StableRuleNames bool `json:"stableRuleNames"`
+		} `json:"spec"`
+	}
+	if err := yaml.Unmarshal([]byte(config), &c); err != nil {
+		return false
+	}
+	return c.Spec.StableRuleNames
+}
+
+// alertingWindows returns the alerting windows of the objective's config, if it overrides the default ones,
+// so alerts with custom severities can be matched to the objective. The config was validated already.
+func alertingWindows(config string) []slo.Window {
+	var c struct {
+		Spec struct {
+			Alerting struct {
+				Windows []struct {
+					Severity string `json:"severity"`
+					Short    string `json:"short"`
+					Long     string `json:"long"`
+					Factor   string `json:"factor"`
+					For      string `json:"for"`
+				} `json:"windows"`
+			} `json:"alerting"`
+		} `json:"spec"`
+	}
+	if err := yaml.Unmarshal([]byte(config), &c); err != nil {
+		return nil
+	}
+
+	var windows []slo.Window
+	for _, w := range c.Spec.Alerting.Windows {
+		short, err := model.ParseDuration(w.Short)
+		if err != nil {
+			return nil
+		}
+		long, err := model.ParseDuration(w.Long)
+		if err != nil {
+			return nil
+		}
+		factor, err := strconv.ParseFloat(w.Factor, 64)
+		if err != nil {
+			return nil
+		}
+		forDuration := time.Duration(short) / 2
+		if w.For != "" {
+			f, err := model.ParseDuration(w.For)
+			if err != nil {
+				return nil
+			}
+			forDuration = time.Duration(f)
+		}
+		windows = append(windows, slo.Window{
+			Severity: slo.Severity(w.Severity),
+			For:      forDuration,
+			Long:     time.Duration(long),
+			Short:    time.Duration(short),
+			Factor:   factor,
+		})
+	}
+	return windows
+}
+
 func FromInternal(o slo.Objective) *Objective {
 	var ratio *Ratio
 	if r := o.Indicator.Ratio; r != nil {
@@ -225,6 +322,7 @@ func FromInternal(o slo.Objective) *Objective {
 		Window:      durationpb.New(time.Duration(o.Window)),
 		Description: o.Description,
 		Config:      o.Config,
+		Owner:       ownerFromInternal(o.Owner),
 	}
 	if ratio != nil {
 		objective.Indicator = &Indicator{
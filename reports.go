@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	// Embed the time zone database, as the container image doesn't ship it.
+	_ "time/tzdata"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/robfig/cron/v3"
+
+	objectivesv1alpha1 "github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1"
+	"github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1/objectivesv1alpha1connect"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+type ReportConfig struct {
+	ReportSchedule     string            `help:"Cron schedule to send SLO reports on, like '0 9 * * 1' for Mondays at 9:00. Reports are disabled if empty. Emails are plain text tables without charts, the webhook's JSON can be charted by the receiver."`
+	ReportPeriod       time.Duration     `default:"168h" help:"The period reports summarize, like 168h for the past week or 720h for the past month."`
+	ReportCalendar     string            `default:"" help:"Align report periods to the calendar instead of --report-period, either week or month. Reports then cover the previous calendar week, starting Monday, or month."`
+	ReportTimezone     string            `default:"UTC" help:"The time zone of the report schedule and calendar periods, like Europe/Berlin, so reports align with local business time."`
+	ReportTeamLabel    string            `default:"pyrra.dev/team" help:"The objective label to group reports by team. One report is sent per team."`
+	ReportWebhookURL   *url.URL          `help:"URL to POST the reports to as JSON."`
+	ReportSMTPAddr     string            `name:"report-smtp-addr" help:"SMTP server, like smtp.example.com:587, to send the reports as email with."`
+	ReportSMTPUsername string            `name:"report-smtp-username" help:"Username to authenticate against the SMTP server with."`
+	ReportSMTPPassword string            `name:"report-smtp-password" env:"PYRRA_REPORT_SMTP_PASSWORD" help:"Password to authenticate against the SMTP server with."`
+	ReportEmailFrom    string            `help:"Sender address of report emails."`
+	ReportEmailTo      []string          `help:"Recipient addresses of report emails of teams without recipients in --report-email-teams."`
+	ReportEmailTeams   map[string]string `name:"report-email-teams" default:"" help:"Recipient addresses of the report emails of teams, by the value of their --report-team-label, like payments=payments@example.com,sre@example.com;checkout=checkout@example.com. Separate teams with ; and addresses with a comma."`
+}
+
+// Validate checks scheduled reports have a valid schedule, period and time zone, and somewhere to be sent to.
+func (rc *ReportConfig) Validate() error {
+	if rc.ReportSchedule == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(rc.ReportTimezone); err != nil {
+		return fmt.Errorf("--report-timezone is invalid: %w", err)
+	}
+	if _, err := rc.schedule(); err != nil {
+		return fmt.Errorf("--report-schedule is invalid: %w", err)
+	}
+	if rc.ReportPeriod <= 0 {
+		return fmt.Errorf("--report-period must be greater than 0")
+	}
+	if rc.ReportCalendar != "" && rc.ReportCalendar != calendarWeek && rc.ReportCalendar != calendarMonth {
+		return fmt.Errorf("--report-calendar must be week or month")
+	}
+	if rc.ReportWebhookURL == nil && rc.ReportSMTPAddr == "" {
+		return fmt.Errorf("--report-schedule requires --report-webhook-url or --report-smtp-addr")
+	}
+	if rc.ReportSMTPAddr != "" && (rc.ReportEmailFrom == "" || (len(rc.ReportEmailTo) == 0 && len(rc.ReportEmailTeams) == 0)) {
+		return fmt.Errorf("--report-smtp-addr requires --report-email-from and --report-email-to or --report-email-teams")
+	}
+	for team, to := range rc.ReportEmailTeams {
+		if len(emailAddresses(to)) == 0 {
+			return fmt.Errorf("--report-email-teams has no addresses for team %q", team)
+		}
+	}
+	return nil
+}
+
+const (
+	calendarWeek  = "week"
+	calendarMonth = "month"
+)
+
+// schedule parses the report schedule in the report time zone, unless the schedule sets its own with CRON_TZ=.
+func (rc ReportConfig) schedule() (cron.Schedule, error) {
+	spec := rc.ReportSchedule
+	if rc.ReportTimezone != "" && !strings.HasPrefix(spec, "CRON_TZ=") && !strings.HasPrefix(spec, "TZ=") {
+		spec = fmt.Sprintf("CRON_TZ=%s %s", rc.ReportTimezone, spec)
+	}
+	return cron.ParseStandard(spec)
+}
+
+// senders returns the configured report senders, if reports are scheduled.
+func (rc ReportConfig) senders(client *http.Client) []reportSender {
+	if rc.ReportSchedule == "" {
+		return nil
+	}
+
+	var senders []reportSender
+	if rc.ReportWebhookURL != nil {
+		senders = append(senders, &webhookReportSender{url: rc.ReportWebhookURL.String(), client: client})
+	}
+	if rc.ReportSMTPAddr != "" {
+		var auth smtp.Auth
+		if rc.ReportSMTPUsername != "" {
+			host, _, _ := net.SplitHostPort(rc.ReportSMTPAddr)
+			auth = smtp.PlainAuth("", rc.ReportSMTPUsername, rc.ReportSMTPPassword, host)
+		}
+		teams := make(map[string][]string, len(rc.ReportEmailTeams))
+		for team, to := range rc.ReportEmailTeams {
+			teams[team] = emailAddresses(to)
+		}
+		senders = append(senders, &emailReportSender{
+			addr:     rc.ReportSMTPAddr,
+			auth:     auth,
+			from:     rc.ReportEmailFrom,
+			to:       rc.ReportEmailTo,
+			teams:    teams,
+			sendMail: smtp.SendMail,
+		})
+	}
+	return senders
+}
+
+// teamReport summarizes the objectives of a team over a period.
+type teamReport struct {
+	Team       string            `json:"team"`
+	From       time.Time         `json:"from"`
+	To         time.Time         `json:"to"`
+	Objectives []objectiveReport `json:"objectives"`
+}
+
+type objectiveReport struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+	Target float64           `json:"target"`
+	// Availability during the report's period.
+	Availability float64 `json:"availability"`
+	// BudgetConsumed is the ratio of the error budget consumed during the report's period.
+	// It is greater than 1 if more errors than budgeted happened.
+	BudgetConsumed float64 `json:"budgetConsumed"`
+	// Alerts is the number of distinct burn rate alerts that fired during the report's period.
+	Alerts int `json:"alerts"`
+	// SLA is reported next to the objective, if it declares one.
+	SLA *slaReport `json:"sla,omitempty"`
+}
+
+type slaReport struct {
+	Target float64 `json:"target"`
+	// BudgetConsumed is the ratio of the SLA's error budget consumed during the report's period.
+	BudgetConsumed float64 `json:"budgetConsumed"`
+	// Met is true if the availability during the report's period kept the SLA target.
+	Met bool `json:"met"`
+}
+
+func (r teamReport) Subject() string {
+	team := r.Team
+	if team == "" {
+		team = "unassigned objectives"
+	}
+	return fmt.Sprintf("SLO report for %s from %s to %s", team, r.From.Format(time.DateOnly), r.To.Format(time.DateOnly))
+}
+
+// Text renders the report as plain text table.
+func (r teamReport) Text() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s\n\n", r.Subject())
+
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Objective\tTarget\tAvailability\tBudget consumed\tAlerts\tSLA")
+	for _, o := range r.Objectives {
+		sla := "-"
+		if o.SLA != nil {
+			status := "met"
+			if !o.SLA.Met {
+				status = "missed"
+			}
+			sla = fmt.Sprintf("%.3f%% %s", 100*o.SLA.Target, status)
+		}
+		fmt.Fprintf(w, "%s\t%.3f%%\t%.3f%%\t%.1f%%\t%d\t%s\n", o.Name, 100*o.Target, 100*o.Availability, 100*o.BudgetConsumed, o.Alerts, sla)
+	}
+	_ = w.Flush()
+
+	return buf.String()
+}
+
+type reportSender interface {
+	Send(ctx context.Context, report teamReport) error
+}
+
+// reporter generates a report per team on a schedule and sends them.
+type reporter struct {
+	logger   log.Logger
+	client   objectivesv1alpha1connect.ObjectiveBackendServiceClient
+	promAPI  budgetQuerier
+	schedule cron.Schedule
+	period   time.Duration
+	// calendar aligns the periods to calendar weeks or months in the location instead, if set.
+	calendar  string
+	location  *time.Location
+	teamLabel string
+	senders   []reportSender
+}
+
+func (r *reporter) Run(ctx context.Context) error {
+	for {
+		now := time.Now()
+		next := r.schedule.Next(now)
+		level.Debug(r.logger).Log("msg", "next report scheduled", "at", next)
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		reports, err := r.generate(ctx, next)
+		if err != nil {
+			level.Warn(r.logger).Log("msg", "failed to generate reports", "err", err)
+			continue
+		}
+		for _, report := range reports {
+			for _, s := range r.senders {
+				if err := s.Send(ctx, report); err != nil {
+					level.Warn(r.logger).Log("msg", "failed to send report", "team", report.Team, "err", err)
+				}
+			}
+		}
+	}
+}
+
+func (r *reporter) generate(ctx context.Context, ts time.Time) ([]teamReport, error) {
+	resp, err := r.client.List(ctx, connect.NewRequest(&objectivesv1alpha1.ListRequest{}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objectives: %w", err)
+	}
+
+	from, to := r.reportPeriod(ts)
+
+	teams := map[string]*teamReport{}
+	for _, o := range resp.Msg.Objectives {
+		objective := objectivesv1alpha1.ToInternal(o)
+
+		report, err := r.objectiveReport(ctx, objective, to, to.Sub(from))
+		if err != nil {
+			level.Warn(r.logger).Log("msg", "failed to report objective", "objective", objective.Name(), "err", err)
+			continue
+		}
+
+		team := objective.Labels.Get(apiLabelName(r.teamLabel))
+		if _, ok := teams[team]; !ok {
+			teams[team] = &teamReport{Team: team, From: from, To: to}
+		}
+		teams[team].Objectives = append(teams[team].Objectives, report)
+	}
+
+	reports := make([]teamReport, 0, len(teams))
+	for _, t := range teams {
+		sort.Slice(t.Objectives, func(i, j int) bool {
+			return t.Objectives[i].Name < t.Objectives[j].Name
+		})
+		reports = append(reports, *t)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Team < reports[j].Team
+	})
+
+	return reports, nil
+}
+
+// reportPeriod returns the period a report generated at ts covers.
+// Calendar periods are the last full week or month before ts in the report's location, other periods end at ts.
+func (r *reporter) reportPeriod(ts time.Time) (time.Time, time.Time) {
+	location := r.location
+	if location == nil {
+		location = time.UTC
+	}
+	ts = ts.In(location)
+
+	switch r.calendar {
+	case calendarWeek:
+		// Weeks start on Monday.
+		daysSinceMonday := (int(ts.Weekday()) + 6) % 7
+		to := time.Date(ts.Year(), ts.Month(), ts.Day()-daysSinceMonday, 0, 0, 0, 0, location)
+		return to.AddDate(0, 0, -7), to
+	case calendarMonth:
+		to := time.Date(ts.Year(), ts.Month(), 1, 0, 0, 0, 0, location)
+		return to.AddDate(0, -1, 0), to
+	default:
+		return ts.Add(-r.period), ts
+	}
+}
+
+func (r *reporter) objectiveReport(ctx context.Context, objective slo.Objective, ts time.Time, period time.Duration) (objectiveReport, error) {
+	report := objectiveReport{
+		Name:   objective.Name(),
+		Labels: objective.Labels.Map(),
+		Target: objective.Target,
+	}
+
+	// Reports cover the objective as a whole, not its individual groups.
+	switch objective.IndicatorType() {
+	case slo.Ratio:
+		objective.Indicator.Ratio.Grouping = nil
+	case slo.Latency:
+		objective.Indicator.Latency.Grouping = nil
+	case slo.LatencyNative:
+		objective.Indicator.LatencyNative.Grouping = nil
+	case slo.BoolGauge:
+		objective.Indicator.BoolGauge.Grouping = nil
+	case slo.Expression:
+		objective.Indicator.Expression.Grouping = nil
+	case slo.Logs:
+		objective.Indicator.Logs.Grouping = nil
+	}
+
+	errorRatio, err := r.querySingle(ctx, objective.Burnrate(period), ts)
+	if err != nil {
+		return report, err
+	}
+	report.Availability = 1 - errorRatio
+	if budget := 1 - objective.Target; budget > 0 {
+		report.BudgetConsumed = errorRatio / budget
+	}
+
+	alerts, err := r.querySingle(ctx, fmt.Sprintf(
+		`count(count_over_time(ALERTS{alertname=%q,alertstate="firing",slo=%q}[%s]))`,
+		objective.AlertName(), objective.Name(), model.Duration(period),
+	), ts)
+	if err != nil {
+		return report, err
+	}
+	report.Alerts = int(alerts)
+
+	// The SLA target isn't part of the API, it's looked up from the recorded rules instead.
+	value, _, err := r.promAPI.Query(ctx, fmt.Sprintf(`pyrra_sla_target{slo=%q}`, objective.Name()), ts)
+	if err != nil {
+		return report, err
+	}
+	if vector, ok := value.(model.Vector); ok && len(vector) > 0 {
+		sla := &slaReport{Target: float64(vector[0].Value)}
+		sla.Met = report.Availability >= sla.Target
+		if budget := 1 - sla.Target; budget > 0 {
+			sla.BudgetConsumed = errorRatio / budget
+		}
+		report.SLA = sla
+	}
+
+	return report, nil
+}
+
+// querySingle returns the value of the first sample or 0 if the query returned no samples.
+func (r *reporter) querySingle(ctx context.Context, query string, ts time.Time) (float64, error) {
+	value, _, err := r.promAPI.Query(ctx, query, ts)
+	if err != nil {
+		return 0, err
+	}
+	vector, ok := value.(model.Vector)
+	if !ok {
+		return 0, fmt.Errorf("unexpected value type %s", value.Type())
+	}
+	if len(vector) == 0 {
+		return 0, nil
+	}
+	return float64(vector[0].Value), nil
+}
+
+type webhookReportSender struct {
+	url    string
+	client *http.Client
+}
+
+func (w *webhookReportSender) Send(ctx context.Context, report teamReport) error {
+	return postJSON(ctx, w.client, w.url, report)
+}
+
+// emailAddresses returns the comma separated addresses.
+func emailAddresses(s string) []string {
+	var addresses []string
+	for _, a := range strings.Split(s, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			addresses = append(addresses, a)
+		}
+	}
+	return addresses
+}
+
+type emailReportSender struct {
+	addr string
+	auth smtp.Auth
+	from string
+	// to receives the reports of teams without recipients in teams.
+	to []string
+	// teams are the recipients of the reports by team.
+	teams    map[string][]string
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func (e *emailReportSender) Send(_ context.Context, report teamReport) error {
+	to, ok := e.teams[report.Team]
+	if !ok {
+		to = e.to
+	}
+	// Teams are only sent their own reports without a default recipient.
+	if len(to) == 0 {
+		return nil
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", report.Subject())
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(report.Text(), "\n", "\r\n"))
+
+	return e.sendMail(e.addr, e.auth, e.from, to, msg.Bytes())
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/go-kit/log"
+	prometheusapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	objectivesv1alpha1 "github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+type staticBackend struct {
+	objectives []slo.Objective
+}
+
+func (b staticBackend) List(context.Context, *connect.Request[objectivesv1alpha1.ListRequest]) (*connect.Response[objectivesv1alpha1.ListResponse], error) {
+	resp := &objectivesv1alpha1.ListResponse{}
+	for _, o := range b.objectives {
+		resp.Objectives = append(resp.Objectives, objectivesv1alpha1.FromInternal(o))
+	}
+	return connect.NewResponse(resp), nil
+}
+
+type queryFunc func(query string) model.Value
+
+func (f queryFunc) Query(_ context.Context, query string, _ time.Time) (model.Value, prometheusapiv1.Warnings, error) {
+	return f(query), nil, nil
+}
+
+func reportObjective(name, team string) slo.Objective {
+	return slo.Objective{
+		Labels: labels.FromStrings(labels.MetricName, name, "pyrra.dev/team", team),
+		Target: 0.99,
+		Window: model.Duration(28 * 24 * time.Hour),
+		Indicator: slo.Indicator{
+			Ratio: &slo.RatioIndicator{
+				Errors: slo.Metric{
+					Name: "http_requests_total",
+					LabelMatchers: []*labels.Matcher{
+						{Type: labels.MatchEqual, Name: "job", Value: name},
+						{Type: labels.MatchRegexp, Name: "code", Value: "5.."},
+						{Type: labels.MatchEqual, Name: labels.MetricName, Value: "http_requests_total"},
+					},
+				},
+				Total: slo.Metric{
+					Name: "http_requests_total",
+					LabelMatchers: []*labels.Matcher{
+						{Type: labels.MatchEqual, Name: "job", Value: name},
+						{Type: labels.MatchEqual, Name: labels.MetricName, Value: "http_requests_total"},
+					},
+				},
+				Grouping: []string{"handler"},
+			},
+		},
+	}
+}
+
+func TestReporter_Generate(t *testing.T) {
+	var queries []string
+	r := &reporter{
+		logger: log.NewNopLogger(),
+		client: staticBackend{objectives: []slo.Objective{
+			reportObjective("web", "frontend"),
+			reportObjective("api", "backend"),
+			reportObjective("checkout", "frontend"),
+		}},
+		promAPI: queryFunc(func(query string) model.Value {
+			queries = append(queries, query)
+			if strings.HasPrefix(query, "count(") {
+				return model.Vector{{Value: 3}}
+			}
+			if strings.HasPrefix(query, "pyrra_sla_target") {
+				if query == `pyrra_sla_target{slo="api"}` {
+					return model.Vector{{Value: 0.99}}
+				}
+				return model.Vector{}
+			}
+			return model.Vector{{Value: 0.005}}
+		}),
+		period:    7 * 24 * time.Hour,
+		teamLabel: "pyrra.dev/team",
+	}
+
+	ts := time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC)
+	reports, err := r.generate(context.Background(), ts)
+	require.NoError(t, err)
+
+	require.Len(t, reports, 2)
+	require.Equal(t, "backend", reports[0].Team)
+	require.Equal(t, "frontend", reports[1].Team)
+	require.Equal(t, ts.Add(-7*24*time.Hour), reports[1].From)
+	require.Equal(t, ts, reports[1].To)
+	require.Len(t, reports[1].Objectives, 2)
+	require.Equal(t, "checkout", reports[1].Objectives[0].Name)
+	require.Equal(t, "web", reports[1].Objectives[1].Name)
+
+	o := reports[0].Objectives[0]
+	require.Equal(t, "api", o.Name)
+	require.InDelta(t, 0.995, o.Availability, 1e-9)
+	require.InDelta(t, 0.5, o.BudgetConsumed, 1e-9)
+	require.Equal(t, 3, o.Alerts)
+	require.NotNil(t, o.SLA)
+	require.InDelta(t, 0.99, o.SLA.Target, 1e-9)
+	require.InDelta(t, 0.5, o.SLA.BudgetConsumed, 1e-9)
+	require.True(t, o.SLA.Met)
+	require.Nil(t, reports[1].Objectives[0].SLA)
+
+	require.Contains(t, queries, `sum(rate(http_requests_total{code=~"5..",job="web"}[1w])) / sum(rate(http_requests_total{job="web"}[1w]))`)
+	require.Contains(t, queries, `count(count_over_time(ALERTS{alertname="ErrorBudgetBurn",alertstate="firing",slo="web"}[1w]))`)
+}
+
+func TestTeamReport_Text(t *testing.T) {
+	report := teamReport{
+		Team: "frontend",
+		From: time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC),
+		Objectives: []objectiveReport{
+			{Name: "checkout", Target: 0.999, Availability: 0.9995, BudgetConsumed: 0.5, Alerts: 0},
+			{Name: "web", Target: 0.99, Availability: 0.98, BudgetConsumed: 2, Alerts: 4, SLA: &slaReport{Target: 0.985, BudgetConsumed: 1.33, Met: false}},
+		},
+	}
+
+	require.Equal(t, `SLO report for frontend from 2024-03-04 to 2024-03-11
+
+Objective  Target   Availability  Budget consumed  Alerts  SLA
+checkout   99.900%  99.950%       50.0%            0       -
+web        99.000%  98.000%       200.0%           4       98.500% missed
+`, report.Text())
+
+	report.Team = ""
+	require.Equal(t, "SLO report for unassigned objectives from 2024-03-04 to 2024-03-11", report.Subject())
+}
+
+func TestEmailReportSender(t *testing.T) {
+	var (
+		gotAddr string
+		gotTo   []string
+		gotMsg  string
+	)
+	sender := &emailReportSender{
+		addr: "smtp.example.com:587",
+		from: "pyrra@example.com",
+		to:   []string{"a@example.com", "b@example.com"},
+		sendMail: func(addr string, _ smtp.Auth, _ string, to []string, msg []byte) error {
+			gotAddr, gotTo, gotMsg = addr, to, string(msg)
+			return nil
+		},
+	}
+
+	err := sender.Send(context.Background(), teamReport{
+		Team: "frontend",
+		From: time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "smtp.example.com:587", gotAddr)
+	require.Equal(t, []string{"a@example.com", "b@example.com"}, gotTo)
+	require.True(t, strings.HasPrefix(gotMsg, "From: pyrra@example.com\r\nTo: a@example.com, b@example.com\r\nSubject: SLO report for frontend from 2024-03-04 to 2024-03-11\r\n"))
+	require.Contains(t, gotMsg, "\r\n\r\nSLO report for frontend")
+
+	// Teams with recipients of their own are only sent to them.
+	sender.teams = map[string][]string{"frontend": {"frontend@example.com"}, "backend": {"backend@example.com"}}
+	require.NoError(t, sender.Send(context.Background(), teamReport{Team: "frontend"}))
+	require.Equal(t, []string{"frontend@example.com"}, gotTo)
+	require.NoError(t, sender.Send(context.Background(), teamReport{Team: "checkout"}))
+	require.Equal(t, []string{"a@example.com", "b@example.com"}, gotTo)
+
+	// Without default recipients, other teams' reports aren't sent.
+	sender.to = nil
+	gotTo = nil
+	require.NoError(t, sender.Send(context.Background(), teamReport{Team: "checkout"}))
+	require.Nil(t, gotTo)
+}
+
+func TestReportConfig_senders(t *testing.T) {
+	rc := ReportConfig{
+		ReportSchedule:   "0 9 * * 1",
+		ReportPeriod:     time.Hour,
+		ReportSMTPAddr:   "smtp.example.com:587",
+		ReportEmailFrom:  "pyrra@example.com",
+		ReportEmailTeams: map[string]string{"payments": "payments@example.com, sre@example.com"},
+	}
+	require.NoError(t, rc.Validate())
+
+	senders := rc.senders(http.DefaultClient)
+	require.Len(t, senders, 1)
+	require.Equal(t, map[string][]string{"payments": {"payments@example.com", "sre@example.com"}}, senders[0].(*emailReportSender).teams)
+
+	rc.ReportEmailTeams["checkout"] = " , "
+	require.EqualError(t, rc.Validate(), `--report-email-teams has no addresses for team "checkout"`)
+}
+
+func TestReportConfig_Validate(t *testing.T) {
+	require.NoError(t, (&ReportConfig{}).Validate())
+
+	rc := &ReportConfig{ReportSchedule: "0 9 * * 1", ReportPeriod: time.Hour}
+	require.EqualError(t, rc.Validate(), "--report-schedule requires --report-webhook-url or --report-smtp-addr")
+
+	rc.ReportSMTPAddr = "smtp.example.com:587"
+	require.EqualError(t, rc.Validate(), "--report-smtp-addr requires --report-email-from and --report-email-to or --report-email-teams")
+
+	rc.ReportEmailFrom = "pyrra@example.com"
+	rc.ReportEmailTo = []string{"team@example.com"}
+	require.NoError(t, rc.Validate())
+
+	rc.ReportCalendar = "quarter"
+	require.EqualError(t, rc.Validate(), "--report-calendar must be week or month")
+	rc.ReportCalendar = "month"
+	require.NoError(t, rc.Validate())
+
+	rc.ReportTimezone = "Mars/Olympus_Mons"
+	require.ErrorContains(t, rc.Validate(), "--report-timezone is invalid")
+	rc.ReportTimezone = "Europe/Berlin"
+	require.NoError(t, rc.Validate())
+
+	rc.ReportSchedule = "every monday"
+	require.ErrorContains(t, rc.Validate(), "--report-schedule is invalid")
+}
+
+func TestReportConfig_schedule(t *testing.T) {
+	rc := ReportConfig{ReportSchedule: "0 9 * * 1", ReportTimezone: "Europe/Berlin"}
+	schedule, err := rc.schedule()
+	require.NoError(t, err)
+
+	// Monday 9:00 in Berlin is 8:00 UTC in winter and 7:00 UTC in summer.
+	next := schedule.Next(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	require.Equal(t, time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC), next.UTC())
+	next = schedule.Next(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC))
+	require.Equal(t, time.Date(2024, 4, 1, 7, 0, 0, 0, time.UTC), next.UTC())
+
+	// Schedules setting their own time zone take precedence.
+	rc.ReportSchedule = "CRON_TZ=UTC 0 9 * * 1"
+	schedule, err = rc.schedule()
+	require.NoError(t, err)
+	next = schedule.Next(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	require.Equal(t, time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC), next.UTC())
+}
+
+func TestReporter_reportPeriod(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	// Monday, 1 April 2024 at 00:30 in Berlin, which is still Sunday in UTC.
+	ts := time.Date(2024, 3, 31, 22, 30, 0, 0, time.UTC)
+
+	testcases := []struct {
+		name     string
+		reporter reporter
+		from     time.Time
+		to       time.Time
+	}{{
+		name:     "rolling",
+		reporter: reporter{period: 24 * time.Hour, location: berlin},
+		from:     ts.Add(-24 * time.Hour),
+		to:       ts,
+	}, {
+		name:     "weekUTC",
+		reporter: reporter{calendar: calendarWeek},
+		from:     time.Date(2024, 3, 18, 0, 0, 0, 0, time.UTC),
+		to:       time.Date(2024, 3, 25, 0, 0, 0, 0, time.UTC),
+	}, {
+		name:     "weekBerlin",
+		reporter: reporter{calendar: calendarWeek, location: berlin},
+		from:     time.Date(2024, 3, 25, 0, 0, 0, 0, berlin),
+		to:       time.Date(2024, 4, 1, 0, 0, 0, 0, berlin),
+	}, {
+		name:     "monthUTC",
+		reporter: reporter{calendar: calendarMonth},
+		from:     time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		to:       time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}, {
+		name:     "monthBerlin",
+		reporter: reporter{calendar: calendarMonth, location: berlin},
+		from:     time.Date(2024, 3, 1, 0, 0, 0, 0, berlin),
+		to:       time.Date(2024, 4, 1, 0, 0, 0, 0, berlin),
+	}}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			from, to := tc.reporter.reportPeriod(ts)
+			require.True(t, tc.from.Equal(from), "from: %s", from)
+			require.True(t, tc.to.Equal(to), "to: %s", to)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+type revisionsResponse struct {
+	// Revisions of the objective, from the newest to the oldest.
+	Revisions []revision `json:"revisions"`
+}
+
+type revision struct {
+	Generation int64     `json:"generation"`
+	ChangedBy  string    `json:"changedBy,omitempty"`
+	ChangedAt  time.Time `json:"changedAt"`
+	Target     string    `json:"target"`
+	Window     string    `json:"window"`
+	// Diff is the unified diff of the spec to the previous revision.
+	Diff string `json:"diff,omitempty"`
+}
+
+// kubernetesRevisionsHandler serves the ServiceLevelObjectiveRevisions of an objective selected by ?namespace=monitoring&name=http-errors.
+type kubernetesRevisionsHandler struct {
+	logger log.Logger
+	client KubernetesClient
+}
+
+func (h *kubernetesRevisionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	namespace, name := r.URL.Query().Get("namespace"), r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "the name of the objective is required, like ?namespace=monitoring&name=http-errors", http.StatusBadRequest)
+		return
+	}
+
+	var list pyrrav1alpha1.ServiceLevelObjectiveRevisionList
+	if err := h.client.List(r.Context(), &list,
+		client.InNamespace(namespace),
+		client.MatchingLabels{pyrrav1alpha1.RevisionObjectiveLabel: name},
+	); err != nil {
+		level.Warn(h.logger).Log("msg", "failed to list revisions", "namespace", namespace, "name", name, "err", err)
+		http.Error(w, "failed to list revisions", http.StatusInternalServerError)
+		return
+	}
+
+	resp := revisionsResponse{Revisions: make([]revision, 0, len(list.Items))}
+	for _, r := range list.Items {
+		resp.Revisions = append(resp.Revisions, revision{
+			Generation: r.Spec.Generation,
+			ChangedBy:  r.Spec.ChangedBy,
+			ChangedAt:  r.Spec.ChangedAt.Time,
+			Target:     r.Spec.ObjectiveSpec.Target,
+			Window:     r.Spec.ObjectiveSpec.Window,
+			Diff:       r.Spec.Diff,
+		})
+	}
+	sort.Slice(resp.Revisions, func(i, j int) bool {
+		return resp.Revisions[i].Generation > resp.Revisions[j].Generation
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// revisionsHandler forwards requests for the revisions of an objective to the API backend.
+// Backends that don't record revisions, like the filesystem operator, respond with 404.
+type revisionsHandler struct {
+	logger log.Logger
+	client *http.Client
+	url    string
+}
+
+func (h *revisionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, fmt.Sprintf("%s/revisions?%s", h.url, r.URL.RawQuery), nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		level.Warn(h.logger).Log("msg", "failed to request revisions", "err", err)
+		http.Error(w, "failed to request revisions", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pyrrav1alpha1 "github.com/pyrra-dev/pyrra/kubernetes/api/v1alpha1"
+)
+
+func TestKubernetesRevisionsHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, pyrrav1alpha1.AddToScheme(scheme))
+
+	revision := func(objective string, generation int64, target, diff string) *pyrrav1alpha1.ServiceLevelObjectiveRevision {
+		return &pyrrav1alpha1.ServiceLevelObjectiveRevision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      objective + "-" + target,
+				Namespace: "monitoring",
+				Labels:    map[string]string{pyrrav1alpha1.RevisionObjectiveLabel: objective},
+			},
+			Spec: pyrrav1alpha1.ServiceLevelObjectiveRevisionSpec{
+				Objective:     objective,
+				Generation:    generation,
+				ChangedBy:     "kubectl-edit",
+				ChangedAt:     metav1.NewTime(time.Date(2024, 3, int(generation), 9, 0, 0, 0, time.UTC)),
+				ObjectiveSpec: pyrrav1alpha1.ServiceLevelObjectiveSpec{Target: target, Window: "4w"},
+				Diff:          diff,
+			},
+		}
+	}
+
+	h := &kubernetesRevisionsHandler{
+		logger: log.NewNopLogger(),
+		client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			revision("http-errors", 1, "99", ""),
+			revision("http-errors", 2, "95", "-target: \"99\"\n+target: \"95\"\n"),
+			revision("grpc-errors", 1, "99.9", ""),
+		).Build(),
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/revisions?namespace=monitoring&name=http-errors", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"revisions":[
+		{"generation":2,"changedBy":"kubectl-edit","changedAt":"2024-03-02T09:00:00Z","target":"95","window":"4w","diff":"-target: \"99\"\n+target: \"95\"\n"},
+		{"generation":1,"changedBy":"kubectl-edit","changedAt":"2024-03-01T09:00:00Z","target":"99","window":"4w"}
+	]}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/revisions?namespace=default&name=http-errors", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"revisions":[]}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/revisions", nil))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestRevisionsHandler(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/revisions" {
+			http.NotFound(w, r)
+			return
+		}
+		require.Equal(t, "namespace=monitoring&name=http-errors", r.URL.RawQuery)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"revisions":[]}`))
+	}))
+	defer backend.Close()
+
+	h := &revisionsHandler{logger: log.NewNopLogger(), client: backend.Client(), url: backend.URL}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/revisions?namespace=monitoring&name=http-errors", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	require.JSONEq(t, `{"revisions":[]}`, rec.Body.String())
+
+	// Backends without revisions don't serve them.
+	h.url = backend.URL + "/filesystem"
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/revisions?namespace=monitoring&name=http-errors", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+//go:embed jsonnet/controller-gen/pyrra.dev_servicelevelobjectives.json
+var objectiveCRD []byte
+
+// objectiveSchema returns the JSON Schema of ServiceLevelObjectives as generated into the CRD.
+// It describes the same YAML Pyrra reads from files and Kubernetes,
+// so editors can validate objectives without the Go types.
+func objectiveSchema() ([]byte, error) {
+	var crd struct {
+		Spec struct {
+			Versions []struct {
+				Name   string `json:"name"`
+				Schema struct {
+					OpenAPIV3Schema map[string]any `json:"openAPIV3Schema"`
+				} `json:"schema"`
+			} `json:"versions"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(objectiveCRD, &crd); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal CRD: %w", err)
+	}
+	if len(crd.Spec.Versions) == 0 {
+		return nil, fmt.Errorf("CRD has no versions")
+	}
+
+	version := crd.Spec.Versions[0]
+	schema := version.Schema.OpenAPIV3Schema
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "ServiceLevelObjective " + version.Name
+
+	return json.MarshalIndent(schema, "", "  ")
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectiveSchema(t *testing.T) {
+	b, err := objectiveSchema()
+	require.NoError(t, err)
+
+	var schema struct {
+		Schema     string `json:"$schema"`
+		Title      string `json:"title"`
+		Properties struct {
+			Spec struct {
+				Required   []string `json:"required"`
+				Properties struct {
+					Indicator struct {
+						Properties map[string]any `json:"properties"`
+					} `json:"indicator"`
+				} `json:"properties"`
+			} `json:"spec"`
+		} `json:"properties"`
+	}
+	require.NoError(t, json.Unmarshal(b, &schema))
+
+	require.Equal(t, "http://json-schema.org/draft-07/schema#", schema.Schema)
+	require.Equal(t, "ServiceLevelObjective v1alpha1", schema.Title)
+	require.Contains(t, schema.Properties.Spec.Required, "target")
+	for _, indicator := range []string{"ratio", "latency", "latencyNative", "bool_gauge", "istio", "linkerd", "grpc"} {
+		require.Contains(t, schema.Properties.Spec.Properties.Indicator.Properties, indicator)
+	}
+}
@@ -0,0 +1,131 @@
+package slo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// go test ./slo -run=^$ -bench=. -count=5 | tee BenchmarkRules
+
+// syntheticObjectives returns n objectives cycling through all indicator types,
+// each grouped by the given number of labels, to benchmark generation at the scale of large deployments.
+func syntheticObjectives(n, grouping int) []Objective {
+	groupingLabels := make([]string, grouping)
+	for i := range groupingLabels {
+		groupingLabels[i] = fmt.Sprintf("group%d", i)
+	}
+
+	objectives := make([]Objective, n)
+	for i := range objectives {
+		job := fmt.Sprintf("service-%d", i)
+		metric := func(name string, extra ...*labels.Matcher) Metric {
+			return Metric{
+				Name: name,
+				LabelMatchers: append([]*labels.Matcher{
+					{Type: labels.MatchEqual, Name: "job", Value: job},
+					{Type: labels.MatchEqual, Name: labels.MetricName, Value: name},
+				}, extra...),
+			}
+		}
+
+		o := Objective{
+			Labels: labels.FromStrings(labels.MetricName, job, "namespace", "monitoring"),
+			Target: 0.99,
+			Window: model.Duration(28 * 24 * time.Hour),
+			Alerting: Alerting{
+				Burnrates: true,
+				Absent:    true,
+			},
+		}
+
+		switch i % 4 {
+		case 0:
+			o.Indicator.Ratio = &RatioIndicator{
+				Errors:   metric("http_requests_total", &labels.Matcher{Type: labels.MatchRegexp, Name: "code", Value: "5.."}),
+				Total:    metric("http_requests_total"),
+				Grouping: groupingLabels,
+			}
+		case 1:
+			o.Indicator.Latency = &LatencyIndicator{
+				Success:  metric("http_request_duration_seconds_bucket", &labels.Matcher{Type: labels.MatchEqual, Name: "le", Value: "1"}),
+				Total:    metric("http_request_duration_seconds_count"),
+				Grouping: groupingLabels,
+			}
+		case 2:
+			o.Indicator.LatencyNative = &LatencyNativeIndicator{
+				Latency:  model.Duration(time.Second),
+				Total:    metric("http_request_duration_seconds"),
+				Grouping: groupingLabels,
+			}
+		case 3:
+			o.Indicator.BoolGauge = &BoolGaugeIndicator{
+				Metric:   metric("probe_success"),
+				Grouping: groupingLabels,
+			}
+		}
+
+		objectives[i] = o
+	}
+	return objectives
+}
+
+func TestSyntheticObjectives(t *testing.T) {
+	for _, o := range syntheticObjectives(8, 2) {
+		if _, err := o.IncreaseRules(); err != nil {
+			t.Fatalf("%s: %v", o.Name(), err)
+		}
+		if _, err := o.Burnrates(); err != nil {
+			t.Fatalf("%s: %v", o.Name(), err)
+		}
+		if len(o.Grouping()) != 2 {
+			t.Fatalf("%s: expected grouping by 2 labels, got %v", o.Name(), o.Grouping())
+		}
+	}
+}
+
+func BenchmarkRules(b *testing.B) {
+	for _, n := range []int{1, 100, 1000} {
+		for _, grouping := range []int{0, 3} {
+			objectives := syntheticObjectives(n, grouping)
+
+			b.Run(fmt.Sprintf("objectives=%d/grouping=%d", n, grouping), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					for _, o := range objectives {
+						if _, err := o.IncreaseRules(); err != nil {
+							b.Fatal(err)
+						}
+						if _, err := o.Burnrates(); err != nil {
+							b.Fatal(err)
+						}
+						if _, err := o.GenericRules(); err != nil && !errors.Is(err, ErrGroupingUnsupported) {
+							b.Fatal(err)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkQueries(b *testing.B) {
+	objectives := syntheticObjectives(100, 3)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, o := range objectives {
+			_ = o.QueryTotal(o.Window)
+			_ = o.QueryErrors(o.Window)
+			_ = o.QueryErrorBudget()
+			_ = o.RequestRange(time.Minute)
+			_ = o.ErrorsRange(time.Minute)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package slo
+
+import (
+	"fmt"
+	"time"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Calendar is a calendar period in UTC, like a month, at whose start the error budget of calendar-aligned objectives resets.
+// Contractual SLAs are usually promised per calendar period rather than over a rolling window.
+type Calendar string
+
+const (
+	// CalendarWeek starts on Mondays, like ISO weeks.
+	CalendarWeek    Calendar = "week"
+	CalendarMonth   Calendar = "month"
+	CalendarQuarter Calendar = "quarter"
+)
+
+// calendarStep is the range of the increases that are summed up since the start of the calendar period.
+// The error budget of the period lags behind by up to one step.
+const calendarStep = model.Duration(5 * time.Minute)
+
+// Validate returns an error if the calendar isn't week, month or quarter.
+func (c Calendar) Validate() error {
+	switch c {
+	case CalendarWeek, CalendarMonth, CalendarQuarter:
+		return nil
+	default:
+		return fmt.Errorf("calendar must be one of week, month or quarter, got %q", c)
+	}
+}
+
+// longest returns how long the calendar period gets at most, the range its increases are summed up over.
+func (c Calendar) longest() model.Duration {
+	day := 24 * time.Hour
+	switch c {
+	case CalendarWeek:
+		return model.Duration(7 * day)
+	case CalendarMonth:
+		return model.Duration(31 * day)
+	default:
+		return model.Duration(92 * day)
+	}
+}
+
+// period returns a PromQL expression numbering the calendar periods of the timestamps,
+// which is the same for all timestamps within a period.
+func (c Calendar) period(timestamps string) string {
+	switch c {
+	case CalendarWeek:
+		// 1970-01-01 was a Thursday, the first week starts 4 days later on a Monday.
+		return fmt.Sprintf("floor((%s - 345600) / 604800)", timestamps)
+	case CalendarMonth:
+		return fmt.Sprintf("year(%[1]s) * 12 + month(%[1]s)", timestamps)
+	default:
+		return fmt.Sprintf("year(%[1]s) * 4 + floor((month(%[1]s) - 1) / 3)", timestamps)
+	}
+}
+
+// sinceStart returns the sum of the increases over the calendarStep the selector selects since the start of the calendar period.
+// The range since the start changes with every evaluation, so the increases of the longest period are summed up,
+// but only the ones whose step started within the period of the evaluation, which the @ end() modifier pins within the subquery.
+func (c Calendar) sinceStart(selector *parser.VectorSelector) (parser.Expr, error) {
+	stepStart := fmt.Sprintf("vector(time() - %d)", int(time.Duration(calendarStep).Seconds()))
+	return parser.ParseExpr(fmt.Sprintf(
+		"sum_over_time((%s and on () (%s == scalar(max_over_time((%s)[%s:%s] @ end()))))[%s:%s])",
+		selector,
+		c.period(stepStart),
+		c.period("vector(time())"), calendarStep, calendarStep,
+		c.longest(), calendarStep,
+	))
+}
+
+// replaceSelectors replaces the vector selectors of the expression, but not the ones of range vectors.
+func replaceSelectors(expr parser.Expr, replace func(*parser.VectorSelector) (parser.Expr, error)) (parser.Expr, error) {
+	var err error
+	switch e := expr.(type) {
+	case *parser.VectorSelector:
+		return replace(e)
+	case *parser.AggregateExpr:
+		e.Expr, err = replaceSelectors(e.Expr, replace)
+	case *parser.BinaryExpr:
+		if e.LHS, err = replaceSelectors(e.LHS, replace); err != nil {
+			return nil, err
+		}
+		e.RHS, err = replaceSelectors(e.RHS, replace)
+	case *parser.ParenExpr:
+		e.Expr, err = replaceSelectors(e.Expr, replace)
+	case *parser.UnaryExpr:
+		e.Expr, err = replaceSelectors(e.Expr, replace)
+	case *parser.Call:
+		for i := range e.Args {
+			if e.Args[i], err = replaceSelectors(e.Args[i], replace); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return expr, err
+}
+
+// calendarSteps returns the objective recording the increases over the calendarStep instead of its window.
+func (o Objective) calendarSteps() Objective {
+	steps := o
+	steps.Window = calendarStep
+	steps.StableRuleNames = false
+	steps.Alerting.Absent = false
+	return steps
+}
+
+// calendarErrorBudget returns the query of the error budget left since the start of the calendar period.
+func (o Objective) calendarErrorBudget() (string, error) {
+	errorBudget := o.calendarSteps().QueryErrorBudget()
+	if errorBudget == "" {
+		return "", fmt.Errorf("objective %s has no error budget query", o.Name())
+	}
+	expr, err := parser.ParseExpr(errorBudget)
+	if err != nil {
+		return "", err
+	}
+	expr, err = replaceSelectors(expr, o.Calendar.sinceStart)
+	if err != nil {
+		return "", err
+	}
+	return expr.String(), nil
+}
+
+// CalendarRules records the error budget left since the start of the objective's calendar period as pyrra_calendar_error_budget,
+// which resets to the full error budget at the start of each period.
+// The increases over the calendarStep are recorded to be summed up, as there are no rules recording the increase since the start.
+func (o Objective) CalendarRules() (monitoringv1.RuleGroup, error) {
+	sloName := o.Labels.Get(labels.MetricName)
+
+	if o.Calendar == "" {
+		return monitoringv1.RuleGroup{}, fmt.Errorf("objective %s has no calendar", sloName)
+	}
+	if err := o.Calendar.Validate(); err != nil {
+		return monitoringv1.RuleGroup{}, err
+	}
+	switch o.IndicatorType() {
+	case Logs, Composite:
+		// Loki can't sum up the increases with subqueries and composites record no increases.
+		return monitoringv1.RuleGroup{}, fmt.Errorf("objective %s with a calendar must not have a logs or composite indicator", sloName)
+	}
+
+	increases, err := o.calendarSteps().IncreaseRules()
+	if err != nil {
+		return monitoringv1.RuleGroup{}, err
+	}
+	var rules []monitoringv1.Rule
+	for _, rule := range increases.Rules {
+		if rule.Record != "" {
+			rules = append(rules, rule)
+		}
+	}
+
+	errorBudget, err := o.calendarErrorBudget()
+	if err != nil {
+		return monitoringv1.RuleGroup{}, err
+	}
+	rules = append(rules, monitoringv1.Rule{
+		Record: "pyrra_calendar_error_budget",
+		Expr:   intstr.FromString(errorBudget),
+		Labels: o.commonRuleLabels(sloName),
+	})
+
+	return monitoringv1.RuleGroup{
+		Name:     sloName + CalendarRuleGroupSuffix,
+		Interval: monitoringDuration("1m"),
+		Rules:    rules,
+	}, nil
+}
@@ -0,0 +1,75 @@
+package slo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The recording rules of composite indicators are named after this metric,
+// as they combine the ratios of several objectives' metrics.
+const compositeMetric = "pyrra_composite"
+
+// CompositeIndicator combines objectives, like the steps of a user journey, into one objective
+// whose error ratio is the weighted average of their error ratios over the same range.
+// The ratios are queried from the objectives' metrics, so they don't depend on the ranges the objectives record.
+type CompositeIndicator struct {
+	Objectives []CompositeObjective
+}
+
+// CompositeObjective is an objective combined by a CompositeIndicator.
+type CompositeObjective struct {
+	// Name of the combined objective.
+	Name string
+	// Weight of the objective's error ratio relative to the others'.
+	Weight float64
+	// Objective is the combined objective, nil until it's resolved from its name.
+	// Rules are only generated once all objectives are resolved.
+	Objective *Objective
+}
+
+// Validate returns an error if rules can't be generated for the composite,
+// because an objective isn't resolved or can't be combined.
+// Only objectives without grouping whose ratios are PromQL can be combined, and no other composites,
+// which also rules out cycles of composites.
+func (c CompositeIndicator) Validate() error {
+	if len(c.Objectives) == 0 {
+		return fmt.Errorf("composite must combine at least one objective")
+	}
+	for _, co := range c.Objectives {
+		if co.Weight <= 0 {
+			return fmt.Errorf("weight of objective %s must be greater than 0", co.Name)
+		}
+		if co.Objective == nil {
+			return fmt.Errorf("objective %s of the composite isn't resolved", co.Name)
+		}
+		switch co.Objective.IndicatorType() {
+		case Unknown:
+			return fmt.Errorf("objective %s has no indicator", co.Name)
+		case Composite:
+			return fmt.Errorf("objective %s is a composite itself, composites can't be combined", co.Name)
+		case Logs:
+			return fmt.Errorf("objective %s has a logs indicator, its LogQL can't be combined with PromQL", co.Name)
+		}
+		if len(co.Objective.Grouping()) > 0 {
+			return fmt.Errorf("objective %s has grouping, only objectives with a single ratio can be combined", co.Name)
+		}
+	}
+	return nil
+}
+
+// ratio returns the weighted average of the objectives' error ratios over the range.
+// Objectives without errors or requests within the range count as without errors.
+func (c CompositeIndicator) ratio(timerange time.Duration) string {
+	var weights float64
+	terms := make([]string, 0, len(c.Objectives))
+	for _, co := range c.Objectives {
+		weights += co.Weight
+		terms = append(terms, fmt.Sprintf("%s * ((%s) or vector(0))",
+			strconv.FormatFloat(co.Weight, 'f', -1, 64),
+			co.Objective.Burnrate(timerange),
+		))
+	}
+	return fmt.Sprintf("(%s) / %s", strings.Join(terms, " + "), strconv.FormatFloat(weights, 'f', -1, 64))
+}
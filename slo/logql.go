@@ -0,0 +1,84 @@
+package slo
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// The recording rules of logs indicators are named after these metrics,
+// as they count the lines of log queries instead of series of metrics.
+// Loki's ruler writes them to Prometheus with remote write.
+const (
+	logsErrorsMetric = "pyrra_log_errors"
+	logsTotalMetric  = "pyrra_log_lines"
+)
+
+// ValidateLogQuery returns an error if the LogQL query can't be the errors or total of a LogsIndicator.
+// The rules count its lines over their windows, so it has to be a log query:
+// a stream selector optionally followed by a pipeline, like `{job="app"} |= "error"`.
+func ValidateLogQuery(query string) error {
+	selector, pipeline, err := splitLogQuery(query)
+	if err != nil {
+		return err
+	}
+	if _, err := parser.ParseMetricSelector(selector); err != nil {
+		return fmt.Errorf("invalid stream selector %s: %w", selector, err)
+	}
+	if pipeline != "" && !strings.HasPrefix(pipeline, "|") {
+		return fmt.Errorf("log query must continue with a pipeline starting with |, not %q", pipeline)
+	}
+	return nil
+}
+
+// splitLogQuery splits the log query into its stream selector and pipeline.
+// Braces within the quoted values of the selector's matchers don't end the selector.
+func splitLogQuery(query string) (string, string, error) {
+	query = strings.TrimSpace(query)
+	if !strings.HasPrefix(query, "{") {
+		return "", "", fmt.Errorf(`log query must start with a stream selector like {job="app"}`)
+	}
+
+	var quote rune
+	escaped := false
+	for i, r := range query {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0 && r == '\\' && quote != '`':
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+		case r == '"' || r == '`':
+			quote = r
+		case r == '}':
+			return query[:i+1], strings.TrimSpace(query[i+1:]), nil
+		}
+	}
+	return "", "", fmt.Errorf("log query must close its stream selector with }")
+}
+
+// sum returns the LogQL sum of the log query's lines by the indicator's grouping, counted by the range function.
+func (l LogsIndicator) sum(query, function string, window time.Duration) string {
+	grouping := slices.Clone(l.Grouping)
+	slices.Sort(grouping)
+
+	var by string
+	if len(grouping) > 0 {
+		by = fmt.Sprintf(" by (%s) ", strings.Join(grouping, ", "))
+	}
+	return fmt.Sprintf("sum%s(%s(%s[%s]))", by, function, strings.TrimSpace(query), model.Duration(window))
+}
+
+// ratio returns the LogQL ratio of the errors and total log queries' lines over the window.
+func (l LogsIndicator) ratio(window time.Duration) string {
+	return fmt.Sprintf("%s / %s",
+		l.sum(l.Errors, "count_over_time", window),
+		l.sum(l.Total, "count_over_time", window),
+	)
+}
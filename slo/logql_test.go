@@ -0,0 +1,32 @@
+package slo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateLogQuery(t *testing.T) {
+	for query, expected := range map[string]string{
+		`{job="nginx"}`:                         "",
+		` {job="nginx", env=~"prod|staging"} `:  "",
+		`{job="nginx"} |= "error" != "timeout"`: "",
+		`{job="nginx"} | json | status >= 500`:  "",
+		"{job=`ng}nx`} |~ `5\\d\\d`":            "",
+		`{job="a\"}"} | logfmt`:                 "",
+		`rate({job="nginx"}[5m])`:               `log query must start with a stream selector like {job="app"}`,
+		`{job="nginx"`:                          "log query must close its stream selector with }",
+		`{job=nginx}`:                           `invalid stream selector {job=nginx}: 1:6: parse error: unexpected identifier "nginx" in label matching, expected string`,
+		`{job="nginx"}[5m]`:                     `log query must continue with a pipeline starting with |, not "[5m]"`,
+		`{job="nginx"} or {job="apache"}`:       `log query must continue with a pipeline starting with |, not "or {job=\"apache\"}"`,
+	} {
+		t.Run(query, func(t *testing.T) {
+			err := ValidateLogQuery(query)
+			if expected == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, expected)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package slo
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/prometheus/common/model"
@@ -25,18 +26,18 @@ func (o Objective) QueryTotal(window model.Duration) string {
 	)
 	switch o.IndicatorType() {
 	case Ratio:
-		metric = increaseName(o.Indicator.Ratio.Total.Name, window)
+		metric = o.increaseName(o.Indicator.Ratio.Total.Name, window)
 		matchers = cloneMatchers(o.Indicator.Ratio.Total.LabelMatchers)
 		grouping = slices.Clone(o.Indicator.Ratio.Grouping)
 	case Latency:
-		metric = increaseName(o.Indicator.Latency.Total.Name, window)
+		metric = o.increaseName(o.Indicator.Latency.Total.Name, window)
 		grouping = slices.Clone(o.Indicator.Latency.Grouping)
 		matchers = append(
 			cloneMatchers(o.Indicator.Latency.Total.LabelMatchers),
 			&labels.Matcher{Type: labels.MatchEqual, Name: labels.BucketLabel, Value: ""},
 		)
 	case LatencyNative:
-		metric = increaseName(o.Indicator.LatencyNative.Total.Name, window)
+		metric = o.increaseName(o.Indicator.LatencyNative.Total.Name, window)
 		grouping = slices.Clone(o.Indicator.LatencyNative.Grouping)
 		matchers = append(
 			cloneMatchers(o.Indicator.LatencyNative.Total.LabelMatchers),
@@ -46,6 +47,11 @@ func (o Objective) QueryTotal(window model.Duration) string {
 		metric = countName(o.Indicator.BoolGauge.Name, window)
 		matchers = cloneMatchers(o.Indicator.BoolGauge.LabelMatchers)
 		grouping = slices.Clone(o.Indicator.BoolGauge.Grouping)
+	case Expression, Logs:
+		total, _ := o.recordedMetrics()
+		metric = o.increaseName(total, window)
+		matchers = []*labels.Matcher{{Type: labels.MatchEqual, Name: labels.MetricName, Value: metric}}
+		grouping = slices.Clone(o.Grouping())
 	default:
 		return ""
 	}
@@ -80,7 +86,7 @@ func (o Objective) QueryErrors(window model.Duration) string {
 			return ""
 		}
 
-		metric := increaseName(o.Indicator.Ratio.Errors.Name, window)
+		metric := o.increaseName(o.Indicator.Ratio.Errors.Name, window)
 		matchers := cloneMatchers(o.Indicator.Ratio.Errors.LabelMatchers)
 
 		for _, m := range matchers {
@@ -101,6 +107,21 @@ func (o Objective) QueryErrors(window model.Duration) string {
 			grouping: o.Indicator.Ratio.Grouping,
 		}.replace(expr)
 
+		return expr.String()
+	case Expression, Logs:
+		expr, err := parser.ParseExpr(`sum by (grouping) (metric{})`)
+		if err != nil {
+			return ""
+		}
+
+		_, errors := o.recordedMetrics()
+		metric := o.increaseName(errors, window)
+		objectiveReplacer{
+			metric:   metric,
+			matchers: o.expressionMatchers(metric),
+			grouping: o.Grouping(),
+		}.replace(expr)
+
 		return expr.String()
 	case Latency:
 		expr, err := parser.ParseExpr(`sum by (grouping) (metric{matchers="total"}) - sum by (grouping) (errorMetric{matchers="errors"})`)
@@ -108,7 +129,7 @@ func (o Objective) QueryErrors(window model.Duration) string {
 			return ""
 		}
 
-		metric := increaseName(o.Indicator.Latency.Total.Name, window)
+		metric := o.increaseName(o.Indicator.Latency.Total.Name, window)
 		matchers := cloneMatchers(o.Indicator.Latency.Total.LabelMatchers)
 		for _, m := range matchers {
 			if m.Name == labels.MetricName {
@@ -124,7 +145,7 @@ func (o Objective) QueryErrors(window model.Duration) string {
 			Value: o.Name(),
 		})
 
-		errorMetric := increaseName(o.Indicator.Latency.Success.Name, window)
+		errorMetric := o.increaseName(o.Indicator.Latency.Success.Name, window)
 		errorMatchers := cloneMatchers(o.Indicator.Latency.Success.LabelMatchers)
 		for _, m := range errorMatchers {
 			if m.Name == labels.MetricName {
@@ -153,7 +174,7 @@ func (o Objective) QueryErrors(window model.Duration) string {
 			return ""
 		}
 
-		metric := increaseName(o.Indicator.LatencyNative.Total.Name, window)
+		metric := o.increaseName(o.Indicator.LatencyNative.Total.Name, window)
 		matchers := cloneMatchers(o.Indicator.LatencyNative.Total.LabelMatchers)
 		for i, m := range matchers {
 			if m.Name == labels.MetricName {
@@ -237,6 +258,9 @@ func (o Objective) QueryErrors(window model.Duration) string {
 func (o Objective) QueryErrorBudget() string {
 	indicatorType := o.IndicatorType()
 	switch indicatorType {
+	case Composite:
+		target := strconv.FormatFloat(o.Target, 'f', -1, 64)
+		return fmt.Sprintf("((1 - %s) - %s) / (1 - %s)", target, o.compositeErrorRatio(), target)
 	case Ratio:
 		expr, err := parser.ParseExpr(`
 (
@@ -255,7 +279,7 @@ func (o Objective) QueryErrorBudget() string {
 			return ""
 		}
 
-		metric := increaseName(o.Indicator.Ratio.Total.Name, o.Window)
+		metric := o.increaseName(o.Indicator.Ratio.Total.Name, o.Window)
 		matchers := cloneMatchers(o.Indicator.Ratio.Total.LabelMatchers)
 		for _, m := range matchers {
 			if m.Name == labels.MetricName {
@@ -269,7 +293,7 @@ func (o Objective) QueryErrorBudget() string {
 			Value: o.Name(),
 		})
 
-		errorMetric := increaseName(o.Indicator.Ratio.Errors.Name, o.Window)
+		errorMetric := o.increaseName(o.Indicator.Ratio.Errors.Name, o.Window)
 		errorMatchers := cloneMatchers(o.Indicator.Ratio.Errors.LabelMatchers)
 		for _, m := range errorMatchers {
 			if m.Name == labels.MetricName {
@@ -292,6 +316,36 @@ func (o Objective) QueryErrorBudget() string {
 			target:        o.Target,
 		}.replace(expr)
 
+		return expr.String()
+	case Expression, Logs:
+		expr, err := parser.ParseExpr(`
+(
+  (1 - 0.696969)
+  -
+  (
+    sum(errorMetric{matchers="errors"} or vector(0))
+    /
+    sum(metric{matchers="total"})
+  )
+)
+/
+(1 - 0.696969)
+`)
+		if err != nil {
+			return ""
+		}
+
+		total, errors := o.recordedMetrics()
+		metric := o.increaseName(total, o.Window)
+		errorMetric := o.increaseName(errors, o.Window)
+		objectiveReplacer{
+			metric:        metric,
+			matchers:      o.expressionMatchers(metric),
+			errorMetric:   errorMetric,
+			errorMatchers: o.expressionMatchers(errorMetric),
+			target:        o.Target,
+		}.replace(expr)
+
 		return expr.String()
 	case Latency, LatencyNative:
 		expr, err := parser.ParseExpr(`
@@ -321,15 +375,15 @@ func (o Objective) QueryErrorBudget() string {
 		)
 		switch indicatorType {
 		case Latency:
-			metric = increaseName(o.Indicator.Latency.Total.Name, o.Window)
+			metric = o.increaseName(o.Indicator.Latency.Total.Name, o.Window)
 			matchers = cloneMatchers(o.Indicator.Latency.Total.LabelMatchers)
-			errorMetric = increaseName(o.Indicator.Latency.Success.Name, o.Window)
+			errorMetric = o.increaseName(o.Indicator.Latency.Success.Name, o.Window)
 			errorMatchers = cloneMatchers(o.Indicator.Latency.Success.LabelMatchers)
 			grouping = o.Indicator.Latency.Grouping
 		case LatencyNative:
-			metric = increaseName(o.Indicator.LatencyNative.Total.Name, o.Window)
+			metric = o.increaseName(o.Indicator.LatencyNative.Total.Name, o.Window)
 			matchers = cloneMatchers(o.Indicator.LatencyNative.Total.LabelMatchers)
-			errorMetric = increaseName(o.Indicator.LatencyNative.Total.Name, o.Window)
+			errorMetric = o.increaseName(o.Indicator.LatencyNative.Total.Name, o.Window)
 			errorMatchers = cloneMatchers(o.Indicator.LatencyNative.Total.LabelMatchers)
 			grouping = o.Indicator.LatencyNative.Grouping
 		}
@@ -474,6 +528,9 @@ func (o Objective) QueryBurnrate(timerange time.Duration, groupingMatchers []*la
 				Value: m.Value,
 			}
 		}
+	case Expression, Logs, Composite:
+		metric = o.BurnrateName(timerange)
+		matchers[labels.MetricName] = &labels.Matcher{Type: labels.MatchEqual, Name: labels.MetricName, Value: metric}
 	}
 
 	if metric == "" {
@@ -508,6 +565,10 @@ func (o Objective) QueryBurnrate(timerange time.Duration, groupingMatchers []*la
 		Name:  "slo",
 		Value: o.Name(),
 	}
+	if id, ok := o.sharedBurnrateID(metric); ok {
+		delete(matchers, "slo")
+		matchers[SharedBurnrateLabel] = &labels.Matcher{Type: labels.MatchEqual, Name: SharedBurnrateLabel, Value: id}
+	}
 
 	matchersSlice := make([]*labels.Matcher, 0, len(matchers))
 	for _, m := range matchers {
@@ -657,6 +718,12 @@ func (o Objective) RequestRange(timerange time.Duration) string {
 			window:   timerange,
 		}.replace(expr)
 
+		return expr.String()
+	case Expression:
+		expr, err := o.Indicator.Expression.sum(o.Indicator.Expression.Total, "rate", timerange)
+		if err != nil {
+			return err.Error()
+		}
 		return expr.String()
 	default:
 		return ""
@@ -740,6 +807,12 @@ func (o Objective) ErrorsRange(timerange time.Duration) string {
 		}.replace(expr)
 
 		return expr.String()
+	case Expression:
+		expr, err := o.Indicator.Expression.ratio("rate", timerange)
+		if err != nil {
+			return err.Error()
+		}
+		return expr
 	default:
 		return ""
 	}
@@ -813,3 +886,123 @@ func cloneMatchers(matchers []*labels.Matcher) []*labels.Matcher {
 	}
 	return r
 }
+
+// ValidateExpression returns an error if the PromQL expression can't be the errors or total of an ExpressionIndicator.
+// The rules select the ranges of its series, so it has to select counters as instant vectors without ranges or subqueries.
+func ValidateExpression(query string) error {
+	_, err := rangeExpression(query, "increase", time.Minute)
+	return err
+}
+
+// rangeExpression parses the expression and wraps each of its series selectors in the range function over the window.
+func rangeExpression(query, function string, window time.Duration) (parser.Expr, error) {
+	expr, err := parser.ParseExpr(query)
+	if err != nil {
+		return nil, err
+	}
+	if expr.Type() != parser.ValueTypeVector {
+		return nil, fmt.Errorf("expression must return an instant vector, not a %s", expr.Type())
+	}
+
+	w := &rangeWrapper{function: parser.Functions[function], window: window}
+	expr, err = w.wrap(expr)
+	if err != nil {
+		return nil, err
+	}
+	if w.selectors == 0 {
+		return nil, fmt.Errorf("expression must select series")
+	}
+	return expr, nil
+}
+
+type rangeWrapper struct {
+	function  *parser.Function
+	window    time.Duration
+	selectors int
+}
+
+func (w *rangeWrapper) wrap(expr parser.Expr) (parser.Expr, error) {
+	var err error
+	switch n := expr.(type) {
+	case *parser.VectorSelector:
+		w.selectors++
+		return &parser.Call{
+			Func: w.function,
+			Args: parser.Expressions{&parser.MatrixSelector{VectorSelector: n, Range: w.window}},
+		}, nil
+	case *parser.AggregateExpr:
+		n.Expr, err = w.wrap(n.Expr)
+	case *parser.Call:
+		for i, arg := range n.Args {
+			if n.Args[i], err = w.wrap(arg); err != nil {
+				return nil, err
+			}
+		}
+	case *parser.BinaryExpr:
+		if n.LHS, err = w.wrap(n.LHS); err != nil {
+			return nil, err
+		}
+		n.RHS, err = w.wrap(n.RHS)
+	case *parser.ParenExpr:
+		n.Expr, err = w.wrap(n.Expr)
+	case *parser.UnaryExpr:
+		n.Expr, err = w.wrap(n.Expr)
+	case *parser.NumberLiteral, *parser.StringLiteral:
+	case *parser.MatrixSelector:
+		return nil, fmt.Errorf("expression must not select ranges like %s, the rules select the ranges of its series", n)
+	case *parser.SubqueryExpr:
+		return nil, fmt.Errorf("expression must not contain subqueries like %s", n)
+	default:
+		return nil, fmt.Errorf("expression must not contain %s", n)
+	}
+	return expr, err
+}
+
+// sum returns the sum of the expression by the indicator's grouping, with its series selectors wrapped in the range function.
+func (e ExpressionIndicator) sum(query, function string, window time.Duration) (parser.Expr, error) {
+	expr, err := rangeExpression(query, function, window)
+	if err != nil {
+		return nil, err
+	}
+
+	grouping := slices.Clone(e.Grouping)
+	slices.Sort(grouping)
+
+	return &parser.AggregateExpr{Op: parser.SUM, Expr: expr, Grouping: grouping}, nil
+}
+
+// ratio returns the ratio of the sums of the errors and total expression with their series selectors wrapped in the range function.
+func (e ExpressionIndicator) ratio(function string, window time.Duration) (string, error) {
+	errors, err := e.sum(e.Errors, function, window)
+	if err != nil {
+		return "", err
+	}
+	total, err := e.sum(e.Total, function, window)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s / %s", errors, total), nil
+}
+
+// recordedMetrics returns the metrics the rules of expression and logs indicators are named after.
+func (o Objective) recordedMetrics() (total, errors string) {
+	if o.IndicatorType() == Logs {
+		return logsTotalMetric, logsErrorsMetric
+	}
+	return expressionTotalMetric, expressionErrorsMetric
+}
+
+// compositeErrorRatio returns the query of the composite's error ratio over the window, recorded by its increase rules.
+func (o Objective) compositeErrorRatio() string {
+	metric := o.BurnrateName(time.Duration(o.Window))
+	expr := &parser.AggregateExpr{Op: parser.SUM, Expr: &parser.VectorSelector{Name: metric, LabelMatchers: o.expressionMatchers(metric)}}
+	return expr.String()
+}
+
+// expressionMatchers returns the matchers of the objective's recorded metric.
+func (o Objective) expressionMatchers(metric string) []*labels.Matcher {
+	return []*labels.Matcher{
+		{Type: labels.MatchEqual, Name: labels.MetricName, Value: metric},
+		{Type: labels.MatchEqual, Name: "slo", Value: o.Name()},
+	}
+}
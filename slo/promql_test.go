@@ -353,6 +353,67 @@ var (
 		o.Indicator.BoolGauge.Metric.LabelMatchers = append(o.Indicator.BoolGauge.LabelMatchers, matcher)
 		return o
 	}
+	objectiveCheckoutExpression = func() Objective {
+		return Objective{
+			Labels: labels.FromStrings(labels.MetricName, "checkout"),
+			Target: 0.99,
+			Window: model.Duration(28 * 24 * time.Hour),
+			Alerting: Alerting{
+				Burnrates: true,
+				Absent:    true,
+			},
+			Indicator: Indicator{
+				Expression: &ExpressionIndicator{
+					Errors: `checkout_failed_total{job="shop"} + payment_declined_total`,
+					Total:  `checkout_started_total{job="shop"}`,
+				},
+			},
+		}
+	}
+	objectiveCheckoutQuantile = func() Objective {
+		quantile := &QuantileIndicator{
+			Total: Metric{
+				Name: "http_request_duration_seconds_bucket",
+				LabelMatchers: []*labels.Matcher{
+					{Type: labels.MatchEqual, Name: "job", Value: "shop"},
+					{Type: labels.MatchEqual, Name: labels.MetricName, Value: "http_request_duration_seconds_bucket"},
+				},
+			},
+			Quantile: 0.99,
+			Latency:  model.Duration(500 * time.Millisecond),
+			Interval: model.Duration(5 * time.Minute),
+		}
+		return Objective{
+			Labels: labels.FromStrings(labels.MetricName, "checkout"),
+			Target: 0.99,
+			Window: model.Duration(28 * 24 * time.Hour),
+			Alerting: Alerting{
+				Burnrates: true,
+				Absent:    true,
+			},
+			Indicator: Indicator{
+				Quantile:  quantile,
+				BoolGauge: quantile.BoolGauge("checkout"),
+			},
+		}
+	}
+	objectiveNginxLogs = func() Objective {
+		return Objective{
+			Labels: labels.FromStrings(labels.MetricName, "nginx"),
+			Target: 0.99,
+			Window: model.Duration(28 * 24 * time.Hour),
+			Alerting: Alerting{
+				Burnrates: true,
+				Absent:    true,
+			},
+			Indicator: Indicator{
+				Logs: &LogsIndicator{
+					Errors: `{job="nginx"} | json | status >= 500`,
+					Total:  `{job="nginx"}`,
+				},
+			},
+		}
+	}
 )
 
 func TestObjective_QueryTotal(t *testing.T) {
@@ -1086,3 +1147,66 @@ func TestReplacer(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateExpression(t *testing.T) {
+	for query, expected := range map[string]string{
+		`checkout_failed_total`:                                         "",
+		`sum by (job) (checkout_failed_total{job="shop"}) * 2`:          "",
+		`label_replace(payment_declined_total, "a", "$1", "b", "(.*)")`: "",
+		`checkout_failed_total{`:                                        "1:23: parse error: unexpected end of input inside braces",
+		`scalar(checkout_failed_total)`:                                 "expression must return an instant vector, not a scalar",
+		`vector(1)`:                                                     "expression must select series",
+		`rate(checkout_failed_total[5m])`:                               "expression must not select ranges like checkout_failed_total[5m], the rules select the ranges of its series",
+		`max_over_time(checkout_failed_total[1h:5m])`:                   "expression must not contain subqueries like checkout_failed_total[1h:5m]",
+	} {
+		t.Run(query, func(t *testing.T) {
+			err := ValidateExpression(query)
+			if expected == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, expected)
+		})
+	}
+}
+
+func TestObjective_QueryExpression(t *testing.T) {
+	o := objectiveCheckoutExpression()
+
+	require.Equal(t, `sum(pyrra_requests:increase4w{slo="checkout"})`, o.QueryTotal(o.Window))
+	require.Equal(t, `sum(pyrra_errors:increase4w{slo="checkout"})`, o.QueryErrors(o.Window))
+	require.Equal(t, `((1 - 0.99) - (sum(pyrra_errors:increase4w{slo="checkout"} or vector(0)) / sum(pyrra_requests:increase4w{slo="checkout"}))) / (1 - 0.99)`, o.QueryErrorBudget())
+
+	burnrate, err := o.QueryBurnrate(time.Hour, nil)
+	require.NoError(t, err)
+	require.Equal(t, `pyrra_requests:burnrate1h{slo="checkout"}`, burnrate)
+
+	require.Equal(t, `sum(rate(checkout_started_total{job="shop"}[5m]))`, o.RequestRange(5*time.Minute))
+	require.Equal(t,
+		`sum(rate(checkout_failed_total{job="shop"}[5m]) + rate(payment_declined_total[5m])) / sum(rate(checkout_started_total{job="shop"}[5m]))`,
+		o.ErrorsRange(5*time.Minute),
+	)
+
+	o.Indicator.Expression.Grouping = []string{"region", "job"}
+	require.Equal(t, `sum by (region, job) (pyrra_requests:increase4w{slo="checkout"})`, o.QueryTotal(o.Window))
+}
+
+func TestObjective_QueryLogs(t *testing.T) {
+	o := objectiveNginxLogs()
+
+	// The queries use the metrics the Loki ruler recorded to Prometheus.
+	require.Equal(t, `sum(pyrra_log_lines:increase4w{slo="nginx"})`, o.QueryTotal(o.Window))
+	require.Equal(t, `sum(pyrra_log_errors:increase4w{slo="nginx"})`, o.QueryErrors(o.Window))
+	require.Equal(t, `((1 - 0.99) - (sum(pyrra_log_errors:increase4w{slo="nginx"} or vector(0)) / sum(pyrra_log_lines:increase4w{slo="nginx"}))) / (1 - 0.99)`, o.QueryErrorBudget())
+
+	burnrate, err := o.QueryBurnrate(time.Hour, nil)
+	require.NoError(t, err)
+	require.Equal(t, `pyrra_log_lines:burnrate1h{slo="nginx"}`, burnrate)
+
+	// The log queries can't be queried from Prometheus.
+	require.Empty(t, o.RequestRange(5*time.Minute))
+	require.Empty(t, o.ErrorsRange(5*time.Minute))
+
+	o.Indicator.Logs.Grouping = []string{"host"}
+	require.Equal(t, `sum by (host) (pyrra_log_lines:increase4w{slo="nginx"})`, o.QueryTotal(o.Window))
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	monitoringv1alpha1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1alpha1"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/promql/parser"
@@ -17,6 +18,61 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// Suffixes of the names of the rule groups generated for an objective, appended to its name.
+// The burn rate rule group is named after the objective without a suffix.
+const (
+	IncreaseRuleGroupSuffix     = "-increase"
+	GenericRuleGroupSuffix      = "-generic"
+	BudgetFreezeRuleGroupSuffix = "-budget-freeze"
+	SLARuleGroupSuffix          = "-sla"
+	CalendarRuleGroupSuffix     = "-calendar"
+)
+
+// RuleGroupSuffixes are the suffixes of all rule groups that can be generated for an objective,
+// including the empty one of the burn rate rule group.
+var RuleGroupSuffixes = []string{
+	"",
+	IncreaseRuleGroupSuffix,
+	GenericRuleGroupSuffix,
+	BudgetFreezeRuleGroupSuffix,
+	SLARuleGroupSuffix,
+	CalendarRuleGroupSuffix,
+}
+
+const (
+	// BurnWindowLabel is added to the burn rate alerts of objectives with Alerting.Inhibit,
+	// BurnWindowFast for the critical ones and BurnWindowSlow for the others.
+	BurnWindowLabel = "burn_window"
+	BurnWindowFast  = "fast"
+	BurnWindowSlow  = "slow"
+)
+
+// InhibitRules returns the Alertmanager inhibition rules that mute the slow burning alerts of the objective
+// while its fast burning alert for the same series fires, so only one of them pages.
+// There are none unless the objective has Alerting.Inhibit and burn rate alerts.
+func (o Objective) InhibitRules() []monitoringv1alpha1.InhibitRule {
+	if !o.Alerting.Inhibit || o.Alerting.Disabled || !o.Alerting.Burnrates {
+		return nil
+	}
+
+	sloName := o.Labels.Get(labels.MetricName)
+	matchers := func(burnWindow string) []monitoringv1alpha1.Matcher {
+		return []monitoringv1alpha1.Matcher{
+			{Name: labels.AlertName, Value: o.AlertName(), MatchType: monitoringv1alpha1.MatchEqual},
+			{Name: "slo", Value: sloName, MatchType: monitoringv1alpha1.MatchEqual},
+			{Name: BurnWindowLabel, Value: burnWindow, MatchType: monitoringv1alpha1.MatchEqual},
+		}
+	}
+	equal := append([]string{"slo"}, o.Grouping()...)
+	sort.Strings(equal[1:])
+
+	return []monitoringv1alpha1.InhibitRule{{
+		SourceMatch: matchers(BurnWindowFast),
+		TargetMatch: matchers(BurnWindowSlow),
+		Equal:       equal,
+	}}
+}
+
 type MultiBurnRateAlert struct {
 	Severity string
 	Short    time.Duration
@@ -29,7 +85,7 @@ type MultiBurnRateAlert struct {
 }
 
 func (o Objective) Alerts() ([]MultiBurnRateAlert, error) {
-	ws := Windows(time.Duration(o.Window))
+	ws := o.Windows()
 
 	mbras := make([]MultiBurnRateAlert, len(ws))
 	for i, w := range ws {
@@ -56,10 +112,20 @@ func (o Objective) Alerts() ([]MultiBurnRateAlert, error) {
 	return mbras, nil
 }
 
+// Burnrates returns the rule group recording the objective's burn rates and alerting on them.
+// With SharedBurnrates the burn rates are recorded by SharedBurnrates instead and only the alerts are left.
 func (o Objective) Burnrates() (monitoringv1.RuleGroup, error) {
+	group, err := o.burnrates()
+	if err != nil || !o.SharedBurnrates {
+		return group, err
+	}
+	return o.shareBurnrates(group)
+}
+
+func (o Objective) burnrates() (monitoringv1.RuleGroup, error) {
 	sloName := o.Labels.Get(labels.MetricName)
 
-	ws := Windows(time.Duration(o.Window))
+	ws := o.Windows()
 	burnrates := burnratesFromWindows(ws)
 	rules := make([]monitoringv1.Rule, 0, len(burnrates))
 
@@ -132,11 +198,16 @@ func (o Objective) Burnrates() (monitoringv1.RuleGroup, error) {
 			alertLabels["long"] = model.Duration(w.Long).String()
 			alertLabels["severity"] = string(w.Severity)
 			alertLabels["exhaustion"] = o.Exhausts(w.Factor).String()
+			if o.Alerting.Inhibit {
+				alertLabels[BurnWindowLabel] = w.burnWindow()
+			}
+			alertAnnotations = o.Alerting.severityRouting(string(w.Severity), alertLabels, alertAnnotations)
+			alertAnnotations = w.routing(alertLabels, alertAnnotations)
 
 			r := monitoringv1.Rule{
 				Alert: o.AlertName(),
 				// TODO: Use expr replacer
-				Expr: intstr.FromString(fmt.Sprintf("%s{%s} > (%.f * (1-%s)) and %s{%s} > (%.f * (1-%s))",
+				Expr: intstr.FromString(o.Alerting.muted(fmt.Sprintf("%s{%s} > (%g * (1-%s)) and %s{%s} > (%g * (1-%s))",
 					o.BurnrateName(w.Short),
 					alertMatchersString,
 					w.Factor,
@@ -145,10 +216,11 @@ func (o Objective) Burnrates() (monitoringv1.RuleGroup, error) {
 					alertMatchersString,
 					w.Factor,
 					strconv.FormatFloat(o.Target, 'f', -1, 64),
-				)),
-				For:         monitoringDuration(w.For.String()),
-				Labels:      alertLabels,
-				Annotations: alertAnnotations,
+				))),
+				For:           monitoringDuration(w.For.String()),
+				KeepFiringFor: o.Alerting.keepFiringFor(),
+				Labels:        alertLabels,
+				Annotations:   alertAnnotations,
 			}
 			rules = append(rules, r)
 		}
@@ -220,11 +292,16 @@ func (o Objective) Burnrates() (monitoringv1.RuleGroup, error) {
 			alertLabels["long"] = model.Duration(w.Long).String()
 			alertLabels["severity"] = string(w.Severity)
 			alertLabels["exhaustion"] = o.Exhausts(w.Factor).String()
+			if o.Alerting.Inhibit {
+				alertLabels[BurnWindowLabel] = w.burnWindow()
+			}
+			alertAnnotations = o.Alerting.severityRouting(string(w.Severity), alertLabels, alertAnnotations)
+			alertAnnotations = w.routing(alertLabels, alertAnnotations)
 
 			r := monitoringv1.Rule{
 				Alert: o.AlertName(),
 				// TODO: Use expr replacer
-				Expr: intstr.FromString(fmt.Sprintf("%s{%s} > (%.f * (1-%s)) and %s{%s} > (%.f * (1-%s))",
+				Expr: intstr.FromString(o.Alerting.muted(fmt.Sprintf("%s{%s} > (%g * (1-%s)) and %s{%s} > (%g * (1-%s))",
 					o.BurnrateName(w.Short),
 					alertMatchersString,
 					w.Factor,
@@ -233,10 +310,11 @@ func (o Objective) Burnrates() (monitoringv1.RuleGroup, error) {
 					alertMatchersString,
 					w.Factor,
 					strconv.FormatFloat(o.Target, 'f', -1, 64),
-				)),
-				For:         monitoringDuration(model.Duration(w.For).String()),
-				Labels:      alertLabels,
-				Annotations: alertAnnotations,
+				))),
+				For:           monitoringDuration(model.Duration(w.For).String()),
+				KeepFiringFor: o.Alerting.keepFiringFor(),
+				Labels:        alertLabels,
+				Annotations:   alertAnnotations,
 			}
 			rules = append(rules, r)
 		}
@@ -308,11 +386,16 @@ func (o Objective) Burnrates() (monitoringv1.RuleGroup, error) {
 			alertLabels["long"] = model.Duration(w.Long).String()
 			alertLabels["severity"] = string(w.Severity)
 			alertLabels["exhaustion"] = o.Exhausts(w.Factor).String()
+			if o.Alerting.Inhibit {
+				alertLabels[BurnWindowLabel] = w.burnWindow()
+			}
+			alertAnnotations = o.Alerting.severityRouting(string(w.Severity), alertLabels, alertAnnotations)
+			alertAnnotations = w.routing(alertLabels, alertAnnotations)
 
 			r := monitoringv1.Rule{
 				Alert: o.AlertName(),
 				// TODO: Use expr replacer
-				Expr: intstr.FromString(fmt.Sprintf("%s{%s} > (%.f * (1-%s)) and %s{%s} > (%.f * (1-%s))",
+				Expr: intstr.FromString(o.Alerting.muted(fmt.Sprintf("%s{%s} > (%g * (1-%s)) and %s{%s} > (%g * (1-%s))",
 					o.BurnrateName(w.Short),
 					alertMatchersString,
 					w.Factor,
@@ -321,14 +404,23 @@ func (o Objective) Burnrates() (monitoringv1.RuleGroup, error) {
 					alertMatchersString,
 					w.Factor,
 					strconv.FormatFloat(o.Target, 'f', -1, 64),
-				)),
-				For:         monitoringDuration(model.Duration(w.For).String()),
-				Labels:      alertLabels,
-				Annotations: alertAnnotations,
+				))),
+				For:           monitoringDuration(model.Duration(w.For).String()),
+				KeepFiringFor: o.Alerting.keepFiringFor(),
+				Labels:        alertLabels,
+				Annotations:   alertAnnotations,
 			}
 			rules = append(rules, r)
 		}
 	case BoolGauge:
+		if o.Indicator.Quantile != nil {
+			rule, err := o.quantileRule(sloName)
+			if err != nil {
+				return monitoringv1.RuleGroup{}, err
+			}
+			rules = append(rules, rule)
+		}
+
 		matchers := o.Indicator.BoolGauge.LabelMatchers
 
 		groupingMap := map[string]struct{}{}
@@ -378,6 +470,8 @@ func (o Objective) Burnrates() (monitoringv1.RuleGroup, error) {
 		}
 		alertMatchers = append(alertMatchers, fmt.Sprintf(`slo="%s"`, sloName))
 		sort.Strings(alertMatchers)
+		// The series recorded for quantile indicators are matched by their slo label already.
+		alertMatchers = slices.Compact(alertMatchers)
 		alertMatchersString := strings.Join(alertMatchers, ",")
 
 		for _, w := range ws {
@@ -396,11 +490,16 @@ func (o Objective) Burnrates() (monitoringv1.RuleGroup, error) {
 			alertLabels["long"] = model.Duration(w.Long).String()
 			alertLabels["severity"] = string(w.Severity)
 			alertLabels["exhaustion"] = o.Exhausts(w.Factor).String()
+			if o.Alerting.Inhibit {
+				alertLabels[BurnWindowLabel] = w.burnWindow()
+			}
+			alertAnnotations = o.Alerting.severityRouting(string(w.Severity), alertLabels, alertAnnotations)
+			alertAnnotations = w.routing(alertLabels, alertAnnotations)
 
 			r := monitoringv1.Rule{
 				Alert: o.AlertName(),
 				// TODO: Use expr replacer
-				Expr: intstr.FromString(fmt.Sprintf("%s{%s} > (%.f * (1-%s)) and %s{%s} > (%.f * (1-%s))",
+				Expr: intstr.FromString(o.Alerting.muted(fmt.Sprintf("%s{%s} > (%g * (1-%s)) and %s{%s} > (%g * (1-%s))",
 					o.BurnrateName(w.Short),
 					alertMatchersString,
 					w.Factor,
@@ -409,13 +508,85 @@ func (o Objective) Burnrates() (monitoringv1.RuleGroup, error) {
 					alertMatchersString,
 					w.Factor,
 					strconv.FormatFloat(o.Target, 'f', -1, 64),
-				)),
-				For:         monitoringDuration(model.Duration(w.For).String()),
-				Labels:      alertLabels,
-				Annotations: alertAnnotations,
+				))),
+				For:           monitoringDuration(model.Duration(w.For).String()),
+				KeepFiringFor: o.Alerting.keepFiringFor(),
+				Labels:        alertLabels,
+				Annotations:   alertAnnotations,
 			}
 			rules = append(rules, r)
 		}
+	case Expression, Logs, Composite:
+		if o.IndicatorType() == Composite {
+			if err := o.Indicator.Composite.Validate(); err != nil {
+				return monitoringv1.RuleGroup{}, err
+			}
+		}
+
+		ruleLabels := o.commonRuleLabels(sloName)
+		for _, br := range burnrates {
+			rules = append(rules, monitoringv1.Rule{
+				Record: o.BurnrateName(br),
+				Expr:   intstr.FromString(o.Burnrate(br)),
+				Labels: ruleLabels,
+			})
+		}
+
+		if o.Alerting.Disabled || !o.Alerting.Burnrates {
+			return monitoringv1.RuleGroup{
+				Name:     sloName,
+				Interval: monitoringDuration("30s"), // TODO: Increase or decrease based on availability target
+				Rules:    rules,
+			}, nil
+		}
+
+		alertMatchersString := fmt.Sprintf(`slo="%s"`, sloName)
+		for _, w := range ws {
+			alertLabels := o.commonRuleLabels(sloName)
+			alertAnnotations := o.commonRuleAnnotations()
+
+			// Propagate useful SLO information to alerts' labels
+			alertLabels["short"] = model.Duration(w.Short).String()
+			alertLabels["long"] = model.Duration(w.Long).String()
+			alertLabels["severity"] = string(w.Severity)
+			alertLabels["exhaustion"] = o.Exhausts(w.Factor).String()
+			if o.Alerting.Inhibit {
+				alertLabels[BurnWindowLabel] = w.burnWindow()
+			}
+			alertAnnotations = o.Alerting.severityRouting(string(w.Severity), alertLabels, alertAnnotations)
+			alertAnnotations = w.routing(alertLabels, alertAnnotations)
+
+			expr := fmt.Sprintf("%s{%s} > (%g * (1-%s)) and %s{%s} > (%g * (1-%s))",
+				o.BurnrateName(w.Short),
+				alertMatchersString,
+				w.Factor,
+				strconv.FormatFloat(o.Target, 'f', -1, 64),
+				o.BurnrateName(w.Long),
+				alertMatchersString,
+				w.Factor,
+				strconv.FormatFloat(o.Target, 'f', -1, 64),
+			)
+			if o.IndicatorType() == Logs {
+				// The Loki ruler can't query the recorded burn rates, so the alerts count the log lines themselves.
+				expr = fmt.Sprintf("(%s) > (%g * (1-%s)) and (%s) > (%g * (1-%s))",
+					o.Burnrate(w.Short),
+					w.Factor,
+					strconv.FormatFloat(o.Target, 'f', -1, 64),
+					o.Burnrate(w.Long),
+					w.Factor,
+					strconv.FormatFloat(o.Target, 'f', -1, 64),
+				)
+			}
+
+			rules = append(rules, monitoringv1.Rule{
+				Alert:         o.AlertName(),
+				Expr:          intstr.FromString(o.Alerting.muted(expr)),
+				For:           monitoringDuration(model.Duration(w.For).String()),
+				KeepFiringFor: o.Alerting.keepFiringFor(),
+				Labels:        alertLabels,
+				Annotations:   alertAnnotations,
+			})
+		}
 	}
 
 	// We only get here if alerting was not disabled
@@ -438,6 +609,12 @@ func (o Objective) BurnrateName(rate time.Duration) string {
 		metric = o.Indicator.LatencyNative.Total.Name
 	case BoolGauge:
 		metric = o.Indicator.BoolGauge.Name
+	case Expression:
+		metric = expressionTotalMetric
+	case Logs:
+		metric = logsTotalMetric
+	case Composite:
+		metric = compositeMetric
 	}
 
 	metric = strings.TrimSuffix(metric, "_total")
@@ -571,11 +748,53 @@ func (o Objective) Burnrate(timerange time.Duration) string {
 		}.replace(expr)
 
 		return expr.String()
+	case Expression:
+		expr, err := o.Indicator.Expression.ratio("rate", timerange)
+		if err != nil {
+			return err.Error()
+		}
+		return expr
+	case Logs:
+		return o.Indicator.Logs.ratio(timerange)
+	case Composite:
+		return o.Indicator.Composite.ratio(timerange)
 	default:
 		return ""
 	}
 }
 
+// quantileRule records whether the quantile of the latency over the interval is faster than the latency,
+// as the series the objective's BoolGauge is evaluated on.
+// Intervals without any requests have no quantile and are left out instead of being recorded as slow.
+func (o Objective) quantileRule(sloName string) (monitoringv1.Rule, error) {
+	q := o.Indicator.Quantile
+
+	expr, err := parser.ParseExpr(`histogram_quantile(0.420, sum by (grouping) (rate(metric{matchers="total"}[1s]))) >= 0 <= bool 0.696969`)
+	if err != nil {
+		return monitoringv1.Rule{}, err
+	}
+
+	grouping := slices.Clone(q.Grouping)
+	if !q.Native() {
+		grouping = append(grouping, labels.BucketLabel)
+	}
+
+	objectiveReplacer{
+		metric:     q.Total.Name,
+		matchers:   slices.Clone(q.Total.LabelMatchers),
+		grouping:   grouping,
+		window:     time.Duration(q.Interval),
+		target:     time.Duration(q.Latency).Seconds(),
+		percentile: q.Quantile,
+	}.replace(expr)
+
+	return monitoringv1.Rule{
+		Record: q.RecordName(),
+		Expr:   intstr.FromString(expr.String()),
+		Labels: o.commonRuleLabels(sloName),
+	}, nil
+}
+
 func sumName(metric string, window model.Duration) string {
 	return fmt.Sprintf("%s:sum%s", metric, window)
 }
@@ -584,17 +803,31 @@ func countName(metric string, window model.Duration) string {
 	return fmt.Sprintf("%s:count%s", metric, window)
 }
 
-func increaseName(metric string, window model.Duration) string {
+// The recording rules of expression indicators are named after these metrics,
+// as their expressions can select any number of metrics.
+const (
+	expressionErrorsMetric = "pyrra_errors"
+	expressionTotalMetric  = "pyrra_requests"
+)
+
+// increaseName returns the name of the increase recording rule of the metric over the window.
+// Objectives with stable rule names leave out their window, so the rules keep their history if the window changes.
+func (o Objective) increaseName(metric string, window model.Duration) string {
 	metric = strings.TrimSuffix(metric, "_total")
 	metric = strings.TrimSuffix(metric, "_count")
 	metric = strings.TrimSuffix(metric, "_bucket")
+	if o.StableRuleNames && window == o.Window {
+		return fmt.Sprintf("%s:increase", metric)
+	}
 	return fmt.Sprintf("%s:increase%s", metric, window)
 }
 
 func (o Objective) commonRuleLabels(sloName string) map[string]string {
-	ruleLabels := map[string]string{
-		"slo": sloName,
+	ruleLabels := make(map[string]string, len(o.ExternalLabels)+1)
+	for name, value := range o.ExternalLabels {
+		ruleLabels[name] = value
 	}
+	ruleLabels["slo"] = sloName
 
 	for _, label := range o.Labels {
 		if strings.HasPrefix(label.Name, PropagationLabelsPrefix) {
@@ -634,8 +867,23 @@ func (o Objective) IncreaseRules() (monitoringv1.RuleGroup, error) {
 		return parser.ParseExpr(`sum by (grouping) (increase(metric{matchers="total"}[1s]))`)
 	}
 
-	absentExpr := func() (parser.Expr, error) {
-		return parser.ParseExpr(`absent(metric{matchers="total"}) == 1`)
+	absentExpr := func(metric Metric) (parser.Expr, error) {
+		query := `absent(metric{matchers="total"}) == 1`
+		if o.Alerting.AbsentOverTime > 0 {
+			query = `absent_over_time(metric{matchers="total"}[1s]) == 1`
+		}
+		expr, err := parser.ParseExpr(query)
+		if err != nil {
+			return nil, err
+		}
+
+		objectiveReplacer{
+			metric:   metric.Name,
+			matchers: metric.LabelMatchers,
+			window:   o.Alerting.AbsentOverTime,
+		}.replace(expr)
+
+		return expr, nil
 	}
 
 	var rules []monitoringv1.Rule
@@ -688,7 +936,7 @@ func (o Objective) IncreaseRules() (monitoringv1.RuleGroup, error) {
 		}.replace(expr)
 
 		rules = append(rules, monitoringv1.Rule{
-			Record: increaseName(o.Indicator.Ratio.Total.Name, o.Window),
+			Record: o.increaseName(o.Indicator.Ratio.Total.Name, o.Window),
 			Expr:   intstr.FromString(expr.String()),
 			Labels: ruleLabels,
 		})
@@ -698,20 +946,16 @@ func (o Objective) IncreaseRules() (monitoringv1.RuleGroup, error) {
 			alertLabels[k] = v
 		}
 		// Add severity label for alerts
-		alertLabels["severity"] = string(critical)
+		alertLabels["severity"] = o.Alerting.absentSeverity()
+		alertAnnotations := o.Alerting.severityRouting(o.Alerting.absentSeverity(), alertLabels, o.commonRuleAnnotations())
 
 		// add the absent alert if configured
 		if o.Alerting.Absent {
-			expr, err = absentExpr()
+			expr, err = absentExpr(o.Indicator.Ratio.Total)
 			if err != nil {
 				return monitoringv1.RuleGroup{}, err
 			}
 
-			objectiveReplacer{
-				metric:   o.Indicator.Ratio.Total.Name,
-				matchers: o.Indicator.Ratio.Total.LabelMatchers,
-			}.replace(expr)
-
 			rules = append(rules, monitoringv1.Rule{
 				Alert: o.AlertNameAbsent(),
 				Expr:  intstr.FromString(expr.String()),
@@ -719,7 +963,7 @@ func (o Objective) IncreaseRules() (monitoringv1.RuleGroup, error) {
 					(time.Duration(o.Window) / (28 * 24 * (60 / 2))).Round(time.Minute),
 				).String()),
 				Labels:      alertLabels,
-				Annotations: o.commonRuleAnnotations(),
+				Annotations: alertAnnotations,
 			})
 		}
 
@@ -737,23 +981,18 @@ func (o Objective) IncreaseRules() (monitoringv1.RuleGroup, error) {
 			}.replace(expr)
 
 			rules = append(rules, monitoringv1.Rule{
-				Record: increaseName(o.Indicator.Ratio.Errors.Name, o.Window),
+				Record: o.increaseName(o.Indicator.Ratio.Errors.Name, o.Window),
 				Expr:   intstr.FromString(expr.String()),
 				Labels: ruleLabels,
 			})
 
 			// add the absent alert if configured
 			if o.Alerting.Absent {
-				expr, err = absentExpr()
+				expr, err = absentExpr(o.Indicator.Ratio.Errors)
 				if err != nil {
 					return monitoringv1.RuleGroup{}, err
 				}
 
-				objectiveReplacer{
-					metric:   o.Indicator.Ratio.Errors.Name,
-					matchers: o.Indicator.Ratio.Errors.LabelMatchers,
-				}.replace(expr)
-
 				rules = append(rules, monitoringv1.Rule{
 					Alert: o.AlertNameAbsent(),
 					Expr:  intstr.FromString(expr.String()),
@@ -761,7 +1000,7 @@ func (o Objective) IncreaseRules() (monitoringv1.RuleGroup, error) {
 						(time.Duration(o.Window) / (28 * 24 * (60 / 2))).Round(time.Minute),
 					).String()),
 					Labels:      alertLabels,
-					Annotations: o.commonRuleAnnotations(),
+					Annotations: alertAnnotations,
 				})
 			}
 		}
@@ -812,7 +1051,7 @@ func (o Objective) IncreaseRules() (monitoringv1.RuleGroup, error) {
 		}.replace(expr)
 
 		rules = append(rules, monitoringv1.Rule{
-			Record: increaseName(o.Indicator.Latency.Total.Name, o.Window),
+			Record: o.increaseName(o.Indicator.Latency.Total.Name, o.Window),
 			Expr:   intstr.FromString(expr.String()),
 			Labels: ruleLabels,
 		})
@@ -842,29 +1081,25 @@ func (o Objective) IncreaseRules() (monitoringv1.RuleGroup, error) {
 		}
 
 		rules = append(rules, monitoringv1.Rule{
-			Record: increaseName(o.Indicator.Latency.Success.Name, o.Window),
+			Record: o.increaseName(o.Indicator.Latency.Success.Name, o.Window),
 			Expr:   intstr.FromString(expr.String()),
 			Labels: ruleLabelsLe,
 		})
 
 		// add the absent alert if configured
 		if o.Alerting.Absent {
-			expr, err = absentExpr()
+			expr, err = absentExpr(o.Indicator.Latency.Total)
 			if err != nil {
 				return monitoringv1.RuleGroup{}, err
 			}
 
-			objectiveReplacer{
-				metric:   o.Indicator.Latency.Total.Name,
-				matchers: o.Indicator.Latency.Total.LabelMatchers,
-			}.replace(expr)
-
 			alertLabels := make(map[string]string, len(ruleLabels)+1)
 			for k, v := range ruleLabels {
 				alertLabels[k] = v
 			}
 			// Add severity label for alerts
-			alertLabels["severity"] = string(critical)
+			alertLabels["severity"] = o.Alerting.absentSeverity()
+			alertAnnotations := o.Alerting.severityRouting(o.Alerting.absentSeverity(), alertLabels, o.commonRuleAnnotations())
 
 			rules = append(rules, monitoringv1.Rule{
 				Alert: o.AlertNameAbsent(),
@@ -873,25 +1108,21 @@ func (o Objective) IncreaseRules() (monitoringv1.RuleGroup, error) {
 					(time.Duration(o.Window) / (28 * 24 * (60 / 2))).Round(time.Minute),
 				).String()),
 				Labels:      alertLabels,
-				Annotations: o.commonRuleAnnotations(),
+				Annotations: alertAnnotations,
 			})
 
-			expr, err = absentExpr()
+			expr, err = absentExpr(o.Indicator.Latency.Success)
 			if err != nil {
 				return monitoringv1.RuleGroup{}, err
 			}
 
-			objectiveReplacer{
-				metric:   o.Indicator.Latency.Success.Name,
-				matchers: o.Indicator.Latency.Success.LabelMatchers,
-			}.replace(expr)
-
 			alertLabelsLe := make(map[string]string, len(ruleLabelsLe)+1)
 			for k, v := range ruleLabelsLe {
 				alertLabelsLe[k] = v
 			}
 			// Add severity label for alerts
-			alertLabelsLe["severity"] = string(critical)
+			alertLabelsLe["severity"] = o.Alerting.absentSeverity()
+			alertAnnotationsLe := o.Alerting.severityRouting(o.Alerting.absentSeverity(), alertLabelsLe, o.commonRuleAnnotations())
 
 			rules = append(rules, monitoringv1.Rule{
 				Alert: o.AlertNameAbsent(),
@@ -900,7 +1131,7 @@ func (o Objective) IncreaseRules() (monitoringv1.RuleGroup, error) {
 					(time.Duration(o.Window) / (28 * 24 * (60 / 2))).Round(time.Minute),
 				).String()),
 				Labels:      alertLabelsLe,
-				Annotations: o.commonRuleAnnotations(),
+				Annotations: alertAnnotationsLe,
 			})
 		}
 	case LatencyNative:
@@ -924,7 +1155,7 @@ func (o Objective) IncreaseRules() (monitoringv1.RuleGroup, error) {
 		}.replace(expr)
 
 		rules = append(rules, monitoringv1.Rule{
-			Record: increaseName(o.Indicator.LatencyNative.Total.Name, o.Window),
+			Record: o.increaseName(o.Indicator.LatencyNative.Total.Name, o.Window),
 			Expr:   intstr.FromString(expr.String()),
 			Labels: ruleLabels,
 		})
@@ -943,14 +1174,37 @@ func (o Objective) IncreaseRules() (monitoringv1.RuleGroup, error) {
 			target:   latencySeconds,
 		}.replace(expr)
 
+		alertLabels := maps.Clone(ruleLabels)
+
 		ruleLabels = maps.Clone(ruleLabels)
 		ruleLabels["le"] = fmt.Sprintf("%g", latencySeconds)
 
 		rules = append(rules, monitoringv1.Rule{
-			Record: increaseName(o.Indicator.LatencyNative.Total.Name, o.Window),
+			Record: o.increaseName(o.Indicator.LatencyNative.Total.Name, o.Window),
 			Expr:   intstr.FromString(expr.String()),
 			Labels: ruleLabels,
 		})
+
+		if o.Alerting.Absent {
+			expr, err = absentExpr(o.Indicator.LatencyNative.Total)
+			if err != nil {
+				return monitoringv1.RuleGroup{}, err
+			}
+
+			// Add severity label for alerts
+			alertLabels["severity"] = o.Alerting.absentSeverity()
+			alertAnnotations := o.Alerting.severityRouting(o.Alerting.absentSeverity(), alertLabels, o.commonRuleAnnotations())
+
+			rules = append(rules, monitoringv1.Rule{
+				Alert: o.AlertNameAbsent(),
+				Expr:  intstr.FromString(expr.String()),
+				For: monitoringDuration(model.Duration(
+					(time.Duration(o.Window) / (28 * 24 * (60 / 2))).Round(time.Minute),
+				).String()),
+				Labels:      alertLabels,
+				Annotations: alertAnnotations,
+			})
+		}
 	case BoolGauge:
 		ruleLabels := o.commonRuleLabels(sloName)
 		for _, m := range o.Indicator.BoolGauge.LabelMatchers {
@@ -1019,22 +1273,23 @@ func (o Objective) IncreaseRules() (monitoringv1.RuleGroup, error) {
 		})
 
 		if o.Alerting.Absent {
-			expr, err := absentExpr()
+			absent := o.Indicator.BoolGauge.Metric
+			if o.Indicator.Quantile != nil {
+				// The recorded series is absent if the histogram is.
+				absent = o.Indicator.Quantile.Total
+			}
+			expr, err := absentExpr(absent)
 			if err != nil {
 				return monitoringv1.RuleGroup{}, err
 			}
 
-			objectiveReplacer{
-				metric:   o.Indicator.BoolGauge.Name,
-				matchers: o.Indicator.BoolGauge.LabelMatchers,
-			}.replace(expr)
-
 			alertLabels := make(map[string]string, len(ruleLabels)+1)
 			for k, v := range ruleLabels {
 				alertLabels[k] = v
 			}
 			// Add severity label for alerts
-			alertLabels["severity"] = string(critical)
+			alertLabels["severity"] = o.Alerting.absentSeverity()
+			alertAnnotations := o.Alerting.severityRouting(o.Alerting.absentSeverity(), alertLabels, o.commonRuleAnnotations())
 
 			rules = append(rules, monitoringv1.Rule{
 				Alert: o.AlertNameAbsent(),
@@ -1043,9 +1298,48 @@ func (o Objective) IncreaseRules() (monitoringv1.RuleGroup, error) {
 					(time.Duration(o.Window) / (28 * 24 * (60 / 2))).Round(time.Minute),
 				).String()),
 				Labels:      alertLabels,
-				Annotations: o.commonRuleAnnotations(),
+				Annotations: alertAnnotations,
+			})
+		}
+	case Expression:
+		ruleLabels := o.commonRuleLabels(sloName)
+		for _, metric := range []struct{ name, query string }{
+			{name: expressionTotalMetric, query: o.Indicator.Expression.Total},
+			{name: expressionErrorsMetric, query: o.Indicator.Expression.Errors},
+		} {
+			expr, err := o.Indicator.Expression.sum(metric.query, "increase", time.Duration(o.Window))
+			if err != nil {
+				return monitoringv1.RuleGroup{}, err
+			}
+
+			rules = append(rules, monitoringv1.Rule{
+				Record: o.increaseName(metric.name, o.Window),
+				Expr:   intstr.FromString(expr.String()),
+				Labels: ruleLabels,
+			})
+		}
+	case Logs:
+		ruleLabels := o.commonRuleLabels(sloName)
+		for _, metric := range []struct{ name, query string }{
+			{name: logsTotalMetric, query: o.Indicator.Logs.Total},
+			{name: logsErrorsMetric, query: o.Indicator.Logs.Errors},
+		} {
+			rules = append(rules, monitoringv1.Rule{
+				Record: o.increaseName(metric.name, o.Window),
+				Expr:   intstr.FromString(o.Indicator.Logs.sum(metric.query, "count_over_time", time.Duration(o.Window))),
+				Labels: ruleLabels,
 			})
 		}
+	case Composite:
+		// There are no requests and errors to count, the error ratio over the window is recorded instead.
+		if err := o.Indicator.Composite.Validate(); err != nil {
+			return monitoringv1.RuleGroup{}, err
+		}
+		rules = append(rules, monitoringv1.Rule{
+			Record: o.BurnrateName(time.Duration(o.Window)),
+			Expr:   intstr.FromString(o.Burnrate(time.Duration(o.Window))),
+			Labels: o.commonRuleLabels(sloName),
+		})
 	}
 
 	day := 24 * time.Hour
@@ -1073,25 +1367,54 @@ func (o Objective) IncreaseRules() (monitoringv1.RuleGroup, error) {
 	}
 
 	return monitoringv1.RuleGroup{
-		Name:     sloName + "-increase",
+		Name:     sloName + IncreaseRuleGroupSuffix,
 		Interval: monitoringDuration(interval.String()),
 		Rules:    rules,
 	}, nil
 }
 
-type severity string
+// Severity of the burn rate alerts, critical or warning.
+type Severity string
 
 const (
-	critical severity = "critical"
-	warning  severity = "warning"
+	critical Severity = "critical"
+	warning  Severity = "warning"
 )
 
 type Window struct {
-	Severity severity
+	Severity Severity
 	For      time.Duration
 	Long     time.Duration
 	Short    time.Duration
 	Factor   float64
+	// Labels and Annotations are added to the window's alert and take precedence over the ones of the alerting.
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// burnWindow returns the value of the window's BurnWindowLabel, fast for critical windows and slow for the others.
+func (w Window) burnWindow() string {
+	if w.Severity == critical {
+		return BurnWindowFast
+	}
+	return BurnWindowSlow
+}
+
+// routing adds the window's labels to the alert labels and returns the annotations with the window's annotations added.
+func (w Window) routing(labels, annotations map[string]string) map[string]string {
+	for name, value := range w.Labels {
+		labels[name] = value
+	}
+	if len(w.Annotations) == 0 {
+		return annotations
+	}
+	if annotations == nil {
+		annotations = make(map[string]string, len(w.Annotations))
+	}
+	for name, value := range w.Annotations {
+		annotations[name] = value
+	}
+	return annotations
 }
 
 func Windows(sloWindow time.Duration) []Window {
@@ -1175,7 +1498,7 @@ func (o Objective) GenericRules() (monitoringv1.RuleGroup, error) {
 			return monitoringv1.RuleGroup{}, err
 		}
 
-		totalIncreaseName := increaseName(o.Indicator.Ratio.Total.Name, o.Window)
+		totalIncreaseName := o.increaseName(o.Indicator.Ratio.Total.Name, o.Window)
 
 		// Copy the list of matchers to modify them
 		totalMatchers := make([]*labels.Matcher, 0, len(o.Indicator.Ratio.Total.LabelMatchers))
@@ -1197,7 +1520,7 @@ func (o Objective) GenericRules() (monitoringv1.RuleGroup, error) {
 			Value: o.Name(),
 		})
 
-		errorsIncreaseName := increaseName(o.Indicator.Ratio.Errors.Name, o.Window)
+		errorsIncreaseName := o.increaseName(o.Indicator.Ratio.Errors.Name, o.Window)
 
 		errorMatchers := make([]*labels.Matcher, 0, len(o.Indicator.Ratio.Errors.LabelMatchers))
 		for _, m := range o.Indicator.Ratio.Errors.LabelMatchers {
@@ -1277,7 +1600,7 @@ func (o Objective) GenericRules() (monitoringv1.RuleGroup, error) {
 				return monitoringv1.RuleGroup{}, err
 			}
 
-			metric := increaseName(o.Indicator.Latency.Total.Name, o.Window)
+			metric := o.increaseName(o.Indicator.Latency.Total.Name, o.Window)
 			matchers := o.Indicator.Latency.Total.LabelMatchers
 			for _, m := range matchers {
 				if m.Name == labels.MetricName {
@@ -1292,7 +1615,7 @@ func (o Objective) GenericRules() (monitoringv1.RuleGroup, error) {
 				Value: o.Name(),
 			})
 
-			errorMetric := increaseName(o.Indicator.Latency.Success.Name, o.Window)
+			errorMetric := o.increaseName(o.Indicator.Latency.Success.Name, o.Window)
 			errorMatchers := o.Indicator.Latency.Success.LabelMatchers
 			for _, m := range errorMatchers {
 				if m.Name == labels.MetricName {
@@ -1478,15 +1801,191 @@ func (o Objective) GenericRules() (monitoringv1.RuleGroup, error) {
 				Labels: ruleLabels,
 			})
 		}
+	case Expression:
+		if len(o.Indicator.Expression.Grouping) > 0 {
+			return monitoringv1.RuleGroup{}, ErrGroupingUnsupported
+		}
+
+		availability, err := parser.ParseExpr(`1 - sum(errorMetric{matchers="errors"} or vector(0)) / sum(metric{matchers="total"})`)
+		if err != nil {
+			return monitoringv1.RuleGroup{}, err
+		}
+
+		totalMetric := o.increaseName(expressionTotalMetric, o.Window)
+		errorMetric := o.increaseName(expressionErrorsMetric, o.Window)
+		objectiveReplacer{
+			metric:        totalMetric,
+			matchers:      o.expressionMatchers(totalMetric),
+			errorMetric:   errorMetric,
+			errorMatchers: o.expressionMatchers(errorMetric),
+		}.replace(availability)
+
+		rules = append(rules, monitoringv1.Rule{
+			Record: "pyrra_availability",
+			Expr:   intstr.FromString(availability.String()),
+			Labels: ruleLabels,
+		})
+
+		total, err := parser.ParseExpr(fmt.Sprintf("sum(%s)", o.Indicator.Expression.Total))
+		if err != nil {
+			return monitoringv1.RuleGroup{}, err
+		}
+
+		rules = append(rules, monitoringv1.Rule{
+			Record: "pyrra_requests_total",
+			Expr:   intstr.FromString(total.String()),
+			Labels: ruleLabels,
+		})
+
+		errors, err := parser.ParseExpr(fmt.Sprintf("sum(%s or vector(0))", o.Indicator.Expression.Errors))
+		if err != nil {
+			return monitoringv1.RuleGroup{}, err
+		}
+
+		rules = append(rules, monitoringv1.Rule{
+			Record: "pyrra_errors_total",
+			Expr:   intstr.FromString(errors.String()),
+			Labels: ruleLabels,
+		})
+	case Composite:
+		// Composites have no requests and errors of their own, only their availability is recorded.
+		rules = append(rules, monitoringv1.Rule{
+			Record: "pyrra_availability",
+			Expr:   intstr.FromString(fmt.Sprintf("1 - %s", o.compositeErrorRatio())),
+			Labels: ruleLabels,
+		})
 	}
 
 	return monitoringv1.RuleGroup{
-		Name:     sloName + "-generic",
+		Name:     sloName + GenericRuleGroupSuffix,
 		Interval: monitoringDuration("30s"),
 		Rules:    rules,
 	}, nil
 }
 
+// BudgetFreezeRules returns the rule group recording pyrra_budget_freeze,
+// which is 1 while less than BudgetFreeze of the error budget remains and 0 otherwise.
+// Deployment pipelines can gate releases on it with a single query.
+func (o Objective) BudgetFreezeRules() (monitoringv1.RuleGroup, error) {
+	sloName := o.Labels.Get(labels.MetricName)
+
+	if o.BudgetFreeze == nil {
+		return monitoringv1.RuleGroup{}, fmt.Errorf("objective %s has no budget freeze", sloName)
+	}
+
+	errorBudget := o.QueryErrorBudget()
+	if errorBudget == "" {
+		return monitoringv1.RuleGroup{}, fmt.Errorf("objective %s has no error budget query", sloName)
+	}
+
+	expr, err := parser.ParseExpr(fmt.Sprintf("(%s) < bool %s", errorBudget, strconv.FormatFloat(*o.BudgetFreeze, 'f', -1, 64)))
+	if err != nil {
+		return monitoringv1.RuleGroup{}, err
+	}
+
+	return monitoringv1.RuleGroup{
+		Name:     sloName + BudgetFreezeRuleGroupSuffix,
+		Interval: monitoringDuration("30s"),
+		Rules: []monitoringv1.Rule{{
+			Record: "pyrra_budget_freeze",
+			Expr:   intstr.FromString(expr.String()),
+			Labels: o.commonRuleLabels(sloName),
+		}},
+	}, nil
+}
+
+// SLARules returns the rule group recording the compliance with the SLA target next to the objective's own rules.
+// pyrra_sla_target is the SLA target, pyrra_sla_error_budget the remaining error budget of the SLA
+// and pyrra_sla_compliance is 1 while the SLA is met over the objective's window and 0 otherwise.
+func (o Objective) SLARules() (monitoringv1.RuleGroup, error) {
+	sloName := o.Labels.Get(labels.MetricName)
+
+	if o.SLATarget == nil {
+		return monitoringv1.RuleGroup{}, fmt.Errorf("objective %s has no SLA target", sloName)
+	}
+
+	sla := o
+	sla.Target = *o.SLATarget
+	errorBudget := sla.QueryErrorBudget()
+	if o.Calendar != "" {
+		var err error
+		if errorBudget, err = sla.calendarErrorBudget(); err != nil {
+			return monitoringv1.RuleGroup{}, err
+		}
+	}
+	if errorBudget == "" {
+		return monitoringv1.RuleGroup{}, fmt.Errorf("objective %s has no error budget query", sloName)
+	}
+
+	errorBudgetExpr, err := parser.ParseExpr(errorBudget)
+	if err != nil {
+		return monitoringv1.RuleGroup{}, err
+	}
+	complianceExpr, err := parser.ParseExpr(fmt.Sprintf("(%s) >= bool 0", errorBudget))
+	if err != nil {
+		return monitoringv1.RuleGroup{}, err
+	}
+
+	ruleLabels := o.commonRuleLabels(sloName)
+
+	return monitoringv1.RuleGroup{
+		Name:     sloName + SLARuleGroupSuffix,
+		Interval: monitoringDuration("30s"),
+		Rules: []monitoringv1.Rule{{
+			Record: "pyrra_sla_target",
+			Expr:   intstr.FromString(fmt.Sprintf("vector(%s)", strconv.FormatFloat(*o.SLATarget, 'f', -1, 64))),
+			Labels: ruleLabels,
+		}, {
+			Record: "pyrra_sla_error_budget",
+			Expr:   intstr.FromString(errorBudgetExpr.String()),
+			Labels: ruleLabels,
+		}, {
+			Record: "pyrra_sla_compliance",
+			Expr:   intstr.FromString(complianceExpr.String()),
+			Labels: ruleLabels,
+		}},
+	}, nil
+}
+
+// absentSeverity returns the severity label of the absent alerts.
+func (a Alerting) absentSeverity() string {
+	if a.AbsentSeverity != "" {
+		return a.AbsentSeverity
+	}
+	return string(critical)
+}
+
+// keepFiringFor returns the keep_firing_for of the burn rate alerts, nil if they stop firing right away.
+func (a Alerting) keepFiringFor() *monitoringv1.NonEmptyDuration {
+	if a.KeepFiringFor <= 0 {
+		return nil
+	}
+	d := monitoringv1.NonEmptyDuration(model.Duration(a.KeepFiringFor).String())
+	return &d
+}
+
+// RuleGroupOptions sets the group-level options of the alerting, like the partial_response_strategy, on the groups.
+func (a Alerting) RuleGroupOptions(groups []monitoringv1.RuleGroup) {
+	for i := range groups {
+		groups[i].PartialResponseStrategy = a.PartialResponseStrategy
+	}
+}
+
+// RuleGroupOptions sets the group-level options of the objective on the groups,
+// its RuleGroupInterval and RuleGroupLimit, and the ones of its alerting.
+func (o Objective) RuleGroupOptions(groups []monitoringv1.RuleGroup) {
+	o.Alerting.RuleGroupOptions(groups)
+	for i := range groups {
+		if o.RuleGroupInterval > 0 {
+			groups[i].Interval = monitoringDuration(model.Duration(o.RuleGroupInterval).String())
+		}
+		if o.RuleGroupLimit > 0 {
+			limit := o.RuleGroupLimit
+			groups[i].Limit = &limit
+		}
+	}
+}
+
 func monitoringDuration(d string) *monitoringv1.Duration {
 	md := monitoringv1.Duration(d)
 	return &md
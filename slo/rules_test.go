@@ -1,10 +1,15 @@
 package slo
 
 import (
+	"strings"
 	"testing"
 	"time"
 
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	monitoringv1alpha1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1alpha1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
@@ -1374,11 +1379,11 @@ func TestObjective_IncreaseRules(t *testing.T) {
 				Record: "http_request_duration_seconds:increase4w",
 				Expr:   intstr.FromString(`histogram_fraction(0, 1, increase(http_request_duration_seconds{code=~"2..",job="metrics-service-thanos-receive-default"}[4w])) * histogram_count(increase(http_request_duration_seconds{code=~"2..",job="metrics-service-thanos-receive-default"}[4w]))`),
 				Labels: map[string]string{"job": "metrics-service-thanos-receive-default", "slo": "monitoring-http-latency", "le": "1"},
-				//}, {
-				//	Alert:  "SLOMetricAbsent",
-				//	Expr:   intstr.FromString(`absent(http_request_duration_seconds{code=~"2..",job="metrics-service-thanos-receive-default"}) == 1`),
-				//	For:    monitoringDuration("2m"),
-				//	Labels: map[string]string{"job": "metrics-service-thanos-receive-default", "slo": "monitoring-http-latency", "severity": "critical"},
+			}, {
+				Alert:  "SLOMetricAbsent",
+				Expr:   intstr.FromString(`absent(http_request_duration_seconds{code=~"2..",job="metrics-service-thanos-receive-default"}) == 1`),
+				For:    monitoringDuration("2m"),
+				Labels: map[string]string{"job": "metrics-service-thanos-receive-default", "slo": "monitoring-http-latency", "severity": "critical"},
 			}},
 		},
 	}, {
@@ -1993,3 +1998,713 @@ func TestObjective_AlertNameMetricAbsent(t *testing.T) {
 		})
 	}
 }
+
+func TestObjective_SeverityRouting(t *testing.T) {
+	o := objectiveHTTPRatio()
+	o.Alerting.SeverityLabels = map[string]map[string]string{
+		"critical": {"pagerduty_service": "thanos", "pagerduty_urgency": "high"},
+		"warning":  {"pagerduty_service": "thanos", "pagerduty_urgency": "low"},
+	}
+	o.Alerting.SeverityAnnotations = map[string]map[string]string{
+		"critical": {"priority": "P1"},
+	}
+
+	o.Alerting.Labels = map[string]string{"team": "thanos", "pagerduty_urgency": "overridden"}
+
+	group, err := o.Burnrates()
+	require.NoError(t, err)
+
+	var alerts int
+	for _, r := range group.Rules {
+		if r.Alert == "" {
+			require.NotContains(t, r.Labels, "pagerduty_service")
+			require.NotContains(t, r.Labels, "team")
+			continue
+		}
+		alerts++
+
+		require.Equal(t, "thanos", r.Labels["team"])
+		severity := r.Labels["severity"]
+		for name, value := range o.Alerting.SeverityLabels[severity] {
+			require.Equal(t, value, r.Labels[name])
+		}
+		require.Equal(t, o.Alerting.SeverityAnnotations[severity], r.Annotations)
+	}
+	require.Equal(t, 4, alerts)
+
+	group, err = o.IncreaseRules()
+	require.NoError(t, err)
+
+	for _, r := range group.Rules {
+		if r.Alert == "" {
+			continue
+		}
+		require.Equal(t, "high", r.Labels["pagerduty_urgency"])
+		require.Equal(t, "thanos", r.Labels["team"])
+		require.Equal(t, map[string]string{"priority": "P1"}, r.Annotations)
+	}
+}
+
+func TestObjective_BudgetFreezeRules(t *testing.T) {
+	o := objectiveHTTPRatio()
+	_, err := o.BudgetFreezeRules()
+	require.EqualError(t, err, "objective monitoring-http-errors has no budget freeze")
+
+	freeze := 0.25
+	o.BudgetFreeze = &freeze
+
+	group, err := o.BudgetFreezeRules()
+	require.NoError(t, err)
+	require.Equal(t, monitoringv1.RuleGroup{
+		Name:     "monitoring-http-errors-budget-freeze",
+		Interval: monitoringDuration("30s"),
+		Rules: []monitoringv1.Rule{{
+			Record: "pyrra_budget_freeze",
+			Expr:   intstr.FromString(`(((1 - 0.99) - (sum(http_requests:increase4w{code=~"5..",job="thanos-receive-default",slo="monitoring-http-errors"} or vector(0)) / sum(http_requests:increase4w{job="thanos-receive-default",slo="monitoring-http-errors"}))) / (1 - 0.99)) < bool 0.25`),
+			Labels: map[string]string{"slo": "monitoring-http-errors"},
+		}},
+	}, group)
+}
+
+func TestObjective_SLARules(t *testing.T) {
+	o := objectiveHTTPRatio()
+	_, err := o.SLARules()
+	require.EqualError(t, err, "objective monitoring-http-errors has no SLA target")
+
+	sla := 0.95
+	o.SLATarget = &sla
+
+	group, err := o.SLARules()
+	require.NoError(t, err)
+	errorBudget := `((1 - 0.95) - (sum(http_requests:increase4w{code=~"5..",job="thanos-receive-default",slo="monitoring-http-errors"} or vector(0)) / sum(http_requests:increase4w{job="thanos-receive-default",slo="monitoring-http-errors"}))) / (1 - 0.95)`
+	require.Equal(t, monitoringv1.RuleGroup{
+		Name:     "monitoring-http-errors-sla",
+		Interval: monitoringDuration("30s"),
+		Rules: []monitoringv1.Rule{{
+			Record: "pyrra_sla_target",
+			Expr:   intstr.FromString(`vector(0.95)`),
+			Labels: map[string]string{"slo": "monitoring-http-errors"},
+		}, {
+			Record: "pyrra_sla_error_budget",
+			Expr:   intstr.FromString(errorBudget),
+			Labels: map[string]string{"slo": "monitoring-http-errors"},
+		}, {
+			Record: "pyrra_sla_compliance",
+			Expr:   intstr.FromString(`(` + errorBudget + `) >= bool 0`),
+			Labels: map[string]string{"slo": "monitoring-http-errors"},
+		}},
+	}, group)
+
+	// The objective's own error budget keeps using its target.
+	require.Contains(t, o.QueryErrorBudget(), "0.99")
+}
+
+func TestObjective_CalendarRules(t *testing.T) {
+	o := objectiveHTTPRatio()
+	_, err := o.CalendarRules()
+	require.EqualError(t, err, "objective monitoring-http-errors has no calendar")
+
+	o.Calendar = "year"
+	_, err = o.CalendarRules()
+	require.EqualError(t, err, `calendar must be one of week, month or quarter, got "year"`)
+
+	o.Calendar = CalendarMonth
+	group, err := o.CalendarRules()
+	require.NoError(t, err)
+
+	sinceStart := func(selector string) string {
+		return `sum_over_time((` + selector + ` and on () (year(vector(time() - 300)) * 12 + month(vector(time() - 300)) == scalar(max_over_time((year(vector(time())) * 12 + month(vector(time())))[5m:5m] @ end()))))[31d:5m])`
+	}
+	errorBudget := `((1 - 0.99) - (sum(` + sinceStart(`http_requests:increase5m{code=~"5..",job="thanos-receive-default",slo="monitoring-http-errors"}`) + ` or vector(0)) / sum(` + sinceStart(`http_requests:increase5m{job="thanos-receive-default",slo="monitoring-http-errors"}`) + `))) / (1 - 0.99)`
+	require.Equal(t, monitoringv1.RuleGroup{
+		Name:     "monitoring-http-errors-calendar",
+		Interval: monitoringDuration("1m"),
+		Rules: []monitoringv1.Rule{{
+			Record: "http_requests:increase5m",
+			Expr:   intstr.FromString(`sum by (code) (increase(http_requests_total{job="thanos-receive-default"}[5m]))`),
+			Labels: map[string]string{"job": "thanos-receive-default", "slo": "monitoring-http-errors"},
+		}, {
+			Record: "pyrra_calendar_error_budget",
+			Expr:   intstr.FromString(errorBudget),
+			Labels: map[string]string{"slo": "monitoring-http-errors"},
+		}},
+	}, group)
+
+	// The SLA is complied with per calendar period too.
+	sla := 0.95
+	o.SLATarget = &sla
+	group, err = o.SLARules()
+	require.NoError(t, err)
+	require.Equal(t, strings.ReplaceAll(errorBudget, "0.99", "0.95"), group.Rules[1].Expr.String())
+
+	for calendar, period := range map[Calendar]string{
+		CalendarWeek:    `floor((vector(time() - 300) - 345600) / 604800)`,
+		CalendarQuarter: `year(vector(time() - 300)) * 4 + floor((month(vector(time() - 300)) - 1) / 3)`,
+	} {
+		o.Calendar = calendar
+		errorBudget, err := o.calendarErrorBudget()
+		require.NoError(t, err)
+		require.Contains(t, errorBudget, period)
+	}
+
+	// Absent alerts and stable rule names are left to the increase rules of the window.
+	o = objectiveHTTPRatio()
+	o.Calendar = CalendarWeek
+	o.StableRuleNames = true
+	o.Alerting.Absent = true
+	group, err = o.CalendarRules()
+	require.NoError(t, err)
+	require.Len(t, group.Rules, 2)
+	require.Equal(t, "http_requests:increase5m", group.Rules[0].Record)
+
+	o = objectiveNginxLogs()
+	o.Calendar = CalendarMonth
+	_, err = o.CalendarRules()
+	require.EqualError(t, err, "objective nginx with a calendar must not have a logs or composite indicator")
+}
+
+func TestObjective_SharedBurnrates(t *testing.T) {
+	unshared, err := objectiveHTTPRatio().Burnrates()
+	require.NoError(t, err)
+
+	o := objectiveHTTPRatio()
+	o.SharedBurnrates = true
+	group, err := o.Burnrates()
+	require.NoError(t, err)
+	require.Len(t, group.Rules, 4)
+	require.Equal(t, `http_requests:burnrate5m{burnrate_id="45c838aa11e369ee",job="thanos-receive-default"} > (14 * (1 - 0.99)) and http_requests:burnrate1h{burnrate_id="402c5adb0a99565f",job="thanos-receive-default"} > (14 * (1 - 0.99))`, group.Rules[0].Expr.String())
+	require.Equal(t, "monitoring-http-errors", group.Rules[0].Labels["slo"])
+
+	query, err := o.QueryBurnrate(5*time.Minute, nil)
+	require.NoError(t, err)
+	require.Equal(t, `http_requests:burnrate5m{burnrate_id="45c838aa11e369ee",job="thanos-receive-default"}`, query)
+
+	// Objectives with the same indicator share the burn rates, whatever their target.
+	other := objectiveHTTPRatio()
+	other.Labels = labels.FromStrings(labels.MetricName, "monitoring-http-errors-strict")
+	other.Target = 0.999
+	other.SharedBurnrates = true
+
+	// Objectives in other namespaces don't.
+	namespaced := objectiveHTTPRatio()
+	namespaced.Labels = labels.FromStrings(labels.MetricName, "monitoring-http-errors", "namespace", "default")
+	namespaced.SharedBurnrates = true
+
+	shared, err := SharedBurnrates("pyrra-shared-burnrates", []Objective{o, other, objectiveHTTPRatio()})
+	require.NoError(t, err)
+	require.Equal(t, "pyrra-shared-burnrates", shared.Name)
+	require.Len(t, shared.Rules, len(unshared.Rules)-4)
+	require.Equal(t, monitoringv1.Rule{
+		Record: "http_requests:burnrate1d",
+		Expr:   intstr.FromString(`sum(rate(http_requests_total{code=~"5..",job="thanos-receive-default"}[1d])) / sum(rate(http_requests_total{job="thanos-receive-default"}[1d]))`),
+		Labels: map[string]string{"burnrate_id": "ee1a87a6dc3f8be3", "job": "thanos-receive-default"},
+	}, shared.Rules[0])
+
+	shared, err = SharedBurnrates("pyrra-shared-burnrates", []Objective{o, namespaced})
+	require.NoError(t, err)
+	require.Len(t, shared.Rules, 2*(len(unshared.Rules)-4))
+}
+
+func TestObjective_MuteWindows(t *testing.T) {
+	o := objectiveHTTPRatio()
+	unmuted, err := o.Burnrates()
+	require.NoError(t, err)
+
+	o.Alerting.MuteWindows = []MuteWindow{
+		{Start: 2 * time.Hour, End: 4 * time.Hour},
+		{Weekdays: []time.Weekday{time.Saturday, time.Sunday}, Start: 22 * time.Hour, End: 24 * time.Hour},
+	}
+	muted, err := o.Burnrates()
+	require.NoError(t, err)
+	require.Len(t, muted.Rules, len(unmuted.Rules))
+
+	var alerts int
+	for i, r := range muted.Rules {
+		if r.Alert == "" {
+			// Recording rules still account for all errors.
+			require.Equal(t, unmuted.Rules[i], r)
+			continue
+		}
+		alerts++
+
+		require.Equal(t,
+			unmuted.Rules[i].Expr.String()+` unless on () ((hour() * 60 + minute() >= 120 < 240) or ((hour() * 60 + minute() >= 1320 < 1440) and (day_of_week() == 6 or day_of_week() == 0)))`,
+			r.Expr.String(),
+		)
+		_, err := parser.ParseExpr(r.Expr.String())
+		require.NoError(t, err)
+	}
+	require.Equal(t, 4, alerts)
+}
+
+func TestObjective_MaintenanceWindows(t *testing.T) {
+	o := objectiveHTTPRatio()
+	unmuted, err := o.Burnrates()
+	require.NoError(t, err)
+
+	o.Alerting.MuteWindows = []MuteWindow{{Start: 2 * time.Hour, End: 4 * time.Hour}}
+	o.Alerting.MaintenanceWindows = []MaintenanceWindow{{
+		Start: time.Date(2024, 6, 1, 22, 0, 0, 0, time.UTC),
+		End:   time.Date(2024, 6, 2, 2, 0, 0, 0, time.UTC),
+	}}
+	muted, err := o.Burnrates()
+	require.NoError(t, err)
+
+	var alerts int
+	for i, r := range muted.Rules {
+		if r.Alert == "" {
+			require.Equal(t, unmuted.Rules[i], r)
+			continue
+		}
+		alerts++
+
+		require.Equal(t,
+			unmuted.Rules[i].Expr.String()+` unless on () ((hour() * 60 + minute() >= 120 < 240) or (vector(time()) >= 1717279200 < 1717293600))`,
+			r.Expr.String(),
+		)
+		_, err := parser.ParseExpr(r.Expr.String())
+		require.NoError(t, err)
+	}
+	require.Equal(t, 4, alerts)
+}
+
+func TestObjective_KeepFiringFor(t *testing.T) {
+	o := objectiveHTTPRatio()
+	o.Alerting.KeepFiringFor = 10 * time.Minute
+	group, err := o.Burnrates()
+	require.NoError(t, err)
+
+	var alerts int
+	for _, r := range group.Rules {
+		if r.Alert == "" {
+			require.Nil(t, r.KeepFiringFor)
+			continue
+		}
+		alerts++
+		require.Equal(t, monitoringv1.NonEmptyDuration("10m"), *r.KeepFiringFor)
+	}
+	require.Equal(t, 4, alerts)
+
+	// Absent alerts stop firing as soon as the metrics are back.
+	increases, err := o.IncreaseRules()
+	require.NoError(t, err)
+	for _, r := range increases.Rules {
+		require.Nil(t, r.KeepFiringFor)
+	}
+
+	o.Alerting.PartialResponseStrategy = "warn"
+	groups := []monitoringv1.RuleGroup{increases, group}
+	o.Alerting.RuleGroupOptions(groups)
+	for _, g := range groups {
+		require.Equal(t, "warn", g.PartialResponseStrategy)
+	}
+}
+
+func TestObjective_RuleGroupOptions(t *testing.T) {
+	o := objectiveHTTPRatio()
+	o.Alerting.PartialResponseStrategy = "abort"
+
+	rules, err := o.Burnrates()
+	require.NoError(t, err)
+	increases, err := o.IncreaseRules()
+	require.NoError(t, err)
+
+	// Without an interval and limit the generated ones are kept.
+	groups := []monitoringv1.RuleGroup{increases, rules}
+	o.RuleGroupOptions(groups)
+	require.Equal(t, increases.Interval, groups[0].Interval)
+	require.Equal(t, rules.Interval, groups[1].Interval)
+	for _, g := range groups {
+		require.Nil(t, g.Limit)
+		require.Equal(t, "abort", g.PartialResponseStrategy)
+	}
+
+	o.RuleGroupInterval = 2 * time.Minute
+	o.RuleGroupLimit = 100
+	o.RuleGroupOptions(groups)
+	for _, g := range groups {
+		require.Equal(t, monitoringv1.Duration("2m"), *g.Interval)
+		require.Equal(t, 100, *g.Limit)
+		require.Equal(t, "abort", g.PartialResponseStrategy)
+	}
+}
+
+func TestObjective_AbsentAlerts(t *testing.T) {
+	o := objectiveHTTPRatio()
+	o.Alerting.AbsentSeverity = "warning"
+	o.Alerting.AbsentOverTime = 15 * time.Minute
+
+	increases, err := o.IncreaseRules()
+	require.NoError(t, err)
+
+	var alerts []monitoringv1.Rule
+	for _, r := range increases.Rules {
+		if r.Alert != "" {
+			alerts = append(alerts, r)
+		}
+	}
+	require.Len(t, alerts, 1)
+	require.Equal(t, `absent_over_time(http_requests_total{job="thanos-receive-default"}[15m]) == 1`, alerts[0].Expr.String())
+	require.Equal(t, "warning", alerts[0].Labels["severity"])
+
+	o.Alerting.AbsentOverTime = 0
+	increases, err = o.IncreaseRules()
+	require.NoError(t, err)
+	require.Equal(t, `absent(http_requests_total{job="thanos-receive-default"}) == 1`, increases.Rules[1].Expr.String())
+}
+
+func TestObjective_InhibitRules(t *testing.T) {
+	o := objectiveHTTPRatioGrouping()
+	require.Nil(t, o.InhibitRules())
+
+	o.Alerting.Inhibit = true
+	burnrates, err := o.Burnrates()
+	require.NoError(t, err)
+
+	windows := map[string]string{}
+	for _, r := range burnrates.Rules {
+		if r.Alert != "" {
+			windows[r.Labels["long"]] = r.Labels[BurnWindowLabel]
+		}
+	}
+	require.Equal(t, map[string]string{"1h": "fast", "6h": "fast", "1d": "slow", "4d": "slow"}, windows)
+
+	matchers := func(burnWindow string) []monitoringv1alpha1.Matcher {
+		return []monitoringv1alpha1.Matcher{
+			{Name: "alertname", Value: "ErrorBudgetBurn", MatchType: monitoringv1alpha1.MatchEqual},
+			{Name: "slo", Value: "monitoring-http-errors", MatchType: monitoringv1alpha1.MatchEqual},
+			{Name: "burn_window", Value: burnWindow, MatchType: monitoringv1alpha1.MatchEqual},
+		}
+	}
+	require.Equal(t, []monitoringv1alpha1.InhibitRule{{
+		SourceMatch: matchers("fast"),
+		TargetMatch: matchers("slow"),
+		Equal:       []string{"slo", "handler", "job"},
+	}}, o.InhibitRules())
+	require.Equal(t, []string{"job", "handler"}, o.Grouping())
+
+	// Without burn rate alerts there's nothing to inhibit.
+	o.Alerting.Burnrates = false
+	require.Nil(t, o.InhibitRules())
+}
+
+func TestObjective_ExternalLabels(t *testing.T) {
+	o := objectiveHTTPRatio()
+	o.Labels = labels.FromStrings(labels.MetricName, "monitoring-http-errors", PropagationLabelsPrefix+"team", "foo")
+	o.ExternalLabels = map[string]string{"cluster": "eu1", "team": "bar", "slo": "other"}
+
+	increases, err := o.IncreaseRules()
+	require.NoError(t, err)
+	burnrates, err := o.Burnrates()
+	require.NoError(t, err)
+	generic, err := o.GenericRules()
+	require.NoError(t, err)
+
+	var rules int
+	for _, g := range []monitoringv1.RuleGroup{increases, burnrates, generic} {
+		for _, r := range g.Rules {
+			rules++
+			require.Equal(t, "eu1", r.Labels["cluster"], r.Record+r.Alert)
+			// The slo label and the labels propagated from the objective take precedence.
+			require.Equal(t, "monitoring-http-errors", r.Labels["slo"], r.Record+r.Alert)
+			require.Equal(t, "foo", r.Labels["team"], r.Record+r.Alert)
+		}
+	}
+	require.Greater(t, rules, 10)
+}
+
+func TestObjective_AlertingWindows(t *testing.T) {
+	o := objectiveHTTPRatio()
+	o.Alerting.Windows = []Window{
+		{Severity: critical, For: 2 * time.Minute, Short: 5 * time.Minute, Long: time.Hour, Factor: 14.4},
+		{Severity: warning, For: time.Hour, Short: 6 * time.Hour, Long: 3 * 24 * time.Hour, Factor: 1},
+	}
+
+	group, err := o.Burnrates()
+	require.NoError(t, err)
+
+	var records []string
+	var alerts []monitoringv1.Rule
+	for _, r := range group.Rules {
+		if r.Alert == "" {
+			records = append(records, r.Record)
+			continue
+		}
+		alerts = append(alerts, r)
+	}
+	// Only the burn rates of the windows are recorded.
+	require.Equal(t, []string{
+		"http_requests:burnrate5m",
+		"http_requests:burnrate1h",
+		"http_requests:burnrate6h",
+		"http_requests:burnrate3d",
+	}, records)
+
+	require.Len(t, alerts, 2)
+	require.Equal(t, `http_requests:burnrate5m{job="thanos-receive-default",slo="monitoring-http-errors"} > (14.4 * (1-0.99)) and http_requests:burnrate1h{job="thanos-receive-default",slo="monitoring-http-errors"} > (14.4 * (1-0.99))`, alerts[0].Expr.String())
+	require.Equal(t, monitoringDuration("2m0s"), alerts[0].For)
+	require.Equal(t, "critical", alerts[0].Labels["severity"])
+	require.Equal(t, `http_requests:burnrate6h{job="thanos-receive-default",slo="monitoring-http-errors"} > (1 * (1-0.99)) and http_requests:burnrate3d{job="thanos-receive-default",slo="monitoring-http-errors"} > (1 * (1-0.99))`, alerts[1].Expr.String())
+	require.Equal(t, "warning", alerts[1].Labels["severity"])
+	require.Equal(t, "4w", alerts[1].Labels["exhaustion"])
+
+	_, found := o.HasWindows(model.Duration(6*time.Hour), model.Duration(3*24*time.Hour))
+	require.True(t, found)
+	_, found = o.HasWindows(model.Duration(30*time.Minute), model.Duration(6*time.Hour))
+	require.False(t, found)
+}
+
+func TestObjective_AlertRouting(t *testing.T) {
+	o := objectiveHTTPRatio()
+	o.Alerting.Absent = true
+	o.Alerting.Labels = map[string]string{"service": "api"}
+	o.Alerting.Annotations = map[string]string{"runbook_url": "https://example.com/runbook"}
+	o.Alerting.Windows = []Window{
+		{Severity: critical, For: 2 * time.Minute, Short: 5 * time.Minute, Long: time.Hour, Factor: 14},
+		{
+			Severity:    "ticket",
+			For:         time.Hour,
+			Short:       6 * time.Hour,
+			Long:        3 * 24 * time.Hour,
+			Factor:      1,
+			Labels:      map[string]string{"service": "api-tickets", "route": "jira"},
+			Annotations: map[string]string{"summary": "Slow error budget burn"},
+		},
+	}
+
+	group, err := o.Burnrates()
+	require.NoError(t, err)
+
+	var alerts []monitoringv1.Rule
+	for _, r := range group.Rules {
+		if r.Alert != "" {
+			alerts = append(alerts, r)
+		}
+	}
+	require.Len(t, alerts, 2)
+
+	require.Equal(t, "critical", alerts[0].Labels["severity"])
+	require.Equal(t, "api", alerts[0].Labels["service"])
+	require.NotContains(t, alerts[0].Labels, "route")
+	require.Equal(t, map[string]string{"runbook_url": "https://example.com/runbook"}, alerts[0].Annotations)
+
+	// The window's labels and annotations take precedence.
+	require.Equal(t, "ticket", alerts[1].Labels["severity"])
+	require.Equal(t, "api-tickets", alerts[1].Labels["service"])
+	require.Equal(t, "jira", alerts[1].Labels["route"])
+	require.Equal(t, map[string]string{
+		"runbook_url": "https://example.com/runbook",
+		"summary":     "Slow error budget burn",
+	}, alerts[1].Annotations)
+
+	increase, err := o.IncreaseRules()
+	require.NoError(t, err)
+	var absent int
+	for _, r := range increase.Rules {
+		if r.Alert == "" {
+			continue
+		}
+		absent++
+		require.Equal(t, "api", r.Labels["service"])
+		require.Equal(t, "https://example.com/runbook", r.Annotations["runbook_url"])
+	}
+	require.Equal(t, 1, absent)
+}
+
+func TestObjective_ExpressionRules(t *testing.T) {
+	o := objectiveCheckoutExpression()
+
+	increase, err := o.IncreaseRules()
+	require.NoError(t, err)
+	require.Equal(t, []monitoringv1.Rule{{
+		Record: "pyrra_requests:increase4w",
+		Expr:   intstr.FromString(`sum(increase(checkout_started_total{job="shop"}[4w]))`),
+		Labels: map[string]string{"slo": "checkout"},
+	}, {
+		Record: "pyrra_errors:increase4w",
+		Expr:   intstr.FromString(`sum(increase(checkout_failed_total{job="shop"}[4w]) + increase(payment_declined_total[4w]))`),
+		Labels: map[string]string{"slo": "checkout"},
+	}}, increase.Rules)
+
+	burnrates, err := o.Burnrates()
+	require.NoError(t, err)
+	require.Equal(t, "pyrra_requests:burnrate5m", burnrates.Rules[0].Record)
+	require.Equal(t,
+		`sum(rate(checkout_failed_total{job="shop"}[5m]) + rate(payment_declined_total[5m])) / sum(rate(checkout_started_total{job="shop"}[5m]))`,
+		burnrates.Rules[0].Expr.String(),
+	)
+	alert := burnrates.Rules[len(burnrates.Rules)-4]
+	require.Equal(t, `pyrra_requests:burnrate5m{slo="checkout"} > (14 * (1-0.99)) and pyrra_requests:burnrate1h{slo="checkout"} > (14 * (1-0.99))`, alert.Expr.String())
+	require.Equal(t, "critical", alert.Labels["severity"])
+
+	generic, err := o.GenericRules()
+	require.NoError(t, err)
+	require.Equal(t, `1 - sum(pyrra_errors:increase4w{slo="checkout"} or vector(0)) / sum(pyrra_requests:increase4w{slo="checkout"})`, generic.Rules[2].Expr.String())
+	require.Equal(t, `sum(checkout_started_total{job="shop"})`, generic.Rules[3].Expr.String())
+	require.Equal(t, `sum(checkout_failed_total{job="shop"} + payment_declined_total or vector(0))`, generic.Rules[4].Expr.String())
+
+	// Grouped expressions keep the grouping labels on their recording rules.
+	o.Indicator.Expression.Grouping = []string{"region"}
+	increase, err = o.IncreaseRules()
+	require.NoError(t, err)
+	require.Equal(t, `sum by (region) (increase(checkout_started_total{job="shop"}[4w]))`, increase.Rules[0].Expr.String())
+
+	_, err = o.GenericRules()
+	require.ErrorIs(t, err, ErrGroupingUnsupported)
+}
+
+func TestObjective_QuantileRules(t *testing.T) {
+	o := objectiveCheckoutQuantile()
+	require.Equal(t, BoolGauge, o.IndicatorType())
+
+	burnrates, err := o.Burnrates()
+	require.NoError(t, err)
+	require.Equal(t, monitoringv1.Rule{
+		Record: "http_request_duration_seconds:quantile_within_latency5m",
+		Expr:   intstr.FromString(`histogram_quantile(0.99, sum by (le) (rate(http_request_duration_seconds_bucket{job="shop"}[5m]))) >= 0 <= bool 0.5`),
+		Labels: map[string]string{"slo": "checkout"},
+	}, burnrates.Rules[0])
+	require.Equal(t, "http_request_duration_seconds:quantile_within_latency5m:burnrate5m", burnrates.Rules[1].Record)
+	require.Contains(t, burnrates.Rules[1].Expr.String(), `count_over_time(http_request_duration_seconds:quantile_within_latency5m{slo="checkout"}[5m])`)
+	alert := burnrates.Rules[len(burnrates.Rules)-4]
+	require.Equal(t, `http_request_duration_seconds:quantile_within_latency5m:burnrate5m{slo="checkout"} > (14 * (1-0.99)) and http_request_duration_seconds:quantile_within_latency5m:burnrate1h{slo="checkout"} > (14 * (1-0.99))`, alert.Expr.String())
+
+	increase, err := o.IncreaseRules()
+	require.NoError(t, err)
+	require.Equal(t, "http_request_duration_seconds:quantile_within_latency5m:count4w", increase.Rules[0].Record)
+	absent := increase.Rules[len(increase.Rules)-1]
+	require.Equal(t, o.AlertNameAbsent(), absent.Alert)
+	require.Equal(t, `absent(http_request_duration_seconds_bucket{job="shop"}) == 1`, absent.Expr.String())
+
+	// Native histograms have no le label to keep.
+	o.Indicator.Quantile.Total = Metric{
+		Name: "http_request_duration_seconds",
+		LabelMatchers: []*labels.Matcher{
+			{Type: labels.MatchEqual, Name: labels.MetricName, Value: "http_request_duration_seconds"},
+		},
+	}
+	o.Indicator.Quantile.Grouping = []string{"handler"}
+	burnrates, err = o.Burnrates()
+	require.NoError(t, err)
+	require.Equal(t, `histogram_quantile(0.99, sum by (handler) (rate(http_request_duration_seconds[5m]))) >= 0 <= bool 0.5`, burnrates.Rules[0].Expr.String())
+}
+
+func TestObjective_LogsRules(t *testing.T) {
+	o := objectiveNginxLogs()
+
+	increase, err := o.IncreaseRules()
+	require.NoError(t, err)
+	require.Equal(t, []monitoringv1.Rule{{
+		Record: "pyrra_log_lines:increase4w",
+		Expr:   intstr.FromString(`sum(count_over_time({job="nginx"}[4w]))`),
+		Labels: map[string]string{"slo": "nginx"},
+	}, {
+		Record: "pyrra_log_errors:increase4w",
+		Expr:   intstr.FromString(`sum(count_over_time({job="nginx"} | json | status >= 500[4w]))`),
+		Labels: map[string]string{"slo": "nginx"},
+	}}, increase.Rules)
+
+	burnrates, err := o.Burnrates()
+	require.NoError(t, err)
+	require.Equal(t, "pyrra_log_lines:burnrate5m", burnrates.Rules[0].Record)
+	require.Equal(t,
+		`sum(count_over_time({job="nginx"} | json | status >= 500[5m])) / sum(count_over_time({job="nginx"}[5m]))`,
+		burnrates.Rules[0].Expr.String(),
+	)
+
+	// The alerts count the log lines themselves, the Loki ruler can't query the recorded burn rates.
+	alert := burnrates.Rules[len(burnrates.Rules)-4]
+	require.Equal(t,
+		`(sum(count_over_time({job="nginx"} | json | status >= 500[5m])) / sum(count_over_time({job="nginx"}[5m]))) > (14 * (1-0.99)) and `+
+			`(sum(count_over_time({job="nginx"} | json | status >= 500[1h])) / sum(count_over_time({job="nginx"}[1h]))) > (14 * (1-0.99))`,
+		alert.Expr.String(),
+	)
+	require.Equal(t, "critical", alert.Labels["severity"])
+
+	generic, err := o.GenericRules()
+	require.NoError(t, err)
+	require.Len(t, generic.Rules, 2)
+	require.Equal(t, "pyrra_objective", generic.Rules[0].Record)
+	require.Equal(t, "pyrra_window", generic.Rules[1].Record)
+
+	o.Indicator.Logs.Grouping = []string{"status", "host"}
+	increase, err = o.IncreaseRules()
+	require.NoError(t, err)
+	require.Equal(t, `sum by (host, status) (count_over_time({job="nginx"}[4w]))`, increase.Rules[0].Expr.String())
+}
+
+func TestObjective_StableRuleNames(t *testing.T) {
+	o := objectiveHTTPRatio()
+	o.StableRuleNames = true
+
+	increase, err := o.IncreaseRules()
+	require.NoError(t, err)
+	require.Equal(t, "http_requests:increase", increase.Rules[0].Record)
+	require.Equal(t, `sum by (code) (increase(http_requests_total{job="thanos-receive-default"}[4w]))`, increase.Rules[0].Expr.String())
+	require.Equal(t, `sum(http_requests:increase{job="thanos-receive-default",slo="monitoring-http-errors"})`, o.QueryTotal(o.Window))
+
+	// The burn rates are still named after their ranges, as there are several of them.
+	burnrates, err := o.Burnrates()
+	require.NoError(t, err)
+	require.Equal(t, "http_requests:burnrate5m", burnrates.Rules[0].Record)
+
+	// Queries over other windows than the objective's use the rules named after them.
+	require.Equal(t, `sum(http_requests:increase1w{job="thanos-receive-default",slo="monitoring-http-errors"})`, o.QueryTotal(model.Duration(7*24*time.Hour)))
+}
+
+func TestObjective_CompositeRules(t *testing.T) {
+	ratio, latency := objectiveHTTPRatio(), objectiveHTTPLatency()
+	o := Objective{
+		Labels:   labels.FromStrings(labels.MetricName, "checkout"),
+		Target:   0.99,
+		Window:   model.Duration(28 * 24 * time.Hour),
+		Alerting: Alerting{Burnrates: true},
+		Indicator: Indicator{Composite: &CompositeIndicator{Objectives: []CompositeObjective{
+			{Name: "monitoring-http-errors", Weight: 2, Objective: &ratio},
+			{Name: "monitoring-http-latency", Weight: 1, Objective: &latency},
+		}}},
+	}
+	require.Equal(t, Composite, o.IndicatorType())
+
+	// The error ratio over the window is recorded, there are no requests and errors of the composite to count.
+	increase, err := o.IncreaseRules()
+	require.NoError(t, err)
+	require.Equal(t, []monitoringv1.Rule{{
+		Record: "pyrra_composite:burnrate4w",
+		Expr: intstr.FromString(`(2 * ((sum(rate(http_requests_total{code=~"5..",job="thanos-receive-default"}[4w])) / sum(rate(http_requests_total{job="thanos-receive-default"}[4w]))) or vector(0)) + ` +
+			`1 * (((sum(rate(http_request_duration_seconds_count{code=~"2..",job="metrics-service-thanos-receive-default"}[4w])) - sum(rate(http_request_duration_seconds_bucket{code=~"2..",job="metrics-service-thanos-receive-default",le="1"}[4w]))) / sum(rate(http_request_duration_seconds_count{code=~"2..",job="metrics-service-thanos-receive-default"}[4w]))) or vector(0))) / 3`),
+		Labels: map[string]string{"slo": "checkout"},
+	}}, increase.Rules)
+
+	burnrates, err := o.Burnrates()
+	require.NoError(t, err)
+	require.Equal(t, "pyrra_composite:burnrate5m", burnrates.Rules[0].Record)
+	require.Equal(t,
+		`(2 * ((sum(rate(http_requests_total{code=~"5..",job="thanos-receive-default"}[5m])) / sum(rate(http_requests_total{job="thanos-receive-default"}[5m]))) or vector(0)) + `+
+			`1 * (((sum(rate(http_request_duration_seconds_count{code=~"2..",job="metrics-service-thanos-receive-default"}[5m])) - sum(rate(http_request_duration_seconds_bucket{code=~"2..",job="metrics-service-thanos-receive-default",le="1"}[5m]))) / sum(rate(http_request_duration_seconds_count{code=~"2..",job="metrics-service-thanos-receive-default"}[5m]))) or vector(0))) / 3`,
+		burnrates.Rules[0].Expr.String(),
+	)
+	alert := burnrates.Rules[len(burnrates.Rules)-4]
+	require.Equal(t, `pyrra_composite:burnrate5m{slo="checkout"} > (14 * (1-0.99)) and pyrra_composite:burnrate1h{slo="checkout"} > (14 * (1-0.99))`, alert.Expr.String())
+
+	generic, err := o.GenericRules()
+	require.NoError(t, err)
+	require.Equal(t, "pyrra_availability", generic.Rules[2].Record)
+	require.Equal(t, `1 - sum(pyrra_composite:burnrate4w{slo="checkout"})`, generic.Rules[2].Expr.String())
+	require.Equal(t, `((1 - 0.99) - sum(pyrra_composite:burnrate4w{slo="checkout"})) / (1 - 0.99)`, o.QueryErrorBudget())
+
+	// No rules are generated until all objectives are resolved, or if they can't be combined.
+	o.Indicator.Composite.Objectives[1].Objective = nil
+	_, err = o.IncreaseRules()
+	require.EqualError(t, err, "objective monitoring-http-latency of the composite isn't resolved")
+
+	grouped := objectiveHTTPRatioGrouping()
+	o.Indicator.Composite.Objectives[1].Objective = &grouped
+	_, err = o.Burnrates()
+	require.EqualError(t, err, "objective monitoring-http-latency has grouping, only objectives with a single ratio can be combined")
+
+	nested := Objective{Indicator: Indicator{Composite: &CompositeIndicator{Objectives: []CompositeObjective{{Name: "monitoring-http-errors", Weight: 1, Objective: &ratio}}}}}
+	o.Indicator.Composite.Objectives[1].Objective = &nested
+	_, err = o.Burnrates()
+	require.EqualError(t, err, "objective monitoring-http-latency is a composite itself, composites can't be combined")
+}
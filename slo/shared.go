@@ -0,0 +1,163 @@
+package slo
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// SharedBurnrateLabel is set on the shared burn rate series to the hash of their recording rule,
+// which the objectives sharing them select them by.
+const SharedBurnrateLabel = "burnrate_id"
+
+// sharedBurnrate is a burn rate recording rule of an objective as it's shared with other objectives.
+type sharedBurnrate struct {
+	rule monitoringv1.Rule
+	id   string
+}
+
+// sharedBurnrates returns the burn rate recording rules of the objective as they're shared, by their record.
+// Their labels are the ones of the objective's rules, without the slo and propagated labels.
+// Objectives in different namespaces don't share rules, as the rulers evaluating them can't tell the namespaces apart.
+func (o Objective) sharedBurnrates(group monitoringv1.RuleGroup) map[string]sharedBurnrate {
+	records := map[string]bool{}
+	for _, br := range burnratesFromWindows(o.Windows()) {
+		records[o.BurnrateName(br)] = true
+	}
+
+	objectiveLabels := map[string]bool{"slo": true}
+	for _, l := range o.Labels {
+		if strings.HasPrefix(l.Name, PropagationLabelsPrefix) {
+			objectiveLabels[strings.TrimPrefix(l.Name, PropagationLabelsPrefix)] = true
+		}
+	}
+
+	shared := map[string]sharedBurnrate{}
+	for _, rule := range group.Rules {
+		if !records[rule.Record] {
+			continue
+		}
+
+		ruleLabels := make(map[string]string, len(rule.Labels))
+		names := make([]string, 0, len(rule.Labels))
+		for name, value := range rule.Labels {
+			if objectiveLabels[name] {
+				continue
+			}
+			ruleLabels[name] = value
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		h := fnv.New64a()
+		_, _ = fmt.Fprintf(h, "%s\n%s\n%s\n", o.Labels.Get("namespace"), rule.Record, rule.Expr.String())
+		for _, name := range names {
+			_, _ = fmt.Fprintf(h, "%s=%s\n", name, ruleLabels[name])
+		}
+		id := fmt.Sprintf("%016x", h.Sum64())
+		ruleLabels[SharedBurnrateLabel] = id
+
+		shared[rule.Record] = sharedBurnrate{
+			rule: monitoringv1.Rule{Record: rule.Record, Expr: rule.Expr, Labels: ruleLabels},
+			id:   id,
+		}
+	}
+	return shared
+}
+
+// shareBurnrates drops the burn rate recording rules from the objective's group
+// and makes its alerts select the shared burn rates instead.
+func (o Objective) shareBurnrates(group monitoringv1.RuleGroup) (monitoringv1.RuleGroup, error) {
+	shared := o.sharedBurnrates(group)
+
+	rules := make([]monitoringv1.Rule, 0, len(group.Rules))
+	for _, rule := range group.Rules {
+		if _, ok := shared[rule.Record]; ok {
+			continue
+		}
+		if rule.Alert != "" {
+			expr, err := parser.ParseExpr(rule.Expr.String())
+			if err != nil {
+				return monitoringv1.RuleGroup{}, err
+			}
+			parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+				if vs, ok := node.(*parser.VectorSelector); ok {
+					if sb, ok := shared[vs.Name]; ok {
+						vs.LabelMatchers = sharedMatchers(vs.LabelMatchers, sb.id)
+					}
+				}
+				return nil
+			})
+			rule.Expr = intstr.FromString(expr.String())
+		}
+		rules = append(rules, rule)
+	}
+	group.Rules = rules
+	return group, nil
+}
+
+// sharedMatchers replaces the slo matcher with the one of the SharedBurnrateLabel.
+func sharedMatchers(matchers []*labels.Matcher, id string) []*labels.Matcher {
+	shared := make([]*labels.Matcher, 0, len(matchers))
+	for _, m := range matchers {
+		if m.Name != "slo" {
+			shared = append(shared, m)
+		}
+	}
+	return append(shared, &labels.Matcher{Type: labels.MatchEqual, Name: SharedBurnrateLabel, Value: id})
+}
+
+// SharedBurnrates returns the rule group recording the burn rates of the objectives with SharedBurnrates,
+// each burn rate recording rule with the same expression and labels only once.
+func SharedBurnrates(name string, objectives []Objective) (monitoringv1.RuleGroup, error) {
+	seen := map[string]bool{}
+	var rules []monitoringv1.Rule
+	for _, o := range objectives {
+		if !o.SharedBurnrates {
+			continue
+		}
+		group, err := o.burnrates()
+		if err != nil {
+			return monitoringv1.RuleGroup{}, fmt.Errorf("failed to get burn rate rules of objective %s: %w", o.Name(), err)
+		}
+		for _, sb := range o.sharedBurnrates(group) {
+			key := sb.rule.Record + "/" + sb.id
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			rules = append(rules, sb.rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Record != rules[j].Record {
+			return rules[i].Record < rules[j].Record
+		}
+		return rules[i].Labels[SharedBurnrateLabel] < rules[j].Labels[SharedBurnrateLabel]
+	})
+
+	return monitoringv1.RuleGroup{
+		Name:     name,
+		Interval: monitoringDuration("30s"),
+		Rules:    rules,
+	}, nil
+}
+
+// sharedBurnrateID returns the SharedBurnrateLabel of the objective's burn rate recorded as the metric, false if it isn't shared.
+func (o Objective) sharedBurnrateID(metric string) (string, bool) {
+	if !o.SharedBurnrates {
+		return "", false
+	}
+	group, err := o.burnrates()
+	if err != nil {
+		return "", false
+	}
+	sb, ok := o.sharedBurnrates(group)[metric]
+	return sb.id, ok
+}
@@ -1,6 +1,8 @@
 package slo
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/prometheus/common/model"
@@ -26,6 +28,45 @@ type Objective struct {
 
 	Alerting  Alerting
 	Indicator Indicator
+
+	// BudgetFreeze is the remaining error budget, from 0 to 1, below which changes should be frozen.
+	// The pyrra_budget_freeze rule is only recorded if it is set.
+	BudgetFreeze *float64
+	// SLATarget is the contractual target, from 0 to 1, promised externally and looser than Target.
+	// The pyrra_sla_* rules are only recorded if it is set.
+	SLATarget *float64
+	// Calendar additionally records the error budget since the start of the calendar period, which resets at the start of each period.
+	// The pyrra_sla_* rules then use it too, as SLAs are usually promised per calendar period. Alerts still use the Window.
+	Calendar Calendar
+	// StableRuleNames names the increase recording rules without the window, like http_requests:increase,
+	// so the error budget's history survives changes of the window.
+	// The burn rate rules are still named after their ranges, which change with the window.
+	StableRuleNames bool
+	// SharedBurnrates leaves recording the burn rates to the SharedBurnrates rule group, which records them once
+	// for all objectives with the same expressions and labels, and selects them by their SharedBurnrateLabel instead of the slo label.
+	SharedBurnrates bool
+	// ExternalLabels are added to all rules, like cluster or region,
+	// so the series of objectives in several clusters don't collide when aggregated.
+	// The slo label and the labels propagated from the objective take precedence.
+	ExternalLabels map[string]string
+	// RuleGroupInterval replaces the evaluation interval of all rule groups, like for objectives with many series,
+	// whose rules are evaluated less often to save the ruler's CPU. The generated intervals are kept if it's 0.
+	RuleGroupInterval time.Duration
+	// RuleGroupLimit limits the series each recording rule and the alerts each alerting rule of the rule groups may produce.
+	// The rule groups are unlimited if it's 0.
+	RuleGroupLimit int
+	// Owner is who is responsible for the objective and how to reach them, for the API to show.
+	// Its labels and annotations are added to the alerts through the Alerting.
+	Owner *Owner
+}
+
+// Owner is the team owning an objective and its contact details.
+type Owner struct {
+	Team             string
+	Slack            string
+	EscalationPolicy string
+	RunbookURL       string
+	Tier             string
 }
 
 func (o Objective) Name() string {
@@ -37,12 +78,17 @@ func (o Objective) Name() string {
 	return ""
 }
 
+// Windows returns the windows of the multi-window multi-burn-rate alerts.
+// They're derived from the objective's window unless the alerting has its own windows.
 func (o Objective) Windows() []Window {
+	if len(o.Alerting.Windows) > 0 {
+		return o.Alerting.Windows
+	}
 	return Windows(time.Duration(o.Window))
 }
 
 func (o Objective) HasWindows(short, long model.Duration) (Window, bool) {
-	for _, w := range Windows(time.Duration(o.Window)) {
+	for _, w := range o.Windows() {
 		if w.Short == time.Duration(short) && w.Long == time.Duration(long) {
 			return w, true
 		}
@@ -63,6 +109,9 @@ const (
 	Latency       IndicatorType = iota
 	LatencyNative IndicatorType = iota
 	BoolGauge     IndicatorType = iota
+	Expression    IndicatorType = iota
+	Logs          IndicatorType = iota
+	Composite     IndicatorType = iota
 )
 
 func (o Objective) IndicatorType() IndicatorType {
@@ -78,6 +127,15 @@ func (o Objective) IndicatorType() IndicatorType {
 	if o.Indicator.BoolGauge != nil && o.Indicator.BoolGauge.Name != "" {
 		return BoolGauge
 	}
+	if o.Indicator.Expression != nil && o.Indicator.Expression.Total != "" {
+		return Expression
+	}
+	if o.Indicator.Logs != nil && o.Indicator.Logs.Total != "" {
+		return Logs
+	}
+	if o.Indicator.Composite != nil && len(o.Indicator.Composite.Objectives) > 0 {
+		return Composite
+	}
 	return Unknown
 }
 
@@ -91,6 +149,10 @@ func (o Objective) Grouping() []string {
 		return o.Indicator.LatencyNative.Grouping
 	case BoolGauge:
 		return o.Indicator.BoolGauge.Grouping
+	case Expression:
+		return o.Indicator.Expression.Grouping
+	case Logs:
+		return o.Indicator.Logs.Grouping
 	default:
 		return nil
 	}
@@ -117,6 +179,10 @@ type Indicator struct {
 	Latency       *LatencyIndicator
 	LatencyNative *LatencyNativeIndicator
 	BoolGauge     *BoolGaugeIndicator
+	Quantile      *QuantileIndicator
+	Expression    *ExpressionIndicator
+	Logs          *LogsIndicator
+	Composite     *CompositeIndicator
 }
 
 type RatioIndicator struct {
@@ -142,12 +208,171 @@ type BoolGaugeIndicator struct {
 	Grouping []string
 }
 
+// QuantileIndicator measures how often a quantile of the latency, like the p99, is faster than the latency.
+// Its rules record whether the quantile over each interval is faster, and the objective is the BoolGauge
+// of the recorded series, so objectives with it have both set. See QuantileIndicator.BoolGauge.
+type QuantileIndicator struct {
+	// Total is the histogram, either the _bucket series of a classic histogram or a native histogram.
+	Total    Metric
+	Quantile float64
+	Latency  model.Duration
+	// Interval the quantile is calculated over.
+	Interval model.Duration
+	Grouping []string
+}
+
+// Native returns whether the histogram is a native histogram.
+func (q QuantileIndicator) Native() bool {
+	return !strings.HasSuffix(q.Total.Name, "_bucket")
+}
+
+// RecordName returns the name of the series recording whether the quantile is faster than the latency.
+func (q QuantileIndicator) RecordName() string {
+	metric := strings.TrimSuffix(q.Total.Name, "_bucket")
+	return fmt.Sprintf("%s:quantile_within_latency%s", metric, q.Interval)
+}
+
+// BoolGauge returns the indicator the objective with the name is evaluated as,
+// the series recorded by the quantile's rule.
+func (q QuantileIndicator) BoolGauge(sloName string) *BoolGaugeIndicator {
+	return &BoolGaugeIndicator{
+		Metric: Metric{
+			Name: q.RecordName(),
+			LabelMatchers: []*labels.Matcher{
+				{Type: labels.MatchEqual, Name: labels.MetricName, Value: q.RecordName()},
+				{Type: labels.MatchEqual, Name: "slo", Value: sloName},
+			},
+		},
+		Grouping: q.Grouping,
+	}
+}
+
+// ExpressionIndicator is the ratio of two PromQL expressions over counters,
+// for errors and requests counted by different metrics whose labels don't match.
+// The rules wrap every series selector of the expressions in increase or rate over their windows.
+// There are no absent alerts for them, as the expressions can select any number of metrics.
+type ExpressionIndicator struct {
+	Errors   string
+	Total    string
+	Grouping []string
+}
+
+// LogsIndicator is the ratio of the lines of two LogQL log queries, for services that only log their requests.
+// Its rules are LogQL and evaluated by the Loki ruler, which writes the recorded metrics to Prometheus.
+// The alerts evaluate the log queries themselves, as the ruler can't query the recorded metrics.
+type LogsIndicator struct {
+	Errors   string
+	Total    string
+	Grouping []string
+}
+
 type Alerting struct {
 	Disabled   bool // deprecated, use Burnrates instead
 	Burnrates  bool
 	Absent     bool
 	Name       string
 	AbsentName string
+
+	// AbsentSeverity is the severity label of the absent alerts, critical by default.
+	AbsentSeverity string
+	// AbsentOverTime makes the absent alerts fire once the metrics had no samples for the duration,
+	// with absent_over_time, instead of as soon as they are absent or stale.
+	AbsentOverTime time.Duration
+
+	// SeverityLabels are added to the alerts of a severity, like critical or warning.
+	// They allow routing alerts to a specific receiver, like a PagerDuty service.
+	SeverityLabels map[string]map[string]string
+	// SeverityAnnotations are added to the alerts of a severity, like critical or warning.
+	SeverityAnnotations map[string]map[string]string
+	// MuteWindows are recurring windows during which the burn rate alerts don't fire.
+	MuteWindows []MuteWindow
+	// MaintenanceWindows are planned downtimes during which the burn rate alerts don't fire.
+	MaintenanceWindows []MaintenanceWindow
+	// Labels are added to all alerts, like the owner of the objective to route them by.
+	// The labels of a severity take precedence.
+	Labels map[string]string
+	// Annotations are added to all alerts, like a runbook_url.
+	// The annotations of a severity take precedence.
+	Annotations map[string]string
+	// Windows replace the multi-window multi-burn-rate alerts derived from the objective's window.
+	Windows []Window
+	// KeepFiringFor keeps the burn rate alerts firing for the duration after their condition cleared,
+	// so they don't flap while the burn rate is close to the threshold.
+	KeepFiringFor time.Duration
+	// PartialResponseStrategy is the partial_response_strategy of the objective's rule groups, warn or abort.
+	// Only Thanos Ruler uses it.
+	PartialResponseStrategy string
+	// Inhibit adds the BurnWindowLabel to the burn rate alerts,
+	// so the fast burning ones can inhibit the slow burning ones of the objective in Alertmanager, see InhibitRules.
+	Inhibit bool
+}
+
+// MuteWindow is a recurring window in UTC, like nightly batch jobs that are known to cause errors.
+// Only the alerts are muted, the errors still consume the error budget.
+type MuteWindow struct {
+	// Weekdays the window applies to, every day if empty.
+	Weekdays []time.Weekday
+	// Start and End are the times of day the window starts and ends at, from 0 to 24h.
+	Start time.Duration
+	End   time.Duration
+}
+
+// MaintenanceWindow is a one-off interval of planned downtime.
+// Like mute windows, only the alerts are muted and the errors still consume the error budget.
+type MaintenanceWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+// muted returns the alert expression so that it doesn't fire during any of the mute or maintenance windows.
+func (a Alerting) muted(expr string) string {
+	if len(a.MuteWindows) == 0 && len(a.MaintenanceWindows) == 0 {
+		return expr
+	}
+
+	windows := make([]string, 0, len(a.MuteWindows)+len(a.MaintenanceWindows))
+	for _, w := range a.MuteWindows {
+		window := fmt.Sprintf("(hour() * 60 + minute() >= %d < %d)", int(w.Start.Minutes()), int(w.End.Minutes()))
+		if len(w.Weekdays) > 0 {
+			days := make([]string, 0, len(w.Weekdays))
+			for _, d := range w.Weekdays {
+				days = append(days, fmt.Sprintf("day_of_week() == %d", d))
+			}
+			window = fmt.Sprintf("(%s and (%s))", window, strings.Join(days, " or "))
+		}
+		windows = append(windows, window)
+	}
+	for _, w := range a.MaintenanceWindows {
+		windows = append(windows, fmt.Sprintf("(vector(time()) >= %d < %d)", w.Start.Unix(), w.End.Unix()))
+	}
+
+	return fmt.Sprintf("%s unless on () (%s)", expr, strings.Join(windows, " or "))
+}
+
+// severityRouting adds the configured labels of all alerts and of the severity to the alert labels
+// and returns the annotations with the configured annotations of all alerts and of the severity added.
+func (a Alerting) severityRouting(severity string, labels, annotations map[string]string) map[string]string {
+	for name, value := range a.Labels {
+		labels[name] = value
+	}
+	for name, value := range a.SeverityLabels[severity] {
+		labels[name] = value
+	}
+
+	if len(a.Annotations) == 0 && len(a.SeverityAnnotations[severity]) == 0 {
+		return annotations
+	}
+	if annotations == nil {
+		annotations = make(map[string]string, len(a.Annotations)+len(a.SeverityAnnotations[severity]))
+	}
+	for name, value := range a.Annotations {
+		annotations[name] = value
+	}
+	for name, value := range a.SeverityAnnotations[severity] {
+		annotations[name] = value
+	}
+
+	return annotations
 }
 
 type Metric struct {
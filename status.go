@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	objectivesv1alpha1 "github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1"
+	"github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1/objectivesv1alpha1connect"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+type StatusCacheConfig struct {
+	StatusCacheInterval  time.Duration `default:"1m" help:"How often the statuses of all objectives are computed in the background, to serve the list of objectives without querying Prometheus on every request. Disabled if 0."`
+	StatusCacheBatchSize int           `default:"50" help:"How many objectives' statuses are computed with one combined query. Set to 1 to query every objective on its own."`
+}
+
+// Validate checks the status cache interval isn't negative and the batch size is at least 1.
+func (sc *StatusCacheConfig) Validate() error {
+	if sc.StatusCacheInterval < 0 {
+		return fmt.Errorf("--status-cache-interval must not be negative")
+	}
+	if sc.StatusCacheBatchSize < 1 {
+		return fmt.Errorf("--status-cache-batch-size must be at least 1")
+	}
+	return nil
+}
+
+// statusCache holds the current statuses of all objectives, refreshed in the background.
+// Requests for the current status of an objective as a whole are served from it,
+// requests for other times or specific groups still query Prometheus.
+type statusCache struct {
+	logger    log.Logger
+	client    objectivesv1alpha1connect.ObjectiveBackendServiceClient
+	promAPI   budgetQuerier
+	interval  time.Duration
+	batchSize int
+
+	mu      sync.RWMutex
+	entries map[string]statusCacheEntry
+}
+
+type statusCacheEntry struct {
+	statuses []*objectivesv1alpha1.ObjectiveStatus
+	updated  time.Time
+}
+
+// get returns the cached statuses of the objective.
+// Statuses not refreshed for two intervals, as Prometheus failed to answer, aren't returned.
+func (c *statusCache) get(objective slo.Objective) ([]*objectivesv1alpha1.ObjectiveStatus, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[statusCacheKey(objective)]
+	c.mu.RUnlock()
+	if !ok || time.Since(entry.updated) > 2*c.interval {
+		return nil, false
+	}
+	return entry.statuses, true
+}
+
+// statusCacheKey identifies the objective by its labels,
+// sorted as they are in random order when converted from the API.
+func statusCacheKey(objective slo.Objective) string {
+	return labels.New(objective.Labels...).String()
+}
+
+func (c *statusCache) Run(ctx context.Context) error {
+	for {
+		if err := c.refresh(ctx); err != nil {
+			level.Warn(c.logger).Log("msg", "failed to refresh objective statuses", "err", err)
+		}
+
+		// The jitter keeps multiple replicas from querying Prometheus at the same time.
+		timer := time.NewTimer(c.interval + time.Duration(rand.Int63n(int64(c.interval)/10+1)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// refresh computes the statuses of all objectives and drops those of objectives that no longer exist.
+func (c *statusCache) refresh(ctx context.Context) error {
+	resp, err := c.client.List(ctx, connect.NewRequest(&objectivesv1alpha1.ListRequest{}))
+	if err != nil {
+		return fmt.Errorf("failed to list objectives: %w", err)
+	}
+
+	objectives := make([]slo.Objective, 0, len(resp.Msg.Objectives))
+	existing := make(map[string]struct{}, len(resp.Msg.Objectives))
+	for _, o := range resp.Msg.Objectives {
+		objective := objectivesv1alpha1.ToInternal(o)
+		objectives = append(objectives, objective)
+		existing[statusCacheKey(objective)] = struct{}{}
+	}
+
+	batchSize := max(c.batchSize, 1)
+	for start := 0; start < len(objectives); start += batchSize {
+		batch := objectives[start:min(start+batchSize, len(objectives))]
+
+		statuses, err := batchStatuses(ctx, c.promAPI, batch, time.Now())
+		if err != nil {
+			// Keep the previous statuses, they expire if Prometheus keeps failing.
+			level.Warn(c.logger).Log("msg", "failed to refresh objective statuses", "objectives", len(batch), "err", err)
+			continue
+		}
+
+		c.mu.Lock()
+		if c.entries == nil {
+			c.entries = map[string]statusCacheEntry{}
+		}
+		for i, objective := range batch {
+			c.entries[statusCacheKey(objective)] = statusCacheEntry{statuses: statuses[i], updated: time.Now()}
+		}
+		c.mu.Unlock()
+	}
+
+	c.mu.Lock()
+	for key := range c.entries {
+		if _, ok := existing[key]; !ok {
+			delete(c.entries, key)
+		}
+	}
+	c.mu.Unlock()
+
+	return nil
+}
+
+// statusBatchLabel is added to the results of every objective in batched queries to tell them apart.
+const statusBatchLabel = "pyrra_status_batch"
+
+// batchStatuses computes the statuses of all objectives with one query for their totals and one for their errors.
+// The queries of all objectives are joined with or, each labeled with its index, and the results are fanned out again.
+func batchStatuses(ctx context.Context, promAPI budgetQuerier, objectives []slo.Objective, ts time.Time) ([][]*objectivesv1alpha1.ObjectiveStatus, error) {
+	totalQueries := make([]string, len(objectives))
+	errorsQueries := make([]string, len(objectives))
+	for i, o := range objectives {
+		totalQueries[i] = batchQuery(o.QueryTotal(o.Window), i)
+		errorsQueries[i] = batchQuery(o.QueryErrors(o.Window), i)
+	}
+
+	total, err := queryBatch(ctx, promAPI, strings.Join(totalQueries, " or "), len(objectives), ts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query total: %w", err)
+	}
+	errs, err := queryBatch(ctx, promAPI, strings.Join(errorsQueries, " or "), len(objectives), ts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query errors: %w", err)
+	}
+
+	statuses := make([][]*objectivesv1alpha1.ObjectiveStatus, len(objectives))
+	for i, o := range objectives {
+		statuses[i] = statusesFromVectors(o, total[i], errs[i])
+	}
+	return statuses, nil
+}
+
+func batchQuery(query string, index int) string {
+	return fmt.Sprintf(`label_replace(%s, "%s", "%d", "", "")`, query, statusBatchLabel, index)
+}
+
+// queryBatch runs the batched query and returns the samples of every objective by its index, without the batch label.
+func queryBatch(ctx context.Context, promAPI budgetQuerier, query string, n int, ts time.Time) ([]model.Vector, error) {
+	value, _, err := promAPI.Query(contextSetPromCache(ctx, 15*time.Second), query, ts)
+	if err != nil {
+		return nil, err
+	}
+	vector, ok := value.(model.Vector)
+	if !ok {
+		return nil, fmt.Errorf("expected vector, got %s", value.Type())
+	}
+
+	vectors := make([]model.Vector, n)
+	for _, sample := range vector {
+		i, err := strconv.Atoi(string(sample.Metric[statusBatchLabel]))
+		if err != nil || i < 0 || i >= n {
+			continue
+		}
+		metric := sample.Metric.Clone()
+		delete(metric, statusBatchLabel)
+		vectors[i] = append(vectors[i], &model.Sample{Metric: metric, Value: sample.Value, Timestamp: sample.Timestamp})
+	}
+	return vectors, nil
+}
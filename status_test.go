@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/require"
+
+	objectivesv1alpha1 "github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+func TestStatusCache(t *testing.T) {
+	web, api := reportObjective("web", "frontend"), reportObjective("api", "backend")
+
+	var queries int
+	c := &statusCache{
+		logger:    log.NewNopLogger(),
+		client:    staticBackend{objectives: []slo.Objective{web, api}},
+		interval:  time.Minute,
+		batchSize: 1,
+		promAPI:   batchQueryFunc(&queries),
+	}
+
+	// Handlers look up objectives as converted from the API.
+	fromAPI := func(o slo.Objective) slo.Objective {
+		return objectivesv1alpha1.ToInternal(objectivesv1alpha1.FromInternal(o))
+	}
+
+	_, ok := c.get(fromAPI(web))
+	require.False(t, ok)
+
+	require.NoError(t, c.refresh(context.Background()))
+	require.Equal(t, 4, queries)
+
+	statuses, ok := c.get(fromAPI(web))
+	require.True(t, ok)
+	require.Len(t, statuses, 1)
+	require.Equal(t, map[string]string{"handler": "/"}, statuses[0].Labels)
+	require.Equal(t, 0.995, statuses[0].Availability.Percentage)
+	require.InDelta(t, 0.5, statuses[0].Budget.Remaining, 1e-9)
+
+	// Serving from the cache doesn't query Prometheus.
+	_, ok = c.get(fromAPI(api))
+	require.True(t, ok)
+	require.Equal(t, 4, queries)
+
+	// Statuses of deleted objectives are dropped.
+	c.client = staticBackend{objectives: []slo.Objective{web}}
+	require.NoError(t, c.refresh(context.Background()))
+	_, ok = c.get(fromAPI(api))
+	require.False(t, ok)
+
+	// Statuses that couldn't be refreshed expire.
+	c.entries[statusCacheKey(fromAPI(web))] = statusCacheEntry{statuses: statuses, updated: time.Now().Add(-3 * time.Minute)}
+	_, ok = c.get(fromAPI(web))
+	require.False(t, ok)
+}
+
+func TestStatusCache_Batches(t *testing.T) {
+	objectives := make([]slo.Objective, 5)
+	for i := range objectives {
+		objectives[i] = reportObjective(fmt.Sprintf("service-%d", i), "backend")
+	}
+
+	var queries int
+	c := &statusCache{
+		logger:    log.NewNopLogger(),
+		client:    staticBackend{objectives: objectives},
+		interval:  time.Minute,
+		batchSize: 2,
+		promAPI:   batchQueryFunc(&queries),
+	}
+	require.NoError(t, c.refresh(context.Background()))
+
+	// Three batches of at most two objectives, with one query for totals and one for errors each.
+	require.Equal(t, 6, queries)
+	for _, o := range objectives {
+		statuses, ok := c.get(objectivesv1alpha1.ToInternal(objectivesv1alpha1.FromInternal(o)))
+		require.True(t, ok)
+		require.Len(t, statuses, 1)
+		require.Equal(t, map[string]string{"handler": "/"}, statuses[0].Labels)
+		require.Equal(t, 0.995, statuses[0].Availability.Percentage)
+	}
+}
+
+func TestBatchQuery(t *testing.T) {
+	o := reportObjective("web", "frontend")
+	query := strings.Join([]string{batchQuery(o.QueryTotal(o.Window), 0), batchQuery(o.QueryErrors(o.Window), 1)}, " or ")
+
+	_, err := parser.ParseExpr(query)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(query, `label_replace(sum by (handler) (http_requests:increase4w{`), query)
+	require.Contains(t, query, `, "pyrra_status_batch", "1", "", "")`)
+}
+
+// batchQueryFunc answers batched status queries with 1000 requests and 5 errors for every objective in the batch.
+func batchQueryFunc(queries *int) queryFunc {
+	return func(query string) model.Value {
+		*queries++
+		value := model.SampleValue(1000)
+		if strings.Contains(query, `code=~"5.."`) {
+			value = 5
+		}
+
+		var vector model.Vector
+		for i := 0; i < strings.Count(query, "label_replace("); i++ {
+			vector = append(vector, &model.Sample{
+				Metric: model.Metric{"handler": "/", statusBatchLabel: model.LabelValue(strconv.Itoa(i))},
+				Value:  value,
+			})
+		}
+		return vector
+	}
+}
+
+func TestStatusCacheConfig_Validate(t *testing.T) {
+	require.NoError(t, (&StatusCacheConfig{StatusCacheBatchSize: 1}).Validate())
+	require.NoError(t, (&StatusCacheConfig{StatusCacheInterval: time.Minute, StatusCacheBatchSize: 50}).Validate())
+	require.EqualError(t, (&StatusCacheConfig{StatusCacheInterval: -time.Minute, StatusCacheBatchSize: 50}).Validate(), "--status-cache-interval must not be negative")
+	require.EqualError(t, (&StatusCacheConfig{StatusCacheInterval: time.Minute}).Validate(), "--status-cache-batch-size must be at least 1")
+}
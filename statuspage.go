@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/util/strutil"
+
+	objectivesv1alpha1 "github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1"
+	"github.com/pyrra-dev/pyrra/proto/objectives/v1alpha1/objectivesv1alpha1connect"
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+type StatuspageConfig struct {
+	StatuspageAPIKey         string        `name:"statuspage-api-key" env:"PYRRA_STATUSPAGE_API_KEY" help:"Statuspage API key. If set the status of components is updated from the objectives labelled with their ID."`
+	StatuspagePageID         string        `name:"statuspage-page-id" help:"ID of the Statuspage page the components belong to."`
+	StatuspageURL            *url.URL      `name:"statuspage-url" default:"https://api.statuspage.io" help:"The URL of the Statuspage API."`
+	StatuspageComponentLabel string        `name:"statuspage-component-label" default:"pyrra.dev/statuspage-component" help:"The objective label holding the ID of the Statuspage component to update."`
+	StatuspageInterval       time.Duration `name:"statuspage-interval" default:"1m" help:"How often the status of the components is updated."`
+}
+
+// Validate checks the Statuspage API key comes with a page and an interval.
+func (sc *StatuspageConfig) Validate() error {
+	if sc.StatuspageAPIKey == "" {
+		return nil
+	}
+	if sc.StatuspagePageID == "" {
+		return fmt.Errorf("--statuspage-api-key requires --statuspage-page-id")
+	}
+	if sc.StatuspageInterval <= 0 {
+		return fmt.Errorf("--statuspage-interval must be greater than 0")
+	}
+	return nil
+}
+
+// client returns a Statuspage client if Statuspage is configured.
+func (sc StatuspageConfig) client(client *http.Client) *statuspageClient {
+	if sc.StatuspageAPIKey == "" {
+		return nil
+	}
+	return &statuspageClient{
+		url:    strings.TrimSuffix(sc.StatuspageURL.String(), "/") + "/v1/pages/" + url.PathEscape(sc.StatuspagePageID),
+		apiKey: sc.StatuspageAPIKey,
+		client: client,
+	}
+}
+
+// componentStatus is the status of a Statuspage component, ordered from best to worst.
+type componentStatus int
+
+const (
+	componentOperational componentStatus = iota
+	componentDegradedPerformance
+	componentPartialOutage
+	componentMajorOutage
+)
+
+func (s componentStatus) String() string {
+	switch s {
+	case componentDegradedPerformance:
+		return "degraded_performance"
+	case componentPartialOutage:
+		return "partial_outage"
+	case componentMajorOutage:
+		return "major_outage"
+	default:
+		return "operational"
+	}
+}
+
+// objectiveStatus returns the status of a component served by the objective.
+// Firing critical alerts mean it's partially unavailable and a major outage once the error budget is exhausted too.
+// Firing warning alerts or an exhausted error budget alone mean degraded performance.
+func objectiveStatus(critical, warning bool, remaining float64) componentStatus {
+	exhausted := remaining <= 0
+	switch {
+	case critical && exhausted:
+		return componentMajorOutage
+	case critical:
+		return componentPartialOutage
+	case warning || exhausted:
+		return componentDegradedPerformance
+	default:
+		return componentOperational
+	}
+}
+
+type statuspageComponent struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+type statuspageClient struct {
+	// url of the page's API.
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+func (c *statuspageClient) components(ctx context.Context) ([]statuspageComponent, error) {
+	var components []statuspageComponent
+	err := c.do(ctx, http.MethodGet, "/components", nil, &components)
+	return components, err
+}
+
+func (c *statuspageClient) updateComponent(ctx context.Context, id string, status componentStatus) error {
+	return c.do(ctx, http.MethodPatch, "/components/"+url.PathEscape(id), map[string]any{
+		"component": map[string]string{"status": status.String()},
+	}, nil)
+}
+
+func (c *statuspageClient) do(ctx context.Context, method, path string, in, out any) error {
+	req, err := newJSONRequest(ctx, method, c.url+path, in)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "OAuth "+c.apiKey)
+	return doJSON(c.client, req, out)
+}
+
+// statuspageSyncer updates the status of Statuspage components from the state of their objectives.
+// Components with multiple objectives get the worst status of them.
+type statuspageSyncer struct {
+	logger         log.Logger
+	client         objectivesv1alpha1connect.ObjectiveBackendServiceClient
+	promAPI        budgetQuerier
+	statuspage     *statuspageClient
+	componentLabel string
+}
+
+func (s *statuspageSyncer) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.sync(ctx); err != nil {
+			level.Warn(s.logger).Log("msg", "failed to sync Statuspage components", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *statuspageSyncer) sync(ctx context.Context) error {
+	resp, err := s.client.List(ctx, connect.NewRequest(&objectivesv1alpha1.ListRequest{}))
+	if err != nil {
+		return fmt.Errorf("failed to list objectives: %w", err)
+	}
+
+	label := apiLabelName(s.componentLabel)
+
+	var objectives []slo.Objective
+	for _, o := range resp.Msg.Objectives {
+		objective := objectivesv1alpha1.ToInternal(o)
+		if objective.Labels.Get(label) != "" {
+			objectives = append(objectives, objective)
+		}
+	}
+	if len(objectives) == 0 {
+		return nil
+	}
+
+	query := `ALERTS{slo=~".+",alertstate="firing"}`
+	value, _, err := s.promAPI.Query(ctx, query, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to query alerts: %w", err)
+	}
+	alerts, ok := value.(model.Vector)
+	if !ok {
+		return fmt.Errorf("unexpected value type %s", value.Type())
+	}
+
+	desired := map[string]componentStatus{}
+	for _, objective := range objectives {
+		var critical, warning bool
+		for _, a := range alertsMatchingObjectives(alerts, []slo.Objective{objective}, nil, false) {
+			switch a.Severity {
+			case "critical":
+				critical = true
+			case "warning":
+				warning = true
+			}
+		}
+
+		remaining := 1.0
+		value, _, err := s.promAPI.Query(ctx, objective.QueryErrorBudget(), time.Now())
+		if err != nil {
+			level.Warn(s.logger).Log("msg", "failed to query error budget", "objective", objective.Name(), "err", err)
+		} else if vector, ok := value.(model.Vector); ok && len(vector) > 0 {
+			remaining = float64(vector[0].Value)
+		}
+
+		id := objective.Labels.Get(label)
+		if status := objectiveStatus(critical, warning, remaining); status >= desired[id] {
+			desired[id] = status
+		}
+	}
+
+	components, err := s.statuspage.components(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list components: %w", err)
+	}
+
+	for _, c := range components {
+		status, ok := desired[c.ID]
+		// Maintenance is set by hand and shouldn't be overridden.
+		if !ok || c.Status == status.String() || c.Status == "under_maintenance" {
+			continue
+		}
+		if err := s.statuspage.updateComponent(ctx, c.ID, status); err != nil {
+			level.Warn(s.logger).Log("msg", "failed to update component", "component", c.Name, "err", err)
+			continue
+		}
+		level.Info(s.logger).Log("msg", "updated component", "component", c.Name, "status", status)
+	}
+
+	return nil
+}
+
+// apiLabelName returns the name of the objective label as served by the API,
+// which is without the pyrra.dev/ prefix and sanitized for Prometheus.
+func apiLabelName(name string) string {
+	return strutil.SanitizeLabelName(strings.TrimPrefix(name, slo.PropagationLabelsPrefix))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pyrra-dev/pyrra/slo"
+)
+
+func TestObjectiveStatus(t *testing.T) {
+	require.Equal(t, componentOperational, objectiveStatus(false, false, 0.5))
+	require.Equal(t, componentDegradedPerformance, objectiveStatus(false, true, 0.5))
+	require.Equal(t, componentDegradedPerformance, objectiveStatus(false, false, -0.1))
+	require.Equal(t, componentPartialOutage, objectiveStatus(true, true, 0.5))
+	require.Equal(t, componentMajorOutage, objectiveStatus(true, false, 0))
+}
+
+func TestStatuspageSyncer_Sync(t *testing.T) {
+	objective := func(name, component string) slo.Objective {
+		o := reportObjective(name, "")
+		o.Labels = labels.FromStrings(labels.MetricName, name, "statuspage-component", component)
+		return o
+	}
+
+	var ticket slo.Window
+	for _, w := range objective("", "").Windows() {
+		if w.Severity == "warning" {
+			ticket = w
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		updates = map[string]string{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		require.Equal(t, "OAuth key", r.Header.Get("Authorization"))
+		switch r.Method {
+		case http.MethodGet:
+			require.Equal(t, "/v1/pages/page/components", r.URL.Path)
+			_ = json.NewEncoder(w).Encode([]statuspageComponent{
+				{ID: "api", Name: "API", Status: "operational"},
+				{ID: "web", Name: "Web", Status: "major_outage"},
+				{ID: "db", Name: "Database", Status: "under_maintenance"},
+				{ID: "other", Name: "Other", Status: "major_outage"},
+			})
+		case http.MethodPatch:
+			var req struct {
+				Component struct {
+					Status string `json:"status"`
+				} `json:"component"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			updates[strings.TrimPrefix(r.URL.Path, "/v1/pages/page/components/")] = req.Component.Status
+		}
+	}))
+	defer server.Close()
+
+	syncer := &statuspageSyncer{
+		logger: log.NewNopLogger(),
+		client: staticBackend{objectives: []slo.Objective{
+			objective("api-requests", "api"),
+			objective("api-latency", "api"),
+			objective("web-requests", "web"),
+			objective("db-queries", "db"),
+		}},
+		promAPI: queryFunc(func(query string) model.Value {
+			if strings.HasPrefix(query, "ALERTS") {
+				return model.Vector{{Metric: model.Metric{
+					"alertstate": "firing",
+					"severity":   "warning",
+					"slo":        "api-latency",
+					"short":      model.LabelValue(model.Duration(ticket.Short).String()),
+					"long":       model.LabelValue(model.Duration(ticket.Long).String()),
+				}, Value: 1}}
+			}
+			return model.Vector{{Value: 0.5}}
+		}),
+		statuspage:     &statuspageClient{url: server.URL + "/v1/pages/page", apiKey: "key", client: server.Client()},
+		componentLabel: "pyrra.dev/statuspage-component",
+	}
+
+	require.NoError(t, syncer.sync(context.Background()))
+	require.Equal(t, map[string]string{
+		"api": "degraded_performance",
+		"web": "operational",
+	}, updates)
+}
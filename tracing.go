@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// tracer is used for spans of the API and its integrations.
+// Without --tracing-endpoint it's a no-op.
+var tracer = otel.Tracer("github.com/pyrra-dev/pyrra")
+
+type TracingConfig struct {
+	TracingEndpoint    *url.URL `name:"tracing-endpoint" help:"The URL of an OpenTelemetry collector, like http://otel-collector:4318, to send traces to via OTLP/HTTP. Tracing is disabled if empty."`
+	TracingSampleRatio float64  `name:"tracing-sample-ratio" default:"1" help:"Ratio of traces to sample, from 0 to 1. Traces already sampled by the caller are always sampled."`
+}
+
+// Validate checks the tracing endpoint is an HTTP URL and the sample ratio is between 0 and 1.
+func (tc *TracingConfig) Validate() error {
+	if tc.TracingEndpoint == nil {
+		return nil
+	}
+	if tc.TracingEndpoint.Scheme != "http" && tc.TracingEndpoint.Scheme != "https" {
+		return fmt.Errorf("--tracing-endpoint must be an http or https URL")
+	}
+	if tc.TracingSampleRatio < 0 || tc.TracingSampleRatio > 1 {
+		return fmt.Errorf("--tracing-sample-ratio must be between 0 and 1")
+	}
+	return nil
+}
+
+// startTracing sets up the global tracer provider and the W3C trace context propagation.
+// The returned function flushes the remaining spans and stops the export.
+func startTracing(ctx context.Context, config TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if config.TracingEndpoint == nil {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(config.TracingEndpoint.String()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "pyrra"))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.TracingSampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
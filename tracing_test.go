@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+	prometheusapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type tracingPromAPI struct {
+	err error
+}
+
+func (a tracingPromAPI) Query(context.Context, string, time.Time, ...prometheusapiv1.Option) (model.Value, prometheusapiv1.Warnings, error) {
+	return model.Vector{{Value: 1}}, nil, a.err
+}
+
+func (a tracingPromAPI) QueryRange(context.Context, string, prometheusapiv1.Range, ...prometheusapiv1.Option) (model.Value, prometheusapiv1.Warnings, error) {
+	return model.Matrix{{Values: []model.SamplePair{{Value: 1}}}}, nil, a.err
+}
+
+func TestPromCacheTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	cache, err := ristretto.NewCache(&ristretto.Config{NumCounters: 100, MaxCost: 10 * 1000, BufferItems: 64})
+	require.NoError(t, err)
+	p := &promCache{api: tracingPromAPI{}, cache: cache}
+
+	ctx := contextSetPromCache(context.Background(), time.Minute)
+	_, _, err = p.Query(ctx, "up", time.Now())
+	require.NoError(t, err)
+	cache.Wait()
+	_, _, err = p.Query(ctx, "up", time.Now())
+	require.NoError(t, err)
+
+	p.api = tracingPromAPI{err: errors.New("unavailable")}
+	_, _, err = p.QueryRange(ctx, "down", prometheusapiv1.Range{Start: time.Now().Add(-time.Hour), End: time.Now()})
+	require.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+
+	require.Equal(t, "prometheus.Query", spans[0].Name())
+	require.Contains(t, spans[0].Attributes(), attribute.String("query", "up"))
+	require.NotContains(t, spans[0].Attributes(), attribute.Bool("cache.hit", true))
+	require.Contains(t, spans[1].Attributes(), attribute.Bool("cache.hit", true))
+
+	require.Equal(t, "prometheus.QueryRange", spans[2].Name())
+	require.Contains(t, spans[2].Attributes(), attribute.String("range", "1h0m0s"))
+	require.Equal(t, codes.Error, spans[2].Status().Code)
+}
+
+func TestTracingConfig_Validate(t *testing.T) {
+	require.NoError(t, (&TracingConfig{}).Validate())
+
+	endpoint, _ := url.Parse("http://collector:4318")
+	require.NoError(t, (&TracingConfig{TracingEndpoint: endpoint, TracingSampleRatio: 0.1}).Validate())
+	require.EqualError(t, (&TracingConfig{TracingEndpoint: endpoint, TracingSampleRatio: 2}).Validate(), "--tracing-sample-ratio must be between 0 and 1")
+
+	endpoint, _ = url.Parse("collector:4318")
+	require.EqualError(t, (&TracingConfig{TracingEndpoint: endpoint, TracingSampleRatio: 1}).Validate(), "--tracing-endpoint must be an http or https URL")
+}
@@ -262,6 +262,32 @@ const Detail = () => {
             ) : (
               <></>
             )}
+            {objective.owner !== undefined ? (
+              <Col xs={12} className="col-xxxl-10 offset-xxxl-1">
+                <p>
+                  Owned by <strong>{objective.owner.team}</strong>
+                  {objective.owner.tier !== '' ? <> ({objective.owner.tier})</> : <></>}
+                  {objective.owner.slack !== '' ? <> · {objective.owner.slack}</> : <></>}
+                  {objective.owner.escalationPolicy !== '' ? (
+                    <> · escalation policy {objective.owner.escalationPolicy}</>
+                  ) : (
+                    <></>
+                  )}
+                  {objective.owner.runbookUrl !== '' ? (
+                    <>
+                      {' · '}
+                      <a href={objective.owner.runbookUrl} target="_blank" rel="noreferrer">
+                        Runbook
+                      </a>
+                    </>
+                  ) : (
+                    <></>
+                  )}
+                </p>
+              </Col>
+            ) : (
+              <></>
+            )}
           </Row>
           <Row>
             <Col className="col-xxxl-10 offset-xxxl-1">
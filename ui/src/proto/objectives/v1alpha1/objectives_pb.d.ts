@@ -20,6 +20,27 @@ export declare class ListRequest extends Message<ListRequest> {
    */
   grouping: string;
 
+  /**
+   * Kubernetes label selector the objectives' labels match, like team=payments.
+   *
+   * @generated from field: string label_selector = 3;
+   */
+  labelSelector: string;
+
+  /**
+   * Most objectives in the response, all of them if 0.
+   *
+   * @generated from field: int32 page_size = 4;
+   */
+  pageSize: number;
+
+  /**
+   * The next_page_token of the previous response, to list the objectives after it.
+   *
+   * @generated from field: string page_token = 5;
+   */
+  pageToken: string;
+
   constructor(data?: PartialMessage<ListRequest>);
 
   static readonly runtime: typeof proto3;
@@ -44,6 +65,13 @@ export declare class ListResponse extends Message<ListResponse> {
    */
   objectives: Objective[];
 
+  /**
+   * Lists the next objectives as page_token, empty if there are none.
+   *
+   * @generated from field: string next_page_token = 2;
+   */
+  nextPageToken: string;
+
   constructor(data?: PartialMessage<ListResponse>);
 
   static readonly runtime: typeof proto3;
@@ -98,6 +126,16 @@ export declare class Objective extends Message<Objective> {
    */
   queries?: Queries;
 
+  /**
+   * @generated from field: objectives.v1alpha1.Owner owner = 8;
+   */
+  owner?: Owner;
+
+  /**
+   * @generated from field: objectives.v1alpha1.Sync sync = 9;
+   */
+  sync?: Sync;
+
   constructor(data?: PartialMessage<Objective>);
 
   static readonly runtime: typeof proto3;
@@ -1073,3 +1111,95 @@ export declare class GraphDurationResponse extends Message<GraphDurationResponse
   static equals(a: GraphDurationResponse | PlainMessage<GraphDurationResponse> | undefined, b: GraphDurationResponse | PlainMessage<GraphDurationResponse> | undefined): boolean;
 }
 
+/**
+ * Owner is the team owning an objective and its contact details.
+ *
+ * @generated from message objectives.v1alpha1.Owner
+ */
+export declare class Owner extends Message<Owner> {
+  /**
+   * @generated from field: string team = 1;
+   */
+  team: string;
+
+  /**
+   * @generated from field: string slack = 2;
+   */
+  slack: string;
+
+  /**
+   * @generated from field: string escalation_policy = 3;
+   */
+  escalationPolicy: string;
+
+  /**
+   * @generated from field: string runbook_url = 4;
+   */
+  runbookUrl: string;
+
+  /**
+   * @generated from field: string tier = 5;
+   */
+  tier: string;
+
+  constructor(data?: PartialMessage<Owner>);
+
+  static readonly runtime: typeof proto3;
+  static readonly typeName = "objectives.v1alpha1.Owner";
+  static readonly fields: FieldList;
+
+  static fromBinary(bytes: Uint8Array, options?: Partial<BinaryReadOptions>): Owner;
+
+  static fromJson(jsonValue: JsonValue, options?: Partial<JsonReadOptions>): Owner;
+
+  static fromJsonString(jsonString: string, options?: Partial<JsonReadOptions>): Owner;
+
+  static equals(a: Owner | PlainMessage<Owner> | undefined, b: Owner | PlainMessage<Owner> | undefined): boolean;
+}
+
+/**
+ * Sync is the outcome of the operator's last reconcile of an objective.
+ *
+ * @generated from message objectives.v1alpha1.Sync
+ */
+export declare class Sync extends Message<Sync> {
+  /**
+   * @generated from field: bool ready = 1;
+   */
+  ready: boolean;
+
+  /**
+   * @generated from field: string reason = 2;
+   */
+  reason: string;
+
+  /**
+   * @generated from field: string message = 3;
+   */
+  message: string;
+
+  /**
+   * @generated from field: string rule_type = 4;
+   */
+  ruleType: string;
+
+  /**
+   * @generated from field: string rule_name = 5;
+   */
+  ruleName: string;
+
+  constructor(data?: PartialMessage<Sync>);
+
+  static readonly runtime: typeof proto3;
+  static readonly typeName = "objectives.v1alpha1.Sync";
+  static readonly fields: FieldList;
+
+  static fromBinary(bytes: Uint8Array, options?: Partial<BinaryReadOptions>): Sync;
+
+  static fromJson(jsonValue: JsonValue, options?: Partial<JsonReadOptions>): Sync;
+
+  static fromJsonString(jsonString: string, options?: Partial<JsonReadOptions>): Sync;
+
+  static equals(a: Sync | PlainMessage<Sync> | undefined, b: Sync | PlainMessage<Sync> | undefined): boolean;
+}
+
@@ -13,6 +13,9 @@ export const ListRequest = proto3.makeMessageType(
   () => [
     { no: 1, name: "expr", kind: "scalar", T: 9 /* ScalarType.STRING */ },
     { no: 2, name: "grouping", kind: "scalar", T: 9 /* ScalarType.STRING */ },
+    { no: 3, name: "label_selector", kind: "scalar", T: 9 /* ScalarType.STRING */ },
+    { no: 4, name: "page_size", kind: "scalar", T: 5 /* ScalarType.INT32 */ },
+    { no: 5, name: "page_token", kind: "scalar", T: 9 /* ScalarType.STRING */ },
   ],
 );
 
@@ -23,6 +26,7 @@ export const ListResponse = proto3.makeMessageType(
   "objectives.v1alpha1.ListResponse",
   () => [
     { no: 1, name: "objectives", kind: "message", T: Objective, repeated: true },
+    { no: 2, name: "next_page_token", kind: "scalar", T: 9 /* ScalarType.STRING */ },
   ],
 );
 
@@ -39,6 +43,8 @@ export const Objective = proto3.makeMessageType(
     { no: 5, name: "indicator", kind: "message", T: Indicator },
     { no: 6, name: "config", kind: "scalar", T: 9 /* ScalarType.STRING */ },
     { no: 7, name: "queries", kind: "message", T: Queries },
+    { no: 8, name: "owner", kind: "message", T: Owner },
+    { no: 9, name: "sync", kind: "message", T: Sync },
   ],
 );
 
@@ -391,3 +397,35 @@ export const GraphDurationResponse = proto3.makeMessageType(
   ],
 );
 
+/**
+ * Owner is the team owning an objective and its contact details.
+ *
+ * @generated from message objectives.v1alpha1.Owner
+ */
+export const Owner = proto3.makeMessageType(
+  "objectives.v1alpha1.Owner",
+  () => [
+    { no: 1, name: "team", kind: "scalar", T: 9 /* ScalarType.STRING */ },
+    { no: 2, name: "slack", kind: "scalar", T: 9 /* ScalarType.STRING */ },
+    { no: 3, name: "escalation_policy", kind: "scalar", T: 9 /* ScalarType.STRING */ },
+    { no: 4, name: "runbook_url", kind: "scalar", T: 9 /* ScalarType.STRING */ },
+    { no: 5, name: "tier", kind: "scalar", T: 9 /* ScalarType.STRING */ },
+  ],
+);
+
+/**
+ * Sync is the outcome of the operator's last reconcile of an objective.
+ *
+ * @generated from message objectives.v1alpha1.Sync
+ */
+export const Sync = proto3.makeMessageType(
+  "objectives.v1alpha1.Sync",
+  () => [
+    { no: 1, name: "ready", kind: "scalar", T: 8 /* ScalarType.BOOL */ },
+    { no: 2, name: "reason", kind: "scalar", T: 9 /* ScalarType.STRING */ },
+    { no: 3, name: "message", kind: "scalar", T: 9 /* ScalarType.STRING */ },
+    { no: 4, name: "rule_type", kind: "scalar", T: 9 /* ScalarType.STRING */ },
+    { no: 5, name: "rule_name", kind: "scalar", T: 9 /* ScalarType.STRING */ },
+  ],
+);
+
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/pyrra-dev/pyrra/kubernetes/controllers"
+)
+
+// cmdVerify checks that the metrics and label matchers of the objectives in the files select series in Prometheus.
+// It prints the problems found per file and fails if there are any.
+func cmdVerify(logger log.Logger, out io.Writer, files []string, querier controllers.BudgetPolicyQuerier) int {
+	ctx := context.Background()
+
+	failed := 0
+	for _, file := range files {
+		problems, err := verifyObjectiveFile(ctx, querier, file)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to verify objective", "file", file, "err", err)
+			return 1
+		}
+		if len(problems) == 0 {
+			fmt.Fprintf(out, "%s: ok\n", file)
+			continue
+		}
+		failed++
+		for _, p := range problems {
+			fmt.Fprintf(out, "%s: %s\n", file, p)
+		}
+	}
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func verifyObjectiveFile(ctx context.Context, querier controllers.BudgetPolicyQuerier, file string) ([]string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	kubeObjective, objective, err := objectiveFromBytes(file, content)
+	if err != nil {
+		return nil, err
+	}
+	if kubeObjective.GetAnnotations()[controllers.LokiRulerAnnotation] == "loki" {
+		// The metrics of objectives evaluated by Loki are LogQL queries Prometheus doesn't know.
+		return nil, nil
+	}
+	return controllers.VerifyMetrics(ctx, querier, objective)
+}
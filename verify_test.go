@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	prometheusapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+// seriesQuerier returns a series for the queries of existing series.
+type seriesQuerier map[string]bool
+
+func (q seriesQuerier) Query(_ context.Context, query string, _ time.Time, _ ...prometheusapiv1.Option) (model.Value, prometheusapiv1.Warnings, error) {
+	if !q[query] {
+		return model.Vector{}, nil, nil
+	}
+	return model.Vector{{Value: 1}}, nil, nil
+}
+
+func TestCmdVerify(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "api.yaml")
+	require.NoError(t, os.WriteFile(valid, []byte(strings.Replace(ciObjective, "%s", "99", 1)), 0o644))
+	typo := filepath.Join(dir, "typo.yaml")
+	require.NoError(t, os.WriteFile(typo, []byte(strings.NewReplacer("%s", "99", `job="api"`, `job="apu"`).Replace(ciObjective)), 0o644))
+
+	querier := seriesQuerier{
+		`count(http_requests_total)`:            true,
+		`count(http_requests_total{job="api"})`: true,
+		`count(http_requests_total{code!=""})`:  true,
+		`count(http_requests_total{job!=""})`:   true,
+	}
+
+	var out bytes.Buffer
+	require.Equal(t, 0, cmdVerify(log.NewNopLogger(), &out, []string{valid}, querier))
+	require.Equal(t, valid+": ok\n", out.String())
+
+	out.Reset()
+	require.Equal(t, 1, cmdVerify(log.NewNopLogger(), &out, []string{valid, typo}, querier))
+	require.Equal(t, valid+": ok\n"+typo+`: http_requests_total{job="apu"} selects no series`+"\n", out.String())
+}